@@ -29,6 +29,16 @@ const (
 	MetaResponseStatus  = "x-msg-response-status"
 	MetaResponseErrCode = "x-msg-response-errcode"
 	MetaPathConstructor = "x-msg-path-constructor"
+	MetaTraceID         = "x-msg-trace-id"
+	MetaWriter          = "x-msg-writer"
+	MetaSequence        = "x-msg-seq"
+	MetaMergeStrategy   = "x-msg-merge-strategy"
+	MetaMergeConflicts  = "x-msg-merge-conflicts"
+	MetaMirror          = "x-msg-mirror"
+	MetaLiveness        = "x-msg-liveness"
+	MetaNamespace       = "x-msg-namespace"
+	MetaSyncIndex       = "x-msg-sync-index"
+	MetaRoles           = "x-msg-roles" // roles of the client writing, comma separated.
 )
 
 type PathConstructor string
@@ -53,6 +63,7 @@ type SystemOp string
 const (
 	OpCreate SystemOp = "core.event.System.Create"
 	OpDelete SystemOp = "core.event.System.Delete"
+	OpReload SystemOp = "core.event.System.Reload"
 )
 
 type Attribution interface {
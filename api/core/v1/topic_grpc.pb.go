@@ -19,6 +19,7 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type TopicClient interface {
 	TopicEventHandler(ctx context.Context, in *TopicEventRequest, opts ...grpc.CallOption) (*TopicEventResponse, error)
+	TopicEventsHandler(ctx context.Context, in *TopicEventsRequest, opts ...grpc.CallOption) (*TopicEventsResponse, error)
 }
 
 type topicClient struct {
@@ -38,11 +39,21 @@ func (c *topicClient) TopicEventHandler(ctx context.Context, in *TopicEventReque
 	return out, nil
 }
 
+func (c *topicClient) TopicEventsHandler(ctx context.Context, in *TopicEventsRequest, opts ...grpc.CallOption) (*TopicEventsResponse, error) {
+	out := new(TopicEventsResponse)
+	err := c.cc.Invoke(ctx, "/api.core.v1.Topic/TopicEventsHandler", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TopicServer is the server API for Topic service.
 // All implementations must embed UnimplementedTopicServer
 // for forward compatibility
 type TopicServer interface {
 	TopicEventHandler(context.Context, *TopicEventRequest) (*TopicEventResponse, error)
+	TopicEventsHandler(context.Context, *TopicEventsRequest) (*TopicEventsResponse, error)
 	mustEmbedUnimplementedTopicServer()
 }
 
@@ -53,6 +64,9 @@ type UnimplementedTopicServer struct {
 func (UnimplementedTopicServer) TopicEventHandler(context.Context, *TopicEventRequest) (*TopicEventResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method TopicEventHandler not implemented")
 }
+func (UnimplementedTopicServer) TopicEventsHandler(context.Context, *TopicEventsRequest) (*TopicEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TopicEventsHandler not implemented")
+}
 func (UnimplementedTopicServer) mustEmbedUnimplementedTopicServer() {}
 
 // UnsafeTopicServer may be embedded to opt out of forward compatibility for this service.
@@ -84,6 +98,24 @@ func _Topic_TopicEventHandler_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Topic_TopicEventsHandler_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopicEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TopicServer).TopicEventsHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.core.v1.Topic/TopicEventsHandler",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TopicServer).TopicEventsHandler(ctx, req.(*TopicEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Topic_ServiceDesc is the grpc.ServiceDesc for Topic service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -95,6 +127,10 @@ var Topic_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "TopicEventHandler",
 			Handler:    _Topic_TopicEventHandler_Handler,
 		},
+		{
+			MethodName: "TopicEventsHandler",
+			Handler:    _Topic_TopicEventsHandler_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/core/v1/topic.proto",
@@ -26,6 +26,7 @@ import (
 
 type TopicHTTPServer interface {
 	TopicEventHandler(context.Context, *TopicEventRequest) (*TopicEventResponse, error)
+	TopicEventsHandler(context.Context, *TopicEventsRequest) (*TopicEventsResponse, error)
 }
 
 type TopicHTTPHandler struct {
@@ -90,6 +91,74 @@ func (h *TopicHTTPHandler) TopicEventHandler(req *go_restful.Request, resp *go_r
 	}
 }
 
+// TopicEventsHandler handle dapr bulk subscribe messages, entries of the bulk
+// are cloud events, statuses of entries responded by entryId.
+func (h *TopicHTTPHandler) TopicEventsHandler(req *go_restful.Request, resp *go_restful.Response) {
+	in := TopicEventsRequest{}
+	defer req.Request.Body.Close()
+	bytes, err := ioutil.ReadAll(req.Request.Body)
+	if nil != err {
+		tErr := errors.FromError(err)
+		httpCode := errors.GRPCToHTTPStatusCode(tErr.GRPCStatus().Code())
+		resp.WriteErrorString(httpCode, tErr.Message)
+		return
+	}
+
+	for _, entry := range gjson.GetBytes(bytes, "entries").Array() {
+		event := entry.Get("event")
+		if event.Type == gjson.String {
+			event = gjson.Parse(event.String())
+		}
+
+		ev := TopicEventRequest{RawData: []byte(event.Get("data").String())}
+		if res := event.Get("data_base64"); res.Type != gjson.Null {
+			// decode base64.
+			base64.StdEncoding.Decode(ev.RawData, []byte(res.String()))
+		}
+
+		meta := Metadata{}
+		if err = json.Unmarshal([]byte(event.Raw), &meta); nil != err {
+			tErr := errors.FromError(err)
+			httpCode := errors.GRPCToHTTPStatusCode(tErr.GRPCStatus().Code())
+			resp.WriteErrorString(httpCode, tErr.Message)
+			return
+		}
+
+		meta.Id = entry.Get("entryId").String()
+		ev.Meta = &meta
+		in.Events = append(in.Events, &ev)
+	}
+
+	ctx := transportHTTP.ContextWithHeader(req.Request.Context(), req.Request.Header)
+
+	out, err := h.srv.TopicEventsHandler(ctx, &in)
+	if err != nil {
+		tErr := errors.FromError(err)
+		httpCode := errors.GRPCToHTTPStatusCode(tErr.GRPCStatus().Code())
+		resp.WriteErrorString(httpCode, tErr.Message)
+		return
+	}
+
+	type entryStatus struct {
+		EntryID string `json:"entryId"`
+		Status  string `json:"status"`
+	}
+	statuses := make([]entryStatus, len(out.Statuses))
+	for index, status := range out.Statuses {
+		statuses[index] = entryStatus{EntryID: status.EntryId, Status: status.Status}
+	}
+	result, err := json.Marshal(map[string]interface{}{"statuses": statuses})
+	if err != nil {
+		resp.WriteErrorString(http.StatusInternalServerError, err.Error())
+		return
+	}
+	_, err = resp.Write(result)
+	if err != nil {
+		resp.WriteErrorString(http.StatusInternalServerError, err.Error())
+		return
+	}
+}
+
 func isBase64() bool {
 	return false
 }
@@ -112,4 +181,6 @@ func RegisterTopicHTTPServer(container *go_restful.Container, srv TopicHTTPServe
 	handler := newTopicHTTPHandler(srv)
 	ws.Route(ws.POST("/topic").
 		To(handler.TopicEventHandler))
+	ws.Route(ws.POST("/topic/bulk").
+		To(handler.TopicEventsHandler))
 }
@@ -0,0 +1,36 @@
+// Code generated by protoc-gen-go-http. DO NOT EDIT.
+// versions:
+// protoc-gen-go-http 0.1.0
+
+package v1
+
+import (
+	go_restful "github.com/emicklei/go-restful"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the tkeel package it is being compiled against.
+// import package.context.http.anypb.result.protojson.go_restful.errors.emptypb.
+
+type ReadyzHTTPHandler interface {
+	Readyz(req *go_restful.Request, resp *go_restful.Response)
+}
+
+func RegisterReadyzHTTPServer(container *go_restful.Container, metricHandler ReadyzHTTPHandler) {
+	var ws *go_restful.WebService
+	for _, v := range container.RegisteredWebServices() {
+		if v.RootPath() == "/ops" {
+			ws = v
+			break
+		}
+	}
+	if ws == nil {
+		ws = new(go_restful.WebService)
+		ws.ApiVersion("/v1")
+		ws.Path("/ops")
+		container.Add(ws)
+	}
+
+	ws.Route(ws.GET("/readyz").
+		To(metricHandler.Readyz))
+}
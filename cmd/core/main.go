@@ -38,6 +38,8 @@ import (
 	"github.com/tkeel-io/core/pkg/repository"
 	"github.com/tkeel-io/core/pkg/repository/dao"
 	"github.com/tkeel-io/core/pkg/resource"
+	_ "github.com/tkeel-io/core/pkg/resource/blob/dapr"
+	_ "github.com/tkeel-io/core/pkg/resource/blob/memory"
 	_ "github.com/tkeel-io/core/pkg/resource/pubsub/dapr"
 	_ "github.com/tkeel-io/core/pkg/resource/pubsub/kafka"
 	_ "github.com/tkeel-io/core/pkg/resource/pubsub/noop"
@@ -56,6 +58,7 @@ import (
 	"github.com/tkeel-io/core/pkg/service"
 	"github.com/tkeel-io/core/pkg/types"
 	"github.com/tkeel-io/core/pkg/util"
+	"github.com/tkeel-io/core/pkg/util/dapr"
 	"github.com/tkeel-io/core/pkg/util/discovery"
 	_ "github.com/tkeel-io/core/pkg/util/transport"
 	"github.com/tkeel-io/core/pkg/version"
@@ -150,6 +153,7 @@ func core(cmd *cobra.Command, args []string) {
 
 	// new servers.
 	httpSrv := http.NewServer(config.Get().Server.HTTPAddr)
+	httpSrv.Container.Filter(service.AuthFilter(config.Get().Auth.Secret))
 	grpcSrv := grpc.NewServer(config.Get().Server.GRPCAddr)
 	serverList := []transport.Server{httpSrv, grpcSrv}
 
@@ -219,6 +223,16 @@ func core(cmd *cobra.Command, args []string) {
 	if err = search.Init(config.Get().Components.SearchEngine); nil != err {
 		log.Fatal(err)
 	}
+	search.GlobalService.UseFieldMapping(config.Get().Components.FieldMapping()).
+		UseTypeMappings(config.Get().Components.TypeMappings())
+	if batch := config.Get().IndexBatch; batch.Enabled {
+		search.GlobalService.UseIndexBatch(batch.Size, time.Duration(batch.Interval)*time.Millisecond)
+	}
+	for typeName := range config.Get().Components.TypeMappings() {
+		if err = search.GlobalService.EnsureIndexMapping(context.Background(), typeName); nil != err {
+			log.L().Warn("ensure search index mapping", logf.Type(typeName), logf.Error(err))
+		}
+	}
 
 	var coreDao dao.IDao
 	if coreDao, err = dao.New(ctx, config.Get().Components.Store, config.Get().Components.Etcd); nil != err {
@@ -235,13 +249,27 @@ func core(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 	_gopsSrv.SetNode(nodeInstance)
+	_probeSrv.AddCheck(service.DependencyDapr, dapr.Healthz).
+		AddCheck(service.DependencyEtcd, coreDao.Ping).
+		AddCheck(service.DependencySearch, search.GlobalService.Ping).
+		AddCheck(service.DependencyRuntime, nodeInstance.Ready)
 
 	// initialize core services.
+	_apiManager.SetSearchClient(search.GlobalService)
+	_apiManager.SetLivenessSource(nodeInstance)
 	initialzeService(_apiManager, search.GlobalService)
+	// drained on stop, producers before the buffers they write to.
+	_apiManager.AddDrainer("ingress", _topicSrv)
+	_apiManager.AddDrainer("runtime", nodeInstance)
+	_apiManager.AddDrainer("search", apim.DrainFunc(search.GlobalService.Stop))
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
 	<-stop
 
+	if err = _apiManager.Stop(context.TODO()); err != nil {
+		log.L().Error("drain buffered data", logf.Error(err))
+	}
+	nodeInstance.Stop()
 	if err = coreApp.Stop(context.TODO()); err != nil {
 		log.Fatal(err)
 	}
@@ -272,6 +300,7 @@ var (
 	_rawdataSrv      *service.RawdataService
 	_metricsSrv      *service.MetricsService
 	_gopsSrv         *service.GOPSService
+	_probeSrv        *service.ProbeService
 )
 
 // serviceRegisterToCoreV1 register your services here.
@@ -328,6 +357,13 @@ func serviceRegisterToCoreV1(ctx context.Context, httpSrv *http.Server, grpcSrv
 	}
 	metricsv1.RegisterMetricsHTTPServer(httpSrv.Container, _metricsSrv)
 
+	// register probe service.
+	if _probeSrv, err = service.NewProbeService(); nil != err {
+		log.Fatal(err)
+	}
+	corev1.RegisterProbeHTTPServer(httpSrv.Container, _probeSrv)
+	opsv1.RegisterReadyzHTTPServer(httpSrv.Container, _probeSrv)
+
 	log.L().Debug("RegisterDebugHTTPServer")
 }
 
@@ -0,0 +1,23 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+type AuthConfig struct {
+	// Secret HMAC key of the HS256 identity tokens carried by the X-Tkeel-Identity header,
+	// signed by the gateway after authenticating the caller. Callers carry no identity if empty.
+	Secret string `yaml:"secret" mapstructure:"secret"`
+}
@@ -40,12 +40,25 @@ var _cmdViper *viper.Viper
 var _config = defaultConfig()
 
 type Configuration struct {
-	Proxy      Proxy          `yaml:"proxy" mapstructure:"proxy"`
-	Server     Server         `yaml:"server" mapstructure:"server"`
-	Logger     LogConfig      `yaml:"logger" mapstructure:"logger"`
-	Discovery  Discovery      `yaml:"discovery" mapstructure:"discovery"`
-	Components Components     `yaml:"components" mapstructure:"components"`
-	Dispatcher DispatchConfig `yaml:"dispatcher" mapstructure:"dispatcher"`
+	Proxy      Proxy            `yaml:"proxy" mapstructure:"proxy"`
+	Server     Server           `yaml:"server" mapstructure:"server"`
+	Logger     LogConfig        `yaml:"logger" mapstructure:"logger"`
+	Discovery  Discovery        `yaml:"discovery" mapstructure:"discovery"`
+	Components Components       `yaml:"components" mapstructure:"components"`
+	Dispatcher DispatchConfig   `yaml:"dispatcher" mapstructure:"dispatcher"`
+	Quota      QuotaConfig      `yaml:"quota" mapstructure:"quota"`
+	Validation ValidationConfig `yaml:"validation" mapstructure:"validation"`
+	DeadLetter DeadLetterConfig `yaml:"dead_letter" mapstructure:"dead_letter"`
+	EntityLock EntityLockConfig `yaml:"entity_lock" mapstructure:"entity_lock"`
+	Ingress    IngressConfig    `yaml:"ingress" mapstructure:"ingress"`
+	Notify     NotifyConfig     `yaml:"notify" mapstructure:"notify"`
+	Writers    WritersConfig    `yaml:"writers" mapstructure:"writers"`
+	Snapshot   SnapshotConfig   `yaml:"snapshot" mapstructure:"snapshot"`
+	Ordering   OrderingConfig   `yaml:"ordering" mapstructure:"ordering"`
+	Expiry     ExpiryConfig     `yaml:"expiry" mapstructure:"expiry"`
+	IndexBatch IndexBatchConfig `yaml:"index_batch" mapstructure:"index_batch"`
+	Liveness   LivenessConfig   `yaml:"liveness" mapstructure:"liveness"`
+	Auth       AuthConfig       `yaml:"auth" mapstructure:"auth"`
 }
 
 type Server struct {
@@ -54,6 +67,44 @@ type Server struct {
 	HTTPAddr string   `yaml:"http_addr" mapstructure:"http_addr"`
 	GRPCAddr string   `yaml:"grpc_addr" mapstructure:"grpc_addr"`
 	Sources  []string `yaml:"sources" mapstructure:"sources"`
+	// MaterializeTimeout seconds to wait runtime materializing a created entity.
+	MaterializeTimeout int64 `yaml:"materialize_timeout" mapstructure:"materialize_timeout"`
+	// QueryFieldsLimit max entities returned by a field projection query.
+	QueryFieldsLimit int `yaml:"query_fields_limit" mapstructure:"query_fields_limit"`
+	// DrainTimeout seconds to flush buffered data on shutdown.
+	DrainTimeout int64 `yaml:"drain_timeout" mapstructure:"drain_timeout"`
+	// AttributesLimit max bytes of keys and values of entity attributes.
+	AttributesLimit int `yaml:"attributes_limit" mapstructure:"attributes_limit"`
+	// ReadRepairRate max search documents repaired per second by listings requesting read repair.
+	ReadRepairRate int `yaml:"read_repair_rate" mapstructure:"read_repair_rate"`
+	// DefaultPageSize page size of paginated requests not specifying one.
+	DefaultPageSize int32 `yaml:"default_page_size" mapstructure:"default_page_size"`
+	// MaxPageSize page size of paginated requests clamped to.
+	MaxPageSize int32 `yaml:"max_page_size" mapstructure:"max_page_size"`
+	// IDPrefixes prefixes of entity ids of entity types, e.g. "sensor-".
+	IDPrefixes []IDPrefixConfig `yaml:"id_prefixes" mapstructure:"id_prefixes"`
+}
+
+// IDPrefixConfig prefix of entity ids of an entity type,
+// listed instead of keyed by type since viper lowercases map keys.
+type IDPrefixConfig struct {
+	Type   string `yaml:"type" mapstructure:"type"`
+	Prefix string `yaml:"prefix" mapstructure:"prefix"`
+}
+
+// Prefixes returns prefixes of entity ids keyed by entity type.
+func (s Server) Prefixes() map[string]string {
+	if len(s.IDPrefixes) == 0 {
+		return nil
+	}
+
+	prefixes := make(map[string]string, len(s.IDPrefixes))
+	for _, item := range s.IDPrefixes {
+		if item.Type != "" && item.Prefix != "" {
+			prefixes[item.Type] = item.Prefix
+		}
+	}
+	return prefixes
 }
 
 type Proxy struct {
@@ -68,8 +119,111 @@ type Components struct {
 	Store        Metadata   `yaml:"store" mapstructure:"store"`
 	TimeSeries   Metadata   `yaml:"time_series" mapstructure:"time_series"`
 	Rawdata      Metadata   `yaml:"rawdata" mapstructure:"rawdata"`
+	// Blob store of blob properties, dapr persists blobs in a dapr state store component,
+	// memory keeps blobs of a single instance until restarted.
+	Blob Metadata `yaml:"blob" mapstructure:"blob"`
+	// RecoverFromSearch reconstruct entity missing in state store from search engine, search may be stale.
+	RecoverFromSearch bool `yaml:"recover_from_search" mapstructure:"recover_from_search"`
+	// SearchFieldMapping maps internal field names to field names of indexed search documents.
+	SearchFieldMapping []FieldMappingConfig `yaml:"search_field_mapping" mapstructure:"search_field_mapping"`
+	// SearchTypeMappings declares keyword, text or numeric properties of entity types,
+	// entity types share one index, so a property declared by types must be of the same kind.
+	SearchTypeMappings []TypeMappingConfig `yaml:"search_type_mappings" mapstructure:"search_type_mappings"`
+	// DeleteOrder order of removing deleted entity from state store and search engine, default state_first.
+	//   state_first: interrupted delete leaves entity gone from state but still listed by search.
+	//   search_first: interrupted delete leaves entity alive in state and runtime but invisible to search.
+	// either way the delete intent is kept until both removed, FinishDeletes completes interrupted deletes.
+	DeleteOrder string `yaml:"delete_order" mapstructure:"delete_order"`
+	// IndexMode when created entity indexed into search engine, default sync.
+	//   sync: CreateEntity indexes the entity before returning, so listing right after create sees it,
+	//         unless indexing failed, see IndexFailure, then it is searchable after runtime flush.
+	//   async: indexing deferred to runtime flush for throughput, search lists created entity eventually.
+	// reads by id never depend on the search engine and see created entity in both modes.
+	IndexMode string `yaml:"index_mode" mapstructure:"index_mode"`
+	// IndexFailure what CreateEntity does when sync indexing failed or search engine unavailable, default warn.
+	//   warn: the create succeeds with a warning, an index intent recorded for FinishIndexes to replay.
+	//   fail: the created entity deleted and the create fails, so creates depend on search availability.
+	IndexFailure string `yaml:"index_failure" mapstructure:"index_failure"`
+	// IndexRetryInterval seconds between FinishIndexes runs replaying index intents, default 60.
+	IndexRetryInterval int64 `yaml:"index_retry_interval" mapstructure:"index_retry_interval"`
+	// SearchDocBuilder name of the builder enriching search documents, see runtime.RegisterSearchDocBuilder,
+	// empty indexes the default documents.
+	SearchDocBuilder string `yaml:"search_doc_builder" mapstructure:"search_doc_builder"`
+}
+
+// FieldMappingConfig maps an internal field to the field of indexed search documents,
+// listed instead of keyed by field since viper lowercases map keys.
+type FieldMappingConfig struct {
+	Field   string `yaml:"field" mapstructure:"field"`
+	Indexed string `yaml:"indexed" mapstructure:"indexed"`
+}
+
+// FieldMapping returns indexed fields keyed by internal field.
+func (c Components) FieldMapping() map[string]string {
+	if len(c.SearchFieldMapping) == 0 {
+		return nil
+	}
+
+	mapping := make(map[string]string, len(c.SearchFieldMapping))
+	for _, item := range c.SearchFieldMapping {
+		if item.Field != "" && item.Indexed != "" {
+			mapping[item.Field] = item.Indexed
+		}
+	}
+	return mapping
+}
+
+// TypeMappingConfig declares field kinds of properties of an entity type,
+// listed instead of keyed by type since viper lowercases map keys.
+type TypeMappingConfig struct {
+	Type   string            `yaml:"type" mapstructure:"type"`
+	Fields []FieldKindConfig `yaml:"fields" mapstructure:"fields"`
 }
 
+// FieldKindConfig declares keyword, text or numeric kind of a property path.
+type FieldKindConfig struct {
+	Path string `yaml:"path" mapstructure:"path"`
+	Kind string `yaml:"kind" mapstructure:"kind"`
+}
+
+// TypeMappings returns field kinds keyed by entity type and property path.
+func (c Components) TypeMappings() map[string]map[string]string {
+	if len(c.SearchTypeMappings) == 0 {
+		return nil
+	}
+
+	mappings := make(map[string]map[string]string, len(c.SearchTypeMappings))
+	for _, item := range c.SearchTypeMappings {
+		if item.Type == "" {
+			continue
+		}
+		if mappings[item.Type] == nil {
+			mappings[item.Type] = make(map[string]string, len(item.Fields))
+		}
+		for _, field := range item.Fields {
+			mappings[item.Type][field.Path] = field.Kind
+		}
+	}
+	return mappings
+}
+
+const (
+	DeleteStateFirst  = "state_first"
+	DeleteSearchFirst = "search_first"
+)
+
+const (
+	IndexSync  = "sync"
+	IndexAsync = "async"
+)
+
+const (
+	IndexFailureWarn = "warn"
+	IndexFailureFail = "fail"
+
+	DefaultIndexRetryInterval = 60
+)
+
 type Pair struct {
 	Key   string      `yaml:"key"`
 	Value interface{} `yaml:"value"`
@@ -83,6 +237,9 @@ type Metadata struct {
 type EtcdConfig struct {
 	Endpoints   []string `yaml:"endpoints" mapstructure:"endpoints"`
 	DialTimeout int64    `yaml:"dial_timeout" mapstructure:"dial_timeout"`
+	// ReadCacheTTL seconds mapper lists read from etcd kept to serve reads while etcd unavailable,
+	// reads served from cache are reported stale, zero disables the cache. writes always require etcd.
+	ReadCacheTTL int64 `yaml:"read_cache_ttl" mapstructure:"read_cache_ttl"`
 }
 
 type LogConfig struct {
@@ -122,7 +279,7 @@ func Init(cfgFile string) {
 	}
 
 	if err := viper.ReadInConfig(); nil != err {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok || errors.As(err, &fs.ErrNotExist) { //nolint
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok || errors.Is(err, fs.ErrNotExist) {
 			// Config file not found.
 			defer writeDefault(cfgFile)
 		} else {
@@ -159,6 +316,13 @@ func init() {
 	viper.SetDefault("server.app_id", _defaultAppServer.AppID)
 	viper.SetDefault("server.http_addr", _defaultAppServer.HTTPAddr)
 	viper.SetDefault("server.grpc_addr", _defaultAppServer.GRPCAddr)
+	viper.SetDefault("server.materialize_timeout", _defaultAppServer.MaterializeTimeout)
+	viper.SetDefault("server.query_fields_limit", _defaultAppServer.QueryFieldsLimit)
+	viper.SetDefault("server.attributes_limit", _defaultAppServer.AttributesLimit)
+	viper.SetDefault("server.drain_timeout", _defaultAppServer.DrainTimeout)
+	viper.SetDefault("server.read_repair_rate", _defaultAppServer.ReadRepairRate)
+	viper.SetDefault("server.default_page_size", _defaultAppServer.DefaultPageSize)
+	viper.SetDefault("server.max_page_size", _defaultAppServer.MaxPageSize)
 	viper.SetDefault("proxy.http_port", _defaultProxyConfig.HTTPPort)
 	viper.SetDefault("proxy.grpc_port", _defaultProxyConfig.GRPCPort)
 	viper.SetDefault("logger.level", _defaultLogConfig.Level)
@@ -169,6 +333,15 @@ func init() {
 	viper.SetDefault("discovery.dial_timeout", _defaultDiscovery.DialTimeout)
 	viper.SetDefault("components.etcd.endpoints", _defaultEtcdConfig.Endpoints)
 	viper.SetDefault("components.etcd.dial_timeout", _defaultEtcdConfig.DialTimeout)
+	viper.SetDefault("components.etcd.read_cache_ttl", _defaultEtcdConfig.ReadCacheTTL)
+	viper.SetDefault("ingress.max_payload_size", DefaultMaxPayloadSize)
+	viper.SetDefault("expiry.interval", DefaultExpiryInterval)
+	viper.SetDefault("expiry.batch_size", DefaultExpiryBatchSize)
+	viper.SetDefault("index_batch.size", DefaultIndexBatchSize)
+	viper.SetDefault("index_batch.interval", DefaultIndexBatchInterval)
+	viper.SetDefault("liveness.interval", DefaultLivenessInterval)
+	viper.SetDefault("components.index_retry_interval", DefaultIndexRetryInterval)
+	viper.SetDefault("liveness.threshold", DefaultLivenessThreshold)
 
 	viper.SetEnvPrefix(_corePrefix)
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -0,0 +1,13 @@
+package config
+
+const (
+	DeadLetterSinkLog    = "log"
+	DeadLetterSinkPubsub = "pubsub"
+)
+
+type DeadLetterConfig struct {
+	// Sink of messages failed to apply, log(default) or pubsub.
+	Sink       string `yaml:"sink" mapstructure:"sink"`
+	PubsubName string `yaml:"pubsub_name" mapstructure:"pubsub_name"`
+	Topic      string `yaml:"topic" mapstructure:"topic"`
+}
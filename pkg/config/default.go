@@ -16,10 +16,17 @@ var (
 		GRPCPort: 20001,
 	}
 	_defaultAppServer = Server{
-		Name:     DefaultName,
-		AppID:    DefaultAppID,
-		HTTPAddr: ":6789",
-		GRPCAddr: ":31234",
+		Name:               DefaultName,
+		AppID:              DefaultAppID,
+		HTTPAddr:           ":6789",
+		GRPCAddr:           ":31234",
+		MaterializeTimeout: 5,
+		QueryFieldsLimit:   10000,
+		AttributesLimit:    4096,
+		DrainTimeout:       10,
+		ReadRepairRate:     10,
+		DefaultPageSize:    20,
+		MaxPageSize:        1000,
 	}
 	_defaultLogConfig = LogConfig{
 		Dev:      false,
@@ -27,8 +34,9 @@ var (
 		Encoding: "json",
 	}
 	_defaultEtcdConfig = EtcdConfig{
-		DialTimeout: 3,
-		Endpoints:   []string{"http://localhost:2379"},
+		DialTimeout:  3,
+		Endpoints:    []string{"http://localhost:2379"},
+		ReadCacheTTL: 10,
 	}
 	_defaultDiscovery = Discovery{
 		HeartTime:   3,
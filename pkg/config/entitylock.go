@@ -0,0 +1,10 @@
+package config
+
+type EntityLockConfig struct {
+	// Enabled serialize entity writes across core instances with etcd locks.
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Timeout seconds to wait acquiring a lock, zero means default timeout.
+	Timeout int64 `yaml:"timeout" mapstructure:"timeout"`
+	// TTL seconds of the lock lease, locks of crashed holders released after it.
+	TTL int64 `yaml:"ttl" mapstructure:"ttl"`
+}
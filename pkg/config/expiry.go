@@ -0,0 +1,16 @@
+package config
+
+const (
+	DefaultExpiryInterval  = 60
+	DefaultExpiryBatchSize = 100
+)
+
+type ExpiryConfig struct {
+	// Enabled sweep properties expired by the ttl of property configs in background,
+	// expiry measured from the write time of properties, see WritersConfig.
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Interval seconds between sweeps.
+	Interval int `yaml:"interval" mapstructure:"interval"`
+	// BatchSize entities scanned per batch.
+	BatchSize int `yaml:"batch_size" mapstructure:"batch_size"`
+}
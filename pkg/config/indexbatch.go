@@ -0,0 +1,17 @@
+package config
+
+const (
+	DefaultIndexBatchSize     = 100
+	DefaultIndexBatchInterval = 1000
+)
+
+type IndexBatchConfig struct {
+	// Enabled buffer search documents of runtime flushes and index them by bulk requests,
+	// documents indexed one request each before the flush returns if disabled.
+	// CreateEntity indexing, see Components.IndexMode, and callers asking sync index stay synchronous.
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Size buffered documents flushed in one bulk request.
+	Size int `yaml:"size" mapstructure:"size"`
+	// Interval milliseconds buffered documents wait at most before flushed.
+	Interval int `yaml:"interval" mapstructure:"interval"`
+}
@@ -0,0 +1,23 @@
+package config
+
+// DefaultMaxPayloadSize max size of ingress messages if not configured.
+const DefaultMaxPayloadSize = 4 << 20
+
+type IngressConfig struct {
+	// Workers count of workers handling ingress messages, zero handles messages synchronously.
+	Workers int `yaml:"workers" mapstructure:"workers"`
+	// QueueDepth max messages queued per worker, messages beyond are retried by the sender.
+	QueueDepth int `yaml:"queue_depth" mapstructure:"queue_depth"`
+	// MaxPayloadSize max size of ingress messages in bytes, DefaultMaxPayloadSize if not positive.
+	MaxPayloadSize int `yaml:"max_payload_size" mapstructure:"max_payload_size"`
+	// DeadLetterOversized forward oversized messages to the dead letter sink, payload omitted.
+	DeadLetterOversized bool `yaml:"dead_letter_oversized" mapstructure:"dead_letter_oversized"`
+}
+
+// PayloadLimit returns max size of ingress messages.
+func (c IngressConfig) PayloadLimit() int {
+	if c.MaxPayloadSize > 0 {
+		return c.MaxPayloadSize
+	}
+	return DefaultMaxPayloadSize
+}
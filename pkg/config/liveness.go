@@ -0,0 +1,21 @@
+package config
+
+const (
+	DefaultLivenessInterval  = 10
+	DefaultLivenessThreshold = 300
+)
+
+type LivenessConfig struct {
+	// Enabled mark entities silent longer than the threshold offline, and online again on next message,
+	// the online property of entities flipped and a liveness event published on each transition.
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Interval seconds between offline detections.
+	Interval int `yaml:"interval" mapstructure:"interval"`
+	// Threshold seconds of inactivity before an entity marked offline.
+	Threshold int `yaml:"threshold" mapstructure:"threshold"`
+	// TypeThresholds thresholds in seconds keyed by entity type, overriding Threshold.
+	TypeThresholds map[string]int `yaml:"type_thresholds" mapstructure:"type_thresholds"`
+	// PubsubName and Topic liveness events published to, events only logged if Topic empty.
+	PubsubName string `yaml:"pubsub_name" mapstructure:"pubsub_name"`
+	Topic      string `yaml:"topic" mapstructure:"topic"`
+}
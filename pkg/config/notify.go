@@ -0,0 +1,8 @@
+package config
+
+type NotifyConfig struct {
+	// BatchWindow milliseconds to batch property change notifications of an entity per subscription,
+	// a single notification with the net changes published at the end of the window.
+	// writes are applied immediately regardless, zero publishes a notification per write.
+	BatchWindow int64 `yaml:"batch_window" mapstructure:"batch_window"`
+}
@@ -0,0 +1,24 @@
+package config
+
+type OrderingConfig struct {
+	// Entities ids of entities whose messages applied in order of sequence numbers carried by
+	// messages, "*" for all entities. messages without sequence number applied on arrival.
+	// sequence numbers carried by "seq" of topic messages, or Message-Sequence header of api writes.
+	// the first sequence number seen by a runtime is the starting point of the entity,
+	// messages with sequence number already applied or skipped are dropped as stale.
+	Entities []string `yaml:"entities" mapstructure:"entities"`
+	// OnGap what runtime does with a message arrived ahead of missing ones, default wait.
+	//   wait: buffered until the missing arrived, the gap skipped once waited WaitTimeout
+	//         or MaxPending buffered. buffers are in memory, flushed on drain, lost on crash.
+	//   drop: applied at once, the missing dropped as stale if arrived later.
+	OnGap string `yaml:"on_gap" mapstructure:"on_gap"`
+	// WaitTimeout milliseconds waiting missing messages.
+	WaitTimeout int64 `yaml:"wait_timeout" mapstructure:"wait_timeout"`
+	// MaxPending max messages buffered per entity.
+	MaxPending int `yaml:"max_pending" mapstructure:"max_pending"`
+}
+
+const (
+	OrderingGapWait = "wait"
+	OrderingGapDrop = "drop"
+)
@@ -0,0 +1,22 @@
+package config
+
+type QuotaConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// default limits of tenant, zero means unlimited.
+	MaxEntities int64 `yaml:"max_entities" mapstructure:"max_entities"`
+	MaxSize     int64 `yaml:"max_size" mapstructure:"max_size"`
+	// limits override of specified tenants.
+	Tenants map[string]QuotaLimit `yaml:"tenants" mapstructure:"tenants"`
+}
+
+type QuotaLimit struct {
+	MaxEntities int64 `yaml:"max_entities" mapstructure:"max_entities"`
+	MaxSize     int64 `yaml:"max_size" mapstructure:"max_size"`
+}
+
+func (qc QuotaConfig) Limit(tenant string) QuotaLimit {
+	if limit, ok := qc.Tenants[tenant]; ok {
+		return limit
+	}
+	return QuotaLimit{MaxEntities: qc.MaxEntities, MaxSize: qc.MaxSize}
+}
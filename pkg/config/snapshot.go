@@ -0,0 +1,6 @@
+package config
+
+type SnapshotConfig struct {
+	// Limit prior states kept per entity for restore, captured before each write, zero disables snapshots.
+	Limit int `yaml:"limit" mapstructure:"limit"`
+}
@@ -0,0 +1,30 @@
+package config
+
+type ValidationConfig struct {
+	// Webhooks called in order before property writes.
+	Webhooks []WebhookConfig `yaml:"webhooks" mapstructure:"webhooks"`
+	// EntityID constraints of entity ids supplied by clients.
+	EntityID EntityIDConfig `yaml:"entity_id" mapstructure:"entity_id"`
+	// DefaultEntityType type of entities created without type. lenient by default, untyped creates
+	// accepted and typed with DefaultEntityType, or left untyped if it is empty.
+	DefaultEntityType string `yaml:"default_entity_type" mapstructure:"default_entity_type"`
+	// StrictEntityType reject untyped creates with ErrMissingType, DefaultEntityType not applied.
+	StrictEntityType bool `yaml:"strict_entity_type" mapstructure:"strict_entity_type"`
+}
+
+type EntityIDConfig struct {
+	// Pattern regular expression ids must match, default ^[A-Za-z0-9_.:@-]+$,
+	// slashes and spaces are never safe as state store or etcd keys.
+	Pattern string `yaml:"pattern" mapstructure:"pattern"`
+	// MaxLength max length of ids, default 256.
+	MaxLength int `yaml:"max_length" mapstructure:"max_length"`
+	// GenerateRetries regenerations of generated ids colliding with existing entities, default 3,
+	// client supplied ids are never regenerated.
+	GenerateRetries int `yaml:"generate_retries" mapstructure:"generate_retries"`
+}
+
+type WebhookConfig struct {
+	URL string `yaml:"url" mapstructure:"url"`
+	// Timeout in milliseconds, zero means default timeout.
+	Timeout int64 `yaml:"timeout" mapstructure:"timeout"`
+}
@@ -0,0 +1,7 @@
+package config
+
+type WritersConfig struct {
+	// Enabled track the last writer and write time of each property, disabled by default
+	// since every write stores an extra record per changed property.
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+}
@@ -10,6 +10,7 @@ import (
 	logf "github.com/tkeel-io/core/pkg/logfield"
 	"github.com/tkeel-io/core/pkg/placement"
 	"github.com/tkeel-io/core/pkg/resource/pubsub"
+	"github.com/tkeel-io/core/pkg/types"
 	"github.com/tkeel-io/core/pkg/util"
 	xkafka "github.com/tkeel-io/core/pkg/util/kafka"
 	"github.com/tkeel-io/core/pkg/util/transport"
@@ -50,6 +51,11 @@ func (d *dispatcher) DispatchToLog(ctx context.Context, ev []byte) error {
 
 func (d *dispatcher) Dispatch(ctx context.Context, ev v1.Event) error {
 	var err error
+	// propagate correlation id of the message being processed.
+	if traceID := types.TraceIDFrom(ctx); traceID != "" && ev.Attr(v1.MetaTraceID) == "" {
+		ev.SetAttr(v1.MetaTraceID, traceID)
+	}
+
 	switch ev.Type() {
 	case v1.ETCallback:
 		err = d.transmitter.Do(ctx, &transport.Request{
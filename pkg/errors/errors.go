@@ -1,6 +1,9 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 var (
 	ErrInvalidJSONPath          = errors.New("Core.JSON.Path.Invalid")
@@ -10,9 +13,13 @@ var (
 	ErrEntityNotFound           = errors.New("Core.Entity.NotFound")
 	ErrEntityAleadyExists       = errors.New("Core.Entity.Already.Exists")
 	ErrInvalidEntityParams      = errors.New("Core.Entity.Params.Invalid")
+	ErrInvalidEntityID          = errors.New("Core.Entity.ID.Invalid")
+	ErrMissingType              = errors.New("Core.Entity.Type.Missing")
 	ErrRuntimeNotExists         = errors.New("Core.Runtime.NotExists")
 	ErrMapperNotFound           = errors.New("Core.Mapper.NotFound")
+	ErrMapperTypeMismatch       = errors.New("Core.Mapper.Type.Mismatch")
 	ErrQueueNotFound            = errors.New("Core.Queue.NotFound")
+	ErrQueueFull                = errors.New("Core.Queue.Full")
 	ErrNodeNotExist             = errors.New("Core.Cluster.Node.NotExist")
 	ErrInvalidQueueType         = errors.New("Core.Queue.Type.Invalid")
 	ErrInvalidQueueConsumerType = errors.New("Core.Queue.Consumer.Type.Invalid")
@@ -35,14 +42,118 @@ var (
 	ErrPatchPathRoot            = errors.New("patch path lack root")
 	ErrPatchTypeInvalid         = errors.New("patch config type invalid")
 	ErrServerNotReady           = errors.New("Core.Service.NotReady")
+	ErrMaintenanceMode          = errors.New("Core.Service.Maintenance")
+	ErrQuotaExceeded            = errors.New("Core.Tenant.Quota.Exceeded")
 	ErrConnectionNil            = errors.New("Core.Resource.Connection.Nil")
 	ErrInvalidParam             = errors.New("Core.Params.Invalid")
 	ErrExpressionNotFound       = errors.New("Core.Expression.NotFound")
+	ErrForbiddenProperty        = errors.New("Core.Entity.Property.Forbidden")
+	ErrEntityConflict           = errors.New("Core.Entity.Version.Conflict")
+	ErrPropertyRejected         = errors.New("Core.Entity.Property.Rejected")
+	ErrConstraintViolation      = errors.New("Core.Entity.Property.Constraint.Violation")
+	ErrLockTimeout              = errors.New("Core.Entity.Lock.Timeout")
+	ErrSnapshotNotFound         = errors.New("Core.Entity.Snapshot.NotFound")
+	ErrUnknownEncoding          = errors.New("Core.Entity.Encoding.Unknown")
+	ErrMessageOutOfOrder        = errors.New("Core.Entity.Message.OutOfOrder")
+	ErrMessageTooLarge          = errors.New("Core.Message.Too.Large")
+	ErrBatchAborted             = errors.New("Core.Batch.Aborted")
+	ErrPermissionDenied         = errors.New("Core.Permission.Denied")
+	ErrUnauthenticated          = errors.New("Core.Unauthenticated")
+	ErrNamespaceInvalid         = errors.New("Core.Entity.Namespace.Invalid")
+	ErrAttributesTooLarge       = errors.New("Core.Entity.Attributes.Too.Large")
+	ErrUnknownProperty          = errors.New("Core.Entity.Property.Unknown")
+	ErrDrainIncomplete          = errors.New("Core.Drain.Incomplete")
+	ErrCrossShardTransaction    = errors.New("Core.Store.Transaction.CrossShard")
 
 	// ErrResourceNotFound errors.
 	ErrResourceNotFound = errors.New("Core.Resource.NotFound")
+	ErrResourceConflict = errors.New("Core.Resource.Conflict")
 )
 
+// sentinels errors crossing runtime responses, restored by New.
+var sentinels = []error{
+	ErrInvalidJSONPath,
+	ErrInvalidProperties,
+	ErrPropertyNotFound,
+	ErrInternal,
+	ErrEntityNotFound,
+	ErrEntityAleadyExists,
+	ErrInvalidEntityParams,
+	ErrInvalidEntityID,
+	ErrMissingType,
+	ErrRuntimeNotExists,
+	ErrMapperNotFound,
+	ErrMapperTypeMismatch,
+	ErrQueueNotFound,
+	ErrQueueFull,
+	ErrNodeNotExist,
+	ErrInvalidQueueType,
+	ErrInvalidQueueConsumerType,
+	ErrInvalidMessageType,
+	ErrInvalidMessageField,
+	ErrInvalidSubscriptionMode,
+	ErrInvalidPropertyConfig,
+	ErrInvalidHTTPRequest,
+	ErrInvalidHTTPInited,
+	ErrTemplateNotFound,
+	ErrEntityPropertyIDEmpty,
+	ErrInvalidRequest,
+	ErrEntityConfigInvalid,
+	ErrJSONPatchReservedOp,
+	ErrInvalidNodeType,
+	ErrEmptyParam,
+	ErrPatchNotFound,
+	ErrPatchPathInvalid,
+	ErrPatchPathLack,
+	ErrPatchPathRoot,
+	ErrPatchTypeInvalid,
+	ErrServerNotReady,
+	ErrMaintenanceMode,
+	ErrQuotaExceeded,
+	ErrConnectionNil,
+	ErrInvalidParam,
+	ErrExpressionNotFound,
+	ErrForbiddenProperty,
+	ErrEntityConflict,
+	ErrPropertyRejected,
+	ErrConstraintViolation,
+	ErrLockTimeout,
+	ErrMessageOutOfOrder,
+	ErrNamespaceInvalid,
+	ErrAttributesTooLarge,
+	ErrUnknownProperty,
+	ErrPermissionDenied,
+	ErrUnauthenticated,
+	ErrResourceNotFound,
+	ErrResourceConflict,
+}
+
+// codeError error code restored from a wrapped sentinel message, unwraps to the sentinel.
+type codeError struct {
+	code  string
+	cause error
+}
+
+func (e *codeError) Error() string { return e.code }
+
+func (e *codeError) Unwrap() error { return e.cause }
+
+// New returns the error of the code, codes of sentinels or of wrapped sentinels
+// keep errors.Is working on errors responded by runtime.
 func New(code string) error {
+	for _, sentinel := range sentinels {
+		msg := sentinel.Error()
+		if code == msg {
+			return sentinel
+		} else if strings.HasSuffix(code, ": "+msg) {
+			return &codeError{code: code, cause: sentinel}
+		}
+	}
 	return errors.New(code)
 }
+
+// IsEntityNotFound reports whether the error is entity not found,
+// state stores report absent entities as ErrResourceNotFound.
+func IsEntityNotFound(err error) bool {
+	return errors.Is(err, ErrEntityNotFound) || errors.Is(err, ErrResourceNotFound)
+}
@@ -0,0 +1,33 @@
+package errors
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	assert.Equal(t, ErrEntityNotFound, New(ErrEntityNotFound.Error()))
+
+	// runtime responds wrapped error messages.
+	wrapped := errors.Wrap(errors.Wrap(ErrResourceNotFound, "get entity repository"), "load entity")
+	err := New(wrapped.Error())
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+	assert.Equal(t, wrapped.Error(), err.Error())
+
+	err = New(errors.Wrap(ErrEntityAleadyExists, "create entity").Error())
+	assert.ErrorIs(t, err, ErrEntityAleadyExists)
+	assert.NotErrorIs(t, err, ErrEntityNotFound)
+
+	err = New("context canceled")
+	assert.EqualError(t, err, "context canceled")
+}
+
+func TestIsEntityNotFound(t *testing.T) {
+	assert.True(t, IsEntityNotFound(errors.Wrap(ErrEntityNotFound, "get entity properties")))
+	assert.True(t, IsEntityNotFound(errors.Wrap(ErrResourceNotFound, "get entity repository")))
+	assert.True(t, IsEntityNotFound(New("load entity: Core.Entity.NotFound")))
+	assert.False(t, IsEntityNotFound(ErrEntityAleadyExists))
+	assert.False(t, IsEntityNotFound(nil))
+}
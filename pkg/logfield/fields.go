@@ -40,6 +40,10 @@ func ReqID(reqID string) zap.Field {
 	return zap.String("request_id", reqID)
 }
 
+func TraceID(traceID string) zap.Field {
+	return zap.String("trace_id", traceID)
+}
+
 // MsgID returns message id field.
 func MsgID(msgID string) zap.Field {
 	return zap.String("message_id", msgID)
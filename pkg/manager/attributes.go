@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+)
+
+// SetAttributes set attributes of entity, attributes of empty values removed, others kept.
+// attributes are persisted with the entity but neither validated by hooks nor indexed,
+// keys and values of all attributes limited to attributesLimit bytes in total.
+func (m *apiManager) SetAttributes(ctx context.Context, en *Base, attrs map[string]string) (*BaseRet, error) {
+	current, err := m.GetEntity(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "set attributes")
+	}
+
+	merged, err := MergeAttributes(current.Attributes, attrs, m.attributesLimit)
+	if nil != err {
+		log.L().Warn("set attributes", logf.Eid(en.ID), logf.Error(err))
+		return nil, errors.Wrap(err, "set attributes")
+	}
+
+	bytes, err := json.Marshal(merged)
+	if nil != err {
+		return nil, errors.Wrap(err, "set attributes")
+	}
+
+	ret, _, err := m.PatchEntity(ctx, en, []*v1.PatchData{{
+		Path:     FieldAttributes,
+		Operator: xjson.OpReplace.String(),
+		Value:    bytes,
+	}}, NewVersionOption(current.Version))
+	return ret, errors.Wrap(err, "set attributes")
+}
+
+// GetAttributes returns attributes of entity, empty if none set.
+func (m *apiManager) GetAttributes(ctx context.Context, en *Base) (map[string]string, error) {
+	ret, err := m.GetEntity(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "get attributes")
+	}
+
+	if ret.Attributes == nil {
+		return map[string]string{}, nil
+	}
+	return ret.Attributes, nil
+}
+
+// MergeAttributes returns attributes set onto current ones, attributes of empty values removed,
+// ErrAttributesTooLarge if keys and values exceed limit bytes, the default limit if limit <= 0.
+func MergeAttributes(current, attrs map[string]string, limit int) (map[string]string, error) {
+	if limit <= 0 {
+		limit = defaultAttributesLimit
+	}
+
+	merged := make(map[string]string, len(current)+len(attrs))
+	for key, val := range current {
+		merged[key] = val
+	}
+	for key, val := range attrs {
+		if val == "" {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = val
+	}
+
+	size := 0
+	for key, val := range merged {
+		size += len(key) + len(val)
+	}
+	if size > limit {
+		return nil, errors.Wrapf(xerrors.ErrAttributesTooLarge, "attributes size %d, limit %d", size, limit)
+	}
+	return merged, nil
+}
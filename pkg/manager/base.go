@@ -1,8 +1,12 @@
 package manager
 
 import (
+	"bytes"
+
 	"github.com/pkg/errors"
 	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/scheme"
 	"github.com/tkeel-io/tdtl"
 )
 
@@ -16,41 +20,71 @@ type Base struct {
 	LastTime   int64        `json:"last_time" msgpack:"last_time" mapstructure:"last_time"`
 	Mappers    []*v1.Mapper `json:"mappers" msgpack:"mappers" mapstructure:"mappers"`
 	TemplateID string       `json:"template_id" msgpack:"template_id" mapstructure:"template_id"`
-	Scheme     []byte       `json:"-" msgpack:"scheme" mapstructure:"-"`
-	Properties []byte       `json:"properties" msgpack:"properties" mapstructure:"properties"`
+	// SchemaVersion version of entity layout, upgraded by registered migrations on read and persisted.
+	SchemaVersion int64  `json:"schema_version" msgpack:"schema_version" mapstructure:"schema_version"`
+	Scheme        []byte `json:"-" msgpack:"scheme" mapstructure:"-"`
+	Properties    []byte `json:"properties" msgpack:"properties" mapstructure:"properties"`
 }
 
 type BaseRet struct {
-	ID          string                 `json:"id" msgpack:"id" mapstructure:"id"`
-	Type        string                 `json:"type" msgpack:"type" mapstructure:"type"`
-	Owner       string                 `json:"owner" msgpack:"owner" mapstructure:"owner"`
-	Source      string                 `json:"source" msgpack:"source" mapstructure:"source"`
-	Version     int64                  `json:"version" msgpack:"version" mapstructure:"version"`
-	LastTime    int64                  `json:"last_time" msgpack:"last_time" mapstructure:"last_time"`
-	Mappers     []*v1.Mapper           `json:"mappers" msgpack:"mappers" mapstructure:"mappers"`
-	TemplateID  string                 `json:"template_id" msgpack:"template_id" mapstructure:"template_id"`
-	Description string                 `json:"description" msgpack:"description" mapstructure:"description"`
-	Properties  map[string]interface{} `json:"properties" msgpack:"properties" mapstructure:"properties"`
-	Scheme      map[string]interface{} `json:"scheme" msgpack:"-" mapstructure:"scheme"`
+	ID            string                 `json:"id" msgpack:"id" mapstructure:"id"`
+	Type          string                 `json:"type" msgpack:"type" mapstructure:"type"`
+	Owner         string                 `json:"owner" msgpack:"owner" mapstructure:"owner"`
+	Source        string                 `json:"source" msgpack:"source" mapstructure:"source"`
+	Version       int64                  `json:"version" msgpack:"version" mapstructure:"version"`
+	LastTime      int64                  `json:"last_time" msgpack:"last_time" mapstructure:"last_time"`
+	Mappers       []*v1.Mapper           `json:"mappers" msgpack:"mappers" mapstructure:"mappers"`
+	TemplateID    string                 `json:"template_id" msgpack:"template_id" mapstructure:"template_id"`
+	Description   string                 `json:"description" msgpack:"description" mapstructure:"description"`
+	SchemaVersion int64                  `json:"schema_version" msgpack:"schema_version" mapstructure:"schema_version"`
+	Properties    map[string]interface{} `json:"properties" msgpack:"properties" mapstructure:"properties"`
+	Scheme        map[string]interface{} `json:"scheme" msgpack:"-" mapstructure:"scheme"`
+	// Frozen entity, FrozenSources frozen entities mapped properties derived from.
+	Frozen        bool                   `json:"frozen,omitempty" msgpack:"-" mapstructure:"frozen"`
+	FrozenSources map[string]interface{} `json:"frozen_sources,omitempty" msgpack:"-" mapstructure:"frozen_sources"`
+	// MovedFrom owner and tenant the entity is moving from, set until the move finished, see MoveEntities.
+	MovedFrom *MovedFrom `json:"moved_from,omitempty" msgpack:"-" mapstructure:"moved_from"`
+	// Conflicts merge conflicts resolved by the patch, see NewMergeStrategyOption.
+	Conflicts []MergeConflict `json:"conflicts,omitempty" msgpack:"-" mapstructure:"-"`
+	// Writers last writer of properties if tracked, see GetWriter.
+	Writers map[string]interface{} `json:"writers,omitempty" msgpack:"-" mapstructure:"writers"`
+	// Attributes opaque client metadata, see SetAttributes.
+	Attributes map[string]string `json:"attributes,omitempty" msgpack:"-" mapstructure:"attributes"`
+	// Score search relevance, Highlight highlighted snippets keyed by field.
+	Score     float64             `json:"score,omitempty" msgpack:"-" mapstructure:"-"`
+	Highlight map[string][]string `json:"highlight,omitempty" msgpack:"-" mapstructure:"-"`
+	// Status creation status, pending if runtime not materialized entity in time.
+	Status CreationStatus `json:"status,omitempty" msgpack:"-" mapstructure:"-"`
+	// Computed virtual properties merged into Properties, see EvalComputed.
+	Computed []string `json:"computed,omitempty" msgpack:"-" mapstructure:"-"`
 }
 
+type CreationStatus string
+
+const (
+	CreationCreated CreationStatus = "created"
+	CreationPending CreationStatus = "pending"
+)
+
 func (b *Base) Basic() Base {
 	cp := Base{
-		ID:         b.ID,
-		Type:       b.Type,
-		Owner:      b.Owner,
-		Source:     b.Source,
-		Version:    b.Version,
-		LastTime:   b.LastTime,
-		TemplateID: b.TemplateID,
-		Scheme:     []byte(`{}`),
-		Properties: []byte(`{}`),
+		ID:            b.ID,
+		Type:          b.Type,
+		Owner:         b.Owner,
+		Source:        b.Source,
+		Version:       b.Version,
+		LastTime:      b.LastTime,
+		TemplateID:    b.TemplateID,
+		SchemaVersion: b.SchemaVersion,
+		Scheme:        []byte(`{}`),
+		Properties:    []byte(`{}`),
 	}
 
 	cp.Mappers = append(cp.Mappers, b.Mappers...)
 	return cp
 }
 
+// JSON returns entity info for logging, values of sensitive properties redacted.
 func (b *Base) JSON() map[string]interface{} {
 	info := make(map[string]interface{})
 	info["id"] = b.ID
@@ -60,11 +94,90 @@ func (b *Base) JSON() map[string]interface{} {
 	info["version"] = b.Version
 	info["last_time"] = b.LastTime
 	info["template_id"] = b.TemplateID
+	info["schema_version"] = b.SchemaVersion
 	info["scheme"] = string(b.Scheme)
-	info["properties"] = string(b.Properties)
+	info["properties"] = string(redactProperties(b.Scheme, b.Properties))
 	return info
 }
 
+// redactProperties replaces values of properties marked sensitive in scheme with RedactedValue.
+func redactProperties(schemeBytes, properties []byte) []byte {
+	return redactPaths(sensitivePathsOf(schemeBytes), properties)
+}
+
+// sensitivePathsOf returns paths of sensitive properties declared in scheme.
+func sensitivePathsOf(schemeBytes []byte) []string {
+	var configs map[string]interface{}
+	if len(schemeBytes) == 0 {
+		return nil
+	} else if err := json.Unmarshal(schemeBytes, &configs); nil != err {
+		return nil
+	}
+	return scheme.SensitivePaths(configs)
+}
+
+// redactPaths replaces values of properties at paths with RedactedValue.
+func redactPaths(paths []string, properties []byte) []byte {
+	if len(paths) == 0 || len(properties) == 0 {
+		return properties
+	}
+
+	cc := tdtl.New(properties)
+	for _, path := range paths {
+		if val := cc.Get(path); val.Type() != tdtl.Null && val.Type() != tdtl.Undefined {
+			cc.Set(path, tdtl.NewString(scheme.RedactedValue))
+		}
+	}
+
+	// never log properties failed to redact.
+	if nil != cc.Error() {
+		return []byte(scheme.RedactedValue)
+	}
+	return cc.Raw()
+}
+
+// redactEntity returns entity state for logging, values of sensitive properties redacted.
+func redactEntity(state []byte) string {
+	cc := tdtl.New(state)
+	properties := cc.Get(fieldProperties)
+	if nil != cc.Error() || properties.Type() != tdtl.Object {
+		return string(state)
+	}
+
+	redacted := redactProperties(cc.Get(FieldScheme).Raw(), properties.Raw())
+	cc.Set(fieldProperties, tdtl.New(redacted))
+	return string(cc.Raw())
+}
+
+// legacyBase entity state written by early versions, properties encoded under property_bytes.
+type legacyBase struct {
+	BaseRet
+	PropertyBytes []byte `json:"property_bytes"`
+}
+
+// DecodeBase decode encoded entity state, the format detected from the data, so that state
+// written by earlier versions stays readable after format changes, ErrUnknownEncoding returned
+// for data of none of the formats:
+//   - json object with properties inline, written by EncodeJSON and runtime, the current format.
+//   - json object with properties base64 encoded under property_bytes, the initial format.
+func DecodeBase(raw []byte) (*BaseRet, error) {
+	if raw = bytes.TrimSpace(raw); len(raw) == 0 || raw[0] != '{' {
+		return nil, errors.Wrap(xerrors.ErrUnknownEncoding, "decode base")
+	}
+
+	var base legacyBase
+	if err := json.Unmarshal(raw, &base); nil != err {
+		return nil, errors.Wrapf(xerrors.ErrUnknownEncoding, "decode base, %s", err.Error())
+	}
+
+	if base.Properties == nil && len(base.PropertyBytes) > 0 {
+		if err := json.Unmarshal(base.PropertyBytes, &base.Properties); nil != err {
+			return nil, errors.Wrapf(xerrors.ErrUnknownEncoding, "decode base, property_bytes %s", err.Error())
+		}
+	}
+	return &base.BaseRet, nil
+}
+
 func (b *Base) EncodeJSON() ([]byte, error) {
 	bytes, err := json.Marshal(b)
 	if nil != err {
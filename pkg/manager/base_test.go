@@ -1 +1,147 @@
 package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+)
+
+func TestBaseRet_GetProperty(t *testing.T) {
+	var ret BaseRet
+	assert.Nil(t, json.Unmarshal([]byte(`{"properties":{"name":"device123","online":true,"metrics":{"cpu":0.5,"mem":2048},"ts":1664417614285,"updated_at":"2022-09-29T02:13:34Z"}}`), &ret))
+
+	name, ok := ret.GetProperty("name").AsString()
+	assert.True(t, ok)
+	assert.Equal(t, "device123", name)
+	_, ok = ret.GetProperty("name").AsFloat64()
+	assert.False(t, ok)
+
+	online, ok := ret.GetProperty("online").AsBool()
+	assert.True(t, ok)
+	assert.True(t, online)
+
+	cpu, ok := ret.GetProperty("metrics.cpu").AsFloat64()
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, cpu)
+
+	ts, ok := ret.GetProperty("ts").AsTime()
+	assert.True(t, ok)
+	assert.Equal(t, int64(1664417614285), ts.UnixMilli())
+	updatedAt, ok := ret.GetProperty("updated_at").AsTime()
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2022, 9, 29, 2, 13, 34, 0, time.UTC), updatedAt.UTC())
+
+	// missing or through non object.
+	assert.True(t, ret.GetProperty("metrics.disk").IsNil())
+	assert.True(t, ret.GetProperty("name.first").IsNil())
+	_, ok = ret.GetProperty("metrics.disk").AsString()
+	assert.False(t, ok)
+}
+
+func TestBaseRet_Frozen(t *testing.T) {
+	var ret BaseRet
+	assert.Nil(t, json.Unmarshal([]byte(`{"id":"room123","properties":{"avg":25},"frozen":true,"frozen_sources":{"properties":{"avg":["device123"]}}}`), &ret))
+	assert.True(t, ret.Frozen)
+	assert.Equal(t, map[string]interface{}{"properties": map[string]interface{}{"avg": []interface{}{"device123"}}}, ret.FrozenSources)
+}
+
+func TestValue_AsFloat64(t *testing.T) {
+	for _, raw := range []interface{}{int(3), int32(3), int64(3), uint8(3), uint64(3), float32(3), float64(3), jsoniter.Number("3")} {
+		val, ok := NewValue(raw).AsFloat64()
+		assert.True(t, ok)
+		assert.Equal(t, float64(3), val)
+	}
+
+	_, ok := NewValue(jsoniter.Number("three")).AsFloat64()
+	assert.False(t, ok)
+	_, ok = NewValue("3").AsFloat64()
+	assert.False(t, ok)
+}
+
+func TestBaseRet_GetWriter(t *testing.T) {
+	var ret BaseRet
+	assert.Nil(t, json.Unmarshal([]byte(`{"id":"device123","properties":{"temp":25,"metrics":{"cpu":0.5}},"writers":{"properties":{"temp":{"_writer":"user:admin","_ts":1664417614285},"metrics":{"cpu":{"_writer":"mapper:cpu-mapper","_ts":1664417614285}}}}}`), &ret))
+
+	writer, ok := ret.GetWriter("temp")
+	assert.True(t, ok)
+	assert.Equal(t, PropertyWriter{Writer: "user:admin", Time: time.UnixMilli(1664417614285)}, writer)
+	writer, ok = ret.GetWriter("metrics.cpu")
+	assert.True(t, ok)
+	assert.Equal(t, "mapper:cpu-mapper", writer.Writer)
+
+	// untracked.
+	_, ok = ret.GetWriter("metrics")
+	assert.False(t, ok)
+	_, ok = ret.GetWriter("metrics.mem")
+	assert.False(t, ok)
+}
+
+func TestBaseRet_EvalComputed(t *testing.T) {
+	var ret BaseRet
+	assert.Nil(t, json.Unmarshal([]byte(`{"id":"device123","properties":{"temp":25,"metrics":{"cpu":0.5}},"scheme":{"temp":{"type":"int"},"cpu_percent":{"type":"float","define":{"computed":"this.metrics.cpu * 100"}},"hot":{"type":"bool","define":{"computed":"this.temp > 30"}},"broken":{"type":"int","define":{"computed":"this.temp +"}}}}`), &ret))
+
+	values := ret.EvalComputed(context.Background())
+	assert.Equal(t, map[string]interface{}{"cpu_percent": float64(50), "hot": false}, values)
+
+	ret.SetComputed(values, "cpu_percent")
+	assert.Equal(t, []string{"cpu_percent"}, ret.Computed)
+	assert.Equal(t, float64(50), ret.Properties["cpu_percent"])
+	assert.Nil(t, ret.Properties["hot"])
+}
+
+func TestDecodeBase(t *testing.T) {
+	fixtures := map[string]string{
+		"current": `{"id":"device123","type":"DEVICE","owner":"admin","version":3,"properties":{"temp":25,"metrics":{"cpu":0.5}},"scheme":{}}`,
+		// {"temp":25,"metrics":{"cpu":0.5}} base64 encoded.
+		"initial": `{"id":"device123","type":"DEVICE","owner":"admin","version":3,"property_bytes":"eyJ0ZW1wIjoyNSwibWV0cmljcyI6eyJjcHUiOjAuNX19"}`,
+	}
+	for name, fixture := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			ret, err := DecodeBase([]byte(fixture))
+			assert.Nil(t, err)
+			assert.Equal(t, "device123", ret.ID)
+			assert.Equal(t, "DEVICE", ret.Type)
+			assert.Equal(t, int64(3), ret.Version)
+			temp, _ := ret.GetProperty("temp").AsFloat64()
+			assert.Equal(t, float64(25), temp)
+			cpu, _ := ret.GetProperty("metrics.cpu").AsFloat64()
+			assert.Equal(t, 0.5, cpu)
+		})
+	}
+
+	for _, raw := range []string{"", "null", "\x1f\x8b\x08", `{"id":`, `{"id":"device123","property_bytes":"bm90IGpzb24="}`} {
+		_, err := DecodeBase([]byte(raw))
+		assert.ErrorIs(t, err, xerrors.ErrUnknownEncoding, raw)
+	}
+}
+
+func TestBase_JSONRedacted(t *testing.T) {
+	base := &Base{
+		ID:         "device123",
+		Scheme:     []byte(`{"ssn":{"type":"string","sensitive":true},"contact":{"type":"struct","define":{"fields":{"phone":{"type":"string","sensitive":true},"city":{"type":"string"}}}}}`),
+		Properties: []byte(`{"ssn":"123-45-6789","temp":20,"contact":{"phone":"555-0100","city":"Paris"}}`),
+	}
+
+	info := base.JSON()
+	assert.JSONEq(t, `{"ssn":"***","temp":20,"contact":{"phone":"***","city":"Paris"}}`, info["properties"].(string))
+
+	state := `{"id":"device123","scheme":` + string(base.Scheme) + `,"properties":` + string(base.Properties) + `}`
+	assert.NotContains(t, redactEntity([]byte(state)), "555-0100")
+	assert.Contains(t, redactEntity([]byte(state)), "Paris")
+
+	// properties without sensitive configs logged as is.
+	base.Scheme = []byte(`{"temp":{"type":"int"}}`)
+	assert.Equal(t, string(base.Properties), base.JSON()["properties"])
+}
+
+func TestStripNamespace(t *testing.T) {
+	props := map[string]interface{}{"temp": 10, "sensor-a__temp": 20, "sensor-a__humi": 40, "sensor-b__temp": 30}
+	assert.Equal(t, map[string]interface{}{"temp": 20, "humi": 40, "sensor-b__temp": 30},
+		StripNamespace(props, "sensor-a"))
+	assert.Equal(t, map[string]interface{}{"temp": 30, "sensor-a__temp": 20, "sensor-a__humi": 40},
+		StripNamespace(props, "sensor-b"))
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/mapper"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/kit/log"
+)
+
+// CloneOptions options of cloning entity.
+type CloneOptions struct {
+	// RebindMappers rewrite references to the source in mappers copied to the clone into references
+	// to the clone, references to other entities left intact.
+	RebindMappers bool
+}
+
+// CloneEntity create entity clone with type, properties and configs of the source entity, mappers of
+// the source copied to the clone. id, owner and source of the clone taken from clone if set, the id
+// generated if empty. mappers copied keep reading the source unless rebound, see CloneOptions.
+func (m *apiManager) CloneEntity(ctx context.Context, sourceID string, clone *Base, opts CloneOptions) (*BaseRet, error) {
+	source, err := m.GetEntity(ctx, &Base{ID: sourceID})
+	if nil != err {
+		log.L().Warn("clone entity", logf.Eid(sourceID), logf.Error(err))
+		return nil, errors.Wrap(err, "clone entity")
+	}
+
+	exprs, err := m.ListExpression(ctx, &Base{ID: sourceID, Owner: source.Owner})
+	if nil != err {
+		log.L().Warn("clone entity, list mappers", logf.Eid(sourceID), logf.Error(err))
+		return nil, errors.Wrap(err, "clone entity")
+	}
+
+	en, err := ClonedBase(source, clone)
+	if nil != err {
+		return nil, errors.Wrap(err, "clone entity")
+	}
+
+	// resolve id of the clone, mappers rebound to it before created.
+	if err = m.checkParams(ctx, en); nil != err {
+		log.L().Warn("clone entity", logf.Eid(sourceID), logf.Type(en.Type), logf.Error(err))
+		return nil, err
+	}
+
+	cloned, err := CloneExprs(exprs, en, opts)
+	if nil != err {
+		log.L().Warn("clone entity, rebind mappers", logf.Eid(sourceID), logf.Error(err))
+		return nil, errors.Wrap(err, "clone entity")
+	}
+
+	ret, err := m.CreateEntity(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "clone entity")
+	} else if err = m.appendExpression(ctx, cloned); nil != err {
+		log.L().Error("clone entity, copy mappers", logf.Eid(en.ID), logf.Error(err))
+		return nil, errors.Wrap(err, "clone entity, copy mappers")
+	}
+
+	log.L().Info("clone entity", logf.Eid(en.ID), logf.Any("from", sourceID),
+		logf.Bool("rebind", opts.RebindMappers))
+	return ret, nil
+}
+
+// ClonedBase returns the entity to create as clone of the source, see CloneEntity.
+func ClonedBase(source *BaseRet, clone *Base) (*Base, error) {
+	en := &Base{
+		ID:            clone.ID,
+		Type:          source.Type,
+		Owner:         source.Owner,
+		Source:        source.Source,
+		TemplateID:    source.TemplateID,
+		SchemaVersion: source.SchemaVersion,
+	}
+	if clone.Owner != "" {
+		en.Owner = clone.Owner
+	}
+	if clone.Source != "" {
+		en.Source = clone.Source
+	}
+
+	// virtual properties computed on read, not stored.
+	properties := make(map[string]interface{}, len(source.Properties))
+	for key, val := range source.Properties {
+		properties[key] = val
+	}
+	for _, key := range source.Computed {
+		delete(properties, key)
+	}
+
+	var err error
+	if en.Properties, err = json.Marshal(properties); nil != err {
+		return nil, errors.Wrap(err, "encode properties")
+	} else if len(source.Scheme) > 0 {
+		en.Scheme, err = json.Marshal(source.Scheme)
+	}
+	return en, errors.Wrap(err, "encode scheme")
+}
+
+// CloneExprs returns copies of expressions of the source for the clone, references to the source
+// in the expressions rewritten to the clone if opts.RebindMappers.
+func CloneExprs(exprs []*repository.Expression, clone *Base, opts CloneOptions) ([]repository.Expression, error) {
+	cloned := make([]repository.Expression, 0, len(exprs))
+	for _, expr := range exprs {
+		item := *expr
+		if opts.RebindMappers {
+			var err error
+			if item.Expression, err = mapper.RebindExpr(expr.Expression, expr.EntityID, clone.ID); nil != err {
+				return nil, errors.Wrapf(err, "rebind expression %s", expr.ID)
+			}
+		}
+
+		item.Owner = clone.Owner
+		item.EntityID = clone.ID
+		item.GenKey()
+		cloned = append(cloned, item)
+	}
+	return cloned, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/kit/log"
+)
+
+const compactPageLimit = 500
+
+// CompactMappers remove expressions of mappers whose entity no longer exists in state, left over by
+// interrupted deletes, returns count of removed expressions. compactEtcd also compacts etcd revision
+// history afterwards. expressions of an entity created but not yet materialized look orphaned,
+// so run it while entities are not being created.
+func (m *apiManager) CompactMappers(ctx context.Context, compactEtcd bool) (int, error) {
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("compact mappers", logf.Error(err))
+		return 0, err
+	}
+
+	removed := 0
+	from := ""
+	for {
+		exprs, next, err := m.entityRepo.PageExpression(ctx, from, compactPageLimit)
+		if nil != err {
+			log.L().Error("compact mappers, page expressions", logf.Error(err))
+			return removed, errors.Wrap(err, "compact mappers")
+		}
+
+		orphans, err := m.orphanExpressions(ctx, exprs)
+		if nil != err {
+			log.L().Error("compact mappers, check entities", logf.Error(err))
+			return removed, errors.Wrap(err, "compact mappers")
+		}
+
+		for _, expr := range orphans {
+			if err = m.entityRepo.DelExpression(ctx, *expr); nil != err {
+				log.L().Error("compact mappers, delete expression",
+					logf.Eid(expr.EntityID), logf.Mid(expr.ID), logf.Error(err))
+				continue
+			}
+			removed++
+		}
+
+		if next == "" {
+			break
+		}
+		from = next
+	}
+
+	log.L().Info("compact mappers completed", logf.Count(int64(removed)))
+	if compactEtcd {
+		if err := m.entityRepo.Compact(ctx); nil != err {
+			log.L().Error("compact mappers, compact etcd", logf.Error(err))
+			return removed, errors.Wrap(err, "compact mappers")
+		}
+	}
+	return removed, nil
+}
+
+// orphanExpressions returns expressions of entities absent in state.
+func (m *apiManager) orphanExpressions(ctx context.Context, exprs []*repository.Expression) ([]*repository.Expression, error) {
+	var ids []string
+	seen := make(map[string]struct{})
+	for _, expr := range exprs {
+		if _, has := seen[expr.EntityID]; !has {
+			seen[expr.EntityID] = struct{}{}
+			ids = append(ids, expr.EntityID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	exists, err := m.entityRepo.HasEntities(ctx, ids)
+	if nil != err {
+		return nil, errors.Wrap(err, "check entities exist")
+	}
+
+	var orphans []*repository.Expression
+	for _, expr := range exprs {
+		if !exists[expr.EntityID] {
+			orphans = append(orphans, expr)
+		}
+	}
+	return orphans, nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/mapper/expression"
+	"github.com/tkeel-io/core/pkg/scheme"
+	"github.com/tkeel-io/kit/log"
+	"github.com/tkeel-io/tdtl"
+)
+
+// computedSource source of computed expressions, e.g. "this.metrics.cpu * 100".
+const computedSource = "this"
+
+// EvalComputed evaluates virtual properties declared in scheme with a define.computed expression,
+// properties of the entity referenced as this.<path>. properties failed to evaluate are skipped.
+func (b *BaseRet) EvalComputed(ctx context.Context) map[string]interface{} {
+	values := make(map[string]interface{})
+	for propertyID, cfg := range b.Scheme {
+		exprText := computedExpr(cfg)
+		if exprText == "" {
+			continue
+		}
+
+		exprIns, err := expression.NewExpr(exprText, nil)
+		if nil != err {
+			log.L().Warn("parse computed property", logf.Eid(b.ID),
+				logf.Key(propertyID), logf.Expr(exprText), logf.Error(err))
+			continue
+		}
+
+		in := make(map[string]tdtl.Node)
+		for _, path := range exprIns.Sources()[computedSource] {
+			path = strings.TrimPrefix(path, computedSource+".")
+			bytes, innerErr := json.Marshal(b.GetProperty(path).Raw())
+			if nil != innerErr {
+				continue
+			}
+			in[computedSource+"."+path] = tdtl.New(bytes)
+		}
+
+		out, err := exprIns.Eval(ctx, in)
+		if nil != err || out.Type() == tdtl.Null || out.Type() == tdtl.Undefined {
+			log.L().Warn("eval computed property", logf.Eid(b.ID),
+				logf.Key(propertyID), logf.Expr(exprText), logf.Error(err))
+			continue
+		}
+
+		var val interface{}
+		if err = json.Unmarshal(out.Raw(), &val); nil != err {
+			continue
+		}
+		values[propertyID] = val
+	}
+	return values
+}
+
+// SetComputed merges computed values into properties and marks them computed,
+// restricted to keys if any given.
+func (b *BaseRet) SetComputed(values map[string]interface{}, keys ...string) {
+	if len(values) == 0 {
+		return
+	}
+
+	if b.Properties == nil {
+		b.Properties = make(map[string]interface{})
+	}
+
+	requested := make(map[string]bool)
+	for _, key := range keys {
+		requested[key] = true
+	}
+
+	b.Computed = nil
+	for propertyID, val := range values {
+		if len(keys) > 0 && !requested[propertyID] {
+			continue
+		}
+		b.Properties[propertyID] = val
+		b.Computed = append(b.Computed, propertyID)
+	}
+	sort.Strings(b.Computed)
+}
+
+func computedExpr(cfg interface{}) string {
+	kv, ok := cfg.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	define, ok := kv["define"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	exprText, _ := define[scheme.DefineFieldComputed].(string)
+	return strings.TrimSpace(exprText)
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const configsPageSize = 100
+
+// SetConfigsByType merge configs into entities of the type page by page, returns count of entities updated.
+// each entity is patched through runtime, so every update emits its own config change.
+// failed entities are logged and skipped, entities already carrying the configs are skipped too,
+// so run again with the same configs to resume an interrupted or partially failed run.
+func (m *apiManager) SetConfigsByType(ctx context.Context, typ string, configs map[string]interface{}) (int, error) {
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("set configs by type", logf.Type(typ), logf.Error(err))
+		return 0, err
+	}
+
+	if m.searchClient == nil {
+		log.L().Error("set configs by type, search client nil", logf.Type(typ))
+		return 0, errors.Wrap(xerrors.ErrConnectionNil, "set configs by type")
+	} else if typ == "" || len(configs) == 0 {
+		return 0, errors.Wrap(xerrors.ErrInvalidParam, "set configs by type")
+	}
+
+	// normalize configs, compare with configs decoded from state.
+	bytes, err := json.Marshal(configs)
+	if nil != err {
+		return 0, errors.Wrap(err, "set configs by type, encode configs")
+	}
+	var target map[string]interface{}
+	if err = json.Unmarshal(bytes, &target); nil != err {
+		return 0, errors.Wrap(err, "set configs by type, decode configs")
+	}
+
+	var (
+		lastErr error
+		updated int
+		failed  int
+	)
+
+	query := &v1.SearchRequest{
+		PageNum:  1,
+		PageSize: configsPageSize,
+		Condition: []*v1.SearchCondition{{
+			Field:    "type",
+			Operator: "$eq",
+			Value:    structpb.NewStringValue(typ),
+		}},
+	}
+
+	for {
+		resp, err := m.searchClient.Search(ctx, query)
+		if nil != err {
+			log.L().Error("set configs by type, search entities",
+				logf.Type(typ), logf.Error(err), logf.Any("page", query.PageNum))
+			return updated, errors.Wrap(err, "set configs by type")
+		}
+
+		for _, item := range resp.Items {
+			kv, ok := item.AsInterface().(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			id, _ := kv["id"].(string)
+			owner, _ := kv["owner"].(string)
+			if id == "" {
+				continue
+			}
+
+			var changed bool
+			if changed, err = m.setConfigs(ctx, &Base{ID: id, Type: typ, Owner: owner}, target, bytes); nil != err {
+				log.L().Error("set configs by type, set entity configs",
+					logf.Eid(id), logf.Type(typ), logf.Error(err))
+				lastErr = err
+				failed++
+				continue
+			} else if changed {
+				updated++
+			}
+		}
+
+		if len(resp.Items) < int(query.PageSize) {
+			break
+		}
+		query.PageNum++
+	}
+
+	log.L().Info("set configs by type completed", logf.Type(typ),
+		logf.Count(int64(updated)), logf.Any("failed", failed))
+	if failed > 0 {
+		return updated, errors.Wrapf(lastErr, "set configs by type, %d entities failed", failed)
+	}
+	return updated, nil
+}
+
+// setConfigs merge configs into entity scheme, reports whether entity changed.
+func (m *apiManager) setConfigs(ctx context.Context, en *Base, target map[string]interface{}, configs []byte) (bool, error) {
+	current, err := m.loadEntity(ctx, en.ID)
+	if nil != err {
+		return false, err
+	} else if hasConfigs(current.Scheme, target) {
+		return false, nil
+	}
+
+	if _, _, err = m.PatchEntity(ctx, en, []*v1.PatchData{{
+		Path:     FieldScheme,
+		Operator: xjson.OpMerge.String(),
+		Value:    configs,
+	}}); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+// hasConfigs reports whether scheme carries all the configs.
+func hasConfigs(scheme, configs map[string]interface{}) bool {
+	for key, val := range configs {
+		if cur, has := scheme[key]; !has || !reflect.DeepEqual(cur, val) {
+			return false
+		}
+	}
+	return true
+}
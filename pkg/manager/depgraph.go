@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/mapper/expression"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/kit/log"
+)
+
+// DepSource property a derived property is computed from, of the entity itself or another entity.
+type DepSource struct {
+	EntityID string `json:"entity_id"`
+	Property string `json:"property"`
+}
+
+// DepNode derived property and the mappers and source properties deriving it.
+type DepNode struct {
+	Property string      `json:"property"`
+	Mappers  []string    `json:"mappers"`
+	Sources  []DepSource `json:"sources"`
+}
+
+// DepGraph dependencies of derived properties of an entity, keyed by derived property path.
+type DepGraph struct {
+	EntityID string              `json:"entity_id"`
+	Derived  map[string]*DepNode `json:"derived"`
+}
+
+// GetDependencyGraph returns derived properties of the entity with the source properties of
+// each, parsed from expressions of the entity mappers.
+func (m *apiManager) GetDependencyGraph(ctx context.Context, id string) (*DepGraph, error) {
+	base, err := m.GetEntity(ctx, &Base{ID: id})
+	if nil != err {
+		return nil, errors.Wrap(err, "get dependency graph")
+	}
+
+	exprs, err := m.ListExpression(ctx, &Base{ID: id, Owner: base.Owner})
+	if nil != err {
+		return nil, errors.Wrap(err, "get dependency graph")
+	}
+	return NewDepGraph(id, exprs), nil
+}
+
+// NewDepGraph build dependency graph of the entity from expressions of its mappers.
+func NewDepGraph(entityID string, exprs []*repository.Expression) *DepGraph {
+	graph := &DepGraph{EntityID: entityID, Derived: make(map[string]*DepNode)}
+	for _, expr := range exprs {
+		path := strings.TrimPrefix(expr.Path, "properties.")
+		if path == "" {
+			continue
+		}
+
+		exprIns, err := expression.NewExpr(expr.Expression, nil)
+		if nil != err {
+			log.L().Warn("dependency graph, parse expression", logf.Eid(entityID),
+				logf.ID(expr.ID), logf.Expr(expr.Expression), logf.Error(err))
+			continue
+		}
+
+		node, ok := graph.Derived[path]
+		if !ok {
+			node = &DepNode{Property: path}
+			graph.Derived[path] = node
+		}
+		node.Mappers = appendUnique(node.Mappers, expr.Name)
+		for sourceEntityID, keys := range exprIns.Sources() {
+			for _, key := range keys {
+				property := strings.TrimPrefix(strings.TrimPrefix(key, sourceEntityID+"."), "properties.")
+				node.Sources = appendSource(node.Sources, DepSource{EntityID: sourceEntityID, Property: property})
+			}
+		}
+	}
+
+	for _, node := range graph.Derived {
+		sort.Strings(node.Mappers)
+		sort.Slice(node.Sources, func(i, j int) bool {
+			if node.Sources[i].EntityID != node.Sources[j].EntityID {
+				return node.Sources[i].EntityID < node.Sources[j].EntityID
+			}
+			return node.Sources[i].Property < node.Sources[j].Property
+		})
+	}
+	return graph
+}
+
+// Dependents returns derived properties affected by changing the property of the entity,
+// directly or through other derived properties, sorted.
+func (g *DepGraph) Dependents(property string) []string {
+	affected := make(map[string]bool)
+	queue := []string{property}
+	for len(queue) > 0 {
+		changed := queue[0]
+		queue = queue[1:]
+		for path, node := range g.Derived {
+			if affected[path] || !node.dependsOn(g.EntityID, changed) {
+				continue
+			}
+			affected[path] = true
+			queue = append(queue, path)
+		}
+	}
+
+	dependents := make([]string, 0, len(affected))
+	for path := range affected {
+		dependents = append(dependents, path)
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// dependsOn whether the node derived from the property, sources of parent or child paths included.
+func (n *DepNode) dependsOn(entityID, property string) bool {
+	for _, source := range n.Sources {
+		if source.EntityID != entityID {
+			continue
+		} else if source.Property == "*" || source.Property == property ||
+			strings.HasPrefix(property, source.Property+".") ||
+			strings.HasPrefix(source.Property, property+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(items []string, item string) []string {
+	for _, it := range items {
+		if it == item {
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+func appendSource(sources []DepSource, source DepSource) []DepSource {
+	for _, s := range sources {
+		if s == source {
+			return sources
+		}
+	}
+	return append(sources, source)
+}
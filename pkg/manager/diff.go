@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/kit/log"
+)
+
+// KeyDiff top-level keys differ between origin and target.
+type KeyDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+func (d KeyDiff) Empty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed) == 0
+}
+
+// EntityDiff differences of current entity against a snapshot.
+type EntityDiff struct {
+	EntityID   string  `json:"entity_id"`
+	Properties KeyDiff `json:"properties"`
+	Configs    KeyDiff `json:"configs"`
+	Mappers    KeyDiff `json:"mappers"`
+}
+
+func (d *EntityDiff) Empty() bool {
+	return d.Properties.Empty() && d.Configs.Empty() && d.Mappers.Empty()
+}
+
+// DiffEntity compare current entity with the snapshot, snapshot taken as origin.
+func (m *apiManager) DiffEntity(ctx context.Context, id string, against *BaseRet) (*EntityDiff, error) {
+	current, err := m.GetEntity(ctx, &Base{ID: id})
+	if nil != err {
+		log.L().Error("diff entity", logf.Eid(id), logf.Error(err))
+		return nil, errors.Wrap(err, "diff entity")
+	}
+
+	return NewEntityDiff(against, current), nil
+}
+
+// NewEntityDiff compare target entity with origin, nil origin treated as empty entity.
+func NewEntityDiff(origin, target *BaseRet) *EntityDiff {
+	if nil == origin {
+		origin = &BaseRet{}
+	}
+
+	return &EntityDiff{
+		EntityID:   target.ID,
+		Properties: diffKeys(origin.Properties, target.Properties),
+		Configs:    diffKeys(origin.Scheme, target.Scheme),
+		Mappers:    diffKeys(mapperSet(origin), mapperSet(target)),
+	}
+}
+
+// mapperSet returns mapper TQL keyed by mapper name.
+func mapperSet(base *BaseRet) map[string]interface{} {
+	mappers := make(map[string]interface{}, len(base.Mappers))
+	for _, mp := range base.Mappers {
+		mappers[mp.Name] = mp.Tql
+	}
+	return mappers
+}
+
+// diffKeys returns sorted keys added, removed and changed from origin to target.
+func diffKeys(origin, target map[string]interface{}) KeyDiff {
+	var diff KeyDiff
+	for key, val := range target {
+		if oval, has := origin[key]; !has {
+			diff.Added = append(diff.Added, key)
+		} else if !reflect.DeepEqual(oval, val) {
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+
+	for key := range origin {
+		if _, has := target[key]; !has {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/kit/log"
+)
+
+const defaultDrainTimeout = 10 * time.Second
+
+// Drainer flush data buffered by a component, e.g. queued messages or batched index documents,
+// returns error if data still pending when the context done.
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// DrainFunc adapts the function to Drainer.
+type DrainFunc func(ctx context.Context) error
+
+func (f DrainFunc) Drain(ctx context.Context) error {
+	return f(ctx)
+}
+
+type namedDrainer struct {
+	name    string
+	drainer Drainer
+}
+
+func drainTimeoutFrom(cfg config.Server) time.Duration {
+	if cfg.DrainTimeout > 0 {
+		return time.Duration(cfg.DrainTimeout) * time.Second
+	}
+	return defaultDrainTimeout
+}
+
+// AddDrainer append drainer, drainers are drained in the order added, so add
+// components before the components they write to.
+func (m *apiManager) AddDrainer(name string, drainer Drainer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.drainers = append(m.drainers, namedDrainer{name: name, drainer: drainer})
+}
+
+// Drain flush data buffered by all drainers, ErrDrainIncomplete naming drainers
+// failed or not drained if the context done with data still pending.
+func (m *apiManager) Drain(ctx context.Context) error {
+	m.lock.RLock()
+	drainers := m.drainers
+	m.lock.RUnlock()
+
+	var pending []string
+	for index, d := range drainers {
+		if nil != ctx.Err() {
+			for _, rest := range drainers[index:] {
+				pending = append(pending, rest.name)
+			}
+			break
+		}
+
+		if err := d.drainer.Drain(ctx); nil != err {
+			log.L().Error("drain buffered data", logf.Name(d.name), logf.Error(err))
+			pending = append(pending, d.name)
+		}
+	}
+
+	if len(pending) > 0 {
+		return errors.Wrapf(xerrors.ErrDrainIncomplete, "pending %s", strings.Join(pending, ","))
+	}
+	return nil
+}
+
+// Stop reject writes, drain buffered data within the drain timeout, then stop background tasks of the manager.
+func (m *apiManager) Stop(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.drainTimeout)
+	defer cancel()
+
+	// writes dispatched while draining would wait runtimes no longer consuming.
+	m.SetMaintenanceMode(true)
+	err := m.Drain(ctx)
+	m.cancel()
+	return errors.Wrap(err, "stop manager")
+}
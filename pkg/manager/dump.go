@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/types"
+	"github.com/tkeel-io/kit/log"
+)
+
+// EntityDump raw state of entity for debugging, as stored and bypassing runtime.
+type EntityDump struct {
+	ID string `json:"id"`
+	// Raw state store value of the entity.
+	Raw []byte `json:"raw"`
+	// ETag state store etag of Raw.
+	ETag string `json:"etag"`
+	// Base decoded from Raw, nil if undecodable with the error in DecodeError.
+	Base        *BaseRet `json:"base,omitempty"`
+	DecodeError string   `json:"decode_error,omitempty"`
+	// Mappers raw etcd values of entity mappers keyed by etcd key, requires Base for the owner.
+	Mappers map[string]string `json:"mappers"`
+}
+
+// DumpEntity returns the raw state of the entity, decode errors reported in the dump rather than failing it,
+// callers must hold types.RoleAdmin of the tenant owning the entity.
+// States undecodable are dumped only if the owner still readable from them.
+func (m *apiManager) DumpEntity(ctx context.Context, id string) (*EntityDump, error) {
+	identity, _ := types.IdentityFrom(ctx)
+	if !identity.HasRole(types.RoleAdmin) {
+		log.L().Warn("dump entity, admin role required", logf.Eid(id),
+			logf.Any("roles", identity.Roles), logf.Error(xerrors.ErrPermissionDenied))
+		return nil, errors.Wrap(xerrors.ErrPermissionDenied, "dump entity")
+	}
+
+	raw, etag, err := m.entityRepo.GetEntityWithETag(ctx, id)
+	if nil != err {
+		return nil, errors.Wrap(err, "dump entity")
+	}
+
+	dump := &EntityDump{ID: id, Raw: raw, ETag: etag, Mappers: make(map[string]string)}
+	base, decodeErr := DecodeBase(raw)
+	owner := dumpOwner(base, raw)
+	if !identity.Owns(owner) {
+		log.L().Warn("dump entity, entity of other tenant", logf.Eid(id), logf.Owner(owner),
+			logf.String("tenant", identity.Tenant), logf.Error(xerrors.ErrPermissionDenied))
+		return nil, errors.Wrap(xerrors.ErrPermissionDenied, "dump entity")
+	} else if nil != decodeErr {
+		dump.DecodeError = decodeErr.Error()
+		return dump, nil
+	}
+	dump.Base = base
+
+	mappers, err := m.entityRepo.RawExpressions(ctx, dump.Base.Owner, id)
+	if nil != err {
+		return nil, errors.Wrap(err, "dump entity")
+	}
+	for key, value := range mappers {
+		dump.Mappers[key] = string(value)
+	}
+	return dump, nil
+}
+
+// dumpOwner returns owner of the state, read from the raw json if the state undecodable.
+func dumpOwner(base *BaseRet, raw []byte) string {
+	if base != nil {
+		return base.Owner
+	}
+	var fields struct {
+		Owner string `json:"owner"`
+	}
+	if err := json.Unmarshal(raw, &fields); nil != err {
+		return ""
+	}
+	return fields.Owner
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/resource/search/driver"
+	"github.com/tkeel-io/kit/log"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// typesCacheTTL how long listed types reused, types listed for UI population.
+	typesCacheTTL = 30 * time.Second
+	maxListTypes  = 1000
+)
+
+// TypeInfo entity type and count of its entities.
+type TypeInfo struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+// aggregator search client computing aggregations, implemented by search.Service.
+type aggregator interface {
+	Aggregate(context.Context, *v1.SearchRequest, []driver.Aggregation) (*driver.AggregationResult, error)
+}
+
+// typesCache types listed recently, keyed by owner.
+type typesCache struct {
+	lock    sync.Mutex
+	entries map[string]typesEntry
+}
+
+type typesEntry struct {
+	types   []TypeInfo
+	expired time.Time
+}
+
+func (c *typesCache) get(owner string) ([]TypeInfo, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, has := c.entries[owner]
+	if !has || time.Now().After(entry.expired) {
+		return nil, false
+	}
+	return append([]TypeInfo{}, entry.types...), true
+}
+
+func (c *typesCache) put(owner string, types []TypeInfo) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]typesEntry)
+	}
+
+	// drop expired entries of other owners.
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expired) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[owner] = typesEntry{types: types, expired: now.Add(typesCacheTTL)}
+}
+
+// ListTypes returns distinct types of entities of the owner with entity counts, counted by search
+// engine, and types with registered migrations but no entity yet, with zero count. result cached
+// for typesCacheTTL, concurrent misses may count twice.
+func (m *apiManager) ListTypes(ctx context.Context, owner string) ([]TypeInfo, error) {
+	if owner == "" {
+		return nil, errors.Wrap(xerrors.ErrInvalidParam, "list types, empty owner")
+	} else if types, ok := m.typesCache.get(owner); ok {
+		return types, nil
+	}
+
+	if m.searchClient == nil {
+		log.L().Error("list types, search client nil")
+		return nil, errors.Wrap(xerrors.ErrConnectionNil, "list types")
+	}
+
+	agg, ok := m.searchClient.(aggregator)
+	if !ok {
+		log.L().Error("list types, search client aggregation unsupported")
+		return nil, errors.New("list types, search client aggregation unsupported")
+	}
+
+	result, err := agg.Aggregate(ctx, &v1.SearchRequest{
+		Owner: owner,
+		Condition: []*v1.SearchCondition{{
+			Field:    fieldOwner,
+			Operator: "$eq",
+			Value:    structpb.NewStringValue(owner),
+		}},
+	}, []driver.Aggregation{{
+		Name:  fieldType,
+		Kind:  driver.AggregationTerms,
+		Field: fieldType,
+		Size:  maxListTypes,
+	}})
+	if nil != err {
+		log.L().Error("list types, aggregate types", logf.Error(err))
+		return nil, errors.Wrap(err, "list types")
+	}
+
+	types := m.typesFrom(result)
+	m.typesCache.put(owner, types)
+	return append([]TypeInfo{}, types...), nil
+}
+
+// typesFrom returns types of the terms aggregation along with registered types, sorted by type.
+func (m *apiManager) typesFrom(result *driver.AggregationResult) []TypeInfo {
+	counts := make(map[string]int64)
+	if value, ok := result.Aggregations[fieldType]; ok {
+		for _, bucket := range value.Buckets {
+			counts[fmt.Sprint(bucket.Key)] += bucket.Count
+		}
+	}
+
+	m.lock.RLock()
+	for typ := range m.schemaVersions {
+		if _, has := counts[typ]; !has {
+			counts[typ] = 0
+		}
+	}
+	m.lock.RUnlock()
+
+	types := make([]TypeInfo, 0, len(counts))
+	for typ, count := range counts {
+		if typ != "" {
+			types = append(types, TypeInfo{Type: typ, Count: count})
+		}
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Type < types[j].Type
+	})
+	return types
+}
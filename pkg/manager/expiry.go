@@ -0,0 +1,128 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/scheme"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+)
+
+// runExpirySweeper sweep expired properties every interval until manager canceled.
+func (m *apiManager) runExpirySweeper(cfg config.ExpiryConfig) {
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = config.DefaultExpiryInterval * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			if m.MaintenanceMode() || m.searchClient == nil {
+				continue
+			} else if _, err := m.sweepExpired(m.ctx, cfg.BatchSize); nil != err {
+				log.L().Error("expiry sweeper", logf.Error(err))
+			}
+		}
+	}
+}
+
+// sweepExpired scan entities batch by batch and remove expired properties through runtime,
+// so the removal notified as property changes, returns count of properties removed.
+func (m *apiManager) sweepExpired(ctx context.Context, batchSize int) (int, error) {
+	if m.searchClient == nil {
+		return 0, errors.Wrap(xerrors.ErrConnectionNil, "sweep expired properties")
+	} else if batchSize <= 0 {
+		batchSize = config.DefaultExpiryBatchSize
+	}
+
+	removed := 0
+	seen := make(map[string]struct{})
+	for page := int32(1); ; page++ {
+		resp, err := m.searchClient.Search(ctx, &v1.SearchRequest{PageNum: page, PageSize: int32(batchSize)})
+		if nil != err {
+			return removed, errors.Wrap(err, "sweep expired properties")
+		}
+
+		for _, en := range entitiesFrom(resp, seen) {
+			count, err := m.removeExpired(ctx, en.ID, time.Now())
+			if nil != err {
+				log.L().Warn("sweep expired properties", logf.Eid(en.ID), logf.Error(err))
+				continue
+			}
+			removed += count
+		}
+
+		if len(resp.Items) < batchSize {
+			break
+		}
+	}
+
+	if removed > 0 {
+		log.L().Info("sweep expired properties completed", logf.Count(int64(removed)))
+	}
+	return removed, nil
+}
+
+// removeExpired remove expired properties of the entity, skipped if entity changed meanwhile.
+func (m *apiManager) removeExpired(ctx context.Context, entityID string, now time.Time) (int, error) {
+	base, err := m.loadEntity(ctx, entityID)
+	if nil != err {
+		return 0, errors.Wrap(err, "remove expired properties")
+	}
+
+	paths := expiredPaths(base, now)
+	if len(paths) == 0 {
+		return 0, nil
+	}
+
+	pds := make([]*v1.PatchData, 0, len(paths))
+	for _, path := range paths {
+		pds = append(pds, &v1.PatchData{Path: fieldProperties + "." + path, Operator: xjson.OpRemove.String()})
+	}
+
+	en := &Base{ID: base.ID, Type: base.Type, Owner: base.Owner, Source: base.Source}
+	if _, _, err = m.PatchEntity(ctx, en, pds, NewVersionOption(base.Version)); nil != err {
+		return 0, errors.Wrap(err, "remove expired properties")
+	}
+	return len(paths), nil
+}
+
+// expiredPaths returns paths of present properties written longer ago than their ttl.
+func expiredPaths(base *BaseRet, now time.Time) []string {
+	var paths []string
+	for path, ttl := range scheme.TTLs(base.Scheme) {
+		if base.GetProperty(path).raw == nil {
+			continue
+		} else if writer, ok := base.GetWriter(path); ok && writer.Time.Add(ttl).Before(now) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
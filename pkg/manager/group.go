@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+)
+
+// FieldMemberOf search indexed property, groups the entity belongs to.
+const FieldMemberOf = "memberOf"
+
+func (m *apiManager) CreateGroup(ctx context.Context, group *repository.Group) error {
+	if err := m.checkWritable(); nil != err {
+		return err
+	} else if group.ID == "" {
+		return errors.Wrap(xerrors.ErrInvalidParam, "create group, empty group id")
+	}
+
+	if err := m.entityRepo.PutGroup(ctx, group); nil != err {
+		log.L().Error("create group", logf.ID(group.ID), logf.Owner(group.Owner), logf.Error(err))
+		return errors.Wrap(err, "create group")
+	}
+	return nil
+}
+
+func (m *apiManager) GetGroup(ctx context.Context, gid string) (*repository.Group, error) {
+	group, err := m.entityRepo.GetGroup(ctx, &repository.Group{ID: gid})
+	if nil != err {
+		log.L().Error("get group", logf.ID(gid), logf.Error(err))
+		return nil, errors.Wrap(err, "get group")
+	}
+	return group, nil
+}
+
+func (m *apiManager) ListGroups(ctx context.Context, owner string) ([]*repository.Group, error) {
+	groups, err := m.entityRepo.ListGroup(ctx, m.entityRepo.GetLastRevision(ctx), owner)
+	if nil != err {
+		log.L().Error("list groups", logf.Owner(owner), logf.Error(err))
+		return nil, errors.Wrap(err, "list groups")
+	}
+	return groups, nil
+}
+
+// DeleteGroup delete group and remove all members from it.
+func (m *apiManager) DeleteGroup(ctx context.Context, gid string) error {
+	if err := m.checkWritable(); nil != err {
+		return err
+	}
+
+	members, err := m.ListGroupMembers(ctx, gid)
+	if nil != err {
+		return errors.Wrap(err, "delete group")
+	}
+
+	for _, eid := range members {
+		if err = m.RemoveFromGroup(ctx, gid, &Base{ID: eid}); nil != err {
+			return errors.Wrap(err, "delete group")
+		}
+	}
+
+	if err = m.entityRepo.DelGroup(ctx, &repository.Group{ID: gid}); nil != err {
+		log.L().Error("delete group", logf.ID(gid), logf.Error(err))
+		return errors.Wrap(err, "delete group")
+	}
+	return nil
+}
+
+func (m *apiManager) AddToGroup(ctx context.Context, gid string, en *Base) error {
+	if err := m.checkWritable(); nil != err {
+		return err
+	} else if _, err = m.GetGroup(ctx, gid); nil != err {
+		return errors.Wrap(err, "add to group")
+	} else if has, err := m.entityRepo.HasEntity(ctx, en.ID); nil != err {
+		return errors.Wrap(err, "add to group")
+	} else if !has {
+		return errors.Wrap(xerrors.ErrEntityNotFound, "add to group")
+	}
+
+	if err := m.entityRepo.PutMembership(ctx,
+		&repository.Membership{GroupID: gid, EntityID: en.ID}); nil != err {
+		log.L().Error("add to group", logf.ID(gid), logf.Eid(en.ID), logf.Error(err))
+		return errors.Wrap(err, "add to group")
+	}
+
+	return errors.Wrap(m.syncMemberOf(ctx, en), "add to group")
+}
+
+func (m *apiManager) RemoveFromGroup(ctx context.Context, gid string, en *Base) error {
+	if err := m.checkWritable(); nil != err {
+		return err
+	}
+
+	if err := m.entityRepo.DelMembership(ctx,
+		&repository.Membership{GroupID: gid, EntityID: en.ID}); nil != err {
+		log.L().Error("remove from group", logf.ID(gid), logf.Eid(en.ID), logf.Error(err))
+		return errors.Wrap(err, "remove from group")
+	}
+
+	return errors.Wrap(m.syncMemberOf(ctx, en), "remove from group")
+}
+
+// ListGroupMembers returns entity ids of the group.
+func (m *apiManager) ListGroupMembers(ctx context.Context, gid string) ([]string, error) {
+	ids, err := m.entityRepo.ListGroupMembers(ctx, m.entityRepo.GetLastRevision(ctx), gid)
+	if nil != err {
+		log.L().Error("list group members", logf.ID(gid), logf.Error(err))
+		return nil, errors.Wrap(err, "list group members")
+	}
+	return ids, nil
+}
+
+// ListEntityGroups returns group ids the entity belongs to.
+func (m *apiManager) ListEntityGroups(ctx context.Context, eid string) ([]string, error) {
+	ids, err := m.entityRepo.ListEntityGroups(ctx, m.entityRepo.GetLastRevision(ctx), eid)
+	if nil != err {
+		log.L().Error("list entity groups", logf.Eid(eid), logf.Error(err))
+		return nil, errors.Wrap(err, "list entity groups")
+	}
+	return ids, nil
+}
+
+// syncMemberOf write entity groups into the search indexed property.
+func (m *apiManager) syncMemberOf(ctx context.Context, en *Base) error {
+	gids, err := m.ListEntityGroups(ctx, en.ID)
+	if nil != err {
+		return errors.Wrap(err, "sync entity groups")
+	}
+
+	sort.Strings(gids)
+	bytes, err := json.Marshal(gids)
+	if nil != err {
+		return errors.Wrap(err, "sync entity groups")
+	}
+
+	_, _, err = m.PatchEntity(ctx, en, []*v1.PatchData{{
+		Path:     "properties." + FieldMemberOf,
+		Operator: xjson.OpReplace.String(),
+		Value:    bytes,
+	}})
+	return errors.Wrap(err, "sync entity groups")
+}
+
+// leaveGroups remove deleted entity from all groups.
+func (m *apiManager) leaveGroups(ctx context.Context, eid string) {
+	gids, err := m.ListEntityGroups(ctx, eid)
+	if nil != err {
+		return
+	}
+
+	for _, gid := range gids {
+		if err = m.entityRepo.DelMembership(ctx,
+			&repository.Membership{GroupID: gid, EntityID: eid}); nil != err {
+			log.L().Error("delete entity, leave group", logf.ID(gid), logf.Eid(eid), logf.Error(err))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/kit/log"
+)
+
+const defaultHookTimeout = 3 * time.Second
+
+// ValidationHook validate property changes before write, reject the write by returning error.
+type ValidationHook interface {
+	Validate(ctx context.Context, id string, changes map[string]interface{}) error
+}
+
+type noopHook struct{}
+
+// NewNoopHook returns hook accepts all changes.
+func NewNoopHook() ValidationHook {
+	return noopHook{}
+}
+
+func (noopHook) Validate(context.Context, string, map[string]interface{}) error {
+	return nil
+}
+
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook returns hook which post changes to external policy service,
+// any non 2xx response rejects the write with the response body as message.
+func NewWebhook(url string) ValidationHook {
+	return &webhook{url: url, client: &http.Client{}}
+}
+
+func (w *webhook) Validate(ctx context.Context, id string, changes map[string]interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{"id": id, "changes": changes})
+	if nil != err {
+		return errors.Wrap(err, "encode webhook payload")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if nil != err {
+		return errors.Wrap(err, "new webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if nil != err {
+		return errors.Wrap(err, "call webhook")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("webhook rejected, status: %d, message: %s", resp.StatusCode, string(msg))
+	}
+	return nil
+}
+
+type validationHook struct {
+	hook    ValidationHook
+	timeout time.Duration
+}
+
+func hooksFrom(cfg config.ValidationConfig) []validationHook {
+	hooks := make([]validationHook, 0, len(cfg.Webhooks))
+	for _, wh := range cfg.Webhooks {
+		hooks = append(hooks, validationHook{
+			hook:    NewWebhook(wh.URL),
+			timeout: time.Duration(wh.Timeout) * time.Millisecond,
+		})
+	}
+	return hooks
+}
+
+// AddValidationHook append hook, hooks are invoked in the order added.
+func (m *apiManager) AddValidationHook(hook ValidationHook, timeout time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.hooks = append(m.hooks, validationHook{hook: hook, timeout: timeout})
+}
+
+func (m *apiManager) validate(ctx context.Context, id string, pds []*v1.PatchData) error {
+	m.lock.RLock()
+	hooks := m.hooks
+	m.lock.RUnlock()
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	changes := make(map[string]interface{}, len(pds))
+	for _, pd := range pds {
+		// attributes are opaque to validation.
+		if pd.Path == FieldAttributes || strings.HasPrefix(pd.Path, FieldAttributes+".") {
+			continue
+		}
+
+		var val interface{}
+		if len(pd.Value) > 0 {
+			if err := json.Unmarshal(pd.Value, &val); nil != err {
+				return errors.Wrap(err, "decode property changes")
+			}
+		}
+		changes[pd.Path] = val
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for _, h := range hooks {
+		if err := runHook(ctx, h, id, changes); nil != err {
+			log.L().Warn("validate property changes", logf.Eid(id), logf.Error(err))
+			return errors.Wrap(xerrors.ErrPropertyRejected, err.Error())
+		}
+	}
+	return nil
+}
+
+func runHook(ctx context.Context, h validationHook, id string, changes map[string]interface{}) error {
+	timeout := h.timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return h.hook.Validate(ctx, id, changes)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/util"
+	"github.com/tkeel-io/kit/log"
+)
+
+const (
+	defaultEntityIDMaxLength = 256
+	defaultEntityIDRetries   = 3
+)
+
+// defaultEntityIDPattern allows letters, digits and "_", ".", ":", "@", "-".
+var defaultEntityIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:@-]+$`)
+
+// idValidator checks entity ids supplied by clients are safe as state store and etcd keys.
+type idValidator struct {
+	pattern   *regexp.Regexp
+	maxLength int
+}
+
+func idValidatorFrom(cfg config.EntityIDConfig) idValidator {
+	v := idValidator{maxLength: cfg.MaxLength}
+	if cfg.Pattern != "" {
+		pattern, err := regexp.Compile(cfg.Pattern)
+		if nil != err {
+			log.L().Error("compile entity id pattern, use default",
+				logf.String("pattern", cfg.Pattern), logf.Error(err))
+			return v
+		}
+		v.pattern = pattern
+	}
+	return v
+}
+
+// idGenerator generates ids of entities created without id.
+type idGenerator struct {
+	generate func(prefix string) (string, error)
+	// regenerations of ids colliding with existing entities.
+	retries int
+}
+
+func idGeneratorFrom(cfg config.EntityIDConfig) idGenerator {
+	return idGenerator{generate: util.IG().NewEIDWith, retries: cfg.GenerateRetries}
+}
+
+func (g idGenerator) Generate(prefix string) (string, error) {
+	generate := g.generate
+	if generate == nil {
+		generate = util.IG().NewEIDWith
+	}
+
+	id, err := generate(prefix)
+	if nil != err {
+		return "", errors.Wrap(err, "generate entity id")
+	} else if id == "" {
+		return "", errors.Wrap(xerrors.ErrInvalidEntityID, "generate entity id, empty id")
+	}
+	return id, nil
+}
+
+// generateAbsent regenerate the id of the entity until absent in state store, fails with
+// ErrEntityAleadyExists once retries exhausted.
+func (m *apiManager) generateAbsent(ctx context.Context, en *Base) error {
+	retries := m.idGenerator.retries
+	if retries <= 0 {
+		retries = defaultEntityIDRetries
+	}
+
+	err := m.checkAbsent(ctx, en.ID)
+	for retry := 1; retry <= retries && errors.Is(err, xerrors.ErrEntityAleadyExists); retry++ {
+		log.L().Warn("generated entity id collided, regenerate",
+			logf.Eid(en.ID), logf.Count(int64(retry)))
+		if en.ID, err = m.idGenerator.Generate(m.idPrefixes[en.Type]); nil != err {
+			return err
+		}
+		err = m.checkAbsent(ctx, en.ID)
+	}
+	return err
+}
+
+func (v idValidator) Validate(id string) error {
+	maxLength, pattern := v.maxLength, v.pattern
+	if maxLength <= 0 {
+		maxLength = defaultEntityIDMaxLength
+	}
+	if pattern == nil {
+		pattern = defaultEntityIDPattern
+	}
+
+	if len(id) > maxLength {
+		return errors.Wrapf(xerrors.ErrInvalidEntityID, "entity id longer than %d", maxLength)
+	} else if !pattern.MatchString(id) {
+		return errors.Wrapf(xerrors.ErrInvalidEntityID, "entity id %q not match %s", id, pattern.String())
+	}
+	return nil
+}
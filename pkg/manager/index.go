@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/runtime"
+	"github.com/tkeel-io/kit/log"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// indexEntity index the entity state with the document runtime flushes index,
+// so that the document indexed by manager never drops fields runtime indexes.
+func (m *apiManager) indexEntity(ctx context.Context, id string, state []byte) error {
+	if m.searchClient == nil {
+		return nil
+	}
+
+	en, err := runtime.NewEntity(id, state)
+	if nil != err {
+		return errors.Wrap(err, "index entity, decode entity")
+	}
+
+	bytes, err := runtime.MakeSearchDocument(en, m.searchModel, m.searchDocBuilder)
+	if nil != err {
+		return errors.Wrap(err, "index entity")
+	}
+
+	var doc map[string]interface{}
+	if err = json.Unmarshal(bytes, &doc); nil != err {
+		return errors.Wrap(err, "index entity, decode document")
+	}
+
+	obj, err := structpb.NewValue(doc)
+	if nil != err {
+		return errors.Wrap(err, "index entity, encode document")
+	}
+	_, err = m.searchClient.Index(ctx, &v1.IndexObject{Obj: obj})
+	return errors.Wrap(err, "index entity")
+}
+
+// indexStored index the entity as stored.
+func (m *apiManager) indexStored(ctx context.Context, id string) error {
+	state, err := m.entityRepo.GetEntity(ctx, id)
+	if nil != err {
+		return errors.Wrap(err, "index entity")
+	}
+	return m.indexEntity(ctx, id, state)
+}
+
+// indexCreated index created entity, on failure or without search client either record an index
+// intent for FinishIndexes and succeed, or delete the created entity and fail, per index failure policy.
+func (m *apiManager) indexCreated(ctx context.Context, ret *BaseRet, state []byte) error {
+	err := errors.Wrap(xerrors.ErrConnectionNil, "index entity, search client nil")
+	if m.searchClient != nil {
+		if err = m.indexEntity(ctx, ret.ID, state); nil == err {
+			return nil
+		}
+	}
+
+	if m.failOnIndex {
+		derr := m.DeleteEntity(ctx, &Base{ID: ret.ID, Owner: ret.Owner, Source: ret.Source})
+		if nil == derr {
+			return err
+		}
+		// entity left created, index it later.
+		log.L().Error("index created entity, delete entity", logf.Eid(ret.ID), logf.Error(derr))
+	}
+
+	log.L().Warn("index created entity, index later", logf.Eid(ret.ID), logf.Error(err))
+	intent := &repository.IndexIntent{EntityID: ret.ID, Owner: ret.Owner, Source: ret.Source}
+	if ierr := m.entityRepo.PutIndexIntent(ctx, intent); nil != ierr {
+		log.L().Error("index created entity, put index intent", logf.Eid(ret.ID), logf.Error(ierr))
+	}
+
+	if m.failOnIndex {
+		return err
+	}
+	return nil
+}
+
+// runIndexRetry replay index intents periodically, so entities created while indexing failed become searchable.
+func (m *apiManager) runIndexRetry(interval time.Duration) {
+	if interval <= 0 {
+		interval = config.DefaultIndexRetryInterval * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			if m.MaintenanceMode() || m.searchClient == nil {
+				continue
+			} else if _, err := m.FinishIndexes(m.ctx); nil != err {
+				log.L().Error("index retry", logf.Error(err))
+			}
+		}
+	}
+}
+
+// FinishIndexes index entities created while indexing failed, returns count of entities indexed,
+// intents of entities deleted since are dropped. failed indexes are kept for the next run.
+func (m *apiManager) FinishIndexes(ctx context.Context) (int, error) {
+	if m.searchClient == nil {
+		log.L().Error("finish indexes, search client nil")
+		return 0, errors.Wrap(xerrors.ErrConnectionNil, "finish indexes")
+	}
+
+	intents, err := m.entityRepo.ListIndexIntent(ctx, m.entityRepo.GetLastRevision(ctx))
+	if nil != err {
+		log.L().Error("finish indexes, list index intents", logf.Error(err))
+		return 0, errors.Wrap(err, "finish indexes")
+	} else if len(intents) == 0 {
+		return 0, nil
+	}
+
+	finished := 0
+	for _, intent := range intents {
+		if err = m.finishIndex(ctx, intent); nil != err {
+			log.L().Error("finish index", logf.Eid(intent.EntityID), logf.Owner(intent.Owner), logf.Error(err))
+			continue
+		}
+		finished++
+	}
+
+	log.L().Info("finish indexes completed", logf.Count(int64(finished)))
+	return finished, nil
+}
+
+func (m *apiManager) finishIndex(ctx context.Context, intent *repository.IndexIntent) error {
+	if err := m.indexStored(ctx, intent.EntityID); nil != err && !xerrors.IsEntityNotFound(err) {
+		return errors.Wrap(err, "finish index")
+	}
+	return errors.Wrap(m.entityRepo.DelIndexIntent(ctx, intent), "finish index")
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/runtime"
+)
+
+// LivenessSource returns liveness of entities tracked, e.g. the runtime node.
+type LivenessSource interface {
+	GetLiveness(ctx context.Context, entityID string) (*runtime.Liveness, error)
+}
+
+// SetLivenessSource set source of entity liveness.
+func (m *apiManager) SetLivenessSource(source LivenessSource) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.livenessSource = source
+}
+
+// GetLiveness returns liveness of the entity, ErrEntityNotFound if the entity not tracked.
+func (m *apiManager) GetLiveness(ctx context.Context, entityID string) (*runtime.Liveness, error) {
+	m.lock.RLock()
+	source := m.livenessSource
+	m.lock.RUnlock()
+	if source == nil {
+		return nil, errors.Wrap(xerrors.ErrConnectionNil, "get entity liveness, liveness source nil")
+	}
+
+	liveness, err := source.GetLiveness(ctx, entityID)
+	return liveness, errors.Wrap(err, "get entity liveness")
+}
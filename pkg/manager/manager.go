@@ -33,10 +33,13 @@ import (
 	"github.com/tkeel-io/core/pkg/mapper"
 	"github.com/tkeel-io/core/pkg/mapper/expression"
 	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/runtime"
 	"github.com/tkeel-io/core/pkg/types"
 	"github.com/tkeel-io/core/pkg/util"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
 	"github.com/tkeel-io/kit/log"
 	"github.com/tkeel-io/tdtl"
+	"go.uber.org/atomic"
 )
 
 const respondFmt = "http://%s:%d/v1/respond"
@@ -50,12 +53,80 @@ const (
 	bornPatch  = "apis.PatchEntity"
 	bornGet    = "apis.GetEntity"
 	bornDelete = "apis.DeleteEntity"
+	bornReload = "apis.ReloadEntity"
+)
+
+const defaultMaterializeTimeout = 5 * time.Second
+
+const defaultQueryFieldsLimit = 10000
+
+const defaultAttributesLimit = 4096
+
+const (
+	defaultLockTimeout = 5 * time.Second
+	defaultLockTTL     = 10 * time.Second
+)
+
+const (
+	waitMinBackoff = 20 * time.Millisecond
+	waitMaxBackoff = time.Second
 )
 
 type apiManager struct {
 	holder     holder.Holder
 	dispatcher dispatch.Dispatcher
 	entityRepo repository.IRepository
+	// find entities for maintenance tasks.
+	searchClient v1.SearchHTTPServer
+	// build documents indexed by manager as runtime flushes do, see runtime.MakeSearchDocument.
+	searchModel      []string
+	searchDocBuilder runtime.SearchDocBuilder
+	// index created entity within CreateEntity.
+	indexOnCreate bool
+	// delete created entity and fail the create if indexing failed.
+	failOnIndex bool
+	// record last writer of properties.
+	trackWriters bool
+	// snapshots kept per entity, zero disables snapshots.
+	snapshotLimit int
+	// types listed recently.
+	typesCache typesCache
+	// sensitive properties of entities logged recently.
+	sensitive sensitiveCache
+	// mapper lists served while etcd unavailable.
+	exprCache *exprCache
+
+	maintenance *atomic.Bool
+	hooks       []validationHook
+	idValidator idValidator
+	idGenerator idGenerator
+	// type of untyped creates, rejected if strictType.
+	defaultType string
+	strictType  bool
+	// wait runtime materializing created entity.
+	materializeTimeout time.Duration
+	// prefixes of entity ids keyed by entity type.
+	idPrefixes map[string]string
+	// serialize get-or-create of the same entity.
+	entityLocks map[string]*entityLock
+	// serialize entity writes across core instances.
+	writeLock config.EntityLockConfig
+	// pace writes of bulk tagging.
+	tagInterval time.Duration
+	// max entities returned by QueryFields.
+	queryFieldsLimit int
+	// max bytes of entity attributes.
+	attributesLimit int
+	// flushed on Stop in the order added.
+	drainers     []namedDrainer
+	drainTimeout time.Duration
+	// liveness of entities tracked by runtimes.
+	livenessSource LivenessSource
+	// search documents lagging the state, repaired in the background.
+	repairs *readRepairer
+	// migrations keyed by entity type and schema version they upgrade from.
+	migrations     map[migrationKey]MigrateFunc
+	schemaVersions map[string]int64
 
 	lock   sync.RWMutex
 	ctx    context.Context
@@ -69,14 +140,51 @@ func New(
 ) (APIManager, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	apiManager := &apiManager{
-		ctx:        ctx,
-		cancel:     cancel,
-		entityRepo: repo,
-		dispatcher: dispatcher,
-		lock:       sync.RWMutex{},
-		holder:     holder.New(ctx, 30*time.Second),
+		ctx:         ctx,
+		cancel:      cancel,
+		entityRepo:  repo,
+		dispatcher:  dispatcher,
+		maintenance: atomic.NewBool(false),
+		hooks:       hooksFrom(config.Get().Validation),
+		idValidator: idValidatorFrom(config.Get().Validation.EntityID),
+		idGenerator: idGeneratorFrom(config.Get().Validation.EntityID),
+		defaultType: config.Get().Validation.DefaultEntityType,
+		strictType:  config.Get().Validation.StrictEntityType,
+		lock:        sync.RWMutex{},
+		holder:      holder.New(ctx, 30*time.Second),
+
+		materializeTimeout: materializeTimeoutFrom(config.Get().Server),
+		idPrefixes:         config.Get().Server.Prefixes(),
+		searchModel:        config.Get().Components.SearchModel,
+		tagInterval:        defaultTagInterval,
+		queryFieldsLimit:   config.Get().Server.QueryFieldsLimit,
+		attributesLimit:    config.Get().Server.AttributesLimit,
+		drainTimeout:       drainTimeoutFrom(config.Get().Server),
+		repairs:            newReadRepairer(config.Get().Server.ReadRepairRate),
+		writeLock:          config.Get().EntityLock,
+		indexOnCreate:      config.Get().Components.IndexMode != config.IndexAsync,
+		failOnIndex:        config.Get().Components.IndexFailure == config.IndexFailureFail,
+		trackWriters:       config.Get().Writers.Enabled,
+		snapshotLimit:      config.Get().Snapshot.Limit,
+		exprCache:          newExprCache(time.Duration(config.Get().Components.Etcd.ReadCacheTTL) * time.Second),
 	}
 
+	var err error
+	if apiManager.searchDocBuilder, err = runtime.SearchDocBuilderOf(config.Get().Components.SearchDocBuilder); nil != err {
+		cancel()
+		return nil, errors.Wrap(err, "new manager")
+	}
+
+	// expiry measured from the write time of properties, nothing to sweep without writers tracked.
+	if cfg := config.Get().Expiry; cfg.Enabled && apiManager.trackWriters {
+		go apiManager.runExpirySweeper(cfg)
+	} else if cfg.Enabled {
+		log.L().Warn("expiry sweeper disabled, writers untracked, properties never expire")
+	}
+
+	go apiManager.runReadRepair(apiManager.repairs)
+	go apiManager.runIndexRetry(time.Duration(config.Get().Components.IndexRetryInterval) * time.Second)
+
 	return apiManager, nil
 }
 
@@ -84,12 +192,52 @@ func (m *apiManager) OnRespond(ctx context.Context, resp *holder.Response) {
 	m.holder.OnRespond(resp)
 }
 
+func (m *apiManager) SetMaintenanceMode(on bool) {
+	log.L().Info("set maintenance mode", logf.Bool("on", on))
+	m.maintenance.Store(on)
+}
+
+func (m *apiManager) MaintenanceMode() bool {
+	return m.maintenance.Load()
+}
+
+func (m *apiManager) checkWritable() error {
+	if m.maintenance.Load() {
+		return xerrors.ErrMaintenanceMode
+	}
+	return nil
+}
+
 // ------------------------------------APIs-----------------------------.
 
-func (m *apiManager) checkParams(base *Base) error {
+func (m *apiManager) checkParams(ctx context.Context, base *Base) error {
+	prefix := m.idPrefixes[base.Type]
 	if base.ID == "" {
-		base.ID = util.IG().EID()
+		id, err := m.idGenerator.Generate(prefix)
+		if nil != err {
+			return err
+		}
+		base.ID = id
+	} else if err := m.idValidator.Validate(base.ID); nil != err {
+		return err
+	} else if !strings.HasPrefix(base.ID, prefix) {
+		return errors.Wrapf(xerrors.ErrInvalidEntityParams,
+			"entity id %s of type %s must have prefix %s", base.ID, base.Type, prefix)
+	}
+	if identity, ok := types.IdentityFrom(ctx); ok && base.Owner == "" {
+		base.Owner = identity.User
+	}
+	return nil
+}
+
+// checkType apply default type to untyped entity, or reject it in strict mode.
+func (m *apiManager) checkType(base *Base) error {
+	if base.Type != "" {
+		return nil
+	} else if m.strictType {
+		return errors.Wrapf(xerrors.ErrMissingType, "entity %s", base.ID)
 	}
+	base.Type = m.defaultType
 	return nil
 }
 
@@ -104,20 +252,66 @@ func (m *apiManager) CreateEntity(ctx context.Context, en *Base) (*BaseRet, erro
 		bytes []byte
 	)
 
-	m.checkParams(en)
+	if err = m.checkWritable(); nil != err {
+		log.L().Warn("create entity", logf.Eid(en.ID), logf.Error(err))
+		return nil, err
+	}
+
+	if err = m.checkType(en); nil != err {
+		log.L().Warn("create entity", logf.Eid(en.ID), logf.Error(err))
+		return nil, err
+	}
+
+	generated := en.ID == ""
+	if err = m.checkParams(ctx, en); nil != err {
+		log.L().Warn("create entity", logf.Eid(en.ID), logf.Type(en.Type), logf.Error(err))
+		return nil, err
+	}
+
+	// runtime only knows entities loaded, check state store, generated ids regenerated on collision.
+	if generated {
+		err = m.generateAbsent(ctx, en)
+	} else {
+		err = m.checkAbsent(ctx, en.ID)
+	}
+	if nil != err {
+		log.L().Warn("create entity", logf.Eid(en.ID), logf.Error(err))
+		return nil, err
+	}
+
 	reqID := util.IG().ReqID()
 	elapsedTime := util.NewElapsed()
 	log.L().Info("entity.CreateEntity", logf.Eid(en.ID), logf.Type(en.Type),
-		logf.ReqID(reqID), logf.Owner(en.Owner), logf.Source(en.Source), logf.Base(en.JSON()))
+		logf.ReqID(reqID), logf.Owner(en.Owner), logf.Source(en.Source), logf.Base(m.logJSON(ctx, en)))
+
+	// new entities are created with current schema version.
+	if en.SchemaVersion == 0 {
+		en.SchemaVersion = m.schemaVersion(en.Type)
+	}
 
 	if bytes, err = en.EncodeJSON(); nil != err {
 		log.L().Error("create entity", logf.Eid(en.ID), logf.Type(en.Type),
-			logf.ReqID(reqID), logf.Owner(en.Owner), logf.Source(en.Source), logf.Base(en.JSON()))
+			logf.ReqID(reqID), logf.Owner(en.Owner), logf.Source(en.Source), logf.Base(m.logJSON(ctx, en)))
 		return nil, errors.Wrap(err, "create entity")
 	}
 
-	// hold request, wait response.
-	respWaiter := m.holder.Wait(ctx, reqID)
+	// check tenant quota.
+	var reserved bool
+	if reserved, err = m.reserveEntity(ctx, en.ID, stateTenant(bytes), int64(len(bytes))); nil != err {
+		log.L().Error("create entity, check quota", logf.Eid(en.ID),
+			logf.ReqID(reqID), logf.Owner(en.Owner), logf.Error(err))
+		return nil, err
+	}
+	defer func() {
+		if nil != err && reserved {
+			m.releaseEntity(ctx, en.ID, stateTenant(bytes))
+		}
+	}()
+
+	// hold request, wait response within materialize timeout.
+	waitCtx, cancel := context.WithTimeout(ctx, m.materializeTimeout)
+	defer cancel()
+	respWaiter := m.holder.Wait(waitCtx, reqID)
 
 	// dispatch event.
 	if err = m.dispatcher.Dispatch(ctx, &v1.ProtoEvent{
@@ -147,30 +341,208 @@ func (m *apiManager) CreateEntity(ctx context.Context, en *Base) (*BaseRet, erro
 		logf.Eid(en.ID), logf.ReqID(reqID))
 
 	resp := respWaiter.Wait()
-	if resp.Status != types.StatusOK {
+	if resp.Status != types.StatusOK && nil == ctx.Err() &&
+		errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+		// event dispatched, runtime not materialized entity yet.
+		reserved = false
+		log.L().Warn("create entity, materialize timeout", logf.Eid(en.ID),
+			logf.ReqID(reqID), logf.Elapsed(elapsedTime.Elapsed()))
+		return &BaseRet{
+			ID:         en.ID,
+			Type:       en.Type,
+			Owner:      en.Owner,
+			Source:     en.Source,
+			TemplateID: en.TemplateID,
+			Status:     CreationPending,
+		}, nil
+	} else if resp.Status != types.StatusOK {
+		err = xerrors.New(resp.ErrCode)
 		log.L().Error("create entity", logf.Eid(en.ID), logf.ReqID(reqID),
-			logf.Error(xerrors.New(resp.ErrCode)), logf.Base(en.JSON()))
-		return nil, xerrors.New(resp.ErrCode)
+			logf.Error(err), logf.Base(m.logJSON(ctx, en)))
+		return nil, err
 	}
 
+	if reserved {
+		// entity created, settle the reservation.
+		reserved = false
+		m.resizeEntity(ctx, en.ID, stateTenant(bytes), int64(len(resp.Data)))
+	}
+	m.sensitive.remove(en.ID)
+
 	log.L().Info("processing completed", logf.Eid(en.ID),
 		logf.ReqID(reqID), logf.Elapsed(elapsedTime.Elapsed()))
 
 	var baseRet BaseRet
 	if err = json.Unmarshal(resp.Data, &baseRet); nil != err {
 		log.L().Error("create entity, decode response", logf.ReqID(reqID),
-			logf.Error(err), logf.Eid(en.ID), logf.Base(en.JSON()))
+			logf.Error(err), logf.Eid(en.ID), logf.Base(m.logJSON(ctx, en)))
 		return nil, errors.Wrap(err, "create entity, decode response")
 	}
 
+	if m.indexOnCreate {
+		if err = m.indexCreated(ctx, &baseRet, resp.Data); nil != err {
+			log.L().Error("create entity, index entity", logf.Eid(en.ID),
+				logf.ReqID(reqID), logf.Error(err))
+			return nil, errors.Wrap(err, "create entity")
+		}
+	}
+
+	baseRet.Status = CreationCreated
 	return &baseRet, errors.Wrap(err, "create entity")
 }
 
+// checkAbsent returns ErrEntityAleadyExists if the entity exists in state store.
+func (m *apiManager) checkAbsent(ctx context.Context, id string) error {
+	has, err := m.entityRepo.HasEntity(ctx, id)
+	if nil != err {
+		return errors.Wrap(err, "check entity exists")
+	} else if has {
+		return errors.Wrapf(xerrors.ErrEntityAleadyExists, "entity %s", id)
+	}
+	return nil
+}
+
+// EntitiesExist reports which of the entities exist in state store, checked in one bulk read
+// without decoding entities, used to split bulk imports into creates and updates.
+func (m *apiManager) EntitiesExist(ctx context.Context, ids []string) (map[string]bool, error) {
+	if len(ids) == 0 {
+		return map[string]bool{}, nil
+	}
+
+	exists, err := m.entityRepo.HasEntities(ctx, ids)
+	if nil != err {
+		log.L().Error("check entities exist", logf.Count(int64(len(ids))), logf.Error(err))
+		return nil, errors.Wrap(err, "check entities exist")
+	}
+	return exists, nil
+}
+
+// GetOrCreateEntity returns the entity, creating it if absent, and reports whether it was created.
+// calls for the same entity are serialized, avoid racing creations within the manager.
+func (m *apiManager) GetOrCreateEntity(ctx context.Context, en *Base) (*BaseRet, bool, error) {
+	if err := m.checkParams(ctx, en); nil != err {
+		log.L().Warn("get or create entity", logf.Eid(en.ID), logf.Type(en.Type), logf.Error(err))
+		return nil, false, err
+	}
+	unlock := m.lockEntity(en.ID)
+	defer unlock()
+
+	has, err := m.entityRepo.HasEntity(ctx, en.ID)
+	if nil != err {
+		log.L().Error("get or create entity", logf.Eid(en.ID), logf.Error(err))
+		return nil, false, errors.Wrap(err, "get or create entity")
+	} else if has {
+		baseRet, err := m.GetEntity(ctx, en)
+		return baseRet, false, errors.Wrap(err, "get or create entity")
+	}
+
+	baseRet, err := m.CreateEntity(ctx, en)
+	if nil != err {
+		return nil, false, errors.Wrap(err, "get or create entity")
+	}
+	return baseRet, true, nil
+}
+
+type entityLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockEntity lock the entity, returns the unlock function.
+func (m *apiManager) lockEntity(eid string) func() {
+	m.lock.Lock()
+	if m.entityLocks == nil {
+		m.entityLocks = make(map[string]*entityLock)
+	}
+	l, ok := m.entityLocks[eid]
+	if !ok {
+		l = &entityLock{}
+		m.entityLocks[eid] = l
+	}
+	l.refs++
+	m.lock.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.lock.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(m.entityLocks, eid)
+		}
+		m.lock.Unlock()
+	}
+}
+
+// lockEntityWrite acquire the write lock of the entity shared by core instances if enabled,
+// returns ErrLockTimeout if not acquired within the lock timeout.
+func (m *apiManager) lockEntityWrite(ctx context.Context, eid string) (func(), error) {
+	if !m.writeLock.Enabled {
+		return func() {}, nil
+	}
+
+	timeout, ttl := defaultLockTimeout, defaultLockTTL
+	if m.writeLock.Timeout > 0 {
+		timeout = time.Duration(m.writeLock.Timeout) * time.Second
+	}
+	if m.writeLock.TTL > 0 {
+		ttl = time.Duration(m.writeLock.TTL) * time.Second
+	}
+
+	lockCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	unlock, err := m.entityRepo.LockEntity(lockCtx, eid, ttl)
+	if nil != err {
+		if nil == ctx.Err() && errors.Is(lockCtx.Err(), context.DeadlineExceeded) {
+			return nil, errors.Wrapf(xerrors.ErrLockTimeout, "lock entity %s", eid)
+		}
+		return nil, errors.Wrap(err, "lock entity")
+	}
+	return unlock, nil
+}
+
+// materializeTimeoutFrom returns how long to wait runtime materializing a created entity.
+func materializeTimeoutFrom(cfg config.Server) time.Duration {
+	if cfg.MaterializeTimeout > 0 {
+		return time.Duration(cfg.MaterializeTimeout) * time.Second
+	}
+	return defaultMaterializeTimeout
+}
+
 func (m *apiManager) PatchEntity(ctx context.Context, en *Base, pds []*v1.PatchData, opts ...Option) (out *BaseRet, raw []byte, err error) {
+	// empty patches only read entity.
+	if len(pds) > 0 {
+		if err = m.checkWritable(); nil != err {
+			log.L().Warn("patch entity", logf.Eid(en.ID), logf.Error(err))
+			return out, raw, err
+		}
+
+		if err = m.checkPatchGrowth(ctx, en.ID, pds); nil != err {
+			log.L().Warn("patch entity, check quota", logf.Eid(en.ID), logf.Error(err))
+			return out, raw, err
+		}
+
+		if err = m.validate(ctx, en.ID, pds); nil != err {
+			return out, raw, err
+		}
+
+		var unlock func()
+		if unlock, err = m.lockEntityWrite(ctx, en.ID); nil != err {
+			log.L().Warn("patch entity, lock entity", logf.Eid(en.ID), logf.Error(err))
+			return out, raw, err
+		}
+		defer unlock()
+
+		if m.snapshotLimit > 0 {
+			if err = m.captureSnapshot(ctx, en.ID); nil != err {
+				log.L().Warn("patch entity, capture snapshot", logf.Eid(en.ID), logf.Error(err))
+			}
+		}
+	}
+
 	reqID := util.IG().ReqID()
 	elapsedTime := util.NewElapsed()
 	log.L().Info("entity.PatchEntity", logf.Eid(en.ID), logf.Type(en.Type),
-		logf.ReqID(reqID), logf.Owner(en.Owner), logf.Source(en.Source), logf.Base(en.JSON()))
+		logf.ReqID(reqID), logf.Owner(en.Owner), logf.Source(en.Source), logf.Base(m.logJSON(ctx, en)))
 
 	// hold request.
 	respWaiter := m.holder.Wait(ctx, reqID)
@@ -182,6 +554,13 @@ func (m *apiManager) PatchEntity(ctx context.Context, en *Base, pds []*v1.PatchD
 		v1.MetaEntityID:  en.ID,
 		v1.MetaRequestID: reqID,
 	}
+	if m.trackWriters && len(pds) > 0 {
+		metadata[v1.MetaWriter] = writerFrom(ctx)
+	}
+	// writes of clients checked against property acls by runtime.
+	if identity, ok := types.IdentityFrom(ctx); ok && len(pds) > 0 {
+		metadata[v1.MetaRoles] = strings.Join(identity.Roles, ",")
+	}
 	// use patch options.
 	for _, option := range opts {
 		option(metadata)
@@ -211,7 +590,10 @@ func (m *apiManager) PatchEntity(ctx context.Context, en *Base, pds []*v1.PatchD
 	resp := respWaiter.Wait()
 	if resp.Status != types.StatusOK {
 		log.L().Error("patch entity", logf.Eid(en.ID),
-			logf.Error(xerrors.New(resp.ErrCode)), logf.Base(en.JSON()))
+			logf.Error(xerrors.New(resp.ErrCode)), logf.Base(m.logJSON(ctx, en)))
+		if resp.ErrCode == xerrors.ErrEntityConflict.Error() {
+			return out, raw, xerrors.ErrEntityConflict
+		}
 		return out, raw, xerrors.New(resp.ErrCode)
 	}
 
@@ -219,10 +601,21 @@ func (m *apiManager) PatchEntity(ctx context.Context, en *Base, pds []*v1.PatchD
 	if err = json.Unmarshal(resp.Data, &baseRet); nil != err {
 		log.L().Error("patch entity, decode response",
 			logf.ReqID(reqID), logf.Error(err), logf.Eid(en.ID),
-			logf.Base(en.JSON()), logf.Entity(string(resp.Data)))
+			logf.Base(m.logJSON(ctx, en)), logf.Entity(redactEntity(resp.Data)))
 		return out, raw, errors.Wrap(err, "patch entity, decode response")
 	}
 
+	if len(pds) > 0 {
+		m.resizeEntity(ctx, en.ID, stateTenant(resp.Data), int64(len(resp.Data)))
+		m.sensitive.remove(en.ID)
+	}
+
+	if conflicts := resp.Metadata[v1.MetaMergeConflicts]; conflicts != "" {
+		if err = json.Unmarshal([]byte(conflicts), &baseRet.Conflicts); nil != err {
+			log.L().Warn("patch entity, decode merge conflicts", logf.Eid(en.ID), logf.Error(err))
+		}
+	}
+
 	log.L().Info("processing completed", logf.Eid(en.ID),
 		logf.ReqID(reqID), logf.Elapsed(elapsedTime.Elapsed()))
 
@@ -232,6 +625,15 @@ func (m *apiManager) PatchEntity(ctx context.Context, en *Base, pds []*v1.PatchD
 // GetProperties returns Base.
 func (m *apiManager) GetEntity(ctx context.Context, en *Base) (*BaseRet, error) {
 	var err error
+	if readPreferenceFrom(ctx) == ReadReplica {
+		var ret *BaseRet
+		if ret, err = m.loadReplicaEntity(ctx, en.ID); nil != err {
+			log.L().Error("get entity from replica", logf.Eid(en.ID), logf.Error(err))
+			return nil, errors.Wrap(err, "get entity")
+		}
+		return ret, nil
+	}
+
 	reqID := util.IG().ReqID()
 	elapsedTime := util.NewElapsed()
 	log.L().Info("entity.GetEntity", logf.Eid(en.ID), logf.Type(en.Type),
@@ -279,26 +681,63 @@ func (m *apiManager) GetEntity(ctx context.Context, en *Base) (*BaseRet, error)
 	var baseRet BaseRet
 	if err = json.Unmarshal(resp.Data, &baseRet); nil != err {
 		log.L().Error("get entity, decode response", logf.ReqID(reqID),
-			logf.Error(err), logf.Eid(en.ID), logf.Base(en.JSON()))
+			logf.Error(err), logf.Eid(en.ID), logf.Base(m.logJSON(ctx, en)))
 		return nil, errors.Wrap(err, "create entity, decode response")
 	}
 
+	if err = m.migrateStored(&baseRet); nil != err {
+		log.L().Error("get entity, migrate entity", logf.ReqID(reqID),
+			logf.Error(err), logf.Eid(en.ID), logf.Type(baseRet.Type))
+		return nil, errors.Wrap(err, "get entity")
+	}
+
 	return &baseRet, errors.Wrap(err, "get entity")
 }
 
 // DeleteEntity delete an entity from manager.
 func (m *apiManager) DeleteEntity(ctx context.Context, en *Base) error {
 	var err error
+	if err = m.checkWritable(); nil != err {
+		log.L().Warn("delete entity", logf.Eid(en.ID), logf.Error(err))
+		return err
+	}
+
 	reqID := util.IG().ReqID()
 	elapsedTime := util.NewElapsed()
 	log.L().Info("entity.DeleteEntity", logf.Eid(en.ID), logf.Type(en.Type),
-		logf.ReqID(reqID), logf.Owner(en.Owner), logf.Source(en.Source), logf.Base(en.JSON()))
+		logf.ReqID(reqID), logf.Owner(en.Owner), logf.Source(en.Source), logf.Base(m.logJSON(ctx, en)))
+
+	respWaiter, err := m.dispatchDelete(ctx, en.ID, reqID)
+	if nil != err {
+		return err
+	}
+
+	log.L().Debug("holding request, wait response",
+		logf.Eid(en.ID), logf.ReqID(reqID))
+
+	// hold request, wait response.
+
+	resp := respWaiter.Wait()
+	if resp.Status != types.StatusOK {
+		log.L().Error("delete entity", logf.Eid(en.ID),
+			logf.ReqID(reqID), logf.Error(xerrors.New(resp.ErrCode)))
+		return xerrors.New(resp.ErrCode)
+	}
+	m.entityDeleted(ctx, en.ID, stateTenant(resp.Data))
+
+	log.L().Info("processing completed", logf.Eid(en.ID),
+		logf.ReqID(reqID), logf.Elapsed(elapsedTime.Elapsed()))
+
+	return nil
+}
 
+// dispatchDelete hold the request and dispatch the delete event of the entity to runtime.
+func (m *apiManager) dispatchDelete(ctx context.Context, eid, reqID string) (*holder.Waiter, error) {
 	// hold request.
 	respWaiter := m.holder.Wait(ctx, reqID)
 
 	// dispatch event.
-	if err = m.dispatcher.Dispatch(ctx, &v1.ProtoEvent{
+	if err := m.dispatcher.Dispatch(ctx, &v1.ProtoEvent{
 		Id:        util.IG().EvID(),
 		Timestamp: time.Now().UnixNano(),
 		Callback:  m.callbackAddr(),
@@ -306,7 +745,7 @@ func (m *apiManager) DeleteEntity(ctx context.Context, en *Base) error {
 			v1.MetaBorn:      bornDelete,
 			v1.MetaType:      sysET,
 			v1.MetaRequestID: reqID,
-			v1.MetaEntityID:  en.ID,
+			v1.MetaEntityID:  eid,
 		},
 		Data: &v1.ProtoEvent_SystemData{
 			SystemData: &v1.SystemData{
@@ -316,41 +755,223 @@ func (m *apiManager) DeleteEntity(ctx context.Context, en *Base) error {
 	}); nil != err {
 		respWaiter.Cancel()
 		log.L().Error("delete entity, dispatch event",
-			logf.Error(err), logf.Eid(en.ID), logf.ReqID(reqID))
-		return errors.Wrap(err, "delete entity, dispatch event")
+			logf.Error(err), logf.Eid(eid), logf.ReqID(reqID))
+		return nil, errors.Wrap(err, "delete entity, dispatch event")
+	}
+	return respWaiter, nil
+}
+
+// entityDeleted cleanup etcd resources of the entity of the tenant deleted by runtime.
+func (m *apiManager) entityDeleted(ctx context.Context, eid, tenant string) {
+	m.releaseEntity(ctx, eid, tenant)
+	m.leaveGroups(ctx, eid)
+	m.removeMirrors(ctx, eid)
+	m.sensitive.remove(eid)
+}
+
+// DeleteEntities delete entities in batch, continue past individual failures, returns errors keyed by entity id.
+// delete events of all entities dispatched before waiting any, so runtimes delete the batch concurrently,
+// etcd resources cleaned up per entity. entity states are not removed by one bulk state call, runtimes
+// remove the state of each entity together with its cached state, mapper tentacles and search index.
+func (m *apiManager) DeleteEntities(ctx context.Context, ids []string, opts DeleteOptions) map[string]error {
+	errs := make(map[string]error)
+	if opts.Soft {
+		for _, id := range ids {
+			if err := m.tombstoneEntity(ctx, &Base{ID: id, Owner: opts.Owner}); nil != err {
+				log.L().Error("delete entities", logf.Eid(id),
+					logf.Owner(opts.Owner), logf.Bool("soft", opts.Soft), logf.Error(err))
+				errs[id] = err
+			}
+		}
+		return errs
 	}
 
-	log.L().Debug("holding request, wait response",
-		logf.Eid(en.ID), logf.ReqID(reqID))
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("delete entities", logf.Count(int64(len(ids))), logf.Error(err))
+		for _, id := range ids {
+			errs[id] = err
+		}
+		return errs
+	}
 
-	// hold request, wait response.
+	waiters := make(map[string]*holder.Waiter, len(ids))
+	for _, id := range ids {
+		if _, has := waiters[id]; has {
+			continue
+		}
 
-	if resp := respWaiter.Wait(); resp.Status != types.StatusOK {
-		log.L().Error("delete entity", logf.Eid(en.ID),
-			logf.ReqID(reqID), logf.Error(xerrors.New(resp.ErrCode)))
-		return xerrors.New(resp.ErrCode)
+		waiter, err := m.dispatchDelete(ctx, id, util.IG().ReqID())
+		if nil != err {
+			errs[id] = err
+			continue
+		}
+		waiters[id] = waiter
 	}
 
-	log.L().Info("processing completed", logf.Eid(en.ID),
-		logf.ReqID(reqID), logf.Elapsed(elapsedTime.Elapsed()))
+	for id, waiter := range waiters {
+		resp := waiter.Wait()
+		if resp.Status != types.StatusOK {
+			log.L().Error("delete entities", logf.Eid(id),
+				logf.Owner(opts.Owner), logf.Error(xerrors.New(resp.ErrCode)))
+			errs[id] = xerrors.New(resp.ErrCode)
+			continue
+		}
 
+		m.entityDeleted(ctx, id, stateTenant(resp.Data))
+		if err := m.purgeResources(ctx, &Base{ID: id, Owner: opts.Owner}); nil != err {
+			log.L().Error("delete entities", logf.Eid(id), logf.Owner(opts.Owner), logf.Error(err))
+			errs[id] = err
+		}
+	}
+
+	return errs
+}
+
+// PatchEntities apply the same patches to entities one by one through PatchEntity, the patches
+// checked once up front, rejected for all entities if malformed. continue past individual failures,
+// returns entities patched and errors keyed by entity id.
+func (m *apiManager) PatchEntities(ctx context.Context, ids []string, pds []*v1.PatchData) (map[string]*BaseRet, map[string]error) {
+	rets := make(map[string]*BaseRet)
+	errs := make(map[string]error)
+	if err := checkPatches(pds); nil != err {
+		log.L().Warn("patch entities", logf.Count(int64(len(ids))), logf.Error(err))
+		for _, id := range ids {
+			errs[id] = err
+		}
+		return rets, errs
+	}
+
+	for _, id := range ids {
+		ret, _, err := m.PatchEntity(ctx, &Base{ID: id}, pds)
+		if nil != err {
+			log.L().Error("patch entities", logf.Eid(id), logf.Error(err))
+			errs[id] = err
+			continue
+		}
+		rets[id] = ret
+	}
+
+	return rets, errs
+}
+
+// checkPatches reports malformed patches, patches of unsupported operator, empty path or invalid value.
+func checkPatches(pds []*v1.PatchData) error {
+	if len(pds) == 0 {
+		return errors.Wrap(xerrors.ErrEmptyParam, "check patches")
+	}
+
+	for _, pd := range pds {
+		if pd.Path == "" {
+			return errors.Wrap(xerrors.ErrPatchPathInvalid, "check patches, empty path")
+		}
+
+		switch xjson.NewPatchOp(pd.Operator) {
+		case xjson.OpRemove:
+		case xjson.OpAdd, xjson.OpMerge, xjson.OpReplace:
+			// decoded rather than json.Valid, which rejects bare numbers.
+			var value interface{}
+			if err := json.Unmarshal(pd.Value, &value); nil != err {
+				return errors.Wrapf(xerrors.ErrInvalidParam, "check patches, invalid value of path %s", pd.Path)
+			}
+		default:
+			return errors.Wrapf(xerrors.ErrJSONPatchReservedOp, "check patches, operator %s", pd.Operator)
+		}
+	}
 	return nil
 }
 
+func (m *apiManager) tombstoneEntity(ctx context.Context, en *Base) error {
+	bytes, _ := json.Marshal(time.Now().UnixNano() / 1e6)
+	_, _, err := m.PatchEntity(ctx, en, []*v1.PatchData{{
+		Path:     FieldDeletedAt,
+		Operator: xjson.OpReplace.String(),
+		Value:    bytes,
+	}})
+	return errors.Wrap(err, "tombstone entity")
+}
+
+func (m *apiManager) purgeEntity(ctx context.Context, en *Base) error {
+	if err := m.DeleteEntity(ctx, en); nil != err {
+		return errors.Wrap(err, "purge entity")
+	}
+	return m.purgeResources(ctx, en)
+}
+
+// purgeResources cleanup expressions and snapshots of the entity deleted.
+func (m *apiManager) purgeResources(ctx context.Context, en *Base) error {
+	// cleanup entity expressions.
+	if en.Owner != "" {
+		if err := m.entityRepo.DelExprByEnity(ctx,
+			repository.Expression{Owner: en.Owner, EntityID: en.ID}); nil != err {
+			return errors.Wrap(err, "purge entity expressions")
+		}
+	}
+	return errors.Wrap(m.entityRepo.DelSnapshots(ctx, en.ID), "purge entity snapshots")
+}
+
+// FreezeEntity freeze or unfreeze entity, mappers of frozen entity stop firing and
+// mappers reading from it see its last value, see runtime.FieldFrozen.
+func (m *apiManager) FreezeEntity(ctx context.Context, en *Base, frozen bool) error {
+	bytes, _ := json.Marshal(frozen)
+	_, _, err := m.PatchEntity(ctx, en, []*v1.PatchData{{
+		Path:     FieldFrozen,
+		Operator: xjson.OpReplace.String(),
+		Value:    bytes,
+	}})
+	return errors.Wrap(err, "freeze entity")
+}
+
+// WaitForEntity polls the state store until entity visible or timeout elapsed.
+func (m *apiManager) WaitForEntity(ctx context.Context, id string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := waitMinBackoff
+	for {
+		has, err := m.entityRepo.HasEntity(ctx, id)
+		if nil != err {
+			log.L().Warn("wait for entity", logf.Eid(id), logf.Error(err))
+		} else if has {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			log.L().Error("wait for entity, timeout", logf.Eid(id), logf.Error(ctx.Err()))
+			return errors.Wrap(ctx.Err(), "wait for entity")
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > waitMaxBackoff {
+			backoff = waitMaxBackoff
+		}
+	}
+}
+
 // AppendMapper append a mapper into entity.
 func (m *apiManager) AppendMapper(ctx context.Context, mp *mapper.Mapper) error {
 	log.L().Info("entity.AppendMapper",
 		logf.ID(mp.ID), logf.Eid(mp.EntityID), logf.Owner(mp.Owner))
 
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("append mapper", logf.Eid(mp.EntityID), logf.Error(err))
+		return err
+	}
+
 	{
 		// check mapper.
-		if err := checkMapper(mp); nil != err {
+		if err := CheckMapper(mp); nil != err {
 			log.L().Error("append mapper", logf.Eid(mp.EntityID), logf.Error(err))
 			return errors.Wrap(err, "check mapper")
 		}
+
+		if err := m.checkMapperType(ctx, mp); nil != err {
+			log.L().Warn("append mapper", logf.ID(mp.ID), logf.Eid(mp.EntityID), logf.Error(err))
+			return err
+		}
 	}
 
-	exprs := convExprs(*mp)
+	exprs := MapperExprs(*mp)
 	if err := m.appendExpression(ctx, exprs); nil != err {
 		log.L().Error("append mapper", logf.Error(err),
 			logf.ID(mp.ID), logf.Eid(mp.EntityID))
@@ -359,13 +980,39 @@ func (m *apiManager) AppendMapper(ctx context.Context, mp *mapper.Mapper) error
 	return nil
 }
 
+// checkMapperType reject attaching a typed mapper to an entity of other types.
+func (m *apiManager) checkMapperType(ctx context.Context, mp *mapper.Mapper) error {
+	if len(mp.AppliesToTypes) == 0 {
+		return nil
+	}
+
+	en, err := m.loadEntity(ctx, mp.EntityID)
+	if nil != err {
+		return errors.Wrap(err, "check mapper type")
+	}
+
+	for _, typ := range mp.AppliesToTypes {
+		if typ == en.Type {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(xerrors.ErrMapperTypeMismatch,
+		"mapper %s applies to %v, entity %s type %q", mp.ID, mp.AppliesToTypes, en.ID, en.Type)
+}
+
 // AppendMapper append a mapper into entity.
 func (m *apiManager) AppendMapperZ(ctx context.Context, mp *mapper.Mapper) error {
 	log.L().Info("entity.AppendMapperZ",
 		logf.ID(mp.ID), logf.Eid(mp.EntityID), logf.Owner(mp.Owner))
 
 	var err error
-	exprs := convExprs(*mp)
+	if err = m.checkWritable(); nil != err {
+		log.L().Warn("append mapper", logf.Eid(mp.EntityID), logf.Error(err))
+		return err
+	}
+
+	exprs := MapperExprs(*mp)
 	if err = m.appendExpression(ctx, exprs); nil != err {
 		log.L().Error("append mapper", logf.Error(err),
 			logf.ID(mp.ID), logf.Eid(mp.EntityID))
@@ -374,7 +1021,8 @@ func (m *apiManager) AppendMapperZ(ctx context.Context, mp *mapper.Mapper) error
 	return errors.Wrap(err, "append mapper")
 }
 
-func checkMapper(m *mapper.Mapper) error {
+// CheckMapper validate mapper TQL and qualify the property paths in it.
+func CheckMapper(m *mapper.Mapper) error {
 	sep := "."
 	FieldProps := "properties"
 	if m.ID == "" {
@@ -516,6 +1164,11 @@ func (m *apiManager) appendExpression(ctx context.Context, exprs []repository.Ex
 }
 
 func (m *apiManager) RemoveExpression(ctx context.Context, exprs []repository.Expression) error {
+	exprs, err := m.expandExpressions(ctx, exprs)
+	if nil != err {
+		return errors.Wrap(err, "delete expression")
+	}
+
 	// delete expressions.
 	for index := range exprs {
 		log.L().Debug("remove expression",
@@ -533,6 +1186,24 @@ func (m *apiManager) RemoveExpression(ctx context.Context, exprs []repository.Ex
 }
 
 func (m *apiManager) GetExpression(ctx context.Context, expr repository.Expression) (*repository.Expression, error) {
+	// expression identified by path only, returns the one in effect.
+	if expr.Name == "" {
+		exprs, err := m.expressionsAt(ctx, expr)
+		if nil != err {
+			return nil, errors.Wrap(err, "get expression")
+		}
+
+		var winner *repository.Expression
+		for _, item := range exprs {
+			if !item.Disabled && (winner == nil || item.Outranks(winner)) {
+				winner = item
+			}
+		}
+		if winner != nil {
+			return winner, nil
+		}
+	}
+
 	// get expression.
 	expr, err := m.entityRepo.GetExpression(ctx, expr)
 	if nil != err {
@@ -543,22 +1214,144 @@ func (m *apiManager) GetExpression(ctx context.Context, expr repository.Expressi
 	return &expr, nil
 }
 
+// expandExpressions expand expressions identified by path only into the expressions
+// of every mapper writing the path.
+func (m *apiManager) expandExpressions(ctx context.Context, exprs []repository.Expression) ([]repository.Expression, error) {
+	expanded := make([]repository.Expression, 0, len(exprs))
+	for _, expr := range exprs {
+		if expr.Name != "" {
+			expanded = append(expanded, expr)
+			continue
+		}
+
+		items, err := m.expressionsAt(ctx, expr)
+		if nil != err {
+			return nil, err
+		}
+		// path-only key of expressions stored before keyed by name.
+		expanded = append(expanded, expr)
+		for _, item := range items {
+			if item.Name != "" {
+				expanded = append(expanded, *item)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// expressionsAt returns expressions of the entity writing the path of expr.
+func (m *apiManager) expressionsAt(ctx context.Context, expr repository.Expression) ([]*repository.Expression, error) {
+	exprs, err := m.ListExpression(ctx, &Base{ID: expr.EntityID, Owner: expr.Owner})
+	if nil != err {
+		return nil, err
+	}
+
+	var items []*repository.Expression
+	for _, item := range exprs {
+		if item.Path == expr.Path {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
 func (m *apiManager) ListExpression(ctx context.Context, en *Base) ([]*repository.Expression, error) {
+	exprs, _, err := m.ListExpressionCached(ctx, en)
+	return exprs, err
+}
+
+// ListExpressionCached list expressions of the entity, served from cache if etcd unavailable and
+// the entity expressions listed within the cache ttl, stale reports whether served from cache.
+func (m *apiManager) ListExpressionCached(ctx context.Context, en *Base) (exprs []*repository.Expression, stale bool, err error) {
 	// list expressions.
-	var err error
-	var exprs []*repository.Expression
 	if exprs, err = m.entityRepo.ListExpression(ctx,
 		m.entityRepo.GetLastRevision(ctx),
 		&repository.ListExprReq{
 			Owner:    en.Owner,
 			EntityID: en.ID,
 		}); nil != err {
+		if cached, ok := m.exprCache.get(en.Owner, en.ID); ok {
+			log.L().Warn("list expression, served stale from cache", logf.Error(err),
+				logf.Eid(en.ID), logf.Owner(en.Owner))
+			return cached, true, nil
+		}
+
 		log.L().Error("list expression", logf.Error(err),
 			logf.Eid(en.ID), logf.Owner(en.Owner))
-		return exprs, errors.Wrap(err, "list expression")
+		return exprs, false, errors.Wrap(err, "list expression")
 	}
 
-	return exprs, nil
+	m.exprCache.put(en.Owner, en.ID, exprs)
+	return exprs, false, nil
+}
+
+// SetMapperEnabled enable or disable expressions of the mapper without removing them.
+func (m *apiManager) SetMapperEnabled(ctx context.Context, en *Base, name string, enabled bool) error {
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("set mapper enabled", logf.Eid(en.ID), logf.Name(name), logf.Error(err))
+		return err
+	}
+
+	exprs, err := m.ListExpression(ctx, en)
+	if nil != err {
+		return errors.Wrap(err, "set mapper enabled")
+	}
+
+	found := false
+	for _, expr := range exprs {
+		if expr.Name != name {
+			continue
+		}
+
+		found = true
+		if expr.Disabled == !enabled {
+			continue
+		}
+
+		expr.Disabled = !enabled
+		if err = m.entityRepo.PutExpression(ctx, *expr); nil != err {
+			log.L().Error("set mapper enabled", logf.Eid(en.ID), logf.Name(name),
+				logf.Bool("enabled", enabled), logf.Error(err))
+			return errors.Wrap(err, "set mapper enabled")
+		}
+	}
+
+	if !found {
+		return errors.Wrap(xerrors.ErrExpressionNotFound, "set mapper enabled")
+	}
+	return nil
+}
+
+// DeleteMapperByName remove expressions of the named mapper, entity owner resolved from entity state.
+func (m *apiManager) DeleteMapperByName(ctx context.Context, entityID, name string) error {
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("delete mapper", logf.Eid(entityID), logf.Name(name), logf.Error(err))
+		return err
+	}
+
+	base, err := m.GetEntity(ctx, &Base{ID: entityID})
+	if nil != err {
+		return errors.Wrap(err, "delete mapper")
+	}
+
+	exprs, err := m.ListExpression(ctx, &Base{ID: entityID, Owner: base.Owner})
+	if nil != err {
+		return errors.Wrap(err, "delete mapper")
+	}
+
+	removes := make([]repository.Expression, 0)
+	for _, expr := range exprs {
+		if expr.Name == name {
+			removes = append(removes, *expr)
+		}
+	}
+
+	if len(removes) == 0 {
+		return errors.Wrap(xerrors.ErrMapperNotFound, "delete mapper")
+	}
+
+	// runtime drop expressions on watching the deletion.
+	return errors.Wrap(m.RemoveExpression(ctx, removes), "delete mapper")
 }
 
 ///////////
@@ -577,9 +1370,10 @@ func (m *apiManager) GetSubscription(ctx context.Context, subscription *reposito
 
 //////////////
 
-func convExprs(mp mapper.Mapper) []repository.Expression {
+// MapperExprs returns expressions of the checked mapper, one for each selected property.
+func MapperExprs(mp mapper.Mapper) []repository.Expression {
 	segs := strings.SplitN(mp.TQL, "select", 2)
-	arr := strings.Split(segs[1], ",")
+	arr := splitFields(segs[1])
 
 	exprs := []repository.Expression{}
 	for index := range arr {
@@ -590,9 +1384,39 @@ func convExprs(mp mapper.Mapper) []repository.Expression {
 			path = segs[1]
 		}
 
-		exprs = append(exprs,
-			*repository.NewExpression(
-				mp.Owner, mp.EntityID, mp.Name, path, segs[0], mp.Description))
+		expr := repository.NewExpression(
+			mp.Owner, mp.EntityID, mp.Name, path, segs[0], mp.Description)
+		expr.Priority = mp.Priority
+		exprs = append(exprs, *expr)
 	}
 	return exprs
 }
+
+// splitFields split select fields on commas outside function calls and string literals.
+func splitFields(fields string) []string {
+	var (
+		quote  byte
+		depth  int
+		offset int
+		arr    []string
+	)
+
+	for index := 0; index < len(fields); index++ {
+		switch ch := fields[index]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case ch == ',' && depth == 0:
+			arr = append(arr, fields[offset:index])
+			offset = index + 1
+		}
+	}
+	return append(arr, fields[offset:])
+}
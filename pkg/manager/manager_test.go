@@ -17,10 +17,34 @@ limitations under the License.
 package manager
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/manager/holder"
 	"github.com/tkeel-io/core/pkg/mapper"
+	"github.com/tkeel-io/core/pkg/mapper/expression"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/repository/dao"
+	"github.com/tkeel-io/core/pkg/resource/search/driver"
+	_ "github.com/tkeel-io/core/pkg/resource/store/memory"
+	"github.com/tkeel-io/core/pkg/runtime"
+	"github.com/tkeel-io/core/pkg/runtime/mock"
+	"github.com/tkeel-io/core/pkg/types"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/tdtl"
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func TestEntity_GetEntity(t *testing.T) {
@@ -45,9 +69,2091 @@ func Test_checkTQL(t *testing.T) {
 	for index := range mappers {
 		t.Run(mappers[index].Name, func(t *testing.T) {
 			mp := &mapper.Mapper{Name: mappers[index].Name, TQL: mappers[index].TQL}
-			err := checkMapper(mp)
+			err := CheckMapper(mp)
 			assert.Nil(t, err)
 			assert.Equal(t, mappers[index].Expect, mp.TQL)
 		})
 	}
 }
+
+func Test_customTQLFunction(t *testing.T) {
+	number := func(node tdtl.Node) float64 {
+		switch node := node.(type) {
+		case tdtl.IntNode:
+			return float64(node)
+		case tdtl.FloatNode:
+			return float64(node)
+		}
+		return 0
+	}
+
+	mapper.RegisterTQLFunction("clamp", func(args ...tdtl.Node) tdtl.Node {
+		if len(args) != 3 {
+			return tdtl.UNDEFINED_RESULT
+		}
+		value, low, high := number(args[0]), number(args[1]), number(args[2])
+		if value < low {
+			return tdtl.FloatNode(low)
+		} else if value > high {
+			return tdtl.FloatNode(high)
+		}
+		return tdtl.FloatNode(value)
+	})
+
+	mp := mapper.Mapper{
+		Name:     "cpu-mapper",
+		Owner:    "admin",
+		EntityID: "device123",
+		TQL:      "insert into device123 select clamp(device234.cpu, 0, 1) as cpu, device234.temp as temp",
+	}
+
+	assert.Nil(t, CheckMapper(&mp))
+	exprs := MapperExprs(mp)
+	assert.Len(t, exprs, 2)
+	assert.Equal(t, "properties.cpu", exprs[0].Path)
+
+	exprIns, err := expression.NewExpr(exprs[0].Expression, nil)
+	assert.Nil(t, err)
+	ret, err := exprIns.Eval(context.Background(), map[string]tdtl.Node{"device234.properties.cpu": tdtl.FloatNode(1.5)})
+	assert.Nil(t, err)
+	assert.Equal(t, tdtl.FloatNode(1), ret)
+}
+
+func Test_derivedProvenance(t *testing.T) {
+	mp := mapper.Mapper{
+		Name:     "cpu-mapper",
+		Owner:    "admin",
+		EntityID: "device123",
+		TQL:      "insert into device123 select device234.metrics.cpu as cpu",
+	}
+
+	assert.Nil(t, CheckMapper(&mp))
+	exprs := MapperExprs(mp)
+	exprPtrs := make([]*repository.Expression, 0)
+	for index := range exprs {
+		exprPtrs = append(exprPtrs, &exprs[index])
+	}
+
+	provenances := derivedProvenance(exprPtrs)
+	assert.Equal(t, Provenance{Source: ProvenanceDerived, Mapper: "cpu-mapper"}, provenances["cpu"])
+
+	props := map[string]interface{}{"cpu": 0.3, "temp": 20}
+	assert.Equal(t, map[string]interface{}{"temp": 20}, FilterProperties(props, provenances, ProvenanceRaw))
+	assert.Equal(t, map[string]interface{}{"cpu": 0.3}, FilterProperties(props, provenances, ProvenanceDerived))
+}
+
+func Test_dependencyGraph(t *testing.T) {
+	var exprs []*repository.Expression
+	for _, mp := range []mapper.Mapper{
+		{Name: "cpu-mapper", TQL: "insert into device123 select device234.metrics.cpu as cpu"},
+		{Name: "temp-mapper", TQL: "insert into device123 select device123.temp * 1.8 + 32 as temp_f"},
+		{Name: "alert-mapper", TQL: "insert into device123 select device123.temp_f + device123.cpu as load"},
+	} {
+		mp.Owner, mp.EntityID = "admin", "device123"
+		assert.Nil(t, CheckMapper(&mp))
+		items := MapperExprs(mp)
+		for index := range items {
+			exprs = append(exprs, &items[index])
+		}
+	}
+
+	graph := NewDepGraph("device123", exprs)
+	assert.Len(t, graph.Derived, 3)
+	assert.Equal(t, &DepNode{Property: "cpu", Mappers: []string{"cpu-mapper"},
+		Sources: []DepSource{{EntityID: "device234", Property: "metrics.cpu"}}}, graph.Derived["cpu"])
+	assert.Equal(t, []DepSource{{EntityID: "device123", Property: "cpu"}, {EntityID: "device123", Property: "temp_f"}},
+		graph.Derived["load"].Sources)
+
+	assert.Equal(t, []string{"load", "temp_f"}, graph.Dependents("temp"))
+	assert.Equal(t, []string{"load"}, graph.Dependents("cpu"))
+	assert.Len(t, graph.Dependents("metrics.cpu"), 0)
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	m := &apiManager{maintenance: atomic.NewBool(false)}
+	assert.False(t, m.MaintenanceMode())
+
+	m.SetMaintenanceMode(true)
+	assert.True(t, m.MaintenanceMode())
+	assert.ErrorIs(t, m.DeleteEntity(context.Background(), &Base{ID: "device123"}), xerrors.ErrMaintenanceMode)
+	assert.ErrorIs(t, m.AppendMapper(context.Background(), &mapper.Mapper{EntityID: "device123"}), xerrors.ErrMaintenanceMode)
+	assert.ErrorIs(t, m.DeleteMapperByName(context.Background(), "device123", "cpu-mapper"), xerrors.ErrMaintenanceMode)
+}
+
+func TestCreateEntity_MaterializeTimeout(t *testing.T) {
+	assert.Equal(t, 5*time.Second, materializeTimeoutFrom(config.Server{}))
+	assert.Equal(t, 2*time.Second, materializeTimeoutFrom(config.Server{MaterializeTimeout: 2}))
+
+	memDao, err := dao.NewMock(context.Background(), config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	m := &apiManager{
+		holder:             holder.New(context.Background(), time.Minute),
+		dispatcher:         mock.NewDispatcher(),
+		entityRepo:         repository.New(memDao),
+		maintenance:        atomic.NewBool(false),
+		materializeTimeout: 20 * time.Millisecond,
+	}
+
+	// runtime never responds.
+	ret, err := m.CreateEntity(context.Background(), &Base{ID: "device123", Owner: "admin"})
+	assert.Nil(t, err)
+	assert.Equal(t, "device123", ret.ID)
+	assert.Equal(t, CreationPending, ret.Status)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = m.CreateEntity(ctx, &Base{ID: "device234", Owner: "admin"})
+	assert.NotNil(t, err)
+}
+
+type hasEntityRepo struct {
+	repository.IRepository
+	has bool
+	err error
+}
+
+func (r *hasEntityRepo) HasEntity(context.Context, string) (bool, error) {
+	return r.has, r.err
+}
+
+func TestCreateEntity_Exists(t *testing.T) {
+	errStore := errors.New("store unavailable")
+	tests := []struct {
+		name    string
+		repo    *hasEntityRepo
+		wantErr error
+	}{
+		{"absent", &hasEntityRepo{}, nil},
+		{"present", &hasEntityRepo{has: true}, xerrors.ErrEntityAleadyExists},
+		{"store error", &hasEntityRepo{err: errStore}, errStore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &apiManager{
+				holder:             holder.New(context.Background(), time.Minute),
+				dispatcher:         mock.NewDispatcher(),
+				entityRepo:         tt.repo,
+				maintenance:        atomic.NewBool(false),
+				materializeTimeout: 20 * time.Millisecond,
+			}
+
+			ret, err := m.CreateEntity(context.Background(), &Base{ID: "device123", Owner: "admin"})
+			if tt.wantErr == nil {
+				assert.Nil(t, err)
+				assert.Equal(t, CreationPending, ret.Status)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Nil(t, ret)
+		})
+	}
+}
+
+func TestCreateEntity_GeneratedIDCollision(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123"}`)))
+
+	var ids []string
+	m := &apiManager{
+		holder:             holder.New(ctx, time.Minute),
+		dispatcher:         mock.NewDispatcher(),
+		entityRepo:         repo,
+		maintenance:        atomic.NewBool(false),
+		materializeTimeout: 20 * time.Millisecond,
+		idGenerator: idGenerator{retries: 2, generate: func(prefix string) (string, error) {
+			if len(ids) == 0 {
+				return "", errors.New("entropy exhausted")
+			}
+			id := ids[0]
+			ids = ids[1:]
+			return id, nil
+		}},
+	}
+
+	// generated ids regenerated on collision.
+	ids = []string{"device123", "device123", "device234"}
+	ret, err := m.CreateEntity(ctx, &Base{Owner: "admin"})
+	assert.Nil(t, err)
+	assert.Equal(t, "device234", ret.ID)
+
+	// retries exhausted.
+	ids = []string{"device123", "device123", "device123"}
+	_, err = m.CreateEntity(ctx, &Base{Owner: "admin"})
+	assert.ErrorIs(t, err, xerrors.ErrEntityAleadyExists)
+
+	// supplied ids never regenerated.
+	ids = []string{"device345"}
+	_, err = m.CreateEntity(ctx, &Base{ID: "device123", Owner: "admin"})
+	assert.ErrorIs(t, err, xerrors.ErrEntityAleadyExists)
+
+	// generation failure never creates an empty id entity.
+	ids = nil
+	_, err = m.CreateEntity(ctx, &Base{Owner: "admin"})
+	assert.NotNil(t, err)
+}
+
+func Test_checkQuota(t *testing.T) {
+	limit := config.QuotaLimit{MaxEntities: 2, MaxSize: 100}
+	usage := &repository.QuotaUsage{Tenant: "tenant01", Count: 1, Size: 60}
+
+	assert.Nil(t, checkQuota("tenant01", limit, usage, 1, 40))
+	assert.ErrorIs(t, checkQuota("tenant01", limit, usage, 1, 41), xerrors.ErrQuotaExceeded)
+	usage.Count = 2
+	assert.ErrorIs(t, checkQuota("tenant01", limit, usage, 1, 0), xerrors.ErrQuotaExceeded)
+	assert.Nil(t, checkQuota("tenant01", limit, usage, 0, 10))
+	assert.Nil(t, checkQuota("tenant01", config.QuotaLimit{}, usage, 1, 1000))
+}
+
+func TestCloneExprs(t *testing.T) {
+	exprs := []repository.Expression{*repository.NewExpression("admin", "device123",
+		"calibrate", "temp", "device123.temp + device234.offset", "")}
+	clone := &Base{ID: "device123-clone", Owner: "tenant01"}
+
+	cloned, err := CloneExprs([]*repository.Expression{&exprs[0]}, clone, CloneOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "device123.temp + device234.offset", cloned[0].Expression)
+	assert.Equal(t, "tenant01", cloned[0].Owner)
+	assert.Equal(t, "device123-clone", cloned[0].EntityID)
+	assert.Contains(t, cloned[0].ID, "/tenant01/device123-clone/")
+
+	cloned, err = CloneExprs([]*repository.Expression{&exprs[0]}, clone, CloneOptions{RebindMappers: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "device123-clone.temp + device234.offset", cloned[0].Expression)
+	// source expressions left alone.
+	assert.Equal(t, "device123.temp + device234.offset", exprs[0].Expression)
+}
+
+func TestClonedBase(t *testing.T) {
+	source := &BaseRet{ID: "device123", Type: "DEVICE", Owner: "admin", Source: "dm",
+		Properties: map[string]interface{}{"temp": 20, "temp_f": 68},
+		Scheme:     map[string]interface{}{"temp": map[string]interface{}{"type": "number"}},
+		Computed:   []string{"temp_f"},
+	}
+
+	en, err := ClonedBase(source, &Base{ID: "device123-clone", Owner: "tenant01"})
+	assert.Nil(t, err)
+	assert.Equal(t, "device123-clone", en.ID)
+	assert.Equal(t, "DEVICE", en.Type)
+	assert.Equal(t, "tenant01", en.Owner)
+	assert.Equal(t, "dm", en.Source)
+	assert.JSONEq(t, `{"temp":20}`, string(en.Properties))
+	assert.JSONEq(t, `{"temp":{"type":"number"}}`, string(en.Scheme))
+}
+
+func Test_stateTenant(t *testing.T) {
+	assert.Equal(t, "tenant01", stateTenant([]byte(`{"owner": "admin", "source": "dm",
+		"properties": {"sysField": {"_tenantId": "tenant01"}}}`)))
+	assert.Equal(t, "admin", stateTenant([]byte(`{"owner": "admin", "source": "dm", "properties": {}}`)))
+	assert.Equal(t, "dm", stateTenant([]byte(`{"owner": "", "source": "dm"}`)))
+	assert.Equal(t, "", stateTenant(nil))
+}
+
+func Test_diffProperties(t *testing.T) {
+	origin := map[string]interface{}{"temp": 20, "metrics": map[string]interface{}{"cpu": 0.3}, "status": "on"}
+	target := map[string]interface{}{"temp": 25, "metrics": map[string]interface{}{"cpu": 0.3}, "mode": "auto"}
+
+	pds, err := diffProperties(origin, target)
+	assert.Nil(t, err)
+	assert.Len(t, pds, 3)
+	for _, pd := range pds {
+		switch pd.Path {
+		case "properties.temp":
+			assert.Equal(t, "replace", pd.Operator)
+			assert.Equal(t, []byte("25"), pd.Value)
+		case "properties.mode":
+			assert.Equal(t, []byte(`"auto"`), pd.Value)
+		case "properties.status":
+			assert.Equal(t, "remove", pd.Operator)
+		default:
+			t.Fatalf("unexpected patch %s", pd.Path)
+		}
+	}
+}
+
+func Test_diffEntity(t *testing.T) {
+	snapshot := &BaseRet{
+		ID:         "device123",
+		Properties: map[string]interface{}{"temp": 20, "status": "on"},
+		Scheme:     map[string]interface{}{"temp": map[string]interface{}{"type": "int"}},
+		Mappers:    []*v1.Mapper{{Name: "m1", Tql: "insert into device123 select device234.temp as temp"}},
+	}
+	current := &BaseRet{
+		ID:         "device123",
+		Properties: map[string]interface{}{"temp": 25, "mode": "auto"},
+		Scheme:     map[string]interface{}{"temp": map[string]interface{}{"type": "int"}},
+		Mappers: []*v1.Mapper{
+			{Name: "m1", Tql: "insert into device123 select device345.temp as temp"},
+			{Name: "m2", Tql: "insert into device123 select device234.mode as mode"},
+		},
+	}
+
+	diff := NewEntityDiff(snapshot, current)
+	assert.Equal(t, KeyDiff{Added: []string{"mode"}, Removed: []string{"status"}, Changed: []string{"temp"}}, diff.Properties)
+	assert.True(t, diff.Configs.Empty())
+	assert.Equal(t, KeyDiff{Added: []string{"m2"}, Changed: []string{"m1"}}, diff.Mappers)
+	assert.False(t, diff.Empty())
+	assert.True(t, NewEntityDiff(current, current).Empty())
+}
+
+type hookFunc func(ctx context.Context, id string, changes map[string]interface{}) error
+
+func (f hookFunc) Validate(ctx context.Context, id string, changes map[string]interface{}) error {
+	return f(ctx, id, changes)
+}
+
+func TestValidationHook(t *testing.T) {
+	m := &apiManager{}
+	pds := []*v1.PatchData{{Path: "properties.temp", Operator: "replace", Value: []byte("120")}}
+	assert.Nil(t, m.validate(context.Background(), "device123", pds))
+
+	var called []string
+	m.AddValidationHook(NewNoopHook(), 0)
+	m.AddValidationHook(hookFunc(func(ctx context.Context, id string, changes map[string]interface{}) error {
+		called = append(called, "range")
+		if changes["properties.temp"].(float64) > 100 {
+			return errors.New("temp out of range")
+		}
+		return nil
+	}), time.Second)
+	m.AddValidationHook(hookFunc(func(ctx context.Context, id string, changes map[string]interface{}) error {
+		called = append(called, "slow")
+		<-ctx.Done()
+		return ctx.Err()
+	}), 10*time.Millisecond)
+
+	err := m.validate(context.Background(), "device123", pds)
+	assert.ErrorIs(t, err, xerrors.ErrPropertyRejected)
+	assert.Contains(t, err.Error(), "temp out of range")
+	assert.Equal(t, []string{"range"}, called)
+
+	pds[0].Value = []byte("20")
+	err = m.validate(context.Background(), "device123", pds)
+	assert.ErrorIs(t, err, xerrors.ErrPropertyRejected)
+	assert.Equal(t, []string{"range", "range", "slow"}, called)
+}
+
+func TestChangeType(t *testing.T) {
+	m := &apiManager{}
+	_, err := m.ChangeType(context.Background(), "device123", "")
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+
+	ret := &BaseRet{ID: "device123", Type: "DEVICE", Properties: map[string]interface{}{
+		"temp": 20, "basicInfo": map[string]interface{}{"name": "light"},
+	}}
+	migrated, err := m.retyped(ret, "SENSOR")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), migrated.SchemaVersion)
+	changes, err := typeChanges(ret, migrated)
+	assert.Nil(t, err)
+	assert.Equal(t, fieldType, changes[0].Path)
+	assert.Equal(t, `"SENSOR"`, string(changes[0].Value))
+	assert.Len(t, changes, 3)
+
+	// properties migrated through the chain of the new type from version 0.
+	m.AddMigration("SENSOR", 0, func(ret *BaseRet) error {
+		ret.Properties["temperature"] = ret.Properties["temp"]
+		delete(ret.Properties, "temp")
+		return nil
+	})
+	migrated, err = m.retyped(ret, "SENSOR")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), migrated.SchemaVersion)
+	assert.Equal(t, 20, ret.Properties["temp"])
+	changes, err = typeChanges(ret, migrated)
+	assert.Nil(t, err)
+	ops := make(map[string]string)
+	for _, change := range changes {
+		ops[change.Path] = change.Operator
+	}
+	assert.Equal(t, map[string]string{
+		fieldType:                xjson.OpReplace.String(),
+		"properties.temp":        xjson.OpRemove.String(),
+		"properties.temperature": xjson.OpReplace.String(),
+		"properties.basicInfo":   xjson.OpReplace.String(),
+	}, ops)
+
+	// properties incompatible with schema of the new type rejected.
+	m.AddValidationHook(hookFunc(func(ctx context.Context, id string, changes map[string]interface{}) error {
+		if changes[fieldType] == "SENSOR" && changes["properties.basicInfo"] != nil {
+			return errors.New("basicInfo not allowed on SENSOR")
+		}
+		return nil
+	}), time.Second)
+	assert.ErrorIs(t, m.validate(context.Background(), "device123", changes), xerrors.ErrPropertyRejected)
+}
+
+func Test_applyTxOps(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"properties":{"temp":20}}`)))
+	assert.Nil(t, repo.PutEntity(ctx, "device234", []byte(`{"properties":{"temp":25}}`)))
+
+	tx := &Tx{}
+	assert.Nil(t, tx.Set(&Base{ID: "device123"}, map[string]interface{}{"temp": 30}))
+	tx.Patch(&Base{ID: "device234"}, []*v1.PatchData{{Path: "properties.temp", Operator: "remove"}})
+	states, err := applyTxOps(ctx, repo, tx.ops)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"properties":{"temp":30}}`, string(states["device123"].state.Raw()))
+	assert.Equal(t, `{"properties":{}}`, string(states["device234"].state.Raw()))
+	assert.Equal(t, []*v1.PatchData{{Path: "properties.temp", Operator: "replace", Value: []byte("30")}},
+		states["device123"].patches())
+	assert.Equal(t, []*v1.PatchData{{Path: "properties.temp", Operator: "remove"}},
+		states["device234"].patches())
+	assert.Equal(t, []string{"device123", "device234"}, tx.entities())
+
+	// appends replayed as the committed values.
+	tx = &Tx{}
+	tx.Patch(&Base{ID: "device123"}, []*v1.PatchData{
+		{Path: "properties.logs", Operator: "add", Value: []byte(`"a"`)},
+		{Path: "properties.logs", Operator: "add", Value: []byte(`"b"`)}})
+	states, err = applyTxOps(ctx, repo, tx.ops)
+	assert.Nil(t, err)
+	assert.Equal(t, []*v1.PatchData{{Path: "properties.logs", Operator: "replace", Value: []byte(`["a","b"]`)}},
+		states["device123"].patches())
+
+	// undeclared properties rejected like runtime patches.
+	assert.Nil(t, repo.PutEntity(ctx, "device345",
+		[]byte(`{"properties":{"temp":20},"scheme":{"additionalProperties":false,"temp":{"type":"int"}}}`)))
+	_, err = applyTxOps(ctx, repo, []txOp{{eid: "device345", pds: []*v1.PatchData{
+		{Path: "properties.humidity", Operator: "replace", Value: []byte("1")}}}})
+	assert.ErrorIs(t, err, xerrors.ErrUnknownProperty)
+
+	// property acls checked for callers carrying an identity.
+	assert.Nil(t, repo.PutEntity(ctx, "device456",
+		[]byte(`{"properties":{"key":"x"},"scheme":{"key":{"type":"string","acl":{"write":["admin"]}}}}`)))
+	userCtx := types.WithIdentity(ctx, types.Identity{Roles: []string{"user"}})
+	_, err = applyTxOps(userCtx, repo, []txOp{{eid: "device456", pds: []*v1.PatchData{
+		{Path: "properties.key", Operator: "replace", Value: []byte(`"y"`)}}}})
+	assert.ErrorIs(t, err, xerrors.ErrForbiddenProperty)
+	_, err = applyTxOps(userCtx, repo, []txOp{{eid: "device456", pds: []*v1.PatchData{
+		{Path: "scheme.key", Operator: "remove"}}}})
+	assert.ErrorIs(t, err, xerrors.ErrPermissionDenied)
+
+	tx = &Tx{}
+
+	tx.Delete(&Base{ID: "device234"})
+	tx.Patch(&Base{ID: "device234"}, []*v1.PatchData{{Path: "properties.temp", Operator: "replace", Value: []byte("1")}})
+	_, err = applyTxOps(ctx, repo, tx.ops)
+	assert.ErrorIs(t, err, xerrors.ErrEntityNotFound)
+
+	_, err = applyTxOps(ctx, repo, []txOp{{eid: "device567"}})
+	assert.NotNil(t, err)
+}
+
+type searchFunc func(ctx context.Context, req *v1.SearchRequest) (*v1.SearchResponse, error)
+
+func (f searchFunc) Index(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error) {
+	return &v1.IndexResponse{}, nil
+}
+
+func (f searchFunc) Search(ctx context.Context, req *v1.SearchRequest) (*v1.SearchResponse, error) {
+	return f(ctx, req)
+}
+
+func (f searchFunc) DeleteByID(ctx context.Context, in *v1.DeleteByIDRequest) (*v1.DeleteByIDResponse, error) {
+	return &v1.DeleteByIDResponse{}, nil
+}
+
+func TestPurgeTombstones(t *testing.T) {
+	m := &apiManager{maintenance: atomic.NewBool(false)}
+	_, err := m.PurgeTombstones(context.Background(), time.Hour)
+	assert.ErrorIs(t, err, xerrors.ErrConnectionNil)
+
+	var conditions []*v1.SearchCondition
+	m.SetSearchClient(searchFunc(func(ctx context.Context, req *v1.SearchRequest) (*v1.SearchResponse, error) {
+		conditions = req.Condition
+		return &v1.SearchResponse{}, nil
+	}))
+	purged, err := m.PurgeTombstones(context.Background(), time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, purged)
+	assert.Equal(t, FieldDeletedAt, conditions[0].Field)
+	assert.True(t, conditions[0].Value.GetNumberValue() < float64(time.Now().Add(-time.Hour).UnixNano()/1e6+1))
+
+	m.SetMaintenanceMode(true)
+	_, err = m.PurgeTombstones(context.Background(), time.Hour)
+	assert.ErrorIs(t, err, xerrors.ErrMaintenanceMode)
+}
+
+func TestFinishDeletes(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	// device123 removed from state, left in search. device234 removed from search, alive in state.
+	repo := &intentRepo{IRepository: repository.New(memDao), intents: []*repository.DeleteIntent{
+		{EntityID: "device123", Owner: "admin"},
+		{EntityID: "device234", Owner: "admin"},
+	}}
+	assert.Nil(t, repo.PutEntity(ctx, "device234", []byte(`{"id":"device234"}`)))
+	m := &apiManager{
+		holder:      holder.New(ctx, 20*time.Millisecond),
+		dispatcher:  mock.NewDispatcher(),
+		entityRepo:  repo,
+		maintenance: atomic.NewBool(false),
+	}
+
+	_, err = m.FinishDeletes(ctx)
+	assert.ErrorIs(t, err, xerrors.ErrConnectionNil)
+
+	var deleted []string
+	m.SetSearchClient(searchDeleteFunc(func(ctx context.Context, in *v1.DeleteByIDRequest) (*v1.DeleteByIDResponse, error) {
+		deleted = append(deleted, in.Id)
+		return &v1.DeleteByIDResponse{}, nil
+	}))
+
+	// runtime never responds, delete of device234 kept for the next run.
+	finished, err := m.FinishDeletes(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, finished)
+	assert.Equal(t, []string{"device123"}, deleted)
+	assert.Len(t, repo.intents, 1)
+	assert.Equal(t, "device234", repo.intents[0].EntityID)
+}
+
+func TestPatchEntities(t *testing.T) {
+	ctx := context.Background()
+	m := &apiManager{
+		holder:      holder.New(ctx, 20*time.Millisecond),
+		dispatcher:  mock.NewDispatcher(),
+		maintenance: atomic.NewBool(false),
+	}
+
+	// malformed patches rejected for all entities.
+	for _, pds := range [][]*v1.PatchData{
+		nil,
+		{{Path: "", Operator: "replace", Value: []byte(`1`)}},
+		{{Path: "properties.temp", Operator: "move", Value: []byte(`1`)}},
+		{{Path: "properties.temp", Operator: "replace", Value: []byte(`{`)}},
+	} {
+		rets, errs := m.PatchEntities(ctx, []string{"device123", "device234"}, pds)
+		assert.Empty(t, rets)
+		assert.Len(t, errs, 2)
+	}
+
+	// runtime never responds, every entity attempted.
+	rets, errs := m.PatchEntities(ctx, []string{"device123", "device234"},
+		[]*v1.PatchData{{Path: "properties.temp", Operator: "replace", Value: []byte(`20`)}, {Path: "properties.hum", Operator: "remove"}})
+	assert.Empty(t, rets)
+	assert.Len(t, errs, 2)
+	assert.NotErrorIs(t, errs["device123"], xerrors.ErrJSONPatchReservedOp)
+
+	// bare numbers are valid values.
+	assert.Nil(t, checkPatches([]*v1.PatchData{{Path: "properties.temp", Operator: "replace", Value: []byte(`20`)}}))
+}
+
+func TestDeleteEntities(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	m := &apiManager{
+		holder:      holder.New(ctx, time.Second),
+		entityRepo:  repository.New(memDao),
+		maintenance: atomic.NewBool(false),
+	}
+	dispatcher := &batchDispatcher{holder: m.holder, size: 3}
+	m.dispatcher = dispatcher
+
+	// runtime responds once the whole batch dispatched, duplicated ids deleted once.
+	errs := m.DeleteEntities(ctx, []string{"device123", "device234", "device404", "device123"}, DeleteOptions{Owner: "admin"})
+	assert.Len(t, dispatcher.events, 3)
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs["device404"], xerrors.ErrEntityNotFound)
+
+	m.SetMaintenanceMode(true)
+	errs = m.DeleteEntities(ctx, []string{"device123", "device234"}, DeleteOptions{})
+	assert.ErrorIs(t, errs["device234"], xerrors.ErrMaintenanceMode)
+}
+
+// batchDispatcher plays the runtime, responding to delete events once size of them dispatched.
+type batchDispatcher struct {
+	holder holder.Holder
+	size   int
+	events []v1.Event
+}
+
+func (d *batchDispatcher) DispatchToLog(ctx context.Context, bytes []byte) error {
+	return nil
+}
+
+func (d *batchDispatcher) Dispatch(ctx context.Context, ev v1.Event) error {
+	if d.events = append(d.events, ev); len(d.events) < d.size {
+		return nil
+	}
+
+	for _, ev := range d.events {
+		resp := &holder.Response{ID: ev.Attr(v1.MetaRequestID), Status: types.StatusOK}
+		if ev.Entity() == "device404" {
+			resp.Status, resp.ErrCode = types.StatusError, xerrors.ErrEntityNotFound.Error()
+		}
+		go d.holder.OnRespond(resp)
+	}
+	return nil
+}
+
+type intentRepo struct {
+	repository.IRepository
+	intents []*repository.DeleteIntent
+}
+
+func (r *intentRepo) ListDeleteIntent(ctx context.Context, rev int64) ([]*repository.DeleteIntent, error) {
+	return append([]*repository.DeleteIntent{}, r.intents...), nil
+}
+
+func (r *intentRepo) DelDeleteIntent(ctx context.Context, intent *repository.DeleteIntent) error {
+	for index := range r.intents {
+		if r.intents[index].EntityID == intent.EntityID {
+			r.intents = append(r.intents[:index], r.intents[index+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestCompactMappers(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := &exprRepo{IRepository: repository.New(memDao), pages: [][]*repository.Expression{
+		{{ID: "expr-1", EntityID: "device123"}, {ID: "expr-2", EntityID: "device123"}, {ID: "expr-3", EntityID: "device234"}},
+		{{ID: "expr-4", EntityID: "device345"}},
+	}}
+	assert.Nil(t, repo.PutEntity(ctx, "device234", []byte(`{"id":"device234"}`)))
+	m := &apiManager{entityRepo: repo, maintenance: atomic.NewBool(false)}
+
+	removed, err := m.CompactMappers(ctx, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, removed)
+	assert.Equal(t, []string{"expr-1", "expr-2", "expr-4"}, repo.deleted)
+	assert.Equal(t, 0, repo.compacted)
+
+	repo.deleted = nil
+	_, err = m.CompactMappers(ctx, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, repo.compacted)
+}
+
+func TestMapperPriority(t *testing.T) {
+	ctx := context.Background()
+	mapperOf := func(name, source string, priority int) []repository.Expression {
+		mp := mapper.Mapper{
+			Name:     name,
+			Owner:    "admin",
+			EntityID: "device123",
+			TQL:      "insert into device123 select " + source + ".temp as temp",
+			Priority: priority,
+		}
+		assert.Nil(t, CheckMapper(&mp))
+		return MapperExprs(mp)
+	}
+
+	low, high := mapperOf("mapper-low", "device234", 0), mapperOf("mapper-high", "device345", 10)
+	assert.Equal(t, low[0].Path, high[0].Path)
+	// mappers writing the same path kept side by side.
+	assert.NotEqual(t, low[0].ID, high[0].ID)
+
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := &exprRepo{IRepository: repository.New(memDao),
+		pages: [][]*repository.Expression{{&low[0], &high[0]}}}
+	m := &apiManager{entityRepo: repo, exprCache: newExprCache(0)}
+	pathOnly := repository.Expression{Owner: "admin", EntityID: "device123", Path: low[0].Path}
+
+	// identified by path, the one in effect returned.
+	expr, err := m.GetExpression(ctx, pathOnly)
+	assert.Nil(t, err)
+	assert.Equal(t, "mapper-high", expr.Name)
+
+	high[0].Disabled = true
+	expr, err = m.GetExpression(ctx, pathOnly)
+	assert.Nil(t, err)
+	assert.Equal(t, "mapper-low", expr.Name)
+
+	// removed by path, expressions of every mapper writing the path removed.
+	pathOnly.GenKey()
+	assert.Nil(t, m.RemoveExpression(ctx, []repository.Expression{pathOnly}))
+	assert.Equal(t, []string{pathOnly.ID, low[0].ID, high[0].ID}, repo.deleted)
+}
+
+func TestListExpressionCached(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := &exprRepo{IRepository: repository.New(memDao), pages: [][]*repository.Expression{
+		{{ID: "expr-1", EntityID: "device123", Owner: "admin"}},
+	}}
+	m := &apiManager{entityRepo: repo, exprCache: newExprCache(time.Minute)}
+	en := &Base{ID: "device123", Owner: "admin"}
+
+	exprs, stale, err := m.ListExpressionCached(ctx, en)
+	assert.Nil(t, err)
+	assert.False(t, stale)
+	assert.Len(t, exprs, 1)
+
+	// etcd unavailable, served from cache.
+	repo.listErr = errors.New("etcdserver: request timed out")
+	exprs[0].Disabled = true
+	exprs, stale, err = m.ListExpressionCached(ctx, en)
+	assert.Nil(t, err)
+	assert.True(t, stale)
+	assert.Equal(t, "expr-1", exprs[0].ID)
+	assert.False(t, exprs[0].Disabled)
+
+	// not cached.
+	_, _, err = m.ListExpressionCached(ctx, &Base{ID: "device234", Owner: "admin"})
+	assert.NotNil(t, err)
+
+	// cache disabled.
+	m.exprCache = newExprCache(0)
+	_, err = m.ListExpression(ctx, en)
+	assert.NotNil(t, err)
+}
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	m := &apiManager{
+		holder:        holder.New(ctx, 20*time.Millisecond),
+		dispatcher:    &absentDispatcher{},
+		entityRepo:    repo,
+		maintenance:   atomic.NewBool(false),
+		snapshotLimit: 2,
+	}
+	m.dispatcher.(*absentDispatcher).holder = m.holder
+
+	// absent entity not snapshotted.
+	assert.Nil(t, m.captureSnapshot(ctx, "device123"))
+
+	// state read from the runtime, the state store lagging.
+	dispatcher := &stateDispatcher{holder: m.holder, state: map[string]interface{}{}}
+	m.dispatcher = dispatcher
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","version":0}`)))
+	for _, version := range []int{1, 2, 3} {
+		dispatcher.version = int64(version)
+		dispatcher.state["properties"] = map[string]interface{}{"temp": version * 10}
+		assert.Nil(t, m.captureSnapshot(ctx, "device123"))
+	}
+	m.dispatcher = mock.NewDispatcher()
+
+	snapshots, err := m.ListSnapshots(ctx, "device123")
+	assert.Nil(t, err)
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, "3", snapshots[0].ID)
+	assert.Equal(t, "2", snapshots[1].ID)
+	temp, _ := snapshots[1].Entity.GetProperty("temp").AsFloat64()
+	assert.Equal(t, float64(20), temp)
+
+	_, err = m.RestoreSnapshot(ctx, "device123", "1")
+	assert.ErrorIs(t, err, xerrors.ErrSnapshotNotFound)
+
+	// runtime never responds.
+	_, err = m.RestoreSnapshot(ctx, "device123", "2")
+	assert.NotNil(t, err)
+}
+
+// absentDispatcher plays the runtime without the entity.
+type absentDispatcher struct {
+	holder holder.Holder
+}
+
+func (d *absentDispatcher) DispatchToLog(ctx context.Context, bytes []byte) error {
+	return nil
+}
+
+func (d *absentDispatcher) Dispatch(ctx context.Context, ev v1.Event) error {
+	go d.holder.OnRespond(&holder.Response{ID: ev.Attr(v1.MetaRequestID),
+		Status: types.StatusError, ErrCode: xerrors.ErrEntityNotFound.Error()})
+	return nil
+}
+
+type exprRepo struct {
+	repository.IRepository
+	pages     [][]*repository.Expression
+	deleted   []string
+	compacted int
+	listErr   error
+}
+
+func (r *exprRepo) ListExpression(ctx context.Context, rev int64, req *repository.ListExprReq) ([]*repository.Expression, error) {
+	if r.listErr != nil {
+		return nil, r.listErr
+	}
+	return r.pages[0], nil
+}
+
+func (r *exprRepo) PageExpression(ctx context.Context, from string, limit int64) ([]*repository.Expression, string, error) {
+	page := 0
+	if from != "" {
+		page, _ = strconv.Atoi(from)
+	}
+
+	next := ""
+	if page+1 < len(r.pages) {
+		next = strconv.Itoa(page + 1)
+	}
+	return r.pages[page], next, nil
+}
+
+func (r *exprRepo) DelExpression(ctx context.Context, expr repository.Expression) error {
+	r.deleted = append(r.deleted, expr.ID)
+	return nil
+}
+
+func (r *exprRepo) Compact(ctx context.Context) error {
+	r.compacted++
+	return nil
+}
+
+type searchDeleteFunc func(ctx context.Context, in *v1.DeleteByIDRequest) (*v1.DeleteByIDResponse, error)
+
+func (f searchDeleteFunc) Index(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error) {
+	return &v1.IndexResponse{}, nil
+}
+
+func (f searchDeleteFunc) Search(ctx context.Context, req *v1.SearchRequest) (*v1.SearchResponse, error) {
+	return &v1.SearchResponse{}, nil
+}
+
+func (f searchDeleteFunc) DeleteByID(ctx context.Context, in *v1.DeleteByIDRequest) (*v1.DeleteByIDResponse, error) {
+	return f(ctx, in)
+}
+
+type searchIndexFunc func(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error)
+
+func (f searchIndexFunc) Index(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error) {
+	return f(ctx, in)
+}
+
+func (f searchIndexFunc) Search(ctx context.Context, req *v1.SearchRequest) (*v1.SearchResponse, error) {
+	return &v1.SearchResponse{}, nil
+}
+
+func (f searchIndexFunc) DeleteByID(ctx context.Context, in *v1.DeleteByIDRequest) (*v1.DeleteByIDResponse, error) {
+	return &v1.DeleteByIDResponse{}, nil
+}
+
+func Test_indexEntity(t *testing.T) {
+	state := []byte(`{"id":"device123","type":"DEVICE","owner":"admin","source":"dm","version":3,
+		"deleted_at":1649824132030,"properties":{"basicInfo":{"name":"light"},"online":true,"telemetry":{"temp":20}}}`)
+
+	m := &apiManager{searchModel: []string{"properties.basicInfo.name"}}
+	assert.Nil(t, m.indexEntity(context.Background(), "device123", state))
+
+	var indexed map[string]interface{}
+	m.searchClient = searchIndexFunc(func(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error) {
+		indexed, _ = in.Obj.AsInterface().(map[string]interface{})
+		return &v1.IndexResponse{Status: "SUCCESS"}, nil
+	})
+	assert.Nil(t, m.indexEntity(context.Background(), "device123", state))
+	// the document runtime flushes index.
+	en, err := runtime.NewEntity("device123", state)
+	assert.Nil(t, err)
+	doc, err := runtime.MakeSearchDocument(en, m.searchModel, nil)
+	assert.Nil(t, err)
+	var expected map[string]interface{}
+	assert.Nil(t, json.Unmarshal(doc, &expected))
+	assert.Equal(t, expected, indexed)
+	assert.Equal(t, float64(1649824132030), indexed["deleted_at"])
+	assert.Equal(t, true, indexed["online"])
+	assert.Equal(t, "light", indexed[runtime.FieldKeyWords])
+	assert.Nil(t, indexed["telemetry"])
+
+	m.searchClient = searchIndexFunc(func(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error) {
+		return nil, xerrors.ErrInternal
+	})
+	assert.ErrorIs(t, m.indexEntity(context.Background(), "device123", state), xerrors.ErrInternal)
+}
+
+func Test_indexCreated(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := &indexIntentRepo{IRepository: repository.New(memDao)}
+	m := &apiManager{
+		holder:      holder.New(ctx, 20*time.Millisecond),
+		dispatcher:  mock.NewDispatcher(),
+		entityRepo:  repo,
+		maintenance: atomic.NewBool(false),
+	}
+	ret := &BaseRet{ID: "device123", Owner: "admin"}
+	state := []byte(`{"id":"device123","owner":"admin"}`)
+
+	// search unavailable, create succeeds, indexed later.
+	assert.Nil(t, m.indexCreated(ctx, ret, state))
+	assert.Len(t, repo.intents, 1)
+
+	indexed := 0
+	m.SetSearchClient(searchIndexFunc(func(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error) {
+		indexed++
+		return &v1.IndexResponse{}, nil
+	}))
+	assert.Nil(t, m.indexCreated(ctx, ret, state))
+	assert.Len(t, repo.intents, 1)
+
+	// entity deleted since dropped, entity alive indexed.
+	repo.intents = append(repo.intents, &repository.IndexIntent{EntityID: "device234", Owner: "admin"})
+	assert.Nil(t, repo.PutEntity(ctx, "device234", []byte(`{"id":"device234","owner":"admin"}`)))
+	finished, err := m.FinishIndexes(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, finished)
+	assert.Equal(t, 2, indexed)
+	assert.Empty(t, repo.intents)
+
+	// fail policy, runtime never responds to the delete, entity left for indexing later.
+	m.failOnIndex = true
+	m.SetSearchClient(searchIndexFunc(func(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error) {
+		return nil, errors.New("search unavailable")
+	}))
+	assert.NotNil(t, m.indexCreated(ctx, ret, state))
+	assert.Len(t, repo.intents, 1)
+}
+
+type indexIntentRepo struct {
+	repository.IRepository
+	intents []*repository.IndexIntent
+}
+
+func (r *indexIntentRepo) PutIndexIntent(ctx context.Context, intent *repository.IndexIntent) error {
+	r.intents = append(r.intents, intent)
+	return nil
+}
+
+func (r *indexIntentRepo) DelIndexIntent(ctx context.Context, intent *repository.IndexIntent) error {
+	for index := range r.intents {
+		if r.intents[index].EntityID == intent.EntityID {
+			r.intents = append(r.intents[:index], r.intents[index+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *indexIntentRepo) ListIndexIntent(ctx context.Context, rev int64) ([]*repository.IndexIntent, error) {
+	return append([]*repository.IndexIntent{}, r.intents...), nil
+}
+
+type searchAggregateFunc func(ctx context.Context, req *v1.SearchRequest, aggs []driver.Aggregation) (*driver.AggregationResult, error)
+
+func (f searchAggregateFunc) Aggregate(ctx context.Context, req *v1.SearchRequest, aggs []driver.Aggregation) (*driver.AggregationResult, error) {
+	return f(ctx, req, aggs)
+}
+
+func (f searchAggregateFunc) Index(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error) {
+	return &v1.IndexResponse{}, nil
+}
+
+func (f searchAggregateFunc) Search(ctx context.Context, req *v1.SearchRequest) (*v1.SearchResponse, error) {
+	return &v1.SearchResponse{}, nil
+}
+
+func (f searchAggregateFunc) DeleteByID(ctx context.Context, in *v1.DeleteByIDRequest) (*v1.DeleteByIDResponse, error) {
+	return &v1.DeleteByIDResponse{}, nil
+}
+
+func TestListTypes(t *testing.T) {
+	m := &apiManager{}
+	_, err := m.ListTypes(context.Background(), "admin")
+	assert.ErrorIs(t, err, xerrors.ErrConnectionNil)
+	_, err = m.ListTypes(context.Background(), "")
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+
+	calls := 0
+	m.AddMigration("GATEWAY", 0, func(*BaseRet) error { return nil })
+	m.AddMigration("DEVICE", 0, func(*BaseRet) error { return nil })
+	m.SetSearchClient(searchAggregateFunc(func(ctx context.Context, req *v1.SearchRequest, aggs []driver.Aggregation) (*driver.AggregationResult, error) {
+		calls++
+		assert.Equal(t, "owner", req.Condition[0].Field)
+		assert.Equal(t, "admin", req.Condition[0].Value.GetStringValue())
+		assert.Equal(t, []driver.Aggregation{{Name: "type", Kind: driver.AggregationTerms, Field: "type", Size: maxListTypes}}, aggs)
+		return &driver.AggregationResult{Total: 5, Aggregations: map[string]*driver.AggregationValue{
+			"type": {Buckets: []*driver.Bucket{{Key: "DEVICE", Count: 3}, {Key: "SPACE", Count: 2}}},
+		}}, nil
+	}))
+
+	types, err := m.ListTypes(context.Background(), "admin")
+	assert.Nil(t, err)
+	assert.Equal(t, []TypeInfo{{Type: "DEVICE", Count: 3}, {Type: "GATEWAY"}, {Type: "SPACE", Count: 2}}, types)
+
+	// cached per owner.
+	_, err = m.ListTypes(context.Background(), "admin")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestReindex(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	m := &apiManager{entityRepo: repo}
+
+	progress := make(chan ReindexStats, 10)
+	_, err = m.Reindex(ctx, []string{"device123"}, ReindexOptions{Progress: progress})
+	assert.ErrorIs(t, err, xerrors.ErrConnectionNil)
+	_, ok := <-progress
+	assert.False(t, ok)
+
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","type":"DEVICE"}`)))
+	assert.Nil(t, repo.PutEntity(ctx, "device234", []byte(`{"id":"device234","type":"DEVICE","deleted_at":1649824132030}`)))
+	var indexed []string
+	docs := make(map[string]map[string]interface{})
+	m.searchClient = searchIndexFunc(func(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error) {
+		doc, _ := in.Obj.AsInterface().(map[string]interface{})
+		indexed = append(indexed, doc["id"].(string))
+		docs[doc["id"].(string)] = doc
+		return &v1.IndexResponse{}, nil
+	})
+
+	progress = make(chan ReindexStats, 10)
+	stats, err := m.Reindex(ctx, []string{"device123", "device404", "device234"},
+		ReindexOptions{Progress: progress, Interval: time.Nanosecond})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"device123", "device234"}, indexed)
+	// tombstone kept, soft-deleted entity stays hidden from listing and purgeable.
+	assert.Equal(t, float64(1649824132030), docs["device234"][FieldDeletedAt])
+	assert.Nil(t, docs["device123"][FieldDeletedAt])
+	assert.Equal(t, 3, stats.Total)
+	assert.Equal(t, 3, stats.Processed)
+	assert.Equal(t, 1, stats.Failed)
+	assert.Equal(t, time.Duration(0), stats.ETA)
+
+	var reports []ReindexStats
+	for report := range progress {
+		reports = append(reports, report)
+	}
+	assert.Len(t, reports, 4)
+	assert.Equal(t, 1, reports[0].Processed)
+	assert.Equal(t, stats.Processed, reports[3].Processed)
+}
+
+func Test_entitiesFrom(t *testing.T) {
+	item := func(kv map[string]interface{}) *structpb.Value {
+		val, _ := structpb.NewValue(kv)
+		return val
+	}
+
+	seen := map[string]struct{}{"device234": {}}
+	resp := &v1.SearchResponse{Items: []*structpb.Value{
+		item(map[string]interface{}{"id": "device123", "owner": "admin"}),
+		item(map[string]interface{}{"id": "device234", "owner": "admin"}),
+		item(map[string]interface{}{"owner": "admin"}),
+		structpb.NewStringValue("device345"),
+	}}
+	assert.Equal(t, []*Base{{ID: "device123", Owner: "admin"}}, entitiesFrom(resp, seen))
+	assert.Nil(t, entitiesFrom(resp, seen))
+}
+
+func Test_expiredPaths(t *testing.T) {
+	now := time.Now()
+	written := func(ago time.Duration) map[string]interface{} {
+		return map[string]interface{}{"_writer": "user:admin", "_ts": float64(now.Add(-ago).UnixNano() / 1e6)}
+	}
+
+	base := &BaseRet{
+		ID: "device123",
+		Scheme: map[string]interface{}{
+			"temp":  map[string]interface{}{"type": "float", "define": map[string]interface{}{"ttl": 60}},
+			"state": map[string]interface{}{"type": "string", "define": map[string]interface{}{"ttl": "10m"}},
+			"metrics": map[string]interface{}{"type": "struct", "define": map[string]interface{}{
+				"fields": map[string]interface{}{
+					"cpu": map[string]interface{}{"type": "float", "define": map[string]interface{}{"ttl": 30}},
+				}}},
+			"mode": map[string]interface{}{"type": "string", "define": map[string]interface{}{"ttl": 1}},
+		},
+		Properties: map[string]interface{}{"temp": 20.5, "state": "on", "metrics": map[string]interface{}{"cpu": 0.5}},
+		Writers: map[string]interface{}{"properties": map[string]interface{}{
+			"temp":    written(2 * time.Minute),
+			"state":   written(2 * time.Minute),
+			"metrics": map[string]interface{}{"cpu": written(time.Minute)},
+			"mode":    written(time.Minute),
+		}},
+	}
+
+	paths := expiredPaths(base, now)
+	sort.Strings(paths)
+	// mode absent, state not yet expired.
+	assert.Equal(t, []string{"metrics.cpu", "temp"}, paths)
+}
+
+func TestGetOrCreateEntity(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	m := &apiManager{
+		holder:             holder.New(ctx, time.Minute),
+		dispatcher:         mock.NewDispatcher(),
+		entityRepo:         repository.New(memDao),
+		maintenance:        atomic.NewBool(false),
+		materializeTimeout: 20 * time.Millisecond,
+	}
+
+	// runtime never responds, absent entity created pending.
+	ret, created, err := m.GetOrCreateEntity(ctx, &Base{ID: "device123", Owner: "admin"})
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, CreationPending, ret.Status)
+	assert.Empty(t, m.entityLocks)
+}
+
+func TestLockEntity(t *testing.T) {
+	m := &apiManager{}
+	unlock := m.lockEntity("device123")
+	locked := make(chan struct{})
+	go func() {
+		defer close(locked)
+		m.lockEntity("device123")()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("entity locked twice")
+	case <-time.After(20 * time.Millisecond):
+	}
+	m.lockEntity("device234")()
+
+	unlock()
+	<-locked
+	assert.Empty(t, m.entityLocks)
+}
+
+func TestTagByQuery(t *testing.T) {
+	m := &apiManager{maintenance: atomic.NewBool(false), tagInterval: time.Millisecond}
+	_, err := m.TagByQuery(context.Background(), &v1.SearchRequest{}, map[string]string{"fleet": "north"})
+	assert.ErrorIs(t, err, xerrors.ErrConnectionNil)
+
+	item := func(id string) *structpb.Value {
+		val, _ := structpb.NewValue(map[string]interface{}{"id": id, "tags": map[string]interface{}{"fleet": "north"}})
+		return val
+	}
+
+	var pages []int32
+	m.SetSearchClient(searchFunc(func(ctx context.Context, req *v1.SearchRequest) (*v1.SearchResponse, error) {
+		pages = append(pages, req.PageNum)
+		if req.PageNum == 1 {
+			return &v1.SearchResponse{Items: []*structpb.Value{item("device123"), item("device234")}}, nil
+		}
+		return &v1.SearchResponse{Items: []*structpb.Value{item("device345")}}, nil
+	}))
+
+	_, err = m.TagByQuery(context.Background(), &v1.SearchRequest{}, map[string]string{"fleet.name": "north"})
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+
+	// entities tagged already, resume from page 1.
+	updated, err := m.TagByQuery(context.Background(), &v1.SearchRequest{PageSize: 2}, map[string]string{"fleet": "north"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, updated)
+	assert.Equal(t, []int32{1, 2}, pages)
+}
+
+func TestSetConfigsByType(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	m := &apiManager{entityRepo: repo, maintenance: atomic.NewBool(false)}
+	configs := map[string]interface{}{"interval": map[string]interface{}{"id": "interval", "type": "int", "define": map[string]interface{}{"default": 5}}}
+
+	_, err = m.SetConfigsByType(ctx, "sensor", configs)
+	assert.ErrorIs(t, err, xerrors.ErrConnectionNil)
+
+	item := func(id string) *structpb.Value {
+		val, _ := structpb.NewValue(map[string]interface{}{"id": id, "type": "sensor"})
+		return val
+	}
+
+	var pages []int32
+	m.SetSearchClient(searchFunc(func(ctx context.Context, req *v1.SearchRequest) (*v1.SearchResponse, error) {
+		assert.Equal(t, "sensor", req.Condition[0].Value.GetStringValue())
+		pages = append(pages, req.PageNum)
+		if req.PageNum == 1 {
+			items := make([]*structpb.Value, 0, configsPageSize)
+			for i := 0; i < configsPageSize; i++ {
+				items = append(items, item(fmt.Sprintf("device%d", i)))
+			}
+			return &v1.SearchResponse{Items: items}, nil
+		}
+		return &v1.SearchResponse{Items: []*structpb.Value{item("device404")}}, nil
+	}))
+
+	_, err = m.SetConfigsByType(ctx, "", configs)
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+
+	// entities carrying the configs skipped, missing entity not stop the run.
+	for i := 0; i < configsPageSize; i++ {
+		id := fmt.Sprintf("device%d", i)
+		state := fmt.Sprintf(`{"id":"%s","type":"sensor","scheme":{"interval":{"id":"interval","type":"int","define":{"default":5}}}}`, id)
+		assert.Nil(t, repo.PutEntity(ctx, id, []byte(state)))
+	}
+	updated, err := m.SetConfigsByType(ctx, "sensor", configs)
+	assert.True(t, xerrors.IsEntityNotFound(err))
+	assert.Contains(t, err.Error(), "1 entities failed")
+	assert.Equal(t, 0, updated)
+	assert.Equal(t, []int32{1, 2}, pages)
+}
+
+func Test_hasConfigs(t *testing.T) {
+	scheme := map[string]interface{}{"interval": map[string]interface{}{"type": "int"}, "unit": "s"}
+	assert.True(t, hasConfigs(scheme, map[string]interface{}{"unit": "s"}))
+	assert.False(t, hasConfigs(scheme, map[string]interface{}{"unit": "ms"}))
+	assert.False(t, hasConfigs(scheme, map[string]interface{}{"mode": "auto"}))
+	assert.False(t, hasConfigs(nil, map[string]interface{}{"unit": "s"}))
+}
+
+func Test_tagPatches(t *testing.T) {
+	item := map[string]interface{}{"id": "device123", "tags": map[string]interface{}{"fleet": "north"}}
+	assert.Empty(t, tagPatches(item, map[string]string{"fleet": "north"}))
+
+	pds := tagPatches(item, map[string]string{"fleet": "south"})
+	assert.Len(t, pds, 1)
+	assert.Equal(t, "properties.tags.fleet", pds[0].Path)
+	assert.Equal(t, "replace", pds[0].Operator)
+	assert.Equal(t, `"south"`, string(pds[0].Value))
+
+	assert.Len(t, tagPatches(map[string]interface{}{"id": "device234"}, map[string]string{"fleet": "north", "zone": "a"}), 2)
+}
+
+func TestWatchEntity(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	m := &apiManager{entityRepo: repo}
+
+	_, err = m.WatchEntity(ctx, "device404", WatchOptions{})
+	assert.ErrorIs(t, err, xerrors.ErrResourceNotFound)
+
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","version":1,"properties":{"temp":20}}`)))
+	ch, err := m.WatchEntity(ctx, "device123", WatchOptions{Snapshot: true, Interval: 5 * time.Millisecond})
+	assert.Nil(t, err)
+
+	// snapshot first, then changes with greater version.
+	assert.Equal(t, int64(1), (<-ch).Version)
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","version":2,"properties":{"temp":25}}`)))
+	baseRet := <-ch
+	assert.Equal(t, int64(2), baseRet.Version)
+	assert.Equal(t, float64(25), baseRet.Properties["temp"])
+
+	assert.Nil(t, repo.DelEntity(ctx, "device123"))
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func Test_checkParams_idPrefix(t *testing.T) {
+	m := &apiManager{idPrefixes: map[string]string{"SENSOR": "sensor-"}}
+
+	en := &Base{Type: "SENSOR"}
+	assert.Nil(t, m.checkParams(context.Background(), en))
+	assert.True(t, strings.HasPrefix(en.ID, "sensor-"))
+	assert.Len(t, en.ID, len("sensor-")+36)
+
+	assert.Nil(t, m.checkParams(context.Background(), &Base{ID: "sensor-123", Type: "SENSOR"}))
+	assert.ErrorIs(t, m.checkParams(context.Background(), &Base{ID: "device123", Type: "SENSOR"}), xerrors.ErrInvalidEntityParams)
+
+	// unprefixed by default.
+	en = &Base{Type: "DEVICE"}
+	assert.Nil(t, m.checkParams(context.Background(), en))
+	assert.True(t, strings.HasPrefix(en.ID, "en-"))
+	assert.Nil(t, m.checkParams(context.Background(), &Base{ID: "device123", Type: "DEVICE"}))
+}
+
+func Test_checkParams_idCharset(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.EntityIDConfig
+		id     string
+		expect error
+	}{
+		{"default", config.EntityIDConfig{}, "iotd-123_a.b:c@d", nil},
+		{"slash", config.EntityIDConfig{}, "device/123", xerrors.ErrInvalidEntityID},
+		{"space", config.EntityIDConfig{}, "device 123", xerrors.ErrInvalidEntityID},
+		{"too long", config.EntityIDConfig{MaxLength: 8}, "device123", xerrors.ErrInvalidEntityID},
+		{"custom pattern", config.EntityIDConfig{Pattern: `^[a-z0-9]+$`}, "Device123", xerrors.ErrInvalidEntityID},
+		{"bad pattern fallback", config.EntityIDConfig{Pattern: `[`}, "device123", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &apiManager{idValidator: idValidatorFrom(tt.cfg)}
+			err := m.checkParams(context.Background(), &Base{ID: tt.id})
+			if tt.expect == nil {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.expect)
+		})
+	}
+}
+
+func Test_checkType(t *testing.T) {
+	m := &apiManager{}
+	en := &Base{ID: "device123"}
+	assert.Nil(t, m.checkType(en))
+	assert.Equal(t, "", en.Type)
+
+	m.defaultType = "BASIC"
+	assert.Nil(t, m.checkType(en))
+	assert.Equal(t, "BASIC", en.Type)
+	en = &Base{ID: "device123", Type: "DEVICE"}
+	assert.Nil(t, m.checkType(en))
+	assert.Equal(t, "DEVICE", en.Type)
+
+	m.strictType = true
+	assert.Nil(t, m.checkType(en))
+	assert.ErrorIs(t, m.checkType(&Base{ID: "device234"}), xerrors.ErrMissingType)
+}
+
+func TestQueryFields(t *testing.T) {
+	m := &apiManager{queryFieldsLimit: 3}
+	_, err := m.QueryFields(context.Background(), &v1.SearchRequest{}, []string{"properties.temp"})
+	assert.ErrorIs(t, err, xerrors.ErrConnectionNil)
+
+	item := func(id string) *structpb.Value {
+		val, _ := structpb.NewValue(map[string]interface{}{"id": id, "temp": 20})
+		return val
+	}
+
+	var pages []int32
+	m.SetSearchClient(searchFunc(func(ctx context.Context, req *v1.SearchRequest) (*v1.SearchResponse, error) {
+		pages = append(pages, req.PageNum)
+		size := req.PageSize
+		if req.PageNum > 1 {
+			size = 1
+		}
+		items := make([]*structpb.Value, size)
+		for i := range items {
+			items[i] = item(fmt.Sprintf("device%d", int(req.PageNum)*1000+i))
+		}
+		return &v1.SearchResponse{Items: items}, nil
+	}))
+
+	_, err = m.QueryFields(context.Background(), &v1.SearchRequest{}, nil)
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+
+	// capped at the limit, query left untouched.
+	query := &v1.SearchRequest{PageNum: 5, PageSize: 10}
+	items, err := m.QueryFields(context.Background(), query, []string{"properties.temp"})
+	assert.Nil(t, err)
+	assert.Len(t, items, 3)
+	assert.Equal(t, "device1000", items[0]["id"])
+	assert.Equal(t, []int32{1}, pages)
+	assert.Equal(t, int32(5), query.PageNum)
+
+	// pages through all matches.
+	pages = nil
+	m.queryFieldsLimit = 0
+	items, err = m.QueryFields(context.Background(), query, []string{"properties.temp"})
+	assert.Nil(t, err)
+	assert.Len(t, items, queryPageSize+1)
+	assert.Equal(t, []int32{1, 2}, pages)
+}
+
+func TestMaterialize(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	m := &apiManager{
+		holder:             holder.New(ctx, time.Minute),
+		dispatcher:         mock.NewDispatcher(),
+		entityRepo:         repo,
+		materializeTimeout: 20 * time.Millisecond,
+	}
+
+	err = m.Materialize(ctx, "device123")
+	assert.ErrorIs(t, err, xerrors.ErrEntityNotFound)
+
+	// runtime never confirms.
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","version":1}`)))
+	err = m.Materialize(ctx, "device123")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+type dumpRepo struct {
+	repository.IRepository
+}
+
+func (r *dumpRepo) RawExpressions(ctx context.Context, owner, entityID string) (map[string][]byte, error) {
+	key := repository.ListExpressionPrefix(owner, entityID) + "/temp"
+	return map[string][]byte{key: []byte(`{"ID":"temp"}`)}, nil
+}
+
+func TestDumpEntity(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := &dumpRepo{IRepository: repository.New(memDao)}
+	m := &apiManager{entityRepo: repo}
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","owner":"admin","version":1}`)))
+	assert.Nil(t, repo.PutEntity(ctx, "device234", []byte(`{"id":"device234","owner":"admin","properties":"bad"}`)))
+	assert.Nil(t, repo.PutEntity(ctx, "device345", []byte(`not json`)))
+	assert.Nil(t, repo.PutEntity(ctx, "device456", []byte(`{"id":"device456","owner":"tenant02","version":1}`)))
+
+	_, err = m.DumpEntity(ctx, "device123")
+	assert.ErrorIs(t, err, xerrors.ErrPermissionDenied)
+
+	// admins of other tenants denied.
+	_, err = m.DumpEntity(types.WithIdentity(ctx, types.Identity{User: "tom", Tenant: "tenant02",
+		Roles: []string{types.RoleAdmin}}), "device123")
+	assert.ErrorIs(t, err, xerrors.ErrPermissionDenied)
+
+	ctx = types.WithIdentity(ctx, types.Identity{User: "admin", Roles: []string{types.RoleAdmin}})
+	_, err = m.DumpEntity(ctx, "device456")
+	assert.ErrorIs(t, err, xerrors.ErrPermissionDenied)
+	dump, err := m.DumpEntity(ctx, "device123")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"device123","owner":"admin","version":1}`, string(dump.Raw))
+	assert.NotEmpty(t, dump.ETag)
+	assert.Equal(t, int64(1), dump.Base.Version)
+	assert.Equal(t, map[string]string{"/core/v1/expressions/admin/device123/temp": `{"ID":"temp"}`}, dump.Mappers)
+
+	// undecodable states dumped raw.
+	dump, err = m.DumpEntity(ctx, "device234")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"device234","owner":"admin","properties":"bad"}`, string(dump.Raw))
+	assert.Nil(t, dump.Base)
+	assert.Contains(t, dump.DecodeError, xerrors.ErrUnknownEncoding.Error())
+	assert.Empty(t, dump.Mappers)
+
+	// owner unknown.
+	_, err = m.DumpEntity(ctx, "device345")
+	assert.ErrorIs(t, err, xerrors.ErrPermissionDenied)
+}
+
+func TestCheckMapperType(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	m := &apiManager{entityRepo: repo}
+
+	// untyped mapper attaches anywhere.
+	assert.Nil(t, m.checkMapperType(ctx, &mapper.Mapper{ID: "mapper123", EntityID: "device404"}))
+
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","type":"actuator"}`)))
+	err = m.checkMapperType(ctx, &mapper.Mapper{ID: "mapper123", EntityID: "device123", AppliesToTypes: []string{"sensor"}})
+	assert.ErrorIs(t, err, xerrors.ErrMapperTypeMismatch)
+	assert.Nil(t, m.checkMapperType(ctx, &mapper.Mapper{ID: "mapper123", EntityID: "device123", AppliesToTypes: []string{"sensor", "actuator"}}))
+}
+
+func TestAppendMappers(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	m := &apiManager{entityRepo: repo, maintenance: atomic.NewBool(false)}
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","type":"actuator"}`)))
+
+	mappers := func() []*mapper.Mapper {
+		return []*mapper.Mapper{
+			{ID: "m1", Name: "m1", EntityID: "device123", TQL: "insert into device123 select device234.temp as temp"},
+			{ID: "m2", Name: "m2", EntityID: "device123", TQL: ""},
+			{ID: "m3", Name: "m3", EntityID: "device123", TQL: "insert into device123 select device234.cpu as cpu",
+				AppliesToTypes: []string{"sensor"}},
+		}
+	}
+
+	results, err := m.AppendMappers(ctx, mappers(), AppendBestEffort)
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+	assert.Nil(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, xerrors.ErrInvalidRequest)
+	assert.ErrorIs(t, results[2].Err, xerrors.ErrMapperTypeMismatch)
+
+	results, err = m.AppendMappers(ctx, mappers(), AppendAllOrNothing)
+	assert.ErrorIs(t, err, xerrors.ErrBatchAborted)
+	assert.ErrorIs(t, results[0].Err, xerrors.ErrBatchAborted)
+	assert.ErrorIs(t, results[1].Err, xerrors.ErrInvalidRequest)
+
+	results, err = m.AppendMappers(ctx, mappers()[:1], AppendAllOrNothing)
+	assert.Nil(t, err)
+	assert.Equal(t, "m1", results[0].ID)
+	assert.Nil(t, results[0].Err)
+}
+
+func TestEntitiesExist(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	m := &apiManager{entityRepo: repo}
+
+	exists, err := m.EntitiesExist(ctx, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, exists)
+
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123"}`)))
+	exists, err = m.EntitiesExist(ctx, []string{"device123", "device404"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"device123": true, "device404": false}, exists)
+}
+
+func TestGetEntity_ReadReplica(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	m := &apiManager{entityRepo: repo}
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","type":"DEVICE","version":3,"properties":{"temp":20}}`)))
+
+	assert.Equal(t, ReadPrimary, readPreferenceFrom(ctx))
+	ctx = WithReadPreference(ctx, ReadReplica)
+	ret, err := m.GetEntity(ctx, &Base{ID: "device123"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), ret.Version)
+	assert.Equal(t, float64(20), ret.Properties["temp"])
+
+	_, err = m.GetEntity(ctx, &Base{ID: "device234"})
+	assert.NotNil(t, err)
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	m := &apiManager{entityRepo: repo}
+
+	// v0 -> v1 rename temp to temperature, v1 -> v2 convert celsius to fahrenheit.
+	m.AddMigration("sensor", 0, func(ret *BaseRet) error {
+		ret.Properties["temperature"] = ret.Properties["temp"]
+		delete(ret.Properties, "temp")
+		return nil
+	})
+	m.AddMigration("sensor", 1, func(ret *BaseRet) error {
+		ret.Properties["temperature"] = ret.Properties["temperature"].(float64)*9/5 + 32
+		return nil
+	})
+	assert.Equal(t, int64(2), m.schemaVersion("sensor"))
+	assert.Equal(t, int64(0), m.schemaVersion("actuator"))
+
+	tests := []struct {
+		name   string
+		state  string
+		expect map[string]interface{}
+	}{
+		{"from v0", `{"id":"device123","type":"sensor","properties":{"temp":20}}`, map[string]interface{}{"temperature": float64(68)}},
+		{"from v1", `{"id":"device123","type":"sensor","schema_version":1,"properties":{"temperature":100}}`, map[string]interface{}{"temperature": float64(212)}},
+		{"current", `{"id":"device123","type":"sensor","schema_version":2,"properties":{"temperature":20}}`, map[string]interface{}{"temperature": float64(20)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(tt.state)))
+			ret, err := m.loadEntity(ctx, "device123")
+			assert.Nil(t, err)
+			assert.Equal(t, int64(2), ret.SchemaVersion)
+			assert.Equal(t, tt.expect, ret.Properties)
+		})
+	}
+
+	// failing migration surfaces error.
+	m.AddMigration("actuator", 0, func(*BaseRet) error { return errors.New("bad layout") })
+	assert.Nil(t, repo.PutEntity(ctx, "device234", []byte(`{"id":"device234","type":"actuator"}`)))
+	_, err = m.loadEntity(ctx, "device234")
+	assert.NotNil(t, err)
+}
+
+func TestLockEntityWrite(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	// managers of two core instances share the lock store.
+	repo := repository.New(memDao)
+	lockCfg := config.EntityLockConfig{Enabled: true, Timeout: 1}
+	m1 := &apiManager{entityRepo: repo, writeLock: lockCfg, maintenance: atomic.NewBool(false)}
+	m2 := &apiManager{entityRepo: repo, writeLock: lockCfg, maintenance: atomic.NewBool(false)}
+
+	unlock, err := m1.lockEntityWrite(ctx, "device123")
+	assert.Nil(t, err)
+
+	_, _, err = m2.PatchEntity(ctx, &Base{ID: "device123"}, []*v1.PatchData{{
+		Path:     "properties.temp",
+		Operator: xjson.OpReplace.String(),
+		Value:    []byte("20"),
+	}})
+	assert.ErrorIs(t, err, xerrors.ErrLockTimeout)
+
+	// other entities not blocked.
+	unlock2, err := m2.lockEntityWrite(ctx, "device234")
+	assert.Nil(t, err)
+	unlock2()
+
+	unlock()
+	unlock, err = m2.lockEntityWrite(ctx, "device123")
+	assert.Nil(t, err)
+	unlock()
+
+	// disabled lock never blocks.
+	m3 := &apiManager{entityRepo: repo}
+	unlock, err = m3.lockEntityWrite(ctx, "device123")
+	assert.Nil(t, err)
+	unlock()
+}
+
+type pageExprRepo struct {
+	repository.IRepository
+	from  string
+	limit int64
+}
+
+func (r *pageExprRepo) PageExpression(ctx context.Context, from string, limit int64) ([]*repository.Expression, string, error) {
+	r.from, r.limit = from, limit
+	return []*repository.Expression{
+		repository.NewExpression("admin", "device123", "mapper1", "temp", " device234.temp", ""),
+		repository.NewExpression("admin", "device123", "mapper1", "cpu", "device234.cpu", ""),
+		repository.NewExpression("admin", "device123", "mapper2", "mem", "device345.mem", ""),
+	}, repository.ExprPrefix + "/admin/device1230", nil
+}
+
+func TestListAllMappers(t *testing.T) {
+	repo := &pageExprRepo{}
+	m := &apiManager{entityRepo: repo}
+
+	_, _, err := m.ListAllMappers(context.Background(), "bad token", 10)
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+
+	mappers, next, err := m.ListAllMappers(context.Background(), "", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(defaultMapperPageLimit), repo.limit)
+	assert.Equal(t, []MapperRef{
+		{EntityID: "device123", Owner: "admin", Name: "mapper1", TQL: "insert into device123 select device234.temp as temp, device234.cpu as cpu"},
+		{EntityID: "device123", Owner: "admin", Name: "mapper2", TQL: "insert into device123 select device345.mem as mem"},
+	}, mappers)
+
+	// continue from the token.
+	_, _, err = m.ListAllMappers(context.Background(), next, 5000)
+	assert.Nil(t, err)
+	assert.Equal(t, repository.ExprPrefix+"/admin/device1230", repo.from)
+	assert.Equal(t, int64(maxMapperPageLimit), repo.limit)
+}
+
+func Test_movePatches(t *testing.T) {
+	base := &BaseRet{ID: "device123", Owner: "admin", Properties: map[string]interface{}{}}
+	assert.Equal(t, "admin", tenantOf(base))
+	base.Properties["sysField"] = map[string]interface{}{"_tenantId": "tenant1"}
+	assert.Equal(t, "tenant1", tenantOf(base))
+
+	pds, err := movePatches(&MovedFrom{Owner: "admin", Tenant: "tenant1"}, "tenant2")
+	assert.Nil(t, err)
+	assert.Len(t, pds, 3)
+	assert.Equal(t, "properties.sysField._tenantId", pds[1].Path)
+	assert.Equal(t, `"tenant2"`, string(pds[1].Value))
+
+	// moved from recorded in state until the move finished.
+	moved, err := DecodeBase([]byte(`{"id":"device123","owner":"tenant2","moved_from":` + string(pds[2].Value) + `}`))
+	assert.Nil(t, err)
+	assert.Equal(t, &MovedFrom{Owner: "admin", Tenant: "tenant1"}, moved.MovedFrom)
+}
+
+// bufferDrainer flushes buffered writes on drain.
+type bufferDrainer struct {
+	buffered []string
+	flushed  []string
+}
+
+func (b *bufferDrainer) Drain(ctx context.Context) error {
+	b.flushed = append(b.flushed, b.buffered...)
+	b.buffered = nil
+	return nil
+}
+
+func TestDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &apiManager{ctx: ctx, cancel: cancel, drainTimeout: 50 * time.Millisecond, maintenance: atomic.NewBool(false)}
+	buffer := &bufferDrainer{buffered: []string{"w1", "w2"}}
+	m.AddDrainer("buffer", buffer)
+	assert.Nil(t, m.Drain(context.Background()))
+	assert.Equal(t, []string{"w1", "w2"}, buffer.flushed)
+	assert.Empty(t, buffer.buffered)
+
+	// timeout hit with data still pending.
+	m.AddDrainer("stuck", DrainFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	m.AddDrainer("later", &bufferDrainer{buffered: []string{"w4"}})
+	buffer.buffered = []string{"w3"}
+	err := m.Stop(context.Background())
+	assert.ErrorIs(t, err, xerrors.ErrDrainIncomplete)
+	assert.Contains(t, err.Error(), "pending stuck,later")
+	assert.Equal(t, []string{"w1", "w2", "w3"}, buffer.flushed)
+	assert.NotNil(t, m.ctx.Err())
+
+	// writes rejected once stopping.
+	assert.ErrorIs(t, m.DeleteEntity(context.Background(), &Base{ID: "device123"}), xerrors.ErrMaintenanceMode)
+}
+
+func Test_readRepair(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","type":"DEVICE","version":3}`)))
+	assert.Nil(t, repo.PutEntity(ctx, "device234", []byte(`{"id":"device234","type":"DEVICE","version":2}`)))
+
+	indexed := make(chan map[string]interface{}, 2)
+	m := &apiManager{ctx: ctx, entityRepo: repo, repairs: newReadRepairer(1000)}
+	m.searchClient = searchIndexFunc(func(ctx context.Context, in *v1.IndexObject) (*v1.IndexResponse, error) {
+		doc, _ := in.Obj.AsInterface().(map[string]interface{})
+		indexed <- doc
+		return &v1.IndexResponse{}, nil
+	})
+
+	// device123 lagging, device234 up to date despite its version lagging, repeated hits queued once.
+	assert.True(t, m.RepairIndex("device123", map[string]interface{}{"id": "device123", "type": "DEVICE", "version": float64(1)}))
+	assert.True(t, m.RepairIndex("device123", map[string]interface{}{"id": "device123", "type": "GATEWAY", "version": float64(3)}))
+	assert.True(t, m.RepairIndex("device234", map[string]interface{}{"id": "device234", "type": "DEVICE", "version": float64(1)}))
+	assert.Len(t, m.repairs.queue, 2)
+	go m.runReadRepair(m.repairs)
+
+	select {
+	case doc := <-indexed:
+		assert.Equal(t, "device123", doc["id"])
+		assert.Equal(t, float64(3), doc["version"])
+	case <-time.After(time.Second):
+		t.Fatal("lagging entity not repaired")
+	}
+	select {
+	case doc := <-indexed:
+		t.Fatalf("up to date entity re-indexed: %v", doc)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// queue full, repairs dropped.
+	full := newReadRepairer(1)
+	for index := 0; index < readRepairQueueSize; index++ {
+		assert.True(t, full.add(fmt.Sprintf("device%d", index), nil))
+	}
+	assert.False(t, full.add("device404", nil))
+}
+
+// stateDispatcher plays the runtime, serving patches of a single entity through the holder.
+type stateDispatcher struct {
+	lock    sync.Mutex
+	holder  holder.Holder
+	version int64
+	state   map[string]interface{}
+	patches [][]*v1.PatchData
+}
+
+func (d *stateDispatcher) DispatchToLog(ctx context.Context, bytes []byte) error {
+	return nil
+}
+
+func (d *stateDispatcher) Dispatch(ctx context.Context, ev v1.Event) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	pds := ev.(*v1.ProtoEvent).GetPatches().GetPatches()
+	resp := &holder.Response{ID: ev.Attr(v1.MetaRequestID), Status: types.StatusOK}
+	if version := ev.Attr(v1.MetaVersion); version != "" && version != strconv.FormatInt(d.version, 10) {
+		resp.Status, resp.ErrCode = types.StatusError, xerrors.ErrEntityConflict.Error()
+	} else if len(pds) > 0 {
+		d.patches = append(d.patches, pds)
+		for _, pd := range pds {
+			segs := strings.Split(pd.Path, ".")
+			parent := d.state
+			for _, seg := range segs[:len(segs)-1] {
+				parent, _ = parent[seg].(map[string]interface{})
+			}
+			if pd.Operator == xjson.OpRemove.String() {
+				delete(parent, segs[len(segs)-1])
+				continue
+			}
+			var val interface{}
+			_ = json.Unmarshal(pd.Value, &val)
+			parent[segs[len(segs)-1]] = val
+		}
+		d.version++
+	}
+
+	state := map[string]interface{}{"id": ev.Entity(), "version": d.version}
+	for key, val := range d.state {
+		state[key] = val
+	}
+	resp.Data, _ = json.Marshal(state)
+	go d.holder.OnRespond(resp)
+	return nil
+}
+
+// rolesDispatcher records roles carried by writes.
+type rolesDispatcher struct {
+	*stateDispatcher
+	roles []string
+}
+
+func (d *rolesDispatcher) Dispatch(ctx context.Context, ev v1.Event) error {
+	if roles, ok := ev.Attributes()[v1.MetaRoles]; ok {
+		d.roles = append(d.roles, roles)
+	}
+	return d.stateDispatcher.Dispatch(ctx, ev)
+}
+
+func TestPatchEntityRoles(t *testing.T) {
+	ctx := context.Background()
+	dispatcher := &rolesDispatcher{stateDispatcher: &stateDispatcher{state: map[string]interface{}{
+		"properties": map[string]interface{}{},
+	}}}
+	m := &apiManager{
+		holder:      holder.New(ctx, time.Second),
+		dispatcher:  dispatcher,
+		maintenance: atomic.NewBool(false),
+	}
+	dispatcher.holder = m.holder
+	pds := func() []*v1.PatchData {
+		return []*v1.PatchData{{Path: "properties.temp", Operator: "replace", Value: []byte(`20`)}}
+	}
+
+	// writes of clients, single or batched, carry roles checked by runtime against property acls.
+	clientCtx := types.WithIdentity(ctx, types.Identity{User: "u1", Roles: []string{"user", "operator"}})
+	_, _, err := m.PatchEntity(clientCtx, &Base{ID: "device123"}, pds())
+	assert.Nil(t, err)
+	_, errs := m.PatchEntities(types.WithIdentity(ctx, types.Identity{}), []string{"device123"}, pds())
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"user,operator", ""}, dispatcher.roles)
+
+	// internal writes and reads carry none.
+	_, _, err = m.PatchEntity(ctx, &Base{ID: "device123"}, pds())
+	assert.Nil(t, err)
+	_, _, err = m.PatchEntity(clientCtx, &Base{ID: "device123"}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, dispatcher.roles, 2)
+}
+
+func TestDeleteProperty(t *testing.T) {
+	ctx := context.Background()
+	dispatcher := &stateDispatcher{version: 3, state: map[string]interface{}{
+		"properties": map[string]interface{}{
+			"temp":    20,
+			"metrics": map[string]interface{}{"cpu": 0.5, "mem": 0.3},
+		},
+	}}
+	m := &apiManager{
+		holder:      holder.New(ctx, time.Second),
+		dispatcher:  dispatcher,
+		maintenance: atomic.NewBool(false),
+	}
+	dispatcher.holder = m.holder
+	en := &Base{ID: "device123", Owner: "admin"}
+
+	_, err := m.DeleteProperty(ctx, en, "", false)
+	assert.ErrorIs(t, err, xerrors.ErrEntityPropertyIDEmpty)
+
+	// nested path removed with a patch pinned to the version read.
+	ret, err := m.DeleteProperty(ctx, en, "metrics.cpu", false)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), ret.Version)
+	assert.True(t, ret.GetProperty("metrics.cpu").IsNil())
+	assert.False(t, ret.GetProperty("metrics.mem").IsNil())
+	assert.Len(t, dispatcher.patches, 1)
+	assert.Equal(t, "properties.metrics.cpu", dispatcher.patches[0][0].Path)
+	assert.Equal(t, xjson.OpRemove.String(), dispatcher.patches[0][0].Operator)
+
+	// absent property.
+	_, err = m.DeleteProperty(ctx, en, "metrics.cpu", false)
+	assert.ErrorIs(t, err, xerrors.ErrPropertyNotFound)
+	ret, err = m.DeleteProperty(ctx, en, "metrics.cpu", true)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), ret.Version)
+	assert.Len(t, dispatcher.patches, 1)
+
+	// entity changed between read and patch.
+	guarded := &versionBumper{stateDispatcher: dispatcher}
+	m.dispatcher = guarded
+	_, err = m.DeleteProperty(ctx, en, "temp", false)
+	assert.ErrorIs(t, err, xerrors.ErrEntityConflict)
+	assert.Len(t, dispatcher.patches, 1)
+}
+
+func TestMigrateStored(t *testing.T) {
+	ctx := context.Background()
+	dispatcher := &stateDispatcher{version: 3, state: map[string]interface{}{
+		"type":       "DEVICE",
+		"properties": map[string]interface{}{"temp": 20, "unit": "C"},
+	}}
+	m := &apiManager{
+		holder:      holder.New(ctx, time.Second),
+		dispatcher:  dispatcher,
+		maintenance: atomic.NewBool(false),
+	}
+	dispatcher.holder = m.holder
+	m.AddMigration("DEVICE", 0, func(ret *BaseRet) error {
+		ret.Properties["temperature"] = ret.Properties["temp"]
+		delete(ret.Properties, "temp")
+		return nil
+	})
+
+	ret, err := m.GetEntity(ctx, &Base{ID: "device123"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), ret.SchemaVersion)
+	assert.Equal(t, map[string]interface{}{"temperature": float64(20), "unit": "C"}, ret.Properties)
+
+	// migrated state persisted in the background, pinned to the version read.
+	assert.Eventually(t, func() bool {
+		ret, err = m.GetEntity(ctx, &Base{ID: "device123"})
+		return nil == err && ret.Version == 4
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]interface{}{"temperature": float64(20), "unit": "C"}, ret.Properties)
+	assert.Len(t, dispatcher.patches, 1)
+	assert.Len(t, dispatcher.patches[0], 3)
+}
+
+// versionBumper changes the entity right after it is read.
+type versionBumper struct {
+	*stateDispatcher
+}
+
+func (d *versionBumper) Dispatch(ctx context.Context, ev v1.Event) error {
+	err := d.stateDispatcher.Dispatch(ctx, ev)
+	if len(ev.(*v1.ProtoEvent).GetPatches().GetPatches()) == 0 {
+		d.lock.Lock()
+		d.version++
+		d.lock.Unlock()
+	}
+	return err
+}
+
+// shardedRepo plays a repository over a sharded state store, transactions span shards.
+type shardedRepo struct {
+	repository.IRepository
+}
+
+func (r *shardedRepo) FlushEntity(ctx context.Context) error {
+	return nil
+}
+
+func (r *shardedRepo) Transaction(ctx context.Context, fn func(tx *repository.Tx) error) error {
+	return fmt.Errorf("sharded store transact: %w", xerrors.ErrCrossShardTransaction)
+}
+
+func TestTransactionCrossShard(t *testing.T) {
+	m := &apiManager{entityRepo: &shardedRepo{}, maintenance: atomic.NewBool(false)}
+	err := m.Transaction(context.Background(), func(tx *Tx) error {
+		tx.Delete(&Base{ID: "device123"})
+		tx.Delete(&Base{ID: "device234"})
+		return nil
+	})
+	assert.ErrorIs(t, err, xerrors.ErrCrossShardTransaction)
+}
+
+type livenessFunc func(ctx context.Context, entityID string) (*runtime.Liveness, error)
+
+func (f livenessFunc) GetLiveness(ctx context.Context, entityID string) (*runtime.Liveness, error) {
+	return f(ctx, entityID)
+}
+
+func TestGetLiveness(t *testing.T) {
+	m := &apiManager{}
+	_, err := m.GetLiveness(context.Background(), "device123")
+	assert.ErrorIs(t, err, xerrors.ErrConnectionNil)
+
+	m.SetLivenessSource(livenessFunc(func(ctx context.Context, entityID string) (*runtime.Liveness, error) {
+		if entityID != "device123" {
+			return nil, xerrors.ErrEntityNotFound
+		}
+		return &runtime.Liveness{EntityID: entityID, Online: true}, nil
+	}))
+	liveness, err := m.GetLiveness(context.Background(), "device123")
+	assert.Nil(t, err)
+	assert.True(t, liveness.Online)
+	_, err = m.GetLiveness(context.Background(), "device234")
+	assert.ErrorIs(t, err, xerrors.ErrEntityNotFound)
+}
+
+func TestLogJSON_StoredScheme(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	repo := repository.New(memDao)
+	assert.Nil(t, repo.PutEntity(ctx, "device123", []byte(`{"id":"device123","template_id":"tpl-1",
+		"scheme":{"ssn":{"type":"string","sensitive":true}}}`)))
+	assert.Nil(t, repo.PutEntity(ctx, "tpl-1", []byte(`{"id":"tpl-1",
+		"scheme":{"phone":{"type":"string","sensitive":true}}}`)))
+	m := &apiManager{entityRepo: repo}
+
+	// schemes of stored state and template resolved if the request carries none.
+	info := m.logJSON(ctx, &Base{ID: "device123", Properties: []byte(`{"ssn":"123-45-6789","phone":"555-0100","temp":20}`)})
+	assert.JSONEq(t, `{"ssn":"***","phone":"***","temp":20}`, info["properties"].(string))
+
+	// template of the request resolved for entities not created yet.
+	info = m.logJSON(ctx, &Base{ID: "device234", TemplateID: "tpl-1", Properties: []byte(`{"phone":"555-0100"}`)})
+	assert.JSONEq(t, `{"phone":"***"}`, info["properties"].(string))
+
+	// resolved paths reused until the entity written.
+	assert.Nil(t, repo.PutEntity(ctx, "device234", []byte(`{"id":"device234","scheme":{"temp":{"type":"int","sensitive":true}}}`)))
+	info = m.logJSON(ctx, &Base{ID: "device234", Properties: []byte(`{"temp":20}`)})
+	assert.JSONEq(t, `{"temp":20}`, info["properties"].(string))
+	m.sensitive.remove("device234")
+	info = m.logJSON(ctx, &Base{ID: "device234", Properties: []byte(`{"temp":20}`)})
+	assert.JSONEq(t, `{"temp":"***"}`, info["properties"].(string))
+}
+
+func TestRemoveMirrors(t *testing.T) {
+	ctx := context.Background()
+	repo := &mirrorRepo{subs: map[string]*repository.Subscription{}, indexes: map[string]*repository.MirrorIndex{}}
+	m := &apiManager{entityRepo: repo}
+
+	// bidirectional mirror within one entity, directions keyed apart.
+	self := &Mirror{ID: "mirror-1", Owner: "admin", SourceID: "device123", SourceProp: "temp",
+		TargetID: "device123", TargetProp: "temp_copy", Mode: MirrorBidirectional}
+	other := &Mirror{ID: "mirror-2", Owner: "admin", SourceID: "device234", SourceProp: "temp",
+		TargetID: "device123", TargetProp: "remote_temp", Mode: MirrorOneWay}
+	assert.Nil(t, m.putMirror(ctx, self))
+	assert.Nil(t, m.putMirror(ctx, other))
+	assert.Len(t, repo.subs, 3)
+	assert.Len(t, repo.indexes, 3)
+
+	// mirrors of the entity found by index, indexes by the other entity removed too.
+	m.removeMirrors(ctx, "device123")
+	assert.Empty(t, repo.subs)
+	assert.Empty(t, repo.indexes)
+}
+
+// mirrorRepo keeps subscriptions and mirror indexes, ranging subscriptions not served.
+type mirrorRepo struct {
+	repository.IRepository
+	subs    map[string]*repository.Subscription
+	indexes map[string]*repository.MirrorIndex
+}
+
+func (r *mirrorRepo) PutSubscription(ctx context.Context, sub *repository.Subscription) error {
+	key, err := sub.EncodeKey()
+	r.subs[string(key)] = sub
+	return err
+}
+
+func (r *mirrorRepo) DelSubscription(ctx context.Context, sub *repository.Subscription) error {
+	key, err := sub.EncodeKey()
+	delete(r.subs, string(key))
+	return err
+}
+
+func (r *mirrorRepo) PutMirrorIndex(ctx context.Context, index *repository.MirrorIndex) error {
+	key, err := index.EncodeKey()
+	r.indexes[string(key)] = index
+	return err
+}
+
+func (r *mirrorRepo) DelMirrorIndex(ctx context.Context, index *repository.MirrorIndex) error {
+	key, err := index.EncodeKey()
+	delete(r.indexes, string(key))
+	return err
+}
+
+func (r *mirrorRepo) ListEntityMirrors(ctx context.Context, rev int64, eid string) ([]*repository.MirrorIndex, error) {
+	var indexes []*repository.MirrorIndex
+	for _, index := range r.indexes {
+		if index.EntityID == eid {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes, nil
+}
@@ -0,0 +1,1628 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package managertest provides an in-memory manager.APIManager for unit testing code built on
+// the entity manager without dapr and etcd.
+package managertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/manager"
+	"github.com/tkeel-io/core/pkg/manager/holder"
+	"github.com/tkeel-io/core/pkg/mapper"
+	"github.com/tkeel-io/core/pkg/mapper/expression"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/runtime"
+	"github.com/tkeel-io/core/pkg/types"
+	"github.com/tkeel-io/core/pkg/util"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/core/third_party/jsonpatch"
+	"github.com/tkeel-io/tdtl"
+)
+
+const (
+	defaultSnapshotLimit = 10
+	watchBufferSize      = 16
+	waitInterval         = 10 * time.Millisecond
+)
+
+var _ manager.APIManager = (*Fake)(nil)
+
+type migrationKey struct {
+	typ  string
+	from int64
+}
+
+// Fake in-memory manager.APIManager, entity writes applied to states in place synchronously,
+// mappers stored as expressions but never evaluated, mirrors stored as subscriptions and synced on
+// creation only. errors match the ones the real manager returns:
+//   - ErrEntityNotFound for absent entities, ErrEntityAleadyExists on creating an existing entity.
+//   - ErrEntityConflict on patching with manager.NewVersionOption of a stale version.
+//   - ErrResourceNotFound for absent groups, expressions and subscriptions.
+//   - ErrMaintenanceMode for writes in maintenance mode.
+//   - ErrPermissionDenied on dumping entities without the admin role of the owning tenant.
+//
+// search based methods match entities in memory, only $eq and $neq conditions supported.
+type Fake struct {
+	lock        sync.RWMutex
+	maintenance bool
+
+	entities      map[string][]byte
+	snapshots     map[string][]*manager.Snapshot
+	exprs         map[string]repository.Expression
+	groups        map[string]*repository.Group
+	members       map[string]map[string]bool
+	subscriptions map[string]*repository.Subscription
+	watchers      map[string][]chan *manager.BaseRet
+	hooks         []manager.ValidationHook
+	migrations    map[migrationKey]manager.MigrateFunc
+	drainers      []drainer
+	liveness      manager.LivenessSource
+}
+
+type drainer struct {
+	name    string
+	drainer manager.Drainer
+}
+
+// NewFake returns an empty fake manager.
+func NewFake() *Fake {
+	return &Fake{
+		entities:      make(map[string][]byte),
+		snapshots:     make(map[string][]*manager.Snapshot),
+		exprs:         make(map[string]repository.Expression),
+		groups:        make(map[string]*repository.Group),
+		members:       make(map[string]map[string]bool),
+		subscriptions: make(map[string]*repository.Subscription),
+		watchers:      make(map[string][]chan *manager.BaseRet),
+		migrations:    make(map[migrationKey]manager.MigrateFunc),
+	}
+}
+
+func (f *Fake) OnRespond(context.Context, *holder.Response) {}
+
+func (f *Fake) SetMaintenanceMode(on bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.maintenance = on
+}
+
+func (f *Fake) MaintenanceMode() bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.maintenance
+}
+
+func (f *Fake) AddValidationHook(hook manager.ValidationHook, _ time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.hooks = append(f.hooks, hook)
+}
+
+func (f *Fake) AddDrainer(name string, d manager.Drainer) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.drainers = append(f.drainers, drainer{name: name, drainer: d})
+}
+
+func (f *Fake) Drain(ctx context.Context) error {
+	f.lock.RLock()
+	drainers := f.drainers
+	f.lock.RUnlock()
+
+	var pending []string
+	for _, d := range drainers {
+		if nil != ctx.Err() {
+			pending = append(pending, d.name)
+		} else if err := d.drainer.Drain(ctx); nil != err {
+			pending = append(pending, d.name)
+		}
+	}
+
+	if len(pending) > 0 {
+		return errors.Wrapf(xerrors.ErrDrainIncomplete, "pending %s", strings.Join(pending, ","))
+	}
+	return nil
+}
+
+// Stop drain buffered data, the fake runs no background tasks.
+func (f *Fake) Stop(ctx context.Context) error {
+	return errors.Wrap(f.Drain(ctx), "stop manager")
+}
+
+func (f *Fake) AddMigration(typ string, from int64, fn manager.MigrateFunc) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.migrations[migrationKey{typ: typ, from: from}] = fn
+}
+
+// SetSearchClient ignored, the fake matches entities in memory.
+func (f *Fake) SetSearchClient(v1.SearchHTTPServer) {}
+
+func (f *Fake) SetLivenessSource(source manager.LivenessSource) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.liveness = source
+}
+
+func (f *Fake) GetLiveness(ctx context.Context, id string) (*runtime.Liveness, error) {
+	f.lock.RLock()
+	source := f.liveness
+	f.lock.RUnlock()
+	if source == nil {
+		return nil, errors.Wrap(xerrors.ErrEntityNotFound, "get entity liveness")
+	}
+	return source.GetLiveness(ctx, id)
+}
+
+func (f *Fake) SetConfigsByType(ctx context.Context, typ string, configs map[string]interface{}) (int, error) {
+	pds, err := replacePatches(manager.FieldScheme, configs)
+	if nil != err {
+		return 0, errors.Wrap(err, "set configs by type")
+	}
+
+	count := 0
+	for _, id := range f.ids(func(ret *manager.BaseRet) bool { return ret.Type == typ }) {
+		if _, _, err = f.PatchEntity(ctx, &manager.Base{ID: id}, pds); nil != err {
+			return count, errors.Wrap(err, "set configs by type")
+		}
+		count++
+	}
+	return count, nil
+}
+
+// FinishDeletes nothing to finish, deletes of the fake never interrupted.
+func (f *Fake) FinishDeletes(context.Context) (int, error) {
+	return 0, f.checkWritable()
+}
+
+// FinishIndexes nothing to finish, the fake has no search index.
+func (f *Fake) FinishIndexes(context.Context) (int, error) {
+	return 0, f.checkWritable()
+}
+
+func (f *Fake) CreateEntity(ctx context.Context, en *manager.Base) (*manager.BaseRet, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.checkWritableLocked(); nil != err {
+		return nil, err
+	}
+
+	if en.ID == "" {
+		id, err := util.IG().NewEIDWith("")
+		if nil != err {
+			return nil, errors.Wrap(err, "create entity")
+		}
+		en.ID = id
+	} else if _, has := f.entities[en.ID]; has {
+		return nil, errors.Wrapf(xerrors.ErrEntityAleadyExists, "create entity %s", en.ID)
+	}
+
+	bytes, err := en.EncodeJSON()
+	if nil != err {
+		return nil, errors.Wrap(err, "create entity")
+	}
+
+	return f.commitLocked(en.ID, tdtl.New(bytes))
+}
+
+func (f *Fake) GetOrCreateEntity(ctx context.Context, en *manager.Base) (*manager.BaseRet, bool, error) {
+	if ret, err := f.GetEntity(ctx, en); nil == err {
+		return ret, false, nil
+	} else if !xerrors.IsEntityNotFound(err) {
+		return nil, false, errors.Wrap(err, "get or create entity")
+	}
+
+	ret, err := f.CreateEntity(ctx, en)
+	if nil != err {
+		return nil, false, errors.Wrap(err, "get or create entity")
+	}
+	return ret, true, nil
+}
+
+func (f *Fake) EntitiesExist(ctx context.Context, ids []string) (map[string]bool, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	exists := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		_, exists[id] = f.entities[id]
+	}
+	return exists, nil
+}
+
+func (f *Fake) PatchEntity(ctx context.Context, en *manager.Base, pds []*v1.PatchData, opts ...manager.Option) (*manager.BaseRet, []byte, error) {
+	if len(pds) == 0 {
+		ret, err := f.GetEntity(ctx, en)
+		if nil != err {
+			return nil, nil, errors.Wrap(err, "patch entity")
+		}
+		bytes, _ := json.Marshal(ret)
+		return ret, bytes, nil
+	}
+
+	if err := f.validate(ctx, en.ID, pds); nil != err {
+		return nil, nil, err
+	}
+
+	metadata := manager.Metadata{}
+	for _, option := range opts {
+		option(metadata)
+	}
+	if namespace, has := metadata[v1.MetaNamespace]; has {
+		var err error
+		if pds, err = namespacePatches(namespace, pds); nil != err {
+			return nil, nil, errors.Wrap(err, "patch entity")
+		}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.checkWritableLocked(); nil != err {
+		return nil, nil, err
+	}
+
+	state, err := f.loadLocked(en.ID)
+	if nil != err {
+		return nil, nil, errors.Wrap(err, "patch entity")
+	}
+
+	if version, has := metadata[v1.MetaVersion]; has {
+		if version != state.Get("version").String() {
+			return nil, nil, xerrors.ErrEntityConflict
+		}
+	}
+
+	if err = applyPatches(state, pds); nil != err {
+		return nil, nil, errors.Wrapf(err, "patch entity %s", en.ID)
+	}
+
+	f.snapshotLocked(en.ID)
+	ret, err := f.commitLocked(en.ID, state)
+	if nil != err {
+		return nil, nil, errors.Wrap(err, "patch entity")
+	}
+	return ret, f.entities[en.ID], nil
+}
+
+// namespacePatches namespace property keys written by the patches, as runtime does.
+func namespacePatches(namespace string, pds []*v1.PatchData) ([]*v1.PatchData, error) {
+	if !types.ValidNamespace(namespace) {
+		return nil, errors.Wrapf(xerrors.ErrNamespaceInvalid, "namespace %q", namespace)
+	}
+
+	ret := make([]*v1.PatchData, 0, len(pds))
+	for _, pd := range pds {
+		switch {
+		case pd.Path == "properties":
+			var props map[string]json.RawMessage
+			if err := json.Unmarshal(pd.Value, &props); nil != err {
+				ret = append(ret, pd)
+				continue
+			}
+			for key, value := range props {
+				ret = append(ret, &v1.PatchData{
+					Path:     "properties." + types.NamespacedKey(namespace, key),
+					Operator: pd.Operator,
+					Value:    value,
+				})
+			}
+		case strings.HasPrefix(pd.Path, "properties."):
+			ret = append(ret, &v1.PatchData{
+				Path:     "properties." + types.NamespacedKey(namespace, strings.TrimPrefix(pd.Path, "properties.")),
+				Operator: pd.Operator,
+				Value:    pd.Value,
+			})
+		default:
+			ret = append(ret, pd)
+		}
+	}
+	return ret, nil
+}
+
+func (f *Fake) DeleteEntity(ctx context.Context, en *manager.Base) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.checkWritableLocked(); nil != err {
+		return err
+	} else if _, has := f.entities[en.ID]; !has {
+		return errors.Wrapf(xerrors.ErrEntityNotFound, "delete entity %s", en.ID)
+	}
+
+	f.deleteLocked(en.ID)
+	return nil
+}
+
+func (f *Fake) PatchEntities(ctx context.Context, ids []string, pds []*v1.PatchData) (map[string]*manager.BaseRet, map[string]error) {
+	rets := make(map[string]*manager.BaseRet)
+	errs := make(map[string]error)
+	for _, id := range ids {
+		ret, _, err := f.PatchEntity(ctx, &manager.Base{ID: id}, pds)
+		if nil != err {
+			errs[id] = err
+			continue
+		}
+		rets[id] = ret
+	}
+	return rets, errs
+}
+
+func (f *Fake) DeleteEntities(ctx context.Context, ids []string, opts manager.DeleteOptions) map[string]error {
+	errs := make(map[string]error)
+	for _, id := range ids {
+		var err error
+		if opts.Soft {
+			bytes, _ := json.Marshal(time.Now().UnixNano() / 1e6)
+			_, _, err = f.PatchEntity(ctx, &manager.Base{ID: id}, []*v1.PatchData{{
+				Path:     manager.FieldDeletedAt,
+				Operator: xjson.OpReplace.String(),
+				Value:    bytes,
+			}})
+		} else if err = f.DeleteEntity(ctx, &manager.Base{ID: id}); nil == err {
+			f.removeExprs(func(expr repository.Expression) bool {
+				return expr.EntityID == id && expr.Owner == opts.Owner
+			})
+		}
+
+		if nil != err {
+			errs[id] = err
+		}
+	}
+	return errs
+}
+
+// MoveEntities rewrite owner and tenant property of the entities, expressions moved along.
+func (f *Fake) MoveEntities(ctx context.Context, ids []string, target string) map[string]error {
+	errs := make(map[string]error)
+	for _, id := range ids {
+		if err := f.moveEntity(ctx, id, target); nil != err {
+			errs[id] = err
+		}
+	}
+	return errs
+}
+
+func (f *Fake) moveEntity(ctx context.Context, id, target string) error {
+	if target == "" {
+		return errors.Wrap(xerrors.ErrInvalidParam, "move entity, empty target tenant")
+	}
+
+	base, err := f.GetEntity(ctx, &manager.Base{ID: id})
+	if nil != err {
+		return errors.Wrap(err, "move entity")
+	}
+
+	bytes, _ := json.Marshal(target)
+	if _, _, err = f.PatchEntity(ctx, &manager.Base{ID: id}, []*v1.PatchData{
+		{Path: "owner", Operator: xjson.OpReplace.String(), Value: bytes},
+		{Path: "properties.sysField._tenantId", Operator: xjson.OpReplace.String(), Value: bytes},
+	}); nil != err {
+		return errors.Wrap(err, "move entity")
+	}
+
+	exprs, _ := f.ListExpression(ctx, &manager.Base{ID: id, Owner: base.Owner})
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, expr := range exprs {
+		delete(f.exprs, expr.ID)
+		expr.Owner = target
+		expr.GenKey()
+		f.exprs[expr.ID] = *expr
+	}
+	return nil
+}
+
+// CloneEntity create the clone with the state of the source, expressions copied along.
+func (f *Fake) CloneEntity(ctx context.Context, sourceID string, clone *manager.Base, opts manager.CloneOptions) (*manager.BaseRet, error) {
+	source, err := f.GetEntity(ctx, &manager.Base{ID: sourceID})
+	if nil != err {
+		return nil, errors.Wrap(err, "clone entity")
+	}
+
+	en, err := manager.ClonedBase(source, clone)
+	if nil != err {
+		return nil, errors.Wrap(err, "clone entity")
+	} else if en.ID == "" {
+		if en.ID, err = util.IG().NewEIDWith(""); nil != err {
+			return nil, errors.Wrap(err, "clone entity")
+		}
+	}
+
+	exprs, _ := f.ListExpression(ctx, &manager.Base{ID: sourceID, Owner: source.Owner})
+	cloned, err := manager.CloneExprs(exprs, en, opts)
+	if nil != err {
+		return nil, errors.Wrap(err, "clone entity")
+	}
+
+	ret, err := f.CreateEntity(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "clone entity")
+	}
+	return ret, errors.Wrap(f.putExprs(cloned), "clone entity")
+}
+
+// RepairIndex nothing to repair, the fake has no search index.
+func (f *Fake) RepairIndex(string, map[string]interface{}) bool {
+	return false
+}
+
+// Reindex counts the entities only, the fake has no search index.
+func (f *Fake) Reindex(ctx context.Context, ids []string, opts manager.ReindexOptions) (manager.ReindexStats, error) {
+	if nil != opts.Progress {
+		defer close(opts.Progress)
+	}
+
+	start := time.Now()
+	if len(ids) == 0 {
+		ids = f.ids(nil)
+	}
+
+	stats := manager.ReindexStats{Total: len(ids)}
+	exists, _ := f.EntitiesExist(ctx, ids)
+	for _, id := range ids {
+		stats.Processed++
+		if !exists[id] {
+			stats.Failed++
+		}
+	}
+
+	stats.Elapsed = time.Since(start)
+	if nil != opts.Progress {
+		select {
+		case opts.Progress <- stats:
+		default:
+		}
+	}
+	return stats, nil
+}
+
+func (f *Fake) ChangeType(ctx context.Context, id, newType string) (*manager.BaseRet, error) {
+	if newType == "" {
+		return nil, errors.Wrap(xerrors.ErrInvalidParam, "change type, empty type")
+	}
+
+	ret, err := f.GetEntity(ctx, &manager.Base{ID: id})
+	if nil != err {
+		return nil, errors.Wrap(err, "change type")
+	} else if ret.Type == newType {
+		return ret, nil
+	}
+
+	bytes, _ := json.Marshal(newType)
+	ret, _, err = f.PatchEntity(ctx, &manager.Base{ID: id}, []*v1.PatchData{{
+		Path:     "type",
+		Operator: xjson.OpReplace.String(),
+		Value:    bytes,
+	}})
+	return ret, errors.Wrap(err, "change type")
+}
+
+func (f *Fake) ListSnapshots(ctx context.Context, id string) ([]*manager.Snapshot, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return append([]*manager.Snapshot{}, f.snapshots[id]...), nil
+}
+
+func (f *Fake) RestoreSnapshot(ctx context.Context, id, snapshotID string) (*manager.BaseRet, error) {
+	snapshots, _ := f.ListSnapshots(ctx, id)
+	for _, snapshot := range snapshots {
+		if snapshot.ID != snapshotID {
+			continue
+		}
+
+		props, _ := json.Marshal(snapshot.Entity.Properties)
+		scheme, _ := json.Marshal(snapshot.Entity.Scheme)
+		ret, _, err := f.PatchEntity(ctx, &manager.Base{ID: id}, []*v1.PatchData{
+			{Path: "properties", Operator: xjson.OpReplace.String(), Value: props},
+			{Path: manager.FieldScheme, Operator: xjson.OpReplace.String(), Value: scheme},
+		})
+		return ret, errors.Wrap(err, "restore snapshot")
+	}
+
+	return nil, errors.Wrapf(xerrors.ErrSnapshotNotFound, "restore snapshot %s of entity %s", snapshotID, id)
+}
+
+func (f *Fake) FreezeEntity(ctx context.Context, en *manager.Base, frozen bool) error {
+	bytes, _ := json.Marshal(frozen)
+	_, _, err := f.PatchEntity(ctx, en, []*v1.PatchData{{
+		Path:     manager.FieldFrozen,
+		Operator: xjson.OpReplace.String(),
+		Value:    bytes,
+	}})
+	return errors.Wrap(err, "freeze entity")
+}
+
+func (f *Fake) SetAttributes(ctx context.Context, en *manager.Base, attrs map[string]string) (*manager.BaseRet, error) {
+	current, err := f.GetEntity(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "set attributes")
+	}
+
+	merged, err := manager.MergeAttributes(current.Attributes, attrs, 0)
+	if nil != err {
+		return nil, errors.Wrap(err, "set attributes")
+	}
+
+	bytes, _ := json.Marshal(merged)
+	ret, _, err := f.PatchEntity(ctx, en, []*v1.PatchData{{
+		Path:     manager.FieldAttributes,
+		Operator: xjson.OpReplace.String(),
+		Value:    bytes,
+	}}, manager.NewVersionOption(current.Version))
+	return ret, errors.Wrap(err, "set attributes")
+}
+
+func (f *Fake) GetAttributes(ctx context.Context, en *manager.Base) (map[string]string, error) {
+	ret, err := f.GetEntity(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "get attributes")
+	}
+
+	if ret.Attributes == nil {
+		return map[string]string{}, nil
+	}
+	return ret.Attributes, nil
+}
+
+func (f *Fake) DeleteProperty(ctx context.Context, en *manager.Base, path string, idempotent bool) (*manager.BaseRet, error) {
+	current, err := f.GetEntity(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "delete property")
+	}
+
+	if current.GetProperty(path).IsNil() {
+		if idempotent {
+			return current, nil
+		}
+		return nil, errors.Wrapf(xerrors.ErrPropertyNotFound, "delete property %s", path)
+	}
+
+	ret, _, err := f.PatchEntity(ctx, en, []*v1.PatchData{{
+		Path:     "properties." + path,
+		Operator: xjson.OpRemove.String(),
+	}}, manager.NewVersionOption(current.Version))
+	return ret, errors.Wrap(err, "delete property")
+}
+
+func (f *Fake) PurgeTombstones(ctx context.Context, olderThan time.Duration) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.checkWritableLocked(); nil != err {
+		return 0, err
+	}
+
+	count := 0
+	deadline := time.Now().Add(-olderThan).UnixNano() / 1e6
+	for id, bytes := range f.entities {
+		deletedAt, err := strconv.ParseInt(tdtl.New(bytes).Get(manager.FieldDeletedAt).String(), 10, 64)
+		if nil == err && deletedAt <= deadline {
+			f.deleteLocked(id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CompactMappers remove expressions of absent entities, compact ignored.
+func (f *Fake) CompactMappers(ctx context.Context, _ bool) (int, error) {
+	if err := f.checkWritable(); nil != err {
+		return 0, err
+	}
+
+	f.lock.RLock()
+	absent := make(map[string]bool)
+	for _, expr := range f.exprs {
+		if _, has := f.entities[expr.EntityID]; !has {
+			absent[expr.EntityID] = true
+		}
+	}
+	f.lock.RUnlock()
+
+	return f.removeExprs(func(expr repository.Expression) bool {
+		return absent[expr.EntityID]
+	}), nil
+}
+
+func (f *Fake) ListTypes(ctx context.Context, owner string) ([]manager.TypeInfo, error) {
+	counts := make(map[string]int64)
+	for _, id := range f.ids(nil) {
+		if ret, err := f.GetEntity(ctx, &manager.Base{ID: id}); nil == err && ret.Owner == owner {
+			counts[ret.Type]++
+		}
+	}
+
+	types := make([]manager.TypeInfo, 0, len(counts))
+	for typ, count := range counts {
+		types = append(types, manager.TypeInfo{Type: typ, Count: count})
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types, nil
+}
+
+// ListAllMappers returns mappers of entities in entity id order, the token is the entity id to start from.
+func (f *Fake) ListAllMappers(ctx context.Context, token string, limit int) ([]manager.MapperRef, string, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	type mapperKey struct{ entityID, name string }
+	byEntity := make(map[string][]mapperKey)
+	refs := make(map[mapperKey]*manager.MapperRef)
+	for _, expr := range f.exprs {
+		key := mapperKey{entityID: expr.EntityID, name: expr.Name}
+		ref, has := refs[key]
+		if !has {
+			ref = &manager.MapperRef{EntityID: expr.EntityID, Owner: expr.Owner, Name: expr.Name}
+			refs[key] = ref
+			byEntity[expr.EntityID] = append(byEntity[expr.EntityID], key)
+		}
+		if ref.TQL != "" {
+			ref.TQL += ", "
+		}
+		ref.TQL += expr.Expression + " as " + strings.TrimPrefix(expr.Path, "properties.")
+	}
+
+	entityIDs := make([]string, 0, len(byEntity))
+	for eid := range byEntity {
+		if eid >= token {
+			entityIDs = append(entityIDs, eid)
+		}
+	}
+	sort.Strings(entityIDs)
+
+	var page []manager.MapperRef
+	for index, eid := range entityIDs {
+		if limit > 0 && len(page) > 0 && len(page)+len(byEntity[eid]) > limit {
+			return page, entityIDs[index], nil
+		}
+		keys := byEntity[eid]
+		sort.Slice(keys, func(i, j int) bool { return keys[i].name < keys[j].name })
+		for _, key := range keys {
+			page = append(page, *refs[key])
+		}
+	}
+	return page, "", nil
+}
+
+func (f *Fake) TagByQuery(ctx context.Context, query *v1.SearchRequest, tags map[string]string) (int, error) {
+	for key := range tags {
+		if key == "" || strings.Contains(key, ".") {
+			return 0, errors.Wrapf(xerrors.ErrInvalidParam, "tag by query, invalid tag key %q", key)
+		}
+	}
+
+	ids, err := f.match(query)
+	if nil != err {
+		return 0, errors.Wrap(err, "tag by query")
+	}
+
+	values := make(map[string]interface{}, len(tags))
+	for key, val := range tags {
+		values[key] = val
+	}
+	pds, err := replacePatches("properties.tags", values)
+	if nil != err {
+		return 0, errors.Wrap(err, "tag by query")
+	}
+
+	for index, id := range ids {
+		if _, _, err = f.PatchEntity(ctx, &manager.Base{ID: id}, pds); nil != err {
+			return index, errors.Wrap(err, "tag by query")
+		}
+	}
+	return len(ids), nil
+}
+
+func (f *Fake) QueryFields(ctx context.Context, query *v1.SearchRequest, fields []string) ([]map[string]interface{}, error) {
+	ids, err := f.match(query)
+	if nil != err {
+		return nil, errors.Wrap(err, "query fields")
+	}
+
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	items := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		state := tdtl.New(f.entities[id])
+		item := map[string]interface{}{"id": id}
+		for _, field := range fields {
+			if val := state.Get(field); val.Type() != tdtl.Null && val.Type() != tdtl.Undefined {
+				var v interface{}
+				if err = json.Unmarshal(val.Raw(), &v); nil == err {
+					item[field] = v
+				}
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (f *Fake) GetEntity(ctx context.Context, en *manager.Base) (*manager.BaseRet, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	state, err := f.loadLocked(en.ID)
+	if nil != err {
+		return nil, errors.Wrap(err, "get entity")
+	}
+
+	ret, err := f.decodeLocked(state.Raw())
+	return ret, errors.Wrap(err, "get entity")
+}
+
+// Materialize entities of the fake always materialized.
+func (f *Fake) Materialize(ctx context.Context, id string) error {
+	_, err := f.GetEntity(ctx, &manager.Base{ID: id})
+	return errors.Wrap(err, "materialize entity")
+}
+
+func (f *Fake) WaitForEntity(ctx context.Context, id string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		if exists, _ := f.EntitiesExist(ctx, []string{id}); exists[id] {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "wait for entity")
+		case <-time.After(waitInterval):
+		}
+	}
+}
+
+// WatchEntity streams entity after each write, interval ignored since writes observed directly.
+func (f *Fake) WatchEntity(ctx context.Context, id string, opts manager.WatchOptions) (<-chan *manager.BaseRet, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	state, err := f.loadLocked(id)
+	if nil != err {
+		return nil, errors.Wrap(err, "watch entity")
+	}
+
+	ch := make(chan *manager.BaseRet, watchBufferSize)
+	if opts.Snapshot {
+		ret, err := f.decodeLocked(state.Raw())
+		if nil != err {
+			return nil, errors.Wrap(err, "watch entity")
+		}
+		ch <- ret
+	}
+	f.watchers[id] = append(f.watchers[id], ch)
+
+	go func() {
+		<-ctx.Done()
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		for index, watcher := range f.watchers[id] {
+			if watcher == ch {
+				f.watchers[id] = append(f.watchers[id][:index], f.watchers[id][index+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (f *Fake) SetPropertiesWithRetry(ctx context.Context, en *manager.Base, mutate manager.MutateFunc, maxRetries int) (*manager.BaseRet, error) {
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		var current *manager.BaseRet
+		if current, err = f.GetEntity(ctx, en); nil != err {
+			return nil, errors.Wrap(err, "set properties, read entity")
+		}
+
+		if err = mutate(current); nil != err {
+			return nil, errors.Wrap(err, "set properties, mutate entity")
+		}
+
+		bytes, _ := json.Marshal(current.Properties)
+		var out *manager.BaseRet
+		out, _, err = f.PatchEntity(ctx, en, []*v1.PatchData{{
+			Path:     "properties",
+			Operator: xjson.OpReplace.String(),
+			Value:    bytes,
+		}}, manager.NewVersionOption(current.Version))
+		if !errors.Is(err, xerrors.ErrEntityConflict) {
+			return out, errors.Wrap(err, "set properties")
+		}
+	}
+
+	return nil, errors.Wrap(err, "set properties, retries exhausted")
+}
+
+// Transaction apply staged writes to copies of states, committed only if all of them succeed.
+func (f *Fake) Transaction(ctx context.Context, fn func(tx *manager.Tx) error) error {
+	tx := &manager.Tx{}
+	if err := fn(tx); nil != err {
+		return errors.Wrap(err, "entity transaction")
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.checkWritableLocked(); nil != err {
+		return err
+	}
+
+	var err error
+	var order []string
+	states := make(map[string]*tdtl.Collect)
+	tx.Each(func(eid string, pds []*v1.PatchData, deleted bool) {
+		if nil != err {
+			return
+		}
+
+		state, has := states[eid]
+		if !has {
+			if state, err = f.loadLocked(eid); nil != err {
+				return
+			}
+			order = append(order, eid)
+		} else if nil == state {
+			err = errors.Wrapf(xerrors.ErrEntityNotFound, "entity %s deleted", eid)
+			return
+		}
+
+		if deleted {
+			states[eid] = nil
+			return
+		}
+		states[eid] = state
+		err = applyPatches(state, pds)
+	})
+	if nil != err {
+		return errors.Wrap(err, "entity transaction")
+	}
+
+	for _, eid := range order {
+		if state := states[eid]; nil == state {
+			f.deleteLocked(eid)
+		} else if _, err = f.commitLocked(eid, state); nil != err {
+			return errors.Wrap(err, "entity transaction")
+		}
+	}
+	return nil
+}
+
+func (f *Fake) DiffEntity(ctx context.Context, id string, against *manager.BaseRet) (*manager.EntityDiff, error) {
+	current, err := f.GetEntity(ctx, &manager.Base{ID: id})
+	if nil != err {
+		return nil, errors.Wrap(err, "diff entity")
+	}
+	return manager.NewEntityDiff(against, current), nil
+}
+
+// DumpEntity dumps of the fake carry no etag, states have none.
+func (f *Fake) DumpEntity(ctx context.Context, id string) (*manager.EntityDump, error) {
+	identity, _ := types.IdentityFrom(ctx)
+	if !identity.HasRole(types.RoleAdmin) {
+		return nil, errors.Wrap(xerrors.ErrPermissionDenied, "dump entity")
+	}
+
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	raw, has := f.entities[id]
+	if !has {
+		return nil, errors.Wrapf(xerrors.ErrEntityNotFound, "dump entity %s", id)
+	}
+
+	var err error
+	dump := &manager.EntityDump{ID: id, Raw: append([]byte{}, raw...), Mappers: make(map[string]string)}
+	if dump.Base, err = manager.DecodeBase(raw); nil != err {
+		return nil, errors.Wrap(xerrors.ErrPermissionDenied, "dump entity, owner unknown")
+	} else if !identity.Owns(dump.Base.Owner) {
+		return nil, errors.Wrap(xerrors.ErrPermissionDenied, "dump entity")
+	}
+	for key, expr := range f.exprs {
+		if expr.EntityID == id && expr.Owner == dump.Base.Owner {
+			bytes, err := expr.Encode()
+			if nil != err {
+				return nil, errors.Wrap(err, "dump entity")
+			}
+			dump.Mappers[key] = string(bytes)
+		}
+	}
+	return dump, nil
+}
+
+func (f *Fake) AppendMapper(ctx context.Context, mp *mapper.Mapper) error {
+	if err := f.checkMapper(ctx, mp); nil != err {
+		return err
+	}
+	return errors.Wrap(f.putExprs(manager.MapperExprs(*mp)), "append mapper")
+}
+
+func (f *Fake) AppendMappers(ctx context.Context, mps []*mapper.Mapper, mode manager.AppendMode) ([]manager.MapperResult, error) {
+	failed := false
+	results := make([]manager.MapperResult, len(mps))
+	for index, mp := range mps {
+		results[index] = manager.MapperResult{ID: mp.ID, EntityID: mp.EntityID, Name: mp.Name, Err: f.checkMapper(ctx, mp)}
+		failed = failed || nil != results[index].Err
+	}
+
+	if mode == manager.AppendAllOrNothing && failed {
+		for index := range results {
+			if nil == results[index].Err {
+				results[index].Err = xerrors.ErrBatchAborted
+			}
+		}
+		return results, errors.Wrap(xerrors.ErrBatchAborted, "append mappers")
+	}
+
+	for index, mp := range mps {
+		if nil == results[index].Err {
+			results[index].Err = errors.Wrap(f.putExprs(manager.MapperExprs(*mp)), "append mapper")
+		}
+	}
+	return results, nil
+}
+
+func (f *Fake) checkMapper(ctx context.Context, mp *mapper.Mapper) error {
+	if err := manager.CheckMapper(mp); nil != err {
+		return errors.Wrap(err, "check mapper")
+	}
+
+	if len(mp.AppliesToTypes) > 0 {
+		en, err := f.GetEntity(ctx, &manager.Base{ID: mp.EntityID})
+		if nil != err {
+			return errors.Wrap(err, "check mapper type")
+		}
+
+		matched := false
+		for _, typ := range mp.AppliesToTypes {
+			matched = matched || typ == en.Type
+		}
+		if !matched {
+			return errors.Wrapf(xerrors.ErrMapperTypeMismatch,
+				"mapper %s applies to %v, entity %s type %q", mp.ID, mp.AppliesToTypes, en.ID, en.Type)
+		}
+	}
+	return nil
+}
+
+func (f *Fake) AppendMapperZ(ctx context.Context, mp *mapper.Mapper) error {
+	return errors.Wrap(f.putExprs(manager.MapperExprs(*mp)), "append mapper")
+}
+
+func (f *Fake) SetMapperEnabled(ctx context.Context, en *manager.Base, name string, enabled bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.checkWritableLocked(); nil != err {
+		return err
+	}
+
+	found := false
+	for key, expr := range f.exprs {
+		if expr.EntityID == en.ID && expr.Owner == en.Owner && expr.Name == name {
+			found = true
+			expr.Disabled = !enabled
+			f.exprs[key] = expr
+		}
+	}
+
+	if !found {
+		return errors.Wrap(xerrors.ErrExpressionNotFound, "set mapper enabled")
+	}
+	return nil
+}
+
+func (f *Fake) DeleteMapperByName(ctx context.Context, entityID, name string) error {
+	if err := f.checkWritable(); nil != err {
+		return err
+	}
+
+	base, err := f.GetEntity(ctx, &manager.Base{ID: entityID})
+	if nil != err {
+		return errors.Wrap(err, "delete mapper")
+	}
+
+	if f.removeExprs(func(expr repository.Expression) bool {
+		return expr.EntityID == entityID && expr.Owner == base.Owner && expr.Name == name
+	}) == 0 {
+		return errors.Wrap(xerrors.ErrMapperNotFound, "delete mapper")
+	}
+	return nil
+}
+
+func (f *Fake) CreateGroup(ctx context.Context, group *repository.Group) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.checkWritableLocked(); nil != err {
+		return err
+	} else if group.ID == "" {
+		return errors.Wrap(xerrors.ErrInvalidParam, "create group, empty group id")
+	}
+
+	cp := *group
+	f.groups[group.ID] = &cp
+	return nil
+}
+
+func (f *Fake) GetGroup(ctx context.Context, gid string) (*repository.Group, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	group, has := f.groups[gid]
+	if !has {
+		return nil, errors.Wrapf(xerrors.ErrResourceNotFound, "get group %s", gid)
+	}
+
+	cp := *group
+	return &cp, nil
+}
+
+func (f *Fake) ListGroups(ctx context.Context, owner string) ([]*repository.Group, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	groups := make([]*repository.Group, 0)
+	for _, group := range f.groups {
+		if group.Owner == owner {
+			cp := *group
+			groups = append(groups, &cp)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups, nil
+}
+
+func (f *Fake) DeleteGroup(ctx context.Context, gid string) error {
+	members, err := f.ListGroupMembers(ctx, gid)
+	if nil != err {
+		return errors.Wrap(err, "delete group")
+	}
+
+	for _, eid := range members {
+		if err = f.RemoveFromGroup(ctx, gid, &manager.Base{ID: eid}); nil != err {
+			return errors.Wrap(err, "delete group")
+		}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err = f.checkWritableLocked(); nil != err {
+		return err
+	} else if _, has := f.groups[gid]; !has {
+		return errors.Wrapf(xerrors.ErrResourceNotFound, "delete group %s", gid)
+	}
+
+	delete(f.groups, gid)
+	delete(f.members, gid)
+	return nil
+}
+
+func (f *Fake) AddToGroup(ctx context.Context, gid string, en *manager.Base) error {
+	if err := f.checkWritable(); nil != err {
+		return err
+	} else if _, err = f.GetGroup(ctx, gid); nil != err {
+		return errors.Wrap(err, "add to group")
+	} else if exists, _ := f.EntitiesExist(ctx, []string{en.ID}); !exists[en.ID] {
+		return errors.Wrap(xerrors.ErrEntityNotFound, "add to group")
+	}
+
+	f.lock.Lock()
+	if f.members[gid] == nil {
+		f.members[gid] = make(map[string]bool)
+	}
+	f.members[gid][en.ID] = true
+	f.lock.Unlock()
+
+	return errors.Wrap(f.syncMemberOf(ctx, en.ID), "add to group")
+}
+
+func (f *Fake) RemoveFromGroup(ctx context.Context, gid string, en *manager.Base) error {
+	if err := f.checkWritable(); nil != err {
+		return err
+	}
+
+	f.lock.Lock()
+	delete(f.members[gid], en.ID)
+	f.lock.Unlock()
+
+	return errors.Wrap(f.syncMemberOf(ctx, en.ID), "remove from group")
+}
+
+func (f *Fake) ListGroupMembers(ctx context.Context, gid string) ([]string, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	ids := make([]string, 0, len(f.members[gid]))
+	for eid := range f.members[gid] {
+		ids = append(ids, eid)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (f *Fake) ListEntityGroups(ctx context.Context, eid string) ([]string, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	gids := make([]string, 0)
+	for gid, members := range f.members {
+		if members[eid] {
+			gids = append(gids, gid)
+		}
+	}
+	sort.Strings(gids)
+	return gids, nil
+}
+
+// AppendExpression store expressions as given, paths not qualified as the real manager does.
+func (f *Fake) AppendExpression(ctx context.Context, exprs []repository.Expression) error {
+	for _, expr := range exprs {
+		if expr.Expression == "" {
+			return errors.Wrap(xerrors.ErrInvalidRequest, "invalid expression")
+		} else if err := expression.Validate(expr); nil != err {
+			return errors.Wrap(err, "invalid expression")
+		}
+	}
+	return errors.Wrap(f.putExprs(exprs), "append expression")
+}
+
+func (f *Fake) RemoveExpression(ctx context.Context, exprs []repository.Expression) error {
+	if err := f.checkWritable(); nil != err {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for index := range exprs {
+		key, _ := exprs[index].EncodeKey()
+		delete(f.exprs, string(key))
+	}
+	return nil
+}
+
+func (f *Fake) GetExpression(ctx context.Context, expr repository.Expression) (*repository.Expression, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	key, _ := expr.EncodeKey()
+	item, has := f.exprs[string(key)]
+	if !has {
+		return nil, errors.Wrapf(xerrors.ErrResourceNotFound, "get expression %s", key)
+	}
+	return &item, nil
+}
+
+func (f *Fake) ListExpression(ctx context.Context, en *manager.Base) ([]*repository.Expression, error) {
+	exprs, _, err := f.ListExpressionCached(ctx, en)
+	return exprs, err
+}
+
+// ListExpressionCached never stale, the fake never loses its expressions.
+func (f *Fake) ListExpressionCached(ctx context.Context, en *manager.Base) ([]*repository.Expression, bool, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	exprs := make([]*repository.Expression, 0)
+	for _, expr := range f.exprs {
+		if expr.EntityID == en.ID && expr.Owner == en.Owner {
+			item := expr
+			exprs = append(exprs, &item)
+		}
+	}
+	sort.Slice(exprs, func(i, j int) bool { return exprs[i].ID < exprs[j].ID })
+	return exprs, false, nil
+}
+
+func (f *Fake) GetProvenance(ctx context.Context, en *manager.Base) (map[string]manager.Provenance, error) {
+	exprs, err := f.ListExpression(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "get provenance")
+	}
+
+	provenances := make(map[string]manager.Provenance)
+	for _, expr := range exprs {
+		if path := strings.TrimPrefix(expr.Path, "properties."); path != "" {
+			provenances[path] = manager.Provenance{Source: manager.ProvenanceDerived, Mapper: expr.Name}
+		}
+	}
+	return provenances, nil
+}
+
+func (f *Fake) GetDependencyGraph(ctx context.Context, id string) (*manager.DepGraph, error) {
+	base, err := f.GetEntity(ctx, &manager.Base{ID: id})
+	if nil != err {
+		return nil, errors.Wrap(err, "get dependency graph")
+	}
+
+	exprs, err := f.ListExpression(ctx, &manager.Base{ID: id, Owner: base.Owner})
+	if nil != err {
+		return nil, errors.Wrap(err, "get dependency graph")
+	}
+	return manager.NewDepGraph(id, exprs), nil
+}
+
+// GetQuotaUsage usage of entities owned by the tenant.
+func (f *Fake) GetQuotaUsage(ctx context.Context, tenant string) (*repository.QuotaUsage, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	usage := &repository.QuotaUsage{Tenant: tenant}
+	for _, bytes := range f.entities {
+		if tdtl.New(bytes).Get("owner").String() == tenant {
+			usage.Count++
+			usage.Size += int64(len(bytes))
+		}
+	}
+	return usage, nil
+}
+
+func (f *Fake) CreateSubscription(ctx context.Context, subscription *repository.Subscription) error {
+	key, err := subscription.EncodeKey()
+	if nil != err {
+		return errors.Wrap(err, "create subscription")
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	cp := *subscription
+	f.subscriptions[string(key)] = &cp
+	return nil
+}
+
+func (f *Fake) DeleteSubscription(ctx context.Context, subscription *repository.Subscription) error {
+	key, err := subscription.EncodeKey()
+	if nil != err {
+		return errors.Wrap(err, "delete subscription")
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.subscriptions, string(key))
+	return nil
+}
+
+func (f *Fake) GetSubscription(ctx context.Context, subscription *repository.Subscription) (*repository.Subscription, error) {
+	key, err := subscription.EncodeKey()
+	if nil != err {
+		return nil, errors.Wrap(err, "get subscription")
+	}
+
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	item, has := f.subscriptions[string(key)]
+	if !has {
+		return nil, errors.Wrapf(xerrors.ErrResourceNotFound, "get subscription %s", key)
+	}
+
+	cp := *item
+	return &cp, nil
+}
+
+func (f *Fake) CreateMirror(ctx context.Context, sourceID, sourceProp, targetID, targetProp string, mode manager.MirrorMode) (*manager.Mirror, error) {
+	mirror := &manager.Mirror{
+		ID:         util.UUID("mirror"),
+		SourceID:   sourceID,
+		SourceProp: sourceProp,
+		TargetID:   targetID,
+		TargetProp: targetProp,
+		Mode:       mode,
+	}
+	if err := mirror.Validate(); nil != err {
+		return nil, errors.Wrap(err, "create mirror")
+	}
+
+	source, err := f.GetEntity(ctx, &manager.Base{ID: sourceID})
+	if nil != err {
+		return nil, errors.Wrap(err, "create mirror")
+	}
+	target, err := f.GetEntity(ctx, &manager.Base{ID: targetID})
+	if nil != err {
+		return nil, errors.Wrap(err, "create mirror")
+	} else if source.Owner != target.Owner {
+		return nil, errors.Wrap(xerrors.ErrInvalidParam, "create mirror, entities of different owners")
+	}
+
+	mirror.Owner = source.Owner
+	if pd := mirror.SyncPatch(source); pd != nil {
+		if _, _, err = f.PatchEntity(ctx, &manager.Base{ID: targetID}, []*v1.PatchData{pd}); nil != err {
+			return nil, errors.Wrap(err, "create mirror, sync value")
+		}
+	}
+	for _, sub := range mirror.Subscriptions() {
+		if err = f.CreateSubscription(ctx, sub); nil != err {
+			return nil, errors.Wrap(err, "create mirror")
+		}
+	}
+	return mirror, nil
+}
+
+func (f *Fake) checkWritable() error {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.checkWritableLocked()
+}
+
+func (f *Fake) checkWritableLocked() error {
+	if f.maintenance {
+		return errors.Wrap(xerrors.ErrMaintenanceMode, "reject write")
+	}
+	return nil
+}
+
+// validate run validation hooks on the changes, as the real manager does before each write.
+func (f *Fake) validate(ctx context.Context, id string, pds []*v1.PatchData) error {
+	f.lock.RLock()
+	hooks := f.hooks
+	f.lock.RUnlock()
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	changes := make(map[string]interface{}, len(pds))
+	for _, pd := range pds {
+		var val interface{}
+		if len(pd.Value) > 0 {
+			if err := json.Unmarshal(pd.Value, &val); nil != err {
+				return errors.Wrap(err, "decode property changes")
+			}
+		}
+		changes[pd.Path] = val
+	}
+
+	for _, hook := range hooks {
+		if err := hook.Validate(ctx, id, changes); nil != err {
+			return errors.Wrap(xerrors.ErrPropertyRejected, err.Error())
+		}
+	}
+	return nil
+}
+
+func (f *Fake) loadLocked(id string) (*tdtl.Collect, error) {
+	bytes, has := f.entities[id]
+	if !has {
+		return nil, errors.Wrapf(xerrors.ErrEntityNotFound, "entity %s", id)
+	}
+	return tdtl.New(append([]byte{}, bytes...)), nil
+}
+
+// decodeLocked decode entity state, upgraded by registered migrations.
+func (f *Fake) decodeLocked(bytes []byte) (*manager.BaseRet, error) {
+	ret, err := manager.DecodeBase(bytes)
+	if nil != err {
+		return nil, errors.Wrap(err, "decode entity")
+	}
+
+	for {
+		fn, has := f.migrations[migrationKey{typ: ret.Type, from: ret.SchemaVersion}]
+		if !has {
+			return ret, nil
+		} else if err = fn(ret); nil != err {
+			return nil, errors.Wrapf(err, "migrate entity %s from version %d", ret.ID, ret.SchemaVersion)
+		}
+		ret.SchemaVersion++
+	}
+}
+
+// commitLocked write entity state with version bumped, then notify watchers.
+func (f *Fake) commitLocked(id string, state *tdtl.Collect) (*manager.BaseRet, error) {
+	version, _ := strconv.ParseInt(state.Get("version").String(), 10, 64)
+	state.Set("version", tdtl.NewInt64(version+1))
+	state.Set("last_time", tdtl.NewInt64(time.Now().UnixNano()/1e6))
+	if err := state.GetError(); nil != err {
+		return nil, errors.Wrapf(err, "write entity %s", id)
+	}
+
+	ret, err := f.decodeLocked(state.Raw())
+	if nil != err {
+		return nil, err
+	}
+
+	f.entities[id] = state.Raw()
+	for _, ch := range f.watchers[id] {
+		select {
+		case ch <- ret:
+		default:
+		}
+	}
+	return ret, nil
+}
+
+func (f *Fake) deleteLocked(id string) {
+	delete(f.entities, id)
+	delete(f.snapshots, id)
+	for _, members := range f.members {
+		delete(members, id)
+	}
+	for _, ch := range f.watchers[id] {
+		close(ch)
+	}
+	delete(f.watchers, id)
+	for key, sub := range f.subscriptions {
+		if sub.Mode == manager.SubscriptionModeMirror && (sub.SourceEntityID == id || sub.Target == id) {
+			delete(f.subscriptions, key)
+		}
+	}
+}
+
+// snapshotLocked keep current state of the entity before a write, latest first.
+func (f *Fake) snapshotLocked(id string) {
+	ret, err := manager.DecodeBase(f.entities[id])
+	if nil != err {
+		return
+	}
+
+	snapshots := append([]*manager.Snapshot{{
+		ID:        fmt.Sprintf("%s-%d", id, ret.Version),
+		Version:   ret.Version,
+		Timestamp: ret.LastTime,
+		Entity:    ret,
+	}}, f.snapshots[id]...)
+	if len(snapshots) > defaultSnapshotLimit {
+		snapshots = snapshots[:defaultSnapshotLimit]
+	}
+	f.snapshots[id] = snapshots
+}
+
+func (f *Fake) putExprs(exprs []repository.Expression) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.checkWritableLocked(); nil != err {
+		return err
+	}
+
+	for index := range exprs {
+		if err := exprs[index].GenKey(); nil != err {
+			return errors.Wrap(err, "put expression")
+		}
+		f.exprs[exprs[index].ID] = exprs[index]
+	}
+	return nil
+}
+
+// removeExprs delete expressions matching the filter, returns count of deleted.
+func (f *Fake) removeExprs(filter func(repository.Expression) bool) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	count := 0
+	for key, expr := range f.exprs {
+		if filter(expr) {
+			delete(f.exprs, key)
+			count++
+		}
+	}
+	return count
+}
+
+// ids returns sorted ids of entities matching the filter, all entities if filter nil.
+func (f *Fake) ids(filter func(*manager.BaseRet) bool) []string {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	ids := make([]string, 0, len(f.entities))
+	for id, bytes := range f.entities {
+		if nil != filter {
+			ret, err := manager.DecodeBase(bytes)
+			if nil != err || !filter(ret) {
+				continue
+			}
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// match returns ids of entities matching source, owner and conditions of the search request.
+func (f *Fake) match(query *v1.SearchRequest) ([]string, error) {
+	for _, cond := range query.Condition {
+		if cond.Operator != "$eq" && cond.Operator != "$neq" {
+			return nil, errors.Wrapf(xerrors.ErrInvalidParam, "operator %s not supported", cond.Operator)
+		}
+	}
+
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	var ids []string
+	for id, bytes := range f.entities {
+		state := tdtl.New(bytes)
+		matched := (query.Source == "" || state.Get("source").String() == query.Source) &&
+			(query.Owner == "" || state.Get("owner").String() == query.Owner)
+		for _, cond := range query.Condition {
+			var expect, actual interface{}
+			if nil != cond.Value {
+				expect = cond.Value.AsInterface()
+			}
+			if val := state.Get(cond.Field); val.Type() != tdtl.Null && val.Type() != tdtl.Undefined {
+				json.Unmarshal(val.Raw(), &actual) //nolint
+			}
+			equal := fmt.Sprint(expect) == fmt.Sprint(actual)
+			matched = matched && equal == (cond.Operator == "$eq")
+		}
+
+		if matched {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// syncMemberOf write entity groups into the memberOf property.
+func (f *Fake) syncMemberOf(ctx context.Context, eid string) error {
+	gids, _ := f.ListEntityGroups(ctx, eid)
+	bytes, _ := json.Marshal(gids)
+	_, _, err := f.PatchEntity(ctx, &manager.Base{ID: eid}, []*v1.PatchData{{
+		Path:     "properties." + manager.FieldMemberOf,
+		Operator: xjson.OpReplace.String(),
+		Value:    bytes,
+	}})
+	return errors.Wrap(err, "sync entity groups")
+}
+
+// applyPatches apply patches to the state as the runtime does.
+func applyPatches(state *tdtl.Collect, pds []*v1.PatchData) error {
+	for _, pd := range pds {
+		switch xjson.NewPatchOp(pd.Operator) {
+		case xjson.OpReplace:
+			state.Set(pd.Path, tdtl.New(pd.Value))
+		case xjson.OpAdd:
+			state.Append(pd.Path, tdtl.New(pd.Value))
+		case xjson.OpRemove:
+			state.Del(pd.Path)
+		case xjson.OpMerge:
+			origin := state.Get(pd.Path)
+			if origin.Type() == tdtl.Null || origin.Type() == tdtl.Undefined {
+				state.Set(pd.Path, tdtl.New(pd.Value))
+				continue
+			} else if origin.Type() != tdtl.Object {
+				return errors.Wrapf(xerrors.ErrPatchTypeInvalid, "merge path %s", pd.Path)
+			}
+
+			merged, err := jsonpatch.MergePatch(origin.Raw(), pd.Value)
+			if nil != err {
+				return errors.Wrapf(err, "merge path %s", pd.Path)
+			}
+			state.Set(pd.Path, tdtl.New(merged))
+		default:
+			return errors.Wrapf(xerrors.ErrJSONPatchReservedOp, "path %s", pd.Path)
+		}
+
+		if err := state.GetError(); nil != err {
+			return errors.Wrapf(err, "path %s", pd.Path)
+		}
+	}
+	return nil
+}
+
+// replacePatches returns patches replacing values under the path.
+func replacePatches(path string, values map[string]interface{}) ([]*v1.PatchData, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pds := make([]*v1.PatchData, 0, len(values))
+	for _, key := range keys {
+		bytes, err := json.Marshal(values[key])
+		if nil != err {
+			return nil, errors.Wrapf(err, "encode %s", key)
+		}
+		pds = append(pds, &v1.PatchData{
+			Path:     path + "." + key,
+			Operator: xjson.OpReplace.String(),
+			Value:    bytes,
+		})
+	}
+	return pds, nil
+}
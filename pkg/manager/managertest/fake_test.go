@@ -0,0 +1,218 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managertest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/manager"
+	"github.com/tkeel-io/core/pkg/mapper"
+	"github.com/tkeel-io/core/pkg/repository"
+)
+
+func TestFake_Entity(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	_, err := fake.GetEntity(ctx, &manager.Base{ID: "device123"})
+	assert.ErrorIs(t, err, xerrors.ErrEntityNotFound)
+
+	ret, err := fake.CreateEntity(ctx, &manager.Base{ID: "device123", Type: "DEVICE", Owner: "admin", Properties: []byte(`{"temp":20}`)})
+	assert.Nil(t, err)
+	assert.Equal(t, "DEVICE", ret.Type)
+	_, err = fake.CreateEntity(ctx, &manager.Base{ID: "device123"})
+	assert.ErrorIs(t, err, xerrors.ErrEntityAleadyExists)
+
+	pds := []*v1.PatchData{{Path: "properties.temp", Operator: "replace", Value: []byte(`25`)}}
+	patched, _, err := fake.PatchEntity(ctx, &manager.Base{ID: "device123"}, pds, manager.NewVersionOption(ret.Version))
+	assert.Nil(t, err)
+	assert.Equal(t, float64(25), patched.Properties["temp"])
+	_, _, err = fake.PatchEntity(ctx, &manager.Base{ID: "device123"}, pds, manager.NewVersionOption(ret.Version))
+	assert.ErrorIs(t, err, xerrors.ErrEntityConflict)
+
+	snapshots, err := fake.ListSnapshots(ctx, "device123")
+	assert.Nil(t, err)
+	assert.Len(t, snapshots, 1)
+	restored, err := fake.RestoreSnapshot(ctx, "device123", snapshots[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(20), restored.Properties["temp"])
+
+	fake.SetMaintenanceMode(true)
+	assert.ErrorIs(t, fake.DeleteEntity(ctx, &manager.Base{ID: "device123"}), xerrors.ErrMaintenanceMode)
+	fake.SetMaintenanceMode(false)
+	assert.Nil(t, fake.DeleteEntity(ctx, &manager.Base{ID: "device123"}))
+	assert.ErrorIs(t, fake.DeleteEntity(ctx, &manager.Base{ID: "device123"}), xerrors.ErrEntityNotFound)
+}
+
+func TestFake_Transaction(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	_, err := fake.CreateEntity(ctx, &manager.Base{ID: "device1", Properties: []byte(`{}`)})
+	assert.Nil(t, err)
+
+	// nothing written if any staged write fails.
+	err = fake.Transaction(ctx, func(tx *manager.Tx) error {
+		assert.Nil(t, tx.Set(&manager.Base{ID: "device1"}, map[string]interface{}{"temp": 1}))
+		return tx.Set(&manager.Base{ID: "device2"}, map[string]interface{}{"temp": 1})
+	})
+	assert.ErrorIs(t, err, xerrors.ErrEntityNotFound)
+	ret, err := fake.GetEntity(ctx, &manager.Base{ID: "device1"})
+	assert.Nil(t, err)
+	assert.Nil(t, ret.Properties["temp"])
+}
+
+func TestFake_Group(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	_, err := fake.CreateEntity(ctx, &manager.Base{ID: "device1", Properties: []byte(`{}`)})
+	assert.Nil(t, err)
+
+	assert.ErrorIs(t, fake.AddToGroup(ctx, "group1", &manager.Base{ID: "device1"}), xerrors.ErrResourceNotFound)
+	assert.Nil(t, fake.CreateGroup(ctx, &repository.Group{ID: "group1"}))
+	assert.ErrorIs(t, fake.AddToGroup(ctx, "group1", &manager.Base{ID: "device2"}), xerrors.ErrEntityNotFound)
+	assert.Nil(t, fake.AddToGroup(ctx, "group1", &manager.Base{ID: "device1"}))
+
+	ret, err := fake.GetEntity(ctx, &manager.Base{ID: "device1"})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"group1"}, ret.Properties[manager.FieldMemberOf])
+
+	assert.Nil(t, fake.DeleteGroup(ctx, "group1"))
+	gids, err := fake.ListEntityGroups(ctx, "device1")
+	assert.Nil(t, err)
+	assert.Empty(t, gids)
+}
+
+func TestFake_Mirror(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	_, err := fake.CreateEntity(ctx, &manager.Base{ID: "device1", Owner: "admin", Properties: []byte(`{"metrics":{"temp":20}}`)})
+	assert.Nil(t, err)
+	_, err = fake.CreateEntity(ctx, &manager.Base{ID: "device2", Owner: "admin", Properties: []byte(`{}`)})
+	assert.Nil(t, err)
+
+	_, err = fake.CreateMirror(ctx, "device1", "metrics.temp", "device1", "metrics.temp", manager.MirrorOneWay)
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+	_, err = fake.CreateMirror(ctx, "device1", "metrics.temp", "device2", "temp", "sideways")
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+
+	mirror, err := fake.CreateMirror(ctx, "device1", "metrics.temp", "device2", "temp", manager.MirrorBidirectional)
+	assert.Nil(t, err)
+	ret, err := fake.GetEntity(ctx, &manager.Base{ID: "device2"})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(20), ret.Properties["temp"])
+
+	subs := mirror.Subscriptions()
+	assert.Len(t, subs, 2)
+	assert.Equal(t, "device2", subs[1].SourceEntityID)
+	assert.Equal(t, "properties.metrics.temp", subs[1].TargetPath)
+	_, err = fake.GetSubscription(ctx, subs[1])
+	assert.Nil(t, err)
+
+	// mirrors removed with either entity.
+	assert.Nil(t, fake.DeleteEntity(ctx, &manager.Base{ID: "device2"}))
+	for _, sub := range subs {
+		_, err = fake.GetSubscription(ctx, sub)
+		assert.ErrorIs(t, err, xerrors.ErrResourceNotFound)
+	}
+}
+
+func TestFake_CloneEntity(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	_, err := fake.CreateEntity(ctx, &manager.Base{ID: "device123", Type: "DEVICE", Owner: "admin",
+		Scheme: []byte(`{"temp":{"type":"number"}}`), Properties: []byte(`{"temp":20,"offset":1}`)})
+	assert.Nil(t, err)
+	assert.Nil(t, fake.AppendMapper(ctx, &mapper.Mapper{
+		Name:     "calibrate",
+		Owner:    "admin",
+		EntityID: "device123",
+		TQL:      "insert into device123 select device123.temp + device123.offset as calibrated, device234.temp as peer",
+	}))
+
+	ret, err := fake.CloneEntity(ctx, "device123", &manager.Base{ID: "device123-a"}, manager.CloneOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "DEVICE", ret.Type)
+	assert.Equal(t, float64(20), ret.Properties["temp"])
+	exprs, err := fake.ListExpression(ctx, &manager.Base{ID: "device123-a", Owner: "admin"})
+	assert.Nil(t, err)
+	assert.Len(t, exprs, 2)
+	assert.Equal(t, "device123.properties.temp + device123.properties.offset", strings.TrimSpace(exprs[0].Expression))
+
+	// self-references rebound to the clone, external references left intact.
+	_, err = fake.CloneEntity(ctx, "device123", &manager.Base{ID: "device123-b"}, manager.CloneOptions{RebindMappers: true})
+	assert.Nil(t, err)
+	exprs, err = fake.ListExpression(ctx, &manager.Base{ID: "device123-b", Owner: "admin"})
+	assert.Nil(t, err)
+	assert.Len(t, exprs, 2)
+	assert.Equal(t, "device123-b.properties.temp + device123-b.properties.offset", strings.TrimSpace(exprs[0].Expression))
+	assert.Equal(t, "device234.properties.temp", strings.TrimSpace(exprs[1].Expression))
+
+	_, err = fake.CloneEntity(ctx, "device123", &manager.Base{ID: "device123-b"}, manager.CloneOptions{})
+	assert.ErrorIs(t, err, xerrors.ErrEntityAleadyExists)
+}
+
+func TestFake_Attributes(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	_, err := fake.CreateEntity(ctx, &manager.Base{ID: "device1", Owner: "admin", Properties: []byte(`{}`)})
+	assert.Nil(t, err)
+
+	attrs, err := fake.GetAttributes(ctx, &manager.Base{ID: "device1"})
+	assert.Nil(t, err)
+	assert.Empty(t, attrs)
+
+	ret, err := fake.SetAttributes(ctx, &manager.Base{ID: "device1"}, map[string]string{"vendor": "acme", "rack": "a1"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"vendor": "acme", "rack": "a1"}, ret.Attributes)
+	assert.Empty(t, ret.Properties)
+
+	// empty values remove attributes.
+	_, err = fake.SetAttributes(ctx, &manager.Base{ID: "device1"}, map[string]string{"rack": ""})
+	assert.Nil(t, err)
+	attrs, err = fake.GetAttributes(ctx, &manager.Base{ID: "device1"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"vendor": "acme"}, attrs)
+
+	_, err = fake.SetAttributes(ctx, &manager.Base{ID: "device1"}, map[string]string{"blob": strings.Repeat("x", 5000)})
+	assert.ErrorIs(t, err, xerrors.ErrAttributesTooLarge)
+}
+
+func TestFake_DeleteProperty(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	_, err := fake.CreateEntity(ctx, &manager.Base{ID: "device1", Owner: "admin",
+		Properties: []byte(`{"metrics":{"cpu":0.3,"mem":0.5},"temp":20}`)})
+	assert.Nil(t, err)
+
+	ret, err := fake.DeleteProperty(ctx, &manager.Base{ID: "device1"}, "metrics.cpu", false)
+	assert.Nil(t, err)
+	assert.True(t, ret.GetProperty("metrics.cpu").IsNil())
+	assert.False(t, ret.GetProperty("metrics.mem").IsNil())
+	assert.False(t, ret.GetProperty("temp").IsNil())
+
+	_, err = fake.DeleteProperty(ctx, &manager.Base{ID: "device1"}, "metrics.cpu", false)
+	assert.ErrorIs(t, err, xerrors.ErrPropertyNotFound)
+
+	ret, err = fake.DeleteProperty(ctx, &manager.Base{ID: "device1"}, "metrics.cpu", true)
+	assert.Nil(t, err)
+	assert.False(t, ret.GetProperty("temp").IsNil())
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/mapper"
+	"github.com/tkeel-io/core/pkg/mapper/expression"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/kit/log"
+)
+
+const (
+	defaultMapperPageLimit = 100
+	maxMapperPageLimit     = 1000
+)
+
+// AppendMode decides how AppendMappers handles mappers failed.
+type AppendMode int
+
+const (
+	// AppendBestEffort append mappers independently, mappers failed skipped.
+	AppendBestEffort AppendMode = iota
+	// AppendAllOrNothing append mappers only if all of them valid,
+	// mappers appended removed if any mapper failed to append.
+	AppendAllOrNothing
+)
+
+// MapperResult result of a mapper appended in batch, Err nil if appended.
+type MapperResult struct {
+	ID       string `json:"id"`
+	EntityID string `json:"entity_id"`
+	Name     string `json:"name"`
+	Err      error  `json:"-"`
+}
+
+// AppendMappers validate and append mappers in batch, returns results in order of mappers.
+// mappers of AppendAllOrNothing mode not failed reported ErrBatchAborted if any mapper failed.
+func (m *apiManager) AppendMappers(ctx context.Context, mps []*mapper.Mapper, mode AppendMode) ([]MapperResult, error) {
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("append mappers", logf.Error(err))
+		return nil, err
+	}
+
+	results := make([]MapperResult, len(mps))
+	exprs := make([][]repository.Expression, len(mps))
+	for index, mp := range mps {
+		exprs[index], results[index].Err = m.validateMapper(ctx, mp)
+		results[index].ID, results[index].EntityID, results[index].Name = mp.ID, mp.EntityID, mp.Name
+	}
+
+	if mode == AppendAllOrNothing && abortBatch(results) {
+		log.L().Warn("append mappers, invalid mappers", logf.Count(int64(len(mps))))
+		return results, errors.Wrap(xerrors.ErrBatchAborted, "append mappers")
+	}
+
+	appended := make([]repository.Expression, 0)
+	for index := range mps {
+		if nil != results[index].Err {
+			continue
+		} else if results[index].Err = m.appendExpression(ctx, exprs[index]); nil != results[index].Err {
+			log.L().Error("append mappers", logf.ID(mps[index].ID),
+				logf.Eid(mps[index].EntityID), logf.Error(results[index].Err))
+			if mode == AppendAllOrNothing {
+				break
+			}
+			continue
+		}
+		appended = append(appended, exprs[index]...)
+	}
+
+	if mode == AppendAllOrNothing && abortBatch(results) {
+		if err := m.RemoveExpression(ctx, appended); nil != err {
+			log.L().Error("append mappers, remove appended mappers", logf.Error(err))
+		}
+		return results, errors.Wrap(xerrors.ErrBatchAborted, "append mappers")
+	}
+	return results, nil
+}
+
+// validateMapper check mapper, returns expressions of the mapper.
+func (m *apiManager) validateMapper(ctx context.Context, mp *mapper.Mapper) ([]repository.Expression, error) {
+	if err := CheckMapper(mp); nil != err {
+		return nil, errors.Wrap(err, "check mapper")
+	} else if err = m.checkMapperType(ctx, mp); nil != err {
+		return nil, err
+	}
+
+	exprs := MapperExprs(*mp)
+	for _, expr := range exprs {
+		if err := expression.Validate(expr); nil != err {
+			return nil, errors.Wrap(err, "invalid expression")
+		}
+	}
+	return exprs, nil
+}
+
+// abortBatch reports whether any result failed, marks results not failed ErrBatchAborted if so.
+func abortBatch(results []MapperResult) bool {
+	failed := false
+	for index := range results {
+		failed = failed || (nil != results[index].Err && !errors.Is(results[index].Err, xerrors.ErrBatchAborted))
+	}
+
+	if failed {
+		for index := range results {
+			if nil == results[index].Err {
+				results[index].Err = xerrors.ErrBatchAborted
+			}
+		}
+	}
+	return failed
+}
+
+// MapperRef mapper attached to an entity.
+type MapperRef struct {
+	EntityID string `json:"entity_id"`
+	Owner    string `json:"owner"`
+	Name     string `json:"name"`
+	TQL      string `json:"tql"`
+}
+
+// ListAllMappers returns mappers of all entities page by page, pass the returned token to fetch the next page,
+// an empty token means no more pages. limit counts expressions of mappers, a page never splits an entity.
+func (m *apiManager) ListAllMappers(ctx context.Context, pageToken string, limit int) ([]MapperRef, string, error) {
+	from, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if nil != err || (len(from) > 0 && !strings.HasPrefix(string(from), repository.ExprPrefix+"/")) {
+		return nil, "", errors.Wrapf(xerrors.ErrInvalidParam, "list all mappers, page token %q", pageToken)
+	}
+
+	if limit <= 0 {
+		limit = defaultMapperPageLimit
+	} else if limit > maxMapperPageLimit {
+		limit = maxMapperPageLimit
+	}
+
+	exprs, next, err := m.entityRepo.PageExpression(ctx, string(from), int64(limit))
+	if nil != err {
+		log.L().Error("list all mappers", logf.Error(err))
+		return nil, "", errors.Wrap(err, "list all mappers")
+	}
+
+	return mapperRefs(exprs), base64.RawURLEncoding.EncodeToString([]byte(next)), nil
+}
+
+// mapperRefs group expressions into mappers by entity and mapper name, TQL rebuilt from expressions.
+func mapperRefs(exprs []*repository.Expression) []MapperRef {
+	type mapperKey struct{ entityID, name string }
+	var (
+		keys    []mapperKey
+		selects = make(map[mapperKey][]string)
+		refs    = make(map[mapperKey]MapperRef)
+	)
+
+	for _, expr := range exprs {
+		key := mapperKey{entityID: expr.EntityID, name: expr.Name}
+		if _, ok := refs[key]; !ok {
+			keys = append(keys, key)
+			refs[key] = MapperRef{EntityID: expr.EntityID, Owner: expr.Owner, Name: expr.Name}
+		}
+		selects[key] = append(selects[key],
+			fmt.Sprintf("%s as %s", strings.TrimSpace(expr.Expression), expr.Path))
+	}
+
+	mappers := make([]MapperRef, 0, len(keys))
+	for _, key := range keys {
+		ref := refs[key]
+		ref.TQL = fmt.Sprintf("insert into %s select %s", ref.EntityID, strings.Join(selects[key], ", "))
+		mappers = append(mappers, ref)
+	}
+	return mappers
+}
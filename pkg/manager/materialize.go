@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/kit/log"
+)
+
+// Materialize load the entity into runtime from the state store, returns once runtime confirmed.
+// an entity is materialized implicitly on create, use this to pre-warm entities after node restarts.
+func (m *apiManager) Materialize(ctx context.Context, id string) error {
+	has, err := m.entityRepo.HasEntity(ctx, id)
+	if nil != err {
+		log.L().Error("materialize entity", logf.Eid(id), logf.Error(err))
+		return errors.Wrap(err, "materialize entity")
+	} else if !has {
+		return errors.Wrapf(xerrors.ErrEntityNotFound, "materialize entity %s", id)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, m.materializeTimeout)
+	defer cancel()
+	if err = m.reloadEntity(waitCtx, id, nil); nil != err {
+		if nil != waitCtx.Err() {
+			err = waitCtx.Err()
+		}
+		log.L().Error("materialize entity", logf.Eid(id), logf.Error(err))
+		return errors.Wrap(err, "materialize entity")
+	}
+
+	log.L().Info("entity materialized", logf.Eid(id))
+	return nil
+}
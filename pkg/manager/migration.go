@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+)
+
+// MigrateFunc upgrade entity of a type from one schema version to the next.
+type MigrateFunc func(*BaseRet) error
+
+type migrationKey struct {
+	typ  string
+	from int64
+}
+
+// AddMigration register migration upgrading entities of type from version `from` to `from+1`,
+// the current schema version of the type is the highest version reachable by migrations.
+func (m *apiManager) AddMigration(typ string, from int64, fn MigrateFunc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.migrations == nil {
+		m.migrations = make(map[migrationKey]MigrateFunc)
+		m.schemaVersions = make(map[string]int64)
+	}
+
+	m.migrations[migrationKey{typ: typ, from: from}] = fn
+	if m.schemaVersions[typ] < from+1 {
+		m.schemaVersions[typ] = from + 1
+	}
+}
+
+// schemaVersion returns current schema version of entity type.
+func (m *apiManager) schemaVersion(typ string) int64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.schemaVersions[typ]
+}
+
+// migrate run the migration chain in memory on entity stored with older schema version.
+// migrations run outside the manager lock, they may be slow or read entities through the manager.
+func (m *apiManager) migrate(ret *BaseRet) error {
+	if ret == nil {
+		return nil
+	}
+
+	chain, err := m.migrationChain(ret.Type, ret.SchemaVersion)
+	if nil != err {
+		return err
+	}
+
+	for _, fn := range chain {
+		if err = fn(ret); nil != err {
+			return errors.Wrapf(err, "migrate entity from version %d", ret.SchemaVersion)
+		}
+		ret.SchemaVersion++
+	}
+	return nil
+}
+
+// migrationChain returns migrations upgrading entities of type from the version to the current one.
+func (m *apiManager) migrationChain(typ string, from int64) ([]MigrateFunc, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	var chain []MigrateFunc
+	for version := from; version < m.schemaVersions[typ]; version++ {
+		fn, has := m.migrations[migrationKey{typ: typ, from: version}]
+		if !has {
+			return nil, errors.Errorf("migration missing, type: %s, version: %d", typ, version)
+		}
+		chain = append(chain, fn)
+	}
+	return chain, nil
+}
+
+// migrateStored migrate entity read from state and persist the migrated state in the background,
+// so entities are migrated once rather than on every read. persisting is pinned to the version
+// read, entities changed meanwhile are migrated again by the next read.
+func (m *apiManager) migrateStored(ret *BaseRet) error {
+	if ret == nil || ret.SchemaVersion >= m.schemaVersion(ret.Type) {
+		return nil
+	}
+
+	// migrations may change nested values in place.
+	var origin map[string]interface{}
+	bytes, err := json.Marshal(ret.Properties)
+	if nil == err {
+		err = json.Unmarshal(bytes, &origin)
+	}
+	if nil != err {
+		return errors.Wrap(err, "copy properties")
+	}
+
+	if err = m.migrate(ret); nil != err {
+		return err
+	}
+
+	pds, err := migrationPatches(origin, ret)
+	if nil != err {
+		return errors.Wrap(err, "migrate entity")
+	}
+
+	go m.persistMigration(ret.ID, ret.Owner, ret.Version, pds)
+	return nil
+}
+
+func (m *apiManager) persistMigration(id, owner string, version int64, pds []*v1.PatchData) {
+	if _, _, err := m.PatchEntity(context.Background(), &Base{ID: id, Owner: owner},
+		pds, NewVersionOption(version)); nil != err {
+		log.L().Warn("persist migrated entity", logf.Eid(id), logf.Error(err))
+	}
+}
+
+// migrationPatches returns patches turning the origin properties into the migrated entity.
+func migrationPatches(origin map[string]interface{}, migrated *BaseRet) ([]*v1.PatchData, error) {
+	diff := diffKeys(origin, migrated.Properties)
+	pds := make([]*v1.PatchData, 0, len(diff.Added)+len(diff.Changed)+len(diff.Removed)+1)
+	for _, key := range diff.Removed {
+		pds = append(pds, &v1.PatchData{Path: fieldProperties + "." + key, Operator: xjson.OpRemove.String()})
+	}
+	for _, key := range append(diff.Added, diff.Changed...) {
+		bytes, err := json.Marshal(migrated.Properties[key])
+		if nil != err {
+			return nil, errors.Wrap(err, "encode property")
+		}
+		pds = append(pds, &v1.PatchData{Path: fieldProperties + "." + key, Operator: xjson.OpReplace.String(), Value: bytes})
+	}
+
+	bytes, _ := json.Marshal(migrated.SchemaVersion)
+	pds = append(pds, &v1.PatchData{Path: fieldSchemaVersion, Operator: xjson.OpReplace.String(), Value: bytes})
+	return pds, nil
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/util"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+	"github.com/tkeel-io/tdtl"
+)
+
+// MirrorMode direction of property mirrors.
+type MirrorMode string
+
+const (
+	// MirrorOneWay source property written to the target property.
+	MirrorOneWay MirrorMode = "one-way"
+	// MirrorBidirectional either property written to the other.
+	MirrorBidirectional MirrorMode = "bidirectional"
+)
+
+// SubscriptionModeMirror mode of subscriptions mirroring properties, handled by runtime rather than published.
+const SubscriptionModeMirror = "MIRROR"
+
+// mirrorReverseSuffix suffix of the subscription id of the reverse direction, subscriptions keyed
+// by id and source entity, directions of a mirror within one entity must not share the key.
+const mirrorReverseSuffix = "-reverse"
+
+// Mirror property of source entity mirrored to property of target entity, properties relative to properties.
+type Mirror struct {
+	ID         string     `json:"id"`
+	Owner      string     `json:"owner"`
+	SourceID   string     `json:"source_id"`
+	SourceProp string     `json:"source_prop"`
+	TargetID   string     `json:"target_id"`
+	TargetProp string     `json:"target_prop"`
+	Mode       MirrorMode `json:"mode"`
+}
+
+// Validate check entities, properties and mode of the mirror.
+func (mr *Mirror) Validate() error {
+	switch {
+	case mr.SourceID == "" || mr.SourceProp == "" || mr.TargetID == "" || mr.TargetProp == "":
+		return errors.Wrap(xerrors.ErrInvalidParam, "mirror entities and properties required")
+	case mr.SourceID == mr.TargetID && mr.SourceProp == mr.TargetProp:
+		return errors.Wrap(xerrors.ErrInvalidParam, "mirror property to itself")
+	case mr.Mode != MirrorOneWay && mr.Mode != MirrorBidirectional:
+		return errors.Wrapf(xerrors.ErrInvalidParam, "mirror mode %q", mr.Mode)
+	}
+	return nil
+}
+
+// Subscriptions returns mirror subscriptions, one for each direction.
+func (mr *Mirror) Subscriptions() []*repository.Subscription {
+	subs := []*repository.Subscription{mirrorSubscription(mr.ID, mr.Owner, mr.SourceID, mr.SourceProp, mr.TargetID, mr.TargetProp)}
+	if mr.Mode == MirrorBidirectional {
+		subs = append(subs, mirrorSubscription(mr.ID+mirrorReverseSuffix, mr.Owner, mr.TargetID, mr.TargetProp, mr.SourceID, mr.SourceProp))
+	}
+	return subs
+}
+
+// indexes returns index of the mirror by each entity of it.
+func (mr *Mirror) indexes() []*repository.MirrorIndex {
+	indexes := []*repository.MirrorIndex{{EntityID: mr.SourceID, MirrorID: mr.ID, Subscriptions: mr.Subscriptions()}}
+	if mr.TargetID != mr.SourceID {
+		indexes = append(indexes, &repository.MirrorIndex{EntityID: mr.TargetID, MirrorID: mr.ID, Subscriptions: mr.Subscriptions()})
+	}
+	return indexes
+}
+
+// SyncPatch returns patch of the target property to the current value of the source, nil if absent.
+func (mr *Mirror) SyncPatch(source *BaseRet) *v1.PatchData {
+	bytes, err := json.Marshal(source.Properties)
+	if nil != err {
+		return nil
+	}
+
+	cc := tdtl.New(bytes).Get(mr.SourceProp)
+	if cc.Type() == tdtl.Null || cc.Type() == tdtl.Undefined {
+		return nil
+	}
+	return &v1.PatchData{
+		Path:     fieldProperties + "." + mr.TargetProp,
+		Operator: xjson.OpReplace.String(),
+		Value:    cc.Raw(),
+	}
+}
+
+func mirrorSubscription(id, owner, sourceID, sourceProp, targetID, targetProp string) *repository.Subscription {
+	return &repository.Subscription{
+		ID:                id,
+		Owner:             owner,
+		Mode:              SubscriptionModeMirror,
+		SourceEntityID:    sourceID,
+		SourceEntityPaths: []string{fieldProperties + "." + sourceProp},
+		Target:            targetID,
+		TargetPath:        fieldProperties + "." + targetProp,
+	}
+}
+
+// CreateMirror mirror property of source entity to property of target entity, the target property
+// takes the current source value at once. entities must share owner, mirrors removed with either entity.
+func (m *apiManager) CreateMirror(ctx context.Context, sourceID, sourceProp, targetID, targetProp string, mode MirrorMode) (*Mirror, error) {
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("create mirror", logf.Eid(sourceID), logf.Target(targetID), logf.Error(err))
+		return nil, err
+	}
+
+	mirror := &Mirror{
+		ID:         util.UUID("mirror"),
+		SourceID:   sourceID,
+		SourceProp: sourceProp,
+		TargetID:   targetID,
+		TargetProp: targetProp,
+		Mode:       mode,
+	}
+	if err := mirror.Validate(); nil != err {
+		return nil, errors.Wrap(err, "create mirror")
+	}
+
+	source, err := m.GetEntity(ctx, &Base{ID: sourceID})
+	if nil != err {
+		return nil, errors.Wrap(err, "create mirror")
+	}
+	target, err := m.GetEntity(ctx, &Base{ID: targetID})
+	if nil != err {
+		return nil, errors.Wrap(err, "create mirror")
+	} else if source.Owner != target.Owner {
+		return nil, errors.Wrap(xerrors.ErrInvalidParam, "create mirror, entities of different owners")
+	}
+
+	// sync the current value, mirrors only follow changes.
+	mirror.Owner = source.Owner
+	if pd := mirror.SyncPatch(source); pd != nil {
+		if _, _, err = m.PatchEntity(ctx, &Base{ID: targetID, Owner: target.Owner}, []*v1.PatchData{pd}); nil != err {
+			return nil, errors.Wrap(err, "create mirror, sync value")
+		}
+	}
+
+	if err = m.putMirror(ctx, mirror); nil != err {
+		m.removeMirror(ctx, mirror)
+		return nil, errors.Wrap(err, "create mirror")
+	}
+
+	log.L().Info("create mirror", logf.ID(mirror.ID), logf.Eid(sourceID),
+		logf.Target(targetID), logf.Mode(string(mode)))
+	return mirror, nil
+}
+
+// putMirror put indexes of the mirror before its subscriptions, so subscriptions always found by index.
+func (m *apiManager) putMirror(ctx context.Context, mirror *Mirror) error {
+	for _, index := range mirror.indexes() {
+		if err := m.entityRepo.PutMirrorIndex(ctx, index); nil != err {
+			return errors.Wrap(err, "put mirror index")
+		}
+	}
+
+	for _, sub := range mirror.Subscriptions() {
+		if err := m.entityRepo.PutSubscription(ctx, sub); nil != err {
+			return errors.Wrap(err, "put mirror subscription")
+		}
+	}
+	return nil
+}
+
+func (m *apiManager) removeMirror(ctx context.Context, mirror *Mirror) {
+	m.removeMirrorIndexes(ctx, mirror.indexes())
+}
+
+// removeMirrors remove mirrors from or to the entity, found by the mirror index of the entity.
+func (m *apiManager) removeMirrors(ctx context.Context, eid string) {
+	indexes, err := m.entityRepo.ListEntityMirrors(ctx, 0, eid)
+	if nil != err {
+		log.L().Error("delete entity, list mirrors", logf.Eid(eid), logf.Error(err))
+		return
+	}
+
+	// indexes of the mirrors by the other entities.
+	var others []*repository.MirrorIndex
+	for _, index := range indexes {
+		others = append(others, otherMirrorIndexes(eid, index)...)
+	}
+	m.removeMirrorIndexes(ctx, append(indexes, others...))
+}
+
+// removeMirrorIndexes remove subscriptions of the indexed mirrors, then the indexes.
+func (m *apiManager) removeMirrorIndexes(ctx context.Context, indexes []*repository.MirrorIndex) {
+	removed := make(map[string]bool)
+	for _, index := range indexes {
+		for _, sub := range index.Subscriptions {
+			if removed[sub.ID] {
+				continue
+			}
+
+			if err := m.entityRepo.DelSubscription(ctx, sub); nil != err {
+				log.L().Error("remove mirror", logf.ID(index.MirrorID), logf.Eid(sub.SourceEntityID), logf.Error(err))
+				continue
+			}
+			removed[sub.ID] = true
+		}
+	}
+
+	for _, index := range indexes {
+		if err := m.entityRepo.DelMirrorIndex(ctx, index); nil != err {
+			log.L().Error("remove mirror index", logf.ID(index.MirrorID), logf.Eid(index.EntityID), logf.Error(err))
+		}
+	}
+}
+
+// otherMirrorIndexes returns indexes of the mirror by entities other than eid.
+func otherMirrorIndexes(eid string, index *repository.MirrorIndex) []*repository.MirrorIndex {
+	var indexes []*repository.MirrorIndex
+	seen := map[string]bool{eid: true}
+	for _, sub := range index.Subscriptions {
+		for _, id := range []string{sub.SourceEntityID, sub.Target} {
+			if !seen[id] {
+				seen[id] = true
+				indexes = append(indexes, &repository.MirrorIndex{EntityID: id, MirrorID: index.MirrorID, Subscriptions: index.Subscriptions})
+			}
+		}
+	}
+	return indexes
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+)
+
+const (
+	// FieldMovedFrom marks an entity whose move to another tenant not finished yet.
+	FieldMovedFrom = "moved_from"
+
+	fieldOwner = "owner"
+	// tenant of entity, owner taken as tenant if absent.
+	propTenantID = "sysField._tenantId"
+)
+
+// MovedFrom owner and tenant an entity moved from.
+type MovedFrom struct {
+	Owner  string `json:"owner"`
+	Tenant string `json:"tenant"`
+}
+
+// MoveEntities move entities to the target tenant one by one, continue past individual failures.
+// entity states are not namespaced by tenant, so the state keeps its key, the owner and the tenant
+// property are rewritten by a single write, which reindexes the entity, then mapper expressions and
+// quota usage follow. the write records where the entity moved from until the rest finished, so an
+// entity is never half moved: run again with the same ids and target to finish interrupted moves.
+func (m *apiManager) MoveEntities(ctx context.Context, ids []string, target string) map[string]error {
+	errs := make(map[string]error)
+	for _, id := range ids {
+		if err := m.moveEntity(ctx, id, target); nil != err {
+			log.L().Error("move entities", logf.Eid(id), logf.Owner(target), logf.Error(err))
+			errs[id] = err
+		}
+	}
+	return errs
+}
+
+func (m *apiManager) moveEntity(ctx context.Context, id, target string) error {
+	if err := m.checkWritable(); nil != err {
+		return err
+	} else if target == "" {
+		return errors.Wrap(xerrors.ErrInvalidParam, "move entity, empty target tenant")
+	}
+
+	base, err := m.GetEntity(ctx, &Base{ID: id})
+	if nil != err {
+		return errors.Wrap(err, "move entity")
+	}
+
+	from := base.MovedFrom
+	if nil == from {
+		if base.Owner == target && tenantOf(base) == target {
+			return nil
+		}
+
+		from = &MovedFrom{Owner: base.Owner, Tenant: tenantOf(base)}
+		var reserved bool
+		if reserved, err = m.reserveMove(ctx, id, from.Tenant, target); nil != err {
+			return errors.Wrap(err, "move entity")
+		}
+
+		var pds []*v1.PatchData
+		if pds, err = movePatches(from, target); nil == err {
+			_, _, err = m.PatchEntity(ctx, &Base{ID: id}, pds, NewVersionOption(base.Version))
+		}
+		if nil != err {
+			if reserved {
+				m.releaseMove(ctx, id, target)
+			}
+			return errors.Wrap(err, "move entity")
+		}
+	} else if base.Owner != target {
+		return errors.Wrapf(xerrors.ErrEntityConflict, "move entity, entity moving to %s", base.Owner)
+	}
+
+	// the entity moved, bring its expressions along and release the origin quota.
+	if err = m.moveExpressions(ctx, id, from.Owner, target); nil != err {
+		return errors.Wrap(err, "move entity")
+	} else if from.Tenant != target {
+		m.releaseMove(ctx, id, from.Tenant)
+	}
+
+	_, _, err = m.PatchEntity(ctx, &Base{ID: id}, []*v1.PatchData{{
+		Path:     FieldMovedFrom,
+		Operator: xjson.OpRemove.String(),
+	}})
+	return errors.Wrap(err, "move entity, finish")
+}
+
+// moveExpressions rekey expressions of the entity under the target owner.
+func (m *apiManager) moveExpressions(ctx context.Context, id, owner, target string) error {
+	if owner == target {
+		return nil
+	}
+
+	exprs, err := m.ListExpression(ctx, &Base{ID: id, Owner: owner})
+	if nil != err || len(exprs) == 0 {
+		return errors.Wrap(err, "move expressions")
+	}
+
+	moved := make([]repository.Expression, 0, len(exprs))
+	removes := make([]repository.Expression, 0, len(exprs))
+	for _, expr := range exprs {
+		removes = append(removes, *expr)
+		item := *expr
+		item.Owner = target
+		item.GenKey()
+		moved = append(moved, item)
+	}
+
+	// expressions put before removed, the entity keeps its mappers whenever interrupted.
+	if err = m.appendExpression(ctx, moved); nil != err {
+		return errors.Wrap(err, "move expressions")
+	}
+	return errors.Wrap(m.RemoveExpression(ctx, removes), "move expressions")
+}
+
+// reserveMove account the entity into the target tenant usage with the size accounted in the origin,
+// returns whether reserved, entities not accounted in the origin or already in the target skipped.
+func (m *apiManager) reserveMove(ctx context.Context, id, origin, target string) (bool, error) {
+	qc := config.Get().Quota
+	if !qc.Enabled || origin == "" || origin == target {
+		return false, nil
+	}
+
+	var size int64
+	if err := m.entityRepo.UpdateQuotaUsage(ctx, origin, id,
+		func(usage *repository.QuotaUsage, es *repository.EntitySize) error {
+			size = es.Size
+			return nil
+		}); nil != err {
+		return false, errors.Wrap(err, "reserve quota")
+	} else if size == 0 {
+		return false, nil
+	}
+
+	var reserved bool
+	limit := qc.Limit(target)
+	err := m.entityRepo.UpdateQuotaUsage(ctx, target, id,
+		func(usage *repository.QuotaUsage, es *repository.EntitySize) error {
+			if reserved = es.Size == 0; !reserved {
+				return nil
+			} else if err := checkQuota(target, limit, usage, 1, size); nil != err {
+				return err
+			}
+			usage.Count++
+			usage.Size += size
+			es.Size = size
+			return nil
+		})
+	return reserved, errors.Wrap(err, "reserve quota")
+}
+
+// releaseMove remove the entity from the tenant usage, released entities skipped.
+func (m *apiManager) releaseMove(ctx context.Context, id, tenant string) {
+	if !config.Get().Quota.Enabled || tenant == "" {
+		return
+	}
+
+	if err := m.entityRepo.UpdateQuotaUsage(ctx, tenant, id,
+		func(usage *repository.QuotaUsage, es *repository.EntitySize) error {
+			if es.Size > 0 {
+				usage.Count--
+				usage.Size -= es.Size
+				es.Deleted = true
+			}
+			return nil
+		}); nil != err {
+		log.L().Error("release quota", logf.Eid(id), logf.Owner(tenant), logf.Error(err))
+	}
+}
+
+// movePatches returns patches moving entity to the target, recording where it moved from.
+func movePatches(from *MovedFrom, target string) ([]*v1.PatchData, error) {
+	targetBytes, _ := json.Marshal(target)
+	fromBytes, err := json.Marshal(from)
+	if nil != err {
+		return nil, errors.Wrap(err, "encode moved from")
+	}
+
+	return []*v1.PatchData{
+		{Path: fieldOwner, Operator: xjson.OpReplace.String(), Value: targetBytes},
+		{Path: "properties." + propTenantID, Operator: xjson.OpReplace.String(), Value: targetBytes},
+		{Path: FieldMovedFrom, Operator: xjson.OpReplace.String(), Value: fromBytes},
+	}, nil
+}
+
+// tenantOf returns tenant of the entity, the owner if no tenant property.
+func tenantOf(base *BaseRet) string {
+	if tenant, ok := base.GetProperty(propTenantID).AsString(); ok && tenant != "" {
+		return tenant
+	}
+	return base.Owner
+}
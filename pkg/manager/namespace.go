@@ -0,0 +1,41 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"strings"
+
+	"github.com/tkeel-io/core/pkg/types"
+)
+
+// StripNamespace returns properties viewed by the namespace, properties of the namespace under
+// their plain keys, shadowing plain properties of the same keys, other properties kept as is.
+func StripNamespace(props map[string]interface{}, namespace string) map[string]interface{} {
+	prefix := types.NamespacedKey(namespace, "")
+	ret := make(map[string]interface{}, len(props))
+	for key, val := range props {
+		if !strings.HasPrefix(key, prefix) {
+			ret[key] = val
+		}
+	}
+	for key, val := range props {
+		if strings.HasPrefix(key, prefix) {
+			ret[strings.TrimPrefix(key, prefix)] = val
+		}
+	}
+	return ret
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+)
+
+// DeleteProperty remove the property of the path, e.g. "metrics.cpu", returns the updated entity.
+// the removal is an ordinary remove patch, so subscriptions and mappers observe it as a change.
+// ErrPropertyNotFound if the property absent, unless idempotent, the entity returned unchanged then.
+func (m *apiManager) DeleteProperty(ctx context.Context, en *Base, path string, idempotent bool) (*BaseRet, error) {
+	if path == "" {
+		return nil, errors.Wrap(xerrors.ErrEntityPropertyIDEmpty, "delete property")
+	}
+
+	current, err := m.GetEntity(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "delete property")
+	}
+
+	if current.GetProperty(path).IsNil() {
+		if idempotent {
+			return current, nil
+		}
+		return nil, errors.Wrapf(xerrors.ErrPropertyNotFound, "delete property %s", path)
+	}
+
+	ret, _, err := m.PatchEntity(ctx, en, []*v1.PatchData{{
+		Path:     fieldProperties + "." + path,
+		Operator: xjson.OpRemove.String(),
+	}}, NewVersionOption(current.Version))
+	return ret, errors.Wrap(err, "delete property")
+}
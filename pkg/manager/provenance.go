@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/repository"
+)
+
+const (
+	ProvenanceRaw     = "raw"
+	ProvenanceDerived = "derived"
+)
+
+// Provenance describe where a property value comes from.
+type Provenance struct {
+	Source string `json:"source"`
+	Mapper string `json:"mapper,omitempty"`
+}
+
+// GetProvenance returns provenances of the derived properties, keyed by property path,
+// properties not listed are raw.
+func (m *apiManager) GetProvenance(ctx context.Context, en *Base) (map[string]Provenance, error) {
+	exprs, err := m.ListExpression(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "get provenance")
+	}
+	return derivedProvenance(exprs), nil
+}
+
+func derivedProvenance(exprs []*repository.Expression) map[string]Provenance {
+	provenances := make(map[string]Provenance)
+	for _, expr := range exprs {
+		if path := strings.TrimPrefix(expr.Path, "properties."); path != "" {
+			provenances[path] = Provenance{Source: ProvenanceDerived, Mapper: expr.Name}
+		}
+	}
+	return provenances
+}
+
+// FilterProperties returns the top level properties of the given source.
+func FilterProperties(props map[string]interface{}, provenances map[string]Provenance, source string) map[string]interface{} {
+	ret := make(map[string]interface{})
+	for key, val := range props {
+		derived := provenances[key].Source == ProvenanceDerived
+		if derived == (source == ProvenanceDerived) {
+			ret[key] = val
+		}
+	}
+	return ret
+}
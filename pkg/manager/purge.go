@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/metrics"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/kit/log"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const purgeChunkSize = 100
+
+// SetSearchClient set search client used to find entities by maintenance tasks.
+func (m *apiManager) SetSearchClient(searchClient v1.SearchHTTPServer) {
+	m.searchClient = searchClient
+}
+
+// PurgeTombstones hard delete entities tombstoned before olderThan chunk by chunk,
+// returns count of purged entities. tombstones not yet visible in search engine are
+// left to the next run, so it is safe to run repeatedly.
+func (m *apiManager) PurgeTombstones(ctx context.Context, olderThan time.Duration) (int, error) {
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("purge tombstones", logf.Error(err))
+		return 0, err
+	}
+
+	if m.searchClient == nil {
+		log.L().Error("purge tombstones, search client nil")
+		return 0, errors.Wrap(xerrors.ErrConnectionNil, "purge tombstones")
+	}
+
+	purged := 0
+	seen := make(map[string]struct{})
+	deadline := time.Now().Add(-olderThan).UnixNano() / 1e6
+	for {
+		// purged entities drop out of search results, so always fetch the first page.
+		resp, err := m.searchClient.Search(ctx, &v1.SearchRequest{
+			PageNum:  1,
+			PageSize: purgeChunkSize,
+			Condition: []*v1.SearchCondition{{
+				Field:    FieldDeletedAt,
+				Operator: "$lte",
+				Value:    structpb.NewNumberValue(float64(deadline)),
+			}},
+		})
+		if nil != err {
+			log.L().Error("purge tombstones, search tombstones", logf.Error(err))
+			return purged, errors.Wrap(err, "purge tombstones")
+		}
+
+		tombstones := entitiesFrom(resp, seen)
+		for _, en := range tombstones {
+			if err = m.purgeEntity(ctx, en); nil != err {
+				log.L().Error("purge tombstone", logf.Eid(en.ID), logf.Owner(en.Owner), logf.Error(err))
+				continue
+			}
+
+			purged++
+			metrics.CollectorPurgedTombstoneCount.WithLabelValues(en.Owner).Inc()
+		}
+
+		if len(tombstones) == 0 || len(resp.Items) < purgeChunkSize {
+			break
+		}
+	}
+
+	log.L().Info("purge tombstones completed", logf.Count(int64(purged)))
+	return purged, nil
+}
+
+// FinishDeletes complete deletes interrupted between removing entity from state and search,
+// returns count of deletes finished. entities still in state are deleted again through runtime,
+// otherwise the leftover search document is removed. failed deletes are kept for the next run.
+func (m *apiManager) FinishDeletes(ctx context.Context) (int, error) {
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("finish deletes", logf.Error(err))
+		return 0, err
+	}
+
+	if m.searchClient == nil {
+		log.L().Error("finish deletes, search client nil")
+		return 0, errors.Wrap(xerrors.ErrConnectionNil, "finish deletes")
+	}
+
+	intents, err := m.entityRepo.ListDeleteIntent(ctx, m.entityRepo.GetLastRevision(ctx))
+	if nil != err {
+		log.L().Error("finish deletes, list delete intents", logf.Error(err))
+		return 0, errors.Wrap(err, "finish deletes")
+	}
+
+	finished := 0
+	for _, intent := range intents {
+		if err = m.finishDelete(ctx, intent); nil != err {
+			log.L().Error("finish delete", logf.Eid(intent.EntityID), logf.Owner(intent.Owner), logf.Error(err))
+			continue
+		}
+		finished++
+	}
+
+	log.L().Info("finish deletes completed", logf.Count(int64(finished)))
+	return finished, nil
+}
+
+func (m *apiManager) finishDelete(ctx context.Context, intent *repository.DeleteIntent) error {
+	has, err := m.entityRepo.HasEntity(ctx, intent.EntityID)
+	if nil != err {
+		return errors.Wrap(err, "finish delete")
+	} else if has {
+		// runtime drops the entity and the intent.
+		return m.DeleteEntity(ctx, &Base{ID: intent.EntityID, Owner: intent.Owner, Source: intent.Source})
+	}
+
+	if _, err = m.searchClient.DeleteByID(ctx, &v1.DeleteByIDRequest{
+		Id:     intent.EntityID,
+		Owner:  intent.Owner,
+		Source: intent.Source,
+	}); nil != err {
+		return errors.Wrap(err, "finish delete, delete search document")
+	}
+	return errors.Wrap(m.entityRepo.DelDeleteIntent(ctx, intent), "finish delete")
+}
+
+// entitiesFrom returns entities of search response not seen before.
+func entitiesFrom(resp *v1.SearchResponse, seen map[string]struct{}) []*Base {
+	var tombstones []*Base
+	for _, item := range resp.Items {
+		kv, ok := item.AsInterface().(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		id, _ := kv["id"].(string)
+		if _, has := seen[id]; has || id == "" {
+			continue
+		}
+
+		seen[id] = struct{}{}
+		owner, _ := kv["owner"].(string)
+		tombstones = append(tombstones, &Base{ID: id, Owner: owner})
+	}
+	return tombstones
+}
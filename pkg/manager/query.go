@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/resource/search"
+	"github.com/tkeel-io/kit/log"
+)
+
+// search backend caps page size.
+const queryPageSize = 200
+
+// QueryFields returns the fields of entities matching the search request, the search backend
+// projects documents to the fields, at most queryFieldsLimit entities returned.
+func (m *apiManager) QueryFields(ctx context.Context, query *v1.SearchRequest, fields []string) ([]map[string]interface{}, error) {
+	if m.searchClient == nil {
+		log.L().Error("query fields, search client nil")
+		return nil, errors.Wrap(xerrors.ErrConnectionNil, "query fields")
+	}
+
+	if len(fields) == 0 {
+		return nil, errors.Wrap(xerrors.ErrInvalidParam, "query fields, fields empty")
+	}
+
+	limit := m.queryFieldsLimit
+	if limit <= 0 {
+		limit = defaultQueryFieldsLimit
+	}
+
+	// copy, paging must not leak to the caller.
+	req := &v1.SearchRequest{
+		Source:       query.Source,
+		Owner:        query.Owner,
+		Query:        query.Query,
+		Condition:    query.Condition,
+		OrderBy:      query.OrderBy,
+		IsDescending: query.IsDescending,
+		PageNum:      1,
+		PageSize:     queryPageSize,
+	}
+	ctx = search.WithFields(ctx, fields...)
+
+	var items []map[string]interface{}
+	for len(items) < limit {
+		resp, err := m.searchClient.Search(ctx, req)
+		if nil != err {
+			log.L().Error("query fields, search entities", logf.Error(err), logf.Any("page", req.PageNum))
+			return nil, errors.Wrap(err, "query fields")
+		}
+
+		for _, item := range resp.Items {
+			if len(items) >= limit {
+				break
+			}
+			if kv, ok := item.AsInterface().(map[string]interface{}); ok {
+				items = append(items, kv)
+			}
+		}
+
+		if len(resp.Items) < int(req.PageSize) {
+			break
+		}
+		req.PageNum++
+	}
+
+	return items, nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/runtime"
+	"github.com/tkeel-io/core/pkg/types"
+	"github.com/tkeel-io/kit/log"
+	"github.com/tkeel-io/tdtl"
+)
+
+// GetQuotaUsage returns current quota usage of the tenant.
+func (m *apiManager) GetQuotaUsage(ctx context.Context, tenant string) (*repository.QuotaUsage, error) {
+	usage, err := m.entityRepo.GetQuotaUsage(ctx, tenant)
+	return usage, errors.Wrap(err, "get quota usage")
+}
+
+// quotaTenant returns tenant the usage accounted to if quota enabled, tenant of the caller,
+// else tenant of the entity for callers without tenant, e.g. internal calls.
+func quotaTenant(ctx context.Context, entityTenant string) (string, config.QuotaLimit, bool) {
+	qc := config.Get().Quota
+	tenant := entityTenant
+	if identity, _ := types.IdentityFrom(ctx); identity.Tenant != "" {
+		tenant = identity.Tenant
+	}
+	if !qc.Enabled || tenant == "" {
+		return "", config.QuotaLimit{}, false
+	}
+	return tenant, qc.Limit(tenant), true
+}
+
+// stateTenant returns tenant of the encoded entity, like tenantOf, the source if without owner.
+func stateTenant(state []byte) string {
+	cc := tdtl.New(state)
+	for _, path := range []string{runtime.FieldProperties + "." + propTenantID, runtime.FieldOwner, runtime.FieldSource} {
+		if val := cc.Get(path); val.Type() == tdtl.String && val.String() != "" {
+			return val.String()
+		}
+	}
+	return ""
+}
+
+func checkQuota(tenant string, limit config.QuotaLimit, usage *repository.QuotaUsage, count, size int64) error {
+	if limit.MaxEntities > 0 && usage.Count+count > limit.MaxEntities {
+		return errors.Wrapf(xerrors.ErrQuotaExceeded, "tenant %s, entity count limit %d, usage %d",
+			tenant, limit.MaxEntities, usage.Count)
+	} else if limit.MaxSize > 0 && usage.Size+size > limit.MaxSize {
+		return errors.Wrapf(xerrors.ErrQuotaExceeded, "tenant %s, entity size limit %d, usage %d",
+			tenant, limit.MaxSize, usage.Size)
+	}
+	return nil
+}
+
+// reserveEntity account a new entity of the tenant into usage, returns whether reserved.
+func (m *apiManager) reserveEntity(ctx context.Context, eid, entityTenant string, size int64) (bool, error) {
+	tenant, limit, ok := quotaTenant(ctx, entityTenant)
+	if !ok {
+		return false, nil
+	}
+
+	var reserved bool
+	err := m.entityRepo.UpdateQuotaUsage(ctx, tenant, eid,
+		func(usage *repository.QuotaUsage, es *repository.EntitySize) error {
+			// entity already accounted.
+			if reserved = es.Size == 0; !reserved {
+				return nil
+			} else if err := checkQuota(tenant, limit, usage, 1, size); nil != err {
+				return err
+			}
+			usage.Count++
+			usage.Size += size
+			es.Size = size
+			return nil
+		})
+	return reserved, errors.Wrap(err, "reserve entity quota")
+}
+
+// checkGrowth check usages can grow by the sizes, keyed by tenant of entities growing.
+func (m *apiManager) checkGrowth(ctx context.Context, growths map[string]int64) error {
+	sizes := make(map[string]int64)
+	limits := make(map[string]config.QuotaLimit)
+	for entityTenant, size := range growths {
+		if tenant, limit, ok := quotaTenant(ctx, entityTenant); ok {
+			sizes[tenant] += size
+			limits[tenant] = limit
+		}
+	}
+
+	for tenant, size := range sizes {
+		usage, err := m.entityRepo.GetQuotaUsage(ctx, tenant)
+		if nil != err {
+			return errors.Wrap(err, "check entity quota")
+		} else if err = checkQuota(tenant, limits[tenant], usage, 0, size); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPatchGrowth check the tenant usage can grow by the size the patches grow the stored state.
+func (m *apiManager) checkPatchGrowth(ctx context.Context, eid string, pds []*v1.PatchData) error {
+	if !config.Get().Quota.Enabled {
+		return nil
+	}
+
+	before, err := m.entityRepo.GetEntity(ctx, eid)
+	if nil != err {
+		return errors.Wrap(err, "check entity quota")
+	} else if len(before) == 0 {
+		// entity not found, rejected by runtime.
+		return nil
+	}
+
+	after, err := runtime.PatchState(eid, before, pds)
+	if nil != err {
+		// invalid patches, rejected by runtime.
+		return nil
+	}
+	return m.checkGrowth(ctx, map[string]int64{
+		stateTenant(before): int64(len(after) - len(before)),
+	})
+}
+
+// resizeEntity settle encoded size of the entity, entities not accounted to the tenant left alone.
+func (m *apiManager) resizeEntity(ctx context.Context, eid, entityTenant string, size int64) {
+	tenant, _, ok := quotaTenant(ctx, entityTenant)
+	if !ok {
+		return
+	}
+
+	if err := m.entityRepo.UpdateQuotaUsage(ctx, tenant, eid,
+		func(usage *repository.QuotaUsage, es *repository.EntitySize) error {
+			if !es.Exists {
+				es.Deleted = true
+				return nil
+			}
+			usage.Size += size - es.Size
+			es.Size = size
+			return nil
+		}); nil != err {
+		log.L().Error("resize entity quota", logf.Eid(eid), logf.Error(err))
+	}
+}
+
+// releaseEntity remove the entity from tenant usage.
+func (m *apiManager) releaseEntity(ctx context.Context, eid, entityTenant string) {
+	tenant, _, ok := quotaTenant(ctx, entityTenant)
+	if !ok {
+		return
+	}
+
+	if err := m.entityRepo.UpdateQuotaUsage(ctx, tenant, eid,
+		func(usage *repository.QuotaUsage, es *repository.EntitySize) error {
+			// entities never accounted or released already leave usage alone.
+			if es.Exists {
+				usage.Count--
+				usage.Size -= es.Size
+			}
+			es.Deleted = true
+			return nil
+		}); nil != err {
+		log.L().Error("release entity quota", logf.Eid(eid), logf.Error(err))
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tkeel-io/core/pkg/repository"
+)
+
+// maxExprCacheEntries entities whose expressions cached, further entities not cached until entries expired.
+const maxExprCacheEntries = 10000
+
+type exprCacheEntry struct {
+	exprs   []repository.Expression
+	expired time.Time
+}
+
+// exprCache expressions of entities recently listed from etcd, serves reads during brief etcd outages.
+type exprCache struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]exprCacheEntry
+}
+
+func newExprCache(ttl time.Duration) *exprCache {
+	return &exprCache{
+		ttl:     ttl,
+		entries: make(map[string]exprCacheEntry),
+	}
+}
+
+func (c *exprCache) put(owner, entityID string, exprs []*repository.Expression) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key := owner + "/" + entityID
+	if _, has := c.entries[key]; !has && len(c.entries) >= maxExprCacheEntries {
+		c.evict()
+		if len(c.entries) >= maxExprCacheEntries {
+			return
+		}
+	}
+
+	entry := exprCacheEntry{
+		exprs:   make([]repository.Expression, 0, len(exprs)),
+		expired: time.Now().Add(c.ttl),
+	}
+	for _, expr := range exprs {
+		entry.exprs = append(entry.exprs, *expr)
+	}
+	c.entries[key] = entry
+}
+
+// get returns copies of cached expressions, so callers free to modify them.
+func (c *exprCache) get(owner, entityID string) ([]*repository.Expression, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[owner+"/"+entityID]
+	if !ok || time.Now().After(entry.expired) {
+		return nil, false
+	}
+
+	exprs := make([]*repository.Expression, 0, len(entry.exprs))
+	for index := range entry.exprs {
+		expr := entry.exprs[index]
+		exprs = append(exprs, &expr)
+	}
+	return exprs, true
+}
+
+func (c *exprCache) evict() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expired) {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/runtime"
+	"github.com/tkeel-io/kit/log"
+)
+
+const (
+	defaultReadRepairRate = 10
+	readRepairQueueSize   = 128
+)
+
+// readRepairer entities listed from search documents maybe lagging the state,
+// repaired one at a time, at most rate per second.
+type readRepairer struct {
+	interval time.Duration
+	queue    chan string
+	lock     sync.Mutex
+	// indexed documents keyed by entity id queued.
+	pending map[string]map[string]interface{}
+}
+
+func newReadRepairer(rate int) *readRepairer {
+	if rate <= 0 {
+		rate = defaultReadRepairRate
+	}
+	return &readRepairer{
+		interval: time.Second / time.Duration(rate),
+		queue:    make(chan string, readRepairQueueSize),
+		pending:  make(map[string]map[string]interface{}),
+	}
+}
+
+// add queue the entity, entity queued already keeps the latest indexed document.
+func (r *readRepairer) add(id string, indexed map[string]interface{}) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, has := r.pending[id]; has {
+		r.pending[id] = indexed
+		return true
+	}
+
+	select {
+	case r.queue <- id:
+		r.pending[id] = indexed
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *readRepairer) take(id string) map[string]interface{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	indexed := r.pending[id]
+	delete(r.pending, id)
+	return indexed
+}
+
+// RepairIndex queue the entity listed from search with the indexed document, re-indexed in the
+// background if it differs from the document built from its state. returns false if dropped for
+// the repair queue full, the index converges on later listings then.
+func (m *apiManager) RepairIndex(id string, indexed map[string]interface{}) bool {
+	if m.repairs == nil {
+		return false
+	}
+	return m.repairs.add(id, indexed)
+}
+
+func (m *apiManager) runReadRepair(repairs *readRepairer) {
+	ticker := time.NewTicker(repairs.interval)
+	defer ticker.Stop()
+	for {
+		var id string
+		select {
+		case <-m.ctx.Done():
+			return
+		case id = <-repairs.queue:
+		}
+
+		if err := m.repairIndex(m.ctx, id, repairs.take(id)); nil != err {
+			log.L().Warn("read repair", logf.Eid(id), logf.Error(err))
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// repairIndex index the entity if the indexed document differs from the one built from its state.
+// the indexed version is not compared, the runtime re-indexes only on changes of indexed fields,
+// so the version of the document lags every write of other properties.
+func (m *apiManager) repairIndex(ctx context.Context, id string, indexed map[string]interface{}) error {
+	if m.searchClient == nil {
+		return errors.Wrap(xerrors.ErrConnectionNil, "repair index")
+	}
+
+	state, err := m.entityRepo.GetEntity(ctx, id)
+	if nil != err {
+		return errors.Wrap(err, "repair index")
+	}
+
+	en, err := runtime.NewEntity(id, state)
+	if nil != err {
+		return errors.Wrap(err, "repair index, decode entity")
+	}
+	bytes, err := runtime.MakeSearchDocument(en, m.searchModel, m.searchDocBuilder)
+	if nil != err {
+		return errors.Wrap(err, "repair index")
+	}
+
+	var doc map[string]interface{}
+	if err = json.Unmarshal(bytes, &doc); nil != err {
+		return errors.Wrap(err, "repair index, decode document")
+	} else if indexFresh(doc, indexed) {
+		return nil
+	}
+
+	log.L().Info("read repair, index lagging", logf.Eid(id))
+	return errors.Wrap(m.indexEntity(ctx, id, state), "repair index")
+}
+
+// indexFresh reports whether the indexed document matches the document built, version ignored.
+func indexFresh(doc, indexed map[string]interface{}) bool {
+	for key := range indexed {
+		if _, has := doc[key]; !has && key != runtime.FieldVersion {
+			return false
+		}
+	}
+	for key, val := range doc {
+		if key != runtime.FieldVersion && !reflect.DeepEqual(val, indexed[key]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tkeel-io/tdtl"
+)
+
+// sensitiveCacheTTL how long resolved sensitive paths reused for logging.
+const sensitiveCacheTTL = 30 * time.Second
+
+// sensitiveCache paths of sensitive properties resolved recently, keyed by entity id.
+type sensitiveCache struct {
+	lock    sync.Mutex
+	entries map[string]sensitiveEntry
+}
+
+type sensitiveEntry struct {
+	paths   []string
+	expired time.Time
+}
+
+func (c *sensitiveCache) get(id string) ([]string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, has := c.entries[id]
+	if !has || time.Now().After(entry.expired) {
+		return nil, false
+	}
+	return entry.paths, true
+}
+
+func (c *sensitiveCache) put(id string, paths []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]sensitiveEntry)
+	}
+
+	// drop expired entries of other entities.
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expired) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[id] = sensitiveEntry{paths: paths, expired: now.Add(sensitiveCacheTTL)}
+}
+
+// remove the entry of entity written, the scheme or template may have changed.
+func (c *sensitiveCache) remove(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, id)
+}
+
+// logJSON returns entity info for logging, values of properties sensitive by the scheme carried in the request,
+// the stored scheme or the scheme of the template of the entity redacted.
+func (m *apiManager) logJSON(ctx context.Context, en *Base) map[string]interface{} {
+	info := en.JSON()
+	if len(en.Properties) == 0 || en.ID == "" {
+		return info
+	}
+
+	paths, ok := m.sensitive.get(en.ID)
+	if !ok {
+		paths = m.resolveSensitive(ctx, en.ID, en.TemplateID)
+		m.sensitive.put(en.ID, paths)
+	}
+	if properties, _ := info[fieldProperties].(string); len(paths) > 0 {
+		info[fieldProperties] = string(redactPaths(paths, []byte(properties)))
+	}
+	return info
+}
+
+// resolveSensitive returns paths of sensitive properties declared by the stored scheme
+// or the scheme of the template of the entity.
+func (m *apiManager) resolveSensitive(ctx context.Context, id, templateID string) []string {
+	var paths []string
+	if state, err := m.entityRepo.GetEntity(ctx, id); nil == err {
+		cc := tdtl.New(state)
+		paths = append(paths, sensitivePathsOf(cc.Get(FieldScheme).Raw())...)
+		if templateID == "" {
+			templateID = cc.Get(fieldTemplateID).String()
+		}
+	}
+	if templateID != "" {
+		if state, err := m.entityRepo.GetEntity(ctx, templateID); nil == err {
+			paths = append(paths, sensitivePathsOf(tdtl.New(state).Get(FieldScheme).Raw())...)
+		}
+	}
+	return paths
+}
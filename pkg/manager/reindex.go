@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/kit/log"
+)
+
+const defaultReindexInterval = time.Second
+
+// ReindexOptions options of Reindex.
+type ReindexOptions struct {
+	// Progress receives stats periodically and the final stats, closed when Reindex returns.
+	// stats dropped if the receiver not ready, reindexing never blocks on it.
+	Progress chan<- ReindexStats
+	// Interval of progress reports, default 1s.
+	Interval time.Duration
+}
+
+// ReindexStats progress of Reindex, Processed counts failed entities too.
+type ReindexStats struct {
+	Total     int           `json:"total"`
+	Processed int           `json:"processed"`
+	Failed    int           `json:"failed"`
+	Elapsed   time.Duration `json:"elapsed"`
+	// Rate entities processed per second, ETA estimated by the rate.
+	Rate float64       `json:"rate"`
+	ETA  time.Duration `json:"eta"`
+}
+
+func (s ReindexStats) at(start time.Time) ReindexStats {
+	s.Elapsed = time.Since(start)
+	if s.Processed > 0 && s.Elapsed > 0 {
+		s.Rate = float64(s.Processed) / s.Elapsed.Seconds()
+		s.ETA = time.Duration(float64(s.Total-s.Processed) / s.Rate * float64(time.Second))
+	}
+	return s
+}
+
+// Reindex rebuild search documents of the entities from the state store, continue past
+// individual failures, returns the final stats.
+func (m *apiManager) Reindex(ctx context.Context, ids []string, opts ReindexOptions) (ReindexStats, error) {
+	start := time.Now()
+	stats := ReindexStats{Total: len(ids)}
+	report := func() {
+		if opts.Progress == nil {
+			return
+		}
+		select {
+		case opts.Progress <- stats.at(start):
+		default:
+		}
+	}
+	if opts.Progress != nil {
+		defer close(opts.Progress)
+	}
+
+	if m.searchClient == nil {
+		log.L().Error("reindex, search client nil")
+		return stats, errors.Wrap(xerrors.ErrConnectionNil, "reindex")
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultReindexInterval
+	}
+
+	reported := start
+	for _, id := range ids {
+		if err := ctx.Err(); nil != err {
+			report()
+			return stats.at(start), errors.Wrap(err, "reindex")
+		}
+
+		if err := m.reindexEntity(ctx, id); nil != err {
+			log.L().Warn("reindex entity", logf.Eid(id), logf.Error(err))
+			stats.Failed++
+		}
+		stats.Processed++
+
+		if time.Since(reported) >= interval {
+			reported = time.Now()
+			report()
+		}
+	}
+
+	report()
+	log.L().Info("reindex completed", logf.Count(int64(stats.Processed)),
+		logf.Any("failed", stats.Failed), logf.Elapsed(time.Since(start)))
+	return stats.at(start), nil
+}
+
+// reindexEntity index the entity as stored with the full search document, tombstone included,
+// so that reindexing never makes soft-deleted entities searchable again.
+func (m *apiManager) reindexEntity(ctx context.Context, id string) error {
+	return errors.Wrap(m.indexStored(ctx, id), "reindex entity")
+}
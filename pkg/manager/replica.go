@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ReadPreference where GetEntity reads entity from, requested by the Read-Preference header of entity reads.
+type ReadPreference string
+
+const (
+	// ReadPrimary read through runtime, reflects all applied writes, the default.
+	ReadPrimary ReadPreference = "primary"
+	// ReadReplica read state store replica bypassing runtime, stale by the runtime flush delay
+	// plus replication lag, falls back to the primary store on replica error.
+	// entity lists are served by the search engine and not affected.
+	ReadReplica ReadPreference = "replica"
+)
+
+type readPreferenceKey struct{}
+
+// WithReadPreference returns context carrying read preference of entity reads.
+func WithReadPreference(ctx context.Context, pref ReadPreference) context.Context {
+	return context.WithValue(ctx, readPreferenceKey{}, pref)
+}
+
+func readPreferenceFrom(ctx context.Context) ReadPreference {
+	if pref, ok := ctx.Value(readPreferenceKey{}).(ReadPreference); ok {
+		return pref
+	}
+	return ReadPrimary
+}
+
+func (m *apiManager) loadReplicaEntity(ctx context.Context, id string) (*BaseRet, error) {
+	bytes, err := m.entityRepo.GetEntityFromReplica(ctx, id)
+	if nil != err {
+		return nil, errors.Wrap(err, "load replica entity")
+	}
+
+	baseRet, err := DecodeBase(bytes)
+	if nil != err {
+		return nil, errors.Wrap(err, "decode entity")
+	}
+	if err = m.migrate(baseRet); nil != err {
+		return nil, errors.Wrap(err, "load replica entity")
+	}
+	return baseRet, nil
+}
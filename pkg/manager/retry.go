@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+)
+
+// MutateFunc modify entity properties in place.
+type MutateFunc func(*BaseRet) error
+
+// SetPropertiesWithRetry read entity, apply mutate and write back the changed properties
+// if the entity version not changed, retry on version conflict up to maxRetries.
+func (m *apiManager) SetPropertiesWithRetry(ctx context.Context, en *Base, mutate MutateFunc, maxRetries int) (*BaseRet, error) {
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		var current *BaseRet
+		if current, err = m.GetEntity(ctx, en); nil != err {
+			return nil, errors.Wrap(err, "set properties, read entity")
+		}
+
+		// mutate a copy of properties.
+		origin := current.Properties
+		current.Properties = copyProperties(origin)
+		if err = mutate(current); nil != err {
+			return nil, errors.Wrap(err, "set properties, mutate entity")
+		}
+
+		var pds []*v1.PatchData
+		if pds, err = diffProperties(origin, current.Properties); nil != err {
+			return nil, errors.Wrap(err, "set properties")
+		} else if len(pds) == 0 {
+			return current, nil
+		}
+
+		var out *BaseRet
+		out, _, err = m.PatchEntity(ctx, en, pds, NewVersionOption(current.Version))
+		if !errors.Is(err, xerrors.ErrEntityConflict) {
+			return out, errors.Wrap(err, "set properties")
+		}
+
+		log.L().Warn("set properties, retry on conflict",
+			logf.Eid(en.ID), logf.Version(current.Version), logf.Count(int64(attempt)))
+	}
+
+	return nil, errors.Wrap(err, "set properties, retries exhausted")
+}
+
+func copyProperties(props map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(props))
+	for key, val := range props {
+		cp[key] = val
+	}
+	return cp
+}
+
+// diffProperties returns patches which transform origin into target.
+func diffProperties(origin, target map[string]interface{}) ([]*v1.PatchData, error) {
+	diff := diffKeys(origin, target)
+	pds := make([]*v1.PatchData, 0)
+	for _, key := range append(diff.Added, diff.Changed...) {
+		bytes, err := json.Marshal(target[key])
+		if nil != err {
+			return nil, errors.Wrap(err, "encode property")
+		}
+
+		pds = append(pds, &v1.PatchData{
+			Path:     "properties." + key,
+			Operator: xjson.OpReplace.String(),
+			Value:    bytes,
+		})
+	}
+
+	for _, key := range diff.Removed {
+		pds = append(pds, &v1.PatchData{
+			Path:     "properties." + key,
+			Operator: xjson.OpRemove.String(),
+		})
+	}
+	return pds, nil
+}
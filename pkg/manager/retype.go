@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+)
+
+const (
+	fieldType          = "type"
+	fieldSchemaVersion = "schema_version"
+	fieldProperties    = "properties"
+	fieldTemplateID    = "template_id"
+)
+
+// ChangeType correct the type of entity in place, id, properties and mappers are preserved.
+// properties laid out by the old type are migrated by the migration chain of the new type from
+// version 0, then re-validated by validation hooks together with the new type, hooks reject
+// properties incompatible with schema of the new type. the entity adopts the schema version
+// reached by migration, and runtime flush reindexes the search document with the new type.
+// entities of all types share one search index and no etcd resources keyed by type, nothing rekeyed.
+func (m *apiManager) ChangeType(ctx context.Context, id, newType string) (*BaseRet, error) {
+	if newType == "" {
+		return nil, errors.Wrap(xerrors.ErrInvalidParam, "change type, empty type")
+	}
+
+	ret, err := m.GetEntity(ctx, &Base{ID: id})
+	if nil != err {
+		return nil, errors.Wrap(err, "change type")
+	} else if ret.Type == newType {
+		return ret, nil
+	}
+
+	migrated, err := m.retyped(ret, newType)
+	if nil != err {
+		log.L().Warn("change type, migrate properties", logf.Eid(id),
+			logf.Type(newType), logf.Error(err))
+		return nil, errors.Wrap(err, "change type")
+	}
+
+	changes, err := typeChanges(ret, migrated)
+	if nil != err {
+		return nil, errors.Wrap(err, "change type")
+	}
+	if err = m.validate(ctx, id, changes); nil != err {
+		log.L().Warn("change type, properties incompatible", logf.Eid(id),
+			logf.Type(newType), logf.Error(err))
+		return nil, err
+	}
+
+	versionBytes, _ := json.Marshal(migrated.SchemaVersion)
+	changes = append(changes, &v1.PatchData{Path: fieldSchemaVersion, Operator: xjson.OpReplace.String(), Value: versionBytes})
+	// reject if entity changed since validated.
+	out, _, err := m.PatchEntity(ctx, &Base{ID: id, Owner: ret.Owner}, changes, NewVersionOption(ret.Version))
+	if nil != err {
+		return nil, errors.Wrap(err, "change type")
+	}
+
+	log.L().Info("change type", logf.Eid(id),
+		logf.Any("from", ret.Type), logf.Type(newType))
+	return out, nil
+}
+
+// retyped returns copy of the entity as of the new type, with properties migrated from schema
+// version 0 of the new type.
+func (m *apiManager) retyped(ret *BaseRet, newType string) (*BaseRet, error) {
+	bytes, err := json.Marshal(ret.Properties)
+	if nil != err {
+		return nil, errors.Wrap(err, "encode properties")
+	}
+
+	out := &BaseRet{ID: ret.ID, Type: newType, Owner: ret.Owner, Source: ret.Source}
+	if err = json.Unmarshal(bytes, &out.Properties); nil != err {
+		return nil, errors.Wrap(err, "decode properties")
+	}
+	return out, errors.Wrap(m.migrate(out), "migrate properties")
+}
+
+// typeChanges returns the type change followed by all migrated properties, validated as a whole,
+// properties dropped by migration removed.
+func typeChanges(ret, migrated *BaseRet) ([]*v1.PatchData, error) {
+	typeBytes, _ := json.Marshal(migrated.Type)
+	changes := []*v1.PatchData{{Path: fieldType, Operator: xjson.OpReplace.String(), Value: typeBytes}}
+	for key := range ret.Properties {
+		if _, has := migrated.Properties[key]; !has {
+			changes = append(changes, &v1.PatchData{
+				Path:     fieldProperties + "." + key,
+				Operator: xjson.OpRemove.String(),
+			})
+		}
+	}
+	for key, val := range migrated.Properties {
+		bytes, err := json.Marshal(val)
+		if nil != err {
+			return nil, errors.Wrap(err, "encode property")
+		}
+		changes = append(changes, &v1.PatchData{
+			Path:     fieldProperties + "." + key,
+			Operator: xjson.OpReplace.String(),
+			Value:    bytes,
+		})
+	}
+	return changes, nil
+}
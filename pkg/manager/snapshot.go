@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+	"github.com/tkeel-io/tdtl"
+)
+
+// Snapshot prior state of an entity captured before a write, identified by the entity version.
+type Snapshot struct {
+	ID        string   `json:"id"`
+	Version   int64    `json:"version"`
+	Timestamp int64    `json:"timestamp"`
+	Entity    *BaseRet `json:"entity"`
+}
+
+// captureSnapshot keep current state of the entity before a write, absent entity skipped.
+// state read from the runtime, the state store lags writes not flushed yet.
+func (m *apiManager) captureSnapshot(ctx context.Context, id string) error {
+	base, bytes, err := m.PatchEntity(ctx, &Base{ID: id}, nil)
+	if nil != err {
+		if xerrors.IsEntityNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "capture snapshot")
+	}
+
+	err = m.entityRepo.PutSnapshot(ctx, id, &repository.Snapshot{
+		ID:        strconv.FormatInt(base.Version, 10),
+		Version:   base.Version,
+		Timestamp: time.Now().UnixNano() / 1e6,
+		Data:      bytes,
+	}, m.snapshotLimit)
+	return errors.Wrap(err, "capture snapshot")
+}
+
+// ListSnapshots returns snapshots of the entity, latest first.
+func (m *apiManager) ListSnapshots(ctx context.Context, id string) ([]*Snapshot, error) {
+	items, err := m.entityRepo.ListSnapshots(ctx, id)
+	if nil != err {
+		log.L().Error("list snapshots", logf.Eid(id), logf.Error(err))
+		return nil, errors.Wrap(err, "list snapshots")
+	}
+
+	snapshots := make([]*Snapshot, 0, len(items))
+	for index := len(items) - 1; index >= 0; index-- {
+		base, err := DecodeBase(items[index].Data)
+		if nil != err {
+			log.L().Warn("list snapshots, decode snapshot", logf.Eid(id),
+				logf.ID(items[index].ID), logf.Error(err))
+			continue
+		}
+		snapshots = append(snapshots, &Snapshot{
+			ID:        items[index].ID,
+			Version:   items[index].Version,
+			Timestamp: items[index].Timestamp,
+			Entity:    base,
+		})
+	}
+	return snapshots, nil
+}
+
+// RestoreSnapshot restore properties and configs of the entity to the snapshot, written through
+// runtime like any other write, so subscribers notified of the changes, the state before restore
+// snapshotted in turn, and the entity reindexed.
+func (m *apiManager) RestoreSnapshot(ctx context.Context, id, snapshotID string) (*BaseRet, error) {
+	items, err := m.entityRepo.ListSnapshots(ctx, id)
+	if nil != err {
+		log.L().Error("restore snapshot", logf.Eid(id), logf.Error(err))
+		return nil, errors.Wrap(err, "restore snapshot")
+	}
+
+	var snapshot *repository.Snapshot
+	for _, item := range items {
+		if item.ID == snapshotID {
+			snapshot = item
+		}
+	}
+	if snapshot == nil {
+		return nil, errors.Wrapf(xerrors.ErrSnapshotNotFound, "restore snapshot %s of entity %s", snapshotID, id)
+	}
+
+	var base Base
+	if err = json.Unmarshal(snapshot.Data, &base); nil != err {
+		return nil, errors.Wrap(err, "restore snapshot, decode snapshot")
+	}
+
+	var pds []*v1.PatchData
+	state := tdtl.New(snapshot.Data)
+	for _, field := range []string{fieldProperties, FieldScheme} {
+		if val := state.Get(field); val.Type() == tdtl.Object {
+			pds = append(pds, &v1.PatchData{
+				Path:     field,
+				Operator: xjson.OpReplace.String(),
+				Value:    val.Raw(),
+			})
+		}
+	}
+
+	ret, raw, err := m.PatchEntity(ctx, &Base{ID: id, Type: base.Type, Owner: base.Owner, Source: base.Source}, pds)
+	if nil != err {
+		log.L().Error("restore snapshot", logf.Eid(id), logf.ID(snapshotID), logf.Error(err))
+		return nil, errors.Wrap(err, "restore snapshot")
+	}
+
+	if err = m.indexEntity(ctx, id, raw); nil != err {
+		log.L().Warn("restore snapshot, index entity, left to runtime flush",
+			logf.Eid(id), logf.Error(err))
+	}
+
+	log.L().Info("restore snapshot", logf.Eid(id), logf.ID(snapshotID))
+	return ret, nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+)
+
+const (
+	tagPageSize = 100
+	// tag at most 50 entities per second.
+	defaultTagInterval = 20 * time.Millisecond
+)
+
+// TagByQuery apply tags to entities matching the search request page by page, returns count of entities updated.
+// entities already carrying the tags are skipped, on failure query.PageNum points to the failed page,
+// search again with the same request to resume.
+func (m *apiManager) TagByQuery(ctx context.Context, query *v1.SearchRequest, tags map[string]string) (int, error) {
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("tag by query", logf.Error(err))
+		return 0, err
+	}
+
+	if m.searchClient == nil {
+		log.L().Error("tag by query, search client nil")
+		return 0, errors.Wrap(xerrors.ErrConnectionNil, "tag by query")
+	}
+
+	for key := range tags {
+		if key == "" || strings.Contains(key, ".") {
+			return 0, errors.Wrapf(xerrors.ErrInvalidParam, "tag by query, invalid tag key %q", key)
+		}
+	}
+
+	if query.PageNum < 1 {
+		query.PageNum = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = tagPageSize
+	}
+
+	interval := m.tagInterval
+	if interval <= 0 {
+		interval = defaultTagInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	updated := 0
+	for {
+		resp, err := m.searchClient.Search(ctx, query)
+		if nil != err {
+			log.L().Error("tag by query, search entities", logf.Error(err), logf.Any("page", query.PageNum))
+			return updated, errors.Wrap(err, "tag by query")
+		}
+
+		for _, item := range resp.Items {
+			kv, ok := item.AsInterface().(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			id, _ := kv["id"].(string)
+			owner, _ := kv["owner"].(string)
+			pds := tagPatches(kv, tags)
+			if id == "" || len(pds) == 0 {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return updated, errors.Wrap(ctx.Err(), "tag by query")
+			case <-ticker.C:
+			}
+
+			if _, _, err = m.PatchEntity(ctx, &Base{ID: id, Owner: owner}, pds); nil != err {
+				log.L().Error("tag by query, tag entity", logf.Eid(id), logf.Error(err))
+				return updated, errors.Wrap(err, "tag by query")
+			}
+			updated++
+		}
+
+		if len(resp.Items) < int(query.PageSize) {
+			break
+		}
+		query.PageNum++
+	}
+
+	log.L().Info("tag by query completed", logf.Count(int64(updated)))
+	return updated, nil
+}
+
+// tagPatches returns patches applying tags missing from the search item.
+func tagPatches(item map[string]interface{}, tags map[string]string) []*v1.PatchData {
+	current, _ := item["tags"].(map[string]interface{})
+	var pds []*v1.PatchData
+	for key, value := range tags {
+		if val, ok := current[key].(string); ok && val == value {
+			continue
+		}
+
+		bytes, _ := json.Marshal(value)
+		pds = append(pds, &v1.PatchData{
+			Path:     FieldTags + "." + key,
+			Operator: xjson.OpReplace.String(),
+			Value:    bytes,
+		})
+	}
+	return pds
+}
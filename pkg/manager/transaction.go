@@ -0,0 +1,318 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/runtime"
+	"github.com/tkeel-io/core/pkg/types"
+	"github.com/tkeel-io/core/pkg/util"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+	"github.com/tkeel-io/tdtl"
+)
+
+type txOp struct {
+	eid     string
+	deleted bool
+	pds     []*v1.PatchData
+}
+
+// Tx stages entity writes, applied by Transaction in staged order.
+type Tx struct {
+	ops []txOp
+}
+
+// Set stage replacing properties of the entity.
+func (tx *Tx) Set(en *Base, props map[string]interface{}) error {
+	pds := make([]*v1.PatchData, 0, len(props))
+	for key, val := range props {
+		bytes, err := json.Marshal(val)
+		if nil != err {
+			return errors.Wrap(err, "transaction set properties")
+		}
+		pds = append(pds, &v1.PatchData{
+			Path:     "properties." + key,
+			Operator: xjson.OpReplace.String(),
+			Value:    bytes,
+		})
+	}
+
+	tx.ops = append(tx.ops, txOp{eid: en.ID, pds: pds})
+	return nil
+}
+
+// Patch stage patches of the entity.
+func (tx *Tx) Patch(en *Base, pds []*v1.PatchData) {
+	tx.ops = append(tx.ops, txOp{eid: en.ID, pds: pds})
+}
+
+// Each visit staged writes in staged order, pds nil for deletes.
+func (tx *Tx) Each(fn func(eid string, pds []*v1.PatchData, deleted bool)) {
+	for _, op := range tx.ops {
+		fn(op.eid, op.pds, op.deleted)
+	}
+}
+
+// Delete stage deleting the entity.
+func (tx *Tx) Delete(en *Base) {
+	tx.ops = append(tx.ops, txOp{eid: en.ID, deleted: true})
+}
+
+// entities returns sorted ids of entities written by the transaction.
+func (tx *Tx) entities() []string {
+	seen := make(map[string]bool)
+	eids := make([]string, 0, len(tx.ops))
+	for _, op := range tx.ops {
+		if !seen[op.eid] {
+			seen[op.eid] = true
+			eids = append(eids, op.eid)
+		}
+	}
+	sort.Strings(eids)
+	return eids
+}
+
+// txState state of an entity written by a transaction.
+type txState struct {
+	// state after the transaction, nil for deleted entity.
+	state *tdtl.Collect
+	// size of state before the transaction.
+	size int
+	// paths written, in written order.
+	paths []string
+}
+
+// patches returns patches bringing any state to the committed values of written paths,
+// replaying them is idempotent, unlike appends staged.
+func (s *txState) patches() []*v1.PatchData {
+	seen := make(map[string]bool)
+	pds := make([]*v1.PatchData, 0, len(s.paths))
+	for _, path := range s.paths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if val := s.state.Get(path); val.Type() != tdtl.Null && val.Type() != tdtl.Undefined {
+			pds = append(pds, &v1.PatchData{
+				Path:     path,
+				Operator: xjson.OpReplace.String(),
+				Value:    val.Raw(),
+			})
+			continue
+		}
+		pds = append(pds, &v1.PatchData{
+			Path:     path,
+			Operator: xjson.OpRemove.String(),
+		})
+	}
+	return pds
+}
+
+// lockEntitiesWrite acquire write locks of the entities in sorted order, returns the unlock function.
+func (m *apiManager) lockEntitiesWrite(ctx context.Context, eids []string) (func(), error) {
+	unlocks := make([]func(), 0, len(eids))
+	unlockAll := func() {
+		for index := len(unlocks) - 1; index >= 0; index-- {
+			unlocks[index]()
+		}
+	}
+
+	for _, eid := range eids {
+		unlock, err := m.lockEntityWrite(ctx, eid)
+		if nil != err {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return unlockAll, nil
+}
+
+// Transaction apply writes staged by fn to the state of several entities atomically,
+// nothing is written if fn or any staged write fails.
+// staged patches are checked like PatchEntity, by validation hooks, property acls, constraints
+// and the quota, and entities are write locked until committed states merged into the runtime.
+// all entities must live in the same state store. with the state store sharded, see
+// dapr store shard_store_names, states of entities are hashed to shards by state key and
+// transactions of entities on different shards fail with ErrCrossShardTransaction.
+func (m *apiManager) Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	if err := m.checkWritable(); nil != err {
+		log.L().Warn("entity transaction", logf.Error(err))
+		return err
+	}
+
+	tx := &Tx{}
+	if err := fn(tx); nil != err {
+		return errors.Wrap(err, "entity transaction")
+	}
+
+	for _, op := range tx.ops {
+		if err := m.validate(ctx, op.eid, op.pds); nil != err {
+			return errors.Wrap(err, "entity transaction")
+		}
+	}
+
+	unlock, err := m.lockEntitiesWrite(ctx, tx.entities())
+	if nil != err {
+		log.L().Warn("entity transaction, lock entities", logf.Error(err))
+		return err
+	}
+	defer unlock()
+
+	// write back states buffered by runtime.
+	if err = m.entityRepo.FlushEntity(ctx); nil != err {
+		log.L().Error("entity transaction, flush entity", logf.Error(err))
+		return errors.Wrap(err, "entity transaction")
+	}
+
+	var states map[string]*txState
+	if err = m.entityRepo.Transaction(ctx, func(rtx *repository.Tx) error {
+		var innerErr error
+		if states, innerErr = applyTxOps(ctx, m.entityRepo, tx.ops); nil != innerErr {
+			return innerErr
+		}
+
+		growths := make(map[string]int64)
+		for eid, state := range states {
+			if nil == state.state {
+				rtx.DelEntity(eid)
+				continue
+			}
+			growths[stateTenant(state.state.Raw())] += int64(len(state.state.Raw()) - state.size)
+			rtx.PutEntity(eid, state.state.Raw())
+		}
+		return m.checkGrowth(ctx, growths)
+	}); nil != err {
+		log.L().Error("entity transaction", logf.Error(err))
+		return errors.Wrap(err, "entity transaction")
+	}
+
+	var deleted []string
+	for eid, state := range states {
+		if nil == state.state {
+			deleted = append(deleted, eid)
+			continue
+		}
+
+		// merge committed states into states cached by runtime, changes propagate like patches.
+		if err = m.reloadEntity(ctx, eid, state.patches()); nil != err {
+			log.L().Warn("entity transaction, reload entity", logf.Eid(eid), logf.Error(err))
+		}
+		m.resizeEntity(ctx, eid, stateTenant(state.state.Raw()), int64(len(state.state.Raw())))
+		m.sensitive.remove(eid)
+	}
+
+	for _, eid := range deleted {
+		if err := m.DeleteEntity(ctx, &Base{ID: eid}); nil != err {
+			log.L().Warn("entity transaction, delete entity", logf.Eid(eid), logf.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// applyTxOps returns entity states after applying ops, ops violating property constraints rejected.
+func applyTxOps(ctx context.Context, repo repository.IRepository, ops []txOp) (map[string]*txState, error) {
+	states := make(map[string]*txState)
+	for _, op := range ops {
+		txs, has := states[op.eid]
+		if op.deleted {
+			states[op.eid] = &txState{}
+			continue
+		} else if !has {
+			bytes, err := repo.GetEntity(ctx, op.eid)
+			if nil != err {
+				return nil, errors.Wrapf(err, "load entity %s", op.eid)
+			}
+			txs = &txState{state: tdtl.New(bytes), size: len(bytes)}
+			states[op.eid] = txs
+		} else if nil == txs.state {
+			return nil, errors.Wrapf(xerrors.ErrEntityNotFound, "entity %s deleted", op.eid)
+		}
+
+		state := txs.state
+		if err := runtime.CheckWrite(ctx, op.eid, state.Raw(), op.pds); nil != err {
+			return nil, errors.Wrapf(err, "patch entity %s", op.eid)
+		}
+
+		for _, pd := range op.pds {
+			txs.paths = append(txs.paths, pd.Path)
+			switch xjson.NewPatchOp(pd.Operator) {
+			case xjson.OpReplace:
+				state.Set(pd.Path, tdtl.New(pd.Value))
+			case xjson.OpAdd:
+				state.Append(pd.Path, tdtl.New(pd.Value))
+			case xjson.OpRemove:
+				state.Del(pd.Path)
+			default:
+				return nil, errors.Wrapf(xerrors.ErrJSONPatchReservedOp, "entity %s", op.eid)
+			}
+
+			if err := state.GetError(); nil != err {
+				return nil, errors.Wrapf(err, "patch entity %s", op.eid)
+			}
+		}
+	}
+
+	return states, nil
+}
+
+// reloadEntity merge committed patches of the entity into the state cached by runtime.
+func (m *apiManager) reloadEntity(ctx context.Context, eid string, pds []*v1.PatchData) error {
+	bytes, err := json.Marshal(pds)
+	if nil != err {
+		return errors.Wrap(err, "reload entity, encode patches")
+	}
+
+	reqID := util.IG().ReqID()
+	respWaiter := m.holder.Wait(ctx, reqID)
+	if err = m.dispatcher.Dispatch(ctx, &v1.ProtoEvent{
+		Id:        util.IG().EvID(),
+		Timestamp: time.Now().UnixNano(),
+		Callback:  m.callbackAddr(),
+		Metadata: map[string]string{
+			v1.MetaBorn:      bornReload,
+			v1.MetaType:      sysET,
+			v1.MetaRequestID: reqID,
+			v1.MetaEntityID:  eid,
+		},
+		Data: &v1.ProtoEvent_SystemData{
+			SystemData: &v1.SystemData{
+				Operator: string(v1.OpReload),
+				Data:     bytes,
+			},
+		},
+	}); nil != err {
+		respWaiter.Cancel()
+		return errors.Wrap(err, "reload entity, dispatch event")
+	}
+
+	if resp := respWaiter.Wait(); resp.Status != types.StatusOK {
+		return xerrors.New(resp.ErrCode)
+	}
+	return nil
+}
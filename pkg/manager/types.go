@@ -19,11 +19,15 @@ package manager
 import (
 	"context"
 	"errors"
+	"strconv"
+	"time"
 
 	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
 	"github.com/tkeel-io/core/pkg/manager/holder"
 	"github.com/tkeel-io/core/pkg/mapper"
 	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/runtime"
 )
 
 const CoreAPISender = "core.api"
@@ -32,35 +36,171 @@ type TemplateEntityID struct{}
 
 var (
 	ErrMapperTQLInvalid    = errors.New("invalid TQL")
-	ErrEntityNotFound      = errors.New("not found")
-	ErrEntityAreadyExisted = errors.New("entity already existed")
+	ErrEntityNotFound      = xerrors.ErrEntityNotFound
+	ErrEntityAreadyExisted = xerrors.ErrEntityAleadyExists
 )
 
 type APIManager interface {
 	// OnRespond handle message.
 	OnRespond(context.Context, *holder.Response)
+	// SetMaintenanceMode reject all writes when maintenance mode on.
+	SetMaintenanceMode(bool)
+	// MaintenanceMode returns whether maintenance mode on.
+	MaintenanceMode() bool
+	// AddValidationHook append property validation hook.
+	AddValidationHook(ValidationHook, time.Duration)
+	// AddDrainer append drainer of buffered data, drained by Drain.
+	AddDrainer(string, Drainer)
+	// Drain flush data buffered by drainers, error if data still pending when context done.
+	Drain(context.Context) error
+	// Stop drain buffered data, then stop background tasks.
+	Stop(context.Context) error
+	// AddMigration register entity schema migration of type from version.
+	AddMigration(string, int64, MigrateFunc)
+	// SetConfigsByType merge configs into all entities of the type.
+	SetConfigsByType(context.Context, string, map[string]interface{}) (int, error)
+	// FinishDeletes complete entity deletes interrupted half way.
+	FinishDeletes(context.Context) (int, error)
+	// FinishIndexes index entities created while indexing failed.
+	FinishIndexes(context.Context) (int, error)
+	// SetSearchClient set search client used by maintenance tasks.
+	SetSearchClient(v1.SearchHTTPServer)
+	// SetLivenessSource set source of entity liveness.
+	SetLivenessSource(LivenessSource)
+	// GetLiveness returns liveness of entity.
+	GetLiveness(context.Context, string) (*runtime.Liveness, error)
 	// CreateEntity create entity.
 	CreateEntity(context.Context, *Base) (*BaseRet, error)
+	// GetOrCreateEntity returns entity, create it if absent, reports whether created.
+	GetOrCreateEntity(context.Context, *Base) (*BaseRet, bool, error)
+	// EntitiesExist reports existence of entities keyed by entity id.
+	EntitiesExist(context.Context, []string) (map[string]bool, error)
 	// UpdateEntity update entity.
 	PatchEntity(context.Context, *Base, []*v1.PatchData, ...Option) (*BaseRet, []byte, error)
 	// DeleteEntity delete entity.
 	DeleteEntity(context.Context, *Base) error
+	// PatchEntities apply patches to entities in batch, returns entities and errors keyed by entity id.
+	PatchEntities(context.Context, []string, []*v1.PatchData) (map[string]*BaseRet, map[string]error)
+	// DeleteEntities delete entities in batch, returns errors keyed by entity id.
+	DeleteEntities(context.Context, []string, DeleteOptions) map[string]error
+	// MoveEntities move entities to the target tenant, returns errors keyed by entity id.
+	MoveEntities(context.Context, []string, string) map[string]error
+	// CloneEntity create the entity as clone of the source entity, mappers copied along.
+	CloneEntity(context.Context, string, *Base, CloneOptions) (*BaseRet, error)
+	// Reindex rebuild search documents of entities, reports progress periodically.
+	Reindex(context.Context, []string, ReindexOptions) (ReindexStats, error)
+	// RepairIndex re-index entity in the background if its indexed document lags the state.
+	RepairIndex(string, map[string]interface{}) bool
+	// ChangeType change type of entity preserving its id.
+	ChangeType(context.Context, string, string) (*BaseRet, error)
+	// ListSnapshots returns prior states of entity, latest first.
+	ListSnapshots(context.Context, string) ([]*Snapshot, error)
+	// RestoreSnapshot restore entity to the snapshot.
+	RestoreSnapshot(context.Context, string, string) (*BaseRet, error)
+	// FreezeEntity freeze or unfreeze entity.
+	FreezeEntity(context.Context, *Base, bool) error
+	// SetAttributes set attributes of entity, attributes of empty values removed.
+	SetAttributes(context.Context, *Base, map[string]string) (*BaseRet, error)
+	// GetAttributes returns attributes of entity.
+	GetAttributes(context.Context, *Base) (map[string]string, error)
+	// DeleteProperty remove the property of the path, absent property tolerated if idempotent.
+	DeleteProperty(context.Context, *Base, string, bool) (*BaseRet, error)
+	// PurgeTombstones hard delete entities soft deleted before the duration.
+	PurgeTombstones(context.Context, time.Duration) (int, error)
+	// CompactMappers remove mapper expressions of entities absent in state, optionally compact etcd.
+	CompactMappers(context.Context, bool) (int, error)
+	// ListTypes returns distinct entity types of the owner with entity counts.
+	ListTypes(context.Context, string) ([]TypeInfo, error)
+	// ListAllMappers returns mappers of all entities page by page.
+	ListAllMappers(context.Context, string, int) ([]MapperRef, string, error)
+	// TagByQuery apply tags to entities matching the search request.
+	TagByQuery(context.Context, *v1.SearchRequest, map[string]string) (int, error)
+	// QueryFields returns the fields of entities matching the search request.
+	QueryFields(context.Context, *v1.SearchRequest, []string) ([]map[string]interface{}, error)
 	// GetProperties returns entity properties.
 	GetEntity(context.Context, *Base) (*BaseRet, error)
+	// Materialize load entity into runtime, returns once runtime confirmed.
+	Materialize(context.Context, string) error
+	// WaitForEntity wait until entity visible.
+	WaitForEntity(context.Context, string, time.Duration) error
+	// WatchEntity streams entity changes, optionally the current entity first.
+	WatchEntity(context.Context, string, WatchOptions) (<-chan *BaseRet, error)
+	// SetPropertiesWithRetry read-modify-write entity properties, retry on version conflict.
+	SetPropertiesWithRetry(context.Context, *Base, MutateFunc, int) (*BaseRet, error)
+	// Transaction apply staged writes of several entities atomically.
+	Transaction(context.Context, func(*Tx) error) error
+	// DiffEntity compare entity with a snapshot.
+	DiffEntity(context.Context, string, *BaseRet) (*EntityDiff, error)
+	// DumpEntity returns raw state of entity for debugging, admins of the owning tenant only.
+	DumpEntity(context.Context, string) (*EntityDump, error)
 	// AppendMapper append entity mapper.
 	AppendMapper(context.Context, *mapper.Mapper) error
 	AppendMapperZ(context.Context, *mapper.Mapper) error
+	// AppendMappers append mappers in batch, returns results of each mapper.
+	AppendMappers(context.Context, []*mapper.Mapper, AppendMode) ([]MapperResult, error)
+	// SetMapperEnabled enable or disable entity mapper.
+	SetMapperEnabled(context.Context, *Base, string, bool) error
+	// DeleteMapperByName remove entity mapper by name.
+	DeleteMapperByName(context.Context, string, string) error
+
+	// Group.
+	CreateGroup(context.Context, *repository.Group) error
+	GetGroup(context.Context, string) (*repository.Group, error)
+	ListGroups(context.Context, string) ([]*repository.Group, error)
+	DeleteGroup(context.Context, string) error
+	AddToGroup(context.Context, string, *Base) error
+	RemoveFromGroup(context.Context, string, *Base) error
+	ListGroupMembers(context.Context, string) ([]string, error)
+	ListEntityGroups(context.Context, string) ([]string, error)
 
 	// Expression.
 	AppendExpression(context.Context, []repository.Expression) error
 	RemoveExpression(context.Context, []repository.Expression) error
 	GetExpression(context.Context, repository.Expression) (*repository.Expression, error)
 	ListExpression(context.Context, *Base) ([]*repository.Expression, error)
+	ListExpressionCached(context.Context, *Base) ([]*repository.Expression, bool, error)
+	GetProvenance(context.Context, *Base) (map[string]Provenance, error)
+	// GetDependencyGraph returns source properties of each derived property of the entity.
+	GetDependencyGraph(context.Context, string) (*DepGraph, error)
+
+	// Quota.
+	GetQuotaUsage(context.Context, string) (*repository.QuotaUsage, error)
 
 	// Subscription.
 	CreateSubscription(context.Context, *repository.Subscription) error
 	DeleteSubscription(context.Context, *repository.Subscription) error
 	GetSubscription(context.Context, *repository.Subscription) (*repository.Subscription, error)
+	// CreateMirror mirror source entity property to target entity property.
+	CreateMirror(context.Context, string, string, string, string, MirrorMode) (*Mirror, error)
+}
+
+// FieldDeletedAt marks a soft deleted(tombstoned) entity.
+const FieldDeletedAt = "deleted_at"
+
+// FieldFrozen marks a frozen entity, FieldFrozenSources lists frozen entities
+// that mapped properties derived from, keyed by property path.
+const (
+	FieldFrozen        = runtime.FieldFrozen
+	FieldFrozenSources = runtime.FieldFrozenSources
+)
+
+// FieldAttributes holds opaque client metadata of entity, neither validated nor indexed.
+const FieldAttributes = "attributes"
+
+// FieldWriters records the last writer of properties, mirroring property paths.
+const FieldWriters = "writers"
+
+// FieldTags holds entity labels.
+const FieldTags = "properties.tags"
+
+// FieldScheme holds entity configs.
+const FieldScheme = "scheme"
+
+type DeleteOptions struct {
+	// Owner of entities, used to cleanup entity expressions.
+	Owner string
+	// Soft tombstone entities instead of purging them.
+	Soft bool
 }
 
 type Metadata map[string]string
@@ -72,3 +212,51 @@ func NewPathConstructorOption(pc v1.PathConstructor) Option {
 		meta[v1.MetaPathConstructor] = string(pc)
 	}
 }
+
+// NewSequenceOption sequence number of the message among messages of the entity,
+// applied in sequence order if ordering enabled for the entity, see config.OrderingConfig.
+func NewSequenceOption(seq int64) Option {
+	return func(meta Metadata) {
+		meta[v1.MetaSequence] = strconv.FormatInt(seq, 10)
+	}
+}
+
+// merge conflict strategies of NewMergeStrategyOption, custom strategies registered in runtime.
+const (
+	MergeLastWriteWins = runtime.MergeLastWriteWins
+	MergeKeepExisting  = runtime.MergeKeepExisting
+)
+
+// NewMergeStrategyOption resolve merge patches changing existing nested values by the strategy,
+// conflicts reported in BaseRet.Conflicts, merges are last-write-wins without reporting by default.
+func NewMergeStrategyOption(strategy string) Option {
+	return func(meta Metadata) {
+		meta[v1.MetaMergeStrategy] = strategy
+	}
+}
+
+// MergeConflict a key of merge patch changed an existing value, Resolved the value kept.
+type MergeConflict = runtime.Conflict
+
+// NewNamespaceOption store written properties under keys namespaced by the source namespace,
+// so sources writing the same keys never clobber each other, see types.NamespaceSeparator.
+func NewNamespaceOption(namespace string) Option {
+	return func(meta Metadata) {
+		meta[v1.MetaNamespace] = namespace
+	}
+}
+
+// NewSyncIndexOption index the entity before the patch returns even if search indexing batched,
+// so listings right after the patch reflect it, see config.IndexBatchConfig.
+func NewSyncIndexOption() Option {
+	return func(meta Metadata) {
+		meta[v1.MetaSyncIndex] = "true"
+	}
+}
+
+// NewVersionOption patch entity only if the entity version matches.
+func NewVersionOption(version int64) Option {
+	return func(meta Metadata) {
+		meta[v1.MetaVersion] = strconv.FormatInt(version, 10)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"strings"
+	"time"
+)
+
+// Value property value decoded from entity state, typed accessors report whether
+// the value holds the type instead of panicking on bad assertions.
+type Value struct {
+	raw interface{}
+}
+
+func NewValue(raw interface{}) Value {
+	return Value{raw: raw}
+}
+
+// Raw returns the underlying value.
+func (v Value) Raw() interface{} {
+	return v.raw
+}
+
+// IsNil reports whether property absent or null.
+func (v Value) IsNil() bool {
+	return v.raw == nil
+}
+
+func (v Value) AsString() (string, bool) {
+	val, ok := v.raw.(string)
+	return val, ok
+}
+
+func (v Value) AsBool() (bool, bool) {
+	val, ok := v.raw.(bool)
+	return val, ok
+}
+
+// AsFloat64 returns numeric value of any int, uint, float or json number.
+func (v Value) AsFloat64() (float64, bool) {
+	switch val := v.raw.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int8:
+		return float64(val), true
+	case int16:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case uint:
+		return float64(val), true
+	case uint8:
+		return float64(val), true
+	case uint16:
+		return float64(val), true
+	case uint32:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	case interface{ Float64() (float64, error) }:
+		// json.Number.
+		f, err := val.Float64()
+		return f, nil == err
+	default:
+		return 0, false
+	}
+}
+
+// AsTime returns time of RFC3339 string or numeric unix timestamp in milliseconds,
+// which is how entity timestamps are stored.
+func (v Value) AsTime() (time.Time, bool) {
+	switch val := v.raw.(type) {
+	case time.Time:
+		return val, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, val)
+		return t, nil == err
+	}
+
+	if ms, ok := v.AsFloat64(); ok {
+		return time.UnixMilli(int64(ms)), true
+	}
+	return time.Time{}, false
+}
+
+// GetProperty returns property of the path, e.g. "metrics.cpu".
+func (b *BaseRet) GetProperty(path string) Value {
+	var cur interface{} = b.Properties
+	for _, segment := range strings.Split(path, ".") {
+		kv, ok := cur.(map[string]interface{})
+		if !ok {
+			return Value{}
+		}
+		cur = kv[segment]
+	}
+	return Value{raw: cur}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/kit/log"
+)
+
+const defaultWatchInterval = 500 * time.Millisecond
+
+// WatchOptions options of WatchEntity.
+type WatchOptions struct {
+	// Snapshot emit the current entity as the first item.
+	Snapshot bool
+	// Interval of polling the state store.
+	Interval time.Duration
+}
+
+// WatchEntity streams changes of the entity observed from the state store, the channel closed
+// when ctx done or the entity deleted. the snapshot and the watch baseline come from the same read,
+// every later item carries a greater version than the snapshot, so no change after the snapshot is
+// missed. changes between two polls are coalesced into the latest one.
+func (m *apiManager) WatchEntity(ctx context.Context, id string, opts WatchOptions) (<-chan *BaseRet, error) {
+	current, err := m.loadEntity(ctx, id)
+	if nil != err {
+		log.L().Error("watch entity", logf.Eid(id), logf.Error(err))
+		return nil, errors.Wrap(err, "watch entity")
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	ch := make(chan *BaseRet, 1)
+	if opts.Snapshot {
+		ch <- current
+	}
+
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		version := current.Version
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			baseRet, err := m.loadEntity(ctx, id)
+			if xerrors.IsEntityNotFound(err) {
+				log.L().Info("watch entity, entity deleted", logf.Eid(id))
+				return
+			} else if nil != err {
+				log.L().Warn("watch entity", logf.Eid(id), logf.Error(err))
+				continue
+			} else if baseRet.Version <= version {
+				continue
+			}
+
+			version = baseRet.Version
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- baseRet:
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// loadEntity read entity from the state store.
+func (m *apiManager) loadEntity(ctx context.Context, id string) (*BaseRet, error) {
+	bytes, err := m.entityRepo.GetEntity(ctx, id)
+	if nil != err {
+		return nil, errors.Wrap(err, "load entity")
+	}
+
+	baseRet, err := DecodeBase(bytes)
+	if nil != err {
+		return nil, errors.Wrap(err, "decode entity")
+	}
+	if err = m.migrate(baseRet); nil != err {
+		return nil, errors.Wrap(err, "load entity")
+	}
+	return baseRet, nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tkeel-io/core/pkg/types"
+)
+
+const (
+	writerUser   = "user:"
+	writerSystem = "system"
+)
+
+// PropertyWriter last writer of a property, "user:<user>" for api writes, "mapper:<names>"
+// for derived values, "system" for api writes without caller identity.
+type PropertyWriter struct {
+	Writer string
+	Time   time.Time
+}
+
+// writerFrom returns writer of api writes, the caller identity of ctx.
+func writerFrom(ctx context.Context) string {
+	if identity, ok := types.IdentityFrom(ctx); ok && identity.User != "" {
+		return writerUser + identity.User
+	}
+	return writerSystem
+}
+
+// GetWriter returns last writer of the property, false if untracked.
+func (b *BaseRet) GetWriter(path string) (PropertyWriter, bool) {
+	var cur interface{} = b.Writers["properties"]
+	for _, segment := range strings.Split(path, ".") {
+		kv, ok := cur.(map[string]interface{})
+		if !ok {
+			return PropertyWriter{}, false
+		}
+		cur = kv[segment]
+	}
+
+	record, ok := cur.(map[string]interface{})
+	if !ok {
+		return PropertyWriter{}, false
+	}
+	writer, ok := NewValue(record["_writer"]).AsString()
+	if !ok {
+		return PropertyWriter{}, false
+	}
+	ts, _ := NewValue(record["_ts"]).AsTime()
+	return PropertyWriter{Writer: writer, Time: ts}, true
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/mapper"
 	"github.com/tkeel-io/core/pkg/repository"
 	"github.com/tkeel-io/tdtl"
 )
@@ -25,7 +26,11 @@ type Expr struct {
 	exprIns tdtl.Expression
 }
 
+// NewExpr returns expression evaler, extFuncs nil resolves the registered TQL functions.
 func NewExpr(expression string, extFuncs map[string]tdtl.ContextFunc) (IExpression, error) {
+	if extFuncs == nil {
+		extFuncs = mapper.TQLFunctions()
+	}
 	exprIns, err := tdtl.NewExpr(expression, extFuncs)
 	return &Expr{exprIns: exprIns}, errors.Wrap(err, "new expression evaler")
 }
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"sync"
+
+	"github.com/tkeel-io/tdtl"
+)
+
+// TQLFunc custom function callable in mapper TQL, e.g. `select f2c(device123.temp) as temp`.
+//
+// args are the call arguments evaluated against the mapper input, in call order,
+// selectors of properties absent from the input evaluate to tdtl.UNDEFINED_RESULT.
+// The function returns the value of the call, tdtl.UNDEFINED_RESULT if the arguments
+// are invalid, which leaves the mapped property unset. Functions are called
+// concurrently by mappers of different entities and must not block.
+type TQLFunc = tdtl.ContextFunc
+
+var (
+	tqlFuncLock sync.RWMutex
+	tqlFuncs    = map[string]TQLFunc{}
+)
+
+// RegisterTQLFunction register custom TQL function, replaces the function registered by name if any.
+// Mappers and expressions resolve functions when constructed, register functions before loading mappers.
+func RegisterTQLFunction(name string, fn TQLFunc) {
+	tqlFuncLock.Lock()
+	defer tqlFuncLock.Unlock()
+	tqlFuncs[name] = fn
+}
+
+// TQLFunctions returns snapshot of registered TQL functions.
+func TQLFunctions() map[string]TQLFunc {
+	tqlFuncLock.RLock()
+	defer tqlFuncLock.RUnlock()
+	funcs := make(map[string]TQLFunc, len(tqlFuncs))
+	for name, fn := range tqlFuncs {
+		funcs[name] = fn
+	}
+	return funcs
+}
@@ -29,6 +29,11 @@ type Mapper struct {
 	Owner       string
 	EntityID    string
 	Description string
+	// Priority of mapper, when mappers write the same property the higher one wins,
+	// equal priorities tie-break by the greater expression id, see repository.Expression.Outranks.
+	Priority int
+	// AppliesToTypes entity types the mapper can be attached to, any type if empty.
+	AppliesToTypes []string
 }
 
 type mapper struct {
@@ -38,7 +43,7 @@ type mapper struct {
 }
 
 func NewMapper(mp Mapper, version int64) (IMapper, error) {
-	tqlInst, err := tdtl.NewTDTL(mp.TQL, nil)
+	tqlInst, err := tdtl.NewTDTL(mp.TQL, TQLFunctions())
 	if nil != err {
 		return nil, errors.Wrap(err, "construct mapper")
 	}
@@ -136,3 +136,62 @@ func TestMapper3(t *testing.T) {
 	t.Log("result: ", res)
 	assert.Equal(t, map[string]tdtl.Node{"sysField._spacePath": tdtl.StringNode("tom/b3a22c80-6afe-44a0-91b7-f1e49f3c962e"), "temp2": tdtl.IntNode(10)}, res)
 }
+
+func TestRebindTQL(t *testing.T) {
+	tql := "insert into device123 select device123.temp * 1.8 + device234.offset as temp_f, '/device123' as path"
+	rebound, err := RebindTQL(tql, "device123", "device123-clone")
+	assert.Nil(t, err)
+	assert.Equal(t, "insert into device123-clone select device123-clone.temp * 1.8 + device234.offset as temp_f, '/device123' as path", rebound)
+
+	// entity ids prefixed with source id left intact.
+	tql = "insert into device1234 select device1234.temp as temp"
+	rebound, err = RebindTQL(tql, "device123", "device999")
+	assert.Nil(t, err)
+	assert.Equal(t, tql, rebound)
+
+	// selectors of the source rebound whatever the target.
+	tql = "insert into sub123 select device123.* "
+	rebound, err = RebindTQL(tql, "device123", "device999")
+	assert.Nil(t, err)
+	assert.Equal(t, "insert into sub123 select device999.* ", rebound)
+
+	// self-referencing mapper of a clone, string literals and quoted selectors.
+	tql = `insert into device123 select device123.temp + device123.offset as temp, 'device123.temp' as src, "device123.mode" as mode`
+	rebound, err = RebindTQL(tql, "device123", "device123-clone")
+	assert.Nil(t, err)
+	assert.Equal(t, `insert into device123-clone select device123-clone.temp + device123-clone.offset as temp, 'device123.temp' as src, "device123-clone.mode" as mode`, rebound)
+}
+
+func TestRebindExpr(t *testing.T) {
+	rebound, err := RebindExpr("device123.temp * 1.8 + device234.offset", "device123", "device123-clone")
+	assert.Nil(t, err)
+	assert.Equal(t, "device123-clone.temp * 1.8 + device234.offset", rebound)
+
+	// expressions without references to the source left intact.
+	rebound, err = RebindExpr("device1234.temp + 'device123.temp'", "device123", "device999")
+	assert.Nil(t, err)
+	assert.Equal(t, "device1234.temp + 'device123.temp'", rebound)
+
+	_, err = RebindExpr("device123.temp +", "device123", "device999")
+	assert.NotNil(t, err)
+}
+
+func TestRegisterTQLFunction(t *testing.T) {
+	RegisterTQLFunction("f2c", func(args ...tdtl.Node) tdtl.Node {
+		if len(args) != 1 {
+			return tdtl.UNDEFINED_RESULT
+		}
+		if temp, ok := args[0].To(tdtl.Number).(tdtl.FloatNode); ok {
+			return (temp - 32) * 5 / 9
+		}
+		return tdtl.UNDEFINED_RESULT
+	})
+
+	m, err := NewMapper(Mapper{ID: "mapper123", TQL: "insert into device123 select f2c(device234.temp) as temp"}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{"device234": {"device234.temp"}}, m.SourceEntities())
+
+	out, err := m.Exec(map[string]tdtl.Node{"device234.temp": tdtl.FloatNode(212)})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]tdtl.Node{"temp": tdtl.FloatNode(100)}, out)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/tdtl"
+	"github.com/tkeel-io/tdtl/parser"
+)
+
+// RebindTQL rewrites references to entity fromID in TQL to entity toID, references located on the parsed TQL,
+// the insert target and the property selectors of fromID rewritten, references to other entities
+// and string literals left intact.
+func RebindTQL(tql, fromID, toID string) (string, error) {
+	tqlInst, err := tdtl.NewTDTL(tql, nil)
+	if nil != err {
+		return tql, errors.Wrap(err, "rebind TQL")
+	} else if _, ok := tqlInst.Entities()[fromID]; !ok && tqlInst.Target() != fromID {
+		return tql, nil
+	}
+
+	rebound := rebind(tql, fromID, toID, func(tqlParser *parser.TDTLParser) antlr.Tree {
+		return tqlParser.Root()
+	})
+	if tqlInst, err = tdtl.NewTDTL(rebound, nil); nil != err {
+		return tql, errors.Wrap(err, "rebind TQL")
+	} else if _, ok := tqlInst.Entities()[fromID]; ok || tqlInst.Target() == fromID {
+		return tql, errors.Wrap(xerrors.ErrInternal, "rebind TQL")
+	}
+	return rebound, nil
+}
+
+// RebindExpr rewrites references to entity fromID in a mapper expression, a select field of
+// mapper TQL as stored, to entity toID, see RebindTQL.
+func RebindExpr(expr, fromID, toID string) (string, error) {
+	exprInst, err := tdtl.NewExpr(expr, nil)
+	if nil != err {
+		return expr, errors.Wrap(err, "rebind expression")
+	} else if _, ok := exprInst.Sources()[fromID]; !ok {
+		return expr, nil
+	}
+
+	rebound := rebind(expr, fromID, toID, func(tqlParser *parser.TDTLParser) antlr.Tree {
+		return tqlParser.Field_elem()
+	})
+	if exprInst, err = tdtl.NewExpr(rebound, nil); nil != err {
+		return expr, errors.Wrap(err, "rebind expression")
+	} else if _, ok := exprInst.Sources()[fromID]; ok {
+		return expr, errors.Wrap(xerrors.ErrInternal, "rebind expression")
+	}
+	return rebound, nil
+}
+
+// rebind replaces references to entity fromID located on the tree parsed from src with toID.
+func rebind(src, fromID, toID string, tree func(*parser.TDTLParser) antlr.Tree) string {
+	tqlParser := parser.NewTDTLParser(antlr.NewCommonTokenStream(
+		parser.NewTDTLLexer(antlr.NewInputStream(src)), antlr.TokenDefaultChannel))
+	tqlParser.RemoveErrorListeners()
+	refs := &entityRefs{entityID: fromID}
+	antlr.ParseTreeWalkerDefault.Walk(refs, tree(tqlParser))
+	sort.Ints(refs.offsets)
+
+	// token offsets index runes of src.
+	var builder strings.Builder
+	runes, last := []rune(src), 0
+	for _, offset := range refs.offsets {
+		builder.WriteString(string(runes[last:offset]))
+		builder.WriteString(toID)
+		last = offset + len([]rune(fromID))
+	}
+	builder.WriteString(string(runes[last:]))
+	return builder.String()
+}
+
+// entityRefs collects offsets of references to the entity within the parsed TQL.
+type entityRefs struct {
+	parser.BaseTDTLListener
+	entityID string
+	offsets  []int
+}
+
+// ExitTarget the insert target.
+func (l *entityRefs) ExitTarget(ctx *parser.TargetContext) {
+	l.addIdentifier(ctx.INDENTIFIER())
+}
+
+// ExitSourceEntity the source of selectors of all properties, e.g. device123.*.
+func (l *entityRefs) ExitSourceEntity(ctx *parser.SourceEntityContext) {
+	l.addIdentifier(ctx.INDENTIFIER())
+}
+
+// ExitXpath_name the property selectors, e.g. device123.temp.
+func (l *entityRefs) ExitXpath_name(ctx *parser.Xpath_nameContext) {
+	for _, path := range ctx.AllDotnotation() {
+		if token := path.GetStart(); strings.HasPrefix(token.GetText(), l.entityID+".") {
+			l.offsets = append(l.offsets, token.GetStart())
+		}
+	}
+}
+
+func (l *entityRefs) addIdentifier(node antlr.TerminalNode) {
+	if node != nil && node.GetText() == l.entityID {
+		l.offsets = append(l.offsets, node.GetSymbol().GetStart())
+	}
+}
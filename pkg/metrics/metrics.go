@@ -12,16 +12,29 @@ const (
 	MetricsLabelTelemetryID = "telemetry_id"
 	MetricsLabelMsgType     = "msg_type"
 	MetricsLabelSpaceType   = "space_type"
+	MetricsLabelStage       = "stage"
+	MetricsLabelEntityType  = "entity_type"
+	MetricsLabelMapper      = "mapper"
+	MetricsLabelOutcome     = "outcome"
+	MetricsLabelReason      = "reason"
 
 	// msg type.
 	MsgTypeSubscribe  = "subscribe"
 	MsgTypeRawData    = "rawdata"
 	MsgTypeTimeseries = "timeseries"
 
+	// mapper evaluation outcome.
+	EvalFired   = "fired"
+	EvalSkipped = "skipped"
+	EvalErrored = "errored"
+
 	// space type.
 	SpaceTypeTotal = "total"
 	SpaceTypeUsed  = "used"
 
+	// ingress rejection reason.
+	RejectOversized = "oversized"
+
 	// metrics msg count name.
 	MetricsMsgCount = "core_msg_total"
 
@@ -39,6 +52,24 @@ const (
 
 	// metrics device telemetry.
 	EntityTelemetry = "entity_telemetry"
+
+	// metrics dead letter count name.
+	MetricsDeadLetterCount = "core_dead_letter_total"
+
+	// metrics purged tombstone count name.
+	MetricsPurgedTombstoneCount = "core_purged_tombstone_total"
+	// metrics mapper evaluation count name.
+	MetricsMapperEvalCount = "core_mapper_eval_total"
+	// metrics mapper evaluation latency name.
+	MetricsMapperEvalSeconds = "core_mapper_eval_seconds"
+	// metrics ingress queue depth name.
+	MetricsIngressQueueDepth = "core_ingress_queue_depth"
+	// metrics subscription delivery retry count name.
+	MetricsSubscriptionRetryCount = "core_subscription_retry_total"
+	// metrics ingress rejected message count name.
+	MetricsIngressRejectedCount = "core_ingress_rejected_total"
+	// metrics pending search index queue depth name.
+	MetricsIndexQueueDepth = "core_index_queue_depth"
 )
 
 var CollectorMsgCount = prometheus.NewCounterVec(
@@ -89,6 +120,69 @@ var CollectorTelemetry = prometheus.NewGaugeVec(
 	[]string{MetricsLabelTenant, MetricsLabelSchema, MetricsLabelEntity, MetricsLabelTelemetryID},
 )
 
+var CollectorDeadLetterCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: MetricsDeadLetterCount,
+		Help: "dead letter count.",
+	},
+	[]string{MetricsLabelStage},
+)
+
+var CollectorPurgedTombstoneCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: MetricsPurgedTombstoneCount,
+		Help: "purged tombstone count.",
+	},
+	[]string{MetricsLabelTenant},
+)
+
+var CollectorMapperEvalCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: MetricsMapperEvalCount,
+		Help: "mapper evaluation count.",
+	},
+	[]string{MetricsLabelEntityType, MetricsLabelMapper, MetricsLabelOutcome},
+)
+
+var CollectorMapperEvalSeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    MetricsMapperEvalSeconds,
+		Help:    "mapper evaluation latency.",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{MetricsLabelEntityType, MetricsLabelMapper, MetricsLabelOutcome},
+)
+
+var CollectorIngressQueueDepth = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: MetricsIngressQueueDepth,
+		Help: "ingress messages queued.",
+	},
+)
+
+var CollectorSubscriptionRetryCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: MetricsSubscriptionRetryCount,
+		Help: "subscription delivery retry count.",
+	},
+	[]string{MetricsLabelTenant},
+)
+
+var CollectorIngressRejectedCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: MetricsIngressRejectedCount,
+		Help: "ingress rejected message count.",
+	},
+	[]string{MetricsLabelReason},
+)
+
+var CollectorIndexQueueDepth = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: MetricsIndexQueueDepth,
+		Help: "search documents pending index.",
+	},
+)
+
 var Metrics = []prometheus.Collector{
 	CollectorRawDataStorage,
 	CollectorTimeseriesStorage,
@@ -96,4 +190,12 @@ var Metrics = []prometheus.Collector{
 	CollectorMsgStorageSpace,
 	CollectorMsgStorageSeconds,
 	CollectorTelemetry,
+	CollectorDeadLetterCount,
+	CollectorPurgedTombstoneCount,
+	CollectorMapperEvalCount,
+	CollectorMapperEvalSeconds,
+	CollectorIngressQueueDepth,
+	CollectorSubscriptionRetryCount,
+	CollectorIngressRejectedCount,
+	CollectorIndexQueueDepth,
 }
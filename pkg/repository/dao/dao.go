@@ -37,6 +37,8 @@ type Dao struct {
 	etcdCfg      config.EtcdConfig
 	stateClient  store.Store
 	etcdEndpoint KeyValue
+	localLocks   localLocks
+	sessions     lockSessions
 }
 
 func NewMock(ctx context.Context, storeCfg config.Metadata, etcdCfg config.EtcdConfig) (IDao, error) {
@@ -75,9 +77,15 @@ func New(ctx context.Context, storeCfg config.Metadata, etcdCfg config.EtcdConfi
 		storeCfg:     storeCfg,
 		etcdEndpoint: etcdEndpoint,
 		stateClient:  store.NewStore(storeMeta),
+		sessions:     lockSessions{newSession: etcdSessionsOf(ctx, etcdEndpoint)},
 	}, nil
 }
 
+func (d *Dao) Ping(ctx context.Context) error {
+	_, err := d.etcdEndpoint.MemberList(ctx)
+	return errors.Wrap(err, "ping etcd")
+}
+
 func (d *Dao) GetLastRevision(ctx context.Context) int64 {
 	var err error
 	var res *clientv3.MemberListResponse
@@ -111,7 +119,19 @@ func (d *Dao) GetLastRevision(ctx context.Context) int64 {
 	return rev
 }
 
+// Compact etcd revision history up to the last revision, keys are left as is.
+func (d *Dao) Compact(ctx context.Context) error {
+	rev := d.GetLastRevision(ctx)
+	if rev == 0 {
+		return errors.New("compact etcd, unknown revision")
+	}
+
+	_, err := d.etcdEndpoint.Compact(ctx, rev)
+	return errors.Wrap(err, "compact etcd")
+}
+
 func (d *Dao) Close() {
+	d.sessions.close()
 	d.cancel()
 	d.etcdEndpoint.Close()
 }
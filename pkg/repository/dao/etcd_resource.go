@@ -24,6 +24,7 @@ import (
 	logf "github.com/tkeel-io/core/pkg/logfield"
 	"github.com/tkeel-io/core/pkg/util"
 	"github.com/tkeel-io/kit/log"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
@@ -138,6 +139,25 @@ func (d *Dao) ListResource(ctx context.Context, rev int64, prefix string, decode
 	}
 }
 
+// PageResource returns at most limit key-values of the prefix from the key on, and whether more left,
+// zero limit returns all.
+func (d *Dao) PageResource(ctx context.Context, prefix, from string, limit int64) ([]*mvccpb.KeyValue, bool, error) {
+	if from < prefix {
+		from = prefix
+	}
+
+	opts := []clientv3.OpOption{
+		clientv3.WithRange(clientv3.GetPrefixRangeEnd(prefix)),
+		clientv3.WithLimit(limit),
+	}
+	resp, err := d.etcdEndpoint.Get(ctx, from, opts...)
+	if nil != err {
+		log.L().Error("page costume resource", logf.Error(err), logf.Prefix(prefix), logf.Key(from))
+		return nil, false, errors.Wrap(err, "page costume resource")
+	}
+	return resp.Kvs, resp.More, nil
+}
+
 func (d *Dao) RangeResource(ctx context.Context, rev int64, prefix string, handler RangeResourceFunc) {
 	opts := make([]clientv3.OpOption, 0)
 	opts = append(opts, clientv3.WithRev(rev),
@@ -206,3 +226,65 @@ func (d *Dao) WatchResource(ctx context.Context, rev int64, prefix string, handl
 		}
 	}
 }
+
+const updateMaxRetry = 5
+
+// UpdateResources read resources, apply update, then write back in a transaction,
+// retry when resources modified concurrently. Resource decode with nil bytes if not exists,
+// and will be deleted if encoded as nil.
+func (d *Dao) UpdateResources(ctx context.Context, update func() error, ress ...Resource) error {
+	for i := 0; i < updateMaxRetry; i++ {
+		keys := make([]string, len(ress))
+		cmps := make([]clientv3.Cmp, len(ress))
+		for index, res := range ress {
+			key, err := res.EncodeKey()
+			if nil != err {
+				return errors.Wrap(err, "update costume resources")
+			}
+
+			var rev int64
+			var value []byte
+			ret, err := d.etcdEndpoint.Get(ctx, string(key))
+			if nil != err && !errors.Is(err, xerrors.ErrResourceNotFound) {
+				return errors.Wrap(err, "update costume resources")
+			} else if nil == err && len(ret.Kvs) > 0 {
+				rev, value = ret.Kvs[0].ModRevision, ret.Kvs[0].Value
+			}
+
+			if err = res.Decode(key, value); nil != err {
+				return errors.Wrap(err, "update costume resources")
+			}
+
+			keys[index] = string(key)
+			cmps[index] = clientv3.Compare(clientv3.ModRevision(string(key)), "=", rev)
+		}
+
+		if err := update(); nil != err {
+			return err
+		}
+
+		ops := make([]clientv3.Op, len(ress))
+		for index, res := range ress {
+			bytes, err := res.Encode()
+			if nil != err {
+				return errors.Wrap(err, "update costume resources")
+			} else if nil == bytes {
+				ops[index] = clientv3.OpDelete(keys[index])
+				continue
+			}
+			ops[index] = clientv3.OpPut(keys[index], string(bytes))
+		}
+
+		resp, err := d.etcdEndpoint.Txn(ctx).If(cmps...).Then(ops...).Commit()
+		if nil != err {
+			return errors.Wrap(err, "update costume resources")
+		} else if resp.Succeeded {
+			return nil
+		}
+
+		log.L().Warn("update costume resources conflict, retry",
+			logf.Any("keys", keys), logf.Int("retry", i))
+	}
+
+	return errors.Wrap(xerrors.ErrResourceConflict, "update costume resources")
+}
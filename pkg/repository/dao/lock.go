@@ -0,0 +1,194 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/kit/log"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// LockResource acquire the lock of key until ctx done, returns the unlock function.
+// locks live in etcd leases with the ttl, locks of crashed holders released once leases expire.
+// holders within the process serialized locally first, one etcd session per ttl shared by holders.
+func (d *Dao) LockResource(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	unlockLocal, err := d.localLocks.lock(ctx, key)
+	if nil != err {
+		return nil, err
+	}
+
+	if d.sessions.newSession == nil {
+		// no etcd, lock within the process.
+		return unlockLocal, nil
+	}
+
+	unlock, err := d.sessions.lock(ctx, key, ttl)
+	if nil != err {
+		unlockLocal()
+		return nil, err
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(d.ctx, ttl)
+		defer cancel()
+		if err := unlock(ctx); nil != err {
+			log.L().Warn("unlock resource", logf.Key(key), logf.Error(err))
+		}
+		unlockLocal()
+	}, nil
+}
+
+// lockSession session holding locks shared by core instances.
+type lockSession interface {
+	// Lock acquire the lock of key until ctx done, returns the unlock function.
+	Lock(ctx context.Context, key string) (func(context.Context) error, error)
+	// Done closed once the session expired.
+	Done() <-chan struct{}
+	Close() error
+}
+
+type etcdSession struct {
+	session *concurrency.Session
+}
+
+// etcdSessionsOf returns function creating etcd sessions of ttl, sessions closed once ctx done.
+func etcdSessionsOf(ctx context.Context, client *clientv3.Client) func(time.Duration) (lockSession, error) {
+	return func(ttl time.Duration) (lockSession, error) {
+		session, err := concurrency.NewSession(client,
+			concurrency.WithTTL(int(ttl/time.Second)), concurrency.WithContext(ctx))
+		if nil != err {
+			return nil, errors.Wrap(err, "create session")
+		}
+		return &etcdSession{session: session}, nil
+	}
+}
+
+func (s *etcdSession) Lock(ctx context.Context, key string) (func(context.Context) error, error) {
+	mutex := concurrency.NewMutex(s.session, key)
+	if err := mutex.Lock(ctx); nil != err {
+		return nil, errors.Wrap(err, "lock resource")
+	}
+	return mutex.Unlock, nil
+}
+
+func (s *etcdSession) Done() <-chan struct{} {
+	return s.session.Done()
+}
+
+func (s *etcdSession) Close() error {
+	return errors.Wrap(s.session.Close(), "close session")
+}
+
+// lockSessions sessions keyed by ttl, reused until expired.
+type lockSessions struct {
+	mu         sync.Mutex
+	sessions   map[time.Duration]lockSession
+	newSession func(ttl time.Duration) (lockSession, error)
+}
+
+func (s *lockSessions) lock(ctx context.Context, key string, ttl time.Duration) (func(context.Context) error, error) {
+	session, err := s.session(ttl)
+	if nil != err {
+		return nil, errors.Wrap(err, "lock resource")
+	}
+	return session.Lock(ctx, key)
+}
+
+// session returns the live session of ttl, a new session created if expired.
+func (s *lockSessions) session(ttl time.Duration) (lockSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[ttl]; ok {
+		select {
+		case <-session.Done():
+			session.Close()
+			delete(s.sessions, ttl)
+		default:
+			return session, nil
+		}
+	}
+
+	session, err := s.newSession(ttl)
+	if nil != err {
+		return nil, err
+	}
+	if s.sessions == nil {
+		s.sessions = make(map[time.Duration]lockSession)
+	}
+	s.sessions[ttl] = session
+	return session, nil
+}
+
+// close sessions, locks held released.
+func (s *lockSessions) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ttl, session := range s.sessions {
+		session.Close()
+		delete(s.sessions, ttl)
+	}
+}
+
+// localLocks process local locks keyed by resource key, removed once no holder nor waiter.
+type localLocks struct {
+	mu    sync.Mutex
+	locks map[string]*localLock
+}
+
+type localLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+func (l *localLocks) lock(ctx context.Context, key string) (func(), error) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*localLock)
+	}
+	lk, ok := l.locks[key]
+	if !ok {
+		lk = &localLock{ch: make(chan struct{}, 1)}
+		l.locks[key] = lk
+	}
+	lk.refs++
+	l.mu.Unlock()
+
+	select {
+	case lk.ch <- struct{}{}:
+		return func() {
+			<-lk.ch
+			l.release(key, lk)
+		}, nil
+	case <-ctx.Done():
+		l.release(key, lk)
+		return nil, errors.Wrap(ctx.Err(), "lock resource")
+	}
+}
+
+func (l *localLocks) release(key string, lk *localLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lk.refs--; lk.refs == 0 {
+		delete(l.locks, key)
+	}
+}
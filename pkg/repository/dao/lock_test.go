@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSession lock session of a fake etcd, locks of keys held across sessions.
+type fakeSession struct {
+	held   *sync.Map
+	done   chan struct{}
+	closed bool
+}
+
+func (s *fakeSession) Lock(ctx context.Context, key string) (func(context.Context) error, error) {
+	if _, loaded := s.held.LoadOrStore(key, s); loaded {
+		return nil, context.DeadlineExceeded
+	}
+	return func(context.Context) error {
+		s.held.Delete(key)
+		return nil
+	}, nil
+}
+
+func (s *fakeSession) Done() <-chan struct{} { return s.done }
+
+func (s *fakeSession) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestDao_LockResource(t *testing.T) {
+	ctx := context.Background()
+	held := &sync.Map{}
+	var sessions []*fakeSession
+	d := &Dao{ctx: ctx, sessions: lockSessions{newSession: func(ttl time.Duration) (lockSession, error) {
+		session := &fakeSession{held: held, done: make(chan struct{})}
+		sessions = append(sessions, session)
+		return session, nil
+	}}}
+
+	// one session shared by writes.
+	unlock, err := d.LockResource(ctx, "device123", time.Second)
+	assert.Nil(t, err)
+	_, ok := held.Load("device123")
+	assert.True(t, ok)
+	unlock()
+	unlock, err = d.LockResource(ctx, "device234", time.Second)
+	assert.Nil(t, err)
+	unlock()
+	assert.Len(t, sessions, 1)
+	_, ok = held.Load("device123")
+	assert.False(t, ok)
+
+	// holders within the process wait locally, never contend on the shared session.
+	unlock, err = d.LockResource(ctx, "device123", time.Second)
+	assert.Nil(t, err)
+	acquired := make(chan struct{})
+	go func() {
+		unlock2, err := d.LockResource(ctx, "device123", time.Second)
+		assert.Nil(t, err)
+		close(acquired)
+		unlock2()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("lock acquired twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-acquired
+
+	// waiters timed out release the local lock.
+	unlock, err = d.LockResource(ctx, "device123", time.Second)
+	assert.Nil(t, err)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = d.LockResource(timeoutCtx, "device123", time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	unlock()
+
+	// local locks pruned once released.
+	assert.Empty(t, d.localLocks.locks)
+
+	// expired session replaced.
+	close(sessions[0].done)
+	unlock, err = d.LockResource(ctx, "device123", time.Second)
+	assert.Nil(t, err)
+	unlock()
+	assert.Len(t, sessions, 2)
+	assert.True(t, sessions[0].closed)
+
+	d.sessions.close()
+	assert.True(t, sessions[1].closed)
+}
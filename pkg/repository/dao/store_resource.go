@@ -42,6 +42,15 @@ func (d *Dao) StoreResource(ctx context.Context, res Resource) error {
 }
 
 func (d *Dao) GetStoreResource(ctx context.Context, res Resource) (Resource, error) {
+	return d.getStoreResource(ctx, res, d.stateClient.Get)
+}
+
+// GetReplicaStoreResource get resource from read replica of the state store, may be stale.
+func (d *Dao) GetReplicaStoreResource(ctx context.Context, res Resource) (Resource, error) {
+	return d.getStoreResource(ctx, res, d.stateClient.GetFromReplica)
+}
+
+func (d *Dao) getStoreResource(ctx context.Context, res Resource, get func(context.Context, string) (*store.StateItem, error)) (Resource, error) {
 	var (
 		err  error
 		key  []byte
@@ -52,7 +61,7 @@ func (d *Dao) GetStoreResource(ctx context.Context, res Resource) (Resource, err
 		return res, errors.Wrap(err, "dao store get entity")
 	}
 
-	if item, err = d.stateClient.Get(ctx, string(key)); nil == err {
+	if item, err = get(ctx, string(key)); nil == err {
 		if len(item.Value) == 0 {
 			return nil, xerrors.ErrResourceNotFound
 		}
@@ -61,6 +70,50 @@ func (d *Dao) GetStoreResource(ctx context.Context, res Resource) (Resource, err
 	return res, errors.Wrap(err, "dao store get entity")
 }
 
+// GetStoreItem get state item of resource as stored, value not decoded.
+func (d *Dao) GetStoreItem(ctx context.Context, res Resource) (*store.StateItem, error) {
+	key, err := res.EncodeKey()
+	if nil != err {
+		return nil, errors.Wrap(err, "dao store get item")
+	}
+
+	item, err := d.stateClient.Get(ctx, string(key))
+	if nil != err {
+		return nil, errors.Wrap(err, "dao store get item")
+	} else if len(item.Value) == 0 {
+		return nil, xerrors.ErrResourceNotFound
+	}
+	return item, nil
+}
+
+// ExistStoreResources reports existence of resources in one round trip, without decoding them.
+func (d *Dao) ExistStoreResources(ctx context.Context, ress []Resource) ([]bool, error) {
+	keys := make([]string, 0, len(ress))
+	for _, res := range ress {
+		key, err := res.EncodeKey()
+		if nil != err {
+			return nil, errors.Wrap(err, "dao store exist entities")
+		}
+		keys = append(keys, string(key))
+	}
+
+	items, err := d.stateClient.BulkGet(ctx, keys)
+	if nil != err {
+		return nil, errors.Wrap(err, "dao store exist entities")
+	}
+
+	present := make(map[string]bool, len(items))
+	for _, item := range items {
+		present[item.Key] = len(item.Value) > 0
+	}
+
+	exists := make([]bool, len(keys))
+	for index, key := range keys {
+		exists[index] = present[key]
+	}
+	return exists, nil
+}
+
 func (d *Dao) RemoveStoreResource(ctx context.Context, res Resource) error {
 	key, err := res.EncodeKey()
 	if nil != err {
@@ -71,6 +124,33 @@ func (d *Dao) RemoveStoreResource(ctx context.Context, res Resource) error {
 	return errors.Wrap(err, "dao store del entity")
 }
 
+// TransactStoreResources upsert and delete resources atomically.
+func (d *Dao) TransactStoreResources(ctx context.Context, upserts, deletes []Resource) error {
+	ops := make([]*store.TxOperation, 0, len(upserts)+len(deletes))
+	for _, res := range upserts {
+		key, err := res.EncodeKey()
+		if nil != err {
+			return errors.Wrap(err, "dao store transact")
+		}
+
+		data, err := res.Encode()
+		if nil != err {
+			return errors.Wrap(err, "dao store transact")
+		}
+		ops = append(ops, &store.TxOperation{Type: store.TxUpsert, Key: string(key), Value: data})
+	}
+
+	for _, res := range deletes {
+		key, err := res.EncodeKey()
+		if nil != err {
+			return errors.Wrap(err, "dao store transact")
+		}
+		ops = append(ops, &store.TxOperation{Type: store.TxDelete, Key: string(key)})
+	}
+
+	return errors.Wrap(d.stateClient.Transact(ctx, ops), "dao store transact")
+}
+
 func (d *Dao) FlushStoreResource(ctx context.Context) error {
 	return d.stateClient.Flush(ctx)
 }
@@ -18,7 +18,9 @@ package dao
 
 import (
 	"context"
+	"time"
 
+	"github.com/tkeel-io/core/pkg/resource/store"
 	"go.etcd.io/etcd/api/v3/mvccpb"
 )
 
@@ -46,6 +48,9 @@ type Resource interface {
 type IDao interface {
 	Close()
 	GetLastRevision(ctx context.Context) int64
+	// Ping checks etcd cluster reachable.
+	Ping(ctx context.Context) error
+	Compact(ctx context.Context) error
 	// resource etcd interfaces.
 	PutResource(ctx context.Context, res Resource) error
 	GetResource(ctx context.Context, res Resource) (Resource, error)
@@ -54,11 +59,18 @@ type IDao interface {
 	HasResource(ctx context.Context, res Resource) (has bool, err error)
 	ListResource(ctx context.Context, rev int64, prefix string, decodeFunc DecodeFunc) ([]Resource, error)
 	RangeResource(ctx context.Context, rev int64, prefix string, handler RangeResourceFunc)
+	PageResource(ctx context.Context, prefix, from string, limit int64) ([]*mvccpb.KeyValue, bool, error)
 	WatchResource(ctx context.Context, rev int64, prefix string, handler WatchResourceFunc)
+	UpdateResources(ctx context.Context, update func() error, ress ...Resource) error
+	LockResource(ctx context.Context, key string, ttl time.Duration) (func(), error)
 
 	// resource store interfaces.
 	StoreResource(ctx context.Context, res Resource) error
 	GetStoreResource(ctx context.Context, res Resource) (Resource, error)
+	GetReplicaStoreResource(ctx context.Context, res Resource) (Resource, error)
+	GetStoreItem(ctx context.Context, res Resource) (*store.StateItem, error)
+	ExistStoreResources(ctx context.Context, ress []Resource) ([]bool, error)
 	RemoveStoreResource(ctx context.Context, res Resource) error
+	TransactStoreResources(ctx context.Context, upserts, deletes []Resource) error
 	FlushStoreResource(ctx context.Context) error
 }
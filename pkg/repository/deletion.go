@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/repository/dao"
+)
+
+const DeleteIntentPrefix = "/core/v1/delete"
+
+var _ dao.Resource = (*DeleteIntent)(nil)
+
+// DeleteIntent records entity delete in progress, removed once both state and search deleted.
+// intents left behind mark deletes interrupted half way.
+type DeleteIntent struct {
+	EntityID string
+	Owner    string
+	Source   string
+}
+
+func (d *DeleteIntent) EncodeKey() ([]byte, error) {
+	if d.EntityID == "" {
+		return nil, errors.Errorf("DeleteIntent EntityID is empty")
+	}
+	return []byte(fmt.Sprintf("%s/%s", DeleteIntentPrefix, d.EntityID)), nil
+}
+
+func (d *DeleteIntent) Encode() ([]byte, error) {
+	bytes, err := json.Marshal(d)
+	return bytes, errors.Wrap(err, "encode DeleteIntent")
+}
+
+func (d *DeleteIntent) Decode(key, bytes []byte) error {
+	err := json.Unmarshal(bytes, d)
+	return errors.Wrap(err, "decode DeleteIntent")
+}
+
+func (r *repo) PutDeleteIntent(ctx context.Context, intent *DeleteIntent) error {
+	err := r.dao.PutResource(ctx, intent)
+	return errors.Wrap(err, "put delete intent repository")
+}
+
+func (r *repo) DelDeleteIntent(ctx context.Context, intent *DeleteIntent) error {
+	err := r.dao.DelResource(ctx, intent)
+	return errors.Wrap(err, "del delete intent repository")
+}
+
+// HasDeleteIntent reports whether delete of the entity is in progress or interrupted.
+func (r *repo) HasDeleteIntent(ctx context.Context, eid string) (bool, error) {
+	intent := &DeleteIntent{EntityID: eid}
+	if _, err := r.dao.GetResource(ctx, intent); nil != err {
+		if errors.Is(err, xerrors.ErrResourceNotFound) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "get delete intent repository")
+	}
+	// keys matched by prefix.
+	return intent.EntityID == eid, nil
+}
+
+// ListDeleteIntent returns intents of deletes not finished.
+func (r *repo) ListDeleteIntent(ctx context.Context, rev int64) ([]*DeleteIntent, error) {
+	ress, err := r.dao.ListResource(ctx, rev, DeleteIntentPrefix+"/",
+		func(key, raw []byte) (dao.Resource, error) {
+			var res DeleteIntent // escape.
+			err := res.Decode(key, raw)
+			return &res, errors.Wrap(err, "decode delete intent")
+		})
+
+	var intents []*DeleteIntent
+	for index := range ress {
+		if intent, ok := ress[index].(*DeleteIntent); ok {
+			intents = append(intents, intent)
+		}
+	}
+	return intents, errors.Wrap(err, "list delete intent repository")
+}
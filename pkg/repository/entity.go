@@ -2,9 +2,14 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository/dao"
+	"github.com/tkeel-io/kit/log"
 	"github.com/tkeel-io/tdtl"
 )
 
@@ -12,6 +17,7 @@ const (
 	EntityTypeBasic        = "BASIC"
 	EntityTypeSubscription = "SUBSCRIPTION"
 	EntityStorePrefix      = "CORE.ENTITY"
+	EntityLockPrefix       = "/core/v1/lock/entity"
 )
 
 type Entity struct {
@@ -122,9 +128,35 @@ func (r *repo) FlushEntity(ctx context.Context) error {
 
 func (r *repo) GetEntity(ctx context.Context, eid string) ([]byte, error) {
 	ret, err := r.dao.GetStoreResource(ctx, &entityResource{id: eid})
+	if nil != err {
+		return nil, errors.Wrap(err, "get entity repository")
+	}
+
+	res, _ := ret.(*entityResource)
+	return res.data, nil
+}
+
+// GetEntityFromReplica get entity from read replica, falls back to the primary on replica error,
+// a stale replica may miss recent writes or even recently created entities.
+func (r *repo) GetEntityFromReplica(ctx context.Context, eid string) ([]byte, error) {
+	ret, err := r.dao.GetReplicaStoreResource(ctx, &entityResource{id: eid})
+	if nil != err {
+		log.L().Warn("get entity from replica, fall back to primary",
+			logf.Eid(eid), logf.Error(err))
+		return r.GetEntity(ctx, eid)
+	}
 
 	res, _ := ret.(*entityResource)
-	return res.data, errors.Wrap(err, "get entity repository")
+	return res.data, nil
+}
+
+// GetEntityWithETag get entity state as stored with its etag.
+func (r *repo) GetEntityWithETag(ctx context.Context, eid string) ([]byte, string, error) {
+	item, err := r.dao.GetStoreItem(ctx, &entityResource{id: eid})
+	if nil != err {
+		return nil, "", errors.Wrap(err, "get entity with etag repository")
+	}
+	return item.Value, item.Etag, nil
 }
 
 func (r *repo) DelEntity(ctx context.Context, eid string) error {
@@ -132,10 +164,62 @@ func (r *repo) DelEntity(ctx context.Context, eid string) error {
 	return errors.Wrap(err, "del entity repository")
 }
 
+// Tx stages entity state writes, committed atomically by Transaction.
+type Tx struct {
+	upserts []dao.Resource
+	deletes []dao.Resource
+}
+
+func (tx *Tx) PutEntity(eid string, data []byte) {
+	tx.upserts = append(tx.upserts, &entityResource{id: eid, data: data})
+}
+
+func (tx *Tx) DelEntity(eid string) {
+	tx.deletes = append(tx.deletes, &entityResource{id: eid})
+}
+
+// Transaction commit writes staged by fn atomically, nothing written if fn returns error.
+func (r *repo) Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	tx := &Tx{}
+	if err := fn(tx); nil != err {
+		return errors.Wrap(err, "entity transaction")
+	} else if len(tx.upserts)+len(tx.deletes) == 0 {
+		return nil
+	}
+
+	err := r.dao.TransactStoreResources(ctx, tx.upserts, tx.deletes)
+	return errors.Wrap(err, "entity transaction")
+}
+
+// LockEntity acquire the lock of the entity shared by core instances, returns the unlock function.
+func (r *repo) LockEntity(ctx context.Context, eid string, ttl time.Duration) (func(), error) {
+	unlock, err := r.dao.LockResource(ctx, fmt.Sprintf("%s/%s", EntityLockPrefix, eid), ttl)
+	return unlock, errors.Wrap(err, "lock entity repository")
+}
+
+// HasEntities reports existence of entities keyed by entity id.
+func (r *repo) HasEntities(ctx context.Context, eids []string) (map[string]bool, error) {
+	ress := make([]dao.Resource, 0, len(eids))
+	for _, eid := range eids {
+		ress = append(ress, &entityResource{id: eid})
+	}
+
+	exists, err := r.dao.ExistStoreResources(ctx, ress)
+	if nil != err {
+		return nil, errors.Wrap(err, "exists entities repository")
+	}
+
+	ret := make(map[string]bool, len(eids))
+	for index, eid := range eids {
+		ret[eid] = exists[index]
+	}
+	return ret, nil
+}
+
 func (r *repo) HasEntity(ctx context.Context, eid string) (bool, error) {
 	_, err := r.dao.GetStoreResource(ctx, &entityResource{id: eid})
 	if nil != err {
-		if errors.Is(err, xerrors.ErrResourceNotFound) {
+		if xerrors.IsEntityNotFound(err) {
 			return false, nil
 		}
 		return false, errors.Wrap(err, "exists entity repository")
@@ -7,8 +7,11 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/tkeel-io/core/pkg/config"
 	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/repository/dao"
 	_ "github.com/tkeel-io/core/pkg/resource/store/dapr"
+	_ "github.com/tkeel-io/core/pkg/resource/store/memory"
 	_ "github.com/tkeel-io/core/pkg/resource/store/noop"
 	"github.com/tkeel-io/tdtl"
 )
@@ -97,3 +100,45 @@ func Test_HasEntity(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, false, has)
 }
+
+func Test_HasEntities(t *testing.T) {
+	exists, err := repoIns.HasEntities(context.Background(), []string{"device123", "device234"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"device123": false, "device234": false}, exists)
+}
+
+func Test_Transaction(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	r := &repo{dao: memDao}
+
+	err = r.Transaction(ctx, func(tx *Tx) error {
+		tx.PutEntity("device123", []byte(`{"properties":{"temp":20}}`))
+		tx.PutEntity("device234", []byte(`{"properties":{"temp":25}}`))
+		return nil
+	})
+	assert.Nil(t, err)
+
+	bytes, err := r.GetEntity(ctx, "device123")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"properties":{"temp":20}}`, string(bytes))
+	bytes, err = r.GetEntity(ctx, "device234")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"properties":{"temp":25}}`, string(bytes))
+
+	// staged writes dropped when fn failed.
+	err = r.Transaction(ctx, func(tx *Tx) error {
+		tx.PutEntity("device123", []byte(`{"properties":{"temp":30}}`))
+		tx.DelEntity("device234")
+		return errors.New("abort")
+	})
+	assert.NotNil(t, err)
+
+	bytes, err = r.GetEntity(ctx, "device123")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"properties":{"temp":20}}`, string(bytes))
+	has, err := r.HasEntity(ctx, "device234")
+	assert.Nil(t, err)
+	assert.True(t, has)
+}
@@ -7,9 +7,11 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
 	"github.com/tkeel-io/core/pkg/repository/dao"
 	"github.com/tkeel-io/kit/log"
 	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 const (
@@ -42,6 +44,10 @@ type Expression struct {
 	Expression string
 	// description.
 	Description string
+	// evaluation priority.
+	Priority int
+	// disabled expression kept but skipped in evaluation.
+	Disabled bool
 }
 
 func NewExpression(owner, entityID, name, path, expr, desc string) *Expression {
@@ -78,13 +84,27 @@ func (e *Expression) GenKey() error {
 	return err
 }
 
+// EncodeKey key of the expression, keyed by name under the path so that
+// expressions of mappers writing the same path kept side by side.
 func (e *Expression) EncodeKey() ([]byte, error) {
 	escapePath := url.PathEscape(e.Path)
 	keyString := fmt.Sprintf("%s/%s/%s/%s",
 		ExprPrefix, e.Owner, e.EntityID, escapePath)
+	if e.Name != "" {
+		keyString = fmt.Sprintf("%s/%s", keyString, url.PathEscape(e.Name))
+	}
 	return []byte(keyString), nil
 }
 
+// Outranks reports whether the expression wins over other writing the same path,
+// the higher priority wins, equal priorities tie-break by the greater id.
+func (e *Expression) Outranks(other *Expression) bool {
+	if e.Priority != other.Priority {
+		return e.Priority > other.Priority
+	}
+	return e.ID > other.ID
+}
+
 func (e *Expression) Encode() ([]byte, error) {
 	bytes, err := json.Marshal(e)
 	return bytes, errors.Wrap(err, "encode Expression")
@@ -96,7 +116,7 @@ func (e *Expression) Decode(key, bytes []byte) error {
 		return errors.Wrap(err, "decode Expression")
 	}
 	keys := strings.Split(string(key), "/")
-	if len(keys) != 7 {
+	if len(keys) != 7 && len(keys) != 8 {
 		return errors.Errorf("error:decode Subscription from key[%s]", string(key))
 	}
 	e.Owner = keys[4]
@@ -173,6 +193,58 @@ func (r *repo) RangeExpression(ctx context.Context, rev int64, handler RangeExpr
 	})
 }
 
+// PageExpression returns expressions of all entities from the key on, and the key of the next page,
+// pages end at entity boundaries so expressions of an entity never split, the last page may be empty.
+func (r *repo) PageExpression(ctx context.Context, from string, limit int64) ([]*Expression, string, error) {
+	kvs, more, err := r.dao.PageResource(ctx, ExprPrefix+"/", from, limit)
+	if nil != err {
+		return nil, "", errors.Wrap(err, "page expression repository")
+	} else if !more || len(kvs) == 0 {
+		return decodeExpressions(kvs), "", nil
+	}
+
+	// complete expressions of the last entity.
+	last := decodeExpressions(kvs[len(kvs)-1:])
+	if len(last) == 0 {
+		return nil, "", errors.Errorf("page expression repository, decode expression %s", kvs[len(kvs)-1].Key)
+	}
+	entityPrefix := ListExpressionPrefix(last[0].Owner, last[0].EntityID) + "/"
+	rest, _, err := r.dao.PageResource(ctx, entityPrefix, string(kvs[len(kvs)-1].Key)+"\x00", 0)
+	if nil != err {
+		return nil, "", errors.Wrap(err, "page expression repository")
+	}
+
+	kvs = append(kvs, rest...)
+	return decodeExpressions(kvs), clientv3.GetPrefixRangeEnd(entityPrefix), nil
+}
+
+// RawExpressions returns expressions of the entity as stored, keyed by etcd key.
+func (r *repo) RawExpressions(ctx context.Context, owner, entityID string) (map[string][]byte, error) {
+	kvs, _, err := r.dao.PageResource(ctx, ListExpressionPrefix(owner, entityID)+"/", "", 0)
+	if nil != err {
+		return nil, errors.Wrap(err, "raw expressions repository")
+	}
+
+	raws := make(map[string][]byte, len(kvs))
+	for _, kv := range kvs {
+		raws[string(kv.Key)] = kv.Value
+	}
+	return raws, nil
+}
+
+func decodeExpressions(kvs []*mvccpb.KeyValue) []*Expression {
+	exprs := make([]*Expression, 0, len(kvs))
+	for index := range kvs {
+		var expr Expression
+		if err := expr.Decode(kvs[index].Key, kvs[index].Value); nil != err {
+			log.L().Warn("decode expression", logf.Key(string(kvs[index].Key)), logf.Error(err))
+			continue
+		}
+		exprs = append(exprs, &expr)
+	}
+	return exprs
+}
+
 func (r *repo) WatchExpression(ctx context.Context, rev int64, handler WatchExpressionFunc) {
 	r.dao.WatchResource(ctx, rev, ExprPrefix, func(et dao.EnventType, kv *mvccpb.KeyValue) {
 		var expr Expression
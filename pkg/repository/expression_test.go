@@ -2,9 +2,13 @@ package repository
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/tkeel-io/core/pkg/repository/dao"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 )
 
 var (
@@ -102,3 +106,65 @@ func Test_Expressions_Decode(t *testing.T) {
 		})
 	}
 }
+
+func Test_Expression_Disabled(t *testing.T) {
+	expr := NewExpression("admin", "device123", "expr1", "temp", "device002.temp", "")
+	expr.Disabled = true
+	key, _ := expr.EncodeKey()
+	bytes, err := expr.Encode()
+	assert.Nil(t, err)
+
+	ex := &Expression{}
+	assert.Nil(t, ex.Decode(key, bytes))
+	assert.True(t, ex.Disabled)
+}
+
+// pageDao pages sorted key-values in memory like etcd range queries.
+type pageDao struct {
+	dao.IDao
+	kvs []*mvccpb.KeyValue
+}
+
+func (d *pageDao) PageResource(ctx context.Context, prefix, from string, limit int64) ([]*mvccpb.KeyValue, bool, error) {
+	if from < prefix {
+		from = prefix
+	}
+	var kvs []*mvccpb.KeyValue
+	for _, kv := range d.kvs {
+		if key := string(kv.Key); key >= from && strings.HasPrefix(key, prefix) {
+			if limit > 0 && int64(len(kvs)) == limit {
+				return kvs, true, nil
+			}
+			kvs = append(kvs, kv)
+		}
+	}
+	return kvs, false, nil
+}
+
+func TestPageExpression(t *testing.T) {
+	pd := &pageDao{}
+	for _, expr := range []*Expression{
+		NewExpression("admin", "device123", "mapper1", "temp", "device002.temp", ""),
+		NewExpression("admin", "device123", "mapper1", "cpu", "device002.cpu", ""),
+		NewExpression("admin", "device1234", "mapper2", "temp", "device003.temp", ""),
+	} {
+		key, _ := expr.EncodeKey()
+		val, _ := expr.Encode()
+		pd.kvs = append(pd.kvs, &mvccpb.KeyValue{Key: key, Value: val})
+	}
+	sort.Slice(pd.kvs, func(i, j int) bool { return string(pd.kvs[i].Key) < string(pd.kvs[j].Key) })
+	r := &repo{dao: pd}
+
+	// page extended to the end of entity device123.
+	exprs, next, err := r.PageExpression(context.Background(), "", 1)
+	assert.Nil(t, err)
+	assert.Len(t, exprs, 2)
+	assert.Equal(t, "device123", exprs[1].EntityID)
+	assert.NotEmpty(t, next)
+
+	exprs, next, err = r.PageExpression(context.Background(), next, 1)
+	assert.Nil(t, err)
+	assert.Len(t, exprs, 1)
+	assert.Equal(t, "device1234", exprs[0].EntityID)
+	assert.Empty(t, next)
+}
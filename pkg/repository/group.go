@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/repository/dao"
+)
+
+const (
+	GroupPrefix      = "/core/v1/group"
+	MembershipPrefix = "/core/v1/membership"
+)
+
+var _ dao.Resource = (*Group)(nil)
+var _ dao.Resource = (*Membership)(nil)
+var _ dao.Resource = (*entityMembership)(nil)
+
+// Group user defined entity collection, cut across entity types.
+type Group struct {
+	ID          string
+	Owner       string
+	Name        string
+	Description string
+}
+
+func (g *Group) EncodeKey() ([]byte, error) {
+	if g.ID == "" {
+		return nil, errors.Errorf("Group ID is empty")
+	}
+	return []byte(fmt.Sprintf("%s/%s", GroupPrefix, g.ID)), nil
+}
+
+func (g *Group) Encode() ([]byte, error) {
+	bytes, err := json.Marshal(g)
+	return bytes, errors.Wrap(err, "encode Group")
+}
+
+func (g *Group) Decode(key, bytes []byte) error {
+	err := json.Unmarshal(bytes, g)
+	return errors.Wrap(err, "decode Group")
+}
+
+// Membership entity belongs to group, indexed by both group and entity.
+type Membership struct {
+	GroupID  string
+	EntityID string
+	// Deleted remove the record when update.
+	Deleted bool `json:"-"`
+}
+
+func (m *Membership) EncodeKey() ([]byte, error) {
+	if m.GroupID == "" || m.EntityID == "" {
+		return nil, errors.Errorf("Membership GroupID or EntityID is empty")
+	}
+	return []byte(fmt.Sprintf("%s/group/%s/%s", MembershipPrefix, m.GroupID, m.EntityID)), nil
+}
+
+func (m *Membership) Encode() ([]byte, error) {
+	if m.Deleted {
+		return nil, nil
+	}
+	bytes, err := json.Marshal(m)
+	return bytes, errors.Wrap(err, "encode Membership")
+}
+
+func (m *Membership) Decode(key, bytes []byte) error {
+	// /core/v1/membership/{group|entity}/{id}/{id}.
+	keys := strings.Split(string(key), "/")
+	if len(keys) != 7 {
+		return errors.Errorf("error:decode Membership from key[%s]", string(key))
+	}
+
+	switch keys[4] {
+	case "entity":
+		m.EntityID, m.GroupID = keys[5], keys[6]
+	default:
+		m.GroupID, m.EntityID = keys[5], keys[6]
+	}
+	return nil
+}
+
+// entityMembership reverse index of Membership.
+type entityMembership struct {
+	*Membership
+}
+
+func (m *entityMembership) EncodeKey() ([]byte, error) {
+	if m.GroupID == "" || m.EntityID == "" {
+		return nil, errors.Errorf("Membership GroupID or EntityID is empty")
+	}
+	return []byte(fmt.Sprintf("%s/entity/%s/%s", MembershipPrefix, m.EntityID, m.GroupID)), nil
+}
+
+func (r *repo) PutGroup(ctx context.Context, group *Group) error {
+	err := r.dao.PutResource(ctx, group)
+	return errors.Wrap(err, "put group repository")
+}
+
+func (r *repo) GetGroup(ctx context.Context, group *Group) (*Group, error) {
+	_, err := r.dao.GetResource(ctx, group)
+	return group, errors.Wrap(err, "get group repository")
+}
+
+func (r *repo) DelGroup(ctx context.Context, group *Group) error {
+	err := r.dao.DelResource(ctx, group)
+	return errors.Wrap(err, "del group repository")
+}
+
+func (r *repo) ListGroup(ctx context.Context, rev int64, owner string) ([]*Group, error) {
+	ress, err := r.dao.ListResource(ctx, rev, GroupPrefix+"/",
+		func(key, raw []byte) (dao.Resource, error) {
+			var res Group // escape.
+			err := res.Decode(key, raw)
+			return &res, errors.Wrap(err, "decode group")
+		})
+
+	var groups []*Group
+	for index := range ress {
+		if group, ok := ress[index].(*Group); ok {
+			if owner == "" || group.Owner == owner {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups, errors.Wrap(err, "list group repository")
+}
+
+func (r *repo) PutMembership(ctx context.Context, m *Membership) error {
+	err := r.dao.UpdateResources(ctx, func() error {
+		m.Deleted = false
+		return nil
+	}, m, &entityMembership{m})
+	return errors.Wrap(err, "put membership repository")
+}
+
+func (r *repo) DelMembership(ctx context.Context, m *Membership) error {
+	err := r.dao.UpdateResources(ctx, func() error {
+		m.Deleted = true
+		return nil
+	}, m, &entityMembership{m})
+	return errors.Wrap(err, "del membership repository")
+}
+
+// ListGroupMembers returns entity ids of the group.
+func (r *repo) ListGroupMembers(ctx context.Context, rev int64, gid string) ([]string, error) {
+	prefix := fmt.Sprintf("%s/group/%s/", MembershipPrefix, gid)
+	ms, err := r.listMembership(ctx, rev, prefix)
+	ids := make([]string, 0, len(ms))
+	for _, m := range ms {
+		ids = append(ids, m.EntityID)
+	}
+	return ids, errors.Wrap(err, "list group members repository")
+}
+
+// ListEntityGroups returns group ids the entity belongs to.
+func (r *repo) ListEntityGroups(ctx context.Context, rev int64, eid string) ([]string, error) {
+	prefix := fmt.Sprintf("%s/entity/%s/", MembershipPrefix, eid)
+	ms, err := r.listMembership(ctx, rev, prefix)
+	ids := make([]string, 0, len(ms))
+	for _, m := range ms {
+		ids = append(ids, m.GroupID)
+	}
+	return ids, errors.Wrap(err, "list entity groups repository")
+}
+
+func (r *repo) listMembership(ctx context.Context, rev int64, prefix string) ([]*Membership, error) {
+	ress, err := r.dao.ListResource(ctx, rev, prefix,
+		func(key, raw []byte) (dao.Resource, error) {
+			var res Membership // escape.
+			err := res.Decode(key, raw)
+			return &res, errors.Wrap(err, "decode membership")
+		})
+
+	var ms []*Membership
+	for index := range ress {
+		if m, ok := ress[index].(*Membership); ok {
+			ms = append(ms, m)
+		}
+	}
+	return ms, errors.Wrap(err, "list membership")
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembership_Key(t *testing.T) {
+	m := &Membership{GroupID: "floor-3-sensors", EntityID: "device123"}
+	key, err := m.EncodeKey()
+	assert.Nil(t, err)
+	assert.Equal(t, "/core/v1/membership/group/floor-3-sensors/device123", string(key))
+
+	rkey, err := (&entityMembership{m}).EncodeKey()
+	assert.Nil(t, err)
+	assert.Equal(t, "/core/v1/membership/entity/device123/floor-3-sensors", string(rkey))
+
+	var decoded Membership
+	assert.Nil(t, decoded.Decode(rkey, nil))
+	assert.Equal(t, Membership{GroupID: "floor-3-sensors", EntityID: "device123"}, decoded)
+
+	m.Deleted = true
+	bytes, err := m.Encode()
+	assert.Nil(t, err)
+	assert.Nil(t, bytes)
+}
+
+func Test_repo_PutGroup(t *testing.T) {
+	err := rr.PutGroup(context.Background(), &Group{ID: "floor-3-sensors", Owner: "admin"})
+	assert.Nil(t, err)
+	err = rr.PutGroup(context.Background(), &Group{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/repository/dao"
+)
+
+const IndexIntentPrefix = "/core/v1/index"
+
+var _ dao.Resource = (*IndexIntent)(nil)
+
+// IndexIntent records entity created but failed to index into search engine, removed once indexed.
+type IndexIntent struct {
+	EntityID string
+	Owner    string
+	Source   string
+}
+
+func (i *IndexIntent) EncodeKey() ([]byte, error) {
+	if i.EntityID == "" {
+		return nil, errors.Errorf("IndexIntent EntityID is empty")
+	}
+	return []byte(fmt.Sprintf("%s/%s", IndexIntentPrefix, i.EntityID)), nil
+}
+
+func (i *IndexIntent) Encode() ([]byte, error) {
+	bytes, err := json.Marshal(i)
+	return bytes, errors.Wrap(err, "encode IndexIntent")
+}
+
+func (i *IndexIntent) Decode(key, bytes []byte) error {
+	err := json.Unmarshal(bytes, i)
+	return errors.Wrap(err, "decode IndexIntent")
+}
+
+func (r *repo) PutIndexIntent(ctx context.Context, intent *IndexIntent) error {
+	err := r.dao.PutResource(ctx, intent)
+	return errors.Wrap(err, "put index intent repository")
+}
+
+func (r *repo) DelIndexIntent(ctx context.Context, intent *IndexIntent) error {
+	err := r.dao.DelResource(ctx, intent)
+	return errors.Wrap(err, "del index intent repository")
+}
+
+// ListIndexIntent returns intents of entities not indexed yet.
+func (r *repo) ListIndexIntent(ctx context.Context, rev int64) ([]*IndexIntent, error) {
+	ress, err := r.dao.ListResource(ctx, rev, IndexIntentPrefix+"/",
+		func(key, raw []byte) (dao.Resource, error) {
+			var res IndexIntent // escape.
+			err := res.Decode(key, raw)
+			return &res, errors.Wrap(err, "decode index intent")
+		})
+
+	var intents []*IndexIntent
+	for index := range ress {
+		if intent, ok := ress[index].(*IndexIntent); ok {
+			intents = append(intents, intent)
+		}
+	}
+	return intents, errors.Wrap(err, "list index intent repository")
+}
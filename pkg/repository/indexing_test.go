@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexIntent_Key(t *testing.T) {
+	intent := &IndexIntent{EntityID: "device123", Owner: "admin", Source: "dm"}
+	key, err := intent.EncodeKey()
+	assert.Nil(t, err)
+	assert.Equal(t, "/core/v1/index/device123", string(key))
+
+	bytes, err := intent.Encode()
+	assert.Nil(t, err)
+	var decoded IndexIntent
+	assert.Nil(t, decoded.Decode(key, bytes))
+	assert.Equal(t, *intent, decoded)
+
+	_, err = (&IndexIntent{}).EncodeKey()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/repository/dao"
+)
+
+const MirrorIndexPrefix = "/core/v1/mirror"
+
+var _ dao.Resource = (*MirrorIndex)(nil)
+
+// MirrorIndex subscriptions of a mirror indexed by an entity of the mirror, mirrors indexed by
+// both entities, so mirrors of a deleted entity found without ranging subscriptions.
+type MirrorIndex struct {
+	EntityID      string
+	MirrorID      string
+	Subscriptions []*Subscription
+}
+
+func (m *MirrorIndex) EncodeKey() ([]byte, error) {
+	if m.EntityID == "" || m.MirrorID == "" {
+		return nil, errors.Errorf("MirrorIndex EntityID or MirrorID is empty")
+	}
+	return []byte(fmt.Sprintf("%s/%s/%s", MirrorIndexPrefix, m.EntityID, m.MirrorID)), nil
+}
+
+func (m *MirrorIndex) Encode() ([]byte, error) {
+	bytes, err := json.Marshal(m)
+	return bytes, errors.Wrap(err, "encode MirrorIndex")
+}
+
+func (m *MirrorIndex) Decode(key, bytes []byte) error {
+	err := json.Unmarshal(bytes, m)
+	return errors.Wrap(err, "decode MirrorIndex")
+}
+
+func (r *repo) PutMirrorIndex(ctx context.Context, index *MirrorIndex) error {
+	err := r.dao.PutResource(ctx, index)
+	return errors.Wrap(err, "put mirror index repository")
+}
+
+func (r *repo) DelMirrorIndex(ctx context.Context, index *MirrorIndex) error {
+	err := r.dao.DelResource(ctx, index)
+	return errors.Wrap(err, "del mirror index repository")
+}
+
+// ListEntityMirrors returns mirrors from or to the entity.
+func (r *repo) ListEntityMirrors(ctx context.Context, rev int64, eid string) ([]*MirrorIndex, error) {
+	ress, err := r.dao.ListResource(ctx, rev, fmt.Sprintf("%s/%s/", MirrorIndexPrefix, eid),
+		func(key, raw []byte) (dao.Resource, error) {
+			var res MirrorIndex // escape.
+			err := res.Decode(key, raw)
+			return &res, errors.Wrap(err, "decode mirror index")
+		})
+
+	var indexes []*MirrorIndex
+	for index := range ress {
+		if mi, ok := ress[index].(*MirrorIndex); ok {
+			indexes = append(indexes, mi)
+		}
+	}
+	return indexes, errors.Wrap(err, "list entity mirrors repository")
+}
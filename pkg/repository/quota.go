@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/repository/dao"
+)
+
+const (
+	QuotaPrefix = "/core/v1/quota"
+)
+
+var _ dao.Resource = (*QuotaUsage)(nil)
+var _ dao.Resource = (*EntitySize)(nil)
+
+// QuotaUsage current usage of a tenant.
+type QuotaUsage struct {
+	Tenant string
+	// entity count.
+	Count int64
+	// total encoded size of entities.
+	Size int64
+}
+
+func (q *QuotaUsage) EncodeKey() ([]byte, error) {
+	if q.Tenant == "" {
+		return nil, errors.Errorf("QuotaUsage Tenant is empty")
+	}
+	return []byte(fmt.Sprintf("%s/%s/usage", QuotaPrefix, q.Tenant)), nil
+}
+
+func (q *QuotaUsage) Encode() ([]byte, error) {
+	bytes, err := json.Marshal(q)
+	return bytes, errors.Wrap(err, "encode QuotaUsage")
+}
+
+func (q *QuotaUsage) Decode(key, bytes []byte) error {
+	*q = QuotaUsage{Tenant: q.Tenant}
+	if bytes == nil {
+		return nil
+	}
+	err := json.Unmarshal(bytes, q)
+	return errors.Wrap(err, "decode QuotaUsage")
+}
+
+// EntitySize encoded size of an entity accounted in tenant usage.
+type EntitySize struct {
+	Tenant   string
+	EntityID string
+	Size     int64
+	// Deleted remove the record when update.
+	Deleted bool `json:"-"`
+	// Exists whether the record was stored when decoded.
+	Exists bool `json:"-"`
+}
+
+func (s *EntitySize) EncodeKey() ([]byte, error) {
+	if s.Tenant == "" || s.EntityID == "" {
+		return nil, errors.Errorf("EntitySize Tenant or EntityID is empty")
+	}
+	return []byte(fmt.Sprintf("%s/%s/entities/%s", QuotaPrefix, s.Tenant, s.EntityID)), nil
+}
+
+func (s *EntitySize) Encode() ([]byte, error) {
+	if s.Deleted {
+		return nil, nil
+	}
+	bytes, err := json.Marshal(s)
+	return bytes, errors.Wrap(err, "encode EntitySize")
+}
+
+func (s *EntitySize) Decode(key, bytes []byte) error {
+	*s = EntitySize{Tenant: s.Tenant, EntityID: s.EntityID}
+	if bytes == nil {
+		return nil
+	}
+	err := json.Unmarshal(bytes, s)
+	s.Exists = nil == err
+	return errors.Wrap(err, "decode EntitySize")
+}
+
+func (r *repo) GetQuotaUsage(ctx context.Context, tenant string) (*QuotaUsage, error) {
+	usage := &QuotaUsage{Tenant: tenant}
+	if _, err := r.dao.GetResource(ctx, usage); nil != err {
+		if errors.Is(err, xerrors.ErrResourceNotFound) {
+			return usage, nil
+		}
+		return nil, errors.Wrap(err, "get quota usage repository")
+	}
+	return usage, nil
+}
+
+func (r *repo) UpdateQuotaUsage(ctx context.Context, tenant, eid string, handler QuotaUpdateFunc) error {
+	usage := &QuotaUsage{Tenant: tenant}
+	size := &EntitySize{Tenant: tenant, EntityID: eid}
+	err := r.dao.UpdateResources(ctx, func() error {
+		return handler(usage, size)
+	}, usage, size)
+	return errors.Wrap(err, "update quota usage repository")
+}
+
+type QuotaUpdateFunc func(*QuotaUsage, *EntitySize) error
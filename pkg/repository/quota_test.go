@@ -0,0 +1,41 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntitySize_Decode(t *testing.T) {
+	size := &EntitySize{Tenant: "tenant01", EntityID: "device123"}
+	key, err := size.EncodeKey()
+	assert.Nil(t, err)
+
+	// never stored.
+	assert.Nil(t, size.Decode(key, nil))
+	assert.False(t, size.Exists)
+
+	assert.Nil(t, size.Decode(key, []byte(`{"Size":42}`)))
+	assert.True(t, size.Exists)
+	assert.Equal(t, int64(42), size.Size)
+
+	bytes, err := size.Encode()
+	assert.Nil(t, err)
+	assert.NotContains(t, string(bytes), "Exists")
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	"github.com/tkeel-io/core/pkg/repository/dao"
 )
 
@@ -19,3 +20,7 @@ func New(dao dao.IDao) IRepository {
 func (r *repo) GetLastRevision(ctx context.Context) int64 {
 	return r.dao.GetLastRevision(ctx)
 }
+
+func (r *repo) Compact(ctx context.Context) error {
+	return errors.Wrap(r.dao.Compact(ctx), "compact repository")
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/repository/dao"
+)
+
+const SnapshotStorePrefix = "CORE.SNAPSHOT"
+
+var _ dao.Resource = (*snapshotsResource)(nil)
+
+// Snapshot encoded entity state captured before a write.
+type Snapshot struct {
+	ID        string `json:"id"`
+	Version   int64  `json:"version"`
+	Timestamp int64  `json:"timestamp"`
+	Data      []byte `json:"data"`
+}
+
+// snapshotsResource snapshots of an entity, oldest first, kept in state store along with the entity.
+type snapshotsResource struct {
+	id        string
+	snapshots []*Snapshot
+}
+
+func (s *snapshotsResource) EncodeKey() ([]byte, error) {
+	return []byte(SnapshotStorePrefix + "." + s.id), nil
+}
+
+func (s *snapshotsResource) Encode() ([]byte, error) {
+	bytes, err := json.Marshal(s.snapshots)
+	return bytes, errors.Wrap(err, "encode snapshots")
+}
+
+func (s *snapshotsResource) Decode(key, bytes []byte) error {
+	s.snapshots = nil
+	err := json.Unmarshal(bytes, &s.snapshots)
+	return errors.Wrap(err, "decode snapshots")
+}
+
+// PutSnapshot append snapshot of the entity, keeps the latest limit snapshots,
+// replaces the last snapshot of the same version.
+func (r *repo) PutSnapshot(ctx context.Context, eid string, snapshot *Snapshot, limit int) error {
+	snapshots, err := r.ListSnapshots(ctx, eid)
+	if nil != err {
+		return errors.Wrap(err, "put snapshot repository")
+	}
+
+	if n := len(snapshots); n > 0 && snapshots[n-1].Version == snapshot.Version {
+		snapshots = snapshots[:n-1]
+	}
+	snapshots = append(snapshots, snapshot)
+	if limit > 0 && len(snapshots) > limit {
+		snapshots = snapshots[len(snapshots)-limit:]
+	}
+
+	err = r.dao.StoreResource(ctx, &snapshotsResource{id: eid, snapshots: snapshots})
+	return errors.Wrap(err, "put snapshot repository")
+}
+
+// ListSnapshots returns snapshots of the entity, oldest first.
+func (r *repo) ListSnapshots(ctx context.Context, eid string) ([]*Snapshot, error) {
+	ret, err := r.dao.GetStoreResource(ctx, &snapshotsResource{id: eid})
+	if nil != err {
+		if xerrors.IsEntityNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "list snapshots repository")
+	}
+
+	res, _ := ret.(*snapshotsResource)
+	return res.snapshots, nil
+}
+
+func (r *repo) DelSnapshots(ctx context.Context, eid string) error {
+	err := r.dao.RemoveStoreResource(ctx, &snapshotsResource{id: eid})
+	return errors.Wrap(err, "del snapshots repository")
+}
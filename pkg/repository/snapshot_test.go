@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkeel-io/core/pkg/config"
+	"github.com/tkeel-io/core/pkg/repository/dao"
+)
+
+func Test_PutSnapshot(t *testing.T) {
+	ctx := context.Background()
+	memDao, err := dao.NewMock(ctx, config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+	r := &repo{dao: memDao}
+
+	snapshots, err := r.ListSnapshots(ctx, "device123")
+	assert.Nil(t, err)
+	assert.Empty(t, snapshots)
+
+	for _, version := range []int64{1, 2, 2, 3, 4} {
+		assert.Nil(t, r.PutSnapshot(ctx, "device123", &Snapshot{Version: version}, 3))
+	}
+
+	// same version replaced, oldest dropped over limit.
+	snapshots, err = r.ListSnapshots(ctx, "device123")
+	assert.Nil(t, err)
+	versions := []int64{}
+	for _, snapshot := range snapshots {
+		versions = append(versions, snapshot.Version)
+	}
+	assert.Equal(t, []int64{2, 3, 4}, versions)
+
+	assert.Nil(t, r.DelSnapshots(ctx, "device123"))
+	snapshots, err = r.ListSnapshots(ctx, "device123")
+	assert.Nil(t, err)
+	assert.Empty(t, snapshots)
+}
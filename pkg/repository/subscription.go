@@ -13,6 +13,8 @@ import (
 
 const (
 	SubscriptionPrefix = "/core/v1/subscription"
+	// key segment mark of type level subscription.
+	typeSubscriptionMark = "type:"
 )
 
 type ListSubscriptionReq struct {
@@ -38,6 +40,24 @@ type Subscription struct {
 
 	SourceEntityPaths []string
 	SourceEntityID    string
+	// SourceEntityType subscribe entities of the type rather than a single entity.
+	SourceEntityType string `json:"source_entity_type,omitempty"`
+	// Delivery delivery guarantee of messages, DeliveryAtMostOnce if empty.
+	Delivery string `json:"delivery,omitempty"`
+	// TargetPath property path of Target entity written by mirror subscriptions.
+	TargetPath string `json:"target_path,omitempty"`
+}
+
+const (
+	// DeliveryAtMostOnce messages published once, lost if publishing failed.
+	DeliveryAtMostOnce = "at-most-once"
+	// DeliveryAtLeastOnce messages retried with backoff, dead-lettered if retries exhausted.
+	DeliveryAtLeastOnce = "at-least-once"
+)
+
+// TypeLevel whether subscribe all entities of a type.
+func (s *Subscription) TypeLevel() bool {
+	return s.SourceEntityType != ""
 }
 
 func NewSubscription(ID, Owner, Mode, Source, Filter, Target, Topic, PubsubName string) *Subscription {
@@ -58,8 +78,13 @@ func (s *Subscription) EncodeKey() ([]byte, error) {
 		return nil, errors.Errorf("Subscription ID is empty")
 	}
 
+	source := s.SourceEntityID
+	if s.TypeLevel() {
+		source = typeSubscriptionMark + s.SourceEntityType
+	}
+
 	keyString := fmt.Sprintf("%s/%s/%s/%s",
-		SubscriptionPrefix, s.Owner, s.ID, s.SourceEntityID)
+		SubscriptionPrefix, s.Owner, s.ID, source)
 	return []byte(keyString), nil
 }
 
@@ -80,6 +105,10 @@ func (s *Subscription) Decode(key, bytes []byte) error {
 	}
 	s.Owner = keys[4]
 	s.ID = keys[5]
+	if strings.HasPrefix(keys[6], typeSubscriptionMark) {
+		s.SourceEntityType = strings.TrimPrefix(keys[6], typeSubscriptionMark)
+		return nil
+	}
 	s.SourceEntityID = keys[6]
 	return nil
 }
@@ -38,3 +38,22 @@ func Test_repo_PutSubscription(t *testing.T) {
 		})
 	}
 }
+
+func TestSubscription_TypeLevelKey(t *testing.T) {
+	sub := &Subscription{ID: "sub-1", Owner: "admin", SourceEntityType: "DEVICE"}
+	key, err := sub.EncodeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key) != "/core/v1/subscription/admin/sub-1/type:DEVICE" {
+		t.Errorf("EncodeKey() = %s", key)
+	}
+
+	var decoded Subscription
+	if err = decoded.Decode(key, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.TypeLevel() || decoded.SourceEntityType != "DEVICE" || decoded.SourceEntityID != "" {
+		t.Errorf("Decode() = %+v", decoded)
+	}
+}
@@ -2,15 +2,22 @@ package repository
 
 import (
 	"context"
+	"time"
 )
 
 type IRepository interface {
 	GetLastRevision(ctx context.Context) int64
+	Compact(ctx context.Context) error
 	PutEntity(ctx context.Context, eid string, data []byte) error
 	FlushEntity(ctx context.Context) error
 	GetEntity(ctx context.Context, eid string) ([]byte, error)
+	GetEntityFromReplica(ctx context.Context, eid string) ([]byte, error)
+	GetEntityWithETag(ctx context.Context, eid string) ([]byte, string, error)
 	DelEntity(ctx context.Context, eid string) error
 	HasEntity(ctx context.Context, eid string) (bool, error)
+	HasEntities(ctx context.Context, eids []string) (map[string]bool, error)
+	LockEntity(ctx context.Context, eid string, ttl time.Duration) (func(), error)
+	Transaction(ctx context.Context, fn func(tx *Tx) error) error
 	PutExpression(ctx context.Context, expr Expression) error
 	GetExpression(ctx context.Context, expr Expression) (Expression, error)
 	DelExpression(ctx context.Context, expr Expression) error
@@ -18,6 +25,8 @@ type IRepository interface {
 	HasExpression(ctx context.Context, expr Expression) (bool, error)
 	ListExpression(ctx context.Context, rev int64, req *ListExprReq) ([]*Expression, error)
 	RangeExpression(ctx context.Context, rev int64, handler RangeExpressionFunc)
+	PageExpression(ctx context.Context, from string, limit int64) ([]*Expression, string, error)
+	RawExpressions(ctx context.Context, owner, entityID string) (map[string][]byte, error)
 	WatchExpression(ctx context.Context, rev int64, handler WatchExpressionFunc)
 	PutSubscription(ctx context.Context, expr *Subscription) error
 	GetSubscription(ctx context.Context, expr *Subscription) (*Subscription, error)
@@ -25,4 +34,27 @@ type IRepository interface {
 	HasSubscription(ctx context.Context, expr *Subscription) (bool, error)
 	RangeSubscription(ctx context.Context, rev int64, handler RangeSubscriptionFunc)
 	WatchSubscription(ctx context.Context, rev int64, handler WatchSubscriptionFunc)
+	PutGroup(ctx context.Context, group *Group) error
+	GetGroup(ctx context.Context, group *Group) (*Group, error)
+	DelGroup(ctx context.Context, group *Group) error
+	ListGroup(ctx context.Context, rev int64, owner string) ([]*Group, error)
+	PutMembership(ctx context.Context, m *Membership) error
+	DelMembership(ctx context.Context, m *Membership) error
+	ListGroupMembers(ctx context.Context, rev int64, gid string) ([]string, error)
+	ListEntityGroups(ctx context.Context, rev int64, eid string) ([]string, error)
+	PutMirrorIndex(ctx context.Context, index *MirrorIndex) error
+	DelMirrorIndex(ctx context.Context, index *MirrorIndex) error
+	ListEntityMirrors(ctx context.Context, rev int64, eid string) ([]*MirrorIndex, error)
+	GetQuotaUsage(ctx context.Context, tenant string) (*QuotaUsage, error)
+	UpdateQuotaUsage(ctx context.Context, tenant, eid string, handler QuotaUpdateFunc) error
+	PutDeleteIntent(ctx context.Context, intent *DeleteIntent) error
+	DelDeleteIntent(ctx context.Context, intent *DeleteIntent) error
+	ListDeleteIntent(ctx context.Context, rev int64) ([]*DeleteIntent, error)
+	HasDeleteIntent(ctx context.Context, eid string) (bool, error)
+	PutIndexIntent(ctx context.Context, intent *IndexIntent) error
+	DelIndexIntent(ctx context.Context, intent *IndexIntent) error
+	ListIndexIntent(ctx context.Context, rev int64) ([]*IndexIntent, error)
+	PutSnapshot(ctx context.Context, eid string, snapshot *Snapshot, limit int) error
+	ListSnapshots(ctx context.Context, eid string) ([]*Snapshot, error)
+	DelSnapshots(ctx context.Context, eid string) error
 }
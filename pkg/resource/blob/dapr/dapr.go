@@ -0,0 +1,89 @@
+package dapr
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/resource/blob"
+	"github.com/tkeel-io/core/pkg/util"
+	"github.com/tkeel-io/core/pkg/util/dapr"
+	"github.com/tkeel-io/kit/log"
+)
+
+// blobKeyPrefix keeps blobs apart from entity state when the state store component shared.
+const blobKeyPrefix = "blob/"
+
+type daprMetadata struct {
+	StoreName string `mapstructure:"store_name"`
+}
+
+// daprBlobStore persists blobs in a dapr state store component, blobs survive restarts
+// and are shared by core instances.
+type daprBlobStore struct {
+	id        string
+	storeName string
+}
+
+func (d *daprBlobStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	var conn dapr.Client
+	if conn = dapr.Get().Select(); nil == conn {
+		log.L().Error("nil connection", logf.Key(key),
+			logf.String("store_name", d.storeName), logf.ID(d.id))
+		return "", errors.Wrap(xerrors.ErrConnectionNil, "dapr send")
+	}
+
+	ref := blobKeyPrefix + key
+	if err := conn.SaveState(ctx, d.storeName, ref, data); nil != err {
+		return "", errors.Wrap(err, "dapr blob put")
+	}
+	return ref, nil
+}
+
+func (d *daprBlobStore) Get(ctx context.Context, ref string) ([]byte, error) {
+	var conn dapr.Client
+	if conn = dapr.Get().Select(); nil == conn {
+		log.L().Error("nil connection", logf.Key(ref),
+			logf.String("store_name", d.storeName), logf.ID(d.id))
+		return nil, errors.Wrap(xerrors.ErrConnectionNil, "dapr send")
+	}
+
+	item, err := conn.GetState(ctx, d.storeName, ref)
+	if nil != err {
+		return nil, errors.Wrap(err, "dapr blob get")
+	} else if len(item.Value) == 0 {
+		return nil, xerrors.ErrResourceNotFound
+	}
+	return item.Value, nil
+}
+
+func (d *daprBlobStore) Delete(ctx context.Context, ref string) error {
+	var conn dapr.Client
+	if conn = dapr.Get().Select(); nil == conn {
+		log.L().Error("nil connection", logf.Key(ref),
+			logf.String("store_name", d.storeName), logf.ID(d.id))
+		return errors.Wrap(xerrors.ErrConnectionNil, "dapr send")
+	}
+
+	return errors.Wrap(conn.DeleteState(ctx, d.storeName, ref), "dapr blob delete")
+}
+
+func init() {
+	log.SuccessStatusEvent(os.Stdout, "Register Resource<blob.dapr> successful")
+	blob.Register("dapr", func(properties map[string]interface{}) (blob.BlobStore, error) {
+		var daprMeta daprMetadata
+		if err := mapstructure.Decode(properties, &daprMeta); nil != err {
+			return nil, errors.Wrap(err, "decode blob.dapr configuration")
+		} else if strings.TrimSpace(daprMeta.StoreName) == "" {
+			return nil, errors.Wrap(xerrors.ErrInvalidParam, "blob.dapr store_name required")
+		}
+
+		id := util.UUID("bdapr")
+		log.L().Info("create blob.dapr instance", logf.ID(id), logf.String("store_name", daprMeta.StoreName))
+		return &daprBlobStore{id: id, storeName: daprMeta.StoreName}, nil
+	})
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/resource/blob"
+	"github.com/tkeel-io/core/pkg/util"
+	"github.com/tkeel-io/kit/log"
+)
+
+// memBlobStore keeps blobs in memory, blobs lost on restart and not shared by instances.
+type memBlobStore struct {
+	id    string
+	lock  sync.RWMutex
+	blobs map[string][]byte
+}
+
+func (m *memBlobStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.blobs[key] = data
+	return key, nil
+}
+
+func (m *memBlobStore) Get(ctx context.Context, ref string) ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if data, ok := m.blobs[ref]; ok {
+		return data, nil
+	}
+	return nil, xerrors.ErrResourceNotFound
+}
+
+func (m *memBlobStore) Delete(ctx context.Context, ref string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.blobs, ref)
+	return nil
+}
+
+func New() blob.BlobStore {
+	id := util.UUID("bmem")
+	log.L().Info("create blob.memory instance", logf.ID(id))
+	return &memBlobStore{id: id, blobs: make(map[string][]byte)}
+}
+
+func init() {
+	log.SuccessStatusEvent(os.Stdout, "Register Resource<blob.memory> successful")
+	blob.Register("memory", func(properties map[string]interface{}) (blob.BlobStore, error) {
+		return New(), nil
+	})
+}
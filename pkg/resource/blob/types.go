@@ -0,0 +1,67 @@
+package blob
+
+import (
+	"context"
+
+	logf "github.com/tkeel-io/core/pkg/logfield"
+
+	"github.com/tkeel-io/core/pkg/resource"
+	"github.com/tkeel-io/kit/log"
+)
+
+// BlobStore stores large property values outside the entity state.
+type BlobStore interface {
+	// Put uploads data, returns reference of the blob.
+	Put(ctx context.Context, key string, data []byte) (ref string, err error)
+	// Get downloads blob by reference.
+	Get(ctx context.Context, ref string) ([]byte, error)
+	// Delete remove blob by reference.
+	Delete(ctx context.Context, ref string) error
+}
+
+// Reference kept in entity state in place of the blob value.
+type Reference struct {
+	Ref  string `json:"blob_ref" mapstructure:"blob_ref"`
+	Size int64  `json:"size" mapstructure:"size"`
+}
+
+func (r Reference) Value() map[string]interface{} {
+	return map[string]interface{}{"blob_ref": r.Ref, "size": r.Size}
+}
+
+// ParseReference parse blob reference from property value.
+func ParseReference(v interface{}) (Reference, bool) {
+	val, ok := v.(map[string]interface{})
+	if !ok {
+		return Reference{}, false
+	}
+
+	ref, _ := val["blob_ref"].(string)
+	size, _ := val["size"].(float64)
+	return Reference{Ref: ref, Size: int64(size)}, ref != ""
+}
+
+var registeredBlobStores = make(map[string]Generator)
+
+type Generator func(map[string]interface{}) (BlobStore, error)
+
+func Register(name string, handler Generator) {
+	registeredBlobStores[name] = handler
+}
+
+// NewBlobStore returns nil if blob store not registered.
+func NewBlobStore(metadata resource.Metadata) BlobStore {
+	generator, has := registeredBlobStores[metadata.Name]
+	if !has {
+		log.L().Warn("blob store not registered", logf.String("name", metadata.Name))
+		return nil
+	}
+
+	blobStore, err := generator(metadata.Properties)
+	if nil != err {
+		log.L().Error("new BlobStore instance", logf.Error(err),
+			logf.String("name", metadata.Name), logf.Any("properties", metadata.Properties))
+		return nil
+	}
+	return blobStore
+}
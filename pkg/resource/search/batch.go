@@ -0,0 +1,237 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/metrics"
+	"github.com/tkeel-io/core/pkg/resource/search/driver"
+	"github.com/tkeel-io/kit/log"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultBatchInterval = time.Second
+	// bulk index attempts of a document before dropped.
+	maxBulkAttempts = 3
+)
+
+type syncIndexKey struct{}
+
+// WithSyncIndex index documents of IndexBytes with the context before returning, even if batched.
+func WithSyncIndex(ctx context.Context) context.Context {
+	return context.WithValue(ctx, syncIndexKey{}, true)
+}
+
+func syncIndexFrom(ctx context.Context) bool {
+	enabled, _ := ctx.Value(syncIndexKey{}).(bool)
+	return enabled
+}
+
+// indexBatcher buffers documents and indexes them by bulk requests once size
+// documents buffered or interval elapsed, a buffered document replaced by later
+// documents of the same id.
+type indexBatcher struct {
+	size     int
+	interval time.Duration
+	index    func(ctx context.Context, docs []driver.Document) error
+
+	// writing serializes bulk requests and writes bypassing the buffer,
+	// so an older document in flight never overwrites a newer one.
+	writing sync.Mutex
+
+	lock      sync.Mutex
+	stopped   bool
+	docs      []driver.Document
+	positions map[string]int
+	// failed bulk attempts keyed by id of documents re-queued.
+	attempts map[string]int
+
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	stop    sync.Once
+}
+
+func newIndexBatcher(size int, interval time.Duration, index func(context.Context, []driver.Document) error) *indexBatcher {
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	if interval <= 0 {
+		interval = defaultBatchInterval
+	}
+
+	b := &indexBatcher{
+		size:      size,
+		interval:  interval,
+		index:     index,
+		positions: make(map[string]int),
+		attempts:  make(map[string]int),
+		flushCh:   make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+
+	go b.run()
+	return b
+}
+
+// add buffer the document, returns false if batching stopped.
+func (b *indexBatcher) add(doc driver.Document) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.stopped {
+		return false
+	}
+	delete(b.attempts, doc.ID)
+	if pos, ok := b.positions[doc.ID]; ok {
+		b.docs[pos] = doc
+		return true
+	}
+
+	b.positions[doc.ID] = len(b.docs)
+	b.docs = append(b.docs, doc)
+	metrics.CollectorIndexQueueDepth.Inc()
+	if len(b.docs) >= b.size {
+		select {
+		case b.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// requeue buffer documents of a failed bulk request again, unless replaced by later documents
+// meanwhile, documents failed maxBulkAttempts times dropped and returned.
+func (b *indexBatcher) requeue(docs []driver.Document) []driver.Document {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var dropped []driver.Document
+	for _, doc := range docs {
+		if _, ok := b.positions[doc.ID]; ok {
+			continue
+		}
+		if b.attempts[doc.ID]++; b.attempts[doc.ID] >= maxBulkAttempts {
+			delete(b.attempts, doc.ID)
+			dropped = append(dropped, doc)
+			continue
+		}
+
+		b.positions[doc.ID] = len(b.docs)
+		b.docs = append(b.docs, doc)
+		metrics.CollectorIndexQueueDepth.Inc()
+	}
+	return dropped
+}
+
+// exclusive run the write of the id bypassing the buffer, once bulk requests in flight completed,
+// buffered document of the id dropped so it can not overwrite the write.
+func (b *indexBatcher) exclusive(id string, write func() error) error {
+	b.writing.Lock()
+	defer b.writing.Unlock()
+	b.discard(id)
+	return write()
+}
+
+// discard drop buffered document of the id.
+func (b *indexBatcher) discard(id string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.attempts, id)
+	pos, ok := b.positions[id]
+	if !ok {
+		return
+	}
+
+	b.docs = append(b.docs[:pos], b.docs[pos+1:]...)
+	delete(b.positions, id)
+	for index := pos; index < len(b.docs); index++ {
+		b.positions[b.docs[index].ID] = index
+	}
+	metrics.CollectorIndexQueueDepth.Dec()
+}
+
+func (b *indexBatcher) pending() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.docs)
+}
+
+// flush index buffered documents by one bulk request, documents failed re-queued,
+// see driver.BulkIndexError, all documents re-queued if the request failed.
+func (b *indexBatcher) flush(ctx context.Context) error {
+	b.writing.Lock()
+	defer b.writing.Unlock()
+
+	b.lock.Lock()
+	docs := b.docs
+	b.docs = nil
+	b.positions = make(map[string]int)
+	b.lock.Unlock()
+
+	if len(docs) == 0 {
+		return nil
+	}
+
+	metrics.CollectorIndexQueueDepth.Sub(float64(len(docs)))
+	err := b.index(ctx, docs)
+	if err == nil {
+		return nil
+	}
+
+	failed := docs
+	var bulkErr *driver.BulkIndexError
+	if errors.As(err, &bulkErr) {
+		failed = bulkErr.Failed(docs)
+	}
+	if dropped := b.requeue(failed); len(dropped) > 0 {
+		ids := make([]string, 0, len(dropped))
+		for _, doc := range dropped {
+			ids = append(ids, doc.ID)
+		}
+		log.L().Error("bulk index, documents dropped", logf.Count(int64(len(dropped))), logf.Any("ids", ids))
+	}
+	return errors.Wrapf(err, "bulk index %d documents, %d failed", len(docs), len(failed))
+}
+
+func (b *indexBatcher) run() {
+	defer close(b.doneCh)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stopCh:
+			return
+		case <-ticker.C:
+		case <-b.flushCh:
+		}
+
+		if err := b.flush(context.Background()); err != nil {
+			log.L().Error("flush search index batch", logf.Error(err))
+		}
+	}
+}
+
+// close stop batching and flush buffered documents.
+func (b *indexBatcher) close(ctx context.Context) error {
+	b.stop.Do(func() {
+		b.lock.Lock()
+		b.stopped = true
+		b.lock.Unlock()
+		close(b.stopCh)
+	})
+	select {
+	case <-b.doneCh:
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "stop search index batch")
+	}
+	return b.flush(ctx)
+}
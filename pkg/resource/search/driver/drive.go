@@ -2,6 +2,7 @@ package driver
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	pb "github.com/tkeel-io/core/api/core/v1"
@@ -11,8 +12,110 @@ type Type string
 
 type SearchEngine interface {
 	BuildIndex(ctx context.Context, index, content string) error
+	// BulkIndex index the documents by one request, documents become searchable on next refresh.
+	BulkIndex(ctx context.Context, docs []Document) error
 	Search(ctx context.Context, request SearchRequest) (SearchResponse, error)
 	Delete(ctx context.Context, id string) error
+	// Flush make all indexed documents visible to search.
+	Flush(ctx context.Context) error
+	// PutMapping create or update mapping of document fields, fields keyed by dotted path.
+	PutMapping(ctx context.Context, fields map[string]FieldKind) error
+	// Aggregate compute aggregations over documents matching the request.
+	Aggregate(ctx context.Context, request SearchRequest, aggs []Aggregation) (*AggregationResult, error)
+}
+
+// Document search document indexed by id, body the JSON encoded document.
+type Document struct {
+	ID   string
+	Body string
+}
+
+// BulkIndexError documents of a bulk request failed, the others indexed.
+type BulkIndexError struct {
+	// IDs of documents failed.
+	IDs    []string
+	Reason string
+}
+
+func (e *BulkIndexError) Error() string {
+	return fmt.Sprintf("bulk index, %d documents failed, %s", len(e.IDs), e.Reason)
+}
+
+// Failed returns the documents failed among docs.
+func (e *BulkIndexError) Failed(docs []Document) []Document {
+	ids := make(map[string]struct{}, len(e.IDs))
+	for _, id := range e.IDs {
+		ids[id] = struct{}{}
+	}
+
+	var failed []Document
+	for _, doc := range docs {
+		if _, ok := ids[doc.ID]; ok {
+			failed = append(failed, doc)
+		}
+	}
+	return failed
+}
+
+type AggregationKind string
+
+const (
+	// AggregationTerms count documents by distinct values of the field.
+	AggregationTerms AggregationKind = "terms"
+	// AggregationStats count, min, max, avg and sum of numeric field.
+	AggregationStats AggregationKind = "stats"
+)
+
+// Aggregation named aggregation of a document field.
+type Aggregation struct {
+	Name  string          `json:"name"`
+	Kind  AggregationKind `json:"kind"`
+	Field string          `json:"field"`
+	// Size max buckets of terms aggregation, default 10.
+	Size int `json:"size,omitempty"`
+}
+
+type AggregationResult struct {
+	// Total documents matching the request.
+	Total int64 `json:"total"`
+	// Aggregations keyed by aggregation name.
+	Aggregations map[string]*AggregationValue `json:"aggregations"`
+}
+
+type AggregationValue struct {
+	Buckets []*Bucket        `json:"buckets,omitempty"`
+	Stats   *AggregatedStats `json:"stats,omitempty"`
+}
+
+type Bucket struct {
+	Key   interface{} `json:"key"`
+	Count int64       `json:"count"`
+}
+
+type AggregatedStats struct {
+	Count int64   `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Sum   float64 `json:"sum"`
+}
+
+// FieldKind decides how a document field is analyzed.
+type FieldKind string
+
+const (
+	FieldKindKeyword FieldKind = "keyword"
+	FieldKindText    FieldKind = "text"
+	FieldKindNumeric FieldKind = "numeric"
+)
+
+func (k FieldKind) Valid() bool {
+	switch k {
+	case FieldKindKeyword, FieldKindText, FieldKindNumeric:
+		return true
+	default:
+		return false
+	}
 }
 
 type SelectDriveOption func() Type
@@ -32,8 +135,17 @@ type SearchRequest struct {
 	Query     string                `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"`
 	Page      *pb.Pager             `protobuf:"bytes,4,opt,name=page,proto3" json:"page,omitempty"`
 	Condition []*pb.SearchCondition `protobuf:"bytes,5,rep,name=condition,proto3" json:"condition,omitempty"`
+	Highlight []string              `json:"highlight,omitempty"`
+	// Fields project returned documents to the fields, all fields if empty.
+	Fields []string `json:"fields,omitempty"`
 }
 
+// reserved fields of search result item, relevance score and highlighted snippets.
+const (
+	FieldScore     = "_score"
+	FieldHighlight = "_highlight"
+)
+
 type SearchResponse struct {
 	Total  int64                    `json:"total,omitempty"`
 	Data   []map[string]interface{} `json:"data,omitempty"`
@@ -23,7 +23,6 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"reflect"
 	"strings"
 
 	"github.com/goinggo/mapstructure"
@@ -103,6 +102,31 @@ func (es *ESClient) BuildIndex(ctx context.Context, index, body string) error {
 	return nil
 }
 
+// BulkIndex index the documents by one bulk request, without refresh.
+func (es *ESClient) BulkIndex(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	bulk := es.Client.Bulk().Index(EntityIndex)
+	for _, doc := range docs {
+		bulk.Add(elastic.NewBulkIndexRequest().Id(doc.ID).Doc(doc.Body))
+	}
+
+	resp, err := bulk.Do(ctx)
+	if err != nil {
+		return errors.Wrap(err, "elasticsearch bulk index")
+	}
+	if failed := resp.Failed(); len(failed) > 0 {
+		bulkErr := &BulkIndexError{Reason: fmt.Sprintf("%s: %v", failed[0].Id, failed[0].Error)}
+		for _, item := range failed {
+			bulkErr.IDs = append(bulkErr.IDs, item.Id)
+		}
+		return errors.Wrap(bulkErr, "elasticsearch bulk index")
+	}
+	return nil
+}
+
 func (es *ESClient) Delete(ctx context.Context, id string) error {
 	_, err := es.Client.Delete().Index(EntityIndex).Id(id).Refresh("true").Do(ctx)
 	if nil != err {
@@ -113,6 +137,55 @@ func (es *ESClient) Delete(ctx context.Context, id string) error {
 	return errors.Wrap(err, "elasticsearch delete by id")
 }
 
+// Flush refresh entity index, expensive, for tests and read-after-write flows only.
+func (es *ESClient) Flush(ctx context.Context) error {
+	_, err := es.Client.Refresh(EntityIndex).Do(ctx)
+	return errors.Wrap(err, "elasticsearch refresh index")
+}
+
+// PutMapping put field mappings into entity index, existing field types can not be changed.
+func (es *ESClient) PutMapping(ctx context.Context, fields map[string]FieldKind) error {
+	body := buildMapping(fields)
+	if _, err := es.Client.PutMapping().Index(EntityIndex).BodyJson(body).Do(ctx); nil != err {
+		return errors.Wrap(err, "elasticsearch put mapping")
+	}
+	return nil
+}
+
+// buildMapping returns mapping body of fields, nested path mapped as object properties.
+// keyword fields keep the keyword sub field, conditions query on it.
+func buildMapping(fields map[string]FieldKind) map[string]interface{} {
+	root := make(map[string]interface{})
+	for path, kind := range fields {
+		props := root
+		segs := strings.Split(path, ".")
+		for _, seg := range segs[:len(segs)-1] {
+			node, ok := props[seg].(map[string]interface{})
+			if sub, has := node["properties"].(map[string]interface{}); ok && has {
+				props = sub
+				continue
+			}
+			sub := make(map[string]interface{})
+			props[seg] = map[string]interface{}{"properties": sub}
+			props = sub
+		}
+		props[segs[len(segs)-1]] = fieldMapping(kind)
+	}
+	return map[string]interface{}{"properties": root}
+}
+
+func fieldMapping(kind FieldKind) map[string]interface{} {
+	keyword := map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 4096}}
+	switch kind {
+	case FieldKindKeyword:
+		return map[string]interface{}{"type": "keyword", "fields": keyword}
+	case FieldKindNumeric:
+		return map[string]interface{}{"type": "double"}
+	default:
+		return map[string]interface{}{"type": "text", "fields": keyword}
+	}
+}
+
 func (es *ESClient) DeleteByQuery(ctx context.Context, query map[string]interface{}) error {
 	var bytes bytes.Buffer
 	if err := json.NewEncoder(&bytes).Encode(query); err != nil {
@@ -126,36 +199,32 @@ func (es *ESClient) DeleteByQuery(ctx context.Context, query map[string]interfac
 
 func (es *ESClient) Search(ctx context.Context, req SearchRequest) (SearchResponse, error) {
 	resp := SearchResponse{}
-	boolQuery := elastic.NewBoolQuery()
+	boolQuery := buildBoolQuery(req)
 	searchQuery := es.Client.Search().Index(EntityIndex)
 
-	if req.Condition != nil {
-		condition2boolQuery(req.Condition, boolQuery)
-	}
-	if req.Query != "" {
-		queryKeyWords := strings.Split(req.Query, " ")
-		for _, val := range queryKeyWords {
-			boolQuery.Must(elastic.NewWildcardQuery("search_model.keyword", fmt.Sprintf("*%s*", val)))
-			//boolQuery.Should(elastic.NewRegexpQuery(name, fmt.Sprintf("*%s*", val)))
-		}
-	}
-
 	req.Page = defaultPage(req.Page)
 	searchQuery = searchQuery.Sort(req.Page.Sort, !req.Page.Reverse)
 	searchQuery = searchQuery.Query(boolQuery).From(int(req.Page.Offset)).Size(int(req.Page.Limit))
+	// compute score even if sorted by field.
+	searchQuery = searchQuery.TrackScores(true)
+	if len(req.Highlight) > 0 {
+		highlight := elastic.NewHighlight()
+		for _, field := range req.Highlight {
+			highlight = highlight.Fields(elastic.NewHighlighterField(field))
+		}
+		searchQuery = searchQuery.Highlight(highlight)
+	}
+	if len(req.Fields) > 0 {
+		searchQuery = searchQuery.FetchSourceContext(
+			elastic.NewFetchSourceContext(true).Include(req.Fields...))
+	}
 
 	searchResult, err := searchQuery.Pretty(true).Do(ctx)
 	if err != nil {
 		return resp, errors.Wrap(err, "query search failed")
 	}
 
-	var data []map[string]interface{}
-	for _, item := range searchResult.Each(reflect.TypeOf(map[string]interface{}{})) {
-		if t, ok := item.(map[string]interface{}); ok {
-			data = append(data, t)
-		}
-	}
-
+	data := decodeHits(searchResult)
 	resp.Total = searchResult.TotalHits()
 	resp.Data = data
 	resp.Raw, _ = json.Marshal(data)
@@ -167,6 +236,126 @@ func (es *ESClient) Search(ctx context.Context, req SearchRequest) (SearchRespon
 	return resp, nil
 }
 
+// decodeHits decode hit sources, carry score and highlight within reserved fields.
+func (es *ESClient) Aggregate(ctx context.Context, req SearchRequest, aggs []Aggregation) (*AggregationResult, error) {
+	searchQuery := es.Client.Search().Index(EntityIndex).Query(buildBoolQuery(req)).Size(0)
+	for _, agg := range aggs {
+		switch agg.Kind {
+		case AggregationTerms:
+			field := agg.Field
+			if !strings.HasSuffix(field, ".keyword") {
+				field += ".keyword"
+			}
+			terms := elastic.NewTermsAggregation().Field(field)
+			if agg.Size > 0 {
+				terms = terms.Size(agg.Size)
+			}
+			searchQuery = searchQuery.Aggregation(agg.Name, terms)
+		case AggregationStats:
+			searchQuery = searchQuery.Aggregation(agg.Name, elastic.NewStatsAggregation().Field(agg.Field))
+		default:
+			return nil, errors.Errorf("aggregation %s, unsupported kind %s", agg.Name, agg.Kind)
+		}
+	}
+
+	searchResult, err := searchQuery.Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "query aggregation failed")
+	}
+
+	return &AggregationResult{
+		Total:        searchResult.TotalHits(),
+		Aggregations: decodeAggregations(searchResult.Aggregations, aggs),
+	}, nil
+}
+
+func buildBoolQuery(req SearchRequest) *elastic.BoolQuery {
+	boolQuery := elastic.NewBoolQuery()
+	if req.Condition != nil {
+		condition2boolQuery(req.Condition, boolQuery)
+	}
+	if req.Query != "" {
+		queryKeyWords := strings.Split(req.Query, " ")
+		for _, val := range queryKeyWords {
+			boolQuery.Must(elastic.NewWildcardQuery("search_model.keyword", fmt.Sprintf("*%s*", val)))
+			//boolQuery.Should(elastic.NewRegexpQuery(name, fmt.Sprintf("*%s*", val)))
+		}
+	}
+	return boolQuery
+}
+
+func decodeAggregations(results elastic.Aggregations, aggs []Aggregation) map[string]*AggregationValue {
+	values := make(map[string]*AggregationValue)
+	for _, agg := range aggs {
+		switch agg.Kind {
+		case AggregationTerms:
+			terms, ok := results.Terms(agg.Name)
+			if !ok {
+				continue
+			}
+			value := &AggregationValue{Buckets: make([]*Bucket, 0, len(terms.Buckets))}
+			for _, bucket := range terms.Buckets {
+				value.Buckets = append(value.Buckets, &Bucket{Key: bucket.Key, Count: bucket.DocCount})
+			}
+			values[agg.Name] = value
+		case AggregationStats:
+			stats, ok := results.Stats(agg.Name)
+			if !ok {
+				continue
+			}
+			value := &AggregatedStats{Count: stats.Count}
+			// min, max and avg are absent when no document has the field.
+			if stats.Min != nil {
+				value.Min = *stats.Min
+			}
+			if stats.Max != nil {
+				value.Max = *stats.Max
+			}
+			if stats.Avg != nil {
+				value.Avg = *stats.Avg
+			}
+			if stats.Sum != nil {
+				value.Sum = *stats.Sum
+			}
+			values[agg.Name] = &AggregationValue{Stats: value}
+		}
+	}
+	return values
+}
+
+func decodeHits(result *elastic.SearchResult) []map[string]interface{} {
+	var data []map[string]interface{}
+	if result.Hits == nil {
+		return data
+	}
+
+	for _, hit := range result.Hits.Hits {
+		item := make(map[string]interface{})
+		if err := json.Unmarshal(hit.Source, &item); nil != err {
+			log.L().Warn("decode search hit", logf.ID(hit.Id), logf.Error(err))
+			continue
+		}
+
+		if hit.Score != nil {
+			item[FieldScore] = *hit.Score
+		}
+
+		if len(hit.Highlight) > 0 {
+			highlight := make(map[string]interface{}, len(hit.Highlight))
+			for field, fragments := range hit.Highlight {
+				snippets := make([]interface{}, 0, len(fragments))
+				for _, fragment := range fragments {
+					snippets = append(snippets, fragment)
+				}
+				highlight[field] = snippets
+			}
+			item[FieldHighlight] = highlight
+		}
+		data = append(data, item)
+	}
+	return data
+}
+
 // reference: https://www.tutorialspoint.com/elasticsearch/elasticsearch_query_dsl.htm#:~:text=In%20Elasticsearch%2C%20searching%20is%20carried%20out%20by%20using,look%20for%20a%20specific%20value%20in%20specific%20field.
 // convert condition.
 func condition2boolQuery(conditions []*pb.SearchCondition, boolQuery *elastic.BoolQuery) {
@@ -189,6 +378,12 @@ func condition2boolQuery(conditions []*pb.SearchCondition, boolQuery *elastic.Bo
 			default:
 				boolQuery = boolQuery.Filter(elastic.NewTermQuery(condition.Field+".keyword", valueItem))
 			}
+		case "$in":
+			var values []interface{}
+			if list := condition.Value.GetListValue(); list != nil {
+				values = list.AsSlice()
+			}
+			boolQuery = boolQuery.Filter(elastic.NewTermsQuery(condition.Field+".keyword", values...))
 		case "$prefix":
 			boolQuery = boolQuery.Must(elastic.NewPrefixQuery(condition.Field+".keyword", condition.Value.GetStringValue()))
 		case "$wildcard":
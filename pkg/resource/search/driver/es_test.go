@@ -29,6 +29,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	pb "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/tdtl"
 )
 
 func printQuery(query elastic.Query) (string, error) {
@@ -95,6 +96,64 @@ func Test_defaultPage(t *testing.T) {
 	assert.Equal(t, DefaultLimit, page.Limit)
 }
 
+func Test_buildMapping(t *testing.T) {
+	body := buildMapping(map[string]FieldKind{
+		"basicInfo.sn":   FieldKindKeyword,
+		"basicInfo.desc": FieldKindText,
+		"metrics.temp":   FieldKindNumeric,
+	})
+
+	bytes, err := json.Marshal(body)
+	assert.Nil(t, err)
+	assert.Equal(t, "keyword", tdtl.New(bytes).Get("properties.basicInfo.properties.sn.type").String())
+	assert.Equal(t, "keyword", tdtl.New(bytes).Get("properties.basicInfo.properties.sn.fields.keyword.type").String())
+	assert.Equal(t, "text", tdtl.New(bytes).Get("properties.basicInfo.properties.desc.type").String())
+	assert.Equal(t, "double", tdtl.New(bytes).Get("properties.metrics.properties.temp.type").String())
+}
+
+func Test_decodeHits(t *testing.T) {
+	score := 1.5
+	result := &elastic.SearchResult{Hits: &elastic.SearchHits{Hits: []*elastic.SearchHit{{
+		Id:        "device123",
+		Score:     &score,
+		Source:    []byte(`{"id":"device123","basicInfo":{"name":"light"}}`),
+		Highlight: elastic.SearchHitHighlight{"basicInfo.name": {"<em>light</em>"}},
+	}, {
+		Id:     "device234",
+		Source: []byte(`{"id":"device234"}`),
+	}}}}
+
+	data := decodeHits(result)
+	assert.Len(t, data, 2)
+	assert.Equal(t, 1.5, data[0][FieldScore])
+	assert.Equal(t, map[string]interface{}{"basicInfo.name": []interface{}{"<em>light</em>"}}, data[0][FieldHighlight])
+	assert.Equal(t, "device234", data[1]["id"])
+	assert.NotContains(t, data[1], FieldScore)
+
+	_, err := structpb.NewValue(data[0])
+	assert.Nil(t, err)
+	assert.Nil(t, decodeHits(&elastic.SearchResult{}))
+}
+
+func Test_decodeAggregations(t *testing.T) {
+	results := elastic.Aggregations{
+		"by_template": []byte(`{"buckets":[{"key":"tpl-light","doc_count":3},{"key":"tpl-fan","doc_count":1}]}`),
+		"temp":        []byte(`{"count":2,"min":20.5,"max":30.5,"avg":25.5,"sum":51}`),
+		"empty":       []byte(`{"count":0,"min":null,"max":null,"avg":null,"sum":0}`),
+	}
+	values := decodeAggregations(results, []Aggregation{
+		{Name: "by_template", Kind: AggregationTerms, Field: "basicInfo.templateId"},
+		{Name: "temp", Kind: AggregationStats, Field: "properties.temp"},
+		{Name: "empty", Kind: AggregationStats, Field: "properties.humidity"},
+		{Name: "missing", Kind: AggregationTerms, Field: "owner"},
+	})
+
+	assert.Equal(t, []*Bucket{{Key: "tpl-light", Count: 3}, {Key: "tpl-fan", Count: 1}}, values["by_template"].Buckets)
+	assert.Equal(t, &AggregatedStats{Count: 2, Min: 20.5, Max: 30.5, Avg: 25.5, Sum: 51}, values["temp"].Stats)
+	assert.Equal(t, &AggregatedStats{}, values["empty"].Stats)
+	assert.NotContains(t, values, "missing")
+}
+
 func TestESClient_Search(t *testing.T) {
 	urlText := "es://admin:admin@tkeel-middleware-elasticsearch-master:9200"
 	urlIns, err := url.Parse(urlText)
@@ -18,6 +18,11 @@ func (ns *noopSearchEngine) BuildIndex(ctx context.Context, index, content strin
 	log.L().Error("BuildIndex noop")
 	return nil
 }
+
+func (ns *noopSearchEngine) BulkIndex(ctx context.Context, docs []Document) error {
+	return nil
+}
+
 func (ns *noopSearchEngine) Search(ctx context.Context, request SearchRequest) (SearchResponse, error) {
 	log.L().Error("Search noop")
 	return SearchResponse{}, nil
@@ -27,6 +32,18 @@ func (ns *noopSearchEngine) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+func (ns *noopSearchEngine) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (ns *noopSearchEngine) PutMapping(ctx context.Context, fields map[string]FieldKind) error {
+	return nil
+}
+
+func (ns *noopSearchEngine) Aggregate(ctx context.Context, request SearchRequest, aggs []Aggregation) (*AggregationResult, error) {
+	return &AggregationResult{Aggregations: map[string]*AggregationValue{}}, nil
+}
+
 func NoopDriver() Type {
 	return DriverNameNoop
 }
@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/resource/search/driver"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// FieldMapping maps internal field names to field names of the indexed search document,
+// fields not in the mapping are indexed as they are.
+type FieldMapping map[string]string
+
+// Indexed translate internal field into indexed field, nested field is translated by its top field.
+func (m FieldMapping) Indexed(field string) string {
+	return m.translate(field, m)
+}
+
+// Internal translate indexed field back into internal field.
+func (m FieldMapping) Internal(field string) string {
+	return m.translate(field, m.reverse())
+}
+
+func (m FieldMapping) translate(field string, mapping map[string]string) string {
+	if len(mapping) == 0 || field == "" {
+		return field
+	}
+
+	top, rest := field, ""
+	if index := strings.Index(field, "."); index > 0 {
+		top, rest = field[:index], field[index:]
+	}
+	if name, ok := mapping[top]; ok && name != "" {
+		return name + rest
+	}
+	return field
+}
+
+func (m FieldMapping) reverse() map[string]string {
+	reversed := make(map[string]string, len(m))
+	for internal, indexed := range m {
+		reversed[indexed] = internal
+	}
+	return reversed
+}
+
+// encodeDoc rename top fields of search document to indexed fields.
+func (m FieldMapping) encodeDoc(data []byte) ([]byte, error) {
+	if len(m) == 0 {
+		return data, nil
+	}
+
+	var doc map[string]jsoniter.RawMessage
+	if err := json.Unmarshal(data, &doc); nil != err {
+		return nil, errors.Wrap(err, "decode search document")
+	}
+
+	indexed := make(map[string]jsoniter.RawMessage, len(doc))
+	for field, value := range doc {
+		indexed[m.Indexed(field)] = value
+	}
+
+	bytes, err := json.Marshal(indexed)
+	return bytes, errors.Wrap(err, "encode search document")
+}
+
+// decodeItem rename fields of search result item back to internal fields.
+func (m FieldMapping) decodeItem(item map[string]interface{}) map[string]interface{} {
+	if len(m) == 0 {
+		return item
+	}
+
+	reversed := m.reverse()
+	internal := make(map[string]interface{}, len(item))
+	for field, value := range item {
+		if highlight, ok := value.(map[string]interface{}); ok && field == driver.FieldHighlight {
+			fields := make(map[string]interface{}, len(highlight))
+			for name, snippets := range highlight {
+				fields[m.translate(name, reversed)] = snippets
+			}
+			value = fields
+		}
+		internal[m.translate(field, reversed)] = value
+	}
+	return internal
+}
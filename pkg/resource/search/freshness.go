@@ -0,0 +1,109 @@
+package search
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/tkeel-io/core/api/core/v1"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	freshMinBackoff = 10 * time.Millisecond
+	freshMaxBackoff = 200 * time.Millisecond
+
+	fieldID      = "id"
+	fieldVersion = "version"
+)
+
+// FormatVersionToken returns token naming version of the entity, formatted as `<entity id>@<version>`.
+//
+// a version token names the entity state a caller wrote, the version taken from the write response.
+// the index reflects the token once it holds the entity at the version or a later one. only writes of
+// indexed fields(type, owner, tombstone and the indexed properties) get reindexed, so tokens of other
+// writes are satisfied by the last indexed write only, and waiting on them times out.
+func FormatVersionToken(id string, version int64) string {
+	return id + "@" + strconv.FormatInt(version, 10)
+}
+
+// ParseVersionToken returns entity id and version named by the token.
+func ParseVersionToken(token string) (string, int64, error) {
+	index := strings.LastIndex(token, "@")
+	if index <= 0 {
+		return "", 0, errors.Wrapf(ErrVersionTokenInvalid, "token %q", token)
+	}
+
+	version, err := strconv.ParseInt(token[index+1:], 10, 64)
+	if nil != err {
+		return "", 0, errors.Wrapf(ErrVersionTokenInvalid, "token %q", token)
+	}
+	return token[:index], version, nil
+}
+
+// SearchFresh search once the index reflects the version token or the timeout elapsed, stale reports
+// the timeout elapsed and the results may miss the write, see FormatVersionToken for token semantics.
+// searches without the token never wait, so only read-your-writes flows pay for the freshness.
+func (s *Service) SearchFresh(ctx context.Context, request *pb.SearchRequest, token string, timeout time.Duration) (*pb.SearchResponse, bool, error) {
+	id, version, err := ParseVersionToken(token)
+	if nil != err {
+		return nil, false, errors.Wrap(err, "search fresh")
+	}
+
+	stale := !s.waitVersion(ctx, id, version, timeout)
+	out, err := s.Search(ctx, request)
+	return out, stale, errors.Wrap(err, "search fresh")
+}
+
+// waitVersion poll the index until it holds the entity at the version, reports whether it does.
+func (s *Service) waitVersion(ctx context.Context, id string, version int64, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	backoff := freshMinBackoff
+	for {
+		if s.indexedVersion(ctx, id) >= version {
+			return true
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		} else if remaining < backoff {
+			backoff = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > freshMaxBackoff {
+			backoff = freshMaxBackoff
+		}
+	}
+}
+
+// indexedVersion returns version of the entity in the index, -1 if absent or unknown.
+func (s *Service) indexedVersion(ctx context.Context, id string) int64 {
+	resp, err := s.Search(WithFields(ctx, fieldID, fieldVersion), &pb.SearchRequest{
+		PageNum:  1,
+		PageSize: 1,
+		Condition: []*pb.SearchCondition{{
+			Field:    fieldID,
+			Operator: "$eq",
+			Value:    structpb.NewStringValue(id),
+		}},
+	})
+	if nil != err || len(resp.Items) == 0 {
+		return -1
+	}
+
+	item, _ := resp.Items[0].AsInterface().(map[string]interface{})
+	if version, ok := item[fieldVersion].(float64); ok {
+		return int64(version)
+	}
+	return -1
+}
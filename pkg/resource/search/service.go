@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	logf "github.com/tkeel-io/core/pkg/logfield"
 
@@ -58,10 +59,17 @@ func defaultRegisteredSE() map[driver.Type]driver.SearchEngine {
 var _ pb.SearchHTTPServer = &Service{}
 
 type Service struct {
-	drivers   map[driver.Type]driver.SearchEngine
-	selectOpt driver.SelectDriveOption
+	drivers      map[driver.Type]driver.SearchEngine
+	selectOpt    driver.SelectDriveOption
+	fieldMapping FieldMapping
+	typeMappings TypeMappings
+	batcher      *indexBatcher
 }
 
+// TypeMappings declares field kinds of entity types, keyed by type and property path,
+// entity types share one index, so types declaring a path must agree on its kind.
+type TypeMappings map[string]map[string]string
+
 func NewService(registered map[driver.Type]driver.SearchEngine) *Service {
 	return &Service{
 		drivers:   registered,
@@ -75,13 +83,15 @@ func (s *Service) Search(ctx context.Context, request *pb.SearchRequest) (*pb.Se
 		Source:    request.Source,
 		Owner:     request.Owner,
 		Query:     request.Query,
-		Condition: request.Condition,
+		Condition: s.indexedConditions(request.Condition),
+		Highlight: s.indexedFields(highlightFrom(ctx)),
+		Fields:    s.indexedFields(fieldsFrom(ctx)),
 	}
 	req.Page = &pb.Pager{}
 	req.Page.Limit = request.PageSize
 	req.Page.Offset = request.PageSize * (request.PageNum - 1)
 	req.Page.Reverse = request.IsDescending
-	req.Page.Sort = request.OrderBy
+	req.Page.Sort = s.fieldMapping.Indexed(request.OrderBy)
 
 	// TODO: Multiple Driver Services One Response support.
 	// assumption len(s.selectOpt) == 1.
@@ -95,7 +105,7 @@ func (s *Service) Search(ctx context.Context, request *pb.SearchRequest) (*pb.Se
 		return nil, errors.Wrap(err, "search error")
 	}
 	for j := range resp.Data {
-		val, err := structpb.NewValue(resp.Data[j])
+		val, err := structpb.NewValue(s.fieldMapping.decodeItem(resp.Data[j]))
 		if err != nil {
 			return nil, errors.Wrap(err, "new value error")
 		}
@@ -108,13 +118,100 @@ func (s *Service) Search(ctx context.Context, request *pb.SearchRequest) (*pb.Se
 	return out, nil
 }
 
+// Aggregate compute terms and stats aggregations over entities matching the request,
+// aggregation fields are mapped onto their indexed names.
+func (s *Service) Aggregate(ctx context.Context, request *pb.SearchRequest, aggs []driver.Aggregation) (*driver.AggregationResult, error) {
+	req := driver.SearchRequest{
+		Source:    request.Source,
+		Owner:     request.Owner,
+		Query:     request.Query,
+		Condition: s.indexedConditions(request.Condition),
+	}
+
+	indexedAggs := make([]driver.Aggregation, 0, len(aggs))
+	for _, agg := range aggs {
+		if agg.Name == "" {
+			agg.Name = agg.Field
+		}
+		agg.Field = s.fieldMapping.Indexed(agg.Field)
+		indexedAggs = append(indexedAggs, agg)
+	}
+
+	engine, ok := s.drivers[s.selectOpt()]
+	if !ok {
+		return nil, errors.New("no specified engine:" + string(s.selectOpt()))
+	}
+	result, err := engine.Aggregate(ctx, req, indexedAggs)
+	return result, errors.Wrap(err, "aggregate error")
+}
+
+func (s *Service) indexedConditions(conditions []*pb.SearchCondition) []*pb.SearchCondition {
+	if len(s.fieldMapping) == 0 {
+		return conditions
+	}
+
+	indexed := make([]*pb.SearchCondition, 0, len(conditions))
+	for _, condition := range conditions {
+		indexed = append(indexed, &pb.SearchCondition{
+			Field:    s.fieldMapping.Indexed(condition.Field),
+			Operator: condition.Operator,
+			Value:    condition.Value,
+		})
+	}
+	return indexed
+}
+
+func (s *Service) indexedFields(fields []string) []string {
+	if len(s.fieldMapping) == 0 {
+		return fields
+	}
+
+	indexed := make([]string, 0, len(fields))
+	for _, field := range fields {
+		indexed = append(indexed, s.fieldMapping.Indexed(field))
+	}
+	return indexed
+}
+
+type highlightKey struct{}
+
+// WithHighlight request highlighted snippets of fields for searches with the context.
+func WithHighlight(ctx context.Context, fields ...string) context.Context {
+	return context.WithValue(ctx, highlightKey{}, fields)
+}
+
+func highlightFrom(ctx context.Context) []string {
+	fields, _ := ctx.Value(highlightKey{}).([]string)
+	return fields
+}
+
+type fieldsKey struct{}
+
+// WithFields project documents returned by searches with the context to the fields.
+func WithFields(ctx context.Context, fields ...string) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+func fieldsFrom(ctx context.Context) []string {
+	fields, _ := ctx.Value(fieldsKey{}).([]string)
+	return fields
+}
+
 func (s *Service) DeleteByID(ctx context.Context, request *pb.DeleteByIDRequest) (*pb.DeleteByIDResponse, error) {
 	out := &pb.DeleteByIDResponse{}
 	engine, ok := s.drivers[s.selectOpt()]
 	if !ok {
 		return out, errors.New("no specified engine:" + string(s.selectOpt()))
 	}
-	if err := engine.Delete(ctx, request.Id); err != nil {
+	remove := func() error { return engine.Delete(ctx, request.Id) }
+	if s.batcher != nil {
+		// buffered document of the entity would bring it back.
+		if err := s.batcher.exclusive(request.Id, remove); err != nil {
+			return out, errors.Wrap(err, "build index error")
+		}
+		return out, nil
+	}
+	if err := remove(); err != nil {
 		return out, errors.Wrap(err, "build index error")
 	}
 
@@ -141,6 +238,9 @@ func (s *Service) Index(ctx context.Context, in *pb.IndexObject) (*pb.IndexRespo
 	if err != nil {
 		return out, errors.Wrap(err, "json marshal error")
 	}
+	if objBytes, err = s.fieldMapping.encodeDoc(objBytes); err != nil {
+		return out, errors.Wrap(err, "json marshal error")
+	}
 	engine, ok := s.drivers[s.selectOpt()]
 	if !ok {
 		return out, errors.New("no specified engine:" + string(s.selectOpt()))
@@ -158,19 +258,125 @@ func (s *Service) IndexBytes(ctx context.Context, id string, jsonData []byte) (o
 	if !ok {
 		return out, errors.New("no specified engine:" + string(s.selectOpt()))
 	}
-	if err = engine.BuildIndex(ctx, id, string(jsonData)); err != nil {
+	if jsonData, err = s.fieldMapping.encodeDoc(jsonData); err != nil {
+		return out, errors.Wrap(err, "build index error")
+	}
+	index := func() error { return engine.BuildIndex(ctx, id, string(jsonData)) }
+	if s.batcher != nil {
+		if !syncIndexFrom(ctx) && s.batcher.add(driver.Document{ID: id, Body: string(jsonData)}) {
+			out.Status = "PENDING"
+			return out, nil
+		}
+		err = s.batcher.exclusive(id, index)
+	} else {
+		err = index()
+	}
+	if err != nil {
 		return out, errors.Wrap(err, "build index error")
 	}
 	out.Status = "SUCCESS"
 	return out, nil
 }
 
+// PendingIndexes returns number of documents buffered for bulk index.
+func (s *Service) PendingIndexes() int {
+	if s.batcher == nil {
+		return 0
+	}
+	return s.batcher.pending()
+}
+
+// Stop index buffered documents and stop batching, later documents of IndexBytes indexed synchronously.
+func (s *Service) Stop(ctx context.Context) error {
+	if s.batcher == nil {
+		return nil
+	}
+	return s.batcher.close(ctx)
+}
+
+// Flush force the search engine to make the latest writes searchable.
+// It is an expensive operation meant for tests and critical read-after-write
+// flows, do not call it in steady-state writes.
+func (s *Service) Flush(ctx context.Context) error {
+	engine, ok := s.drivers[s.selectOpt()]
+	if !ok {
+		return errors.New("no specified engine:" + string(s.selectOpt()))
+	}
+	return errors.Wrap(engine.Flush(ctx), "flush search engine")
+}
+
+// Ping checks the search engine available by a search of one document.
+func (s *Service) Ping(ctx context.Context) error {
+	engine, ok := s.drivers[s.selectOpt()]
+	if !ok {
+		return errors.New("no specified engine:" + string(s.selectOpt()))
+	}
+	_, err := engine.Search(ctx, driver.SearchRequest{Page: &pb.Pager{Limit: 1}})
+	return errors.Wrap(err, "ping search engine")
+}
+
 // Use SelectDriveOption and set the option to this service.
 func (s *Service) Use(opt driver.SelectDriveOption) *Service {
 	s.selectOpt = opt
 	return s
 }
 
+// UseFieldMapping set internal to indexed field mapping of search documents.
+func (s *Service) UseFieldMapping(mapping FieldMapping) *Service {
+	s.fieldMapping = mapping
+	return s
+}
+
+// UseIndexBatch buffer documents of IndexBytes and index them by bulk requests once size
+// documents buffered or interval elapsed, see WithSyncIndex for read-after-write flows.
+func (s *Service) UseIndexBatch(size int, interval time.Duration) *Service {
+	s.batcher = newIndexBatcher(size, interval, s.bulkIndex)
+	return s
+}
+
+func (s *Service) bulkIndex(ctx context.Context, docs []driver.Document) error {
+	engine, ok := s.drivers[s.selectOpt()]
+	if !ok {
+		return errors.New("no specified engine:" + string(s.selectOpt()))
+	}
+	return errors.Wrap(engine.BulkIndex(ctx, docs), "bulk index")
+}
+
+// UseTypeMappings set per type field kinds of search documents.
+func (s *Service) UseTypeMappings(mappings TypeMappings) *Service {
+	s.typeMappings = mappings
+	return s
+}
+
+// EnsureIndexMapping create or update mapping of properties declared for the entity type,
+// ErrFieldKindConflict if another type declared a property of the type of a different kind.
+func (s *Service) EnsureIndexMapping(ctx context.Context, typeName string) error {
+	declared, ok := s.typeMappings[typeName]
+	if !ok {
+		return errors.Wrap(ErrTypeMappingNotFound, typeName)
+	}
+
+	fields := make(map[string]driver.FieldKind, len(declared))
+	for path, kind := range declared {
+		if !driver.FieldKind(kind).Valid() {
+			return errors.Wrapf(ErrFieldKindInvalid, "%s.%s: %s", typeName, path, kind)
+		}
+		for otherType, otherDeclared := range s.typeMappings {
+			if otherKind, ok := otherDeclared[path]; ok && otherKind != kind {
+				return errors.Wrapf(ErrFieldKindConflict, "%s.%s: %s, %s.%s: %s",
+					typeName, path, kind, otherType, path, otherKind)
+			}
+		}
+		fields[s.fieldMapping.Indexed(path)] = driver.FieldKind(kind)
+	}
+
+	engine, ok := s.drivers[s.selectOpt()]
+	if !ok {
+		return errors.New("no specified engine:" + string(s.selectOpt()))
+	}
+	return errors.Wrap(engine.PutMapping(ctx, fields), "ensure index mapping")
+}
+
 // With SelectDriveOption create a copy from original service.
 func (s Service) With(opt driver.SelectDriveOption) *Service {
 	serv := s
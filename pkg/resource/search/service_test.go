@@ -2,8 +2,11 @@ package search
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
+	pb "github.com/tkeel-io/core/api/core/v1"
 	"github.com/tkeel-io/core/pkg/resource/search/driver"
 
 	"github.com/stretchr/testify/assert"
@@ -44,12 +47,128 @@ func TestService_Register(t *testing.T) {
 	assert.Equal(t, engine, d)
 }
 
+func TestService_Flush(t *testing.T) {
+	var fake driver.Type = "fake"
+	service := NewService(nil).Register(fake, fakeEngine{})
+	assert.NotNil(t, service.Flush(context.Background()))
+	assert.Nil(t, service.Use(func() driver.Type { return fake }).Flush(context.Background()))
+}
+
+func TestWithHighlight(t *testing.T) {
+	assert.Nil(t, highlightFrom(context.Background()))
+	ctx := WithHighlight(context.Background(), "basicInfo.name", "basicInfo.desc")
+	assert.Equal(t, []string{"basicInfo.name", "basicInfo.desc"}, highlightFrom(ctx))
+}
+
+func TestFieldMapping(t *testing.T) {
+	mapping := FieldMapping{"basicInfo": "basic_info", "owner": "tenant"}
+	assert.Equal(t, "basic_info.name", mapping.Indexed("basicInfo.name"))
+	assert.Equal(t, "tenant", mapping.Indexed("owner"))
+	assert.Equal(t, "id", mapping.Indexed("id"))
+	assert.Equal(t, "basicInfo.name", mapping.Internal("basic_info.name"))
+	assert.Equal(t, "basicInfo", FieldMapping(nil).Indexed("basicInfo"))
+
+	doc, err := mapping.encodeDoc([]byte(`{"id":"device123","owner":"admin","basicInfo":{"name":"dev"}}`))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"id":"device123","tenant":"admin","basic_info":{"name":"dev"}}`, string(doc))
+	_, err = mapping.encodeDoc([]byte(`[`))
+	assert.NotNil(t, err)
+
+	item := mapping.decodeItem(map[string]interface{}{
+		"id": "device123", "tenant": "admin",
+		driver.FieldHighlight: map[string]interface{}{"basic_info.name": []interface{}{"<em>dev</em>"}},
+	})
+	assert.Equal(t, map[string]interface{}{
+		"id": "device123", "owner": "admin",
+		driver.FieldHighlight: map[string]interface{}{"basicInfo.name": []interface{}{"<em>dev</em>"}},
+	}, item)
+
+	service := NewService(nil).UseFieldMapping(mapping)
+	conditions := service.indexedConditions([]*pb.SearchCondition{{Field: "basicInfo.name", Operator: "$eq"}})
+	assert.Equal(t, "basic_info.name", conditions[0].Field)
+}
+
+func TestService_EnsureIndexMapping(t *testing.T) {
+	var fake driver.Type = "fake"
+	service := NewService(nil).Register(fake, fakeEngine{}).Use(func() driver.Type { return fake })
+	service.UseTypeMappings(TypeMappings{
+		"DEVICE":  {"basicInfo.sn": "keyword", "basicInfo.desc": "text", "metrics.temp": "numeric"},
+		"GATEWAY": {"basicInfo.mac": "ngram"},
+		"Sensor":  {"basicInfo.desc": "text", "metrics.humidity": "numeric"},
+	})
+
+	assert.Nil(t, service.EnsureIndexMapping(context.Background(), "DEVICE"))
+	assert.Nil(t, service.EnsureIndexMapping(context.Background(), "Sensor"))
+	assert.ErrorIs(t, service.EnsureIndexMapping(context.Background(), "GATEWAY"), ErrFieldKindInvalid)
+	assert.ErrorIs(t, service.EnsureIndexMapping(context.Background(), "SENSOR"), ErrTypeMappingNotFound)
+
+	// types share one index, conflicting kinds rejected.
+	service.UseTypeMappings(TypeMappings{
+		"DEVICE": {"metrics.temp": "numeric"},
+		"Sensor": {"metrics.temp": "keyword"},
+	})
+	assert.ErrorIs(t, service.EnsureIndexMapping(context.Background(), "Sensor"), ErrFieldKindConflict)
+}
+
+func TestService_Aggregate(t *testing.T) {
+	var fake driver.Type = "fake"
+	service := NewService(nil).Register(fake, fakeEngine{}).
+		UseFieldMapping(FieldMapping{"basicInfo": "basic_info"})
+
+	_, err := service.Aggregate(context.Background(), &pb.SearchRequest{}, nil)
+	assert.NotNil(t, err)
+
+	service = service.Use(func() driver.Type { return fake })
+	result, err := service.Aggregate(context.Background(), &pb.SearchRequest{}, []driver.Aggregation{
+		{Name: "by_template", Kind: driver.AggregationTerms, Field: "basicInfo.templateId"},
+		{Kind: driver.AggregationStats, Field: "properties.temp"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "basic_info.templateId", result.Aggregations["by_template"].Buckets[0].Key)
+	assert.Equal(t, "properties.temp", result.Aggregations["properties.temp"].Buckets[0].Key)
+}
+
+func TestService_SearchFresh(t *testing.T) {
+	id, version, err := ParseVersionToken(FormatVersionToken("device@123", 5))
+	assert.Nil(t, err)
+	assert.Equal(t, "device@123", id)
+	assert.Equal(t, int64(5), version)
+	_, _, err = ParseVersionToken("device123")
+	assert.ErrorIs(t, err, ErrVersionTokenInvalid)
+
+	var fake driver.Type = "fake"
+	engine := &versionEngine{version: 3}
+	service := NewService(nil).Register(fake, engine).Use(func() driver.Type { return fake })
+
+	_, stale, err := service.SearchFresh(context.Background(), &pb.SearchRequest{}, FormatVersionToken("device123", 3), 0)
+	assert.Nil(t, err)
+	assert.False(t, stale)
+
+	_, stale, err = service.SearchFresh(context.Background(), &pb.SearchRequest{}, FormatVersionToken("device123", 4), 30*time.Millisecond)
+	assert.Nil(t, err)
+	assert.True(t, stale)
+}
+
+// versionEngine index holding a single entity at the version.
+type versionEngine struct {
+	fakeEngine
+	version int64
+}
+
+func (f *versionEngine) Search(ctx context.Context, request driver.SearchRequest) (driver.SearchResponse, error) {
+	return driver.SearchResponse{Data: []map[string]interface{}{{"id": "device123", "version": f.version}}}, nil
+}
+
 type fakeEngine struct{}
 
 func (f fakeEngine) BuildIndex(ctx context.Context, index, content string) error {
 	return nil
 }
 
+func (f fakeEngine) BulkIndex(ctx context.Context, docs []driver.Document) error {
+	return nil
+}
+
 func (f fakeEngine) Search(ctx context.Context, request driver.SearchRequest) (driver.SearchResponse, error) {
 	return driver.SearchResponse{}, nil
 }
@@ -57,3 +176,160 @@ func (f fakeEngine) Search(ctx context.Context, request driver.SearchRequest) (d
 func (f fakeEngine) Delete(ctx context.Context, id string) error {
 	return nil
 }
+
+func (f fakeEngine) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (f fakeEngine) PutMapping(ctx context.Context, fields map[string]driver.FieldKind) error {
+	return nil
+}
+
+func (f fakeEngine) Aggregate(ctx context.Context, request driver.SearchRequest, aggs []driver.Aggregation) (*driver.AggregationResult, error) {
+	// echo indexed fields back as bucket keys.
+	result := &driver.AggregationResult{Aggregations: map[string]*driver.AggregationValue{}}
+	for _, agg := range aggs {
+		result.Aggregations[agg.Name] = &driver.AggregationValue{Buckets: []*driver.Bucket{{Key: agg.Field}}}
+	}
+	return result, nil
+}
+
+// bulkEngine records documents indexed.
+type bulkEngine struct {
+	fakeEngine
+	lock    sync.Mutex
+	bulks   [][]driver.Document
+	indexed []string
+	deleted []string
+	// ids of documents failing bulk requests.
+	failing []string
+}
+
+func (f *bulkEngine) Delete(ctx context.Context, id string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+func (f *bulkEngine) BuildIndex(ctx context.Context, index, content string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.indexed = append(f.indexed, index)
+	return nil
+}
+
+func (f *bulkEngine) BulkIndex(ctx context.Context, docs []driver.Document) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.bulks = append(f.bulks, docs)
+	if len(f.failing) > 0 {
+		return &driver.BulkIndexError{IDs: f.failing, Reason: "mapper_parsing_exception"}
+	}
+	return nil
+}
+
+func (f *bulkEngine) bulkCount() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.bulks)
+}
+
+func TestService_IndexBatch(t *testing.T) {
+	var fake driver.Type = "fake"
+	engine := &bulkEngine{}
+	service := NewService(nil).Register(fake, engine).
+		Use(func() driver.Type { return fake }).
+		UseIndexBatch(2, time.Hour)
+	ctx := context.Background()
+
+	out, err := service.IndexBytes(ctx, "device1", []byte(`{"id":"device1","version":1}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "PENDING", out.Status)
+	_, err = service.IndexBytes(ctx, "device1", []byte(`{"id":"device1","version":2}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, service.PendingIndexes())
+
+	// sync index drops the buffered document of the entity.
+	out, err = service.IndexBytes(WithSyncIndex(ctx), "device1", []byte(`{"id":"device1","version":3}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "SUCCESS", out.Status)
+	assert.Equal(t, []string{"device1"}, engine.indexed)
+	assert.Equal(t, 0, service.PendingIndexes())
+
+	// flushed once size documents buffered.
+	service.IndexBytes(ctx, "device1", []byte(`{"id":"device1","version":4}`))
+	service.IndexBytes(ctx, "device2", []byte(`{"id":"device2","version":1}`))
+	assert.Eventually(t, func() bool { return engine.bulkCount() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []driver.Document{
+		{ID: "device1", Body: `{"id":"device1","version":4}`},
+		{ID: "device2", Body: `{"id":"device2","version":1}`},
+	}, engine.bulks[0])
+
+	// stop flushes buffered documents, later documents indexed synchronously.
+	service.IndexBytes(ctx, "device3", []byte(`{"id":"device3","version":1}`))
+	assert.Nil(t, service.Stop(ctx))
+	assert.Equal(t, 2, engine.bulkCount())
+	assert.Equal(t, "device3", engine.bulks[1][0].ID)
+	out, err = service.IndexBytes(ctx, "device4", []byte(`{"id":"device4","version":1}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "SUCCESS", out.Status)
+	assert.Equal(t, []string{"device1", "device4"}, engine.indexed)
+}
+
+func TestService_IndexBatchDelete(t *testing.T) {
+	var fake driver.Type = "fake"
+	engine := &bulkEngine{}
+	service := NewService(nil).Register(fake, engine).
+		Use(func() driver.Type { return fake }).
+		UseIndexBatch(10, time.Hour)
+	ctx := context.Background()
+
+	// deleted entity not brought back by its buffered document.
+	service.IndexBytes(ctx, "device1", []byte(`{"id":"device1","version":1}`))
+	service.IndexBytes(ctx, "device2", []byte(`{"id":"device2","version":1}`))
+	_, err := service.DeleteByID(ctx, &pb.DeleteByIDRequest{Id: "device1"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"device1"}, engine.deleted)
+	assert.Nil(t, service.Stop(ctx))
+	assert.Equal(t, []driver.Document{{ID: "device2", Body: `{"id":"device2","version":1}`}}, engine.bulks[0])
+}
+
+func TestIndexBatcher_requeue(t *testing.T) {
+	engine := &bulkEngine{failing: []string{"device1"}}
+	batcher := newIndexBatcher(10, time.Hour, engine.BulkIndex)
+	defer batcher.close(context.Background())
+
+	// failed documents re-queued, the others indexed.
+	batcher.add(driver.Document{ID: "device1", Body: `{"version":1}`})
+	batcher.add(driver.Document{ID: "device2", Body: `{"version":1}`})
+	assert.Error(t, batcher.flush(context.Background()))
+	assert.Equal(t, 1, batcher.pending())
+
+	// document replaced meanwhile, attempts reset.
+	batcher.add(driver.Document{ID: "device1", Body: `{"version":2}`})
+	assert.Error(t, batcher.flush(context.Background()))
+	assert.Equal(t, []driver.Document{{ID: "device1", Body: `{"version":2}`}}, batcher.docs)
+
+	// dropped after max attempts.
+	for index := 1; index < maxBulkAttempts; index++ {
+		assert.Error(t, batcher.flush(context.Background()))
+	}
+	assert.Equal(t, 0, batcher.pending())
+
+	// sync writes wait on bulk requests in flight.
+	engine.failing = nil
+	batcher.writing.Lock()
+	done := make(chan struct{})
+	go func() {
+		assert.Nil(t, batcher.exclusive("device1", func() error { return nil }))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("sync write raced bulk request in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+	batcher.writing.Unlock()
+	<-done
+}
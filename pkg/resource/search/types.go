@@ -19,5 +19,9 @@ package search
 import "errors"
 
 var (
-	ErrIndexParamInvalid = errors.New("invalid index params")
+	ErrIndexParamInvalid   = errors.New("invalid index params")
+	ErrTypeMappingNotFound = errors.New("type mapping not found")
+	ErrFieldKindInvalid    = errors.New("invalid field kind")
+	ErrFieldKindConflict   = errors.New("conflicting field kinds")
+	ErrVersionTokenInvalid = errors.New("invalid version token")
 )
@@ -19,8 +19,19 @@ import (
 	"github.com/tkeel-io/kit/log"
 )
 
+const bulkGetParallelism = 10
+
 type daprMetadata struct {
 	StoreName string `mapstructure:"store_name"`
+	// ReplicaStoreName dapr state store component of read replica, optional.
+	ReplicaStoreName string `mapstructure:"replica_store_name"`
+	// Concurrency and Consistency of state calls, defaults of the state store component if empty,
+	// overridden per call by store.WithStateOptions.
+	Concurrency string `mapstructure:"concurrency"`
+	Consistency string `mapstructure:"consistency"`
+	// ShardStoreNames dapr state store components sharing entity state by consistent
+	// hashing of the key, store_name unused if set, shards have no read replica.
+	ShardStoreNames []string `mapstructure:"shard_store_names"`
 }
 
 type daprBulkStore struct {
@@ -29,28 +40,70 @@ type daprBulkStore struct {
 }
 
 func (d *daprBulkStore) Set(ctx context.Context, key string, data []byte) error {
-	return d.bulkTransport.Send(ctx, &client.SetStateItem{Key: key, Value: data})
+	opts := store.StateOptionsFrom(ctx).Merge(d.options)
+	if opts.Etag != "" {
+		// etag checked on the write, not on a later flush.
+		return d.daprStore.Set(ctx, key, data)
+	}
+
+	so, err := stateOptionsOf(opts)
+	if nil != err {
+		return errors.Wrap(err, "dapr store set")
+	}
+	return d.bulkTransport.Send(ctx, &client.SetStateItem{Key: key, Value: data, Options: so})
 }
 
 func (d *daprBulkStore) Flush(ctx context.Context) error {
 	return d.bulkTransport.Flush(ctx)
 }
 
+// Transact flush pending bulk writes, make sure they not overwrite the transaction.
+func (d *daprBulkStore) Transact(ctx context.Context, ops []*store.TxOperation) error {
+	if err := d.Flush(ctx); nil != err {
+		return errors.Wrap(err, "dapr store transact, flush")
+	}
+	return d.daprStore.Transact(ctx, ops)
+}
+
 type daprStore struct {
-	id        string
-	storeName string
+	id               string
+	storeName        string
+	replicaStoreName string
+	// default options of state calls.
+	options store.StateOptions
 }
 
 // Get returns state.
 func (d *daprStore) Get(ctx context.Context, key string) (*store.StateItem, error) {
+	return d.get(ctx, d.storeName, key)
+}
+
+// GetFromReplica returns state from replica store, from the primary if no replica configured.
+func (d *daprStore) GetFromReplica(ctx context.Context, key string) (*store.StateItem, error) {
+	if d.replicaStoreName == "" {
+		return d.get(ctx, d.storeName, key)
+	}
+	return d.get(ctx, d.replicaStoreName, key)
+}
+
+func (d *daprStore) get(ctx context.Context, storeName, key string) (*store.StateItem, error) {
 	var conn dapr.Client
 	if conn = dapr.Get().Select(); nil == conn {
 		log.L().Error("nil connection", logf.Key(key),
-			logf.String("store_name", d.storeName), logf.ID(d.id))
+			logf.String("store_name", storeName), logf.ID(d.id))
 		return nil, errors.Wrap(xerrors.ErrConnectionNil, "dapr send")
 	}
 
-	item, err := conn.GetState(ctx, d.storeName, key)
+	var err error
+	var item *client.StateItem
+	if consistency := store.StateOptionsFrom(ctx).Merge(d.options).Consistency; consistency == "" {
+		item, err = conn.GetState(ctx, storeName, key)
+	} else {
+		var so *client.StateOptions
+		if so, err = stateOptionsOf(store.StateOptions{Consistency: consistency}); nil == err {
+			item, err = conn.GetStateWithConsistency(ctx, storeName, key, nil, so.Consistency)
+		}
+	}
 	if nil != err {
 		return nil, errors.Wrap(err, "dapr store get")
 	}
@@ -67,6 +120,39 @@ func (d *daprStore) Get(ctx context.Context, key string) (*store.StateItem, erro
 	}, nil
 }
 
+// BulkGet returns states of keys.
+func (d *daprStore) BulkGet(ctx context.Context, keys []string) ([]*store.StateItem, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	var conn dapr.Client
+	if conn = dapr.Get().Select(); nil == conn {
+		log.L().Error("nil connection", logf.Count(int64(len(keys))),
+			logf.String("store_name", d.storeName), logf.ID(d.id))
+		return nil, errors.Wrap(xerrors.ErrConnectionNil, "dapr send")
+	}
+
+	bulkItems, err := conn.GetBulkState(ctx, d.storeName, keys, nil, bulkGetParallelism)
+	if nil != err {
+		return nil, errors.Wrap(err, "dapr store bulk get")
+	}
+
+	items := make([]*store.StateItem, 0, len(bulkItems))
+	for _, item := range bulkItems {
+		if item.Error != "" {
+			return nil, errors.Errorf("dapr store bulk get, key: %s, error: %s", item.Key, item.Error)
+		}
+		items = append(items, &store.StateItem{
+			Key:      item.Key,
+			Etag:     item.Etag,
+			Value:    item.Value,
+			Metadata: item.Metadata,
+		})
+	}
+	return items, nil
+}
+
 // Set saves the raw data into store using default state options.
 func (d *daprStore) Set(ctx context.Context, key string, data []byte) error {
 	var conn dapr.Client
@@ -76,7 +162,47 @@ func (d *daprStore) Set(ctx context.Context, key string, data []byte) error {
 			logf.ID(d.id), logf.String("data", string(data)))
 		return errors.Wrap(xerrors.ErrConnectionNil, "dapr send")
 	}
-	return errors.Wrap(conn.SaveState(ctx, d.storeName, key, data), "dapr store set")
+
+	opts := store.StateOptionsFrom(ctx).Merge(d.options)
+	if opts == (store.StateOptions{}) {
+		return errors.Wrap(conn.SaveState(ctx, d.storeName, key, data), "dapr store set")
+	}
+
+	so, err := stateOptionsOf(opts)
+	if nil != err {
+		return errors.Wrap(err, "dapr store set")
+	}
+	return errors.Wrap(conn.SaveBulkState(ctx, d.storeName,
+		&client.SetStateItem{Key: key, Value: data, Etag: etagOf(opts), Options: so}), "dapr store set")
+}
+
+// Transact execute operations with dapr transactional state api.
+func (d *daprStore) Transact(ctx context.Context, ops []*store.TxOperation) error {
+	var conn dapr.Client
+	if conn = dapr.Get().Select(); nil == conn {
+		log.L().Error("nil connection",
+			logf.String("store_name", d.storeName), logf.ID(d.id))
+		return errors.Wrap(xerrors.ErrConnectionNil, "dapr send")
+	}
+
+	so, err := stateOptionsOf(store.StateOptionsFrom(ctx).Merge(d.options))
+	if nil != err {
+		return errors.Wrap(err, "dapr store transact")
+	}
+
+	stateOps := make([]*client.StateOperation, 0, len(ops))
+	for _, op := range ops {
+		typ := client.StateOperationTypeUpsert
+		if op.Type == store.TxDelete {
+			typ = client.StateOperationTypeDelete
+		}
+		stateOps = append(stateOps, &client.StateOperation{
+			Type: typ,
+			Item: &client.SetStateItem{Key: op.Key, Value: op.Value, Options: so},
+		})
+	}
+
+	return errors.Wrap(conn.ExecuteStateTransaction(ctx, d.storeName, nil, stateOps), "dapr store transact")
 }
 
 func (d *daprStore) BatchWrite(ctx context.Context, args *[]interface{}) error {
@@ -122,7 +248,54 @@ func (d *daprStore) Del(ctx context.Context, key string) error {
 			logf.String("store_name", d.storeName), logf.ID(d.id))
 		return errors.Wrap(xerrors.ErrConnectionNil, "dapr send")
 	}
-	return errors.Wrap(conn.DeleteState(ctx, d.storeName, key), "dapr store del")
+
+	opts := store.StateOptionsFrom(ctx).Merge(d.options)
+	if opts == (store.StateOptions{}) {
+		return errors.Wrap(conn.DeleteState(ctx, d.storeName, key), "dapr store del")
+	}
+
+	so, err := stateOptionsOf(opts)
+	if nil != err {
+		return errors.Wrap(err, "dapr store del")
+	}
+	return errors.Wrap(conn.DeleteStateWithETag(ctx, d.storeName, key, etagOf(opts), nil, so), "dapr store del")
+}
+
+// stateOptionsOf returns dapr state options, nil if none specified, unspecified option
+// left undefined to take the default of the state store component.
+func stateOptionsOf(opts store.StateOptions) (*client.StateOptions, error) {
+	if opts.Concurrency == "" && opts.Consistency == "" {
+		return nil, nil
+	}
+
+	so := &client.StateOptions{}
+	switch opts.Concurrency {
+	case "":
+	case store.ConcurrencyLastWrite:
+		so.Concurrency = client.StateConcurrencyLastWrite
+	case store.ConcurrencyFirstWrite:
+		so.Concurrency = client.StateConcurrencyFirstWrite
+	default:
+		return nil, errors.Wrapf(xerrors.ErrInvalidParam, "state concurrency %s", opts.Concurrency)
+	}
+
+	switch opts.Consistency {
+	case "":
+	case store.ConsistencyStrong:
+		so.Consistency = client.StateConsistencyStrong
+	case store.ConsistencyEventual:
+		so.Consistency = client.StateConsistencyEventual
+	default:
+		return nil, errors.Wrapf(xerrors.ErrInvalidParam, "state consistency %s", opts.Consistency)
+	}
+	return so, nil
+}
+
+func etagOf(opts store.StateOptions) *client.ETag {
+	if opts.Etag == "" {
+		return nil
+	}
+	return &client.ETag{Value: opts.Etag}
 }
 
 func init() {
@@ -133,19 +306,42 @@ func init() {
 			return nil, errors.Wrap(err, "decode store.dapr configuration")
 		}
 
-		id := util.UUID("sdapr")
-		log.L().Info("create store.dapr instance", logf.ID(id))
-		s := daprStore{
-			id:        id,
-			storeName: daprMeta.StoreName,
+		options := store.StateOptions{Concurrency: daprMeta.Concurrency, Consistency: daprMeta.Consistency}
+		if _, err := stateOptionsOf(options); nil != err {
+			return nil, errors.Wrap(err, "decode store.dapr configuration")
+		}
+
+		if len(daprMeta.ShardStoreNames) == 0 {
+			return newDaprStore(daprMeta.StoreName, daprMeta.ReplicaStoreName, options)
 		}
-		bulkTransport, err := transport.NewDaprStateTransport(context.Background(), &s)
-		if err != nil {
-			return nil, err
+
+		shards := make([]store.Shard, 0, len(daprMeta.ShardStoreNames))
+		for _, storeName := range daprMeta.ShardStoreNames {
+			s, err := newDaprStore(storeName, "", options)
+			if nil != err {
+				return nil, errors.Wrap(err, "create store.dapr shard")
+			}
+			shards = append(shards, store.Shard{Name: storeName, Store: s})
 		}
-		return &daprBulkStore{
-			daprStore:     s,
-			bulkTransport: bulkTransport,
-		}, nil
+		return store.NewShardedStore(shards)
 	})
 }
+
+func newDaprStore(storeName, replicaStoreName string, options store.StateOptions) (store.Store, error) {
+	id := util.UUID("sdapr")
+	log.L().Info("create store.dapr instance", logf.ID(id), logf.String("store_name", storeName))
+	s := daprStore{
+		id:               id,
+		storeName:        storeName,
+		replicaStoreName: replicaStoreName,
+		options:          options,
+	}
+	bulkTransport, err := transport.NewDaprStateTransport(context.Background(), &s)
+	if err != nil {
+		return nil, err
+	}
+	return &daprBulkStore{
+		daprStore:     s,
+		bulkTransport: bulkTransport,
+	}, nil
+}
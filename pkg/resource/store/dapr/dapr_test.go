@@ -4,7 +4,11 @@ import (
 	"context"
 	"testing"
 
+	"github.com/dapr/go-sdk/client"
+	"github.com/stretchr/testify/assert"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
 	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/resource/store"
 	"github.com/tkeel-io/core/pkg/resource/transport"
 	"github.com/tkeel-io/core/pkg/util"
 	"github.com/tkeel-io/kit/log"
@@ -33,3 +37,30 @@ func TestDaprStateTransport(t *testing.T) {
 	//signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
 	//<-ch
 }
+
+func Test_stateOptionsOf(t *testing.T) {
+	so, err := stateOptionsOf(store.StateOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, so)
+
+	so, err = stateOptionsOf(store.StateOptions{Concurrency: store.ConcurrencyFirstWrite})
+	assert.Nil(t, err)
+	assert.Equal(t, &client.StateOptions{Concurrency: client.StateConcurrencyFirstWrite}, so)
+
+	so, err = stateOptionsOf(store.StateOptions{Consistency: store.ConsistencyEventual})
+	assert.Nil(t, err)
+	assert.Equal(t, &client.StateOptions{Consistency: client.StateConsistencyEventual}, so)
+
+	so, err = stateOptionsOf(store.StateOptions{Concurrency: store.ConcurrencyLastWrite, Consistency: store.ConsistencyStrong})
+	assert.Nil(t, err)
+	assert.Equal(t, &client.StateOptions{Concurrency: client.StateConcurrencyLastWrite, Consistency: client.StateConsistencyStrong}, so)
+
+	_, err = stateOptionsOf(store.StateOptions{Concurrency: "first"})
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+
+	// per call options override store defaults.
+	ctx := store.WithStateOptions(context.Background(), store.StateOptions{Consistency: store.ConsistencyStrong, Etag: "3"})
+	opts := store.StateOptionsFrom(ctx).Merge(store.StateOptions{Concurrency: store.ConcurrencyFirstWrite, Consistency: store.ConsistencyEventual})
+	assert.Equal(t, store.StateOptions{Concurrency: store.ConcurrencyFirstWrite, Consistency: store.ConsistencyStrong, Etag: "3"}, opts)
+	assert.Equal(t, &client.ETag{Value: "3"}, etagOf(opts))
+}
@@ -31,6 +31,25 @@ func (n *memStore) Get(ctx context.Context, key string) (*store.StateItem, error
 	return nil, xerrors.ErrResourceNotFound
 }
 
+// GetFromReplica memory store has no replica, reads the store.
+func (n *memStore) GetFromReplica(ctx context.Context, key string) (*store.StateItem, error) {
+	return n.Get(ctx, key)
+}
+
+func (n *memStore) BulkGet(ctx context.Context, keys []string) ([]*store.StateItem, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	items := make([]*store.StateItem, 0, len(keys))
+	for _, key := range keys {
+		if v, ok := n.store[key]; ok {
+			items = append(items, v)
+			continue
+		}
+		items = append(items, &store.StateItem{Key: key})
+	}
+	return items, nil
+}
+
 // Set saves the raw data into store using default state options.
 func (n *memStore) Set(ctx context.Context, key string, data []byte) error {
 	lock.Lock()
@@ -50,6 +69,26 @@ func (n *memStore) Del(ctx context.Context, key string) error {
 	delete(n.store, key)
 	return nil
 }
+
+func (n *memStore) Transact(ctx context.Context, ops []*store.TxOperation) error {
+	lock.Lock()
+	defer lock.Unlock()
+	for _, op := range ops {
+		switch op.Type {
+		case store.TxDelete:
+			delete(n.store, op.Key)
+		default:
+			n.store[op.Key] = &store.StateItem{
+				Key:      op.Key,
+				Etag:     UUID.String(),
+				Value:    op.Value,
+				Metadata: map[string]string{},
+			}
+		}
+	}
+	return nil
+}
+
 func (n *memStore) Flush(ctx context.Context) error {
 	return nil
 }
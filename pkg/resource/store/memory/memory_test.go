@@ -25,3 +25,15 @@ func Test_MemStore(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Nil(t, ret)
 }
+
+func Test_MemStoreBulkGet(t *testing.T) {
+	ns, err := initStore(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, ns.Set(context.Background(), "entity123", []byte("{}")))
+	items, err := ns.BulkGet(context.Background(), []string{"entity123", "entity234"})
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, []byte("{}"), items[0].Value)
+	assert.Equal(t, "entity234", items[1].Key)
+	assert.Empty(t, items[1].Value)
+}
@@ -19,6 +19,18 @@ func (n *noopStore) Get(ctx context.Context, key string) (*store.StateItem, erro
 	return nil, xerrors.ErrResourceNotFound
 }
 
+func (n *noopStore) GetFromReplica(ctx context.Context, key string) (*store.StateItem, error) {
+	return nil, xerrors.ErrResourceNotFound
+}
+
+func (n *noopStore) BulkGet(ctx context.Context, keys []string) ([]*store.StateItem, error) {
+	items := make([]*store.StateItem, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, &store.StateItem{Key: key})
+	}
+	return items, nil
+}
+
 // Set saves the raw data into store using default state options.
 func (n *noopStore) Set(ctx context.Context, key string, data []byte) error {
 	return nil
@@ -28,6 +40,10 @@ func (n *noopStore) Del(ctx context.Context, key string) error {
 	return nil
 }
 
+func (n *noopStore) Transact(ctx context.Context, ops []*store.TxOperation) error {
+	return nil
+}
+
 func (n *noopStore) Flush(ctx context.Context) error {
 	return nil
 }
@@ -0,0 +1,175 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+)
+
+// shardReplicas virtual nodes of each shard on the hash ring.
+const shardReplicas = 128
+
+// Shard state store serving a part of the key space.
+type Shard struct {
+	// Name identifies the shard on the ring, the dapr state store name for dapr shards.
+	Name  string
+	Store Store
+}
+
+// shardedStore routes state calls to shards by consistent hashing of the whole key,
+// keys are not grouped by entity, so keys of one entity, e.g. its state and its
+// snapshots, as well as states of different entities may live on different shards.
+//
+// Rebalancing: adding or removing a shard moves about 1/N of the keys to another
+// shard, the moved keys read as absent until copied over, core does not migrate
+// state itself, so migrate the moved keys before switching the configuration.
+// Renaming a shard moves its keys as well, shard names must stay stable.
+type shardedStore struct {
+	shards map[string]Store
+	// ring sorted hashes of virtual nodes.
+	ring  []uint32
+	nodes map[uint32]string
+}
+
+// NewShardedStore returns store distributing keys over shards, the store itself if one shard.
+func NewShardedStore(shards []Shard) (Store, error) {
+	if len(shards) == 0 {
+		return nil, errors.Wrap(xerrors.ErrInvalidParam, "sharded store, no shards")
+	} else if len(shards) == 1 {
+		return shards[0].Store, nil
+	}
+
+	s := &shardedStore{
+		shards: make(map[string]Store, len(shards)),
+		nodes:  make(map[uint32]string, len(shards)*shardReplicas),
+	}
+	for _, shard := range shards {
+		if _, has := s.shards[shard.Name]; has || shard.Name == "" {
+			return nil, errors.Wrapf(xerrors.ErrInvalidParam, "sharded store, shard name %q", shard.Name)
+		}
+		s.shards[shard.Name] = shard.Store
+		for index := 0; index < shardReplicas; index++ {
+			hash := hashKey(shard.Name + "#" + strconv.Itoa(index))
+			if _, has := s.nodes[hash]; has {
+				continue
+			}
+			s.nodes[hash] = shard.Name
+			s.ring = append(s.ring, hash)
+		}
+	}
+
+	sort.Slice(s.ring, func(i, j int) bool { return s.ring[i] < s.ring[j] })
+	return s, nil
+}
+
+// hashKey fnv hash finalized with murmur3 mixing, keys differing in the
+// last bytes only, like sequential entity ids, spread over the ring.
+func hashKey(key string) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	h := hash.Sum32()
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// shardOf returns name of the shard owning the key.
+func (s *shardedStore) shardOf(key string) string {
+	hash := hashKey(key)
+	index := sort.Search(len(s.ring), func(i int) bool { return s.ring[i] >= hash })
+	if index == len(s.ring) {
+		index = 0
+	}
+	return s.nodes[s.ring[index]]
+}
+
+func (s *shardedStore) storeOf(key string) Store {
+	return s.shards[s.shardOf(key)]
+}
+
+func (s *shardedStore) Get(ctx context.Context, key string) (*StateItem, error) {
+	return s.storeOf(key).Get(ctx, key)
+}
+
+func (s *shardedStore) GetFromReplica(ctx context.Context, key string) (*StateItem, error) {
+	return s.storeOf(key).GetFromReplica(ctx, key)
+}
+
+// BulkGet groups keys by shard, one bulk call per shard.
+func (s *shardedStore) BulkGet(ctx context.Context, keys []string) ([]*StateItem, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		name := s.shardOf(key)
+		groups[name] = append(groups[name], key)
+	}
+
+	items := make([]*StateItem, 0, len(keys))
+	for name, group := range groups {
+		shardItems, err := s.shards[name].BulkGet(ctx, group)
+		if nil != err {
+			return nil, errors.Wrapf(err, "sharded store bulk get, shard %s", name)
+		}
+		items = append(items, shardItems...)
+	}
+	return items, nil
+}
+
+func (s *shardedStore) Set(ctx context.Context, key string, data []byte) error {
+	return s.storeOf(key).Set(ctx, key, data)
+}
+
+func (s *shardedStore) Del(ctx context.Context, key string) error {
+	return s.storeOf(key).Del(ctx, key)
+}
+
+// Transact executes operations on the shard owning the keys, transactions
+// spanning shards are rejected with ErrCrossShardTransaction since shards do not share transactions.
+func (s *shardedStore) Transact(ctx context.Context, ops []*TxOperation) error {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	name := s.shardOf(ops[0].Key)
+	for _, op := range ops[1:] {
+		if s.shardOf(op.Key) != name {
+			return errors.Wrapf(xerrors.ErrCrossShardTransaction, "sharded store transact, keys %s and %s on different shards", ops[0].Key, op.Key)
+		}
+	}
+	return s.shards[name].Transact(ctx, ops)
+}
+
+func (s *shardedStore) Flush(ctx context.Context) error {
+	for name, shard := range s.shards {
+		if err := shard.Flush(ctx); nil != err {
+			return errors.Wrapf(err, "sharded store flush, shard %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/resource"
+	"github.com/tkeel-io/core/pkg/resource/store"
+	_ "github.com/tkeel-io/core/pkg/resource/store/memory"
+)
+
+func Test_ShardedStore(t *testing.T) {
+	shards := make([]store.Shard, 0, 3)
+	for _, name := range []string{"state-0", "state-1", "state-2"} {
+		ns := store.NewStore(resource.Metadata{Name: "memory"})
+		shards = append(shards, store.Shard{Name: name, Store: ns})
+	}
+	ss, err := store.NewShardedStore(shards)
+	assert.Nil(t, err)
+
+	keys := make([]string, 0, 30)
+	for index := 0; index < 30; index++ {
+		key := fmt.Sprintf("core.entity.device%d", index)
+		keys = append(keys, key)
+		assert.Nil(t, ss.Set(context.Background(), key, []byte(key)))
+	}
+
+	// each key on exactly one shard, keys spread over shards.
+	owners := make(map[string]string)
+	for _, shard := range shards {
+		items, err := shard.Store.BulkGet(context.Background(), keys)
+		assert.Nil(t, err)
+		for _, item := range items {
+			if len(item.Value) > 0 {
+				assert.Empty(t, owners[item.Key])
+				owners[item.Key] = shard.Name
+			}
+		}
+	}
+	assert.Len(t, owners, 30)
+	crossKey := ""
+	used := make(map[string]bool)
+	for _, key := range keys {
+		used[owners[key]] = true
+		if owners[key] != owners[keys[0]] {
+			crossKey = key
+		}
+	}
+	assert.Len(t, used, 3)
+
+	items, err := ss.BulkGet(context.Background(), append(keys, "core.entity.absent"))
+	assert.Nil(t, err)
+	assert.Len(t, items, 31)
+	for _, item := range items {
+		if item.Key != "core.entity.absent" {
+			assert.Equal(t, item.Key, string(item.Value))
+		}
+	}
+
+	ret, err := ss.Get(context.Background(), keys[7])
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(keys[7]), ret.Value)
+	assert.Nil(t, ss.Del(context.Background(), keys[7]))
+	_, err = ss.Get(context.Background(), keys[7])
+	assert.NotNil(t, err)
+
+	// transactions within a shard only.
+	err = ss.Transact(context.Background(), []*store.TxOperation{
+		{Type: store.TxUpsert, Key: keys[0]}, {Type: store.TxUpsert, Key: crossKey}})
+	assert.ErrorIs(t, err, xerrors.ErrCrossShardTransaction)
+
+	_, err = store.NewShardedStore([]store.Shard{{Name: "state-0"}, {Name: "state-0"}})
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+}
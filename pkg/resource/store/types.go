@@ -17,13 +17,79 @@ type StateItem struct {
 	Metadata map[string]string
 }
 
+// StateOptions concurrency and consistency of state calls, empty fields use the store defaults,
+// the defaults of the underlying store if not configured either.
+type StateOptions struct {
+	// Concurrency ConcurrencyFirstWrite or ConcurrencyLastWrite.
+	Concurrency string `mapstructure:"concurrency"`
+	// Consistency ConsistencyEventual or ConsistencyStrong.
+	Consistency string `mapstructure:"consistency"`
+	// Etag expected etag of the state, writes rejected on mismatch under first-write concurrency.
+	Etag string `mapstructure:"-"`
+}
+
+const (
+	ConcurrencyFirstWrite = "first-write"
+	ConcurrencyLastWrite  = "last-write"
+	ConsistencyEventual   = "eventual"
+	ConsistencyStrong     = "strong"
+)
+
+// Merge returns options with empty fields filled from defaults.
+func (o StateOptions) Merge(defaults StateOptions) StateOptions {
+	if o.Concurrency == "" {
+		o.Concurrency = defaults.Concurrency
+	}
+	if o.Consistency == "" {
+		o.Consistency = defaults.Consistency
+	}
+	if o.Etag == "" {
+		o.Etag = defaults.Etag
+	}
+	return o
+}
+
+type stateOptionsKey struct{}
+
+// WithStateOptions override state options of store calls made with the context.
+func WithStateOptions(ctx context.Context, opts StateOptions) context.Context {
+	return context.WithValue(ctx, stateOptionsKey{}, opts)
+}
+
+// StateOptionsFrom returns state options overridden by the context.
+func StateOptionsFrom(ctx context.Context) StateOptions {
+	opts, _ := ctx.Value(stateOptionsKey{}).(StateOptions)
+	return opts
+}
+
+type TxOperationType int
+
+const (
+	TxUpsert TxOperationType = iota + 1
+	TxDelete
+)
+
+// TxOperation state operation executed in a transaction.
+type TxOperation struct {
+	Type  TxOperationType
+	Key   string
+	Value []byte
+}
+
 type Store interface {
 	// GetState retrieves state from specific store using default consistency option.
 	Get(ctx context.Context, key string) (item *StateItem, err error)
+	// BulkGet retrieves states of keys in one round trip, item of absent key has empty value.
+	BulkGet(ctx context.Context, keys []string) ([]*StateItem, error)
+	// GetFromReplica retrieves state from read replica, may lag behind the primary,
+	// stores without replica read from the primary.
+	GetFromReplica(ctx context.Context, key string) (*StateItem, error)
 	// SaveState saves the raw data into store using default state options.
 	Set(ctx context.Context, key string, data []byte) error
 	// Del delete record from store.
 	Del(ctx context.Context, key string) error
+	// Transact execute operations atomically, all keys must live in the same store.
+	Transact(ctx context.Context, ops []*TxOperation) error
 	Flush(ctx context.Context) error
 }
 
@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/scheme"
+	"github.com/tkeel-io/core/pkg/types"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+)
+
+// writeRoles returns roles of the client writing, false for writes not subject to acls,
+// writes of internal callers or derived by the runtime. topic publishers hold no roles.
+func writeRoles(ev v1.Event) ([]string, bool) {
+	if ev.Attr(v1.MetaTopic) != "" {
+		return nil, true
+	}
+
+	roles, ok := ev.Attributes()[v1.MetaRoles]
+	if !ok {
+		return nil, false
+	} else if roles == "" {
+		return []string{}, true
+	}
+	return strings.Split(roles, ","), true
+}
+
+// checkACL returns ErrForbiddenProperty if the roles can not write a property written by the patches,
+// ErrPermissionDenied if the patches change acl of a property without the admin role.
+func checkACL(state Entity, roles []string, patches []Patch) error {
+	configs := make(map[string]interface{})
+	if raw := state.Get(FieldScheme).Raw(); len(raw) > 0 {
+		if err := json.Unmarshal(raw, &configs); nil != err {
+			return errors.Wrap(err, "check property acl")
+		}
+	}
+
+	for _, patch := range patches {
+		for _, propertyID := range writtenPropertyIDs(state, patch) {
+			acl, err := scheme.ParseACLFrom(configs[propertyID])
+			if nil != err {
+				return errors.Wrap(err, "check property acl")
+			} else if !acl.Writable(roles) {
+				return errors.Wrapf(xerrors.ErrForbiddenProperty, "property %s", propertyID)
+			}
+		}
+
+		propertyID, changed := aclChanged(configs, patch)
+		if changed && !(types.Identity{Roles: roles}).HasRole(types.RoleAdmin) {
+			return errors.Wrapf(xerrors.ErrPermissionDenied, "change acl of property %s", propertyID)
+		}
+	}
+	return nil
+}
+
+// writtenPropertyIDs returns ids of properties written by the patch, properties dropped by
+// replacing or removing properties as a whole included.
+func writtenPropertyIDs(state Entity, patch Patch) []string {
+	var ids []string
+	if patch.Path == FieldProperties && patch.Op != xjson.OpMerge {
+		props := make(map[string]interface{})
+		json.Unmarshal(state.Get(FieldProperties).Raw(), &props)
+		for propertyID := range props {
+			ids = append(ids, propertyID)
+		}
+	}
+
+	for _, path := range writtenProperties(patch) {
+		ids = append(ids, strings.SplitN(path, ".", 2)[0])
+	}
+	return ids
+}
+
+// aclChanged reports whether the patch may change acl of a property config, returns the property.
+func aclChanged(configs map[string]interface{}, patch Patch) (string, bool) {
+	if patch.Path != FieldScheme && !strings.HasPrefix(patch.Path, FieldScheme+".") {
+		return "", false
+	}
+
+	var value interface{}
+	if nil != patch.Value {
+		json.Unmarshal(patch.Value.Raw(), &value)
+	}
+	if patch.Path == FieldScheme {
+		after, _ := value.(map[string]interface{})
+		for propertyID, cfg := range after {
+			if id, changed := configACLChanged(configs, propertyID, patch.Op, cfg); changed {
+				return id, true
+			}
+		}
+		if patch.Op == xjson.OpMerge {
+			return "", false
+		}
+
+		// configs replaced or removed as a whole drop configs absent.
+		for propertyID := range configs {
+			if _, has := after[propertyID]; !has {
+				if id, changed := configACLChanged(configs, propertyID, xjson.OpRemove, nil); changed {
+					return id, true
+				}
+			}
+		}
+		return "", false
+	}
+
+	segments := strings.SplitN(strings.TrimPrefix(patch.Path, FieldScheme+"."), ".", 2)
+	if len(segments) == 1 {
+		return configACLChanged(configs, segments[0], patch.Op, value)
+	} else if segments[1] == "acl" || strings.HasPrefix(segments[1], "acl.") {
+		return segments[0], true
+	}
+	return "", false
+}
+
+// configACLChanged reports whether writing cfg as config of the property with op changes its acl.
+func configACLChanged(configs map[string]interface{}, propertyID string, op xjson.PatchOp, cfg interface{}) (string, bool) {
+	before, err := scheme.ParseACLFrom(configs[propertyID])
+	if nil != err {
+		return propertyID, true
+	}
+
+	switch op {
+	case xjson.OpRemove:
+		return propertyID, nil != before
+	case xjson.OpMerge:
+		// configs merged keep acl unless merged with one.
+		if kv, _ := cfg.(map[string]interface{}); nil == kv["acl"] {
+			return propertyID, false
+		}
+	}
+
+	after, err := scheme.ParseACLFrom(cfg)
+	return propertyID, nil != err || !reflect.DeepEqual(before, after)
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tkeel-io/core/pkg/config"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/kit/log"
+	"github.com/tkeel-io/tdtl"
+)
+
+// changeBatch net changes of an entity matching a subscription.
+type changeBatch struct {
+	entityID string
+	sub      *repository.Subscription
+	// changed paths in order of first change.
+	paths  []string
+	values map[string]*tdtl.Collect
+	// values before the first change.
+	previous map[string]*tdtl.Collect
+}
+
+func newChangeBatch(entityID string, sub *repository.Subscription) *changeBatch {
+	return &changeBatch{
+		entityID: entityID,
+		sub:      sub,
+		values:   make(map[string]*tdtl.Collect),
+		previous: make(map[string]*tdtl.Collect),
+	}
+}
+
+// add merge changes of the feed matching subscription paths, reports whether any matched.
+func (b *changeBatch) add(feed *Feed) bool {
+	cc := tdtl.New(feed.State)
+	matched := false
+	for _, change := range feed.Changes {
+		path := change.Path
+		if !pathMatch(b.sub.SourceEntityPaths, path) {
+			continue
+		}
+		if _, has := b.values[path]; !has {
+			b.paths = append(b.paths, path)
+			// previous values of changed properties, consumers build deltas without caching states.
+			if change.Old != nil {
+				b.previous[path] = change.Old
+			}
+		}
+		b.values[path] = cc.Get(path)
+		matched = true
+	}
+	return matched
+}
+
+func (b *changeBatch) encode() []byte {
+	ret := tdtl.New(`{}`)
+	for _, path := range b.paths {
+		ret.Set(path, b.values[path])
+		if old, ok := b.previous[path]; ok {
+			ret.Set(FieldPrevious+"."+path, old)
+		}
+	}
+
+	ret.Set("id", tdtl.NewString(b.entityID))
+	ret.Set("subscribe_id", tdtl.NewString(b.sub.ID))
+	ret.Set("owner", tdtl.NewString(b.sub.Owner))
+	return ret.Raw()
+}
+
+type publishFunc func(ctx context.Context, entityID string, sub *repository.Subscription, state []byte) error
+
+// changeBatcher coalesce change notifications of an entity per subscription within a window,
+// the window starts at the first change, writes are not delayed, only notifications.
+type changeBatcher struct {
+	window  time.Duration
+	publish publishFunc
+
+	lock sync.Mutex
+	// map[subscriptionID/entityID]batch.
+	batches map[string]*changeBatch
+}
+
+func changeBatcherFrom(cfg config.NotifyConfig, publish publishFunc) *changeBatcher {
+	if cfg.BatchWindow <= 0 {
+		return nil
+	}
+	return newChangeBatcher(time.Duration(cfg.BatchWindow)*time.Millisecond, publish)
+}
+
+func newChangeBatcher(window time.Duration, publish publishFunc) *changeBatcher {
+	return &changeBatcher{
+		window:  window,
+		publish: publish,
+		batches: make(map[string]*changeBatch),
+	}
+}
+
+func (cb *changeBatcher) add(feed *Feed, sub *repository.Subscription) {
+	key := sub.ID + "/" + feed.EntityID
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if batch, has := cb.batches[key]; has {
+		batch.add(feed)
+		return
+	}
+
+	batch := newChangeBatch(feed.EntityID, sub)
+	if !batch.add(feed) {
+		return
+	}
+	cb.batches[key] = batch
+	time.AfterFunc(cb.window, func() {
+		cb.flush(key)
+	})
+}
+
+func (cb *changeBatcher) flush(key string) {
+	cb.lock.Lock()
+	batch, has := cb.batches[key]
+	delete(cb.batches, key)
+	cb.lock.Unlock()
+
+	if has {
+		cb.publishBatch(batch)
+	}
+}
+
+// flushAll publish all pending batches.
+func (cb *changeBatcher) flushAll() {
+	cb.lock.Lock()
+	batches := cb.batches
+	cb.batches = make(map[string]*changeBatch)
+	cb.lock.Unlock()
+
+	for _, batch := range batches {
+		cb.publishBatch(batch)
+	}
+}
+
+func (cb *changeBatcher) publishBatch(batch *changeBatch) {
+	log.L().Debug("publish batched changes", logf.Eid(batch.entityID),
+		logf.ID(batch.sub.ID), logf.Any("paths", batch.paths))
+	if err := cb.publish(context.Background(), batch.entityID, batch.sub, batch.encode()); nil != err {
+		log.L().Warn("publish batched changes", logf.Eid(batch.entityID),
+			logf.ID(batch.sub.ID), logf.Error(err))
+	}
+}
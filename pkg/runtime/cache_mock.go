@@ -18,6 +18,8 @@ package runtime
 
 import (
 	"context"
+
+	xerrors "github.com/tkeel-io/core/pkg/errors"
 )
 
 type cacheMock struct {
@@ -33,7 +35,7 @@ func (ec *cacheMock) Load(ctx context.Context, id string) (Entity, error) {
 		return state, nil
 	}
 
-	panic("load cache entity")
+	return nil, xerrors.ErrEntityNotFound
 }
 
 func (ec *cacheMock) Snapshot() error {
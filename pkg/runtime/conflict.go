@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+)
+
+// merge conflict strategies, last-write-wins by default.
+const (
+	MergeLastWriteWins = "last-write-wins"
+	MergeKeepExisting  = "keep-existing"
+)
+
+// ConflictResolver returns value kept of the path when a merge changes an existing value.
+type ConflictResolver func(path string, existing, incoming interface{}) interface{}
+
+// Conflict a key of merge patch changing an existing value.
+type Conflict struct {
+	Path     string      `json:"path"`
+	Existing interface{} `json:"existing"`
+	Incoming interface{} `json:"incoming"`
+	Resolved interface{} `json:"resolved"`
+}
+
+var (
+	resolverLock      sync.RWMutex
+	conflictResolvers = map[string]ConflictResolver{
+		MergeLastWriteWins: func(path string, existing, incoming interface{}) interface{} { return incoming },
+		MergeKeepExisting:  func(path string, existing, incoming interface{}) interface{} { return existing },
+	}
+)
+
+// RegisterConflictResolver register custom resolver, selected by name through event metadata v1.MetaMergeStrategy.
+func RegisterConflictResolver(name string, resolver ConflictResolver) {
+	resolverLock.Lock()
+	defer resolverLock.Unlock()
+	conflictResolvers[name] = resolver
+}
+
+func conflictResolverOf(name string) (ConflictResolver, error) {
+	resolverLock.RLock()
+	defer resolverLock.RUnlock()
+	if resolver, ok := conflictResolvers[name]; ok {
+		return resolver, nil
+	}
+	return nil, errors.Wrapf(xerrors.ErrInvalidParam, "merge strategy %q", name)
+}
+
+// resolveConflicts resolve keys of merge patch changing existing values, nested objects compared key by key,
+// returns the patch resolved and the conflicts ordered by path.
+func resolveConflicts(path string, existing, patch []byte, resolver ConflictResolver) ([]byte, []Conflict, error) {
+	var current, incoming map[string]interface{}
+	if err := json.Unmarshal(existing, &current); nil != err {
+		return nil, nil, errors.Wrap(err, "resolve merge conflicts")
+	} else if err = json.Unmarshal(patch, &incoming); nil != err {
+		return nil, nil, errors.Wrap(err, "resolve merge conflicts")
+	}
+
+	conflicts := walkConflicts(path, current, incoming, resolver)
+	if len(conflicts) == 0 {
+		return patch, nil, nil
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Path < conflicts[j].Path })
+	bytes, err := json.Marshal(incoming)
+	return bytes, conflicts, errors.Wrap(err, "resolve merge conflicts")
+}
+
+func walkConflicts(prefix string, existing, incoming map[string]interface{}, resolver ConflictResolver) []Conflict {
+	var conflicts []Conflict
+	for key, in := range incoming {
+		ex, ok := existing[key]
+		if !ok || ex == nil || reflect.DeepEqual(ex, in) {
+			continue
+		}
+
+		path := strings.Join([]string{prefix, key}, ".")
+		exObj, ok1 := ex.(map[string]interface{})
+		inObj, ok2 := in.(map[string]interface{})
+		if ok1 && ok2 {
+			conflicts = append(conflicts, walkConflicts(path, exObj, inObj, resolver)...)
+			continue
+		}
+
+		resolved := resolver(path, ex, in)
+		if incoming[key] = resolved; reflect.DeepEqual(ex, resolved) {
+			// existing value kept, drop the key so the merge neither changes nor notifies it.
+			delete(incoming, key)
+		}
+		conflicts = append(conflicts, Conflict{Path: path, Existing: ex, Incoming: in, Resolved: resolved})
+	}
+	return conflicts
+}
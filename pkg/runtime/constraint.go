@@ -0,0 +1,278 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/scheme"
+	"github.com/tkeel-io/core/pkg/types"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+	"github.com/tkeel-io/tdtl"
+)
+
+// bornAPIs prefix of events born from writes of the api manager.
+const bornAPIs = "apis."
+
+// clientWrite reports whether the event written by clients, through apis or topics,
+// writes derived by the runtime, e.g. mappers, mirrors or liveness, are not checked.
+func clientWrite(ev v1.Event) bool {
+	return ev.Attr(v1.MetaTopic) != "" || strings.HasPrefix(ev.Attr(v1.MetaBorn), bornAPIs)
+}
+
+// handleSchema reject client writes violating the schema of the entity, undeclared properties
+// or values violating value rules of property configs.
+func (r *Runtime) handleSchema(ctx context.Context, feed *Feed) *Feed {
+	if nil != feed.Err || feed.Event == nil || !clientWrite(feed.Event) {
+		return feed
+	}
+
+	state, has := r.loadState(ctx, feed.EntityID)
+	if !has {
+		return feed
+	}
+
+	namespace := feed.Event.Attr(v1.MetaNamespace)
+	if roles, checked := writeRoles(feed.Event); checked {
+		if err := checkACL(state, roles, feed.Patches); nil != err {
+			log.L().Warn("reject entity write", logf.Eid(feed.EntityID), logf.Error(err))
+			feed.Err = err
+			return feed
+		}
+	}
+
+	if err := checkDeclared(state, feed.Patches); nil != err {
+		log.L().Warn("reject entity write", logf.Eid(feed.EntityID), logf.Error(err))
+		feed.Err = err
+	} else if err = checkConstraints(state, namespace, feed.Patches); nil != err {
+		log.L().Warn("reject entity write", logf.Eid(feed.EntityID), logf.Error(err))
+		feed.Err = err
+	}
+	return feed
+}
+
+// CheckWrite returns the error client patches of the entity state rejected with, for writes
+// committed to the state store bypassing the runtime, e.g. entity transactions.
+// acls checked against roles of the caller identity carried by ctx, if any.
+func CheckWrite(ctx context.Context, id string, state []byte, pds []*v1.PatchData) error {
+	en, err := NewEntity(id, state)
+	if nil != err {
+		return errors.Wrap(err, "check entity write")
+	}
+
+	patches := conv(pds)
+	if identity, ok := types.IdentityFrom(ctx); ok {
+		if err = checkACL(en, identity.Roles, patches); nil != err {
+			return err
+		}
+	}
+
+	if err = checkDeclared(en, patches); nil != err {
+		return err
+	}
+	return checkConstraints(en, "", patches)
+}
+
+// checkConstraints returns ErrConstraintViolation naming the property written with value violating
+// the value rule of property config, leaves of objects merged checked leaf by leaf.
+func checkConstraints(state Entity, namespace string, patches []Patch) error {
+	for _, patch := range patches {
+		if patch.Op != xjson.OpReplace && patch.Op != xjson.OpMerge {
+			continue
+		}
+
+		for _, path := range writtenProperties(patch) {
+			rule, err := scheme.ParseRuleFrom(propertyConfig(state, namespace, path))
+			if nil != err {
+				return errors.Wrap(err, "check property constraints")
+			} else if err = rule.Check(path, leafValue(patch, path)); nil != err {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkDeclared returns ErrUnknownProperty naming the property written but not declared
+// by the schema of the entity not accepting additional properties, removals always accepted.
+func checkDeclared(state Entity, patches []Patch) error {
+	additional := state.Get(FieldScheme + "." + scheme.AdditionalProperties)
+	if additional.Type() != tdtl.Bool || additional.String() != "false" {
+		return nil
+	}
+
+	for _, patch := range patches {
+		if patch.Op == xjson.OpRemove {
+			continue
+		}
+		for _, path := range writtenProperties(patch) {
+			if !declared(state, path) {
+				return errors.Wrapf(xerrors.ErrUnknownProperty, "property %s", path)
+			}
+		}
+	}
+	return nil
+}
+
+// writtenProperties returns property paths written by the patch, objects merged written leaf by leaf,
+// rawData maintained by core excluded.
+func writtenProperties(patch Patch) []string {
+	if patch.Path != FieldProperties && !strings.HasPrefix(patch.Path, FieldProperties+".") {
+		return nil
+	}
+
+	var paths []string
+	var walk func(path string, value *tdtl.Collect)
+	walk = func(path string, value *tdtl.Collect) {
+		if path != "" && (patch.Op != xjson.OpMerge || value == nil || value.Type() != tdtl.Object) {
+			paths = append(paths, path)
+			return
+		} else if value == nil || value.Type() != tdtl.Object {
+			return
+		}
+
+		value.Foreach(func(key []byte, child *tdtl.Collect) {
+			if path == "" {
+				walk(string(key), child)
+			} else {
+				walk(path+"."+string(key), child)
+			}
+		})
+	}
+	walk(strings.TrimPrefix(strings.TrimPrefix(patch.Path, FieldProperties), "."), patch.Value)
+	return paths
+}
+
+// declared reports whether the schema of the entity declares the property path,
+// nested paths resolved via struct fields, paths within properties not of struct declared.
+func declared(state Entity, path string) bool {
+	if FieldProperties+"."+strings.Split(path, ".")[0] == FieldRawData {
+		return true
+	}
+
+	cfg := state.Get(configPath(state, path))
+	return cfg.Type() != tdtl.Undefined && cfg.Type() != tdtl.Null
+}
+
+// configPath returns path of the property config within the entity, nested paths resolved via struct fields.
+func configPath(state Entity, path string) string {
+	segs := strings.Split(path, ".")
+	cfgPath := FieldScheme + "." + segs[0]
+	for _, seg := range segs[1:] {
+		if state.Get(cfgPath+".type").String() != scheme.PropertyTypeStruct {
+			break
+		}
+		cfgPath += ".define." + scheme.DefineFieldStructFields + "." + seg
+	}
+	return cfgPath
+}
+
+// handleDeadband ignore client writes changing property values within the deadband of property configs,
+// neither persisted nor notified.
+func (r *Runtime) handleDeadband(ctx context.Context, feed *Feed) *Feed {
+	if nil != feed.Err || feed.Event == nil || !clientWrite(feed.Event) {
+		return feed
+	}
+
+	state, has := r.loadState(ctx, feed.EntityID)
+	if !has {
+		return feed
+	}
+
+	namespace := feed.Event.Attr(v1.MetaNamespace)
+	if feed.Patches = filterDeadband(state, namespace, feed.Patches); len(feed.Patches) == 0 {
+		log.L().Debug("entity write, all writes within deadband", logf.Eid(feed.EntityID))
+	}
+	return feed
+}
+
+// filterDeadband removes property writes within deadband, leaves of objects merged removed leaf by leaf,
+// properties namespaced by the write configured by property configs of keys without namespace.
+func filterDeadband(state Entity, namespace string, patches []Patch) []Patch {
+	filtered := patches[:0]
+	for _, patch := range patches {
+		if patch.Op != xjson.OpReplace && patch.Op != xjson.OpMerge {
+			filtered = append(filtered, patch)
+			continue
+		}
+
+		written := writtenProperties(patch)
+		var within int
+		for _, path := range written {
+			if !withinDeadband(state, namespace, path, leafValue(patch, path)) {
+				continue
+			}
+			if within++; leafKey(patch, path) != "" {
+				patch.Value.Del(leafKey(patch, path))
+			}
+		}
+		if len(written) == 0 || within < len(written) {
+			filtered = append(filtered, patch)
+		}
+	}
+	return filtered
+}
+
+// withinDeadband returns true if the value written changes the property value within the deadband of property config.
+func withinDeadband(state Entity, namespace, path string, value interface{}) bool {
+	deadband, err := scheme.ParseDeadbandFrom(propertyConfig(state, namespace, path))
+	if nil != err {
+		log.L().Warn("parse property deadband", logf.Key(path), logf.Error(err))
+		return false
+	} else if deadband == nil {
+		return false
+	}
+
+	var current interface{}
+	json.Unmarshal(state.Get(FieldProperties+"."+path).Raw(), &current)
+	return deadband.Within(current, value)
+}
+
+// propertyConfig returns config of the property path decoded, properties namespaced by the write
+// configured by property configs of keys without namespace.
+func propertyConfig(state Entity, namespace, path string) interface{} {
+	if namespace != "" {
+		path = strings.TrimPrefix(path, types.NamespacedKey(namespace, ""))
+	}
+
+	var cfg interface{}
+	if raw := state.Get(configPath(state, path)); raw.Type() != tdtl.Object {
+		return nil
+	} else if err := json.Unmarshal(raw.Raw(), &cfg); nil != err {
+		return nil
+	}
+	return cfg
+}
+
+// leafKey returns key of the property path within value of the patch, empty if the value itself.
+func leafKey(patch Patch, path string) string {
+	base := strings.TrimPrefix(strings.TrimPrefix(patch.Path, FieldProperties), ".")
+	return strings.TrimPrefix(strings.TrimPrefix(path, base), ".")
+}
+
+// leafValue returns value of the property path written by the patch decoded.
+func leafValue(patch Patch, path string) interface{} {
+	var value interface{}
+	json.Unmarshal(patch.Value.Get(leafKey(patch, path)).Raw(), &value)
+	return value
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"time"
+
+	daprSDK "github.com/dapr/go-sdk/client"
+	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/metrics"
+	"github.com/tkeel-io/core/pkg/util/dapr"
+	"github.com/tkeel-io/kit/log"
+)
+
+const (
+	DeadLetterStageDecode = "decode"
+	DeadLetterStageHandle = "handle"
+	// DeadLetterStageDeliver subscription message not delivered after retries.
+	DeadLetterStageDeliver = "deliver"
+	// DeadLetterStageIngress message rejected on ingress, e.g. oversized.
+	DeadLetterStageIngress = "ingress"
+)
+
+// DeadLetter message failed to apply, with the failure reason attached.
+type DeadLetter struct {
+	RuntimeID string `json:"runtime_id"`
+	EventID   string `json:"event_id,omitempty"`
+	EntityID  string `json:"entity_id,omitempty"`
+	// SubscriptionID subscription of undelivered message.
+	SubscriptionID string `json:"subscription_id,omitempty"`
+	Stage          string `json:"stage"`
+	Reason         string `json:"reason"`
+	Timestamp      int64  `json:"timestamp"`
+	// Payload raw message, nil if message decoded.
+	Payload []byte `json:"payload,omitempty"`
+	Event   []byte `json:"event,omitempty"`
+}
+
+type DeadLetterSink interface {
+	Send(ctx context.Context, letter *DeadLetter) error
+}
+
+// NewDeadLetterSink returns sink configured, log sink by default.
+func NewDeadLetterSink(cfg config.DeadLetterConfig) DeadLetterSink {
+	if cfg.Sink == config.DeadLetterSinkPubsub {
+		return &pubsubSink{pubsubName: cfg.PubsubName, topic: cfg.Topic}
+	}
+	return &logSink{}
+}
+
+type logSink struct{}
+
+func (s *logSink) Send(ctx context.Context, letter *DeadLetter) error {
+	log.L().Error("dead letter", logf.RID(letter.RuntimeID), logf.ID(letter.EventID),
+		logf.Eid(letter.EntityID), logf.String("stage", letter.Stage), logf.Reason(letter.Reason),
+		logf.Message(string(letter.Payload)), logf.Value(string(letter.Event)))
+	return nil
+}
+
+type pubsubSink struct {
+	pubsubName string
+	topic      string
+}
+
+func (s *pubsubSink) Send(ctx context.Context, letter *DeadLetter) error {
+	bytes, err := json.Marshal(letter)
+	if nil != err {
+		return errors.Wrap(err, "encode dead letter")
+	}
+
+	conn := dapr.Get().Select()
+	if nil == conn {
+		return errors.Wrap(xerrors.ErrConnectionNil, "publish dead letter")
+	}
+
+	ctOpts := daprSDK.PublishEventWithContentType("application/json")
+	err = conn.PublishEvent(ctx, s.pubsubName, s.topic, bytes, ctOpts)
+	return errors.Wrap(err, "publish dead letter")
+}
+
+// deadLetter forward failed message to dead letter sink, fallback to log sink.
+func (r *Runtime) deadLetter(ctx context.Context, letter *DeadLetter) {
+	letter.RuntimeID = r.id
+	letter.Timestamp = time.Now().UnixNano() / 1e6
+	metrics.CollectorDeadLetterCount.WithLabelValues(letter.Stage).Inc()
+
+	sink := r.deadLetterSink
+	if nil == sink {
+		sink = &logSink{}
+	}
+
+	if err := sink.Send(ctx, letter); nil != err {
+		log.L().Error("send dead letter", logf.RID(r.id), logf.ID(letter.EventID), logf.Error(err))
+		_ = (&logSink{}).Send(ctx, letter)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/metrics"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/kit/log"
+	"go.uber.org/atomic"
+)
+
+const (
+	// deliveryMaxAttempts attempts of at-least-once delivery before dead-lettered.
+	deliveryMaxAttempts = 4
+	// deliveryBackoff wait before the first retry, doubled on each retry.
+	deliveryBackoff = 100 * time.Millisecond
+	// deliveryMaxRetries retries pending at most, further failed messages dead-lettered at once.
+	deliveryMaxRetries = 1024
+)
+
+// deliveryRetries retries of at-least-once messages pending, scheduled off the event loop
+// so a failing subscriber never stalls entities of the partition.
+type deliveryRetries struct {
+	pending atomic.Int64
+	wg      sync.WaitGroup
+}
+
+// wait retries pending done or ctx done.
+func (d *deliveryRetries) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "wait delivery retries, %d pending", d.pending.Load())
+	}
+}
+
+// deliver publish subscription message honoring delivery mode of the subscription,
+// at-most-once published once, at-least-once retried with backoff in the background and
+// dead-lettered on exhaustion, nil returned once the retry scheduled.
+func (r *Runtime) deliver(ctx context.Context, entityID string, sub *repository.Subscription, state []byte) error {
+	err := r.publisher()(ctx, entityID, sub, state)
+	if nil == err || sub.Delivery != repository.DeliveryAtLeastOnce {
+		return err
+	}
+	return r.retryDelivery(ctx, entityID, sub, state, 1, err)
+}
+
+func (r *Runtime) publisher() publishFunc {
+	if r.publish == nil {
+		return publishSubData
+	}
+	return r.publish
+}
+
+// retryDelivery schedule the attempt of the message failed by err.
+func (r *Runtime) retryDelivery(ctx context.Context, entityID string, sub *repository.Subscription, state []byte, attempt int, err error) error {
+	if attempt >= deliveryMaxAttempts || r.retries.pending.Load() >= deliveryMaxRetries {
+		r.deadLetter(ctx, &DeadLetter{
+			EntityID:       entityID,
+			SubscriptionID: sub.ID,
+			Stage:          DeadLetterStageDeliver,
+			Reason:         err.Error(),
+			Payload:        state,
+		})
+		return errors.Wrap(err, "deliver subscription message")
+	}
+
+	log.L().Warn("deliver subscription message, retry", logf.ID(sub.ID),
+		logf.Eid(entityID), logf.Count(int64(attempt)), logf.Error(err))
+	metrics.CollectorSubscriptionRetryCount.WithLabelValues(sub.Owner).Inc()
+
+	r.retries.pending.Inc()
+	r.retries.wg.Add(1)
+	time.AfterFunc(deliveryBackoff<<(attempt-1), func() {
+		defer r.retries.wg.Done()
+		defer r.retries.pending.Dec()
+		if err := r.publisher()(ctx, entityID, sub, state); nil != err {
+			if err = r.retryDelivery(ctx, entityID, sub, state, attempt+1, err); nil != err {
+				log.L().Error("deliver subscription message", logf.ID(sub.ID), logf.Eid(entityID), logf.Error(err))
+			}
+		}
+	})
+	return nil
+}
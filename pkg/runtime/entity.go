@@ -32,6 +32,7 @@ const (
 	FieldProperties  string = "properties"
 	FieldRawData     string = "properties.rawData"
 	FieldKeyWords    string = "search_model"
+	FieldDeletedAt   string = "deleted_at"
 	// FieldEntitySource string = "entity_source".
 
 )
@@ -66,6 +67,21 @@ func NewEntity(id string, state []byte) (Entity, error) {
 		errors.Wrap(s.Error(), "new entity")
 }
 
+// PatchState returns the entity state after applying the patches as runtime does.
+func PatchState(id string, state []byte, pds []*v1.PatchData) ([]byte, error) {
+	en, err := NewEntity(id, state)
+	if nil != err {
+		return nil, err
+	}
+
+	feed := en.Handle(context.Background(), &Feed{
+		Event:    &v1.ProtoEvent{},
+		EntityID: id,
+		Patches:  conv(pds),
+	})
+	return en.Raw(), errors.Wrap(feed.Err, "patch entity state")
+}
+
 func (e *entity) ID() string {
 	return e.id
 }
@@ -89,6 +105,18 @@ func (e *entity) Handle(ctx context.Context, feed *Feed) *Feed { //nolint
 		return feed
 	}
 
+	// optimistic concurrency, reject patches on version conflict.
+	if version := feed.Event.Attr(v1.MetaVersion); version != "" {
+		if version != strconv.FormatInt(e.Version(), 10) {
+			log.L().Warn("update entity, version conflict", logf.Eid(e.id),
+				logf.Any("expected", version), logf.Version(e.Version()))
+			feed.Err = xerrors.ErrEntityConflict
+			feed.Patches = []Patch{}
+			feed.State = e.Raw()
+			return feed
+		}
+	}
+
 	changes := []Patch{}
 	pc := feed.Event.Attr(v1.MetaPathConstructor)
 
@@ -105,7 +133,7 @@ func (e *entity) Handle(ctx context.Context, feed *Feed) *Feed { //nolint
 		case xjson.OpMerge:
 			var err error
 			if patch.Value.Type() != tdtl.Null {
-				err = merge(cc, patch, e, feed)
+				err = merge(cc, &patch, e, feed)
 				if err != nil {
 					return feed
 				}
@@ -147,18 +175,22 @@ func (e *entity) Handle(ctx context.Context, feed *Feed) *Feed { //nolint
 		switch patch.Op {
 		case xjson.OpMerge:
 			patch.Value.Foreach(func(key []byte, value *tdtl.Collect) {
+				path := strings.Join([]string{patch.Path, string(key)}, ".")
 				changes = append(changes, Patch{
 					Op: xjson.OpReplace, Value: value,
-					Path: strings.Join([]string{patch.Path, string(key)}, "."),
+					Path: path, Old: e.oldValue(path),
 				})
 			})
 		default:
 			changes = append(changes,
-				Patch{Op: patch.Op, Path: patch.Path, Value: patch.Value})
+				Patch{Op: patch.Op, Path: patch.Path, Value: patch.Value, Old: e.oldValue(patch.Path)})
 		}
 	}
 
 	if cc.Error() == nil {
+		if writer := feed.Event.Attr(v1.MetaWriter); writer != "" {
+			recordWriters(cc, writer, changes)
+		}
 		e.state = *cc
 		version := e.Version()
 		if version%100 == 99 {
@@ -181,7 +213,15 @@ func (e *entity) Handle(ctx context.Context, feed *Feed) *Feed { //nolint
 	return feed
 }
 
-func merge(cc *tdtl.JSONNode, patch Patch, e Entity, feed *Feed) error {
+// oldValue returns value of the path before patches applied, nil if absent.
+func (e *entity) oldValue(path string) *tdtl.Collect {
+	if val := e.state.Get(path); val.Type() != tdtl.Null && val.Type() != tdtl.Undefined {
+		return val
+	}
+	return nil
+}
+
+func merge(cc *tdtl.JSONNode, patch *Patch, e Entity, feed *Feed) error {
 	tc := cc.Get(patch.Path)
 	if tc.Type() == tdtl.Null {
 		cc.Set(patch.Path, patch.Value)
@@ -193,6 +233,28 @@ func merge(cc *tdtl.JSONNode, patch Patch, e Entity, feed *Feed) error {
 		feed.State = e.Raw()
 		return feed.Err
 	}
+	// resolve conflicts by the merge strategy requested, resolved patch value notified as changes.
+	var strategy string
+	if feed.Event != nil {
+		strategy = feed.Event.Attr(v1.MetaMergeStrategy)
+	}
+	if strategy != "" && tc.Type() == tdtl.Object {
+		resolver, err := conflictResolverOf(strategy)
+		var value []byte
+		var conflicts []Conflict
+		if nil == err {
+			value, conflicts, err = resolveConflicts(patch.Path, tc.Raw(), patch.Value.Raw(), resolver)
+		}
+		if nil != err {
+			feed.Err = err
+			feed.Patches = []Patch{}
+			feed.State = e.Raw()
+			return feed.Err
+		}
+		patch.Value = tdtl.New(value)
+		feed.Conflicts = append(feed.Conflicts, conflicts...)
+	}
+
 	ntc, err := jsonpatch.MergePatch(tc.Raw(), patch.Value.Raw())
 	if err != nil {
 		feed.Err = errors.New("datatype is not object")
@@ -22,6 +22,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/repository"
 	xjson "github.com/tkeel-io/core/pkg/util/json"
 	"github.com/tkeel-io/tdtl"
 )
@@ -130,6 +132,153 @@ func TestEntity_HandleScheme(t *testing.T) {
 	}
 }
 
+func TestEntity_HandleVersionConflict(t *testing.T) {
+	en, err := NewEntity("en-123", []byte(`{"version": 3, "properties": {"temp": 20}}`))
+	assert.Nil(t, err)
+
+	patches := []Patch{{Path: "properties.temp", Value: tdtl.New("50"), Op: xjson.OpReplace}}
+	got := en.Handle(context.Background(), &Feed{
+		Event:   &v1.ProtoEvent{Metadata: map[string]string{v1.MetaVersion: "2"}},
+		Patches: patches,
+	})
+	assert.ErrorIs(t, got.Err, xerrors.ErrEntityConflict)
+	assert.Equal(t, "20", tdtl.New(got.State).Get("properties.temp").String())
+
+	got = en.Handle(context.Background(), &Feed{
+		Event:   &v1.ProtoEvent{Metadata: map[string]string{v1.MetaVersion: "3"}},
+		Patches: patches,
+	})
+	assert.Nil(t, got.Err)
+	assert.Equal(t, "50", tdtl.New(got.State).Get("properties.temp").String())
+}
+
+func TestEntity_HandleOldValue(t *testing.T) {
+	en, err := NewEntity("en-123", []byte(`{"properties": {"temp": 20, "metrics": {"cpu": 0.5}}}`))
+	assert.Nil(t, err)
+
+	got := en.Handle(context.Background(), &Feed{
+		Event: &v1.ProtoEvent{},
+		Patches: []Patch{
+			{Path: "properties.temp", Value: tdtl.New("50"), Op: xjson.OpReplace},
+			{Path: "properties.metrics", Value: tdtl.New(`{"cpu": 0.7, "mem": 0.3}`), Op: xjson.OpMerge},
+		},
+	})
+	assert.Nil(t, got.Err)
+
+	olds := make(map[string]string)
+	for _, change := range got.Changes {
+		if change.Old != nil {
+			olds[change.Path] = change.Old.String()
+		}
+	}
+	assert.Equal(t, map[string]string{"properties.temp": "20", "properties.metrics.cpu": "0.5"}, olds)
+}
+
+func TestEntity_HandleWriters(t *testing.T) {
+	en, err := NewEntity("en-123", []byte(`{"properties": {"temp": 20}}`))
+	assert.Nil(t, err)
+
+	// untracked writes.
+	got := en.Handle(context.Background(), &Feed{
+		Event:   &v1.ProtoEvent{},
+		Patches: []Patch{{Path: "properties.temp", Value: tdtl.New("30"), Op: xjson.OpReplace}},
+	})
+	assert.Nil(t, got.Err)
+	assert.Equal(t, tdtl.Null, tdtl.New(got.State).Get(FieldWriters).Type())
+
+	got = en.Handle(context.Background(), &Feed{
+		Event: &v1.ProtoEvent{Metadata: map[string]string{v1.MetaWriter: "user:admin"}},
+		Patches: []Patch{
+			{Path: "properties.temp", Value: tdtl.New("50"), Op: xjson.OpReplace},
+			{Path: "properties.metrics", Value: tdtl.New(`{"cpu": 0.7}`), Op: xjson.OpMerge},
+		},
+	})
+	assert.Nil(t, got.Err)
+	state := tdtl.New(got.State)
+	assert.Equal(t, "user:admin", state.Get("writers.properties.temp._writer").String())
+	assert.Equal(t, "user:admin", state.Get("writers.properties.metrics.cpu._writer").String())
+	assert.Equal(t, tdtl.Number, state.Get("writers.properties.temp._ts").Type())
+
+	got = en.Handle(context.Background(), &Feed{
+		Event:   &v1.ProtoEvent{Metadata: map[string]string{v1.MetaWriter: "mapper:cpu-mapper"}},
+		Patches: []Patch{{Path: "properties.metrics.cpu", Value: tdtl.New("0.9"), Op: xjson.OpReplace}},
+	})
+	assert.Nil(t, got.Err)
+	state = tdtl.New(got.State)
+	assert.Equal(t, "user:admin", state.Get("writers.properties.temp._writer").String())
+	assert.Equal(t, "mapper:cpu-mapper", state.Get("writers.properties.metrics.cpu._writer").String())
+}
+
+func TestEntity_HandleMergeConflicts(t *testing.T) {
+	RegisterConflictResolver("max", func(path string, existing, incoming interface{}) interface{} {
+		if existing.(float64) > incoming.(float64) {
+			return existing
+		}
+		return incoming
+	})
+
+	tests := []struct {
+		strategy string
+		cpu      string
+		mem      string
+	}{
+		{MergeLastWriteWins, "0.7", "0.1"},
+		{MergeKeepExisting, "0.5", "0.3"},
+		{"max", "0.7", "0.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			en, err := NewEntity("en-123", []byte(`{"properties": {"metrics": {"cpu": 0.5, "mem": 0.3}}}`))
+			assert.Nil(t, err)
+
+			got := en.Handle(context.Background(), &Feed{
+				Event: &v1.ProtoEvent{Metadata: map[string]string{v1.MetaMergeStrategy: tt.strategy}},
+				Patches: []Patch{{Path: "properties.metrics",
+					Value: tdtl.New(`{"cpu": 0.7, "mem": 0.1, "disk": 0.2}`), Op: xjson.OpMerge}},
+			})
+			assert.Nil(t, got.Err)
+			state := tdtl.New(got.State)
+			assert.Equal(t, tt.cpu, state.Get("properties.metrics.cpu").String())
+			assert.Equal(t, tt.mem, state.Get("properties.metrics.mem").String())
+			assert.Equal(t, "0.2", state.Get("properties.metrics.disk").String())
+			assert.Len(t, got.Conflicts, 2)
+			assert.Equal(t, "properties.metrics.cpu", got.Conflicts[0].Path)
+		})
+	}
+
+	en, err := NewEntity("en-123", []byte(`{"properties": {"metrics": {"cpu": 0.5}}}`))
+	assert.Nil(t, err)
+	got := en.Handle(context.Background(), &Feed{
+		Event:   &v1.ProtoEvent{Metadata: map[string]string{v1.MetaMergeStrategy: "unknown"}},
+		Patches: []Patch{{Path: "properties.metrics", Value: tdtl.New(`{"cpu": 0.7}`), Op: xjson.OpMerge}},
+	})
+	assert.ErrorIs(t, got.Err, xerrors.ErrInvalidParam)
+}
+
+func TestPatchState(t *testing.T) {
+	state := []byte(`{"properties": {"temp": 20, "metrics": {"cpu": 0.5, "mem": 0.3}}}`)
+	after, err := PatchState("en-123", state, []*v1.PatchData{
+		{Path: "properties.temp", Operator: xjson.OpReplace.String(), Value: []byte("50")},
+		{Path: "properties.metrics.mem", Operator: xjson.OpRemove.String()},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "50", tdtl.New(after).Get("properties.temp").String())
+	assert.NotContains(t, string(after), "mem")
+
+	_, err = PatchState("en-123", state, []*v1.PatchData{
+		{Path: "properties.temp", Operator: xjson.OpReplace.String(), Value: []byte("50")},
+		{Path: "properties.temp", Operator: "unknown"},
+	})
+	assert.NotNil(t, err)
+}
+
+func Test_mapperWriter(t *testing.T) {
+	assert.Equal(t, "mapper:avg,expr-2", mapperWriter([]ExpressionInfo{
+		{Expression: repository.Expression{ID: "expr-2"}},
+		{Expression: repository.Expression{ID: "expr-1", Name: "avg"}},
+	}))
+}
+
 func TestMerge(t *testing.T) {
 	cc := tdtl.New("{}")
 	cc.Merge(tdtl.New([]byte(`{"sss":{"id":"sss","type":"struct","name":"","weight":0,"enabled":true,"enabled_search":true,"enabled_time_series":false,"description":"","define":{"fields":{"aaa":{"id":"aaa","type":"struct","name":"","weight":0,"enabled":true,"enabled_search":true,"enabled_time_series":false,"description":"","define":{"fields":{}},"last_time":0}}},"last_time":0}}`)))
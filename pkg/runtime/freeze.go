@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"sort"
+
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/mapper"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/tdtl"
+)
+
+// frozen semantics of mapper evaluation, an entity is frozen while FieldFrozen is true:
+//   - mappers of a frozen entity stop firing, the mapped properties keep their last values.
+//   - changes of a frozen entity are not propagated to mappers reading from it.
+//   - mappers reading from a frozen entity still fire on changes of their other inputs and
+//     read the last value of the frozen entity, the derived value is flagged by listing the
+//     frozen entities under FieldFrozenSources.<mapper path>, the flag cleared once none of
+//     the sources frozen.
+const (
+	FieldFrozen        string = "frozen"
+	FieldFrozenSources string = "frozen_sources"
+)
+
+func isFrozen(en Entity) bool {
+	frozen := en.Get(FieldFrozen)
+	return frozen.Type() == tdtl.Bool && frozen.String() == "true"
+}
+
+// loadState returns entity state from runtime entities, or from cache.
+func (r *Runtime) loadState(ctx context.Context, id string) (Entity, bool) {
+	if state, has := r.entities[id]; has {
+		return state, true
+	}
+	if state, err := r.enCache.Load(ctx, id); nil == err {
+		return state, true
+	}
+	return nil, false
+}
+
+func (r *Runtime) entityFrozen(ctx context.Context, id string) bool {
+	state, has := r.loadState(ctx, id)
+	return has && isFrozen(state)
+}
+
+// frozenSources returns sorted frozen entities the expression reads from.
+func (r *Runtime) frozenSources(ctx context.Context, expr ExpressionInfo) []string {
+	sources := make(map[string]struct{})
+	for _, item := range expr.evalEndpoints {
+		entityID := mapper.NewWatchKey(item.path).EntityID
+		if _, has := sources[entityID]; has || entityID == expr.EntityID {
+			continue
+		}
+		if r.entityFrozen(ctx, entityID) {
+			sources[entityID] = struct{}{}
+		}
+	}
+
+	frozen := make([]string, 0, len(sources))
+	for entityID := range sources {
+		frozen = append(frozen, entityID)
+	}
+	sort.Strings(frozen)
+	return frozen
+}
+
+// frozenSourcesPatch flag or clear the derived value of the expression, returns nil if unchanged.
+func (r *Runtime) frozenSourcesPatch(ctx context.Context, expr ExpressionInfo) *v1.PatchData {
+	path := FieldFrozenSources + "." + expr.Path
+	sources := r.frozenSources(ctx, expr)
+	if len(sources) == 0 {
+		state, has := r.loadState(ctx, expr.EntityID)
+		if !has || state.Get(path).Type() == tdtl.Null || state.Get(path).Type() == tdtl.Undefined {
+			return nil
+		}
+		return &v1.PatchData{Operator: xjson.OpRemove.String(), Path: path}
+	}
+
+	bytes, _ := json.Marshal(sources)
+	return &v1.PatchData{Operator: xjson.OpReplace.String(), Path: path, Value: bytes}
+}
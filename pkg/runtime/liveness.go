@@ -0,0 +1,322 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	daprSDK "github.com/dapr/go-sdk/client"
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/placement"
+	"github.com/tkeel-io/core/pkg/util"
+	"github.com/tkeel-io/core/pkg/util/dapr"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	EventEntityOffline = "OnEntityOffline"
+	EventEntityOnline  = "OnEntityOnline"
+
+	// PropertyOnline property flipped on liveness transitions, indexed.
+	PropertyOnline = "online"
+
+	livenessQueueSize = 1024
+	livenessPageSize  = 100
+)
+
+// Liveness activity of an entity, tracked from entity messages ingested from topics.
+type Liveness struct {
+	EntityID string `json:"entity_id"`
+	Online   bool   `json:"online"`
+	// LastSeen timestamp(ms) of the last entity message ingested.
+	LastSeen int64 `json:"last_seen"`
+}
+
+// LivenessEvent published when an entity goes offline or comes back online.
+type LivenessEvent struct {
+	Event      string `json:"event"`
+	EntityID   string `json:"entity_id"`
+	EntityType string `json:"entity_type,omitempty"`
+	LastSeen   int64  `json:"last_seen"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+type livenessTracker struct {
+	interval   time.Duration
+	threshold  time.Duration
+	thresholds map[string]time.Duration
+	publish    func(ctx context.Context, ev *LivenessEvent) error
+	// entities came back online, written by the detector off the event loop.
+	onlines chan string
+}
+
+// livenessTrackerFrom returns tracker configured, nil if liveness detection disabled.
+func livenessTrackerFrom(cfg config.LivenessConfig) *livenessTracker {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	tracker := &livenessTracker{
+		interval:   time.Duration(cfg.Interval) * time.Second,
+		threshold:  time.Duration(cfg.Threshold) * time.Second,
+		thresholds: make(map[string]time.Duration, len(cfg.TypeThresholds)),
+		publish:    logLiveness,
+		onlines:    make(chan string, livenessQueueSize),
+	}
+	if tracker.interval <= 0 {
+		tracker.interval = config.DefaultLivenessInterval * time.Second
+	}
+	if tracker.threshold <= 0 {
+		tracker.threshold = config.DefaultLivenessThreshold * time.Second
+	}
+	for entityType, threshold := range cfg.TypeThresholds {
+		if threshold > 0 {
+			tracker.thresholds[entityType] = time.Duration(threshold) * time.Second
+		}
+	}
+	if cfg.Topic != "" {
+		tracker.publish = func(ctx context.Context, ev *LivenessEvent) error {
+			return publishLiveness(ctx, cfg.PubsubName, cfg.Topic, ev)
+		}
+	}
+	return tracker
+}
+
+func (t *livenessTracker) thresholdOf(entityType string) time.Duration {
+	if threshold, ok := t.thresholds[entityType]; ok {
+		return threshold
+	}
+	return t.threshold
+}
+
+func logLiveness(ctx context.Context, ev *LivenessEvent) error {
+	log.L().Info("entity liveness", logf.Eid(ev.EntityID),
+		logf.Type(ev.EntityType), logf.String("event", ev.Event))
+	return nil
+}
+
+func publishLiveness(ctx context.Context, pubsubName, topic string, ev *LivenessEvent) error {
+	bytes, err := json.Marshal(ev)
+	if nil != err {
+		return errors.Wrap(err, "encode liveness event")
+	}
+
+	conn := dapr.Get().Select()
+	if nil == conn {
+		return errors.Wrap(xerrors.ErrConnectionNil, "publish liveness event")
+	}
+
+	ctOpts := daprSDK.PublishEventWithContentType("application/json")
+	err = conn.PublishEvent(ctx, pubsubName, topic, bytes, ctOpts)
+	return errors.Wrap(err, "publish liveness event")
+}
+
+// runLivenessDetector detect offline entities every interval and write entities came back online
+// until runtime stopped, so liveness written in order and never blocks the event loop.
+func (r *Runtime) runLivenessDetector() {
+	ticker := time.NewTicker(r.liveness.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case entityID := <-r.liveness.onlines:
+			r.setLiveness(r.ctx, entityID, r.entityType(entityID), true, r.lastSeen(entityID))
+		case <-ticker.C:
+			r.detectOffline(r.ctx, time.Now())
+		}
+	}
+}
+
+// queueOnline queue the entity came back online, marked offline again if the queue full
+// so that the next message retries.
+func (r *Runtime) queueOnline(entityID string) {
+	select {
+	case r.liveness.onlines <- entityID:
+	default:
+		log.L().Warn("queue entity online, queue full", logf.Eid(entityID))
+		r.slock.Lock()
+		r.entityStats(entityID).Offline = true
+		r.slock.Unlock()
+	}
+}
+
+func (r *Runtime) lastSeen(entityID string) int64 {
+	r.slock.RLock()
+	defer r.slock.RUnlock()
+	if stats, ok := r.stats[entityID]; ok {
+		return stats.LastSeen
+	}
+	return 0
+}
+
+// seedLiveness track liveness persisted of the entity not seen since started, entities online
+// are seen as of now, so they go offline if silent longer than threshold after a restart.
+func (r *Runtime) seedLiveness(entityID string, online bool, now time.Time) {
+	r.slock.Lock()
+	defer r.slock.Unlock()
+	if stats := r.entityStats(entityID); stats.LastSeen == 0 && !stats.Offline {
+		if online {
+			stats.LastSeen = now.UnixNano() / 1e6
+		} else {
+			stats.Offline = true
+		}
+	}
+}
+
+// detectOffline mark entities silent longer than threshold of their type offline.
+func (r *Runtime) detectOffline(ctx context.Context, now time.Time) {
+	r.slock.RLock()
+	lastSeens := make(map[string]int64)
+	for entityID, stats := range r.stats {
+		if !stats.Offline && stats.LastSeen > 0 {
+			lastSeens[entityID] = stats.LastSeen
+		}
+	}
+	r.slock.RUnlock()
+
+	for entityID, lastSeen := range lastSeens {
+		entityType := r.entityType(entityID)
+		if now.Sub(time.Unix(0, lastSeen*1e6)) < r.liveness.thresholdOf(entityType) {
+			continue
+		}
+
+		// skip entity seen again meanwhile.
+		r.slock.Lock()
+		stats, ok := r.stats[entityID]
+		offline := ok && !stats.Offline && stats.LastSeen == lastSeen
+		if offline {
+			stats.Offline = true
+		}
+		r.slock.Unlock()
+
+		if offline {
+			r.setLiveness(ctx, entityID, entityType, false, lastSeen)
+		}
+	}
+}
+
+func (r *Runtime) entityType(entityID string) string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if state, ok := r.entities[entityID]; ok {
+		return state.Type()
+	}
+	return ""
+}
+
+// setLiveness write online property of the entity through runtime and publish liveness event,
+// writes of liveness are not recorded as entity messages, so never flip liveness back.
+func (r *Runtime) setLiveness(ctx context.Context, entityID, entityType string, online bool, lastSeen int64) {
+	event := EventEntityOffline
+	if online {
+		event = EventEntityOnline
+	}
+
+	if err := r.dispatcher.Dispatch(ctx, &v1.ProtoEvent{
+		Id:        util.IG().EvID(),
+		Timestamp: time.Now().UnixNano(),
+		Metadata: map[string]string{
+			v1.MetaType:     string(v1.ETEntity),
+			v1.MetaBorn:     "handleLiveness",
+			v1.MetaEntityID: entityID,
+			v1.MetaLiveness: event,
+		},
+		Data: &v1.ProtoEvent_Patches{
+			Patches: &v1.PatchDatas{Patches: []*v1.PatchData{{
+				Path:     FieldProperties + "." + PropertyOnline,
+				Operator: xjson.OpReplace.String(),
+				Value:    []byte(strconv.FormatBool(online)),
+			}}},
+		},
+	}); nil != err {
+		log.L().Error("set entity liveness, dispatch event", logf.Eid(entityID), logf.Error(err))
+	}
+
+	if err := r.liveness.publish(ctx, &LivenessEvent{
+		Event:      event,
+		EntityID:   entityID,
+		EntityType: entityType,
+		LastSeen:   lastSeen,
+		Timestamp:  time.Now().UnixNano() / 1e6,
+	}); nil != err {
+		log.L().Error("publish liveness event", logf.Eid(entityID), logf.Error(err))
+	}
+}
+
+func (r *Runtime) GetLiveness(entityID string) (Liveness, bool) {
+	r.slock.RLock()
+	defer r.slock.RUnlock()
+	if stats, ok := r.stats[entityID]; ok {
+		return Liveness{EntityID: entityID, Online: !stats.Offline && stats.LastSeen > 0, LastSeen: stats.LastSeen}, true
+	}
+	return Liveness{}, false
+}
+
+// seedLiveness track liveness persisted of entities by runtimes of the node, so entities
+// silent since a restart still go offline, and entities offline flip back online.
+func (n *Node) seedLiveness(ctx context.Context) {
+	now := time.Now()
+	for _, online := range []bool{true, false} {
+		for pageNum := int32(1); ; pageNum++ {
+			resp, err := n.resourceManager.Search().Search(ctx, &v1.SearchRequest{
+				PageNum:  pageNum,
+				PageSize: livenessPageSize,
+				Condition: []*v1.SearchCondition{{
+					Field:    FieldProperties + "." + PropertyOnline,
+					Operator: "$eq",
+					Value:    structpb.NewBoolValue(online),
+				}},
+			})
+			if nil != err {
+				log.L().Error("seed entity liveness, search entities", logf.Error(err))
+				break
+			}
+
+			for _, item := range resp.Items {
+				fields, _ := item.AsInterface().(map[string]interface{})
+				entityID, _ := fields[FieldID].(string)
+				if rt, ok := n.runtimes[placement.Global().Select(entityID).ID]; ok && entityID != "" && rt.liveness != nil {
+					rt.seedLiveness(entityID, online, now)
+				}
+			}
+			if len(resp.Items) < livenessPageSize {
+				break
+			}
+		}
+	}
+}
+
+// GetLiveness returns liveness of the entity tracked by runtimes of the node.
+func (n *Node) GetLiveness(ctx context.Context, entityID string) (*Liveness, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	for _, rt := range n.runtimes {
+		if liveness, ok := rt.GetLiveness(entityID); ok {
+			return &liveness, nil
+		}
+	}
+	return nil, errors.Wrap(xerrors.ErrEntityNotFound, "get entity liveness")
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/util"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+	"github.com/tkeel-io/tdtl"
+)
+
+// mirror write the mirrored property to the target entity if changed by the feed,
+// writes of mirrors are not mirrored again, so bidirectional mirrors never loop.
+func (r *Runtime) mirror(ctx context.Context, feed *Feed, sub *repository.Subscription) {
+	if feed.Event != nil && feed.Event.Attr(v1.MetaMirror) != "" {
+		return
+	} else if len(sub.SourceEntityPaths) == 0 || sub.Target == "" || sub.TargetPath == "" {
+		log.L().Warn("mirror property, invalid mirror", logf.ID(sub.ID), logf.Eid(feed.EntityID))
+		return
+	}
+
+	source := sub.SourceEntityPaths[0]
+	if !mirrorChanged(feed.Changes, source) {
+		return
+	}
+
+	pd := &v1.PatchData{Path: sub.TargetPath, Operator: xjson.OpReplace.String()}
+	if value := tdtl.New(feed.State).Get(source); value.Type() == tdtl.Null || value.Type() == tdtl.Undefined {
+		pd.Operator = xjson.OpRemove.String()
+	} else {
+		pd.Value = value.Raw()
+	}
+
+	if err := r.dispatcher.Dispatch(ctx, &v1.ProtoEvent{
+		Id:        util.IG().EvID(),
+		Timestamp: time.Now().UnixNano(),
+		Metadata: map[string]string{
+			v1.MetaType:     string(v1.ETEntity),
+			v1.MetaBorn:     "handleMirror",
+			v1.MetaEntityID: sub.Target,
+			v1.MetaSender:   feed.EntityID,
+			v1.MetaMirror:   sub.ID,
+		},
+		Data: &v1.ProtoEvent_Patches{
+			Patches: &v1.PatchDatas{Patches: []*v1.PatchData{pd}},
+		},
+	}); nil != err {
+		log.L().Error("mirror property, dispatch event", logf.ID(sub.ID),
+			logf.Eid(feed.EntityID), logf.Target(sub.Target), logf.Error(err))
+	}
+}
+
+// mirrorChanged reports whether changes touch the path, the path itself, nested or parent properties.
+func mirrorChanged(changes []Patch, path string) bool {
+	for _, change := range changes {
+		if change.Path == path ||
+			strings.HasPrefix(change.Path, path+".") ||
+			strings.HasPrefix(path, change.Path+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/types"
+	"github.com/tkeel-io/tdtl"
+)
+
+// handleNamespace namespace keys of properties written by the event with the namespace of the event,
+// patches of the whole properties object split into patches of its keys, so other keys are kept.
+// rawData maintained by core never namespaced.
+func (r *Runtime) handleNamespace(ctx context.Context, feed *Feed) *Feed {
+	if feed.Event == nil || feed.Event.Attr(v1.MetaNamespace) == "" {
+		return feed
+	}
+
+	namespace := feed.Event.Attr(v1.MetaNamespace)
+	if !types.ValidNamespace(namespace) {
+		feed.Err = errors.Wrapf(xerrors.ErrNamespaceInvalid, "namespace %q", namespace)
+		return feed
+	}
+
+	patches := make([]Patch, 0, len(feed.Patches))
+	for _, patch := range feed.Patches {
+		switch {
+		case patch.Path == FieldProperties && patch.Value != nil && patch.Value.Type() == tdtl.Object:
+			op := patch.Op
+			patch.Value.Foreach(func(key []byte, value *tdtl.Collect) {
+				patches = append(patches, Patch{
+					Op:    op,
+					Path:  FieldProperties + "." + types.NamespacedKey(namespace, string(key)),
+					Value: value,
+				})
+			})
+		case patch.Path == FieldRawData:
+			patches = append(patches, patch)
+		case strings.HasPrefix(patch.Path, FieldProperties+"."):
+			patch.Path = FieldProperties + "." + types.NamespacedKey(namespace, strings.TrimPrefix(patch.Path, FieldProperties+"."))
+			patches = append(patches, patch)
+		default:
+			patches = append(patches, patch)
+		}
+	}
+
+	feed.Patches = patches
+	return feed
+}
@@ -3,6 +3,7 @@ package runtime
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/Shopify/sarama"
 	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/config"
 	"github.com/tkeel-io/core/pkg/dispatch"
 	xerrors "github.com/tkeel-io/core/pkg/errors"
 	logf "github.com/tkeel-io/core/pkg/logfield"
@@ -23,6 +25,7 @@ import (
 	"github.com/tkeel-io/core/pkg/util"
 	xkafka "github.com/tkeel-io/core/pkg/util/kafka"
 	"github.com/tkeel-io/kit/log"
+	"go.uber.org/atomic"
 )
 
 type NodeConf struct {
@@ -39,10 +42,24 @@ type Node struct {
 	ctx             context.Context
 	cancel          context.CancelFunc
 	searchModel     []string
+	// enrich search documents of entities.
+	searchDocBuilder SearchDocBuilder
+	// order of removing deleted entity from state and search.
+	deleteOrder string
+	// started set once sources consumed, see Ready.
+	started atomic.Bool
+	// draining set once draining started, sources no longer consumed.
+	draining atomic.Bool
+	// stopConsuming stop consuming sources, messages not consumed left to other nodes or the next start.
+	stopConsuming context.CancelFunc
 }
 
 func NewNode(ctx context.Context, resourceManager types.ResourceManager, dispatcher dispatch.Dispatcher, searchModel []string) *Node {
 	ctx, cacel := context.WithCancel(ctx)
+	builder, err := SearchDocBuilderOf(config.Get().Components.SearchDocBuilder)
+	if nil != err {
+		log.L().Error("create node, default search documents indexed", logf.Error(err))
+	}
 	return &Node{
 		ctx:             ctx,
 		cancel:          cacel,
@@ -52,6 +69,9 @@ func NewNode(ctx context.Context, resourceManager types.ResourceManager, dispatc
 		runtimes:        make(map[string]*Runtime),
 		queues:          make(map[string]*xkafka.Pubsub),
 		searchModel:     searchModel,
+		deleteOrder:     config.Get().Components.DeleteOrder,
+
+		searchDocBuilder: builder,
 	}
 }
 
@@ -76,6 +96,9 @@ func (n *Node) Start(cfg NodeConf) error {
 		log.L().Info("create runtime instance",
 			logf.ID(runtimeID), logf.Source(cfg.Sources[index]))
 		entityResouce := EntityResource{PersistentEntity: n.PersistentEntity, FlushHandler: n.FlushEntity, RemoveHandler: n.RemoveEntity}
+		if config.Get().Components.RecoverFromSearch {
+			entityResouce.RecoverHandler = n.RecoverEntity
+		}
 		runtime := NewRuntime(n.ctx, entityResouce, runtimeID, n.dispatch, n.resourceManager.Repo())
 		n.runtimes[runtimeID] = runtime
 		placement.Global().Append(placement.Info{ID: sourceIns.ID(), Flag: true})
@@ -87,14 +110,20 @@ func (n *Node) Start(cfg NodeConf) error {
 
 	// 3. watch resource
 	n.watchMetadata()
+	if config.Get().Liveness.Enabled {
+		go n.seedLiveness(n.ctx)
+	}
 
 	// 4. start KafkaReceived
+	var consumeCtx context.Context
+	consumeCtx, n.stopConsuming = context.WithCancel(n.ctx)
 	for _, queue := range n.queues {
-		if err = queue.Received(n.ctx, n); nil != err {
+		if err = queue.Received(consumeCtx, n); nil != err {
 			return errors.Wrap(err, "consume source")
 		}
 	}
 	// watch metadata.
+	n.started.Store(true)
 	log.L().Debug("start node completed", logf.Elapsedms(elapsed.ElapsedMilli()))
 	//
 	//for index := range cfg.Sources {
@@ -136,6 +165,45 @@ func (n *Node) Start(cfg NodeConf) error {
 	return nil
 }
 
+// Ready returns nil if node started and not stopped.
+func (n *Node) Ready(ctx context.Context) error {
+	if !n.started.Load() {
+		return errors.Wrap(xerrors.ErrServerNotReady, "node not started")
+	} else if nil != n.ctx.Err() {
+		return errors.Wrap(xerrors.ErrServerNotReady, "node stopped")
+	} else if n.draining.Load() {
+		return errors.Wrap(xerrors.ErrServerNotReady, "node draining")
+	}
+	return nil
+}
+
+// Stop node, stop consuming sources and flush pending notifications of runtimes.
+func (n *Node) Stop() {
+	n.cancel()
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	for _, rt := range n.runtimes {
+		rt.Stop()
+	}
+}
+
+// Drain stop consuming sources, then drain runtimes of the node, see Runtime.Drain.
+func (n *Node) Drain(ctx context.Context) error {
+	n.draining.Store(true)
+	if n.stopConsuming != nil {
+		n.stopConsuming()
+	}
+
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	for _, rt := range n.runtimes {
+		if err := rt.Drain(ctx); nil != err {
+			return errors.Wrap(err, "drain node")
+		}
+	}
+	return nil
+}
+
 func (n *Node) HandleMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
 	rid := msg.Topic
 	if _, has := n.runtimes[rid]; !has {
@@ -145,9 +213,9 @@ func (n *Node) HandleMessage(ctx context.Context, msg *sarama.ConsumerMessage) e
 	}
 
 	// load runtime spec.
+	// rejected messages not marked consumed, redelivered.
 	rt := n.runtimes[rid]
-	rt.DeliveredEvent(context.Background(), msg)
-	return nil
+	return errors.Wrap(rt.DeliveredEvent(context.Background(), msg), "handle message")
 }
 
 // initialize runtime environments.
@@ -188,6 +256,13 @@ func (n *Node) listMetadata() {
 		// 将mapper加入每一个 runtime.
 		for _, sub := range subscriptions {
 			log.L().Debug("sync subscription", logf.String("subID", sub.ID), logf.Owner(sub.Owner))
+			if sub.TypeLevel() {
+				// entities of the type may be placed on any runtime.
+				for _, rt := range n.runtimes {
+					rt.putTypeSubscription(sub)
+				}
+				continue
+			}
 			entityID := sub.SourceEntityID
 			runtimeInfo := placement.Global().Select(entityID)
 			runtime, ok := n.runtimes[runtimeInfo.ID]
@@ -249,6 +324,12 @@ func (n *Node) watchMetadata() {
 			switch et {
 			case dao.DELETE:
 				log.L().Debug("sync DELETE Subscription", logf.String("subID", sub.ID), logf.Owner(sub.Owner))
+				if sub.TypeLevel() {
+					for _, rt := range n.runtimes {
+						rt.delTypeSubscription(sub)
+					}
+					return
+				}
 				entityID := sub.SourceEntityID
 				runtimeInfo := placement.Global().Select(entityID)
 				runtime, ok := n.runtimes[runtimeInfo.ID]
@@ -259,6 +340,12 @@ func (n *Node) watchMetadata() {
 				}
 			case dao.PUT:
 				log.L().Debug("sync PUT Subscription", logf.String("subID", sub.ID), logf.Owner(sub.Owner))
+				if sub.TypeLevel() {
+					for _, rt := range n.runtimes {
+						rt.putTypeSubscription(sub)
+					}
+					return
+				}
 				entityID := sub.SourceEntityID
 				runtimeInfo := placement.Global().Select(entityID)
 				runtime, ok := n.runtimes[runtimeInfo.ID]
@@ -354,6 +441,52 @@ func (n *Node) Debug(req *go_restful.Request, resp *go_restful.Response) {
 		n.entity(entityID, resp)
 	case "cache":
 		n.cache(entityID, resp)
+	case "stats":
+		if stats, err := n.GetEntityStats(req.Request.Context(), entityID); nil != err {
+			resp.WriteErrorString(404, err.Error())
+		} else {
+			resp.WriteAsJson(stats)
+		}
+	case "liveness":
+		if liveness, err := n.GetLiveness(req.Request.Context(), entityID); nil != err {
+			resp.WriteErrorString(404, err.Error())
+		} else {
+			resp.WriteAsJson(liveness)
+		}
+	case "messages":
+		count, _ := strconv.Atoi(req.Request.URL.Query().Get("n"))
+		if msgs, err := n.ReplayMessages(req.Request.Context(), entityID, count); nil != err {
+			resp.WriteErrorString(404, err.Error())
+		} else {
+			resp.WriteAsJson(msgs)
+		}
+	case "replay":
+		count, _ := strconv.Atoi(req.Request.URL.Query().Get("n"))
+		msgs, err := n.ReplayMessages(req.Request.Context(), entityID, count)
+		if nil != err {
+			resp.WriteErrorString(404, err.Error())
+			return
+		}
+
+		shadow, err := n.Replay(req.Request.Context(), entityID, msgs)
+		if nil != err {
+			resp.WriteErrorString(500, err.Error())
+			return
+		}
+		resp.Write(shadow.Raw())
+	case "simulate":
+		results, err := n.SimulateMapper(req.Request.Context(), entityID, req.Request.URL.Query().Get("tql"))
+		if nil != err {
+			resp.WriteErrorString(400, err.Error())
+			return
+		}
+		resp.WriteAsJson(results)
+	case "status":
+		if status, err := n.GetRuntimeStatus(req.Request.Context(), entityID); nil != err {
+			resp.WriteErrorString(404, err.Error())
+		} else {
+			resp.WriteAsJson(status)
+		}
 	case "subtree":
 		ret := n.runtimes[runtimeID]
 		resp.Write([]byte(ret.subTree.String()))
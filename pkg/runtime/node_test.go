@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
 	"github.com/tkeel-io/tdtl"
 )
 
@@ -118,6 +120,65 @@ func TestNode_getGlobalData(t *testing.T) {
 	})
 	_, err = node.makeSearchData(en, feed)
 	assert.Error(t, err)
+
+	// tombstone indexed.
+	en, err = NewEntity("device123", []byte(`{"id":"device123","version":3,"properties":{},"deleted_at":1649824132030}`))
+	assert.Nil(t, err)
+	feed = &Feed{}
+	feed.Changes = append(feed.Changes, Patch{
+		Op:    0,
+		Path:  FieldDeletedAt,
+		Value: tdtl.New([]byte("1649824132030")),
+	})
+	res, err = node.makeSearchData(en, feed)
+	assert.Nil(t, err)
+	assert.Equal(t, "1649824132030", tdtl.New(res).Get(FieldDeletedAt).String())
+	assert.Equal(t, "3", tdtl.New(res).Get(FieldVersion).String())
+
+	// enrich document with computed fields, builder registered by name.
+	RegisterSearchDocBuilder("geohash", func(en Entity, doc []byte) ([]byte, error) {
+		cc := tdtl.New(doc)
+		cc.Set("geohash", tdtl.NewString("wx4g0"))
+		return cc.Raw(), cc.Error()
+	})
+	_, err = SearchDocBuilderOf("absent")
+	assert.Error(t, err)
+	builder, err := SearchDocBuilderOf("geohash")
+	assert.Nil(t, err)
+	node.UseSearchDocBuilder(builder)
+	res, err = node.makeSearchData(en, feed)
+	assert.Nil(t, err)
+	assert.Equal(t, "wx4g0", tdtl.New(res).Get("geohash").String())
+	assert.Equal(t, "device123", tdtl.New(res).Get(FieldID).String())
+	assert.Equal(t, tdtl.Null, en.Get("geohash").Type())
+
+	// builder may index any property, changes of other properties indexed too.
+	res, err = node.makeSearchData(en, &Feed{Changes: []Patch{{
+		Op:    0,
+		Path:  "properties.rawData",
+		Value: &tdtl.JSONNode{},
+	}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "wx4g0", tdtl.New(res).Get("geohash").String())
+
+	// type changed, reindexed.
+	en, err = NewEntity("device123", []byte(`{"id":"device123","type":"GATEWAY","properties":{}}`))
+	assert.Nil(t, err)
+	res, err = node.makeSearchData(en, &Feed{Changes: []Patch{{
+		Op:    0,
+		Path:  FieldType,
+		Value: tdtl.New([]byte(`"GATEWAY"`)),
+	}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "GATEWAY", tdtl.New(res).Get(FieldType).String())
+}
+
+func Test_syncIndex(t *testing.T) {
+	ev := &v1.ProtoEvent{Metadata: map[string]string{}}
+	assert.False(t, syncIndex(&Feed{Event: ev, Changes: []Patch{{Path: "properties.temp"}}}))
+	assert.True(t, syncIndex(&Feed{Event: ev, Changes: []Patch{{Path: FieldDeletedAt}}}))
+	ev.SetAttr(v1.MetaSyncIndex, "true")
+	assert.True(t, syncIndex(&Feed{Event: ev, Changes: []Patch{{Path: "properties.temp"}}}))
 }
 
 func TestNode_makeRawData(t *testing.T) {
@@ -202,6 +263,35 @@ func TestNode_makeSeriesData(t *testing.T) {
 	t.Log(out.Data)
 }
 
+func Test_stateFromSearch(t *testing.T) {
+	item := map[string]interface{}{
+		"id":        "device123",
+		"type":      "DEVICE",
+		"owner":     "admin",
+		"source":    "dm",
+		"basicInfo": map[string]interface{}{"name": "light"},
+		"_score":    1.5,
+	}
+
+	state, err := stateFromSearch("device123", item)
+	assert.Nil(t, err)
+	en, err := NewEntity("device123", state)
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", en.Owner())
+	assert.Equal(t, "DEVICE", en.Type())
+	assert.Equal(t, `"light"`, string(en.GetProp("basicInfo.name").Raw()))
+
+	_, err = stateFromSearch("device234", item)
+	assert.NotNil(t, err)
+	_, err = stateFromSearch("device123", nil)
+	assert.NotNil(t, err)
+
+	// tombstoned entities never recovered.
+	item["deleted_at"] = float64(1666790831700)
+	_, err = stateFromSearch("device123", item)
+	assert.ErrorIs(t, err, xerrors.ErrEntityNotFound)
+}
+
 func Test_parseExpression(t *testing.T) {
 }
 
@@ -20,17 +20,23 @@ import (
 	"context"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tkeel-io/collectjs"
 	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
 	logf "github.com/tkeel-io/core/pkg/logfield"
 	"github.com/tkeel-io/core/pkg/metrics"
+	"github.com/tkeel-io/core/pkg/repository"
 	"github.com/tkeel-io/core/pkg/resource/rawdata"
+	"github.com/tkeel-io/core/pkg/resource/search"
 	"github.com/tkeel-io/core/pkg/resource/tseries"
 	"github.com/tkeel-io/kit/log"
 	"github.com/tkeel-io/tdtl"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func (n *Node) PersistentEntity(ctx context.Context, en Entity, feed *Feed) error {
@@ -58,7 +64,11 @@ func (n *Node) PersistentEntity(ctx context.Context, en Entity, feed *Feed) erro
 	if nil != err {
 		log.L().Warn("make SearchData error", logf.Error(err), logf.Eid(en.ID()))
 	} else {
-		if _, err = n.resourceManager.Search().IndexBytes(ctx, en.ID(), globalData); nil != err {
+		indexCtx := ctx
+		if syncIndex(feed) {
+			indexCtx = search.WithSyncIndex(ctx)
+		}
+		if _, err = n.resourceManager.Search().IndexBytes(indexCtx, en.ID(), globalData); nil != err {
 			log.L().Error("flush entity search engine", logf.Error(err), logf.Eid(en.ID()))
 			//			return errors.Wrap(err, "flush entity into search engine")
 		}
@@ -216,10 +226,66 @@ func makeTimeSeriesData(ctx context.Context, en Entity, feed *Feed) (*tseries.TS
 	return ret, tsCount, errors.Wrap(err, "write ts db error")
 }
 
+// SearchDocBuilder build the document indexed into search engine from the entity and its default document,
+// e.g. add computed fields, the entity state stored is untouched.
+type SearchDocBuilder func(en Entity, doc []byte) ([]byte, error)
+
+var (
+	builderLock       sync.RWMutex
+	searchDocBuilders = map[string]SearchDocBuilder{}
+)
+
+// RegisterSearchDocBuilder register builder of search documents, selected by name through
+// config components.search_doc_builder, register before the node and the manager are created.
+func RegisterSearchDocBuilder(name string, builder SearchDocBuilder) {
+	builderLock.Lock()
+	defer builderLock.Unlock()
+	searchDocBuilders[name] = builder
+}
+
+// SearchDocBuilderOf returns the builder registered by name, nil for empty name.
+func SearchDocBuilderOf(name string) (SearchDocBuilder, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	builderLock.RLock()
+	defer builderLock.RUnlock()
+	if builder, ok := searchDocBuilders[name]; ok {
+		return builder, nil
+	}
+	return nil, errors.Wrapf(xerrors.ErrInvalidParam, "search doc builder %q", name)
+}
+
+// UseSearchDocBuilder set builder of search documents, the default document indexed if nil.
+func (n *Node) UseSearchDocBuilder(builder SearchDocBuilder) {
+	n.searchDocBuilder = builder
+}
+
+// syncIndex reports whether the document indexed before the flush returns even if indexing batched,
+// requested by the write or changing which listings the entity belongs to.
+func syncIndex(feed *Feed) bool {
+	if feed.Event != nil && feed.Event.Attr(v1.MetaSyncIndex) == "true" {
+		return true
+	}
+	for _, patch := range feed.Changes {
+		if patch.Path == FieldDeletedAt || patch.Path == FieldType {
+			return true
+		}
+	}
+	return false
+}
+
+// properties indexed into search engine.
+var searchBasicPath = []string{"sysField", "basicInfo", "connectInfo", "group", "memberOf", "tags", PropertyOnline}
+
 func (n *Node) makeSearchData(en Entity, feed *Feed) ([]byte, error) {
-	searchBasicPath := []string{"sysField", "basicInfo", "connectInfo", "group"}
-	writeFlag := false
+	// builder may index any property, so every change indexed.
+	writeFlag := n.searchDocBuilder != nil && len(feed.Changes) > 0
 	for _, patch := range feed.Changes {
+		if patch.Path == FieldDeletedAt || patch.Path == FieldType {
+			writeFlag = true
+		}
 		for _, searchPath := range searchBasicPath {
 			if strings.HasPrefix(patch.Path, "properties."+searchPath) {
 				writeFlag = true
@@ -230,10 +296,24 @@ func (n *Node) makeSearchData(en Entity, feed *Feed) ([]byte, error) {
 		return nil, errors.New("no need to write")
 	}
 
+	return MakeSearchDocument(en, n.searchModel, n.searchDocBuilder)
+}
+
+// MakeSearchDocument build the document of the entity indexed into search engine, enriched by builder
+// if not nil, shared by runtime flushes and manager indexing so that documents indexed either way identical.
+func MakeSearchDocument(en Entity, searchModel []string, builder SearchDocBuilder) ([]byte, error) {
 	globalData := collectjs.ByteNew([]byte(`{}`))
-	fields := []string{FieldID, FieldType, FieldOwner, FieldSource, FieldTemplate}
+	// version indexed for waiting on the index to reflect a write, see search.SearchFresh.
+	fields := []string{FieldID, FieldType, FieldOwner, FieldSource, FieldTemplate, FieldVersion}
 	for _, field := range fields {
-		globalData.Set(field, en.Get(field).Raw())
+		if val := en.Get(field); val.Type() != tdtl.Null {
+			globalData.Set(field, val.Raw())
+		}
+	}
+
+	// index tombstone, purge tombstoned entities by searching.
+	if deletedAt := en.Get(FieldDeletedAt); deletedAt.Type() != tdtl.Null {
+		globalData.Set(FieldDeletedAt, deletedAt.Raw())
 	}
 
 	/*
@@ -252,8 +332,8 @@ func (n *Node) makeSearchData(en Entity, feed *Feed) ([]byte, error) {
 
 	// log.L().Info("searchModel", logf.Value(n.searchModel))
 	keywords := make([]string, 0, 4)
-	if n.searchModel != nil && len(n.searchModel) > 0 {
-		for _, field := range n.searchModel {
+	if len(searchModel) > 0 {
+		for _, field := range searchModel {
 			val := strings.Trim(string(en.Get(field).Raw()), "\"")
 			// log.L().Info("searchModel:field", logf.Value(val))
 			if val != "" {
@@ -264,15 +344,90 @@ func (n *Node) makeSearchData(en Entity, feed *Feed) ([]byte, error) {
 			globalData.Set(FieldKeyWords, tdtl.NewString(strings.Join(keywords, " ")).Raw())
 		}
 	}
+
+	if builder != nil {
+		doc, err := builder(en, globalData.GetRaw())
+		return doc, errors.Wrap(err, "build search document")
+	}
 	return globalData.GetRaw(), nil
 }
 
+// RecoverEntity reconstruct entity state from search engine and repair state storage,
+// the search copy may be stale and only carry indexed properties. entities tombstoned
+// or being deleted are never recovered.
+func (n *Node) RecoverEntity(ctx context.Context, id string) ([]byte, error) {
+	deleting, err := n.resourceManager.Repo().HasDeleteIntent(ctx, id)
+	if nil != err {
+		return nil, errors.Wrap(err, "recover entity")
+	} else if deleting {
+		return nil, errors.Wrap(xerrors.ErrEntityNotFound, "recover entity, entity being deleted")
+	}
+
+	resp, err := n.resourceManager.Search().Search(ctx, &v1.SearchRequest{
+		PageNum:  1,
+		PageSize: 1,
+		Condition: []*v1.SearchCondition{{
+			Field:    FieldID,
+			Operator: "$eq",
+			Value:    structpb.NewStringValue(id),
+		}},
+	})
+	if nil != err {
+		return nil, errors.Wrap(err, "recover entity, search entity")
+	} else if len(resp.Items) == 0 {
+		return nil, errors.Wrap(xerrors.ErrEntityNotFound, "recover entity")
+	}
+
+	item, _ := resp.Items[0].AsInterface().(map[string]interface{})
+	state, err := stateFromSearch(id, item)
+	if nil != err {
+		return nil, errors.Wrap(err, "recover entity")
+	}
+
+	if err = n.resourceManager.Repo().PutEntity(ctx, id, state); nil != err {
+		log.L().Error("recover entity, repair state storage", logf.Eid(id), logf.Error(err))
+		return nil, errors.Wrap(err, "recover entity, repair state storage")
+	}
+
+	log.L().Warn("entity recovered from search engine", logf.Eid(id), logf.Value(string(state)))
+	return state, nil
+}
+
+// stateFromSearch reconstruct entity state from search document.
+func stateFromSearch(id string, item map[string]interface{}) ([]byte, error) {
+	if nil == item || item[FieldID] != id {
+		return nil, xerrors.ErrEntityNotFound
+	} else if nil != item[FieldDeletedAt] {
+		// tombstoned.
+		return nil, xerrors.ErrEntityNotFound
+	}
+
+	state := tdtl.New([]byte(`{"properties":{}}`))
+	for _, field := range []string{FieldID, FieldType, FieldOwner, FieldSource, FieldTemplate} {
+		if val, ok := item[field].(string); ok {
+			state.Set(field, tdtl.NewString(val))
+		}
+	}
+
+	for _, path := range searchBasicPath {
+		if val, has := item[path]; has && nil != val {
+			bytes, err := json.Marshal(val)
+			if nil != err {
+				return nil, errors.Wrap(err, "encode property")
+			}
+			state.Set(FieldProperties+"."+path, tdtl.New(bytes))
+		}
+	}
+
+	return state.Raw(), errors.Wrap(state.GetError(), "reconstruct entity state")
+}
+
 func (n *Node) RemoveEntity(ctx context.Context, en Entity, feed *Feed) error {
 	var err error
 
-	// recover entity state.
+	// recover entity state, entities deleted while missing from state have none.
 	defer func() {
-		if nil != err {
+		if nil != err && en.Version() > 0 {
 			if innerErr := n.FlushEntity(ctx, en, feed); nil != innerErr {
 				log.L().Error("remove entity failed, recover entity state failed", logf.Eid(en.ID()),
 					logf.Reason(err.Error()), logf.Error(innerErr), logf.Value(string(en.Raw())))
@@ -280,15 +435,42 @@ func (n *Node) RemoveEntity(ctx context.Context, en Entity, feed *Feed) error {
 		}
 	}()
 
-	// 1. 从状态存储中删除（可标记）
+	// 0. 记录删除意图, 删除中断时由 FinishDeletes 补全.
+	intent := &repository.DeleteIntent{EntityID: en.ID(), Owner: en.Owner(), Source: en.Source()}
+	if err = n.resourceManager.Repo().PutDeleteIntent(ctx, intent); nil != err {
+		log.L().Error("remove entity, put delete intent", logf.Error(err), logf.Eid(en.ID()))
+		return errors.Wrap(err, "remove entity, put delete intent")
+	}
+
+	// 1. 从状态存储和搜索中删除, 顺序可配置.
+	steps := []func(context.Context, Entity) error{n.removeFromState, n.removeFromSearch}
+	if n.deleteOrder == config.DeleteSearchFirst {
+		steps[0], steps[1] = steps[1], steps[0]
+	}
+	for _, step := range steps {
+		if err = step(ctx, en); nil != err {
+			return err
+		}
+	}
+
+	// 2. 删除完成, 清理删除意图.
+	if innerErr := n.resourceManager.Repo().DelDeleteIntent(ctx, intent); nil != innerErr {
+		log.L().Warn("remove entity, del delete intent", logf.Error(innerErr), logf.Eid(en.ID()))
+	}
+	return nil
+}
+
+func (n *Node) removeFromState(ctx context.Context, en Entity) error {
 	if err := n.resourceManager.Repo().
 		DelEntity(ctx, en.ID()); nil != err {
 		log.L().Error("remove entity from state storage",
 			logf.Error(err), logf.Eid(en.ID()), logf.Value(string(en.Raw())))
 		return errors.Wrap(err, "remove entity from state storage")
 	}
+	return nil
+}
 
-	// 2. 从搜索中删除（可标记）
+func (n *Node) removeFromSearch(ctx context.Context, en Entity) error {
 	if _, err := n.resourceManager.Search().
 		DeleteByID(ctx, &v1.DeleteByIDRequest{
 			Id:     en.ID(),
@@ -299,8 +481,6 @@ func (n *Node) RemoveEntity(ctx context.Context, en Entity, feed *Feed) error {
 			logf.Error(err), logf.Eid(en.ID()), logf.Value(string(en.Raw())))
 		return errors.Wrap(err, "remove entity from state search engine")
 	}
-
-	// 3. 删除实体相关的 Expression.
 	return nil
 }
 
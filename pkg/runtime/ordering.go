@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+)
+
+const (
+	defaultOrderingWaitTimeout = time.Second
+	defaultOrderingMaxPending  = 100
+	orderingAllEntities        = "*"
+	// states of entities idle longer pruned, the next message of the entity starts its sequence again.
+	orderingStateTTL = 10 * time.Minute
+)
+
+// sequencer applies messages of ordered entities in order of sequence numbers, see config.OrderingConfig.
+type sequencer struct {
+	all         bool
+	entities    map[string]bool
+	dropOnGap   bool
+	waitTimeout time.Duration
+	maxPending  int
+
+	lock   sync.Mutex
+	states map[string]*sequenceState
+}
+
+type sequenceState struct {
+	// last sequence number applied or skipped.
+	last    int64
+	pending map[int64]v1.Event
+	// waiting since the first message buffered.
+	since time.Time
+	// last message of the entity arrived.
+	seen time.Time
+}
+
+// sequencerFrom returns sequencer of the config, nil if ordering enabled for none entity.
+func sequencerFrom(cfg config.OrderingConfig) *sequencer {
+	if len(cfg.Entities) == 0 {
+		return nil
+	}
+
+	s := &sequencer{
+		entities:    make(map[string]bool),
+		dropOnGap:   cfg.OnGap == config.OrderingGapDrop,
+		waitTimeout: time.Duration(cfg.WaitTimeout) * time.Millisecond,
+		maxPending:  cfg.MaxPending,
+		states:      make(map[string]*sequenceState),
+	}
+	for _, entityID := range cfg.Entities {
+		s.all = s.all || entityID == orderingAllEntities
+		s.entities[entityID] = true
+	}
+	if s.waitTimeout <= 0 {
+		s.waitTimeout = defaultOrderingWaitTimeout
+	}
+	if s.maxPending <= 0 {
+		s.maxPending = defaultOrderingMaxPending
+	}
+	return s
+}
+
+// admit returns messages ready to apply in order, and stale messages to drop.
+func (s *sequencer) admit(ev v1.Event) (ready []v1.Event, stale []v1.Event) {
+	if s == nil || ev.Type() != v1.ETEntity || !(s.all || s.entities[ev.Entity()]) {
+		return []v1.Event{ev}, nil
+	}
+
+	seq, err := strconv.ParseInt(ev.Attr(v1.MetaSequence), 10, 64)
+	if nil != err {
+		return []v1.Event{ev}, nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state, ok := s.states[ev.Entity()]
+	if !ok {
+		state = &sequenceState{last: seq - 1, pending: make(map[int64]v1.Event)}
+		s.states[ev.Entity()] = state
+	}
+	state.seen = time.Now()
+
+	switch _, buffered := state.pending[seq]; {
+	case seq <= state.last || buffered:
+		return nil, []v1.Event{ev}
+	case seq == state.last+1 || s.dropOnGap:
+		state.last = seq
+		ready = append(ready, ev)
+	default:
+		state.pending[seq] = ev
+		if state.since.IsZero() {
+			state.since = time.Now()
+		}
+		if len(state.pending) <= s.maxPending && time.Since(state.since) < s.waitTimeout {
+			return nil, nil
+		}
+		// give up waiting, skip the gap.
+		state.last = state.minPending() - 1
+	}
+
+	ready = append(ready, state.drain()...)
+	state.since = time.Time{}
+	if len(state.pending) > 0 {
+		state.since = time.Now()
+	}
+	return ready, nil
+}
+
+// expire returns messages buffered of entities waited longer than wait timeout, gaps skipped,
+// so that gaps resolved without later messages, and prunes states of entities idle.
+func (s *sequencer) expire(now time.Time) []v1.Event {
+	if s == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var ready []v1.Event
+	for entityID, state := range s.states {
+		switch {
+		case len(state.pending) > 0 && now.Sub(state.since) >= s.waitTimeout:
+			state.last = state.minPending() - 1
+			ready = append(ready, state.drain()...)
+			state.since = time.Time{}
+			if len(state.pending) > 0 {
+				state.since = now
+			}
+		case len(state.pending) == 0 && now.Sub(state.seen) > orderingStateTTL:
+			delete(s.states, entityID)
+		}
+	}
+	return ready
+}
+
+// flushAll returns all messages buffered in order of sequence numbers, gaps skipped.
+func (s *sequencer) flushAll() []v1.Event {
+	if s == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var ready []v1.Event
+	for _, state := range s.states {
+		for len(state.pending) > 0 {
+			state.last = state.minPending() - 1
+			ready = append(ready, state.drain()...)
+		}
+		state.since = time.Time{}
+	}
+	return ready
+}
+
+// drain returns buffered messages consecutive to the last applied.
+func (st *sequenceState) drain() []v1.Event {
+	var events []v1.Event
+	for {
+		ev, ok := st.pending[st.last+1]
+		if !ok {
+			return events
+		}
+		st.last++
+		delete(st.pending, st.last)
+		events = append(events, ev)
+	}
+}
+
+func (st *sequenceState) minPending() int64 {
+	seqs := make([]int64, 0, len(st.pending))
+	for seq := range st.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs[0]
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/placement"
+	"github.com/tkeel-io/kit/log"
+)
+
+// messageLogSize messages retained per entity for replay.
+const messageLogSize = 32
+
+// MessageContext entity event processed by runtime, retained for debugging.
+type MessageContext struct {
+	EventID  string `json:"event_id"`
+	EntityID string `json:"entity_id"`
+	TraceID  string `json:"trace_id,omitempty"`
+	// Timestamp(ms) the event handled.
+	Timestamp int64 `json:"timestamp"`
+	// Event encoded event.
+	Event []byte `json:"event"`
+}
+
+// logMessage retain the entity event, the oldest dropped when the log full.
+func (r *Runtime) logMessage(event v1.Event) {
+	bytes, err := v1.Marshal(event)
+	if nil != err {
+		log.L().Warn("log message, encode event", logf.Eid(event.Entity()),
+			logf.ID(event.ID()), logf.Error(err))
+		return
+	}
+
+	r.slock.Lock()
+	defer r.slock.Unlock()
+	if r.messageLogs == nil {
+		r.messageLogs = make(map[string][]MessageContext)
+	}
+
+	msgs := r.messageLogs[event.Entity()]
+	if len(msgs) >= messageLogSize {
+		msgs = append(msgs[:0], msgs[len(msgs)-messageLogSize+1:]...)
+	}
+	r.messageLogs[event.Entity()] = append(msgs, MessageContext{
+		EventID:   event.ID(),
+		EntityID:  event.Entity(),
+		TraceID:   event.Attr(v1.MetaTraceID),
+		Timestamp: time.Now().UnixNano() / 1e6,
+		Event:     bytes,
+	})
+}
+
+// forgetMessages evict messages logged of the entity deleted.
+func (r *Runtime) forgetMessages(entityID string) {
+	r.slock.Lock()
+	defer r.slock.Unlock()
+	delete(r.messageLogs, entityID)
+}
+
+// ReplayMessages returns the last n messages processed for the entity, oldest first, all retained if n <= 0.
+func (r *Runtime) ReplayMessages(entityID string, n int) ([]MessageContext, bool) {
+	r.slock.RLock()
+	defer r.slock.RUnlock()
+	msgs, ok := r.messageLogs[entityID]
+	if !ok {
+		return nil, false
+	}
+
+	if n > 0 && n < len(msgs) {
+		msgs = msgs[len(msgs)-n:]
+	}
+	return append([]MessageContext{}, msgs...), true
+}
+
+// Replay re-apply messages to a shadow copy of the entity, the entity itself is left untouched.
+func (r *Runtime) Replay(ctx context.Context, entityID string, msgs []MessageContext) (Entity, error) {
+	shadow, err := r.shadowOf(ctx, entityID)
+	if nil != err {
+		return nil, errors.Wrap(err, "replay messages")
+	}
+
+	for _, msg := range msgs {
+		if err = replayMessage(ctx, shadow, msg); nil != err {
+			return shadow, err
+		}
+	}
+	return shadow, nil
+}
+
+// replayMessage apply the message to the shadow entity, messages other than patches skipped.
+func replayMessage(ctx context.Context, shadow Entity, msg MessageContext) error {
+	var ev v1.ProtoEvent
+	if err := v1.Unmarshal(msg.Event, &ev); nil != err {
+		return errors.Wrapf(err, "replay message %s, decode event", msg.EventID)
+	}
+
+	// replayed on the current state, skip version check.
+	ev.SetAttr(v1.MetaVersion, "")
+	e, _ := v1.Event(&ev).(v1.PatchEvent)
+	if e == nil {
+		return nil
+	}
+
+	feed := shadow.Handle(ctx, &Feed{
+		Event:    &ev,
+		State:    shadow.Raw(),
+		EntityID: shadow.ID(),
+		Patches:  conv(e.Patches()),
+	})
+	return errors.Wrapf(feed.Err, "replay message %s", msg.EventID)
+}
+
+// ReplayMessages returns the last n messages processed for the entity by runtimes of the node.
+func (n *Node) ReplayMessages(ctx context.Context, entityID string, count int) ([]MessageContext, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	for _, rt := range n.runtimes {
+		if msgs, ok := rt.ReplayMessages(entityID, count); ok {
+			return msgs, nil
+		}
+	}
+	return nil, errors.Wrap(xerrors.ErrEntityNotFound, "replay messages")
+}
+
+// Replay re-apply messages to a shadow copy of the entity on the runtime it placed on.
+func (n *Node) Replay(ctx context.Context, entityID string, msgs []MessageContext) (Entity, error) {
+	n.lock.RLock()
+	rt, ok := n.runtimes[placement.Global().Select(entityID).ID]
+	n.lock.RUnlock()
+	if !ok {
+		return nil, errors.Wrap(xerrors.ErrRuntimeNotExists, "replay messages")
+	}
+	return rt.Replay(ctx, entityID, msgs)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,6 +15,7 @@ import (
 	"github.com/Shopify/sarama"
 	"github.com/pkg/errors"
 	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
 	"github.com/tkeel-io/core/pkg/dispatch"
 	xerrors "github.com/tkeel-io/core/pkg/errors"
 	logf "github.com/tkeel-io/core/pkg/logfield"
@@ -26,6 +28,7 @@ import (
 	"github.com/tkeel-io/core/pkg/util/path"
 	"github.com/tkeel-io/kit/log"
 	"github.com/tkeel-io/tdtl"
+	"go.uber.org/atomic"
 )
 
 const (
@@ -33,12 +36,19 @@ const (
 	rawDataTelemetryType = "telemetry"
 )
 
+// drainInterval interval polling pending messages while draining.
+const drainInterval = 10 * time.Millisecond
+
 type EntityResourceFunc func(context.Context, Entity, *Feed) error
 
+type EntityRecoverFunc func(context.Context, string) ([]byte, error)
+
 type EntityResource struct {
 	PersistentEntity EntityResourceFunc
 	FlushHandler     EntityResourceFunc
 	RemoveHandler    EntityResourceFunc
+	// RecoverHandler reconstruct entity state missing in state storage, optional.
+	RecoverHandler EntityRecoverFunc
 }
 
 type Runtime struct {
@@ -53,8 +63,38 @@ type Runtime struct {
 	entityResourcer EntityResource
 	// map[entityID][SubscriptionID]Subscription
 	entitySubscriptions map[string]map[string]*repository.Subscription
+	// map[entityType][SubscriptionID]Subscription
+	typeSubscriptions map[string]map[string]*repository.Subscription
 	msgs                chan sarama.ConsumerMessage
-
+	// messages received and not handled yet, queued or being handled.
+	inflight atomic.Int64
+	// draining set once draining started, messages no longer received.
+	draining atomic.Bool
+	// map[entityID]EntityStats
+	stats map[string]*EntityStats
+	// map[entityID]recent messages, bounded.
+	messageLogs map[string][]MessageContext
+	// map[entityID/path][expressionID], expressions writing the path of entity.
+	exprWrites map[string]map[string]struct{}
+	// sink of events failed to apply.
+	deadLetterSink DeadLetterSink
+	// batch subscription notifications, nil if disabled.
+	batcher *changeBatcher
+	// publish subscription messages, publishSubData if nil.
+	publish publishFunc
+	// at-least-once deliveries retried in the background.
+	retries deliveryRetries
+	// order messages of entities, nil if disabled.
+	sequencer *sequencer
+	// requests flushing messages buffered by sequencer, handled on the event loop.
+	flushes chan chan struct{}
+	// record last writer of properties.
+	trackWriters bool
+	// detect offline entities, nil if disabled.
+	liveness *livenessTracker
+
+	slock  sync.RWMutex
+	tlock  sync.RWMutex
 	mlock  sync.RWMutex
 	lock   sync.RWMutex
 	ctx    context.Context
@@ -69,6 +109,13 @@ func NewRuntime(ctx context.Context, ercFuncs EntityResource, id string, dispatc
 		entities:            map[string]Entity{},
 		expressions:         map[string]ExpressionInfo{},
 		entitySubscriptions: make(map[string]map[string]*repository.Subscription),
+		typeSubscriptions:   make(map[string]map[string]*repository.Subscription),
+		stats:               make(map[string]*EntityStats),
+		deadLetterSink:      NewDeadLetterSink(config.Get().DeadLetter),
+		publish:             publishSubData,
+		sequencer:           sequencerFrom(config.Get().Ordering),
+		trackWriters:        config.Get().Writers.Enabled,
+		liveness:            livenessTrackerFrom(config.Get().Liveness),
 		entityResourcer:     ercFuncs,
 		dispatcher:          dispatcher,
 		repository:          repo,
@@ -79,8 +126,13 @@ func NewRuntime(ctx context.Context, ercFuncs EntityResource, id string, dispatc
 		cancel:              cancel,
 		ctx:                 ctx,
 		msgs:                make(chan sarama.ConsumerMessage, 10),
+		flushes:             make(chan chan struct{}),
 	}
+	runtime.batcher = changeBatcherFrom(config.Get().Notify, runtime.deliver)
 	go runtime.deliveredEvent()
+	if runtime.liveness != nil {
+		go runtime.runLivenessDetector()
+	}
 	return &runtime
 }
 
@@ -88,22 +140,105 @@ func (r *Runtime) ID() string {
 	return r.id
 }
 
-func (r *Runtime) DeliveredEvent(ctx context.Context, msg *sarama.ConsumerMessage) {
+// Stop runtime, pending batched notifications are published.
+func (r *Runtime) Stop() {
+	r.cancel()
+	if r.batcher != nil {
+		r.batcher.flushAll()
+	}
+}
+
+// Drain wait messages received handled, including the message being handled, flush messages
+// buffered for ordering, then publish pending batched notifications and wait delivery retries pending.
+// messages buffered for ordering lost if the process exits without drain.
+func (r *Runtime) Drain(ctx context.Context) error {
+	r.draining.Store(true)
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for r.inflight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "drain runtime %s, %d messages pending", r.id, r.inflight.Load())
+		case <-ticker.C:
+		}
+	}
+
+	if r.sequencer != nil {
+		done := make(chan struct{})
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "drain runtime %s, flush ordering buffers", r.id)
+		case r.flushes <- done:
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "drain runtime %s, flush ordering buffers", r.id)
+		case <-done:
+		}
+	}
+
+	if r.batcher != nil {
+		r.batcher.flushAll()
+	}
+	return errors.Wrapf(r.retries.wait(ctx), "drain runtime %s", r.id)
+}
+
+// DeliveredEvent queue the message, rejected once draining started.
+func (r *Runtime) DeliveredEvent(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	// counted before checking draining, so that Drain never misses a message accepted.
+	if r.inflight.Inc(); r.draining.Load() {
+		r.inflight.Dec()
+		return errors.Wrapf(xerrors.ErrServerNotReady, "runtime %s draining", r.id)
+	}
+
 	r.msgs <- *msg
+	return nil
 }
 
+// deliveredEvent is the event loop, messages buffered by sequencer expired and flushed on it too.
 func (r *Runtime) deliveredEvent() {
-	for msg := range r.msgs {
-		var err error
-		var ev v1.ProtoEvent
-		if err = v1.Unmarshal(msg.Value, &ev); nil != err {
-			log.L().Error("decode Event", logf.Error(err),
-				logf.Message(string(msg.Value)), logf.RID(r.id))
-			continue
+	var expires <-chan time.Time
+	if r.sequencer != nil {
+		ticker := time.NewTicker(r.sequencer.waitTimeout)
+		defer ticker.Stop()
+		expires = ticker.C
+	}
+
+	for {
+		select {
+		case msg, ok := <-r.msgs:
+			if !ok {
+				return
+			}
+			r.deliverMessage(msg)
+			r.inflight.Dec()
+		case now := <-expires:
+			for _, ev := range r.sequencer.expire(now) {
+				r.handleEvent(context.Background(), ev)
+			}
+		case done := <-r.flushes:
+			for _, ev := range r.sequencer.flushAll() {
+				r.handleEvent(context.Background(), ev)
+			}
+			close(done)
 		}
+	}
+}
 
-		r.HandleEvent(context.Background(), &ev)
+func (r *Runtime) deliverMessage(msg sarama.ConsumerMessage) {
+	var ev v1.ProtoEvent
+	if err := v1.Unmarshal(msg.Value, &ev); nil != err {
+		log.L().Error("decode Event", logf.Error(err),
+			logf.Message(string(msg.Value)), logf.RID(r.id))
+		r.deadLetter(context.Background(), &DeadLetter{
+			Stage:   DeadLetterStageDecode,
+			Reason:  err.Error(),
+			Payload: msg.Value,
+		})
+		return
 	}
+
+	r.HandleEvent(context.Background(), &ev)
 }
 
 type FeedLog struct {
@@ -111,9 +246,31 @@ type FeedLog struct {
 	New *Feed
 }
 
+// HandleEvent handle the event, events of ordered entities handled in sequence order.
 func (r *Runtime) HandleEvent(ctx context.Context, event v1.Event) error {
+	ready, stale := r.sequencer.admit(event)
+	for _, ev := range stale {
+		r.dropStale(ctx, ev)
+	}
+	for _, ev := range ready {
+		r.handleEvent(ctx, ev)
+	}
+	return nil
+}
+
+func (r *Runtime) handleEvent(ctx context.Context, event v1.Event) {
+	traceID := traceIDOf(event)
+	ctx = types.WithTraceID(ctx, traceID)
 	log.L().Debug("handle event", logf.RID(r.id),
-		logf.Event(event), logf.EvID(event.ID()))
+		logf.Event(event), logf.EvID(event.ID()), logf.TraceID(traceID))
+
+	if event.Type() == v1.ETEntity {
+		// only messages ingested from topics are device activity, not writes of api, mirrors or liveness.
+		if r.recordMessage(event.Entity(), event.Attr(v1.MetaTopic) != "") && r.liveness != nil {
+			r.queueOnline(event.Entity())
+		}
+		r.logMessage(event)
+	}
 
 	execer, feed := r.PrepareEvent(ctx, event)
 	newFeed := execer.Exec(ctx, feed)
@@ -121,8 +278,19 @@ func (r *Runtime) HandleEvent(ctx context.Context, event v1.Event) error {
 	// call callback once.
 	r.handleCallback(ctx, newFeed)
 	if nil != newFeed.Err {
-		log.Error("handle event", logf.Error(newFeed.Err),
+		log.Error("handle event", logf.Error(newFeed.Err), logf.TraceID(traceID),
 			logf.ID(event.ID()), logf.Eid(event.Entity()), logf.Event(event))
+		if event.Type() == v1.ETEntity {
+			// property message failed to apply.
+			bytes, _ := v1.Marshal(event)
+			r.deadLetter(ctx, &DeadLetter{
+				EventID:  event.ID(),
+				EntityID: event.Entity(),
+				Stage:    DeadLetterStageHandle,
+				Reason:   newFeed.Err.Error(),
+				Event:    bytes,
+			})
+		}
 	}
 
 	byt, err := json.Marshal(FeedLog{feed, newFeed})
@@ -131,13 +299,32 @@ func (r *Runtime) HandleEvent(ctx context.Context, event v1.Event) error {
 			logf.ID(event.ID()), logf.Eid(event.Entity()), logf.Event(event))
 	}
 	r.dispatcher.DispatchToLog(ctx, byt)
+}
 
-	return nil
+// dropStale drop message of sequence number already applied or skipped, the sender notified.
+func (r *Runtime) dropStale(ctx context.Context, event v1.Event) {
+	log.L().Warn("drop out of order message", logf.RID(r.id), logf.ID(event.ID()),
+		logf.Eid(event.Entity()), logf.String("seq", event.Attr(v1.MetaSequence)))
+	r.handleCallback(ctx, &Feed{
+		Event:    event,
+		EntityID: event.Entity(),
+		Err:      xerrors.ErrMessageOutOfOrder,
+	})
+}
+
+// traceIDOf returns correlation id of the event, generate one if absent.
+func traceIDOf(ev v1.Event) string {
+	traceID := ev.Attr(v1.MetaTraceID)
+	if traceID == "" {
+		traceID = util.IG().TraceID()
+		ev.SetAttr(v1.MetaTraceID, traceID)
+	}
+	return traceID
 }
 
 func (r *Runtime) PrepareEvent(ctx context.Context, ev v1.Event) (*Execer, *Feed) {
-	log.L().Info("prepare event", logf.RID(r.id),
-		logf.ID(ev.ID()), logf.Eid(ev.Entity()))
+	log.L().Info("prepare event", logf.RID(r.id), logf.ID(ev.ID()),
+		logf.Eid(ev.Entity()), logf.TraceID(ev.Attr(v1.MetaTraceID)))
 
 	switch ev.Type() {
 	case v1.ETSystem:
@@ -203,6 +390,9 @@ func (r *Runtime) handleEntityEvent(ev v1.Event) (*Execer, *Feed) {
 		state: entity,
 		preFuncs: []Handler{
 			&handlerImpl{fn: r.handleRawData},
+			&handlerImpl{fn: r.handleNamespace},
+			&handlerImpl{fn: r.handleSchema},
+			&handlerImpl{fn: r.handleDeadband},
 		}, // 新增了 Patches
 		execFunc: entity,
 		postFuncs: []Handler{
@@ -321,11 +511,22 @@ func (r *Runtime) prepareSystemEvent(ctx context.Context, event v1.Event) (*Exec
 		state, err := r.LoadEntity(ev.Entity())
 		if nil != err {
 			state = DefaultEntity(ev.Entity())
-			if errors.Is(err, xerrors.ErrEntityNotFound) {
-				// TODO: if entity not exists.
+			if xerrors.IsEntityNotFound(err) {
+				// entity gone from state, still remove leftovers of interrupted deletes.
 				return &Execer{
 						state:    state,
 						execFunc: state,
+						preFuncs: []Handler{
+							&handlerImpl{fn: func(ctx context.Context, feed *Feed) *Feed {
+								if innerErr := r.entityResourcer.RemoveHandler(ctx, state, feed); nil != innerErr {
+									log.L().Warn("delete entity not found, remove leftovers", logf.Eid(ev.Entity()),
+										logf.Error(innerErr), logf.ID(ev.ID()))
+								}
+								r.forgetStats(ev.Entity())
+								r.forgetMessages(ev.Entity())
+								return feed
+							}},
+						},
 					}, &Feed{
 						Event:    ev,
 						State:    state.Raw(),
@@ -350,6 +551,8 @@ func (r *Runtime) prepareSystemEvent(ctx context.Context, event v1.Event) (*Exec
 
 					// remove entity from runtime.
 					delete(r.entities, state.ID())
+					r.forgetStats(state.ID())
+					r.forgetMessages(state.ID())
 
 					return feed
 				}},
@@ -370,6 +573,42 @@ func (r *Runtime) prepareSystemEvent(ctx context.Context, event v1.Event) (*Exec
 			State:    state.Raw(),
 			EntityID: ev.Entity(),
 		}
+	case v1.OpReload:
+		// load entity into runtime, merge states committed by transactions into the cached
+		// state, runtime writes not flushed yet kept, changes propagated like patches.
+		var pds []*v1.PatchData
+		state, err := r.LoadEntity(ev.Entity())
+		if nil != err {
+			log.L().Error("reload entity", logf.Eid(ev.Entity()),
+				logf.ID(ev.ID()), logf.Error(err))
+			state = DefaultEntity(ev.Entity())
+		} else if len(action.GetData()) > 0 {
+			if err = json.Unmarshal(action.GetData(), &pds); nil != err {
+				log.L().Error("reload entity, decode patches", logf.Eid(ev.Entity()),
+					logf.ID(ev.ID()), logf.Error(err))
+			}
+		}
+
+		execer := &Execer{
+			state:    state,
+			execFunc: state,
+		}
+		if len(pds) > 0 {
+			execer.postFuncs = []Handler{
+				&handlerImpl{fn: r.handleTentacle},
+				&handlerImpl{fn: r.handleComputed},
+				&handlerImpl{fn: r.handlePersistent},
+				&handlerImpl{fn: r.handleSubscribe},
+				&handlerImpl{fn: r.handleTemplate},
+			}
+		}
+		return execer, &Feed{
+			Err:      err,
+			Event:    ev,
+			State:    state.Raw(),
+			EntityID: ev.Entity(),
+			Patches:  conv(pds),
+		}
 	default:
 		return &Execer{
 				state:    DefaultEntity(ev.Entity()),
@@ -391,23 +630,30 @@ func (r *Runtime) prepareSystemEvent(ctx context.Context, event v1.Event) (*Exec
 }
 
 func (r *Runtime) handleComputed(ctx context.Context, feed *Feed) *Feed {
-	log.L().Debug("handle computed", logf.Eid(feed.EntityID))
+	log.L().Debug("handle computed", logf.Eid(feed.EntityID), logf.TraceID(types.TraceIDFrom(ctx)))
 	// 1. 检查 ret.path 和 订阅列表.
 	entityID := feed.EntityID
+	// changes of frozen entity not propagated.
+	if r.entityFrozen(ctx, entityID) {
+		log.L().Debug("handle computed, entity frozen", logf.Eid(entityID))
+		return feed
+	}
+
 	expressions := make(map[string]ExpressionInfo)
 	for _, change := range feed.Changes {
 		for _, node := range r.evalTree.
 			MatchPrefix(path.FmtWatchKey(entityID, change.Path)) {
 			evalEnd, _ := node.(*EvalEndpoint)
-			if expr, has := r.getExpr(evalEnd.expresionID); has {
+			if expr, has := r.getExpr(evalEnd.expresionID); has && !expr.Disabled {
 				expressions[expr.ID] = expr
 			}
 		}
 	}
 
 	patches := make(map[string][]*v1.PatchData)
-	for id, expr := range expressions {
-		target := expr.EntityID
+	writers := make(map[string][]ExpressionInfo)
+	for _, expr := range sortExpressions(expressions) {
+		id, target := expr.ID, expr.EntityID
 
 		// TODO: 当收到 ETEntity 类型的事件，事件不应该触发不属于自己的 Expression.
 		if feed.Event == nil {
@@ -416,11 +662,24 @@ func (r *Runtime) handleComputed(ctx context.Context, feed *Feed) *Feed {
 		if target != feed.EntityID && v1.ETEntity == feed.Event.Type() {
 			continue
 		}
+		if r.entityFrozen(ctx, target) {
+			log.L().Debug("eval expression, target entity frozen",
+				logf.Eid(target), logf.Mid(id))
+			continue
+		}
+		if r.shadowed(expr) {
+			log.L().Debug("eval expression, shadowed by higher priority",
+				logf.Eid(target), logf.Mid(id), logf.Path(expr.Path))
+			continue
+		}
 
 		log.L().Debug("eval expression",
 			logf.Eid(entityID), logf.Mid(id),
 			logf.Expr(expr.Expression.Expression))
+		r.recordEval(target)
+		evalStart := time.Now()
 		result, err := r.evalExpression(ctx, expr.Expression)
+		r.observeEval(target, expr.Name, evalOutcome(result, err), time.Since(evalStart))
 		if nil != err {
 			log.L().Error("eval expression",
 				logf.Eid(entityID), logf.Mid(id),
@@ -440,19 +699,29 @@ func (r *Runtime) handleComputed(ctx context.Context, feed *Feed) *Feed {
 				Path:     expr.Expression.Path,
 				Value:    result.Raw(),
 			})
+		writers[target] = append(writers[target], expr)
+		if flag := r.frozenSourcesPatch(ctx, expr); flag != nil {
+			log.L().Warn("eval expression, derived from frozen entities",
+				logf.Eid(target), logf.Mid(id), logf.Value(string(flag.Value)))
+			patches[target] = append(patches[target], flag)
+		}
 	}
 
 	// 2. dispatch.send()
 	for target, patch := range patches {
+		metadata := map[string]string{
+			v1.MetaType:        string(v1.ETEntity),
+			v1.MetaBorn:        "handleComputed",
+			v1.MetaPartitionID: r.ID(),
+			v1.MetaEntityID:    target,
+		}
+		if r.trackWriters {
+			metadata[v1.MetaWriter] = mapperWriter(writers[target])
+		}
 		r.dispatcher.Dispatch(ctx, &v1.ProtoEvent{
 			Id:        util.IG().EvID(),
 			Timestamp: time.Now().UnixNano(),
-			Metadata: map[string]string{
-				v1.MetaType:        string(v1.ETEntity),
-				v1.MetaBorn:        "handleComputed",
-				v1.MetaPartitionID: r.ID(),
-				v1.MetaEntityID:    target,
-			},
+			Metadata:  metadata,
 			Data: &v1.ProtoEvent_Patches{
 				Patches: &v1.PatchDatas{
 					Patches: patch,
@@ -464,6 +733,23 @@ func (r *Runtime) handleComputed(ctx context.Context, feed *Feed) *Feed {
 	return feed
 }
 
+// sortExpressions order expressions by priority ascending, so that patches of
+// the higher priority applied later and win, equal priorities tie-break by expression id.
+func sortExpressions(expressions map[string]ExpressionInfo) []ExpressionInfo {
+	exprs := make([]ExpressionInfo, 0, len(expressions))
+	for _, expr := range expressions {
+		exprs = append(exprs, expr)
+	}
+
+	sort.Slice(exprs, func(i, j int) bool {
+		if exprs[i].Priority != exprs[j].Priority {
+			return exprs[i].Priority < exprs[j].Priority
+		}
+		return exprs[i].ID < exprs[j].ID
+	})
+	return exprs
+}
+
 func (r *Runtime) evalExpression(ctx context.Context, expr repository.Expression) (tdtl.Node, error) {
 	var (
 		err      error
@@ -543,7 +829,7 @@ func whichPrefix(targetPath, changePath string) string {
 }
 
 func (r *Runtime) handleTentacle(ctx context.Context, feed *Feed) *Feed {
-	log.L().Debug("handle tentacle", logf.Eid(feed.EntityID),
+	log.L().Debug("handle tentacle", logf.Eid(feed.EntityID), logf.TraceID(types.TraceIDFrom(ctx)),
 		logf.Any("changes", feed.Changes), logf.String("state", string(feed.State)))
 
 	// 1. 检查 ret.path 和 订阅列表.
@@ -639,6 +925,10 @@ func (r *Runtime) handleCallback(ctx context.Context, feed *Feed) error {
 			ev.SetType(v1.ETCallback)
 			ev.SetAttr(v1.MetaBorn, "handleCallback")
 			ev.SetAttr(v1.MetaResponseStatus, string(types.StatusOK))
+			if len(feed.Conflicts) > 0 {
+				conflicts, _ := json.Marshal(feed.Conflicts)
+				ev.SetAttr(v1.MetaMergeConflicts, string(conflicts))
+			}
 			err = r.dispatcher.Dispatch(ctx, ev)
 		} else {
 			ev := &v1.ProtoEvent{
@@ -667,7 +957,7 @@ func (r *Runtime) handleCallback(ctx context.Context, feed *Feed) error {
 }
 
 func (r *Runtime) handlePersistent(ctx context.Context, feed *Feed) *Feed {
-	log.L().Debug("handle persistent", logf.Eid(feed.EntityID))
+	log.L().Debug("handle persistent", logf.Eid(feed.EntityID), logf.TraceID(types.TraceIDFrom(ctx)))
 	en, ok := r.entities[feed.EntityID]
 	if !ok {
 		// entity has been deleted.
@@ -678,7 +968,7 @@ func (r *Runtime) handlePersistent(ctx context.Context, feed *Feed) *Feed {
 }
 
 func (r *Runtime) handleFlush(ctx context.Context, feed *Feed) *Feed {
-	log.L().Debug("handler flush", logf.Eid(feed.EntityID))
+	log.L().Debug("handler flush", logf.Eid(feed.EntityID), logf.TraceID(types.TraceIDFrom(ctx)))
 	if err := r.entityResourcer.FlushHandler(ctx, nil, nil); err != nil {
 		log.L().Error("handler flush error", logf.Error(err))
 	}
@@ -686,7 +976,7 @@ func (r *Runtime) handleFlush(ctx context.Context, feed *Feed) *Feed {
 }
 
 func (r *Runtime) handleTemplate(ctx context.Context, feed *Feed) *Feed {
-	log.L().Debug("handle template", logf.Eid(feed.EntityID))
+	log.L().Debug("handle template", logf.Eid(feed.EntityID), logf.TraceID(types.TraceIDFrom(ctx)))
 	for index := range feed.Changes {
 		if FieldTemplate == feed.Changes[index].Path {
 			log.Info("entity template changed", logf.Eid(feed.EntityID),
@@ -909,7 +1199,7 @@ func adjustGatewayTSData(bytes []byte, _ Entity) []byte {
 }
 
 func (r *Runtime) handleRawData(ctx context.Context, feed *Feed) *Feed {
-	log.L().Debug("handle RawData", logf.Eid(feed.EntityID))
+	log.L().Debug("handle RawData", logf.Eid(feed.EntityID), logf.TraceID(types.TraceIDFrom(ctx)))
 
 	// match properties.rawData.
 	for _, patch := range feed.Patches {
@@ -1009,11 +1299,18 @@ func (r *Runtime) RemoveExpression(exprID string) {
 		for _, item := range exprInfo.evalEndpoints {
 			r.evalTree.Remove(item.WildcardPath(), &item)
 		}
+
+		r.delExpr(exprID)
 	}
 }
 
 func (r *Runtime) initializeExpression(ctx context.Context, expr ExpressionInfo) {
-	if mapper.VersionInited != expr.version {
+	if mapper.VersionInited != expr.version || expr.Disabled || r.shadowed(expr) {
+		return
+	}
+	if r.entityFrozen(ctx, expr.EntityID) {
+		log.L().Debug("initialize expression, entity frozen",
+			logf.Eid(expr.EntityID), logf.ID(expr.ID))
 		return
 	}
 
@@ -1128,6 +1425,11 @@ func (r *Runtime) LoadEntity(id string) (Entity, error) {
 
 	// load from state storage.
 	jsonData, err := r.repository.GetEntity(context.TODO(), id)
+	if nil != err && r.entityResourcer.RecoverHandler != nil &&
+		xerrors.IsEntityNotFound(err) {
+		jsonData, err = r.entityResourcer.RecoverHandler(context.TODO(), id)
+	}
+
 	if nil != err {
 		log.L().Warn("load entity from state storage",
 			logf.Eid(id), logf.Reason(err.Error()))
@@ -1174,4 +1476,46 @@ func (r *Runtime) setExpr(exprInfo ExpressionInfo) {
 	r.mlock.Lock()
 	defer r.mlock.Unlock()
 	r.expressions[exprInfo.ID] = exprInfo
+
+	key := exprWritesKey(exprInfo.EntityID, exprInfo.Path)
+	if r.exprWrites == nil {
+		r.exprWrites = make(map[string]map[string]struct{})
+	}
+	if r.exprWrites[key] == nil {
+		r.exprWrites[key] = make(map[string]struct{})
+	}
+	r.exprWrites[key][exprInfo.ID] = struct{}{}
+}
+
+func (r *Runtime) delExpr(id string) {
+	r.mlock.Lock()
+	defer r.mlock.Unlock()
+	exprInfo, has := r.expressions[id]
+	if !has {
+		return
+	}
+
+	delete(r.expressions, id)
+	key := exprWritesKey(exprInfo.EntityID, exprInfo.Path)
+	if delete(r.exprWrites[key], id); len(r.exprWrites[key]) == 0 {
+		delete(r.exprWrites, key)
+	}
+}
+
+// shadowed reports whether an enabled expression outranks expr writing the same path of the entity,
+// so that mappers writing the same property resolved by priority whatever triggers the evaluation.
+func (r *Runtime) shadowed(expr ExpressionInfo) bool {
+	r.mlock.RLock()
+	defer r.mlock.RUnlock()
+	for id := range r.exprWrites[exprWritesKey(expr.EntityID, expr.Path)] {
+		other := r.expressions[id]
+		if id != expr.ID && !other.Disabled && other.Outranks(&expr.Expression) {
+			return true
+		}
+	}
+	return false
+}
+
+func exprWritesKey(entityID, path string) string {
+	return entityID + "/" + path
 }
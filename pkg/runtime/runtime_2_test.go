@@ -212,7 +212,7 @@ func Test_merge(t *testing.T) {
 		for i := 0; i < 100; i++ {
 			// cc1 := cc.Copy()
 			cc := entityCopy(e)
-			err := merge(cc, patch, e, feed)
+			err := merge(cc, &patch, e, feed)
 			if err != nil {
 				t.Log(err)
 			}
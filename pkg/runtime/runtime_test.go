@@ -20,18 +20,27 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"strconv"
 	"testing"
 	"time"
 
 	_ "github.com/ClickHouse/clickhouse-go/v2"
+	_ "github.com/tkeel-io/core/pkg/resource/store/memory"
 	_ "github.com/tkeel-io/core/pkg/resource/tseries/builder"
 
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	v1 "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/metrics"
 	"github.com/tkeel-io/core/pkg/placement"
 	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/repository/dao"
 	"github.com/tkeel-io/core/pkg/resource"
 	"github.com/tkeel-io/core/pkg/resource/tseries"
+	"github.com/tkeel-io/core/pkg/types"
 	tkeelJson "github.com/tkeel-io/core/pkg/util/json"
 	"github.com/tkeel-io/core/pkg/util/path"
 	"github.com/tkeel-io/tdtl"
@@ -702,6 +711,90 @@ func TestRuntime_AppendExpression(t *testing.T) {
 	t.Log(rt)
 }
 
+func TestRuntime_mapperPriority(t *testing.T) {
+	placement.Initialize()
+	placement.Global().Append(placement.Info{
+		ID:   "core/1234",
+		Flag: true,
+	})
+
+	recorder := &eventRecorder{}
+	entities := make(map[string]Entity)
+	for id, temp := range map[string]string{"device123": "0", "device234": "20", "device345": "30"} {
+		en, err := NewEntity(id, []byte(`{"properties":{"temp":`+temp+`}}`))
+		assert.Nil(t, err)
+		entities[id] = en
+	}
+
+	rt := &Runtime{
+		dispatcher:  recorder,
+		entities:    entities,
+		enCache:     NewCacheMock(entities),
+		expressions: map[string]ExpressionInfo{},
+		subTree:     path.NewRefTree(),
+		evalTree:    path.New(),
+	}
+
+	// two mappers write properties.temp of device123.
+	low := repository.NewExpression("admin", "device123", "mapper-low", "properties.temp", "device234.properties.temp", "")
+	high := repository.NewExpression("admin", "device123", "mapper-high", "properties.temp", "device345.properties.temp", "")
+	high.Priority = 10
+	assert.NotEqual(t, low.ID, high.ID)
+
+	appendExpr := func(expr repository.Expression) {
+		exprInfos, err := parseExpression(expr, 1)
+		assert.Nil(t, err)
+		for _, exprInfo := range exprInfos {
+			rt.AppendExpression(*exprInfo)
+		}
+	}
+	computed := func(entityID string) []string {
+		recorder.events = nil
+		rt.handleComputed(context.Background(), &Feed{
+			EntityID: entityID,
+			Event:    &v1.ProtoEvent{},
+			Changes:  []Patch{{Op: tkeelJson.OpReplace, Path: "properties.temp"}},
+		})
+
+		var values []string
+		for _, ev := range recorder.events {
+			for _, patch := range ev.(*v1.ProtoEvent).GetPatches().Patches {
+				values = append(values, string(patch.Value))
+			}
+		}
+		return values
+	}
+
+	appendExpr(*low)
+	appendExpr(*high)
+
+	// the lower priority shadowed whatever triggers the evaluation.
+	assert.Empty(t, computed("device234"))
+	assert.Equal(t, []string{"30"}, computed("device345"))
+
+	// higher priority disabled, the lower takes effect.
+	high.Disabled = true
+	appendExpr(*high)
+	assert.Equal(t, []string{"20"}, computed("device234"))
+
+	// equal priorities tie-break by the greater expression id.
+	high.Disabled, high.Priority = false, 0
+	appendExpr(*high)
+	winner, loser := "device234", "device345"
+	if high.ID > low.ID {
+		winner, loser = loser, winner
+	}
+	assert.Empty(t, computed(loser))
+	assert.NotEmpty(t, computed(winner))
+
+	// higher priority removed, the lower takes effect.
+	high.Priority = 10
+	appendExpr(*high)
+	rt.RemoveExpression(high.ID)
+	assert.Equal(t, []string{"20"}, computed("device234"))
+	assert.Empty(t, rt.exprWrites[exprWritesKey("device123", "properties.temp")][high.ID])
+}
+
 func Test_parsePayload(t *testing.T) {
 	t.Logf("It is now %s\n", time.Now())
 
@@ -764,3 +857,737 @@ func Test_adjustTSData1(t *testing.T) {
 		})
 	}
 }
+
+func TestRuntime_EntityStats(t *testing.T) {
+	rt := &Runtime{stats: make(map[string]*EntityStats)}
+	rt.recordMessage("device123", true)
+	rt.recordMessage("device123", true)
+	rt.recordEval("device123")
+
+	n := &Node{runtimes: map[string]*Runtime{"rt-1": rt}}
+	stats, err := n.GetEntityStats(context.Background(), "device123")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), stats.MessageCount)
+	assert.Equal(t, int64(1), stats.MapperEvalCount)
+	assert.True(t, stats.LastSeen > 0)
+
+	_, err = n.GetEntityStats(context.Background(), "device234")
+	assert.ErrorIs(t, err, xerrors.ErrEntityNotFound)
+}
+
+func TestRuntime_Liveness(t *testing.T) {
+	en, err := NewEntity("device123", []byte(`{"id":"device123","type":"sensor","properties":{}}`))
+	assert.Nil(t, err)
+	var published []*LivenessEvent
+	recorder := &eventRecorder{}
+	rt := &Runtime{
+		id:         "rt-1",
+		dispatcher: recorder,
+		entities:   map[string]Entity{"device123": en},
+		stats:      make(map[string]*EntityStats),
+		liveness: &livenessTracker{
+			threshold:  time.Minute,
+			thresholds: map[string]time.Duration{"sensor": 10 * time.Second},
+			publish: func(ctx context.Context, ev *LivenessEvent) error {
+				published = append(published, ev)
+				return nil
+			},
+		},
+	}
+	rt.recordMessage("device123", true)
+	rt.recordMessage("device234", true)
+
+	// device123 silent longer than threshold of sensor.
+	rt.detectOffline(context.Background(), time.Now().Add(30*time.Second))
+	assert.Len(t, published, 1)
+	assert.Equal(t, EventEntityOffline, published[0].Event)
+	assert.Equal(t, "sensor", published[0].EntityType)
+	assert.Len(t, recorder.events, 1)
+	ev, _ := recorder.events[0].(*v1.ProtoEvent)
+	assert.Equal(t, EventEntityOffline, ev.Attr(v1.MetaLiveness))
+	assert.Equal(t, "properties.online", ev.GetPatches().Patches[0].Path)
+	assert.Equal(t, []byte("false"), ev.GetPatches().Patches[0].Value)
+
+	n := &Node{runtimes: map[string]*Runtime{"rt-1": rt}}
+	liveness, err := n.GetLiveness(context.Background(), "device123")
+	assert.Nil(t, err)
+	assert.False(t, liveness.Online)
+	liveness, err = n.GetLiveness(context.Background(), "device234")
+	assert.Nil(t, err)
+	assert.True(t, liveness.Online)
+
+	// offline entities not marked again, the next message flips back online.
+	rt.detectOffline(context.Background(), time.Now().Add(30*time.Second))
+	assert.Len(t, published, 1)
+	assert.True(t, rt.recordMessage("device123", true))
+	assert.False(t, rt.recordMessage("device123", true))
+	liveness, _ = n.GetLiveness(context.Background(), "device123")
+	assert.True(t, liveness.Online)
+
+	// writes not ingested from topics are not device activity.
+	assert.False(t, rt.recordMessage("device345", false))
+	liveness, _ = n.GetLiveness(context.Background(), "device345")
+	assert.False(t, liveness.Online)
+	assert.Equal(t, int64(0), liveness.LastSeen)
+}
+
+func TestRuntime_LivenessSeeded(t *testing.T) {
+	var published []*LivenessEvent
+	rt := &Runtime{
+		id:         "rt-1",
+		dispatcher: &eventRecorder{},
+		stats:      make(map[string]*EntityStats),
+		liveness: &livenessTracker{
+			threshold: time.Minute,
+			publish: func(ctx context.Context, ev *LivenessEvent) error {
+				published = append(published, ev)
+				return nil
+			},
+			onlines: make(chan string, 1),
+		},
+	}
+
+	// entities persisted online but silent since started go offline after threshold.
+	now := time.Now()
+	rt.seedLiveness("device123", true, now)
+	rt.seedLiveness("device234", false, now)
+	rt.detectOffline(context.Background(), now.Add(2*time.Minute))
+	assert.Len(t, published, 1)
+	assert.Equal(t, "device123", published[0].EntityID)
+
+	// entities persisted offline flip online on the next message.
+	assert.True(t, rt.recordMessage("device234", true))
+
+	// entities seen meanwhile not overridden by seeding.
+	rt.seedLiveness("device234", false, now)
+	liveness, _ := rt.GetLiveness("device234")
+	assert.True(t, liveness.Online)
+
+	// online entities queued off the event loop, queue full marks the entity offline again to retry.
+	rt.queueOnline("device234")
+	rt.queueOnline("device345")
+	assert.Equal(t, "device234", <-rt.liveness.onlines)
+	assert.True(t, rt.recordMessage("device345", true))
+}
+
+func TestRuntime_ReplayMessages(t *testing.T) {
+	en, err := NewEntity("device123", []byte(`{"id":"device123","type":"sensor","properties":{"temp":10}}`))
+	assert.Nil(t, err)
+	rt := &Runtime{entities: map[string]Entity{"device123": en}}
+	patchEvent := func(id string, temp int) *v1.ProtoEvent {
+		return &v1.ProtoEvent{
+			Id:       id,
+			Metadata: map[string]string{v1.MetaEntityID: "device123", v1.MetaVersion: "7"},
+			Data: &v1.ProtoEvent_Patches{Patches: &v1.PatchDatas{Patches: []*v1.PatchData{{
+				Path:     "properties.temp",
+				Operator: "replace",
+				Value:    []byte(strconv.Itoa(temp)),
+			}}}},
+		}
+	}
+
+	// retained log bounded.
+	for i := 0; i < messageLogSize+3; i++ {
+		rt.logMessage(patchEvent(fmt.Sprintf("ev-%d", i), i))
+	}
+	msgs, ok := rt.ReplayMessages("device123", 0)
+	assert.True(t, ok)
+	assert.Len(t, msgs, messageLogSize)
+	assert.Equal(t, "ev-3", msgs[0].EventID)
+
+	msgs, _ = rt.ReplayMessages("device123", 2)
+	assert.Equal(t, []string{"ev-33", "ev-34"}, []string{msgs[0].EventID, msgs[1].EventID})
+	_, ok = rt.ReplayMessages("device234", 2)
+	assert.False(t, ok)
+
+	// replay on shadow copy.
+	shadow, err := rt.Replay(context.Background(), "device123", msgs)
+	assert.Nil(t, err)
+	assert.Equal(t, "34", shadow.Get("properties.temp").String())
+	assert.Equal(t, "10", en.Get("properties.temp").String())
+}
+
+func TestRuntime_SimulateMapper(t *testing.T) {
+	newEntity := func(id, state string) Entity {
+		en, err := NewEntity(id, []byte(state))
+		assert.Nil(t, err)
+		return en
+	}
+	device := newEntity("device123", `{"id":"device123","properties":{"temp":30}}`)
+	rt := &Runtime{
+		entities: map[string]Entity{"device123": device},
+		enCache:  NewCacheMock(map[string]Entity{"room123": newEntity("room123", `{"properties":{"offset":5}}`)}),
+	}
+	for i, temp := range []int{10, 20, 30} {
+		rt.logMessage(&v1.ProtoEvent{
+			Id:       fmt.Sprintf("ev-%d", i),
+			Metadata: map[string]string{v1.MetaEntityID: "device123"},
+			Data: &v1.ProtoEvent_Patches{Patches: &v1.PatchDatas{Patches: []*v1.PatchData{{
+				Path:     "properties.temp",
+				Operator: "replace",
+				Value:    []byte(strconv.Itoa(temp)),
+			}}}},
+		})
+	}
+
+	results, err := rt.SimulateMapper(context.Background(), "device123",
+		"insert into room123 select device123.properties.temp + room123.properties.offset as adjusted")
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+	for i, adjusted := range []float64{15, 25, 35} {
+		assert.Equal(t, fmt.Sprintf("ev-%d", i), results[i].EventID)
+		assert.Equal(t, map[string]interface{}{"adjusted": adjusted}, results[i].Output)
+	}
+	// nothing persisted.
+	assert.Equal(t, "30", device.Get("properties.temp").String())
+
+	_, err = rt.SimulateMapper(context.Background(), "device123", "insert into room123 select room234.properties.temp as temp")
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+	_, err = rt.SimulateMapper(context.Background(), "device234", "insert into room123 select device234.properties.temp as temp")
+	assert.ErrorIs(t, err, xerrors.ErrEntityNotFound)
+}
+
+func TestRuntime_frozenSources(t *testing.T) {
+	newEntity := func(id, state string) Entity {
+		en, err := NewEntity(id, []byte(state))
+		assert.Nil(t, err)
+		return en
+	}
+	rt := &Runtime{
+		entities: map[string]Entity{
+			"device123": newEntity("device123", `{"properties":{"temp":20},"frozen":true}`),
+			"device234": newEntity("device234", `{"properties":{"temp":30},"frozen":false}`),
+			"room123":   newEntity("room123", `{"properties":{}}`),
+		},
+		enCache: NewCacheMock(map[string]Entity{
+			"device345": newEntity("device345", `{"properties":{"temp":40},"frozen":true}`),
+		}),
+	}
+	expr := ExpressionInfo{
+		Expression: repository.Expression{EntityID: "room123", Path: "properties.avg"},
+		evalEndpoints: []EvalEndpoint{
+			newEvalEnd("device345.properties.temp", "room123", ""),
+			newEvalEnd("device234.properties.temp", "room123", ""),
+			newEvalEnd("device123.properties.temp", "room123", ""),
+			newEvalEnd("device123.properties.humidity", "room123", ""),
+		},
+	}
+
+	assert.True(t, rt.entityFrozen(context.Background(), "device123"))
+	assert.False(t, rt.entityFrozen(context.Background(), "device234"))
+	assert.Equal(t, []string{"device123", "device345"}, rt.frozenSources(context.Background(), expr))
+
+	flag := rt.frozenSourcesPatch(context.Background(), expr)
+	assert.Equal(t, "frozen_sources.properties.avg", flag.Path)
+	assert.JSONEq(t, `["device123","device345"]`, string(flag.Value))
+
+	// clear flag once sources unfrozen.
+	expr.evalEndpoints = expr.evalEndpoints[1:2]
+	assert.Nil(t, rt.frozenSourcesPatch(context.Background(), expr))
+	rt.entities["room123"] = newEntity("room123", `{"frozen_sources":{"properties":{"avg":["device123"]}}}`)
+	flag = rt.frozenSourcesPatch(context.Background(), expr)
+	assert.Equal(t, "remove", flag.Operator)
+}
+
+func TestRuntime_observeEval(t *testing.T) {
+	assert.Equal(t, metrics.EvalErrored, evalOutcome(nil, xerrors.ErrInvalidParam))
+	assert.Equal(t, metrics.EvalSkipped, evalOutcome(nil, nil))
+	assert.Equal(t, metrics.EvalFired, evalOutcome(tdtl.New(20), nil))
+
+	en, err := NewEntity("device123", []byte(`{"id":"device123","type":"sensor","properties":{}}`))
+	assert.Nil(t, err)
+	rt := &Runtime{entities: map[string]Entity{"device123": en}}
+	counter := metrics.CollectorMapperEvalCount.WithLabelValues("sensor", "mapper-temp", metrics.EvalErrored)
+	before := testutil.ToFloat64(counter)
+	rt.observeEval("device123", "mapper-temp", metrics.EvalErrored, time.Millisecond)
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+}
+
+func TestNode_GetRuntimeStatus(t *testing.T) {
+	n := &Node{runtimes: map[string]*Runtime{}}
+	_, err := n.GetRuntimeStatus(context.Background(), "device123")
+	assert.ErrorIs(t, err, xerrors.ErrRuntimeNotExists)
+
+	placement.Initialize()
+	placement.Global().Append(placement.Info{ID: "rt-1", Flag: true})
+	rt := &Runtime{
+		id:       "rt-1",
+		entities: map[string]Entity{"device123": DefaultEntity("device123")},
+		msgs:     make(chan sarama.ConsumerMessage, 10),
+	}
+	rt.msgs <- sarama.ConsumerMessage{}
+	n.runtimes["rt-1"] = rt
+
+	status, err := n.GetRuntimeStatus(context.Background(), "device123")
+	assert.Nil(t, err)
+	assert.Equal(t, RuntimeStatus{EntityID: "device123", RuntimeID: "rt-1", Local: true, Loaded: true, MailboxDepth: 1}, *status)
+
+	status, err = n.GetRuntimeStatus(context.Background(), "device234")
+	assert.Nil(t, err)
+	assert.False(t, status.Loaded)
+}
+
+func TestRuntime_Drain(t *testing.T) {
+	rt := &Runtime{id: "rt-1", msgs: make(chan sarama.ConsumerMessage, 10)}
+	assert.Nil(t, rt.DeliveredEvent(context.Background(), &sarama.ConsumerMessage{}))
+
+	// message dequeued and still being handled awaited.
+	<-rt.msgs
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, rt.Drain(timeoutCtx), context.DeadlineExceeded)
+
+	// messages rejected once draining, left unconsumed.
+	assert.ErrorIs(t, rt.DeliveredEvent(context.Background(), &sarama.ConsumerMessage{}), xerrors.ErrServerNotReady)
+
+	rt.inflight.Dec()
+	assert.Nil(t, rt.Drain(context.Background()))
+}
+
+type deadLetterFunc func(ctx context.Context, letter *DeadLetter) error
+
+func (f deadLetterFunc) Send(ctx context.Context, letter *DeadLetter) error {
+	return f(ctx, letter)
+}
+
+func TestRuntime_deadLetter(t *testing.T) {
+	assert.IsType(t, &logSink{}, NewDeadLetterSink(config.DeadLetterConfig{}))
+	assert.IsType(t, &pubsubSink{}, NewDeadLetterSink(config.DeadLetterConfig{
+		Sink: config.DeadLetterSinkPubsub, PubsubName: "core-pubsub", Topic: "core-dead-letter"}))
+
+	var letters []*DeadLetter
+	rt := &Runtime{id: "rt-1", deadLetterSink: deadLetterFunc(func(ctx context.Context, letter *DeadLetter) error {
+		letters = append(letters, letter)
+		return nil
+	})}
+
+	rt.deadLetter(context.Background(), &DeadLetter{Stage: DeadLetterStageDecode, Reason: "invalid event", Payload: []byte("{")})
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "rt-1", letters[0].RuntimeID)
+	assert.True(t, letters[0].Timestamp > 0)
+
+	// fallback to log sink.
+	rt.deadLetterSink = deadLetterFunc(func(ctx context.Context, letter *DeadLetter) error {
+		return xerrors.ErrConnectionNil
+	})
+	rt.deadLetter(context.Background(), &DeadLetter{Stage: DeadLetterStageHandle, Reason: "entity not found"})
+	rt.deadLetterSink = nil
+	rt.deadLetter(context.Background(), &DeadLetter{Stage: DeadLetterStageHandle, Reason: "entity not found"})
+}
+
+func Test_sequencer(t *testing.T) {
+	assert.Nil(t, sequencerFrom(config.OrderingConfig{}))
+
+	event := func(entityID string, seq int) v1.Event {
+		ev := &v1.ProtoEvent{Id: strconv.Itoa(seq), Metadata: map[string]string{}}
+		ev.SetType(v1.ETEntity)
+		ev.SetEntity(entityID)
+		ev.SetAttr(v1.MetaSequence, strconv.Itoa(seq))
+		return ev
+	}
+	ids := func(evs []v1.Event) []string {
+		var out []string
+		for _, ev := range evs {
+			out = append(out, ev.ID())
+		}
+		return out
+	}
+
+	s := sequencerFrom(config.OrderingConfig{Entities: []string{"device123"}, WaitTimeout: 60000, MaxPending: 2})
+	ready, stale := s.admit(event("device123", 1))
+	assert.Equal(t, []string{"1"}, ids(ready))
+	assert.Len(t, stale, 0)
+
+	// buffered until the gap filled.
+	ready, _ = s.admit(event("device123", 3))
+	assert.Len(t, ready, 0)
+	ready, _ = s.admit(event("device123", 2))
+	assert.Equal(t, []string{"2", "3"}, ids(ready))
+
+	// stale dropped.
+	ready, stale = s.admit(event("device123", 2))
+	assert.Len(t, ready, 0)
+	assert.Equal(t, []string{"2"}, ids(stale))
+
+	// gap skipped once max pending exceeded.
+	s.admit(event("device123", 5))
+	s.admit(event("device123", 6))
+	ready, _ = s.admit(event("device123", 7))
+	assert.Equal(t, []string{"5", "6", "7"}, ids(ready))
+	_, stale = s.admit(event("device123", 4))
+	assert.Equal(t, []string{"4"}, ids(stale))
+
+	// unordered entities applied on arrival.
+	ready, _ = s.admit(event("device234", 3))
+	assert.Equal(t, []string{"3"}, ids(ready))
+	ready, _ = s.admit(event("device234", 1))
+	assert.Equal(t, []string{"1"}, ids(ready))
+
+	// drop on gap.
+	s = sequencerFrom(config.OrderingConfig{Entities: []string{"*"}, OnGap: config.OrderingGapDrop})
+	s.admit(event("device234", 1))
+	ready, _ = s.admit(event("device234", 3))
+	assert.Equal(t, []string{"3"}, ids(ready))
+	_, stale = s.admit(event("device234", 2))
+	assert.Equal(t, []string{"2"}, ids(stale))
+
+	// gap skipped once waited longer than wait timeout, without later messages.
+	s = sequencerFrom(config.OrderingConfig{Entities: []string{"*"}, WaitTimeout: 60000})
+	s.admit(event("device123", 1))
+	s.admit(event("device123", 3))
+	s.admit(event("device123", 5))
+	assert.Len(t, s.expire(time.Now()), 0)
+	assert.Equal(t, []string{"3"}, ids(s.expire(time.Now().Add(time.Minute))))
+	assert.Equal(t, []string{"5"}, ids(s.expire(time.Now().Add(2*time.Minute))))
+
+	// idle states pruned.
+	s.expire(time.Now().Add(orderingStateTTL + time.Minute))
+	assert.NotContains(t, s.states, "device123")
+
+	// flush all buffered on drain.
+	s.admit(event("device123", 1))
+	s.admit(event("device123", 4))
+	s.admit(event("device123", 6))
+	s.admit(event("device234", 3))
+	s.admit(event("device234", 5))
+	flushed := ids(s.flushAll())
+	assert.Len(t, flushed, 3)
+	assert.Equal(t, []string{"4", "6"}, filterIDs(flushed, "4", "6"))
+	assert.Equal(t, 0, len(s.states["device123"].pending)+len(s.states["device234"].pending))
+}
+
+func filterIDs(ids []string, want ...string) []string {
+	var out []string
+	for _, id := range ids {
+		for _, w := range want {
+			if id == w {
+				out = append(out, id)
+			}
+		}
+	}
+	return out
+}
+
+func TestRuntime_typeSubscriptions(t *testing.T) {
+	rt := &Runtime{}
+	sub1 := &repository.Subscription{ID: "sub-1", Owner: "tenant01", SourceEntityType: "DEVICE"}
+	sub2 := &repository.Subscription{ID: "sub-2", Owner: "tenant01", SourceEntityType: "DEVICE"}
+	sub3 := &repository.Subscription{ID: "sub-3", Owner: "tenant02", SourceEntityType: "DEVICE"}
+	rt.putTypeSubscription(sub1)
+	rt.putTypeSubscription(sub2)
+	rt.putTypeSubscription(sub3)
+
+	assert.Len(t, rt.typeSubscriptionsOf("DEVICE", "tenant01"), 2)
+	assert.Len(t, rt.typeSubscriptionsOf("GATEWAY", "tenant01"), 0)
+	assert.Nil(t, rt.typeSubscriptionsOf("", "tenant01"))
+
+	// subscriptions of other tenants never match.
+	assert.Equal(t, map[string]*repository.Subscription{"sub-3": sub3}, rt.typeSubscriptionsOf("DEVICE", "tenant02"))
+	assert.Empty(t, rt.typeSubscriptionsOf("DEVICE", "tenant03"))
+	assert.Empty(t, rt.typeSubscriptionsOf("DEVICE", ""))
+
+	rt.delTypeSubscription(sub1)
+	assert.Len(t, rt.typeSubscriptionsOf("DEVICE", "tenant01"), 1)
+	rt.delTypeSubscription(sub2)
+	rt.delTypeSubscription(sub3)
+	assert.NotContains(t, rt.typeSubscriptions, "DEVICE")
+}
+
+func Test_traceIDOf(t *testing.T) {
+	ev := &v1.ProtoEvent{Id: "ev-1", Metadata: map[string]string{v1.MetaTraceID: "trace-123"}}
+	assert.Equal(t, "trace-123", traceIDOf(ev))
+
+	ev = &v1.ProtoEvent{Id: "ev-2", Metadata: map[string]string{}}
+	traceID := traceIDOf(ev)
+	assert.NotEmpty(t, traceID)
+	assert.Equal(t, traceID, ev.Attr(v1.MetaTraceID))
+	assert.Equal(t, traceID, traceIDOf(ev))
+	assert.Equal(t, traceID, types.TraceIDFrom(types.WithTraceID(context.Background(), traceID)))
+}
+
+func TestRuntime_handleNamespace(t *testing.T) {
+	rt := &Runtime{}
+	ev := &v1.ProtoEvent{Metadata: map[string]string{v1.MetaNamespace: "sensor-a"}}
+	feed := rt.handleNamespace(context.Background(), &Feed{
+		Event: ev,
+		Patches: []Patch{
+			{Op: tkeelJson.OpReplace, Path: "properties.gps.lat", Value: tdtl.New(`10`)},
+			{Op: tkeelJson.OpMerge, Path: "properties", Value: tdtl.New(`{"temp":20}`)},
+			{Op: tkeelJson.OpReplace, Path: "type", Value: tdtl.New(`"sensor"`)},
+			{Op: tkeelJson.OpReplace, Path: FieldRawData, Value: tdtl.New(`{}`)},
+		},
+	})
+	assert.Nil(t, feed.Err)
+	assert.Len(t, feed.Patches, 4)
+	assert.Equal(t, "properties.sensor-a__gps.lat", feed.Patches[0].Path)
+	assert.Equal(t, "properties.sensor-a__temp", feed.Patches[1].Path)
+	assert.Equal(t, tkeelJson.OpMerge, feed.Patches[1].Op)
+	assert.Equal(t, "20", feed.Patches[1].Value.String())
+	assert.Equal(t, "type", feed.Patches[2].Path)
+	assert.Equal(t, FieldRawData, feed.Patches[3].Path)
+
+	ev.SetAttr(v1.MetaNamespace, "sensor.a")
+	feed = rt.handleNamespace(context.Background(), &Feed{Event: ev})
+	assert.ErrorIs(t, feed.Err, xerrors.ErrNamespaceInvalid)
+}
+
+func TestRuntime_handleSchema(t *testing.T) {
+	en, err := NewEntity("device123", []byte(`{"id":"device123","type":"sensor","properties":{},
+		"scheme":{"additionalProperties":false,"temp":{"type":"int"},
+		"metrics":{"type":"struct","define":{"fields":{"mode":{"type":"string"}}}}}}`))
+	assert.Nil(t, err)
+	rt := &Runtime{entities: map[string]Entity{"device123": en}}
+
+	write := func(born string, patches ...Patch) error {
+		ev := &v1.ProtoEvent{Metadata: map[string]string{v1.MetaBorn: born}}
+		return rt.handleSchema(context.Background(), &Feed{Event: ev, EntityID: "device123", Patches: patches}).Err
+	}
+
+	// strict type accepts declared properties only.
+	assert.Nil(t, write("apis.PatchEntity",
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.temp", Value: tdtl.New(`10`)},
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.metrics.mode", Value: tdtl.New(`"eco"`)},
+		Patch{Op: tkeelJson.OpRemove, Path: "properties.legacy"}))
+	assert.ErrorIs(t, write("apis.PatchEntity",
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.status", Value: tdtl.New(`"on"`)}), xerrors.ErrUnknownProperty)
+	assert.ErrorIs(t, write("apis.PatchEntity",
+		Patch{Op: tkeelJson.OpMerge, Path: "properties", Value: tdtl.New(`{"temp":1,"metrics":{"load":2}}`)}), xerrors.ErrUnknownProperty)
+	assert.ErrorIs(t, write("apis.PatchEntity",
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.metrics.load", Value: tdtl.New(`2`)}), xerrors.ErrUnknownProperty)
+
+	// topic messages checked as well, rawData maintained by core accepted.
+	ev := &v1.ProtoEvent{Metadata: map[string]string{v1.MetaTopic: "core-pub"}}
+	feed := rt.handleSchema(context.Background(), &Feed{Event: ev, EntityID: "device123", Patches: []Patch{
+		{Op: tkeelJson.OpReplace, Path: FieldRawData, Value: tdtl.New(`{}`)},
+		{Op: tkeelJson.OpReplace, Path: "properties.status", Value: tdtl.New(`"on"`)},
+	}})
+	assert.ErrorIs(t, feed.Err, xerrors.ErrUnknownProperty)
+
+	// writes derived by runtime not checked.
+	assert.Nil(t, write("handleLiveness",
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.online", Value: tdtl.New(`true`)}))
+
+	// lenient types accept additional properties.
+	en, err = NewEntity("device234", []byte(`{"id":"device234","type":"gateway","properties":{},"scheme":{}}`))
+	assert.Nil(t, err)
+	rt.entities["device234"] = en
+	ev = &v1.ProtoEvent{Metadata: map[string]string{v1.MetaBorn: "apis.PatchEntity"}}
+	feed = rt.handleSchema(context.Background(), &Feed{Event: ev, EntityID: "device234", Patches: []Patch{
+		{Op: tkeelJson.OpReplace, Path: "properties.status", Value: tdtl.New(`"on"`)},
+	}})
+	assert.Nil(t, feed.Err)
+}
+
+func TestRuntime_handleSchemaConstraints(t *testing.T) {
+	en, err := NewEntity("device301", []byte(`{"id":"device301","type":"sensor","properties":{},
+		"scheme":{"temp":{"type":"int","define":{"min":-20,"max":60}},
+		"metrics":{"type":"struct","define":{"fields":{"mode":{"type":"string","define":{"enum":["auto","manual"]}}}}}}}`))
+	assert.Nil(t, err)
+	rt := &Runtime{entities: map[string]Entity{"device301": en}}
+
+	write := func(meta map[string]string, patches ...Patch) error {
+		ev := &v1.ProtoEvent{Metadata: meta}
+		return rt.handleSchema(context.Background(), &Feed{Event: ev, EntityID: "device301", Patches: patches}).Err
+	}
+	api := map[string]string{v1.MetaBorn: "apis.PatchEntity"}
+
+	assert.Nil(t, write(api,
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.temp", Value: tdtl.New(`60`)},
+		Patch{Op: tkeelJson.OpMerge, Path: "properties", Value: tdtl.New(`{"status":"on","metrics":{"mode":"auto"}}`)},
+		Patch{Op: tkeelJson.OpRemove, Path: "properties.metrics.mode"}))
+	assert.ErrorIs(t, write(api,
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.temp", Value: tdtl.New(`61`)}), xerrors.ErrConstraintViolation)
+	assert.ErrorIs(t, write(api,
+		Patch{Op: tkeelJson.OpMerge, Path: "properties.metrics", Value: tdtl.New(`{"mode":"off"}`)}), xerrors.ErrConstraintViolation)
+
+	// topic messages and namespaced writes checked by configs of keys without namespace.
+	assert.ErrorIs(t, write(map[string]string{v1.MetaTopic: "core-pub"},
+		Patch{Op: tkeelJson.OpMerge, Path: "properties.telemetry", Value: tdtl.New(`{"temp":1}`)},
+		Patch{Op: tkeelJson.OpMerge, Path: "properties", Value: tdtl.New(`{"temp":-21}`)}), xerrors.ErrConstraintViolation)
+	assert.ErrorIs(t, write(map[string]string{v1.MetaBorn: "apis.PatchEntity", v1.MetaNamespace: "ns1"},
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.ns1__temp", Value: tdtl.New(`100`)}), xerrors.ErrConstraintViolation)
+
+	// writes derived by runtime not checked.
+	assert.Nil(t, write(map[string]string{v1.MetaBorn: "handleMirror"},
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.temp", Value: tdtl.New(`100`)}))
+}
+
+func TestRuntime_handleSchemaACL(t *testing.T) {
+	en, err := NewEntity("device303", []byte(`{"id":"device303","type":"sensor",
+		"properties":{"temp":20,"firmware_key":"secret"},
+		"scheme":{"temp":{"type":"int"},
+		"firmware_key":{"type":"string","acl":{"write":["operator"]}}}}`))
+	assert.Nil(t, err)
+	rt := &Runtime{entities: map[string]Entity{"device303": en}}
+
+	write := func(meta map[string]string, patches ...Patch) error {
+		ev := &v1.ProtoEvent{Metadata: meta}
+		return rt.handleSchema(context.Background(), &Feed{Event: ev, EntityID: "device303", Patches: patches}).Err
+	}
+	user := map[string]string{v1.MetaBorn: "apis.PatchEntity", v1.MetaRoles: "user"}
+	operator := map[string]string{v1.MetaBorn: "apis.PatchEntity", v1.MetaRoles: "user,operator"}
+	admin := map[string]string{v1.MetaBorn: "apis.PatchEntity", v1.MetaRoles: "admin"}
+
+	// properties written directly, merged, or dropped by writing properties as a whole.
+	assert.Nil(t, write(user, Patch{Op: tkeelJson.OpReplace, Path: "properties.temp", Value: tdtl.New(`10`)}))
+	assert.ErrorIs(t, write(user,
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.firmware_key", Value: tdtl.New(`"x"`)}), xerrors.ErrForbiddenProperty)
+	assert.ErrorIs(t, write(user,
+		Patch{Op: tkeelJson.OpMerge, Path: "properties", Value: tdtl.New(`{"firmware_key":"x"}`)}), xerrors.ErrForbiddenProperty)
+	assert.ErrorIs(t, write(user,
+		Patch{Op: tkeelJson.OpReplace, Path: "properties", Value: tdtl.New(`{"temp":1}`)}), xerrors.ErrForbiddenProperty)
+	assert.ErrorIs(t, write(user,
+		Patch{Op: tkeelJson.OpRemove, Path: "properties.firmware_key"}), xerrors.ErrForbiddenProperty)
+	assert.ErrorIs(t, write(map[string]string{v1.MetaTopic: "core-pub"},
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.firmware_key", Value: tdtl.New(`"x"`)}), xerrors.ErrForbiddenProperty)
+	assert.Nil(t, write(operator,
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.firmware_key", Value: tdtl.New(`"x"`)}))
+
+	// acls rewritten or removed through configs by admins only, configs replaced, merged or removed.
+	for _, patch := range []Patch{
+		{Op: tkeelJson.OpReplace, Path: "scheme", Value: tdtl.New(`{"temp":{"type":"int"}}`)},
+		{Op: tkeelJson.OpReplace, Path: "scheme", Value: tdtl.New(`{"firmware_key":{"type":"string"}}`)},
+		{Op: tkeelJson.OpMerge, Path: "scheme", Value: tdtl.New(`{"firmware_key":{"type":"string","acl":{}}}`)},
+		{Op: tkeelJson.OpRemove, Path: "scheme"},
+		{Op: tkeelJson.OpReplace, Path: "scheme.firmware_key", Value: tdtl.New(`{"type":"string"}`)},
+		{Op: tkeelJson.OpRemove, Path: "scheme.firmware_key"},
+		{Op: tkeelJson.OpReplace, Path: "scheme.firmware_key.acl.write", Value: tdtl.New(`[]`)},
+		{Op: tkeelJson.OpReplace, Path: "scheme.temp", Value: tdtl.New(`{"type":"int","acl":{"write":["user"]}}`)},
+	} {
+		assert.ErrorIs(t, write(operator, patch), xerrors.ErrPermissionDenied, patch.Path)
+		assert.Nil(t, write(admin, patch), patch.Path)
+	}
+
+	// configs leaving acls alone written without the admin role.
+	assert.Nil(t, write(user,
+		Patch{Op: tkeelJson.OpMerge, Path: "scheme", Value: tdtl.New(`{"firmware_key":{"type":"string"},"hum":{"type":"int"}}`)},
+		Patch{Op: tkeelJson.OpReplace, Path: "scheme.firmware_key.define", Value: tdtl.New(`{"max":10}`)},
+		Patch{Op: tkeelJson.OpReplace, Path: "scheme.temp", Value: tdtl.New(`{"type":"float"}`)}))
+
+	// writes of internal callers carry no roles, not checked.
+	assert.Nil(t, write(map[string]string{v1.MetaBorn: "apis.PatchEntity"},
+		Patch{Op: tkeelJson.OpRemove, Path: "properties.firmware_key"},
+		Patch{Op: tkeelJson.OpRemove, Path: "scheme.firmware_key"}))
+}
+
+func TestRuntime_handleDeadband(t *testing.T) {
+	en, err := NewEntity("device302", []byte(`{"id":"device302","type":"sensor",
+		"properties":{"temp":20,"metrics":{"load":50},"ns1__temp":20},
+		"scheme":{"temp":{"type":"float","define":{"deadband":{"absolute":0.5}}},
+		"metrics":{"type":"struct","define":{"fields":{"load":{"type":"float","define":{"deadband":{"percent":10}}}}}}}}`))
+	assert.Nil(t, err)
+	rt := &Runtime{entities: map[string]Entity{"device302": en}}
+
+	write := func(meta map[string]string, patches ...Patch) []Patch {
+		ev := &v1.ProtoEvent{Metadata: meta}
+		return rt.handleDeadband(context.Background(), &Feed{Event: ev, EntityID: "device302", Patches: patches}).Patches
+	}
+	topic := map[string]string{v1.MetaTopic: "core-pub"}
+
+	// topic messages within deadband ignored, leaves of merged objects filtered.
+	patches := write(topic,
+		Patch{Op: tkeelJson.OpReplace, Path: FieldRawData, Value: tdtl.New(`{}`)},
+		Patch{Op: tkeelJson.OpMerge, Path: "properties.telemetry", Value: tdtl.New(`{"status":"on"}`)},
+		Patch{Op: tkeelJson.OpMerge, Path: "properties", Value: tdtl.New(`{"temp":20.4,"metrics":{"load":54},"status":"on"}`)},
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.metrics.load", Value: tdtl.New(`56`)},
+		Patch{Op: tkeelJson.OpRemove, Path: "properties.temp"})
+	assert.Len(t, patches, 5)
+	assert.Equal(t, `{"metrics":{},"status":"on"}`, string(patches[2].Value.Raw()))
+
+	patches = write(map[string]string{v1.MetaBorn: "apis.PatchEntity"},
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.temp", Value: tdtl.New(`19.8`)},
+		Patch{Op: tkeelJson.OpMerge, Path: "properties", Value: tdtl.New(`{"temp":20.2}`)},
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.metrics.load", Value: tdtl.New(`40`)})
+	assert.Len(t, patches, 1)
+	assert.Equal(t, "properties.metrics.load", patches[0].Path)
+
+	// namespaced writes configured by property configs of keys without namespace.
+	patches = write(map[string]string{v1.MetaBorn: "apis.PatchEntity", v1.MetaNamespace: "ns1"},
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.ns1__temp", Value: tdtl.New(`20.3`)})
+	assert.Len(t, patches, 0)
+
+	// writes derived by runtime not filtered.
+	patches = write(map[string]string{v1.MetaBorn: "handleMirror"},
+		Patch{Op: tkeelJson.OpReplace, Path: "properties.temp", Value: tdtl.New(`20.1`)})
+	assert.Len(t, patches, 1)
+}
+
+func TestRuntime_deleteEntityNotFound(t *testing.T) {
+	memDao, err := dao.NewMock(context.Background(), config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+
+	var removed []string
+	rt := &Runtime{
+		entities:   map[string]Entity{},
+		repository: repository.New(memDao),
+		entityResourcer: EntityResource{
+			RemoveHandler: func(ctx context.Context, en Entity, feed *Feed) error {
+				removed = append(removed, en.ID())
+				return nil
+			},
+		},
+		stats: make(map[string]*EntityStats),
+	}
+	rt.recordMessage("device404", true)
+	rt.logMessage(&v1.ProtoEvent{Id: "ev-12344", Metadata: map[string]string{v1.MetaEntityID: "device404"}})
+
+	// leftovers of the missing entity still removed, delete succeeds.
+	execer, feed := rt.prepareSystemEvent(context.Background(), &v1.ProtoEvent{
+		Id:       "ev-12345",
+		Metadata: map[string]string{v1.MetaEntityID: "device404"},
+		Data: &v1.ProtoEvent_SystemData{
+			SystemData: &v1.SystemData{Operator: string(v1.OpDelete)},
+		},
+	})
+	feed = execer.Exec(context.Background(), feed)
+	assert.Nil(t, feed.Err)
+	assert.Equal(t, []string{"device404"}, removed)
+
+	// stats and messages of the entity deleted evicted.
+	_, ok := rt.GetEntityStats("device404")
+	assert.False(t, ok)
+	_, ok = rt.ReplayMessages("device404", 0)
+	assert.False(t, ok)
+}
+
+func TestRuntime_reloadEntity(t *testing.T) {
+	memDao, err := dao.NewMock(context.Background(), config.Metadata{Name: "memory"}, config.EtcdConfig{})
+	assert.Nil(t, err)
+
+	// runtime write not flushed yet.
+	en, err := NewEntity("device-reload", []byte(`{"id":"device-reload","properties":{"temp":20,"humidity":50}}`))
+	assert.Nil(t, err)
+
+	var persisted []byte
+	rt := &Runtime{
+		entities:   map[string]Entity{"device-reload": en},
+		repository: repository.New(memDao),
+		subTree:    path.NewRefTree(),
+		evalTree:   path.New(),
+		entityResourcer: EntityResource{
+			PersistentEntity: func(ctx context.Context, en Entity, feed *Feed) error {
+				persisted = en.Raw()
+				return nil
+			},
+		},
+	}
+
+	// committed values merged into the cached state, changes propagated.
+	execer, feed := rt.prepareSystemEvent(context.Background(), &v1.ProtoEvent{
+		Id:       "ev-12345",
+		Metadata: map[string]string{v1.MetaEntityID: "device-reload"},
+		Data: &v1.ProtoEvent_SystemData{
+			SystemData: &v1.SystemData{
+				Operator: string(v1.OpReload),
+				Data:     []byte(`[{"path":"properties.temp","operator":"replace","value":"MzA="}]`),
+			},
+		},
+	})
+	feed = execer.Exec(context.Background(), feed)
+	assert.Nil(t, feed.Err)
+	assert.Equal(t, "30", rt.entities["device-reload"].Get("properties.temp").String())
+	assert.Equal(t, "50", rt.entities["device-reload"].Get("properties.humidity").String())
+	assert.Len(t, feed.Changes, 1)
+	assert.Equal(t, "properties.temp", feed.Changes[0].Path)
+	assert.Contains(t, string(persisted), `"temp":30`)
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/mapper"
+	"github.com/tkeel-io/core/pkg/placement"
+	"github.com/tkeel-io/tdtl"
+)
+
+// SimResult output of the simulated mapper after a logged message.
+type SimResult struct {
+	EventID   string `json:"event_id"`
+	Timestamp int64  `json:"timestamp"`
+	// Output properties the mapper would write to its target, keyed by property.
+	Output map[string]interface{} `json:"output,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// SimulateMapper evaluate the tql after each logged message of the entity, oldest first,
+// nothing persisted. messages are replayed on a shadow copy of the current state, like Replay,
+// other entities the tql reads from take their current values. the state before the first
+// logged message is not kept, so properties the messages do not write take their current
+// values all along, and outputs of early messages may reflect values written later.
+func (r *Runtime) SimulateMapper(ctx context.Context, entityID, tql string) ([]SimResult, error) {
+	msgs, ok := r.ReplayMessages(entityID, 0)
+	if !ok {
+		return nil, errors.Wrap(xerrors.ErrEntityNotFound, "simulate mapper, no message log")
+	}
+
+	mp, err := mapper.NewMapper(mapper.Mapper{ID: "simulate", TQL: tql}, mapper.VersionInited)
+	if nil != err {
+		return nil, errors.Wrapf(xerrors.ErrInvalidParam, "simulate mapper, %s", err)
+	}
+	sources := mp.SourceEntities()
+	if _, has := sources[entityID]; !has {
+		return nil, errors.Wrapf(xerrors.ErrInvalidParam, "simulate mapper, tql not reading entity %s", entityID)
+	}
+
+	shadow, err := r.shadowOf(ctx, entityID)
+	if nil != err {
+		return nil, errors.Wrap(err, "simulate mapper")
+	}
+
+	states := map[string]Entity{entityID: shadow}
+	for sourceID := range sources {
+		if sourceID == entityID {
+			continue
+		} else if state, has := r.copyState(ctx, sourceID); has {
+			states[sourceID] = state
+		}
+	}
+
+	results := make([]SimResult, 0, len(msgs))
+	for _, msg := range msgs {
+		result := SimResult{EventID: msg.EventID, Timestamp: msg.Timestamp}
+		if err = replayMessage(ctx, shadow, msg); nil != err {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		in := make(map[string]tdtl.Node)
+		for sourceID, paths := range sources {
+			state, has := states[sourceID]
+			if !has {
+				continue
+			}
+			for _, path := range paths {
+				in[path] = state.Get(mapper.NewWatchKey(path).PropertyKey)
+			}
+		}
+
+		out, err := mp.Exec(in)
+		if nil != err {
+			result.Error = err.Error()
+		} else {
+			result.Output = simOutput(out)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// shadowOf returns a copy of the entity state, loaded from state store if not cached.
+func (r *Runtime) shadowOf(ctx context.Context, id string) (Entity, error) {
+	if shadow, has := r.copyState(ctx, id); has {
+		return shadow, nil
+	}
+
+	state, err := r.LoadEntity(id)
+	if nil != err {
+		return nil, err
+	}
+	return NewEntity(id, state.Raw())
+}
+
+// copyState returns a copy of the entity state like loadState, copied under the lock
+// so callers off the event loop never read states the event loop mutates.
+func (r *Runtime) copyState(ctx context.Context, id string) (Entity, bool) {
+	r.lock.RLock()
+	state, has := r.entities[id]
+	var raw []byte
+	if has {
+		raw = state.Raw()
+	}
+	r.lock.RUnlock()
+
+	if !has {
+		var err error
+		if state, err = r.enCache.Load(ctx, id); nil != err {
+			return nil, false
+		}
+		raw = state.Raw()
+	}
+
+	shadow, err := NewEntity(id, raw)
+	return shadow, nil == err
+}
+
+func simOutput(out map[string]tdtl.Node) map[string]interface{} {
+	ret := make(map[string]interface{}, len(out))
+	for key, node := range out {
+		if node == nil || node.Type() == tdtl.Null || node.Type() == tdtl.Undefined {
+			continue
+		}
+
+		var val interface{}
+		if err := json.Unmarshal(node.Raw(), &val); nil != err {
+			val = node.String()
+		}
+		ret[key] = val
+	}
+	return ret
+}
+
+// SimulateMapper evaluate the tql over logged messages of the entity on the runtime it placed on.
+func (n *Node) SimulateMapper(ctx context.Context, entityID, tql string) ([]SimResult, error) {
+	n.lock.RLock()
+	rt, ok := n.runtimes[placement.Global().Select(entityID).ID]
+	n.lock.RUnlock()
+	if !ok {
+		return nil, errors.Wrap(xerrors.ErrRuntimeNotExists, "simulate mapper")
+	}
+	return rt.SimulateMapper(ctx, entityID, tql)
+}
@@ -24,17 +24,20 @@ type Feed struct {
 	EntityID string
 	Patches  []Patch
 	Changes  []Patch
+	// Conflicts merge conflicts resolved, see v1.MetaMergeStrategy.
+	Conflicts []Conflict
 }
 
 func (feed *Feed) Copy() *Feed {
 	return &Feed{
-		TTL:      feed.TTL,
-		Err:      feed.Err,
-		Event:    feed.Event,
-		State:    feed.State,
-		EntityID: feed.EntityID,
-		Patches:  feed.Patches,
-		Changes:  feed.Changes,
+		TTL:       feed.TTL,
+		Err:       feed.Err,
+		Event:     feed.Event,
+		State:     feed.State,
+		EntityID:  feed.EntityID,
+		Patches:   feed.Patches,
+		Changes:   feed.Changes,
+		Conflicts: feed.Conflicts,
 	}
 }
 
@@ -0,0 +1,166 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/metrics"
+	"github.com/tkeel-io/core/pkg/placement"
+	"github.com/tkeel-io/tdtl"
+)
+
+// EntityStats lightweight runtime counters of an entity, distinct from entity state.
+type EntityStats struct {
+	EntityID string `json:"entity_id"`
+	// MessageCount count of entity events handled.
+	MessageCount int64 `json:"message_count"`
+	// LastSeen timestamp(ms) of the last entity message ingested from topics.
+	LastSeen int64 `json:"last_seen"`
+	// MapperEvalCount count of mapper evaluations targeting the entity.
+	MapperEvalCount int64 `json:"mapper_eval_count"`
+	// Offline whether the entity marked offline by liveness detection.
+	Offline bool `json:"offline"`
+}
+
+func (r *Runtime) entityStats(entityID string) *EntityStats {
+	if r.stats == nil {
+		r.stats = make(map[string]*EntityStats)
+	}
+
+	stats, ok := r.stats[entityID]
+	if !ok {
+		stats = &EntityStats{EntityID: entityID}
+		r.stats[entityID] = stats
+	}
+	return stats
+}
+
+// recordMessage count entity message, messages ingested mark the entity seen,
+// returns true if the entity seen was marked offline.
+func (r *Runtime) recordMessage(entityID string, ingested bool) bool {
+	r.slock.Lock()
+	defer r.slock.Unlock()
+	stats := r.entityStats(entityID)
+	stats.MessageCount++
+	if !ingested {
+		return false
+	}
+
+	stats.LastSeen = time.Now().UnixNano() / 1e6
+	offline := stats.Offline
+	stats.Offline = false
+	return offline
+}
+
+// forgetStats evict stats of the entity deleted.
+func (r *Runtime) forgetStats(entityID string) {
+	r.slock.Lock()
+	defer r.slock.Unlock()
+	delete(r.stats, entityID)
+}
+
+func (r *Runtime) recordEval(entityID string) {
+	r.slock.Lock()
+	defer r.slock.Unlock()
+	r.entityStats(entityID).MapperEvalCount++
+}
+
+// observeEval record mapper evaluation outcome and latency labeled by target entity type and mapper name.
+func (r *Runtime) observeEval(entityID, mapperName, outcome string, elapsed time.Duration) {
+	entityType := "unknown"
+	r.lock.RLock()
+	if state, ok := r.entities[entityID]; ok && state.Type() != "" {
+		entityType = state.Type()
+	}
+	r.lock.RUnlock()
+
+	metrics.CollectorMapperEvalCount.WithLabelValues(entityType, mapperName, outcome).Inc()
+	metrics.CollectorMapperEvalSeconds.WithLabelValues(entityType, mapperName, outcome).Observe(elapsed.Seconds())
+}
+
+// evalOutcome classify a mapper evaluation, empty results skipped.
+func evalOutcome(result tdtl.Node, err error) string {
+	switch {
+	case nil != err:
+		return metrics.EvalErrored
+	case nil == result:
+		return metrics.EvalSkipped
+	default:
+		return metrics.EvalFired
+	}
+}
+
+func (r *Runtime) GetEntityStats(entityID string) (EntityStats, bool) {
+	r.slock.RLock()
+	defer r.slock.RUnlock()
+	if stats, ok := r.stats[entityID]; ok {
+		return *stats, true
+	}
+	return EntityStats{}, false
+}
+
+// GetEntityStats returns entity stats maintained by runtimes of the node.
+func (n *Node) GetEntityStats(ctx context.Context, entityID string) (*EntityStats, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	for _, rt := range n.runtimes {
+		if stats, ok := rt.GetEntityStats(entityID); ok {
+			return &stats, nil
+		}
+	}
+	return nil, errors.Wrap(xerrors.ErrEntityNotFound, "get entity stats")
+}
+
+// RuntimeStatus placement and loading status of an entity.
+type RuntimeStatus struct {
+	EntityID string `json:"entity_id"`
+	// RuntimeID runtime(shard) the entity placed on.
+	RuntimeID string `json:"runtime_id"`
+	// Local whether the runtime served by this node.
+	Local bool `json:"local"`
+	// Loaded whether entity state instantiated in runtime.
+	Loaded bool `json:"loaded"`
+	// MailboxDepth messages received by the runtime and not yet handled.
+	MailboxDepth int `json:"mailbox_depth"`
+}
+
+// GetRuntimeStatus returns which runtime the entity placed on and whether it is loaded.
+func (n *Node) GetRuntimeStatus(ctx context.Context, entityID string) (*RuntimeStatus, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	if len(n.runtimes) == 0 {
+		return nil, errors.Wrap(xerrors.ErrRuntimeNotExists, "get runtime status")
+	}
+
+	info := placement.Global().Select(entityID)
+	status := &RuntimeStatus{EntityID: entityID, RuntimeID: info.ID}
+	rt, ok := n.runtimes[info.ID]
+	if !ok {
+		return status, nil
+	}
+
+	rt.lock.RLock()
+	_, status.Loaded = rt.entities[entityID]
+	rt.lock.RUnlock()
+	status.Local = true
+	status.MailboxDepth = len(rt.msgs)
+	return status, nil
+}
@@ -5,9 +5,11 @@ import (
 	"strings"
 
 	daprSDK "github.com/dapr/go-sdk/client"
+	"github.com/pkg/errors"
 	logf "github.com/tkeel-io/core/pkg/logfield"
 	"github.com/tkeel-io/core/pkg/metrics"
 	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/types"
 	"github.com/tkeel-io/core/pkg/util/dapr"
 	"github.com/tkeel-io/kit/log"
 	"github.com/tkeel-io/tdtl"
@@ -23,34 +25,106 @@ const (
 	SModePeriod    SubscriptionMode = "PERIOD"
 	SModeRealtime  SubscriptionMode = "REALTIME"
 	SModeOnChanged SubscriptionMode = "ONCHANGED"
+	// SModeMirror write the subscribed property to TargetPath of Target entity rather than publishing.
+	SModeMirror SubscriptionMode = "MIRROR"
 )
 
 func (r *Runtime) handleSubscribe(ctx context.Context, feed *Feed) *Feed {
-	log.L().Debug("handle external subscribe", logf.Eid(feed.EntityID), logf.Event(feed.Event))
+	log.L().Debug("handle external subscribe", logf.Eid(feed.EntityID),
+		logf.Event(feed.Event), logf.TraceID(types.TraceIDFrom(ctx)))
 
 	entityID := feed.EntityID
 	if subs, ok := r.entitySubscriptions[entityID]; ok {
-		for _, sub := range subs {
-			state := makeSubData(feed, sub)
-			if state == nil {
-				continue
-			}
-			metrics.CollectorMsgCount.WithLabelValues(sub.Owner, metrics.MsgTypeSubscribe).Inc()
-			log.L().Debug("handle external subs", logf.Eid(feed.EntityID), logf.Event(feed.Event), logf.Any("sub", sub.Filter))
-			ctOpts := daprSDK.PublishEventWithContentType("application/json")
-			err := dapr.Get().Select().PublishEvent(ctx, sub.PubsubName, sub.Topic, state, ctOpts)
-			if nil != err {
-				log.L().Error("publish message via dapr", logf.ID(sub.ID),
-					logf.Eid(entityID), logf.Topic(sub.Topic), logf.Pubsub(sub.PubsubName), logf.Mode(sub.Mode))
-				return feed
-			}
-		}
+		r.publishSubscriptions(ctx, feed, subs)
 	} else {
 		log.L().Info("handle external subscribe nil", logf.Eid(feed.EntityID))
 	}
+
+	// type level subscriptions, fan-in entities of the type owned by the subscriber.
+	state := tdtl.New(feed.State)
+	if subs := r.typeSubscriptionsOf(state.Get(FieldType).String(), state.Get(FieldOwner).String()); len(subs) > 0 {
+		r.publishSubscriptions(ctx, feed, subs)
+	}
 	return feed
 }
 
+func (r *Runtime) publishSubscriptions(ctx context.Context, feed *Feed, subs map[string]*repository.Subscription) {
+	for _, sub := range subs {
+		if sub.Mode == SModeMirror.S() {
+			r.mirror(ctx, feed, sub)
+			continue
+		}
+
+		// notifications batched, published at the end of window.
+		if r.batcher != nil {
+			r.batcher.add(feed, sub)
+			continue
+		}
+
+		state := makeSubData(feed, sub)
+		if state == nil {
+			continue
+		}
+		log.L().Debug("handle external subs", logf.Eid(feed.EntityID), logf.Event(feed.Event), logf.Any("sub", sub.Filter))
+		// keep delivering to the other subscribers.
+		if err := r.deliver(ctx, feed.EntityID, sub, state); nil != err {
+			log.L().Warn("handle external subs, deliver", logf.ID(sub.ID), logf.Eid(feed.EntityID), logf.Error(err))
+		}
+	}
+}
+
+func publishSubData(ctx context.Context, entityID string, sub *repository.Subscription, state []byte) error {
+	metrics.CollectorMsgCount.WithLabelValues(sub.Owner, metrics.MsgTypeSubscribe).Inc()
+	ctOpts := daprSDK.PublishEventWithContentType("application/json")
+	err := dapr.Get().Select().PublishEvent(ctx, sub.PubsubName, sub.Topic, state, ctOpts)
+	if nil != err {
+		log.L().Error("publish message via dapr", logf.ID(sub.ID),
+			logf.Eid(entityID), logf.Topic(sub.Topic), logf.Pubsub(sub.PubsubName), logf.Mode(sub.Mode))
+	}
+	return errors.Wrap(err, "publish subscription message")
+}
+
+// typeSubscriptionsOf returns a copy of subscriptions of the entity type owned by owner,
+// subscribers only receive changes of entities of their own tenant.
+func (r *Runtime) typeSubscriptionsOf(entityType, owner string) map[string]*repository.Subscription {
+	if entityType == "" {
+		return nil
+	}
+
+	r.tlock.RLock()
+	defer r.tlock.RUnlock()
+	subs := make(map[string]*repository.Subscription)
+	for id, sub := range r.typeSubscriptions[entityType] {
+		if sub.Owner == owner {
+			subs[id] = sub
+		}
+	}
+	return subs
+}
+
+func (r *Runtime) putTypeSubscription(sub *repository.Subscription) {
+	r.tlock.Lock()
+	defer r.tlock.Unlock()
+	if r.typeSubscriptions == nil {
+		r.typeSubscriptions = make(map[string]map[string]*repository.Subscription)
+	}
+	if _, ok := r.typeSubscriptions[sub.SourceEntityType]; !ok {
+		r.typeSubscriptions[sub.SourceEntityType] = make(map[string]*repository.Subscription)
+	}
+	r.typeSubscriptions[sub.SourceEntityType][sub.ID] = sub
+}
+
+func (r *Runtime) delTypeSubscription(sub *repository.Subscription) {
+	r.tlock.Lock()
+	defer r.tlock.Unlock()
+	if subs, ok := r.typeSubscriptions[sub.SourceEntityType]; ok {
+		delete(subs, sub.ID)
+		if len(subs) == 0 {
+			delete(r.typeSubscriptions, sub.SourceEntityType)
+		}
+	}
+}
+
 func pathMatch(paths []string, pathCheck string) bool {
 	log.L().Info("pathMatch", logf.Any("paths", paths), logf.String("pathCheck", pathCheck))
 	for _, path := range paths {
@@ -63,24 +137,13 @@ func pathMatch(paths []string, pathCheck string) bool {
 	return false
 }
 
+// FieldPrevious field of subscription messages carrying previous values of changed properties.
+const FieldPrevious = "previous"
+
 func makeSubData(feed *Feed, sub *repository.Subscription) []byte {
-	ret := tdtl.New(`{}`)
-	cc := tdtl.New(feed.State)
-	writeFlag := false
-	for _, change := range feed.Changes {
-		path := change.Path
-		if pathMatch(sub.SourceEntityPaths, path) {
-			ret.Set(path, cc.Get(path))
-			writeFlag = true
-		}
-	}
-	if !writeFlag {
+	batch := newChangeBatch(feed.EntityID, sub)
+	if !batch.add(feed) {
 		return nil
 	}
-
-	ret.Set("id", tdtl.NewString(feed.EntityID))
-	ret.Set("subscribe_id", tdtl.NewString(sub.ID))
-	ret.Set("owner", tdtl.NewString(sub.Owner))
-
-	return ret.Raw()
+	return batch.encode()
 }
@@ -1,9 +1,15 @@
 package runtime
 
 import (
+	"context"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	v1 "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
 	"github.com/tkeel-io/core/pkg/repository"
 	xjson "github.com/tkeel-io/core/pkg/util/json"
 	"github.com/tkeel-io/tdtl"
@@ -39,3 +45,222 @@ func Test_makeSubData(t *testing.T) {
 	}, &sub)
 	t.Log("payload: ", string(bytes))
 }
+
+func Test_makeSubDataPrevious(t *testing.T) {
+	sub := &repository.Subscription{SourceEntityPaths: []string{"properties.temp"}}
+	sub.ID = "subID"
+	bytes := makeSubData(&Feed{
+		EntityID: "device123",
+		State:    []byte(`{"properties":{"temp":25,"cpu":0.5}}`),
+		Changes: []Patch{
+			{Op: xjson.OpReplace, Path: "properties.temp", Value: tdtl.New("25"), Old: tdtl.New("20")},
+			{Op: xjson.OpReplace, Path: "properties.cpu", Value: tdtl.New("0.5"), Old: tdtl.New("0.4")},
+		},
+	}, sub)
+
+	cc := tdtl.New(bytes)
+	assert.Equal(t, "25", cc.Get("properties.temp").String())
+	assert.Equal(t, "20", cc.Get(FieldPrevious+".properties.temp").String())
+	// unsubscribed changes excluded.
+	assert.Equal(t, tdtl.Null, cc.Get(FieldPrevious+".properties.cpu").Type())
+}
+
+func Test_changeBatcher(t *testing.T) {
+	var (
+		lock      sync.Mutex
+		published [][]byte
+	)
+	cb := newChangeBatcher(20*time.Millisecond, func(ctx context.Context, entityID string, sub *repository.Subscription, state []byte) error {
+		lock.Lock()
+		defer lock.Unlock()
+		published = append(published, state)
+		return nil
+	})
+	count := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(published)
+	}
+
+	sub := &repository.Subscription{SourceEntityPaths: []string{"properties.temp", "properties.cpu"}}
+	sub.ID = "subID"
+	feed := func(state string, changes ...Patch) *Feed {
+		return &Feed{EntityID: "device123", State: []byte(state), Changes: changes}
+	}
+	cb.add(feed(`{"properties":{"temp":25}}`,
+		Patch{Op: xjson.OpReplace, Path: "properties.temp", Value: tdtl.New("25"), Old: tdtl.New("20")}), sub)
+	cb.add(feed(`{"properties":{"temp":30,"cpu":0.5}}`,
+		Patch{Op: xjson.OpReplace, Path: "properties.temp", Value: tdtl.New("30"), Old: tdtl.New("25")},
+		Patch{Op: xjson.OpReplace, Path: "properties.cpu", Value: tdtl.New("0.5")}), sub)
+	// unmatched changes not batched.
+	cb.add(feed(`{"properties":{"mem":10}}`,
+		Patch{Op: xjson.OpReplace, Path: "properties.mem", Value: tdtl.New("10")}), sub)
+	assert.Equal(t, 0, count())
+
+	// single coalesced notification with net changes.
+	assert.Eventually(t, func() bool { return count() == 1 }, time.Second, 5*time.Millisecond)
+	cc := tdtl.New(published[0])
+	assert.Equal(t, "30", cc.Get("properties.temp").String())
+	assert.Equal(t, "0.5", cc.Get("properties.cpu").String())
+	assert.Equal(t, "20", cc.Get(FieldPrevious+".properties.temp").String())
+	assert.Equal(t, "device123", cc.Get("id").String())
+
+	// pending batch flushed on stop.
+	cb.add(feed(`{"properties":{"temp":35}}`,
+		Patch{Op: xjson.OpReplace, Path: "properties.temp", Value: tdtl.New("35")}), sub)
+	cb.flushAll()
+	assert.Equal(t, 2, count())
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, 2, count())
+}
+
+func TestRuntime_deliver(t *testing.T) {
+	var lock sync.Mutex
+	var letters []*DeadLetter
+	failures, attempts := 0, 0
+	rt := &Runtime{
+		id: "rt-1",
+		publish: func(ctx context.Context, entityID string, sub *repository.Subscription, state []byte) error {
+			lock.Lock()
+			defer lock.Unlock()
+			attempts++
+			if attempts <= failures {
+				return xerrors.ErrConnectionNil
+			}
+			return nil
+		},
+		deadLetterSink: deadLetterFunc(func(ctx context.Context, letter *DeadLetter) error {
+			lock.Lock()
+			defer lock.Unlock()
+			letters = append(letters, letter)
+			return nil
+		}),
+	}
+
+	// at-most-once published once.
+	failures = 1
+	sub := &repository.Subscription{ID: "sub-1", Owner: "admin"}
+	assert.NotNil(t, rt.deliver(context.Background(), "device123", sub, []byte(`{}`)))
+	assert.Equal(t, 1, attempts)
+	assert.Len(t, letters, 0)
+
+	// at-least-once retried in the background, the caller not blocked.
+	attempts, failures = 0, 2
+	sub.Delivery = repository.DeliveryAtLeastOnce
+	assert.Nil(t, rt.deliver(context.Background(), "device123", sub, []byte(`{}`)))
+	lock.Lock()
+	assert.Equal(t, 1, attempts)
+	lock.Unlock()
+	assert.Nil(t, rt.retries.wait(context.Background()))
+	assert.Equal(t, 3, attempts)
+	assert.Len(t, letters, 0)
+
+	// dead-lettered on exhaustion.
+	attempts, failures = 0, deliveryMaxAttempts
+	assert.Nil(t, rt.deliver(context.Background(), "device123", sub, []byte(`{"temp":25}`)))
+	assert.Nil(t, rt.retries.wait(context.Background()))
+	assert.Equal(t, deliveryMaxAttempts, attempts)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, DeadLetterStageDeliver, letters[0].Stage)
+	assert.Equal(t, "sub-1", letters[0].SubscriptionID)
+	assert.Equal(t, []byte(`{"temp":25}`), letters[0].Payload)
+}
+
+func TestRuntime_publishSubscriptions(t *testing.T) {
+	var published []string
+	rt := &Runtime{
+		id: "rt-1",
+		publish: func(ctx context.Context, entityID string, sub *repository.Subscription, state []byte) error {
+			published = append(published, sub.ID)
+			if sub.ID == "sub-1" {
+				return xerrors.ErrConnectionNil
+			}
+			return nil
+		},
+	}
+
+	// subscribers after a failing one still delivered.
+	subs := map[string]*repository.Subscription{}
+	for _, id := range []string{"sub-1", "sub-2", "sub-3"} {
+		subs[id] = &repository.Subscription{ID: id, Owner: "admin", Mode: SModeRealtime.S(),
+			SourceEntityPaths: []string{"properties.temp"}}
+	}
+	feed := &Feed{EntityID: "device123", State: []byte(`{"properties":{"temp":25}}`),
+		Changes: []Patch{{Op: xjson.OpReplace, Path: "properties.temp", Value: tdtl.New("25")}}}
+	rt.publishSubscriptions(context.Background(), feed, subs)
+	assert.ElementsMatch(t, []string{"sub-1", "sub-2", "sub-3"}, published)
+}
+
+type eventRecorder struct {
+	events []v1.Event
+}
+
+func (d *eventRecorder) DispatchToLog(ctx context.Context, bytes []byte) error {
+	return nil
+}
+
+func (d *eventRecorder) Dispatch(ctx context.Context, event v1.Event) error {
+	d.events = append(d.events, event)
+	return nil
+}
+
+func TestRuntime_mirror(t *testing.T) {
+	recorder := &eventRecorder{}
+	rt := &Runtime{id: "rt-1", dispatcher: recorder}
+	subs := map[string]*repository.Subscription{"mirror-1": {
+		ID:                "mirror-1",
+		Owner:             "admin",
+		Mode:              SModeMirror.S(),
+		SourceEntityID:    "device123",
+		SourceEntityPaths: []string{"properties.temp"},
+		Target:            "device234",
+		TargetPath:        "properties.temperature",
+	}}
+	feedOf := func(event *v1.ProtoEvent, paths ...string) *Feed {
+		feed := &Feed{EntityID: "device123", Event: event, State: []byte(`{"properties":{"temp":25,"humi":40}}`)}
+		for _, path := range paths {
+			feed.Changes = append(feed.Changes, Patch{Path: path})
+		}
+		return feed
+	}
+
+	rt.publishSubscriptions(context.Background(), feedOf(&v1.ProtoEvent{}, "properties.temp"), subs)
+	assert.Len(t, recorder.events, 1)
+	ev, _ := recorder.events[0].(*v1.ProtoEvent)
+	assert.Equal(t, "device234", ev.Entity())
+	assert.Equal(t, "mirror-1", ev.Attr(v1.MetaMirror))
+	assert.Equal(t, "properties.temperature", ev.GetPatches().Patches[0].Path)
+	assert.Equal(t, []byte("25"), ev.GetPatches().Patches[0].Value)
+
+	// unrelated changes.
+	rt.publishSubscriptions(context.Background(), feedOf(&v1.ProtoEvent{}, "properties.humi"), subs)
+	assert.Len(t, recorder.events, 1)
+
+	// writes of mirrors not mirrored back.
+	mirrored := &v1.ProtoEvent{Metadata: map[string]string{v1.MetaMirror: "mirror-1"}}
+	rt.publishSubscriptions(context.Background(), feedOf(mirrored, "properties.temp"), subs)
+	assert.Len(t, recorder.events, 1)
+}
+
+func TestRuntime_handleSubscribe_typeTenant(t *testing.T) {
+	var published []string
+	rt := &Runtime{batcher: newChangeBatcher(time.Hour,
+		func(ctx context.Context, entityID string, sub *repository.Subscription, state []byte) error {
+			published = append(published, sub.ID)
+			return nil
+		})}
+	rt.putTypeSubscription(&repository.Subscription{ID: "sub-1", Owner: "tenant01",
+		SourceEntityType: "DEVICE", SourceEntityPaths: []string{"properties.temp"}})
+	rt.putTypeSubscription(&repository.Subscription{ID: "sub-2", Owner: "tenant02",
+		SourceEntityType: "DEVICE", SourceEntityPaths: []string{"properties.temp"}})
+
+	rt.handleSubscribe(context.Background(), &Feed{
+		EntityID: "device123",
+		State:    []byte(`{"id":"device123","type":"DEVICE","owner":"tenant02","properties":{"temp":25}}`),
+		Changes:  []Patch{{Op: xjson.OpReplace, Path: "properties.temp", Value: tdtl.New("25")}},
+	})
+	rt.batcher.flushAll()
+
+	// subscribers of other tenants never see the change.
+	assert.Equal(t, []string{"sub-2"}, published)
+}
@@ -16,6 +16,8 @@ type Patch struct {
 	Op    xjson.PatchOp
 	Path  string
 	Value *tdtl.Collect
+	// Old value before the change, nil if absent.
+	Old *tdtl.Collect
 }
 
 type EntityAttr interface {
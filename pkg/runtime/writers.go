@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tkeel-io/tdtl"
+)
+
+// FieldWriters mirrors the changed property paths, records the last writer and write time
+// of each property, e.g. writers.properties.metrics.cpu = {"_writer":"user:admin","_ts":1664417614285}.
+// keys prefixed with underscore, so records of nested properties live along with them.
+const (
+	FieldWriters string = "writers"
+
+	writerKey          = "_writer"
+	writerTSKey        = "_ts"
+	writerMapperPrefix = "mapper:"
+)
+
+// recordWriters records writer of the changed properties.
+func recordWriters(cc *tdtl.Collect, writer string, changes []Patch) {
+	ts := time.Now().UnixNano() / 1e6
+	record := tdtl.New([]byte(`{}`))
+	record.Set(writerKey, tdtl.NewString(writer))
+	record.Set(writerTSKey, tdtl.NewInt64(ts))
+	for _, change := range changes {
+		if !strings.HasPrefix(change.Path, "properties.") {
+			continue
+		}
+		cc.Set(FieldWriters+"."+change.Path, tdtl.New(record.Raw()))
+	}
+}
+
+// mapperWriter returns writer of properties derived by the expressions, expressions named by name or id.
+func mapperWriter(exprs []ExpressionInfo) string {
+	names := make([]string, 0, len(exprs))
+	for _, expr := range exprs {
+		name := expr.Name
+		if name == "" {
+			name = expr.ID
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return writerMapperPrefix + strings.Join(names, ",")
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheme
+
+import (
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+// ACL restricts property access to callers holding any of the listed roles,
+// an empty role list means no restriction.
+type ACL struct {
+	Read  []string `json:"read,omitempty" mapstructure:"read"`
+	Write []string `json:"write,omitempty" mapstructure:"write"`
+}
+
+func (acl *ACL) Readable(roles []string) bool {
+	if acl == nil {
+		return true
+	}
+	return allowed(acl.Read, roles)
+}
+
+func (acl *ACL) Writable(roles []string) bool {
+	if acl == nil {
+		return true
+	}
+	return allowed(acl.Write, roles)
+}
+
+func allowed(required, roles []string) bool {
+	if len(required) == 0 {
+		return true
+	}
+
+	for _, role := range roles {
+		for _, r := range required {
+			if role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseACLFrom decode acl from property config, returns nil if not configured.
+func ParseACLFrom(data interface{}) (*ACL, error) {
+	cfg, ok := data.(map[string]interface{})
+	if !ok || cfg["acl"] == nil {
+		return nil, nil
+	}
+
+	acl := &ACL{}
+	if err := mapstructure.Decode(cfg["acl"], acl); nil != err {
+		return nil, errors.Wrap(err, "decode property acl")
+	}
+	return acl, nil
+}
+
+// PropertySensitive marks property config sensitive, values of sensitive properties redacted from logs.
+const PropertySensitive = "sensitive"
+
+// RedactedValue replaces values of sensitive properties.
+const RedactedValue = "***"
+
+// SensitivePaths returns paths of sensitive properties in property configs, nested struct fields included.
+func SensitivePaths(configs map[string]interface{}) []string {
+	var paths []string
+	for id, data := range configs {
+		cfg, ok := data.(map[string]interface{})
+		if !ok {
+			continue
+		} else if sensitive, _ := cfg[PropertySensitive].(bool); sensitive {
+			paths = append(paths, id)
+			continue
+		}
+
+		define, _ := cfg["define"].(map[string]interface{})
+		fields, _ := define[DefineFieldStructFields].(map[string]interface{})
+		for _, path := range SensitivePaths(fields) {
+			paths = append(paths, id+"."+path)
+		}
+	}
+	return paths
+}
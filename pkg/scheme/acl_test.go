@@ -0,0 +1,46 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheme
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACL(t *testing.T) {
+	var nilACL *ACL
+	assert.True(t, nilACL.Readable(nil))
+	assert.True(t, nilACL.Writable(nil))
+
+	acl := &ACL{Write: []string{"admin"}}
+	assert.True(t, acl.Readable(nil))
+	assert.False(t, acl.Writable(nil))
+	assert.False(t, acl.Writable([]string{"user"}))
+	assert.True(t, acl.Writable([]string{"user", "admin"}))
+}
+
+func TestParseConfigFrom_ACL(t *testing.T) {
+	cfg, err := ParseConfigFrom(map[string]interface{}{
+		"id":   "firmware_key",
+		"type": "string",
+		"acl":  map[string]interface{}{"read": []string{"admin"}, "write": []string{"admin"}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"admin"}, cfg.ACL.Read)
+	assert.False(t, cfg.ACL.Readable([]string{"user"}))
+}
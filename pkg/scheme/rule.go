@@ -0,0 +1,211 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheme
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+)
+
+const (
+	RuleMin     = "min"
+	RuleMax     = "max"
+	RuleEnum    = "enum"
+	RulePattern = "pattern"
+	// RuleDeadband not a constraint, see Deadband.
+	RuleDeadband = "deadband"
+	// RuleTTL not a constraint, see TTLs.
+	RuleTTL = "ttl"
+)
+
+// Rule restricts property values, Min and Max apply to numbers,
+// Pattern applies to strings and Enum applies to both.
+type Rule struct {
+	Min     *float64      `json:"min,omitempty" mapstructure:"min"`
+	Max     *float64      `json:"max,omitempty" mapstructure:"max"`
+	Enum    []interface{} `json:"enum,omitempty" mapstructure:"enum"`
+	Pattern string        `json:"pattern,omitempty" mapstructure:"pattern"`
+}
+
+// Check returns ErrConstraintViolation naming the property and the violated rule.
+func (r *Rule) Check(propertyID string, value interface{}) error {
+	if r == nil || value == nil {
+		return nil
+	}
+
+	violation := func(rule string, cond interface{}) error {
+		return errors.Wrap(xerrors.ErrConstraintViolation,
+			fmt.Sprintf("property %s violates %s(%v)", propertyID, rule, cond))
+	}
+
+	if number, ok := toNumber(value); ok {
+		if r.Min != nil && number < *r.Min {
+			return violation(RuleMin, *r.Min)
+		} else if r.Max != nil && number > *r.Max {
+			return violation(RuleMax, *r.Max)
+		}
+	}
+
+	if text, ok := value.(string); ok && r.Pattern != "" {
+		matched, err := regexp.MatchString(r.Pattern, text)
+		if nil != err {
+			return errors.Wrap(xerrors.ErrInvalidPropertyConfig, err.Error())
+		} else if !matched {
+			return violation(RulePattern, r.Pattern)
+		}
+	}
+
+	if len(r.Enum) > 0 && !enumContains(r.Enum, value) {
+		return violation(RuleEnum, r.Enum)
+	}
+	return nil
+}
+
+func enumContains(enum []interface{}, value interface{}) bool {
+	number, isNumber := toNumber(value)
+	for _, item := range enum {
+		if n, ok := toNumber(item); ok && isNumber {
+			if n == number {
+				return true
+			}
+		} else if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// ParseRuleFrom decode value rule from property config define, returns nil if not configured.
+func ParseRuleFrom(data interface{}) (*Rule, error) {
+	cfg, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	define, ok := cfg["define"].(map[string]interface{})
+	if !ok || (define[RuleMin] == nil && define[RuleMax] == nil &&
+		define[RuleEnum] == nil && define[RulePattern] == nil) {
+		return nil, nil
+	}
+
+	rule := &Rule{}
+	if err := mapstructure.WeakDecode(define, rule); nil != err {
+		return nil, errors.Wrap(err, "decode property rule")
+	}
+	return rule, nil
+}
+
+// Deadband suppresses writes of numeric property values changed within threshold,
+// Absolute is the threshold of delta and Percent the threshold of delta relative to current value.
+type Deadband struct {
+	Absolute *float64 `json:"absolute,omitempty" mapstructure:"absolute"`
+	Percent  *float64 `json:"percent,omitempty" mapstructure:"percent"`
+}
+
+// Within returns true if value changed from current within any configured threshold.
+func (d *Deadband) Within(current, value interface{}) bool {
+	if d == nil {
+		return false
+	}
+
+	from, ok1 := toNumber(current)
+	to, ok2 := toNumber(value)
+	if !ok1 || !ok2 {
+		return false
+	}
+
+	delta := math.Abs(to - from)
+	if d.Absolute != nil && delta <= *d.Absolute {
+		return true
+	}
+	return d.Percent != nil && delta <= math.Abs(from)**d.Percent/100
+}
+
+// ParseDeadbandFrom decode deadband from property config define, returns nil if not configured.
+func ParseDeadbandFrom(data interface{}) (*Deadband, error) {
+	cfg, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	define, _ := cfg["define"].(map[string]interface{})
+	if define[RuleDeadband] == nil {
+		return nil, nil
+	}
+
+	deadband := &Deadband{}
+	if err := mapstructure.WeakDecode(define[RuleDeadband], deadband); nil != err {
+		return nil, errors.Wrap(err, "decode property deadband")
+	}
+	return deadband, nil
+}
+
+// TTLs returns time to live of properties keyed by property path, nested struct fields included,
+// ttl configured in seconds or as duration string, e.g. "10m".
+func TTLs(configs map[string]interface{}) map[string]time.Duration {
+	ttls := make(map[string]time.Duration)
+	for id, data := range configs {
+		cfg, _ := data.(map[string]interface{})
+		define, _ := cfg["define"].(map[string]interface{})
+		if ttl := parseTTL(define[RuleTTL]); ttl > 0 {
+			ttls[id] = ttl
+			continue
+		}
+
+		fields, _ := define[DefineFieldStructFields].(map[string]interface{})
+		for path, ttl := range TTLs(fields) {
+			ttls[id+"."+path] = ttl
+		}
+	}
+	return ttls
+}
+
+func parseTTL(data interface{}) time.Duration {
+	if text, ok := data.(string); ok {
+		ttl, _ := time.ParseDuration(text)
+		return ttl
+	} else if seconds, ok := toNumber(data); ok {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return 0
+}
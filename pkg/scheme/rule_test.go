@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheme
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+)
+
+func TestRule_MinMax(t *testing.T) {
+	min, max := 0.0, 100.0
+	rule := &Rule{Min: &min, Max: &max}
+	assert.Nil(t, rule.Check("temp", 0.0))
+	assert.Nil(t, rule.Check("temp", 100))
+	assert.Nil(t, rule.Check("temp", 50.5))
+	assert.ErrorIs(t, rule.Check("temp", -0.1), xerrors.ErrConstraintViolation)
+	assert.ErrorIs(t, rule.Check("temp", 100.1), xerrors.ErrConstraintViolation)
+	assert.Contains(t, rule.Check("temp", 101).Error(), "property temp violates max")
+	// not applied to strings.
+	assert.Nil(t, rule.Check("temp", "1000"))
+}
+
+func TestRule_Pattern(t *testing.T) {
+	rule := &Rule{Pattern: `^[a-z]+-\d{3}$`}
+	assert.Nil(t, rule.Check("serial", "abc-123"))
+	assert.ErrorIs(t, rule.Check("serial", "abc-12"), xerrors.ErrConstraintViolation)
+	assert.Contains(t, rule.Check("serial", "ABC-123").Error(), "property serial violates pattern")
+	// not applied to numbers.
+	assert.Nil(t, rule.Check("serial", 123))
+
+	rule = &Rule{Pattern: `[`}
+	assert.ErrorIs(t, rule.Check("serial", "abc"), xerrors.ErrInvalidPropertyConfig)
+}
+
+func TestRule_Enum(t *testing.T) {
+	rule := &Rule{Enum: []interface{}{"on", "off", 1.0}}
+	assert.Nil(t, rule.Check("status", "on"))
+	assert.Nil(t, rule.Check("status", 1))
+	assert.ErrorIs(t, rule.Check("status", "auto"), xerrors.ErrConstraintViolation)
+	assert.ErrorIs(t, rule.Check("status", 2), xerrors.ErrConstraintViolation)
+	assert.Contains(t, rule.Check("status", "auto").Error(), "property status violates enum")
+
+	var nilRule *Rule
+	assert.Nil(t, nilRule.Check("status", "auto"))
+	assert.Nil(t, rule.Check("status", nil))
+}
+
+func TestParseRuleFrom(t *testing.T) {
+	rule, err := ParseRuleFrom(map[string]interface{}{"type": "int"})
+	assert.Nil(t, err)
+	assert.Nil(t, rule)
+
+	rule, err = ParseRuleFrom(map[string]interface{}{
+		"type":   "int",
+		"define": map[string]interface{}{"min": 1, "max": "10", "enum": []interface{}{1, 5, 10}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, *rule.Min)
+	assert.Equal(t, 10.0, *rule.Max)
+	assert.Len(t, rule.Enum, 3)
+
+	_, err = ParseRuleFrom(map[string]interface{}{"define": map[string]interface{}{"max": "ten"}})
+	assert.NotNil(t, err)
+}
+
+func TestDeadband_Within(t *testing.T) {
+	absolute, percent := 0.5, 10.0
+	deadband := &Deadband{Absolute: &absolute}
+	assert.True(t, deadband.Within(20.0, 20.5))
+	assert.True(t, deadband.Within(20, 19.6))
+	assert.False(t, deadband.Within(20.0, 20.6))
+	// not applied to non-numbers.
+	assert.False(t, deadband.Within("20", 20.1))
+	assert.False(t, deadband.Within(nil, 20.1))
+
+	deadband = &Deadband{Percent: &percent}
+	assert.True(t, deadband.Within(200, 220))
+	assert.False(t, deadband.Within(200, 179))
+	assert.False(t, deadband.Within(0, 0.1))
+
+	var none *Deadband
+	assert.False(t, none.Within(20, 20))
+}
+
+func TestParseDeadbandFrom(t *testing.T) {
+	deadband, err := ParseDeadbandFrom(map[string]interface{}{
+		"define": map[string]interface{}{"deadband": map[string]interface{}{"absolute": "0.5", "percent": 5}}})
+	assert.Nil(t, err)
+	assert.Equal(t, 0.5, *deadband.Absolute)
+	assert.Equal(t, 5.0, *deadband.Percent)
+
+	deadband, err = ParseDeadbandFrom(map[string]interface{}{"define": map[string]interface{}{"min": 1}})
+	assert.Nil(t, err)
+	assert.Nil(t, deadband)
+}
+
+func TestTTLs(t *testing.T) {
+	ttls := TTLs(map[string]interface{}{
+		"temp":  map[string]interface{}{"define": map[string]interface{}{"ttl": 90}},
+		"state": map[string]interface{}{"define": map[string]interface{}{"ttl": "10m"}},
+		"mode":  map[string]interface{}{"define": map[string]interface{}{"ttl": "never"}},
+		"metrics": map[string]interface{}{"define": map[string]interface{}{
+			"fields": map[string]interface{}{
+				"cpu": map[string]interface{}{"define": map[string]interface{}{"ttl": 0.5}},
+			}}},
+	})
+	assert.Equal(t, map[string]time.Duration{
+		"temp":        90 * time.Second,
+		"state":       10 * time.Minute,
+		"metrics.cpu": 500 * time.Millisecond,
+	}, ttls)
+}
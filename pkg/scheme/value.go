@@ -39,10 +39,18 @@ const (
 	PropertyTypeArray  = "array"
 	PropertyTypeStruct = "struct"
 	PropertyTypeEnum   = "enum"
+	PropertyTypeBlob   = "blob"
 
 	DefineFieldArrayLength  = "length"
 	DefineFieldArrayElemCfg = "elem_type"
 	DefineFieldStructFields = "fields"
+	// DefineFieldComputed expression of virtual property, evaluated on read.
+	DefineFieldComputed = "computed"
+
+	// AdditionalProperties reserved key of the schema, not a property config. false rejects writes
+	// of properties not declared by the schema with ErrUnknownProperty, absent or true accepts them.
+	// set it for a whole type through SetConfigsByType.
+	AdditionalProperties = "additionalProperties"
 )
 
 type Config struct {
@@ -55,6 +63,7 @@ type Config struct {
 	EnabledTimeSeries bool                   `json:"enabled_time_series" mapstructure:"enabled_time_series"`
 	Description       string                 `json:"description" mapstructure:"description"`
 	Define            map[string]interface{} `json:"define" mapstructure:"define"`
+	ACL               *ACL                   `json:"acl,omitempty" mapstructure:"acl"`
 	LastTime          int64                  `json:"last_time" mapstructure:"last_time"`
 }
 
@@ -138,7 +147,9 @@ func Parse(bytes []byte) (map[string]*Config, error) {
 	var cfg Config
 	cfgs := make(map[string]*Config)
 	for key, val := range configs {
-		if cfg, err = ParseConfigFrom(val); nil != err {
+		if key == AdditionalProperties {
+			continue
+		} else if cfg, err = ParseConfigFrom(val); nil != err {
 			// TODO: dispose error.
 			log.L().Error("parse configs", logf.Error(err))
 			continue
@@ -178,6 +189,7 @@ func parseField(in Config) (out Config, err error) {
 	case PropertyTypeDouble:
 	case PropertyTypeString:
 	case PropertyTypeEnum:
+	case PropertyTypeBlob:
 	case PropertyTypeArray:
 		arrDefine := DefineArray{}
 		if err = mapstructure.Decode(in.Define, &arrDefine); nil != err {
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	apim "github.com/tkeel-io/core/pkg/manager"
+	"github.com/tkeel-io/core/pkg/scheme"
+	"github.com/tkeel-io/core/pkg/types"
+	"github.com/tkeel-io/kit/log"
+)
+
+// parseRolesFrom returns caller roles carried by ctx.
+func parseRolesFrom(ctx context.Context) []string {
+	identity, _ := types.IdentityFrom(ctx)
+	return identity.Roles
+}
+
+func propertyACL(configs map[string]interface{}, propertyID string) (*scheme.ACL, error) {
+	acl, err := scheme.ParseACLFrom(configs[propertyID])
+	return acl, errors.Wrap(err, "parse property acl")
+}
+
+// redactProperties omit properties which the caller can not read.
+func redactProperties(props, configs map[string]interface{}, roles []string) {
+	for propertyID := range props {
+		acl, err := propertyACL(configs, propertyID)
+		if nil != err || !acl.Readable(roles) {
+			delete(props, propertyID)
+		}
+	}
+}
+
+// checkPropertiesWritable check the caller can write the properties, paths relative to properties.
+func checkPropertiesWritable(configs map[string]interface{}, roles []string, paths ...string) error {
+	for _, path := range paths {
+		propertyID := strings.SplitN(path, sep, 2)[0]
+		acl, err := propertyACL(configs, propertyID)
+		if nil != err {
+			return errors.Wrap(err, "check property writable")
+		} else if !acl.Writable(roles) {
+			log.L().Warn("write forbidden property", logf.Key(propertyID),
+				logf.Any("roles", roles), logf.Error(xerrors.ErrForbiddenProperty))
+			return xerrors.ErrForbiddenProperty
+		}
+	}
+	return nil
+}
+
+// checkWritable check the caller can write the properties, returns the current entity.
+func (s *EntityService) checkWritable(ctx context.Context, en *Entity, paths ...string) (*apim.BaseRet, error) {
+	baseRet, err := s.apiManager.GetEntity(ctx, en)
+	if nil != err {
+		return nil, errors.Wrap(err, "check property writable")
+	}
+	return baseRet, checkPropertiesWritable(baseRet.Scheme, parseRolesFrom(ctx), paths...)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/types"
+	"github.com/tkeel-io/kit/log"
+)
+
+// identityClaims claims of identity tokens.
+type identityClaims struct {
+	User   string   `json:"user"`
+	Tenant string   `json:"tenant"`
+	Roles  []string `json:"roles"`
+	// Expires unix seconds the token expires at, never if zero.
+	Expires int64 `json:"exp"`
+}
+
+// verifyIdentity verify HS256 identity token signed with secret, returns the identity claimed.
+func verifyIdentity(token string, secret []byte) (types.Identity, error) {
+	if len(secret) == 0 {
+		return types.Identity{}, errors.Wrap(xerrors.ErrUnauthenticated, "identity token, no secret configured")
+	}
+
+	segs := strings.Split(token, ".")
+	if len(segs) != 3 {
+		return types.Identity{}, errors.Wrap(xerrors.ErrUnauthenticated, "identity token, malformed")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(segs[0], &header); nil != err || header.Alg != "HS256" {
+		return types.Identity{}, errors.Wrap(xerrors.ErrUnauthenticated, "identity token, algorithm")
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(segs[0] + "." + segs[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(segs[2])
+	if nil != err || !hmac.Equal(signature, mac.Sum(nil)) {
+		return types.Identity{}, errors.Wrap(xerrors.ErrUnauthenticated, "identity token, signature")
+	}
+
+	var claims identityClaims
+	if err = decodeSegment(segs[1], &claims); nil != err {
+		return types.Identity{}, errors.Wrap(xerrors.ErrUnauthenticated, "identity token, claims")
+	} else if claims.Expires > 0 && time.Now().Unix() >= claims.Expires {
+		return types.Identity{}, errors.Wrap(xerrors.ErrUnauthenticated, "identity token, expired")
+	}
+
+	return types.Identity{User: claims.User, Tenant: claims.Tenant, Roles: claims.Roles}, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	bytes, err := base64.RawURLEncoding.DecodeString(seg)
+	if nil != err {
+		return errors.Wrap(err, "decode token segment")
+	}
+	return errors.Wrap(json.Unmarshal(bytes, v), "decode token segment")
+}
+
+// AuthFilter rejects requests carrying identity tokens failing verification,
+// requests without token pass through carrying no identity.
+func AuthFilter(secret string) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		if token := req.HeaderParameter(HeaderIdentity); token != "" {
+			if _, err := verifyIdentity(token, []byte(secret)); nil != err {
+				log.L().Warn("reject request", logf.URL(req.Request.URL.Path), logf.Error(err))
+				resp.WriteErrorString(http.StatusUnauthorized, xerrors.ErrUnauthenticated.Error()) //nolint
+				return
+			}
+		}
+		chain.ProcessFilter(req, resp)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	apim "github.com/tkeel-io/core/pkg/manager"
+	"github.com/tkeel-io/core/pkg/resource/blob"
+	"github.com/tkeel-io/core/pkg/scheme"
+	xjson "github.com/tkeel-io/core/pkg/util/json"
+	"github.com/tkeel-io/kit/log"
+)
+
+func isBlobProperty(configs map[string]interface{}, propertyID string) bool {
+	cfg, ok := configs[propertyID].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	typ, _ := cfg["type"].(string)
+	return typ == scheme.PropertyTypeBlob
+}
+
+func blobKey(entityID, propertyID string) string {
+	return strings.Join([]string{entityID, propertyID}, "/")
+}
+
+// uploadBlob upload blob property value, returns the reference kept in entity state.
+func (s *EntityService) uploadBlob(ctx context.Context, configs map[string]interface{}, entityID, propertyID string, value interface{}) (interface{}, error) {
+	if !isBlobProperty(configs, propertyID) {
+		return value, nil
+	} else if s.blobStore == nil {
+		return nil, errors.Wrap(xerrors.ErrServerNotReady, "blob store not configured")
+	}
+
+	// blob value encoded by base64.
+	encoded, ok := value.(string)
+	if !ok {
+		return nil, errors.Wrap(xerrors.ErrInvalidRequest, "blob value must be base64 string")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if nil != err {
+		return nil, errors.Wrap(xerrors.ErrInvalidRequest, "decode blob value")
+	}
+
+	ref, err := s.blobStore.Put(ctx, blobKey(entityID, propertyID), data)
+	if nil != err {
+		return nil, errors.Wrap(err, "upload blob")
+	}
+
+	return blob.Reference{Ref: ref, Size: int64(len(data))}.Value(), nil
+}
+
+func (s *EntityService) uploadBlobPatch(ctx context.Context, configs map[string]interface{}, entityID string, pd PatchData) (interface{}, error) {
+	segs := strings.Split(pd.Path, ".")
+	if !isBlobProperty(configs, segs[0]) {
+		return pd.Value, nil
+	} else if len(segs) > 1 {
+		// blob property can only be patched as a whole.
+		return nil, xerrors.ErrPatchPathInvalid
+	} else if xjson.NewPatchOp(pd.Operator) == xjson.OpRemove {
+		return pd.Value, nil
+	}
+
+	return s.uploadBlob(ctx, configs, entityID, segs[0], pd.Value)
+}
+
+// resolveBlobs replace blob references with base64 encoded blob data.
+func (s *EntityService) resolveBlobs(ctx context.Context, props, configs map[string]interface{}) error {
+	if s.blobStore == nil {
+		return nil
+	}
+
+	for propertyID, value := range props {
+		if !isBlobProperty(configs, propertyID) {
+			continue
+		}
+
+		ref, ok := blob.ParseReference(value)
+		if !ok {
+			continue
+		}
+
+		data, err := s.blobStore.Get(ctx, ref.Ref)
+		if nil != err {
+			return errors.Wrap(err, "resolve blob")
+		}
+		props[propertyID] = base64.StdEncoding.EncodeToString(data)
+	}
+	return nil
+}
+
+// deleteBlobs garbage collect blobs referenced by the entity, all blob properties if propertyIDs empty.
+func (s *EntityService) deleteBlobs(ctx context.Context, current *apim.BaseRet, propertyIDs ...string) {
+	if current == nil || s.blobStore == nil {
+		return
+	}
+
+	if len(propertyIDs) == 0 {
+		for propertyID := range current.Properties {
+			propertyIDs = append(propertyIDs, propertyID)
+		}
+	}
+
+	for _, propertyID := range propertyIDs {
+		if !isBlobProperty(current.Scheme, propertyID) {
+			continue
+		}
+
+		ref, ok := blob.ParseReference(current.Properties[propertyID])
+		if !ok {
+			continue
+		}
+
+		if err := s.blobStore.Delete(ctx, ref.Ref); nil != err {
+			log.L().Error("delete blob", logf.Eid(current.ID),
+				logf.String("property", propertyID), logf.Error(err))
+		}
+	}
+}
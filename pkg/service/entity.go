@@ -20,15 +20,22 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
 	pb "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
 	xerrors "github.com/tkeel-io/core/pkg/errors"
 	logf "github.com/tkeel-io/core/pkg/logfield"
 	apim "github.com/tkeel-io/core/pkg/manager"
 	"github.com/tkeel-io/core/pkg/mapper"
+	"github.com/tkeel-io/core/pkg/resource"
+	"github.com/tkeel-io/core/pkg/resource/blob"
+	"github.com/tkeel-io/core/pkg/resource/search"
+	"github.com/tkeel-io/core/pkg/resource/search/driver"
 	"github.com/tkeel-io/core/pkg/scheme"
+	"github.com/tkeel-io/core/pkg/types"
 	xjson "github.com/tkeel-io/core/pkg/util/json"
 	"github.com/tkeel-io/kit/log"
 	"github.com/tkeel-io/tdtl"
@@ -68,15 +75,17 @@ type EntityService struct {
 	cancel       context.CancelFunc
 	apiManager   apim.APIManager
 	searchClient pb.SearchHTTPServer
+	blobStore    blob.BlobStore
 }
 
 func NewEntityService(ctx context.Context) (*EntityService, error) {
 	ctx, cancel := context.WithCancel(ctx)
 
 	return &EntityService{
-		ctx:    ctx,
-		cancel: cancel,
-		inited: atomic.NewBool(false),
+		ctx:       ctx,
+		cancel:    cancel,
+		inited:    atomic.NewBool(false),
+		blobStore: blob.NewBlobStore(resource.ParseFrom(config.Get().Components.Blob)),
 	}, nil
 }
 
@@ -98,7 +107,7 @@ func (s *EntityService) CreateEntity(ctx context.Context, req *pb.CreateEntityRe
 	entity.Type = req.Type
 	entity.Source = req.Source
 	entity.TemplateID = req.From
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
 	properties := req.Properties.AsInterface()
 	switch properties.(type) {
 	case map[string]interface{}:
@@ -139,16 +148,16 @@ func (s *EntityService) UpdateEntity(ctx context.Context, req *pb.UpdateEntityRe
 	entity.Type = req.Type
 	entity.Owner = req.Owner
 	entity.Source = req.Source
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
 	patches := []*pb.PatchData{}
 
 	log.L().Debug("update entity",
 		logf.Eid(req.Id), logf.Owner(entity.Owner),
 		logf.Template(req.TemplateId), logf.Desc(req.Description),
-		logf.Any("scheme", req.Configs), logf.Any("properties", req.Properties))
+		logf.Any("scheme", req.Configs))
 
 	properties := req.Properties.AsInterface()
-	switch properties.(type) {
+	switch props := properties.(type) {
 	case map[string]interface{}:
 		if entity.Properties, err = json.Marshal(properties); nil != err {
 			log.L().Error("create entity, invalid params", logf.Reason(err.Error()),
@@ -156,6 +165,15 @@ func (s *EntityService) UpdateEntity(ctx context.Context, req *pb.UpdateEntityRe
 			return out, errors.Wrap(err, "create entity")
 		}
 
+		propertyIDs := make([]string, 0, len(props))
+		for propertyID := range props {
+			propertyIDs = append(propertyIDs, propertyID)
+		}
+		if _, err = s.checkWritable(ctx, entity, propertyIDs...); nil != err {
+			log.L().Error("update entity failed.", logf.Eid(req.Id), logf.Error(err))
+			return out, errors.Wrap(err, "update entity failed")
+		}
+
 		// patch merge properties.
 		if len(entity.Properties) > 0 {
 			patches = append(patches, &pb.PatchData{
@@ -235,7 +253,8 @@ func (s *EntityService) GetEntity(ctx context.Context, req *pb.GetEntityRequest)
 	entity.Type = req.Type
 	entity.Owner = req.Owner
 	entity.Source = req.Source
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
+	ctx = apim.WithReadPreference(ctx, parseReadPreferenceFrom(ctx))
 
 	var baseRet *apim.BaseRet
 	if baseRet, err = s.apiManager.GetEntity(ctx, entity); nil != err {
@@ -243,6 +262,8 @@ func (s *EntityService) GetEntity(ctx context.Context, req *pb.GetEntityRequest)
 		return out, errors.Wrap(err, "get entity")
 	}
 
+	baseRet.SetComputed(baseRet.EvalComputed(ctx))
+	redactProperties(baseRet.Properties, baseRet.Scheme, parseRolesFrom(ctx))
 	out, err = s.makeResponse(baseRet)
 	return out, errors.Wrap(err, "get entity")
 }
@@ -258,7 +279,15 @@ func (s *EntityService) DeleteEntity(ctx context.Context, req *pb.DeleteEntityRe
 	entity.Type = req.Type
 	entity.Owner = req.Owner
 	entity.Source = req.Source
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
+
+	// hold blob references before delete.
+	var current *apim.BaseRet
+	if s.blobStore != nil {
+		if current, err = s.apiManager.GetEntity(ctx, entity); nil != err {
+			log.L().Warn("delete entity, get blob references", logf.Error(err), logf.ID(req.Id))
+		}
+	}
 
 	// delete entity.
 	if err = s.apiManager.DeleteEntity(ctx, entity); nil != err {
@@ -266,6 +295,9 @@ func (s *EntityService) DeleteEntity(ctx context.Context, req *pb.DeleteEntityRe
 		return nil, errors.Wrap(err, "delete entity")
 	}
 
+	// garbage collect entity blobs.
+	s.deleteBlobs(ctx, current)
+
 	return &pb.DeleteEntityResponse{Id: req.Id, Status: "ok"}, nil
 }
 
@@ -280,25 +312,38 @@ func (s *EntityService) UpdateEntityProps(ctx context.Context, req *pb.UpdateEnt
 	entity.Type = req.Type
 	entity.Owner = req.Owner
 	entity.Source = req.Source
-	parseHeaderFrom(ctx, entity)
-	properties := req.Properties.AsInterface()
-	switch properties.(type) {
-	case map[string]interface{}:
-		if entity.Properties, err = json.Marshal(properties); nil != err {
-			log.L().Error("create entity, but invalid params",
-				logf.Eid(req.Id), logf.Error(xerrors.ErrInvalidEntityParams))
-			return out, errors.Wrap(err, "create entity")
-		}
-	case nil:
-		log.L().Error("update entity failed.",
-			logf.Eid(req.Id), logf.Error(xerrors.ErrInvalidRequest))
-		return nil, xerrors.ErrInvalidRequest
-	default:
+	ctx = parseHeaderFrom(ctx, entity)
+	properties, ok := req.Properties.AsInterface().(map[string]interface{})
+	if !ok {
 		log.L().Error("update entity failed.",
 			logf.Eid(req.Id), logf.Error(xerrors.ErrInvalidRequest))
 		return nil, xerrors.ErrInvalidRequest
 	}
 
+	var current *apim.BaseRet
+	propertyIDs := make([]string, 0)
+	for propertyID := range properties {
+		propertyIDs = append(propertyIDs, propertyID)
+	}
+	if current, err = s.checkWritable(ctx, entity, propertyIDs...); nil != err {
+		log.L().Error("update entity properties.", logf.Eid(req.Id), logf.Error(err))
+		return out, errors.Wrap(err, "update entity properties")
+	}
+
+	// upload blob properties.
+	for propertyID, value := range properties {
+		if properties[propertyID], err = s.uploadBlob(ctx, current.Scheme, entity.ID, propertyID, value); nil != err {
+			log.L().Error("update entity properties.", logf.Eid(req.Id), logf.Error(err))
+			return out, errors.Wrap(err, "update entity properties")
+		}
+	}
+
+	if entity.Properties, err = json.Marshal(properties); nil != err {
+		log.L().Error("create entity, but invalid params",
+			logf.Eid(req.Id), logf.Error(xerrors.ErrInvalidEntityParams))
+		return out, errors.Wrap(err, "create entity")
+	}
+
 	patches := []*pb.PatchData{{
 		Path:     FieldProps,
 		Operator: xjson.OpMerge.String(),
@@ -306,11 +351,15 @@ func (s *EntityService) UpdateEntityProps(ctx context.Context, req *pb.UpdateEnt
 	}}
 
 	var baseRet *apim.BaseRet
-	if baseRet, _, err = s.apiManager.PatchEntity(ctx, entity, patches); nil != err {
+	namespace := parseNamespaceFrom(ctx)
+	if baseRet, _, err = s.apiManager.PatchEntity(ctx, entity, patches, patchOptions(ctx, namespace)...); nil != err {
 		log.L().Error("update entity properties.", logf.Eid(req.Id), logf.Error(err))
 		return out, errors.Wrap(err, "update entity properties")
 	}
 
+	if namespace != "" {
+		baseRet.Properties = apim.StripNamespace(baseRet.Properties, namespace)
+	}
 	out, err = s.makeResponse(baseRet)
 	return out, errors.Wrap(err, "update entity properties")
 }
@@ -326,7 +375,7 @@ func (s *EntityService) PatchEntityProps(ctx context.Context, req *pb.PatchEntit
 	entity.Type = req.Type
 	entity.Owner = req.Owner
 	entity.Source = req.Source
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
 
 	patches := []*pb.PatchData{}
 	params := req.Properties.AsInterface()
@@ -342,11 +391,24 @@ func (s *EntityService) PatchEntityProps(ctx context.Context, req *pb.PatchEntit
 			return nil, errors.Wrap(err, "json unmarshal patch data")
 		}
 
+		var current *apim.BaseRet
+		paths := make([]string, 0, len(patchData))
+		for index := range patchData {
+			paths = append(paths, patchData[index].Path)
+		}
+		if current, err = s.checkWritable(ctx, entity, paths...); nil != err {
+			log.L().Error("patch entity properties.", logf.Eid(req.Id), logf.Error(err))
+			return nil, errors.Wrap(err, "patch entity properties")
+		}
+
 		for index := range patchData {
 			var bytes []byte
 			if err = checkPatchData(patchData[index]); nil != err {
 				log.L().Error("patch entity properties.", logf.Eid(req.Id), logf.Error(err))
 				return nil, errors.Wrap(err, "patch entity properties")
+			} else if patchData[index].Value, err = s.uploadBlobPatch(ctx, current.Scheme, entity.ID, patchData[index]); nil != err {
+				log.L().Error("patch entity properties.", logf.Eid(req.Id), logf.Error(err))
+				return nil, errors.Wrap(err, "patch entity properties")
 			} else if bytes, err = json.Marshal(patchData[index].Value); nil != err {
 				return nil, errors.Wrap(err, "encode property")
 			}
@@ -364,13 +426,16 @@ func (s *EntityService) PatchEntityProps(ctx context.Context, req *pb.PatchEntit
 
 	var rawEntity []byte
 	var baseRet *apim.BaseRet
-	if baseRet, rawEntity, err = s.apiManager.PatchEntity(ctx, entity, patches); nil != err {
+	namespace := parseNamespaceFrom(ctx)
+	if baseRet, rawEntity, err = s.apiManager.PatchEntity(ctx, entity, patches, patchOptions(ctx, namespace)...); nil != err {
 		log.L().Error("patch entity properties.", logf.Eid(req.Id), logf.Error(err))
 		return nil, errors.Wrap(err, "patch entity properties")
 	}
 
-	// clip copy properties.
-	if properties, cpflag, innerErr := CopyFrom(rawEntity, patches...); nil != innerErr {
+	// clip copy properties, namespaced writes respond the namespace view.
+	if namespace != "" {
+		baseRet.Properties = apim.StripNamespace(baseRet.Properties, namespace)
+	} else if properties, cpflag, innerErr := CopyFrom(rawEntity, patches...); nil != innerErr {
 		log.L().Warn("patch entity properties.", logf.Eid(req.Id), logf.Reason(err.Error()))
 	} else if cpflag {
 		baseRet.Properties = properties
@@ -404,11 +469,17 @@ func (s *EntityService) GetEntityProps(ctx context.Context, in *pb.GetEntityProp
 	entity.Type = in.Type
 	entity.Owner = in.Owner
 	entity.Source = in.Source
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
 
-	var propKeys []string
+	var keys, propKeys []string
+	namespace := parseNamespaceFrom(ctx)
 	if pidsStr := strings.TrimSpace(in.PropertyKeys); len(pidsStr) > 0 {
 		for _, key := range strings.Split(pidsStr, ",") {
+			keys = append(keys, key)
+			if namespace != "" {
+				// clip properties of the namespace.
+				key = types.NamespacedKey(namespace, key)
+			}
 			propKeys = append(propKeys, propKey(key))
 		}
 	}
@@ -421,6 +492,9 @@ func (s *EntityService) GetEntityProps(ctx context.Context, in *pb.GetEntityProp
 		return out, errors.Wrap(err, "get entity properties")
 	}
 
+	// evaluate computed properties, on all properties before clipped.
+	computed := baseRet.EvalComputed(ctx)
+
 	// clip copy properties.
 	if props, cpflag, innerErr := CopyFrom2(rawEntity, propKeys...); nil != innerErr {
 		log.L().Warn("patch entity properties.", logf.Eid(in.Id), logf.Reason(innerErr.Error()))
@@ -428,6 +502,32 @@ func (s *EntityService) GetEntityProps(ctx context.Context, in *pb.GetEntityProp
 		baseRet.Properties = props
 	}
 
+	// filter properties by provenance.
+	if source := parsePropSourceFrom(ctx); source != "" {
+		var provenances map[string]apim.Provenance
+		if provenances, err = s.apiManager.GetProvenance(ctx, entity); nil != err {
+			log.L().Error("get entity properties provenance", logf.Eid(in.Id), logf.Error(err))
+			return out, errors.Wrap(err, "get entity properties")
+		}
+		baseRet.Properties = apim.FilterProperties(baseRet.Properties, provenances, source)
+	}
+
+	// view properties of the namespace under plain keys.
+	if namespace != "" {
+		baseRet.Properties = apim.StripNamespace(baseRet.Properties, namespace)
+	}
+
+	baseRet.SetComputed(computed, keys...)
+	redactProperties(baseRet.Properties, baseRet.Scheme, parseRolesFrom(ctx))
+
+	// resolve blob data on request.
+	if parseResolveBlobFrom(ctx) {
+		if err = s.resolveBlobs(ctx, baseRet.Properties, baseRet.Scheme); nil != err {
+			log.L().Error("get entity properties, resolve blob", logf.Eid(in.Id), logf.Error(err))
+			return out, errors.Wrap(err, "get entity properties")
+		}
+	}
+
 	baseRet.Scheme = nil
 	out, err = s.makeResponse(baseRet)
 	return out, errors.Wrap(err, "get entity properties")
@@ -444,7 +544,7 @@ func (s *EntityService) RemoveEntityProps(ctx context.Context, in *pb.RemoveEnti
 	entity.Type = in.Type
 	entity.Owner = in.Owner
 	entity.Source = in.Source
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
 
 	var propertyKeys []string
 	if propertyKeys = strings.Split(strings.TrimSpace(in.PropertyKeys), ","); len(propertyKeys) == 0 {
@@ -452,6 +552,12 @@ func (s *EntityService) RemoveEntityProps(ctx context.Context, in *pb.RemoveEnti
 		return out, xerrors.ErrInvalidRequest
 	}
 
+	var current *apim.BaseRet
+	if current, err = s.checkWritable(ctx, entity, propertyKeys...); nil != err {
+		log.L().Error("remove entity properties.", logf.Eid(in.Id), logf.Error(err))
+		return out, errors.Wrap(err, "remove entity properties")
+	}
+
 	patches := make([]*pb.PatchData, 0)
 	for index := range propertyKeys {
 		patches = append(patches, &pb.PatchData{
@@ -467,6 +573,9 @@ func (s *EntityService) RemoveEntityProps(ctx context.Context, in *pb.RemoveEnti
 		return out, errors.Wrap(err, "remove entity properties")
 	}
 
+	// garbage collect removed blobs.
+	s.deleteBlobs(ctx, current, propertyKeys...)
+
 	out, err = s.makeResponse(baseRet)
 	return out, errors.Wrap(err, "remove entity properties")
 }
@@ -483,7 +592,7 @@ func (s *EntityService) UpdateEntityConfigs(ctx context.Context, in *pb.UpdateEn
 	entity.Type = in.Type
 	entity.Owner = in.Owner
 	entity.Source = in.Source
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
 	param := in.Configs.AsInterface()
 	switch param.(type) {
 	// TODO: 这里在后面调整 API 的时候换成 map[string]interfae{}.
@@ -530,7 +639,7 @@ func (s *EntityService) PatchEntityConfigs(ctx context.Context, in *pb.PatchEnti
 	entity.Type = in.Type
 	entity.Owner = in.Owner
 	entity.Source = in.Source
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
 
 	var patches []*pb.PatchData
 	param := in.Configs.AsInterface()
@@ -549,13 +658,17 @@ func (s *EntityService) PatchEntityConfigs(ctx context.Context, in *pb.PatchEnti
 				return nil, errors.Wrap(err, "patch entity scheme")
 			}
 
-			var cfg scheme.Config
+			var cfg interface{} = scheme.Config{}
 			switch value := patchData[index].Value.(type) {
 			case map[string]interface{}:
 				if cfg, err = scheme.ParseConfigFrom(value); nil != err {
 					log.L().Error("check entity scheme.", logf.Eid(in.Id), logf.Error(err))
 					return out, errors.Wrap(err, "parse entity scheme")
 				}
+			case bool:
+				if patchData[index].Path == scheme.AdditionalProperties {
+					cfg = value
+				}
 			}
 
 			var bytes []byte
@@ -617,7 +730,7 @@ func (s *EntityService) GetEntityConfigs(ctx context.Context, in *pb.GetEntityCo
 	entity.Type = in.Type
 	entity.Owner = in.Owner
 	entity.Source = in.Source
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
 
 	// set properties.
 	var propKeys []string
@@ -664,7 +777,7 @@ func (s *EntityService) RemoveEntityConfigs(ctx context.Context, in *pb.RemoveEn
 	entity.Type = in.Type
 	entity.Owner = in.Owner
 	entity.Source = in.Source
-	parseHeaderFrom(ctx, entity)
+	ctx = parseHeaderFrom(ctx, entity)
 
 	// set properties.
 	propertyIDs := strings.Split(in.PropertyKeys, ",")
@@ -692,6 +805,11 @@ func (s *EntityService) ListEntity(ctx context.Context, req *pb.ListEntityReques
 		return nil, errors.Wrap(xerrors.ErrServerNotReady, "service not ready")
 	}
 
+	if req.PageNum, req.PageSize, err = pageOf(req.PageNum, req.PageSize); nil != err {
+		log.L().Warn("list entity", logf.Owner(req.Owner), logf.Error(err))
+		return nil, errors.Wrap(err, "list entity")
+	}
+
 	searchReq := &pb.SearchRequest{}
 	searchReq.Query = req.Query
 	searchReq.PageNum = req.PageNum
@@ -710,17 +828,24 @@ func (s *EntityService) ListEntity(ctx context.Context, req *pb.ListEntityReques
 		searchReq.OrderBy += ".keyword"
 	}
 
+	if fields := parseHighlightFrom(ctx); len(fields) > 0 {
+		ctx = search.WithHighlight(ctx, fields...)
+	}
+
 	var resp *pb.SearchResponse
-	if resp, err = s.searchClient.Search(ctx, searchReq); err != nil {
+	if resp, err = searchWith(ctx, s.searchClient, searchReq, req.Owner); err != nil {
 		log.L().Error("list entity.", logf.Error(err))
 		return out, errors.Wrap(err, "list entity")
 	}
 
+	readRepair := parseReadRepairFrom(ctx)
+
 	out = &pb.ListEntityResponse{}
 	out.Total = int32(resp.Total)
 	out.PageNum = resp.PageNum
 	out.PageSize = resp.PageSize
-	for _, item := range resp.Items {
+	out.Stale = resp.Stale
+	for index, item := range resp.Items {
 		switch kv := item.AsInterface().(type) {
 		case map[string]interface{}:
 			// state := interface2string(kv[runtime.FieldEntitySource])
@@ -736,6 +861,12 @@ func (s *EntityService) ListEntity(ctx context.Context, req *pb.ListEntityReques
 			baseRet.Properties["basicInfo"] = kv["basicInfo"]
 			baseRet.Properties["connectInfo"] = kv["connectInfo"]
 			baseRet.Properties["group"] = kv["group"]
+			baseRet.Properties[apim.FieldMemberOf] = kv[apim.FieldMemberOf]
+
+			// repair hits lagging the state, in the background.
+			if readRepair {
+				s.apiManager.RepairIndex(baseRet.ID, kv)
+			}
 
 			if baseRet.Type == "group" && baseRet.Properties["group"] == nil {
 				entity := new(Entity)
@@ -749,7 +880,12 @@ func (s *EntityService) ListEntity(ctx context.Context, req *pb.ListEntityReques
 				}
 				log.L().Warn("reload entity OK", logf.Eid(interface2string(kv["id"])), zap.Error(err))
 			}
+
+			baseRet.Score, baseRet.Highlight = decodeSearchHit(kv)
 			entityItem, _ := s.makeResponse(baseRet)
+			if index < len(resp.Refs) && nil != entityItem {
+				entityItem.Refs = resp.Refs[index]
+			}
 			out.Items = append(out.Items, entityItem)
 		}
 	}
@@ -789,7 +925,8 @@ func parseSchemeFrom(data interface{}) (out map[string]*scheme.Config, err error
 }
 
 // parseHeaderFrom parse headers.
-func parseHeaderFrom(ctx context.Context, en *apim.Base) {
+// parseHeaderFrom fill entity from request header, returns ctx carrying caller identity.
+func parseHeaderFrom(ctx context.Context, en *apim.Base) context.Context {
 	if header := ctx.Value(struct{}{}); nil != header {
 		switch h := header.(type) {
 		case http.Header:
@@ -802,10 +939,142 @@ func parseHeaderFrom(ctx context.Context, en *apim.Base) {
 			if en.Source == "" {
 				en.Source = h.Get(HeaderSource)
 			}
+			if _, ok := types.IdentityFrom(ctx); !ok {
+				ctx = types.WithIdentity(ctx, parseIdentity(h, config.Get().Auth.Secret))
+			}
 		default:
 			panic("invalid HEADERS")
 		}
 	}
+	return ctx
+}
+
+// parseIdentity parse caller identity from the signed token of identity header,
+// callers without a token or with a token failing verification carry no identity.
+func parseIdentity(header http.Header, secret string) types.Identity {
+	token := header.Get(HeaderIdentity)
+	if token == "" {
+		return types.Identity{}
+	}
+
+	identity, err := verifyIdentity(token, []byte(secret))
+	if nil != err {
+		log.L().Warn("parse identity", logf.Error(err))
+		return types.Identity{}
+	}
+	return identity
+}
+
+func parsePropSourceFrom(ctx context.Context) string {
+	if header, ok := ctx.Value(struct{}{}).(http.Header); ok {
+		switch source := header.Get(HeaderPropSource); source {
+		case apim.ProvenanceRaw, apim.ProvenanceDerived:
+			return source
+		}
+	}
+	return ""
+}
+
+// parseNamespaceFrom returns namespace of properties written or viewed, see types.NamespaceSeparator.
+func parseNamespaceFrom(ctx context.Context) string {
+	if header, ok := ctx.Value(struct{}{}).(http.Header); ok {
+		return strings.TrimSpace(header.Get(HeaderPropNamespace))
+	}
+	return ""
+}
+
+func namespaceOptions(namespace string) []apim.Option {
+	if namespace == "" {
+		return nil
+	}
+	return []apim.Option{apim.NewNamespaceOption(namespace)}
+}
+
+// patchOptions options of property writes requested by headers.
+func patchOptions(ctx context.Context, namespace string) []apim.Option {
+	opts := namespaceOptions(namespace)
+	if parseSyncIndexFrom(ctx) {
+		opts = append(opts, apim.NewSyncIndexOption())
+	}
+	if seq, ok := parseSequenceFrom(ctx); ok {
+		opts = append(opts, apim.NewSequenceOption(seq))
+	}
+	return opts
+}
+
+func parseResolveBlobFrom(ctx context.Context) bool {
+	if header, ok := ctx.Value(struct{}{}).(http.Header); ok {
+		return strings.EqualFold(header.Get(HeaderResolveBlob), "true")
+	}
+	return false
+}
+
+func parseHighlightFrom(ctx context.Context) []string {
+	var fields []string
+	if header, ok := ctx.Value(struct{}{}).(http.Header); ok {
+		for _, field := range strings.Split(header.Get(HeaderHighlight), ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// parseSyncIndexFrom reports whether the write requested indexing before returning.
+func parseSyncIndexFrom(ctx context.Context) bool {
+	if header, ok := ctx.Value(struct{}{}).(http.Header); ok {
+		enabled, _ := strconv.ParseBool(header.Get(HeaderSyncIndex))
+		return enabled
+	}
+	return false
+}
+
+// parseSequenceFrom returns sequence number of the write among writes of the entity, if any.
+func parseSequenceFrom(ctx context.Context) (int64, bool) {
+	if header, ok := ctx.Value(struct{}{}).(http.Header); ok {
+		seq, err := strconv.ParseInt(header.Get(HeaderSequence), 10, 64)
+		return seq, err == nil
+	}
+	return 0, false
+}
+
+// parseReadPreferenceFrom returns where the entity read requested from, the primary by default.
+func parseReadPreferenceFrom(ctx context.Context) apim.ReadPreference {
+	if header, ok := ctx.Value(struct{}{}).(http.Header); ok {
+		if strings.EqualFold(header.Get(HeaderReadPreference), string(apim.ReadReplica)) {
+			return apim.ReadReplica
+		}
+	}
+	return apim.ReadPrimary
+}
+
+// parseReadRepairFrom reports whether listing requested repairing stale search documents.
+func parseReadRepairFrom(ctx context.Context) bool {
+	if header, ok := ctx.Value(struct{}{}).(http.Header); ok {
+		enabled, _ := strconv.ParseBool(header.Get(HeaderReadRepair))
+		return enabled
+	}
+	return false
+}
+
+// decodeSearchHit returns score and highlighted snippets carried by search result item.
+func decodeSearchHit(kv map[string]interface{}) (float64, map[string][]string) {
+	score, _ := kv[driver.FieldScore].(float64)
+	fields, _ := kv[driver.FieldHighlight].(map[string]interface{})
+	if len(fields) == 0 {
+		return score, nil
+	}
+
+	highlight := make(map[string][]string, len(fields))
+	for field, fragments := range fields {
+		if snippets, ok := fragments.([]interface{}); ok {
+			for _, snippet := range snippets {
+				highlight[field] = append(highlight[field], interface2string(snippet))
+			}
+		}
+	}
+	return score, highlight
 }
 
 func (s *EntityService) makeResponse(base *apim.BaseRet) (out *pb.EntityResponse, err error) {
@@ -841,6 +1110,23 @@ func (s *EntityService) makeResponse(base *apim.BaseRet) (out *pb.EntityResponse
 	out.LastTime = base.LastTime
 	out.TemplateId = base.TemplateID
 	out.Description = base.Description
+
+	// search relevance, of listed entities only.
+	out.Score = base.Score
+	if len(base.Highlight) > 0 {
+		highlight := make(map[string]interface{}, len(base.Highlight))
+		for field, snippets := range base.Highlight {
+			fragments := make([]interface{}, len(snippets))
+			for index := range snippets {
+				fragments[index] = snippets[index]
+			}
+			highlight[field] = fragments
+		}
+		if out.Highlight, err = structpb.NewValue(highlight); nil != err {
+			log.L().Error("convert entity highlight", logf.Error(err), logf.ID(base.ID))
+			return out, errors.Wrap(err, "convert entity highlight")
+		}
+	}
 	return out, nil
 }
 
@@ -2,14 +2,25 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
+	"github.com/emicklei/go-restful"
 	"github.com/stretchr/testify/assert"
 	pb "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
 	apim "github.com/tkeel-io/core/pkg/manager"
+	"github.com/tkeel-io/core/pkg/resource/blob/memory"
+	"github.com/tkeel-io/core/pkg/resource/search/driver"
 	"github.com/tkeel-io/core/pkg/service/mock"
+	"github.com/tkeel-io/core/pkg/types"
 	"github.com/tkeel-io/kit/log"
+	"go.uber.org/atomic"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -113,6 +124,28 @@ func Test_ListEntity(t *testing.T) {
 		Source: "dm",
 	})
 	assert.Nil(t, err)
+
+	_, err = entityService.ListEntity(context.Background(), &pb.ListEntityRequest{PageNum: -1})
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
+}
+
+func Test_pageOf(t *testing.T) {
+	pageNum, pageSize, err := pageOf(0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), pageNum)
+	assert.Equal(t, defaultPageSize, pageSize)
+
+	pageNum, pageSize, err = pageOf(3, 50)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), pageNum)
+	assert.Equal(t, int32(50), pageSize)
+
+	_, pageSize, err = pageOf(1, maxPageSize+1)
+	assert.Nil(t, err)
+	assert.Equal(t, maxPageSize, pageSize)
+
+	_, _, err = pageOf(-1, 10)
+	assert.ErrorIs(t, err, xerrors.ErrInvalidParam)
 }
 
 func Test_AppendMapper(t *testing.T) {
@@ -364,3 +397,209 @@ func TestCopyFrom(t *testing.T) {
 	assert.Nil(t, err)
 	t.Log("\nResult: ", result)
 }
+
+func Test_redactProperties(t *testing.T) {
+	configs := map[string]interface{}{
+		"temp": map[string]interface{}{"id": "temp", "type": "int"},
+		"firmware_key": map[string]interface{}{
+			"id": "firmware_key", "type": "string",
+			"acl": map[string]interface{}{"read": []interface{}{"admin"}},
+		},
+	}
+
+	props := map[string]interface{}{"temp": 20, "firmware_key": "secret"}
+	redactProperties(props, configs, []string{"user"})
+	assert.Equal(t, map[string]interface{}{"temp": 20}, props)
+
+	props = map[string]interface{}{"temp": 20, "firmware_key": "secret"}
+	redactProperties(props, configs, []string{"admin"})
+	assert.Len(t, props, 2)
+}
+
+func Test_checkPropertiesWritable(t *testing.T) {
+	configs := map[string]interface{}{
+		"firmware_key": map[string]interface{}{
+			"id": "firmware_key", "type": "string",
+			"acl": map[string]interface{}{"write": []interface{}{"admin"}},
+		},
+	}
+
+	assert.Nil(t, checkPropertiesWritable(configs, nil, "temp", "metrics.cpu"))
+	assert.ErrorIs(t, checkPropertiesWritable(configs, []string{"user"}, "temp", "firmware_key"), xerrors.ErrForbiddenProperty)
+	assert.ErrorIs(t, checkPropertiesWritable(configs, nil, "firmware_key.version"), xerrors.ErrForbiddenProperty)
+	assert.Nil(t, checkPropertiesWritable(configs, []string{"admin"}, "firmware_key"))
+}
+
+// aclManager serves an entity with an acl guarded property, recording patches.
+type aclManager struct {
+	apim.APIManager
+	patches [][]*pb.PatchData
+}
+
+func (m *aclManager) GetEntity(_ context.Context, in *apim.Base) (*apim.BaseRet, error) {
+	return &apim.BaseRet{ID: in.ID, Scheme: map[string]interface{}{
+		"firmware_key": map[string]interface{}{
+			"id": "firmware_key", "type": "string",
+			"acl": map[string]interface{}{"write": []interface{}{"admin"}},
+		},
+	}}, nil
+}
+
+func (m *aclManager) PatchEntity(ctx context.Context, in *apim.Base, pds []*pb.PatchData, opts ...apim.Option) (*apim.BaseRet, []byte, error) {
+	m.patches = append(m.patches, pds)
+	return m.APIManager.PatchEntity(ctx, in, pds, opts...)
+}
+
+func Test_UpdateEntityACL(t *testing.T) {
+	manager := &aclManager{APIManager: mock.NewAPIManagerMock()}
+	s := &EntityService{inited: atomic.NewBool(true), apiManager: manager}
+	update := func(roles []string, props map[string]interface{}) error {
+		properties, err := structpb.NewValue(props)
+		assert.Nil(t, err)
+		ctx := types.WithIdentity(context.Background(), types.Identity{User: "tom", Roles: roles})
+		_, err = s.UpdateEntity(ctx, &pb.UpdateEntityRequest{Id: "device123", Properties: properties})
+		return err
+	}
+
+	// properties merged by updates checked like patches.
+	assert.ErrorIs(t, update([]string{"user"}, map[string]interface{}{"temp": 20, "firmware_key": "x"}), xerrors.ErrForbiddenProperty)
+	assert.Empty(t, manager.patches)
+	assert.Nil(t, update([]string{"admin"}, map[string]interface{}{"firmware_key": "x"}))
+	assert.Len(t, manager.patches, 1)
+}
+
+func signIdentity(claims string, secret string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) +
+		"." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func Test_parseIdentity(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderIdentity, signIdentity(`{"user":"tom","tenant":"tenant01","roles":["admin","ops"]}`, "secret"))
+	assert.Equal(t, types.Identity{User: "tom", Tenant: "tenant01", Roles: []string{"admin", "ops"}}, parseIdentity(header, "secret"))
+
+	// signed with another secret, or expired.
+	assert.Equal(t, types.Identity{}, parseIdentity(header, "secret2"))
+	header.Set(HeaderIdentity, signIdentity(`{"user":"tom","roles":["admin"],"exp":1}`, "secret"))
+	assert.Equal(t, types.Identity{}, parseIdentity(header, "secret"))
+
+	// unsigned identity claims.
+	header.Set(HeaderIdentity, base64.StdEncoding.EncodeToString([]byte("user=tom&tenant=tenant01&role=admin")))
+	assert.Equal(t, types.Identity{}, parseIdentity(header, "secret"))
+	header.Set(HeaderIdentity, base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))+"."+
+		base64.RawURLEncoding.EncodeToString([]byte(`{"user":"tom","roles":["admin"]}`))+".")
+	assert.Equal(t, types.Identity{}, parseIdentity(header, "secret"))
+}
+
+func Test_parseHeaderFrom_identity(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Tkeel-Auth", base64.StdEncoding.EncodeToString([]byte("user=tom&tenant=tenant01&role=admin")))
+	header.Set("Roles", "admin")
+	ctx := context.WithValue(context.Background(), struct{}{}, header)
+
+	// client supplied headers grant no roles.
+	ctx = parseHeaderFrom(ctx, &Entity{})
+	identity, ok := types.IdentityFrom(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, types.Identity{}, identity)
+	assert.Empty(t, parseRolesFrom(ctx))
+}
+
+func Test_parseReadPreferenceFrom(t *testing.T) {
+	header := http.Header{}
+	ctx := context.WithValue(context.Background(), struct{}{}, header)
+	assert.Equal(t, apim.ReadPrimary, parseReadPreferenceFrom(ctx))
+
+	header.Set(HeaderReadPreference, "Replica")
+	assert.Equal(t, apim.ReadReplica, parseReadPreferenceFrom(ctx))
+	header.Set(HeaderReadPreference, "nearest")
+	assert.Equal(t, apim.ReadPrimary, parseReadPreferenceFrom(ctx))
+}
+
+func TestAuthFilter(t *testing.T) {
+	container := restful.NewContainer()
+	container.Filter(AuthFilter("secret"))
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/entities").To(func(req *restful.Request, resp *restful.Response) {
+		resp.WriteHeader(http.StatusOK)
+	}))
+	container.Add(ws)
+
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/entities", nil)
+		if token != "" {
+			req.Header.Set(HeaderIdentity, token)
+		}
+		recorder := httptest.NewRecorder()
+		container.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusOK, status(""))
+	assert.Equal(t, http.StatusOK, status(signIdentity(`{"user":"tom","roles":["admin"]}`, "secret")))
+	assert.Equal(t, http.StatusUnauthorized, status(signIdentity(`{"user":"tom","roles":["admin"]}`, "forged")))
+	assert.Equal(t, http.StatusUnauthorized, status(base64.StdEncoding.EncodeToString([]byte("user=tom&role=admin"))))
+}
+
+func Test_blobProperty(t *testing.T) {
+	s := &EntityService{blobStore: memory.New()}
+	configs := map[string]interface{}{
+		"image": map[string]interface{}{"id": "image", "type": "blob"},
+	}
+
+	ctx := context.Background()
+	ref, err := s.uploadBlob(ctx, configs, "device123", "image", base64.StdEncoding.EncodeToString([]byte("png")))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"blob_ref": "device123/image", "size": int64(3)}, ref)
+
+	val, err := s.uploadBlob(ctx, configs, "device123", "temp", 20)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, val)
+
+	_, err = s.uploadBlobPatch(ctx, configs, "device123", PatchData{Path: "image.size", Operator: "replace"})
+	assert.ErrorIs(t, err, xerrors.ErrPatchPathInvalid)
+
+	props := map[string]interface{}{"image": map[string]interface{}{"blob_ref": "device123/image", "size": float64(3)}}
+	assert.Nil(t, s.resolveBlobs(ctx, props, configs))
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("png")), props["image"])
+
+	s.deleteBlobs(ctx, &apim.BaseRet{
+		ID: "device123", Scheme: configs,
+		Properties: map[string]interface{}{"image": map[string]interface{}{"blob_ref": "device123/image", "size": float64(3)}},
+	})
+	_, err = s.blobStore.Get(ctx, "device123/image")
+	assert.ErrorIs(t, err, xerrors.ErrResourceNotFound)
+}
+
+func Test_decodeSearchHit(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderHighlight, "basicInfo.name, basicInfo.desc,")
+	ctx := context.WithValue(context.Background(), struct{}{}, header)
+	assert.Equal(t, []string{"basicInfo.name", "basicInfo.desc"}, parseHighlightFrom(ctx))
+	assert.Nil(t, parseHighlightFrom(context.Background()))
+
+	score, highlight := decodeSearchHit(map[string]interface{}{
+		"id":              "device123",
+		driver.FieldScore: 2.5,
+		driver.FieldHighlight: map[string]interface{}{
+			"basicInfo.name": []interface{}{"<em>light</em>", "<em>light</em> 2"},
+		},
+	})
+	assert.Equal(t, 2.5, score)
+	assert.Equal(t, map[string][]string{"basicInfo.name": {"<em>light</em>", "<em>light</em> 2"}}, highlight)
+
+	// relevance returned in dedicated fields, not within properties.
+	out, err := (&EntityService{}).makeResponse(&apim.BaseRet{
+		ID: "device123", Properties: map[string]interface{}{}, Score: score, Highlight: highlight})
+	assert.Nil(t, err)
+	assert.Equal(t, 2.5, out.Score)
+	assert.Equal(t, map[string]interface{}{"basicInfo.name": []interface{}{"<em>light</em>", "<em>light</em> 2"}}, out.Highlight.AsInterface())
+	assert.Empty(t, out.Properties.GetStructValue().Fields)
+
+	score, highlight = decodeSearchHit(map[string]interface{}{"id": "device123"})
+	assert.Equal(t, float64(0), score)
+	assert.Nil(t, highlight)
+}
@@ -23,7 +23,7 @@ func (s *EntityService) AppendExpression(ctx context.Context, req *pb.AppendExpr
 		ID:     req.EntityId,
 		Owner:  req.Owner,
 		Source: req.Source}
-	parseHeaderFrom(ctx, &en)
+	ctx = parseHeaderFrom(ctx, &en)
 
 	log.L().Debug("append expression", logf.Owner(req.Owner),
 		logf.Eid(req.EntityId), logf.Value(req.Expressions))
@@ -58,7 +58,7 @@ func (s *EntityService) RemoveExpression(ctx context.Context, req *pb.RemoveExpr
 		ID:     req.EntityId,
 		Owner:  req.Owner,
 		Source: req.Source}
-	parseHeaderFrom(ctx, &en)
+	ctx = parseHeaderFrom(ctx, &en)
 
 	log.L().Debug("remove expression", logf.Owner(en.Owner),
 		logf.Eid(en.ID), logf.Path(req.Paths))
@@ -101,7 +101,7 @@ func (s *EntityService) GetExpression(ctx context.Context, in *pb.GetExpressionR
 		ID:     in.EntityId,
 		Owner:  in.Owner,
 		Source: in.Source}
-	parseHeaderFrom(ctx, &en)
+	ctx = parseHeaderFrom(ctx, &en)
 
 	var expr *repository.Expression
 	if expr, err = s.apiManager.GetExpression(ctx,
@@ -132,10 +132,11 @@ func (s *EntityService) ListExpression(ctx context.Context, in *pb.ListExpressio
 		ID:     in.EntityId,
 		Owner:  in.Owner,
 		Source: in.Source}
-	parseHeaderFrom(ctx, &en)
+	ctx = parseHeaderFrom(ctx, &en)
 
+	var stale bool
 	var exprs []*repository.Expression
-	if exprs, err = s.apiManager.ListExpression(ctx,
+	if exprs, stale, err = s.apiManager.ListExpressionCached(ctx,
 		&apim.Base{
 			ID:    en.ID,
 			Owner: en.Owner,
@@ -143,12 +144,15 @@ func (s *EntityService) ListExpression(ctx context.Context, in *pb.ListExpressio
 		log.L().Error("list expressions", logf.Error(err),
 			logf.Eid(en.ID), logf.Owner(en.Owner))
 		return nil, errors.Wrap(err, "list expressions")
+	} else if stale {
+		log.L().Warn("list expressions, served stale", logf.Eid(en.ID), logf.Owner(en.Owner))
 	}
 
 	out = &pb.ListExpressionResp{
 		Owner:       en.Owner,
 		EntityId:    en.ID,
 		Expressions: []*pb.Expression{},
+		Stale:       stale,
 	}
 
 	for index := range exprs {
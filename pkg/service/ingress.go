@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+
+	"github.com/pkg/errors"
+	pb "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/core/pkg/metrics"
+	"github.com/tkeel-io/kit/log"
+	"go.uber.org/atomic"
+)
+
+const (
+	defaultIngressQueueDepth = 1000
+	// ingressDrainInterval interval polling pending messages while draining.
+	ingressDrainInterval = 10 * time.Millisecond
+)
+
+type ingressHandler func(context.Context, *pb.ProtoEvent) error
+
+// ingressItem message queued, done receives the result of handling it.
+type ingressItem struct {
+	ev   *pb.ProtoEvent
+	done chan error
+}
+
+// ingressPool handle ingress messages with bounded workers,
+// messages of an entity go to the same worker so that handled in order.
+type ingressPool struct {
+	ctx     context.Context
+	queues  []chan ingressItem
+	handler ingressHandler
+	// messages queued or being handled.
+	pending *atomic.Int64
+	// draining set once draining started, messages no longer submitted.
+	draining atomic.Bool
+}
+
+func newIngressPool(ctx context.Context, workers, depth int, handler ingressHandler) *ingressPool {
+	if depth <= 0 {
+		depth = defaultIngressQueueDepth
+	}
+
+	pool := &ingressPool{
+		ctx:     ctx,
+		queues:  make([]chan ingressItem, workers),
+		handler: handler,
+		pending: atomic.NewInt64(0),
+	}
+	for index := range pool.queues {
+		pool.queues[index] = make(chan ingressItem, depth)
+		go pool.work(pool.queues[index])
+	}
+	return pool
+}
+
+// Submit queue the message, the returned channel receives the result once the message handled,
+// returns ErrQueueFull if the worker of the entity saturated.
+func (p *ingressPool) Submit(ev *pb.ProtoEvent) (<-chan error, error) {
+	hash := fnv.New32a()
+	hash.Write([]byte(ev.Entity()))
+	queue := p.queues[hash.Sum32()%uint32(len(p.queues))]
+
+	// counted before checking draining, so that Drain never misses a message accepted.
+	if p.pending.Inc(); p.draining.Load() {
+		p.pending.Dec()
+		// senders retry, delivered to other instances or after restarted.
+		return nil, errors.Wrapf(xerrors.ErrQueueFull, "ingress draining, entity %s", ev.Entity())
+	}
+
+	item := ingressItem{ev: ev, done: make(chan error, 1)}
+	select {
+	case queue <- item:
+		metrics.CollectorIngressQueueDepth.Inc()
+		return item.done, nil
+	default:
+		p.pending.Dec()
+		return nil, errors.Wrapf(xerrors.ErrQueueFull, "ingress entity %s", ev.Entity())
+	}
+}
+
+func (p *ingressPool) work(queue chan ingressItem) {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case item := <-queue:
+			metrics.CollectorIngressQueueDepth.Dec()
+			err := p.handler(p.ctx, item.ev)
+			if nil != err {
+				log.L().Error("handle ingress event", logf.ID(item.ev.ID()),
+					logf.Eid(item.ev.Entity()), logf.Error(err))
+			}
+			item.done <- err
+			p.pending.Dec()
+		}
+	}
+}
+
+// waitHandled wait the result of the message submitted, ErrQueueFull wrapped if ctx done first,
+// so the sender retries the message.
+func waitHandled(ctx context.Context, done <-chan error) error {
+	select {
+	case err := <-done:
+		return errors.Wrap(err, "handle event")
+	case <-ctx.Done():
+		return errors.Wrapf(xerrors.ErrQueueFull, "wait ingress event handled, %s", ctx.Err())
+	}
+}
+
+// Drain stop accepting messages, then wait queued messages handled, returns error
+// if messages still pending when ctx done.
+func (p *ingressPool) Drain(ctx context.Context) error {
+	p.draining.Store(true)
+	ticker := time.NewTicker(ingressDrainInterval)
+	defer ticker.Stop()
+	for p.pending.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "drain ingress, %d messages pending", p.pending.Load())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"net/http"
+	"strings"
 
 	"github.com/pkg/errors"
 	pb "github.com/tkeel-io/core/api/core/v1"
@@ -22,7 +24,7 @@ func (s *EntityService) AppendMapper(ctx context.Context, req *pb.AppendMapperRe
 	entity.Type = req.Type
 	entity.Owner = req.Owner
 	entity.Source = req.Source
-	parseHeaderFrom(ctx, &entity)
+	ctx = parseHeaderFrom(ctx, &entity)
 
 	mp := mapper.Mapper{
 		ID:          req.Mapper.Id,
@@ -31,6 +33,8 @@ func (s *EntityService) AppendMapper(ctx context.Context, req *pb.AppendMapperRe
 		Owner:       entity.Owner,
 		EntityID:    req.EntityId,
 		Description: req.Mapper.Description,
+
+		AppliesToTypes: mapperTypes(ctx),
 	}
 
 	// append mapper.
@@ -52,3 +56,19 @@ func (s *EntityService) AppendMapper(ctx context.Context, req *pb.AppendMapperRe
 		},
 	}, nil
 }
+
+// mapperTypes returns entity types the mapper applies to from the comma separated header.
+func mapperTypes(ctx context.Context) []string {
+	header, ok := ctx.Value(struct{}{}).(http.Header)
+	if !ok || header.Get(HeaderMapperTypes) == "" {
+		return nil
+	}
+
+	var entityTypes []string
+	for _, typ := range strings.Split(header.Get(HeaderMapperTypes), ",") {
+		if typ = strings.TrimSpace(typ); typ != "" {
+			entityTypes = append(entityTypes, typ)
+		}
+	}
+	return entityTypes
+}
@@ -1,11 +1,23 @@
 package service
 
 import (
+	"context"
+	"net/http"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/tkeel-io/tdtl"
 )
 
 func TestRaw(t *testing.T) {
 	t.Log(string(tdtl.NewString("hahah").Raw()))
 }
+
+func Test_mapperTypes(t *testing.T) {
+	assert.Nil(t, mapperTypes(context.Background()))
+
+	header := http.Header{}
+	header.Set(HeaderMapperTypes, "sensor, thermometer,")
+	ctx := context.WithValue(context.Background(), struct{}{}, header)
+	assert.Equal(t, []string{"sensor", "thermometer"}, mapperTypes(ctx))
+}
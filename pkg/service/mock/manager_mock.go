@@ -2,12 +2,14 @@ package mock
 
 import (
 	"context"
+	"time"
 
 	v1 "github.com/tkeel-io/core/api/core/v1"
 	apim "github.com/tkeel-io/core/pkg/manager"
 	"github.com/tkeel-io/core/pkg/manager/holder"
 	"github.com/tkeel-io/core/pkg/mapper"
 	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/runtime"
 )
 
 type APIManagerMock struct {
@@ -24,6 +26,10 @@ func (m *APIManagerMock) Start() error { return nil }
 func (m *APIManagerMock) OnRespond(ctx context.Context, resp *holder.Response) {
 }
 
+func (m *APIManagerMock) SetMaintenanceMode(bool) {}
+
+func (m *APIManagerMock) MaintenanceMode() bool { return false }
+
 // CreateEntity create entity.
 func (m *APIManagerMock) CreateEntity(_ context.Context, in *apim.Base) (*apim.BaseRet, error) {
 	return &apim.BaseRet{
@@ -34,6 +40,21 @@ func (m *APIManagerMock) CreateEntity(_ context.Context, in *apim.Base) (*apim.B
 	}, nil
 }
 
+// GetOrCreateEntity returns entity, create it if absent.
+func (m *APIManagerMock) GetOrCreateEntity(ctx context.Context, in *apim.Base) (*apim.BaseRet, bool, error) {
+	ret, err := m.CreateEntity(ctx, in)
+	return ret, true, err
+}
+
+// EntitiesExist reports existence of entities keyed by entity id.
+func (m *APIManagerMock) EntitiesExist(ctx context.Context, ids []string) (map[string]bool, error) {
+	exists := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		exists[id] = false
+	}
+	return exists, nil
+}
+
 // UpdateEntity update entity.
 func (m *APIManagerMock) PatchEntity(_ context.Context, in *apim.Base, _ []*v1.PatchData, _ ...apim.Option) (*apim.BaseRet, []byte, error) {
 	return &apim.BaseRet{
@@ -59,6 +80,190 @@ func (m *APIManagerMock) GetEntity(_ context.Context, in *apim.Base) (*apim.Base
 	}, nil
 }
 
+// PatchEntities apply patches to entities in batch.
+func (m *APIManagerMock) PatchEntities(_ context.Context, ids []string, _ []*v1.PatchData) (map[string]*apim.BaseRet, map[string]error) {
+	rets := make(map[string]*apim.BaseRet, len(ids))
+	for _, id := range ids {
+		rets[id] = &apim.BaseRet{ID: id}
+	}
+	return rets, map[string]error{}
+}
+
+// DeleteEntities delete entities in batch.
+func (m *APIManagerMock) DeleteEntities(context.Context, []string, apim.DeleteOptions) map[string]error {
+	return map[string]error{}
+}
+
+// MoveEntities move entities to the target tenant.
+func (m *APIManagerMock) MoveEntities(context.Context, []string, string) map[string]error {
+	return map[string]error{}
+}
+
+// CloneEntity create the entity as clone of the source entity.
+func (m *APIManagerMock) CloneEntity(_ context.Context, _ string, clone *apim.Base, _ apim.CloneOptions) (*apim.BaseRet, error) {
+	return &apim.BaseRet{ID: clone.ID, Owner: clone.Owner, Source: clone.Source}, nil
+}
+
+// Reindex rebuild search documents of entities.
+func (m *APIManagerMock) Reindex(_ context.Context, ids []string, opts apim.ReindexOptions) (apim.ReindexStats, error) {
+	if opts.Progress != nil {
+		close(opts.Progress)
+	}
+	return apim.ReindexStats{Total: len(ids), Processed: len(ids)}, nil
+}
+
+// ChangeType change type of entity.
+func (m *APIManagerMock) ChangeType(_ context.Context, id, newType string) (*apim.BaseRet, error) {
+	return &apim.BaseRet{ID: id, Type: newType}, nil
+}
+
+// ListSnapshots returns prior states of entity.
+func (m *APIManagerMock) ListSnapshots(context.Context, string) ([]*apim.Snapshot, error) {
+	return nil, nil
+}
+
+// RestoreSnapshot restore entity to the snapshot.
+func (m *APIManagerMock) RestoreSnapshot(_ context.Context, id, _ string) (*apim.BaseRet, error) {
+	return &apim.BaseRet{ID: id}, nil
+}
+
+// FreezeEntity freeze or unfreeze entity.
+func (m *APIManagerMock) FreezeEntity(context.Context, *apim.Base, bool) error {
+	return nil
+}
+
+// SetAttributes set attributes of entity.
+func (m *APIManagerMock) SetAttributes(_ context.Context, en *apim.Base, attrs map[string]string) (*apim.BaseRet, error) {
+	return &apim.BaseRet{ID: en.ID, Attributes: attrs}, nil
+}
+
+// GetAttributes returns attributes of entity.
+func (m *APIManagerMock) GetAttributes(context.Context, *apim.Base) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+// DeleteProperty remove the property of the path.
+func (m *APIManagerMock) DeleteProperty(_ context.Context, en *apim.Base, _ string, _ bool) (*apim.BaseRet, error) {
+	return &apim.BaseRet{ID: en.ID}, nil
+}
+
+// PurgeTombstones hard delete tombstoned entities.
+func (m *APIManagerMock) PurgeTombstones(context.Context, time.Duration) (int, error) {
+	return 0, nil
+}
+
+// WatchEntity streams entity changes.
+func (m *APIManagerMock) WatchEntity(context.Context, string, apim.WatchOptions) (<-chan *apim.BaseRet, error) {
+	ch := make(chan *apim.BaseRet)
+	close(ch)
+	return ch, nil
+}
+
+// ListTypes returns distinct entity types of the owner with entity counts.
+func (m *APIManagerMock) ListTypes(context.Context, string) ([]apim.TypeInfo, error) {
+	return nil, nil
+}
+
+// ListAllMappers returns mappers of all entities page by page.
+func (m *APIManagerMock) ListAllMappers(context.Context, string, int) ([]apim.MapperRef, string, error) {
+	return nil, "", nil
+}
+
+// CompactMappers remove mapper expressions of entities absent in state.
+func (m *APIManagerMock) CompactMappers(context.Context, bool) (int, error) {
+	return 0, nil
+}
+
+// TagByQuery apply tags to entities matching the search request.
+func (m *APIManagerMock) TagByQuery(context.Context, *v1.SearchRequest, map[string]string) (int, error) {
+	return 0, nil
+}
+
+// QueryFields returns the fields of entities matching the search request.
+func (m *APIManagerMock) QueryFields(context.Context, *v1.SearchRequest, []string) ([]map[string]interface{}, error) {
+	return nil, nil
+}
+
+// SetSearchClient set search client used by maintenance tasks.
+func (m *APIManagerMock) SetSearchClient(v1.SearchHTTPServer) {}
+
+// SetLivenessSource set source of entity liveness.
+func (m *APIManagerMock) SetLivenessSource(apim.LivenessSource) {}
+
+// GetLiveness returns liveness of entity.
+func (m *APIManagerMock) GetLiveness(context.Context, string) (*runtime.Liveness, error) {
+	return nil, nil
+}
+
+// AddValidationHook append property validation hook.
+func (m *APIManagerMock) AddValidationHook(apim.ValidationHook, time.Duration) {}
+
+// AddDrainer append drainer of buffered data.
+func (m *APIManagerMock) AddDrainer(string, apim.Drainer) {}
+
+// Drain flush buffered data.
+func (m *APIManagerMock) Drain(context.Context) error {
+	return nil
+}
+
+// Stop drain buffered data and stop.
+func (m *APIManagerMock) Stop(context.Context) error {
+	return nil
+}
+
+// AddMigration register entity schema migration of type from version.
+func (m *APIManagerMock) AddMigration(string, int64, apim.MigrateFunc) {}
+
+// SetConfigsByType merge configs into all entities of the type.
+func (m *APIManagerMock) SetConfigsByType(context.Context, string, map[string]interface{}) (int, error) {
+	return 0, nil
+}
+
+// FinishDeletes complete entity deletes interrupted half way.
+func (m *APIManagerMock) FinishDeletes(context.Context) (int, error) {
+	return 0, nil
+}
+
+// RepairIndex re-index entity lagging the state.
+func (m *APIManagerMock) RepairIndex(string, map[string]interface{}) bool {
+	return true
+}
+
+// FinishIndexes index entities created while indexing failed.
+func (m *APIManagerMock) FinishIndexes(context.Context) (int, error) {
+	return 0, nil
+}
+
+// Materialize load entity into runtime.
+func (m *APIManagerMock) Materialize(context.Context, string) error {
+	return nil
+}
+
+// WaitForEntity wait until entity visible.
+func (m *APIManagerMock) WaitForEntity(context.Context, string, time.Duration) error {
+	return nil
+}
+
+// SetPropertiesWithRetry read-modify-write entity properties.
+func (m *APIManagerMock) SetPropertiesWithRetry(ctx context.Context, en *apim.Base, mutate apim.MutateFunc, maxRetries int) (*apim.BaseRet, error) {
+	return m.GetEntity(ctx, en)
+}
+
+// Transaction apply staged writes of several entities atomically.
+func (m *APIManagerMock) Transaction(ctx context.Context, fn func(*apim.Tx) error) error {
+	return fn(&apim.Tx{})
+}
+
+// DiffEntity compare entity with a snapshot.
+func (m *APIManagerMock) DiffEntity(ctx context.Context, id string, against *apim.BaseRet) (*apim.EntityDiff, error) {
+	return &apim.EntityDiff{EntityID: id}, nil
+}
+
+// DumpEntity returns raw state of entity.
+func (m *APIManagerMock) DumpEntity(ctx context.Context, id string) (*apim.EntityDump, error) {
+	return &apim.EntityDump{ID: id, Mappers: map[string]string{}}, nil
+}
+
 // AppendMapper append entity mapper.
 func (m *APIManagerMock) AppendMapper(ctx context.Context, mp *mapper.Mapper) error {
 	return nil
@@ -69,6 +274,25 @@ func (m *APIManagerMock) AppendMapperZ(ctx context.Context, mp *mapper.Mapper) e
 	return nil
 }
 
+// AppendMappers append mappers in batch.
+func (m *APIManagerMock) AppendMappers(ctx context.Context, mps []*mapper.Mapper, mode apim.AppendMode) ([]apim.MapperResult, error) {
+	results := make([]apim.MapperResult, len(mps))
+	for index, mp := range mps {
+		results[index] = apim.MapperResult{ID: mp.ID, EntityID: mp.EntityID, Name: mp.Name}
+	}
+	return results, nil
+}
+
+// SetMapperEnabled enable or disable entity mapper.
+func (m *APIManagerMock) SetMapperEnabled(context.Context, *apim.Base, string, bool) error {
+	return nil
+}
+
+// DeleteMapperByName remove entity mapper by name.
+func (m *APIManagerMock) DeleteMapperByName(context.Context, string, string) error {
+	return nil
+}
+
 // CheckSubscription check subscription.
 func (m *APIManagerMock) CheckSubscription(ctx context.Context, en *apim.Base) (err error) {
 	return nil
@@ -85,6 +309,54 @@ func (m *APIManagerMock) ListExpression(context.Context, *apim.Base) ([]*reposit
 	return nil, nil
 }
 
+func (m *APIManagerMock) ListExpressionCached(context.Context, *apim.Base) ([]*repository.Expression, bool, error) {
+	return nil, false, nil
+}
+
+func (m *APIManagerMock) GetProvenance(context.Context, *apim.Base) (map[string]apim.Provenance, error) {
+	return map[string]apim.Provenance{}, nil
+}
+
+func (m *APIManagerMock) GetDependencyGraph(_ context.Context, id string) (*apim.DepGraph, error) {
+	return &apim.DepGraph{EntityID: id, Derived: map[string]*apim.DepNode{}}, nil
+}
+
+func (m *APIManagerMock) GetQuotaUsage(_ context.Context, tenant string) (*repository.QuotaUsage, error) {
+	return &repository.QuotaUsage{Tenant: tenant}, nil
+}
+
+func (m *APIManagerMock) CreateGroup(context.Context, *repository.Group) error {
+	return nil
+}
+
+func (m *APIManagerMock) GetGroup(_ context.Context, gid string) (*repository.Group, error) {
+	return &repository.Group{ID: gid}, nil
+}
+
+func (m *APIManagerMock) ListGroups(context.Context, string) ([]*repository.Group, error) {
+	return nil, nil
+}
+
+func (m *APIManagerMock) DeleteGroup(context.Context, string) error {
+	return nil
+}
+
+func (m *APIManagerMock) AddToGroup(context.Context, string, *apim.Base) error {
+	return nil
+}
+
+func (m *APIManagerMock) RemoveFromGroup(context.Context, string, *apim.Base) error {
+	return nil
+}
+
+func (m *APIManagerMock) ListGroupMembers(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+func (m *APIManagerMock) ListEntityGroups(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
 func (m *APIManagerMock) CreateSubscription(context.Context, *repository.Subscription) error {
 	return nil
 }
@@ -96,3 +368,8 @@ func (m *APIManagerMock) DeleteSubscription(context.Context, *repository.Subscri
 func (m *APIManagerMock) GetSubscription(context.Context, *repository.Subscription) (*repository.Subscription, error) {
 	return nil, nil
 }
+
+// CreateMirror mirror source entity property to target entity property.
+func (m *APIManagerMock) CreateMirror(ctx context.Context, sourceID, sourceProp, targetID, targetProp string, mode apim.MirrorMode) (*apim.Mirror, error) {
+	return &apim.Mirror{SourceID: sourceID, SourceProp: sourceProp, TargetID: targetID, TargetProp: targetProp, Mode: mode}, nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+)
+
+const (
+	defaultPageSize int32 = 20
+	maxPageSize     int32 = 1000
+)
+
+// pageOf returns page of paginated requests, page number 0 taken as the first page,
+// page size 0 as the configured default, page sizes above the configured max clamped.
+// negative page numbers rejected.
+func pageOf(pageNum, pageSize int32) (int32, int32, error) {
+	if pageNum < 0 {
+		return 0, 0, errors.Wrapf(xerrors.ErrInvalidParam, "page number %d", pageNum)
+	} else if pageNum == 0 {
+		pageNum = 1
+	}
+
+	cfg := config.Get().Server
+	limit := maxPageSize
+	if cfg.MaxPageSize > 0 {
+		limit = cfg.MaxPageSize
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+		if cfg.DefaultPageSize > 0 {
+			pageSize = cfg.DefaultPageSize
+		}
+	}
+	if pageSize > limit {
+		pageSize = limit
+	}
+	return pageNum, pageSize, nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	go_restful "github.com/emicklei/go-restful"
+	"github.com/pkg/errors"
+	pb "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
+	"github.com/tkeel-io/kit/log"
+)
+
+// dependencies checked by readiness.
+const (
+	DependencyDapr    = "dapr"
+	DependencyEtcd    = "etcd"
+	DependencySearch  = "search"
+	DependencyRuntime = "runtime"
+
+	defaultReadinessTimeout = 3 * time.Second
+)
+
+// ReadinessCheck returns nil if the dependency ready.
+type ReadinessCheck func(ctx context.Context) error
+
+// DependencyStatus readiness of a dependency, LastError kept after the dependency recovered.
+type DependencyStatus struct {
+	Name      string `json:"name"`
+	Ready     bool   `json:"ready"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	// LastErrorAt timestamp(ms) of LastError.
+	LastErrorAt int64 `json:"last_error_at,omitempty"`
+}
+
+// ReadinessReport readiness of dependencies, ready if all dependencies ready.
+type ReadinessReport struct {
+	Ready        bool                `json:"ready"`
+	Timestamp    int64               `json:"timestamp"`
+	Dependencies []*DependencyStatus `json:"dependencies"`
+}
+
+type lastError struct {
+	message   string
+	timestamp int64
+}
+
+type ProbeService struct {
+	lock       sync.Mutex
+	names      []string
+	checks     map[string]ReadinessCheck
+	lastErrors map[string]lastError
+	timeout    time.Duration
+}
+
+func NewProbeService() (*ProbeService, error) {
+	return &ProbeService{
+		checks:     make(map[string]ReadinessCheck),
+		lastErrors: make(map[string]lastError),
+		timeout:    defaultReadinessTimeout,
+	}, nil
+}
+
+// AddCheck register readiness check of the dependency, replaces check registered with the name.
+func (s *ProbeService) AddCheck(name string, check ReadinessCheck) *ProbeService {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.checks[name]; !ok {
+		s.names = append(s.names, name)
+	}
+	s.checks[name] = check
+	return s
+}
+
+// Health returns error unless all dependencies ready.
+func (s *ProbeService) Health(ctx context.Context, req *pb.HealthRequest) (*pb.HealthResponse, error) {
+	if report := s.Readiness(ctx); !report.Ready {
+		return nil, errors.Wrap(xerrors.ErrServerNotReady, "health check")
+	}
+	return &pb.HealthResponse{}, nil
+}
+
+// Readiness checks dependencies concurrently, each within the readiness timeout.
+func (s *ProbeService) Readiness(ctx context.Context) *ReadinessReport {
+	s.lock.Lock()
+	names := append([]string{}, s.names...)
+	checks := make([]ReadinessCheck, len(names))
+	for index, name := range names {
+		checks[index] = s.checks[name]
+	}
+	s.lock.Unlock()
+
+	var wg sync.WaitGroup
+	statuses := make([]*DependencyStatus, len(names))
+	for index := range names {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			statuses[index] = s.check(ctx, names[index], checks[index])
+		}(index)
+	}
+	wg.Wait()
+
+	report := &ReadinessReport{
+		Ready:        true,
+		Timestamp:    time.Now().UnixNano() / 1e6,
+		Dependencies: statuses,
+	}
+	for _, status := range statuses {
+		report.Ready = report.Ready && status.Ready
+	}
+	return report
+}
+
+func (s *ProbeService) check(ctx context.Context, name string, check ReadinessCheck) *DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	status := &DependencyStatus{
+		Name:      name,
+		Ready:     err == nil,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if nil != err {
+		log.L().Warn("dependency not ready", logf.Name(name), logf.Error(err))
+		status.Error = err.Error()
+		s.lastErrors[name] = lastError{message: status.Error, timestamp: time.Now().UnixNano() / 1e6}
+	}
+
+	if last, ok := s.lastErrors[name]; ok {
+		status.LastError, status.LastErrorAt = last.message, last.timestamp
+	}
+	return status
+}
+
+// Readyz serialize readiness report, responds 503 unless all dependencies ready.
+func (s *ProbeService) Readyz(req *go_restful.Request, resp *go_restful.Response) {
+	report := s.Readiness(req.Request.Context())
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	if err := resp.WriteHeaderAndJson(status, report, go_restful.MIME_JSON); nil != err {
+		log.L().Error("write readiness report", logf.Error(err))
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+)
+
+func TestProbeService_Readiness(t *testing.T) {
+	srv, err := NewProbeService()
+	assert.Nil(t, err)
+
+	var searchErr error
+	srv.AddCheck(DependencyEtcd, func(ctx context.Context) error { return nil }).
+		AddCheck(DependencySearch, func(ctx context.Context) error { return searchErr }).
+		AddCheck(DependencyRuntime, func(ctx context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+
+	report := srv.Readiness(context.Background())
+	assert.True(t, report.Ready)
+	assert.Len(t, report.Dependencies, 3)
+	assert.Equal(t, DependencyRuntime, report.Dependencies[2].Name)
+	assert.GreaterOrEqual(t, report.Dependencies[2].LatencyMs, int64(20))
+	_, err = srv.Health(context.Background(), nil)
+	assert.Nil(t, err)
+
+	// degraded search reported, the last error kept after recovered.
+	searchErr = errors.New("connection refused")
+	report = srv.Readiness(context.Background())
+	assert.False(t, report.Ready)
+	assert.True(t, report.Dependencies[0].Ready)
+	assert.False(t, report.Dependencies[1].Ready)
+	assert.Equal(t, "connection refused", report.Dependencies[1].Error)
+	_, err = srv.Health(context.Background(), nil)
+	assert.ErrorIs(t, err, xerrors.ErrServerNotReady)
+
+	searchErr = nil
+	report = srv.Readiness(context.Background())
+	assert.True(t, report.Ready)
+	assert.Empty(t, report.Dependencies[1].Error)
+	assert.Equal(t, "connection refused", report.Dependencies[1].LastError)
+	assert.NotZero(t, report.Dependencies[1].LastErrorAt)
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/pkg/errors"
 	pb "github.com/tkeel-io/core/api/core/v1"
 	"github.com/tkeel-io/core/pkg/config"
 	logf "github.com/tkeel-io/core/pkg/logfield"
@@ -43,6 +44,11 @@ func (s *RawdataService) GetRawdata(ctx context.Context, req *pb.GetRawdataReque
 		return nil, err
 	}
 
+	var err error
+	if req.PageNum, req.PageSize, err = pageOf(req.PageNum, req.PageSize); nil != err {
+		return nil, errors.Wrap(err, "query raw data")
+	}
+
 	user := defalutUser
 	h := ctx.Value(contextHTTPHeaderKey)
 	header, ok := h.(http.Header)
@@ -54,6 +60,10 @@ func (s *RawdataService) GetRawdata(ctx context.Context, req *pb.GetRawdataReque
 
 	resp, err := s.rawdataClient.Query(ctx, req)
 	s.entityHistory.AddEnity(user, req.EntityId)
+	if nil != resp {
+		resp.PageNum = req.PageNum
+		resp.PageSize = req.PageSize
+	}
 
 	return resp, err
 }
@@ -18,12 +18,26 @@ package service
 
 import (
 	"context"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	pb "github.com/tkeel-io/core/api/core/v1"
 	xerrors "github.com/tkeel-io/core/pkg/errors"
+	logf "github.com/tkeel-io/core/pkg/logfield"
 	"github.com/tkeel-io/kit/log"
 	"go.uber.org/atomic"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	defaultFreshTimeout = 2 * time.Second
+	maxFreshTimeout     = 10 * time.Second
+
+	fieldID          = "id"
+	fieldOwner       = "owner"
+	fieldDisplayName = "properties.basicInfo.name"
 )
 
 type SearchService struct {
@@ -44,6 +58,25 @@ func (s *SearchService) Init(searchClient pb.SearchHTTPServer) {
 	s.inited.Store(true)
 }
 
+type searchFlusher interface {
+	Flush(ctx context.Context) error
+}
+
+// FlushSearch force the search index to catch up, so subsequent ListEntity see the latest writes.
+// It is expensive, meant for tests and critical read-after-write flows, not steady-state use.
+func (s *SearchService) FlushSearch(ctx context.Context) error {
+	if !s.inited.Load() {
+		log.L().Warn("service not ready")
+		return errors.Wrap(xerrors.ErrServerNotReady, "service not ready")
+	}
+
+	flusher, ok := s.searchClient.(searchFlusher)
+	if !ok {
+		return nil
+	}
+	return errors.Wrap(flusher.Flush(ctx), "flush search")
+}
+
 func (s *SearchService) Index(ctx context.Context, req *pb.IndexObject) (*pb.IndexResponse, error) {
 	out, err := s.searchClient.Index(ctx, req)
 	if err != nil {
@@ -57,9 +90,175 @@ func (s *SearchService) Search(ctx context.Context, req *pb.SearchRequest) (*pb.
 		return nil, errors.Wrap(xerrors.ErrServerNotReady, "service not ready")
 	}
 
-	out, err := s.searchClient.Search(ctx, req)
+	var err error
+	if req.PageNum, req.PageSize, err = pageOf(req.PageNum, req.PageSize); nil != err {
+		log.L().Warn("search", logf.Error(err))
+		return nil, errors.Wrap(err, "search failed")
+	}
+
+	owner := req.Owner
+	if header, ok := ctx.Value(struct{}{}).(http.Header); ok && owner == "" {
+		owner = header.Get(HeaderOwner)
+	}
+
+	out, err := searchWith(ctx, s.searchClient, req, owner)
 	if err != nil {
 		return out, errors.Wrap(err, "search failed")
 	}
 	return out, nil
 }
+
+type freshSearcher interface {
+	SearchFresh(ctx context.Context, req *pb.SearchRequest, token string, timeout time.Duration) (*pb.SearchResponse, bool, error)
+}
+
+// searchWith search through the client, options carried in request headers:
+//   - HeaderFreshToken, see searchFresh.
+//   - HeaderResolveRefs, fields of entity references to resolve, off by default, see resolveRefs.
+//
+// references resolve to entities of owner only.
+func searchWith(ctx context.Context, client pb.SearchHTTPServer, req *pb.SearchRequest, owner string) (*pb.SearchResponse, error) {
+	out, err := searchFresh(ctx, client, req)
+	if nil == err {
+		resolveRefs(ctx, client, out, owner, parseRefFieldsFrom(ctx))
+	}
+	return out, err
+}
+
+// searchFresh if the request carries a version token in HeaderFreshToken wait up to HeaderFreshTimeout
+// for the index to reflect it first, see search.FormatVersionToken.
+// results of a timed out wait are returned anyway, flagged stale.
+func searchFresh(ctx context.Context, client pb.SearchHTTPServer, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	token, timeout := parseFreshnessFrom(ctx)
+	searcher, ok := client.(freshSearcher)
+	if token == "" || !ok {
+		out, err := client.Search(ctx, req)
+		return out, errors.Wrap(err, "search")
+	}
+
+	out, stale, err := searcher.SearchFresh(ctx, req, token, timeout)
+	if nil != err {
+		return out, errors.Wrap(err, "search")
+	} else if stale {
+		log.L().Warn("search, index not reflecting version token",
+			logf.Value(token), logf.Elapsed(timeout))
+		out.Stale = true
+	}
+	return out, nil
+}
+
+// resolveRefs denormalize display names of entities of owner referenced by the fields of result items,
+// resolved in a single batched search, into out.Refs keyed by field, aligned with out.Items.
+// names are best-effort, read from the search index they may lag behind entity state,
+// and references failed to resolve are left out.
+func resolveRefs(ctx context.Context, client pb.SearchHTTPServer, out *pb.SearchResponse, owner string, fields []string) {
+	if nil == out || len(fields) == 0 {
+		return
+	} else if owner == "" {
+		log.L().Warn("search, resolve references without owner")
+		return
+	}
+
+	var ids []*structpb.Value
+	seen := make(map[string]struct{})
+	items := make([]map[string]interface{}, len(out.Items))
+	for index := range out.Items {
+		items[index], _ = out.Items[index].AsInterface().(map[string]interface{})
+		for _, field := range fields {
+			id, _ := fieldOf(items[index], field).(string)
+			if _, has := seen[id]; !has && id != "" {
+				seen[id] = struct{}{}
+				ids = append(ids, structpb.NewStringValue(id))
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	refs, err := client.Search(ctx, &pb.SearchRequest{
+		PageNum:  1,
+		PageSize: int32(len(ids)),
+		Condition: []*pb.SearchCondition{
+			{Field: fieldID, Operator: "$in", Value: structpb.NewListValue(&structpb.ListValue{Values: ids})},
+			{Field: fieldOwner, Operator: "$eq", Value: structpb.NewStringValue(owner)},
+		},
+	})
+	if nil != err {
+		log.L().Warn("search, resolve references", logf.Error(err))
+		return
+	}
+
+	names := make(map[string]interface{})
+	for _, ref := range refs.Items {
+		kv, _ := ref.AsInterface().(map[string]interface{})
+		if id, ok := kv[fieldID].(string); ok {
+			names[id] = fieldOf(kv, fieldDisplayName)
+		}
+	}
+
+	out.Refs = make([]*structpb.Value, len(items))
+	for index, item := range items {
+		resolved := make(map[string]interface{})
+		for _, field := range fields {
+			if id, ok := fieldOf(item, field).(string); ok && names[id] != nil {
+				resolved[field] = names[id]
+			}
+		}
+
+		out.Refs[index] = structpb.NewNullValue()
+		if len(resolved) > 0 {
+			if val, err := structpb.NewValue(resolved); nil == err {
+				out.Refs[index] = val
+			}
+		}
+	}
+}
+
+// fieldOf returns value of the dotted field of the document, nested or flattened.
+func fieldOf(doc map[string]interface{}, field string) interface{} {
+	if val, ok := doc[field]; ok {
+		return val
+	}
+
+	var cur interface{} = doc
+	for _, seg := range strings.Split(field, sep) {
+		kv, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = kv[seg]
+	}
+	return cur
+}
+
+func parseRefFieldsFrom(ctx context.Context) []string {
+	header, ok := ctx.Value(struct{}{}).(http.Header)
+	if !ok || header.Get(HeaderResolveRefs) == "" {
+		return nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(header.Get(HeaderResolveRefs), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func parseFreshnessFrom(ctx context.Context) (string, time.Duration) {
+	header, ok := ctx.Value(struct{}{}).(http.Header)
+	if !ok || header.Get(HeaderFreshToken) == "" {
+		return "", 0
+	}
+
+	timeout, err := time.ParseDuration(header.Get(HeaderFreshTimeout))
+	if nil != err || timeout <= 0 {
+		timeout = defaultFreshTimeout
+	} else if timeout > maxFreshTimeout {
+		timeout = maxFreshTimeout
+	}
+	return header.Get(HeaderFreshToken), timeout
+}
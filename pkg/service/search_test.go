@@ -1,12 +1,115 @@
 package service
 
 import (
+	"context"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	pb "github.com/tkeel-io/core/api/core/v1"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func Test_NewSearchService(t *testing.T) {
 	sv := NewSearchService()
 	assert.NotNil(t, sv)
 }
+
+func Test_parseFreshnessFrom(t *testing.T) {
+	token, _ := parseFreshnessFrom(context.Background())
+	assert.Equal(t, "", token)
+
+	header := http.Header{}
+	header.Set(HeaderFreshToken, "device123@5")
+	ctx := context.WithValue(context.Background(), struct{}{}, header)
+	token, timeout := parseFreshnessFrom(ctx)
+	assert.Equal(t, "device123@5", token)
+	assert.Equal(t, defaultFreshTimeout, timeout)
+
+	header.Set(HeaderFreshTimeout, "500ms")
+	_, timeout = parseFreshnessFrom(ctx)
+	assert.Equal(t, 500*time.Millisecond, timeout)
+
+	header.Set(HeaderFreshTimeout, "1h")
+	_, timeout = parseFreshnessFrom(ctx)
+	assert.Equal(t, maxFreshTimeout, timeout)
+}
+
+type refSearcher struct {
+	pb.UnimplementedSearchServer
+	requests []*pb.SearchRequest
+}
+
+func (s *refSearcher) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	s.requests = append(s.requests, req)
+	item := func(kv map[string]interface{}) *structpb.Value {
+		val, _ := structpb.NewValue(kv)
+		return val
+	}
+
+	if len(s.requests) == 1 {
+		return &pb.SearchResponse{Items: []*structpb.Value{
+			item(map[string]interface{}{"id": "sensor1", "parent": "gateway1"}),
+			item(map[string]interface{}{"id": "sensor2", "parent": "gateway1", "properties": map[string]interface{}{"site": "site404"}}),
+			item(map[string]interface{}{"id": "sensor3"}),
+		}}, nil
+	}
+	return &pb.SearchResponse{Items: []*structpb.Value{
+		item(map[string]interface{}{"id": "gateway1", "properties": map[string]interface{}{
+			"basicInfo": map[string]interface{}{"name": "Gateway-A"}}}),
+	}}, nil
+}
+
+func Test_searchWith_resolveRefs(t *testing.T) {
+	client := &refSearcher{}
+	out, err := searchWith(context.Background(), client, &pb.SearchRequest{}, "admin")
+	assert.Nil(t, err)
+	assert.Len(t, client.requests, 1)
+	assert.Nil(t, out.Refs)
+
+	header := http.Header{}
+	header.Set(HeaderResolveRefs, "parent, properties.site")
+	ctx := context.WithValue(context.Background(), struct{}{}, header)
+
+	// references of unknown owner left unresolved.
+	client = &refSearcher{}
+	out, err = searchWith(ctx, client, &pb.SearchRequest{}, "")
+	assert.Nil(t, err)
+	assert.Len(t, client.requests, 1)
+	assert.Nil(t, out.Refs)
+
+	client = &refSearcher{}
+	out, err = searchWith(ctx, client, &pb.SearchRequest{}, "admin")
+	assert.Nil(t, err)
+
+	// references resolved in a single batched search, within the owner.
+	assert.Len(t, client.requests, 2)
+	assert.Equal(t, "$in", client.requests[1].Condition[0].Operator)
+	assert.Len(t, client.requests[1].Condition[0].Value.GetListValue().Values, 2)
+	assert.Equal(t, "owner", client.requests[1].Condition[1].Field)
+	assert.Equal(t, "admin", client.requests[1].Condition[1].Value.GetStringValue())
+
+	// items untouched, references aligned with items.
+	assert.Nil(t, out.Items[0].GetStructValue().Fields["_refs"])
+	assert.Len(t, out.Refs, 3)
+	assert.Equal(t, map[string]interface{}{"parent": "Gateway-A"}, out.Refs[0].AsInterface())
+	assert.Equal(t, map[string]interface{}{"parent": "Gateway-A"}, out.Refs[1].AsInterface())
+	assert.Nil(t, out.Refs[2].AsInterface())
+}
+
+type staleSearcher struct {
+	pb.UnimplementedSearchServer
+}
+
+func (s *staleSearcher) SearchFresh(ctx context.Context, req *pb.SearchRequest, token string, timeout time.Duration) (*pb.SearchResponse, bool, error) {
+	return &pb.SearchResponse{}, true, nil
+}
+
+func Test_searchFresh_stale(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderFreshToken, "device123@5")
+	out, err := searchFresh(context.WithValue(context.Background(), struct{}{}, header), &staleSearcher{}, &pb.SearchRequest{})
+	assert.Nil(t, err)
+	assert.True(t, out.Stale)
+}
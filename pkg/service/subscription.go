@@ -19,6 +19,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -81,13 +82,17 @@ func (s *SubscriptionService) entity2SubscriptionResponse(base *apim.BaseRet) (o
 	out.Id = base.ID
 	out.Owner = base.Owner
 	out.Source = base.Source
+	prop := func(key string) string {
+		val, _ := base.GetProperty(key).AsString()
+		return val
+	}
 	out.Subscription = &pb.SubscriptionObject{
-		Mode:       interface2string(base.Properties["mode"]),
-		Source:     interface2string(base.Properties["source"]),
-		Filter:     interface2string(base.Properties["filter"]),
-		Target:     interface2string(base.Properties["target"]),
-		Topic:      interface2string(base.Properties["topic"]),
-		PubsubName: interface2string(base.Properties["pubsub_name"]),
+		Mode:       prop("mode"),
+		Source:     prop("source"),
+		Filter:     prop("filter"),
+		Target:     prop("target"),
+		Topic:      prop("topic"),
+		PubsubName: prop("pubsub_name"),
 	}
 	return out
 }
@@ -116,6 +121,10 @@ func (s *SubscriptionService) CreateSubscription(ctx context.Context, req *pb.Cr
 	if sub.Source == "" {
 		sub.Source = req.Source
 	}
+	typeLevel(ctx, sub)
+	if err = deliveryMode(ctx, sub); nil != err {
+		return out, errors.Wrap(err, "create subscription")
+	}
 
 	err = s.apiManager.CreateSubscription(ctx, sub)
 	out = &pb.SubscriptionResponse{
@@ -155,6 +164,12 @@ func (s *SubscriptionService) UpdateSubscription(ctx context.Context, req *pb.Up
 	if sub.Source == "" {
 		sub.Source = req.Source
 	}
+	typeLevel(ctx, sub)
+	if err = s.keepDelivery(ctx, sub); nil != err {
+		return out, errors.Wrap(err, "update subscription")
+	} else if err = deliveryMode(ctx, sub); nil != err {
+		return out, errors.Wrap(err, "update subscription")
+	}
 
 	err = s.apiManager.CreateSubscription(ctx, sub)
 
@@ -197,6 +212,55 @@ func makeSubscription(subObj *pb.SubscriptionObject) (*repository.Subscription,
 	return sub, nil
 }
 
+// typeLevel turn subscription into type level if Subscribe-Type specified,
+// the filter source stands for any entity of the type.
+func typeLevel(ctx context.Context, sub *repository.Subscription) {
+	if header, ok := ctx.Value(struct{}{}).(http.Header); ok {
+		if entityType := header.Get(HeaderSubscribeType); entityType != "" {
+			sub.SourceEntityType = entityType
+			sub.SourceEntityID = ""
+		}
+	}
+}
+
+// keepDelivery keep delivery guarantee of the stored subscription, changed by Subscribe-Delivery only.
+func (s *SubscriptionService) keepDelivery(ctx context.Context, sub *repository.Subscription) error {
+	stored, err := s.apiManager.GetSubscription(ctx, &repository.Subscription{
+		ID:               sub.ID,
+		Owner:            sub.Owner,
+		SourceEntityID:   sub.SourceEntityID,
+		SourceEntityType: sub.SourceEntityType,
+	})
+	if nil != err {
+		if xerrors.IsEntityNotFound(err) {
+			return nil
+		}
+		log.L().Error("update subscription, get subscription", logf.ID(sub.ID), logf.Error(err))
+		return errors.Wrap(err, "get subscription")
+	} else if stored != nil {
+		sub.Delivery = stored.Delivery
+	}
+	return nil
+}
+
+// deliveryMode set delivery guarantee of subscription from Subscribe-Delivery, at-most-once by default.
+func deliveryMode(ctx context.Context, sub *repository.Subscription) error {
+	header, ok := ctx.Value(struct{}{}).(http.Header)
+	if !ok {
+		return nil
+	}
+
+	switch delivery := header.Get(HeaderDelivery); delivery {
+	case "":
+	case repository.DeliveryAtMostOnce, repository.DeliveryAtLeastOnce:
+		sub.Delivery = delivery
+	default:
+		log.L().Warn("invalid subscription delivery", logf.ID(sub.ID), logf.Value(delivery))
+		return errors.Wrapf(xerrors.ErrInvalidParam, "subscription delivery %s", delivery)
+	}
+	return nil
+}
+
 func (s *SubscriptionService) DeleteSubscription(ctx context.Context, req *pb.DeleteSubscriptionRequest) (out *pb.DeleteSubscriptionResponse, err error) {
 	if !s.inited.Load() {
 		log.L().Warn("service not ready", logf.Eid(req.Id))
@@ -212,6 +276,7 @@ func (s *SubscriptionService) DeleteSubscription(ctx context.Context, req *pb.De
 	sub.ID = req.Id
 	sub.Owner = req.Owner
 	sub.Source2 = req.Source
+	typeLevel(ctx, sub)
 	sub, err = s.apiManager.GetSubscription(ctx, sub)
 	if err != nil {
 		return nil, errors.Wrap(err, "delete subscription")
@@ -2,10 +2,14 @@ package service
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	pb "github.com/tkeel-io/core/api/core/v1"
+	apim "github.com/tkeel-io/core/pkg/manager"
+	"github.com/tkeel-io/core/pkg/repository"
+	"github.com/tkeel-io/core/pkg/service/mock"
 )
 
 func Test_NewSubscriptionService(t *testing.T) {
@@ -61,6 +65,57 @@ func Test_UpdateSubscription(t *testing.T) {
 	assert.Equal(t, "dm", res.Source)
 }
 
+// storedSubscriptions serves subscriptions stored, records subscriptions written.
+type storedSubscriptions struct {
+	apim.APIManager
+	stored  *repository.Subscription
+	written *repository.Subscription
+}
+
+func (m *storedSubscriptions) GetSubscription(ctx context.Context, sub *repository.Subscription) (*repository.Subscription, error) {
+	return m.stored, nil
+}
+
+func (m *storedSubscriptions) CreateSubscription(ctx context.Context, sub *repository.Subscription) error {
+	m.written = sub
+	return nil
+}
+
+func Test_UpdateSubscriptionDelivery(t *testing.T) {
+	ss, err := NewSubscriptionService(context.Background())
+	assert.Nil(t, err)
+
+	manager := &storedSubscriptions{
+		APIManager: mock.NewAPIManagerMock(),
+		stored:     &repository.Subscription{ID: "sub123", Delivery: repository.DeliveryAtLeastOnce},
+	}
+	ss.Init(manager)
+	req := &pb.UpdateSubscriptionRequest{
+		Id:     "sub123",
+		Source: "dm",
+		Owner:  "admin",
+		Subscription: &pb.SubscriptionObject{
+			Mode:       "realtime",
+			Filter:     "insert into sub123 select device123.*",
+			Target:     "subscription.service",
+			Topic:      "sub123-device123",
+			PubsubName: "sub123",
+		},
+	}
+
+	// stored delivery kept without the header.
+	_, err = ss.UpdateSubscription(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, repository.DeliveryAtLeastOnce, manager.written.Delivery)
+
+	// changed by the header.
+	header := http.Header{}
+	header.Set(HeaderDelivery, repository.DeliveryAtMostOnce)
+	_, err = ss.UpdateSubscription(context.WithValue(context.Background(), struct{}{}, header), req)
+	assert.Nil(t, err)
+	assert.Equal(t, repository.DeliveryAtMostOnce, manager.written.Delivery)
+}
+
 func Test_DeleteSubscription(t *testing.T) {
 	ss, err := NewSubscriptionService(context.Background())
 	assert.Nil(t, err)
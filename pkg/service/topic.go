@@ -18,17 +18,24 @@ package service
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tkeel-io/collectjs"
 	pb "github.com/tkeel-io/core/api/core/v1"
+	"github.com/tkeel-io/core/pkg/config"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
 	logf "github.com/tkeel-io/core/pkg/logfield"
 	apim "github.com/tkeel-io/core/pkg/manager"
+	"github.com/tkeel-io/core/pkg/metrics"
 	"github.com/tkeel-io/core/pkg/resource/pubsub/dapr"
+	"github.com/tkeel-io/core/pkg/runtime"
+	"github.com/tkeel-io/core/pkg/util"
 	xjson "github.com/tkeel-io/core/pkg/util/json"
 	"github.com/tkeel-io/kit/log"
 	"github.com/tkeel-io/tdtl"
+	"go.uber.org/atomic"
 )
 
 type TopicService struct {
@@ -36,6 +43,14 @@ type TopicService struct {
 	ctx        context.Context
 	cancel     context.CancelFunc
 	apiManager apim.APIManager
+	// decouple ingress from runtime processing, nil handles messages synchronously.
+	ingress *ingressPool
+	// maxPayloadSize max size of messages, larger messages rejected.
+	maxPayloadSize int
+	// deadLetterSink receives rejected messages, nil drops them.
+	deadLetterSink runtime.DeadLetterSink
+	// draining set once draining started, messages retried by senders.
+	draining atomic.Bool
 }
 
 const (
@@ -50,26 +65,190 @@ const (
 func NewTopicService(ctx context.Context) (*TopicService, error) {
 	ctx, cancel := context.WithCancel(ctx)
 
-	return &TopicService{
-		ctx:    ctx,
-		cancel: cancel,
-	}, nil
+	cfg := config.Get().Ingress
+	srv := &TopicService{
+		ctx:            ctx,
+		cancel:         cancel,
+		maxPayloadSize: cfg.PayloadLimit(),
+	}
+
+	if cfg.DeadLetterOversized {
+		srv.deadLetterSink = runtime.NewDeadLetterSink(config.Get().DeadLetter)
+	}
+
+	if cfg.Workers > 0 {
+		srv.ingress = newIngressPool(ctx, cfg.Workers, cfg.QueueDepth,
+			func(ctx context.Context, ev *pb.ProtoEvent) error {
+				_, err := dapr.HandleEvent(ctx, ev)
+				return errors.Wrap(err, "handle event")
+			})
+	}
+	return srv, nil
 }
 
 func (s *TopicService) Init(apiManager apim.APIManager) {
 	s.apiManager = apiManager
 }
 
+// Drain stop accepting messages, then wait messages queued by ingress workers handled.
+func (s *TopicService) Drain(ctx context.Context) error {
+	s.draining.Store(true)
+	if s.ingress == nil {
+		return nil
+	}
+	return s.ingress.Drain(ctx)
+}
+
 func (s *TopicService) TopicEventHandler(ctx context.Context, req *pb.TopicEventRequest) (out *pb.TopicEventResponse, err error) {
-	log.L().Debug("received event", logf.ReqID(req.Meta.Id),
+	traceID := traceIDOf(req)
+	log.L().Debug("received event", logf.ReqID(req.Meta.Id), logf.TraceID(traceID),
 		logf.Type(req.Meta.Type), logf.Source(req.Meta.Source),
 		logf.Topic(req.Meta.Topic), logf.Pubsub(req.Meta.Pubsubname))
 
-	var payload []byte
+	if s.draining.Load() {
+		return &pb.TopicEventResponse{Status: SubscriptionResponseStatusRetry},
+			errors.Wrap(xerrors.ErrServerNotReady, "topic service draining")
+	}
+
+	// reject oversized message before decoding it.
+	if err = s.checkPayloadSize(ctx, req); nil != err {
+		return &pb.TopicEventResponse{Status: SubscriptionResponseStatusDrop}, err
+	}
+
+	ev, err := eventOf(req, traceID)
+	if nil != err {
+		return &pb.TopicEventResponse{Status: SubscriptionResponseStatusDrop}, err
+	}
+
+	if s.ingress != nil {
+		// saturated, let the sender retry.
+		done, err := s.ingress.Submit(ev)
+		if nil != err {
+			log.L().Warn("submit event", logf.ReqID(req.Meta.Id), logf.Eid(ev.Entity()), logf.Error(err))
+			return &pb.TopicEventResponse{Status: SubscriptionResponseStatusRetry}, err
+		}
+		// acked once the runtime accepted the event, so messages queued are redelivered after a crash.
+		status, err := ingressStatus(waitHandled(ctx, done))
+		return &pb.TopicEventResponse{Status: status}, err
+	}
+
+	res, err := dapr.HandleEvent(ctx, ev)
+	if nil != err {
+		return &pb.TopicEventResponse{Status: SubscriptionResponseStatusDrop}, errors.Wrap(err, "handle event")
+	}
+
+	return res, nil
+}
+
+// ingressStatus status acking the message handled by ingress workers with err.
+func ingressStatus(err error) (string, error) {
+	switch {
+	case nil == err:
+		return SubscriptionResponseStatusSuccess, nil
+	case errors.Is(err, xerrors.ErrQueueFull):
+		return SubscriptionResponseStatusRetry, err
+	default:
+		return SubscriptionResponseStatusDrop, err
+	}
+}
+
+// MessageStatus acceptance status of a message in a batch.
+type MessageStatus struct {
+	ID     string
+	Status string
+	Error  error
+}
+
+// OnMessages ingest a batch of messages, each message submitted as an event of its own
+// in batch order, so that every message keeps its own rawData record and timestamp.
+// once a message of an entity not accepted, later messages of the entity are retried
+// instead of submitted out of order, returns status of each message in order once
+// the messages submitted handled.
+func (s *TopicService) OnMessages(ctx context.Context, reqs []*pb.TopicEventRequest) []MessageStatus {
+	statuses := make([]MessageStatus, len(reqs))
+	log.L().Debug("received event batch", logf.Count(int64(len(reqs))))
+
+	dones := make([]<-chan error, len(reqs))
+	retries := make(map[string]error)
+	for index, req := range reqs {
+		statuses[index].ID = req.Meta.Id
+		if s.draining.Load() {
+			statuses[index].Status = SubscriptionResponseStatusRetry
+			statuses[index].Error = errors.Wrap(xerrors.ErrServerNotReady, "topic service draining")
+			continue
+		}
+
+		if err := s.checkPayloadSize(ctx, req); nil != err {
+			statuses[index].Status, statuses[index].Error = SubscriptionResponseStatusDrop, err
+			continue
+		}
+
+		ev, err := eventOf(req, traceIDOf(req))
+		if nil != err {
+			statuses[index].Status, statuses[index].Error = SubscriptionResponseStatusDrop, err
+			continue
+		}
+
+		entityID := ev.Entity()
+		if err = retries[entityID]; nil != err {
+			statuses[index].Status, statuses[index].Error = SubscriptionResponseStatusRetry, err
+			continue
+		}
+
+		status := SubscriptionResponseStatusSuccess
+		if s.ingress != nil {
+			if dones[index], err = s.ingress.Submit(ev); nil != err {
+				log.L().Warn("submit event", logf.ID(ev.Id), logf.Eid(entityID), logf.Error(err))
+				status, retries[entityID] = SubscriptionResponseStatusRetry, err
+			}
+		} else if _, err = dapr.HandleEvent(ctx, ev); nil != err {
+			status, err = SubscriptionResponseStatusDrop, errors.Wrap(err, "handle event")
+		}
+
+		statuses[index].Status, statuses[index].Error = status, err
+	}
+
+	// acked once the runtime accepted the events.
+	for index, done := range dones {
+		if done != nil {
+			statuses[index].Status, statuses[index].Error = ingressStatus(waitHandled(ctx, done))
+		}
+	}
+	return statuses
+}
+
+// TopicEventsHandler handle a batch of messages, see OnMessages.
+func (s *TopicService) TopicEventsHandler(ctx context.Context, req *pb.TopicEventsRequest) (*pb.TopicEventsResponse, error) {
+	statuses := s.OnMessages(ctx, req.Events)
+	out := &pb.TopicEventsResponse{Statuses: make([]*pb.TopicEventStatus, len(statuses))}
+	for index, status := range statuses {
+		if nil != status.Error {
+			log.L().Warn("handle event", logf.ReqID(status.ID),
+				logf.Status(status.Status), logf.Error(status.Error))
+		}
+		out.Statuses[index] = &pb.TopicEventStatus{EntryId: status.ID, Status: status.Status}
+	}
+	return out, nil
+}
+
+// traceIDOf returns trace id of the message, carried by the cloud event traceid or
+// traceparent extension, generated only if the message carries none.
+func traceIDOf(req *pb.TopicEventRequest) string {
+	if traceID := req.Meta.GetTraceid(); traceID != "" {
+		return traceID
+	} else if traceID = req.Meta.GetTraceparent(); traceID != "" {
+		return traceID
+	}
+	return util.IG().TraceID()
+}
+
+// eventOf decode message into entity event patching properties.rawData.
+func eventOf(req *pb.TopicEventRequest, traceID string) (*pb.ProtoEvent, error) {
 	// set event payload.
-	if payload, _, err = collectjs.Get(req.RawData, "data.rawData"); nil != err {
+	payload, _, err := collectjs.Get(req.RawData, "data.rawData")
+	if nil != err {
 		log.L().Warn("get event payload", logf.String("id", req.Meta.Id), logf.Any("event", req), logf.Reason(err.Error()))
-		return &pb.TopicEventResponse{Status: SubscriptionResponseStatusDrop}, errors.Wrap(err, "get event payload")
+		return nil, errors.Wrap(err, "get event payload")
 	}
 
 	cc := tdtl.New(req.RawData)
@@ -80,10 +259,19 @@ func (s *TopicService) TopicEventHandler(ctx context.Context, req *pb.TopicEvent
 
 	ev.SetType(pb.ETEntity)
 	ev.SetAttr(pb.MetaTopic, req.Meta.Topic)
+	ev.SetAttr(pb.MetaTraceID, traceID)
 	ev.SetAttr(pb.MetaEntityID, cc.Get("id").String())
 	ev.SetAttr(pb.MetaOwner, cc.Get("type").String())
 	ev.SetAttr(pb.MetaSource, cc.Get("owner").String())
 	ev.SetAttr(pb.MetaEntityType, cc.Get("source").String())
+	// properties namespaced by the runtime if the message carries a namespace, see HeaderPropNamespace.
+	if namespace := cc.Get("namespace"); namespace.Type() == tdtl.String && namespace.String() != "" {
+		ev.SetAttr(pb.MetaNamespace, namespace.String())
+	}
+	// sequence number of the message among messages of the entity, ordered if ordering enabled.
+	if seq, err := strconv.ParseInt(cc.Get("seq").String(), 10, 64); err == nil {
+		ev.SetAttr(pb.MetaSequence, strconv.FormatInt(seq, 10))
+	}
 	ev.SetPayload(&pb.ProtoEvent_Patches{
 		Patches: &pb.PatchDatas{
 			Patches: []*pb.PatchData{{
@@ -94,12 +282,31 @@ func (s *TopicService) TopicEventHandler(ctx context.Context, req *pb.TopicEvent
 		},
 	})
 
-	res, err := dapr.HandleEvent(ctx, &ev)
-	if nil != err {
-		return &pb.TopicEventResponse{Status: SubscriptionResponseStatusDrop}, errors.Wrap(err, "handle event")
+	return &ev, nil
+}
+
+// checkPayloadSize reject message larger than max payload size, forwarded to dead letter sink if any.
+func (s *TopicService) checkPayloadSize(ctx context.Context, req *pb.TopicEventRequest) error {
+	if s.maxPayloadSize <= 0 || len(req.RawData) <= s.maxPayloadSize {
+		return nil
 	}
 
-	return res, nil
+	metrics.CollectorIngressRejectedCount.WithLabelValues(metrics.RejectOversized).Inc()
+	err := errors.Wrapf(xerrors.ErrMessageTooLarge, "message size %d, limit %d", len(req.RawData), s.maxPayloadSize)
+	log.L().Error("reject message", logf.ReqID(req.Meta.Id), logf.Topic(req.Meta.Topic),
+		logf.Pubsub(req.Meta.Pubsubname), logf.Error(err))
+
+	if nil != s.deadLetterSink {
+		if sendErr := s.deadLetterSink.Send(ctx, &runtime.DeadLetter{
+			EventID:   req.Meta.Id,
+			Stage:     runtime.DeadLetterStageIngress,
+			Reason:    err.Error(),
+			Timestamp: time.Now().UnixNano() / 1e6,
+		}); nil != sendErr {
+			log.L().Error("send dead letter", logf.ReqID(req.Meta.Id), logf.Error(sendErr))
+		}
+	}
+	return err
 }
 
 type RawData struct {
@@ -1,7 +1,14 @@
 package service
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	pb "github.com/tkeel-io/core/api/core/v1"
+	xerrors "github.com/tkeel-io/core/pkg/errors"
+	"github.com/tkeel-io/core/pkg/runtime"
 )
 
 func TestPublish(t *testing.T) {
@@ -19,3 +26,243 @@ func TestPublish(t *testing.T) {
 	// err := client.PublishEvent(context.Background(), "core-pubsub", "core-pub", data)
 	// t.Log(err)
 }
+
+func TestIngressPool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	handled := make(chan string, 10)
+	pool := newIngressPool(ctx, 1, 1, func(ctx context.Context, ev *pb.ProtoEvent) error {
+		started <- struct{}{}
+		<-release
+		handled <- ev.ID()
+		return nil
+	})
+
+	event := func(id string) *pb.ProtoEvent {
+		return &pb.ProtoEvent{Id: id, Metadata: map[string]string{pb.MetaEntityID: "device123"}}
+	}
+
+	// the worker busy with ev1, ev2 queued, ev3 rejected.
+	done1, err := pool.Submit(event("ev1"))
+	assert.Nil(t, err)
+	<-started
+	done2, err := pool.Submit(event("ev2"))
+	assert.Nil(t, err)
+	_, err = pool.Submit(event("ev3"))
+	assert.ErrorIs(t, err, xerrors.ErrQueueFull)
+
+	// messages of an entity handled in order.
+	close(release)
+	assert.Equal(t, "ev1", <-handled)
+	assert.Equal(t, "ev2", <-handled)
+	assert.Nil(t, <-done1)
+	assert.Nil(t, <-done2)
+}
+
+func TestIngressPool_Drain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	var handled []string
+	pool := newIngressPool(ctx, 1, 10, func(ctx context.Context, ev *pb.ProtoEvent) error {
+		<-release
+		handled = append(handled, ev.ID())
+		return nil
+	})
+
+	for _, id := range []string{"ev1", "ev2", "ev3"} {
+		_, err := pool.Submit(&pb.ProtoEvent{Id: id, Metadata: map[string]string{pb.MetaEntityID: "device123"}})
+		assert.Nil(t, err)
+	}
+
+	// messages still pending when timeout hit.
+	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer timeoutCancel()
+	assert.ErrorIs(t, pool.Drain(timeoutCtx), context.DeadlineExceeded)
+
+	// messages rejected once draining, senders retry.
+	_, err := pool.Submit(&pb.ProtoEvent{Id: "ev4", Metadata: map[string]string{pb.MetaEntityID: "device234"}})
+	assert.ErrorIs(t, err, xerrors.ErrQueueFull)
+
+	close(release)
+	assert.Nil(t, pool.Drain(context.Background()))
+	assert.Equal(t, []string{"ev1", "ev2", "ev3"}, handled)
+}
+
+type letterRecorder struct {
+	letters []*runtime.DeadLetter
+}
+
+func (r *letterRecorder) Send(ctx context.Context, letter *runtime.DeadLetter) error {
+	r.letters = append(r.letters, letter)
+	return nil
+}
+
+func TestTopicService_checkPayloadSize(t *testing.T) {
+	sink := &letterRecorder{}
+	srv := &TopicService{maxPayloadSize: 8, deadLetterSink: sink}
+
+	req := &pb.TopicEventRequest{Meta: &pb.Metadata{Id: "ev1"}, RawData: []byte("12345678")}
+	assert.Nil(t, srv.checkPayloadSize(context.Background(), req))
+
+	req.RawData = []byte("123456789")
+	assert.ErrorIs(t, srv.checkPayloadSize(context.Background(), req), xerrors.ErrMessageTooLarge)
+	assert.Len(t, sink.letters, 1)
+	assert.Equal(t, "ev1", sink.letters[0].EventID)
+	assert.Equal(t, runtime.DeadLetterStageIngress, sink.letters[0].Stage)
+}
+
+func Test_traceIDOf(t *testing.T) {
+	req := &pb.TopicEventRequest{Meta: &pb.Metadata{Id: "ev1", Traceid: "trace-123"}}
+	assert.Equal(t, "trace-123", traceIDOf(req))
+
+	req.Meta.Traceid = ""
+	req.Meta.Traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	assert.Equal(t, req.Meta.Traceparent, traceIDOf(req))
+
+	// generated only if absent.
+	req.Meta.Traceparent = ""
+	assert.NotEqual(t, "", traceIDOf(req))
+}
+
+func Test_eventOf_sequence(t *testing.T) {
+	req := &pb.TopicEventRequest{Meta: &pb.Metadata{Id: "ev1", Topic: "core-pubsub"},
+		RawData: []byte(`{"id":"device123","seq":12,"data":{"rawData":{"temp":20}}}`)}
+	ev, err := eventOf(req, "trace-123")
+	assert.Nil(t, err)
+	assert.Equal(t, "12", ev.Attr(pb.MetaSequence))
+
+	// unordered without sequence.
+	req.RawData = []byte(`{"id":"device123","data":{"rawData":{"temp":20}}}`)
+	ev, err = eventOf(req, "trace-123")
+	assert.Nil(t, err)
+	assert.Equal(t, "", ev.Attr(pb.MetaSequence))
+	assert.Equal(t, "", ev.Attr(pb.MetaNamespace))
+}
+
+func Test_eventOf_namespace(t *testing.T) {
+	req := &pb.TopicEventRequest{Meta: &pb.Metadata{Id: "ev1", Topic: "core-pubsub"},
+		RawData: []byte(`{"id":"device123","namespace":"gateway1","data":{"rawData":{"temp":20}}}`)}
+	ev, err := eventOf(req, "trace-123")
+	assert.Nil(t, err)
+	assert.Equal(t, "gateway1", ev.Attr(pb.MetaNamespace))
+}
+
+func TestTopicService_OnMessages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handled := make(chan *pb.ProtoEvent, 10)
+	srv := &TopicService{maxPayloadSize: 128}
+	srv.ingress = newIngressPool(ctx, 2, 4, func(ctx context.Context, ev *pb.ProtoEvent) error {
+		handled <- ev
+		return nil
+	})
+
+	message := func(id, entityID, value string) *pb.TopicEventRequest {
+		return &pb.TopicEventRequest{Meta: &pb.Metadata{Id: id},
+			RawData: []byte(`{"id":"` + entityID + `","data":{"rawData":"` + value + `"}}`)}
+	}
+
+	oversized := message("ev4", "device123", string(make([]byte, 128)))
+	out, err := srv.TopicEventsHandler(ctx, &pb.TopicEventsRequest{Events: []*pb.TopicEventRequest{
+		message("ev1", "device123", "a"),
+		message("ev2", "device234", "b"),
+		message("ev3", "device123", "c"),
+		oversized,
+	}})
+
+	assert.Nil(t, err)
+	assert.Len(t, out.Statuses, 4)
+	for index, id := range []string{"ev1", "ev2", "ev3"} {
+		assert.Equal(t, id, out.Statuses[index].EntryId)
+		assert.Equal(t, SubscriptionResponseStatusSuccess, out.Statuses[index].Status)
+	}
+	assert.Equal(t, SubscriptionResponseStatusDrop, out.Statuses[3].Status)
+
+	// every message submitted as an event of its own, in batch order.
+	values := make(map[string][]string)
+	for index := 0; index < 3; index++ {
+		ev := <-handled
+		assert.Len(t, ev.GetPatches().Patches, 1)
+		values[ev.Entity()] = append(values[ev.Entity()], string(ev.GetPatches().Patches[0].Value))
+	}
+	assert.Equal(t, []string{`"a"`, `"c"`}, values["device123"])
+	assert.Equal(t, []string{`"b"`}, values["device234"])
+}
+
+func TestTopicService_OnMessagesRetryInOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// worker blocked, queue of one message saturated by the first message.
+	block := make(chan struct{})
+	srv := &TopicService{}
+	srv.ingress = newIngressPool(ctx, 1, 1, func(ctx context.Context, ev *pb.ProtoEvent) error {
+		<-block
+		return nil
+	})
+
+	message := func(id, entityID string) *pb.TopicEventRequest {
+		return &pb.TopicEventRequest{Meta: &pb.Metadata{Id: id},
+			RawData: []byte(`{"id":"` + entityID + `","data":{"rawData":"a"}}`)}
+	}
+
+	srv.ingress.Submit(&pb.ProtoEvent{Metadata: map[string]string{pb.MetaEntityID: "device123"}})
+	time.Sleep(10 * time.Millisecond)
+	time.AfterFunc(30*time.Millisecond, func() { close(block) })
+	statuses := srv.OnMessages(ctx, []*pb.TopicEventRequest{
+		message("ev1", "device123"),
+		message("ev2", "device123"),
+		message("ev3", "device123"),
+	})
+
+	assert.Equal(t, SubscriptionResponseStatusSuccess, statuses[0].Status)
+	assert.Equal(t, SubscriptionResponseStatusRetry, statuses[1].Status)
+	assert.ErrorIs(t, statuses[1].Error, xerrors.ErrQueueFull)
+	// later messages of the entity retried instead of submitted out of order.
+	assert.Equal(t, SubscriptionResponseStatusRetry, statuses[2].Status)
+}
+
+func TestTopicService_OnMessagesAckHandled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// messages acked once handled, messages failed dropped as the sync path does.
+	srv := &TopicService{}
+	srv.ingress = newIngressPool(ctx, 1, 10, func(ctx context.Context, ev *pb.ProtoEvent) error {
+		if ev.Entity() == "device234" {
+			return xerrors.ErrInternal
+		}
+		return nil
+	})
+
+	message := func(id, entityID string) *pb.TopicEventRequest {
+		return &pb.TopicEventRequest{Meta: &pb.Metadata{Id: id},
+			RawData: []byte(`{"id":"` + entityID + `","data":{"rawData":"a"}}`)}
+	}
+
+	statuses := srv.OnMessages(ctx, []*pb.TopicEventRequest{
+		message("ev1", "device123"),
+		message("ev2", "device234"),
+	})
+	assert.Equal(t, SubscriptionResponseStatusSuccess, statuses[0].Status)
+	assert.Equal(t, SubscriptionResponseStatusDrop, statuses[1].Status)
+	assert.ErrorIs(t, statuses[1].Error, xerrors.ErrInternal)
+
+	// messages not handled before ctx done retried.
+	block := make(chan struct{})
+	defer close(block)
+	srv.ingress = newIngressPool(ctx, 1, 10, func(ctx context.Context, ev *pb.ProtoEvent) error {
+		<-block
+		return nil
+	})
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer timeoutCancel()
+	statuses = srv.OnMessages(timeoutCtx, []*pb.TopicEventRequest{message("ev3", "device123")})
+	assert.Equal(t, SubscriptionResponseStatusRetry, statuses[0].Status)
+	assert.ErrorIs(t, statuses[0].Error, xerrors.ErrQueueFull)
+}
@@ -81,12 +81,10 @@ func (s *TSService) GetTSData(ctx context.Context, req *pb.GetTSDataRequest) (*p
 			log.L().Error("auth error", logf.Error(err))
 		}
 	}
+	var err error
 	resp := &pb.GetTSDataResponse{}
-	if req.PageNum <= 0 {
-		req.PageNum = 1
-	}
-	if req.PageSize <= 0 {
-		req.PageSize = 0
+	if req.PageNum, req.PageSize, err = pageOf(req.PageNum, req.PageSize); nil != err {
+		return nil, errors.Wrap(err, "query time series data")
 	}
 
 	res, err := s.tseriesClient.Query(ctx, req)
@@ -96,6 +94,8 @@ func (s *TSService) GetTSData(ctx context.Context, req *pb.GetTSDataRequest) (*p
 	s.entityHistory.AddEnity(user, req.Id)
 	resp.Total = res.Total
 	resp.Items = res.Items
+	resp.PageNum = req.PageNum
+	resp.PageSize = req.PageSize
 	return resp, nil
 }
 
@@ -23,13 +23,28 @@ import (
 type Entity = apim.Base
 
 const (
-	HeaderSource      = "Source"
-	HeaderTopic       = "Topic"
-	HeaderOwner       = "Owner"
-	HeaderType        = "Type"
-	HeaderMetadata    = "Metadata"
-	HeaderContentType = "Content-Type"
-	QueryType         = "type"
+	HeaderSource         = "Source"
+	HeaderTopic          = "Topic"
+	HeaderOwner          = "Owner"
+	HeaderType           = "Type"
+	HeaderMetadata       = "Metadata"
+	HeaderIdentity       = "X-Tkeel-Identity"
+	HeaderPropSource     = "Property-Source"
+	HeaderPropNamespace  = "Property-Namespace"
+	HeaderResolveBlob    = "Resolve-Blob"
+	HeaderHighlight      = "Search-Highlight"
+	HeaderFreshToken     = "Search-Fresh-Token"
+	HeaderFreshTimeout   = "Search-Fresh-Timeout"
+	HeaderResolveRefs    = "Search-Resolve-Refs"
+	HeaderReadRepair     = "Search-Read-Repair"
+	HeaderSyncIndex      = "Search-Sync-Index"
+	HeaderSequence       = "Message-Sequence"
+	HeaderReadPreference = "Read-Preference"
+	HeaderSubscribeType  = "Subscribe-Type"
+	HeaderDelivery       = "Subscribe-Delivery"
+	HeaderMapperTypes    = "Mapper-Types"
+	HeaderContentType    = "Content-Type"
+	QueryType            = "type"
 
 	Plugin = "plugin"
 	User   = "user_id"
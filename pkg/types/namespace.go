@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The tKeel Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import "strings"
+
+// NamespaceSeparator separates source namespace and key of namespaced properties.
+//
+// properties written with a namespace are stored under top level keys formatted as
+// `<namespace>__<key>`, e.g. `properties.temp` written with namespace sensor-a is stored
+// as `properties.sensor-a__temp`, nested paths keep the rest, `properties.sensor-a__gps.lat`.
+// the namespace carried by the Property-Namespace header of api writes, or "namespace" of topic messages.
+const NamespaceSeparator = "__"
+
+// NamespacedKey returns key of the property path namespaced, only the top level key prefixed.
+func NamespacedKey(namespace, key string) string {
+	return namespace + NamespaceSeparator + key
+}
+
+// ValidNamespace namespace non empty, without dots, brackets or the separator.
+func ValidNamespace(namespace string) bool {
+	return namespace != "" &&
+		!strings.ContainsAny(namespace, ".[]") &&
+		!strings.Contains(namespace, NamespaceSeparator)
+}
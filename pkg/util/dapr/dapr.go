@@ -1,11 +1,16 @@
 package dapr
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
 	"sync"
 
 	logf "github.com/tkeel-io/core/pkg/logfield"
 
 	daprSDK "github.com/dapr/go-sdk/client"
+	"github.com/pkg/errors"
 	"github.com/tkeel-io/kit/log"
 )
 
@@ -49,6 +54,31 @@ func (p *daprClientPool) Select() daprSDK.Client {
 	return p.client
 }
 
+// Healthz checks health of the dapr sidecar.
+func Healthz(ctx context.Context) error {
+	port := os.Getenv("DAPR_HTTP_PORT")
+	if port == "" {
+		port = "3500"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("http://localhost:%s/v1.0/healthz", port), nil)
+	if nil != err {
+		return errors.Wrap(err, "dapr healthz")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if nil != err {
+		return errors.Wrap(err, "dapr healthz")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("dapr healthz, status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 func init() {
 	pool = &daprClientPool{}
 }
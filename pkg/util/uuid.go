@@ -3,6 +3,9 @@ package util
 import (
 	"crypto/rand"
 	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
 )
 
 const (
@@ -10,8 +13,12 @@ const (
 	defaultEventPrefix        = "ev-"
 	defaultRequestPrefix      = "req-"
 	defaultSubscriptionPrefix = "sub-"
+	defaultTracePrefix        = "trace-"
 )
 
+// randReader random source of uuids, replaced in tests.
+var randReader io.Reader = rand.Reader
+
 func IG() *idGenerator { //nolint
 	return &idGenerator{}
 }
@@ -30,6 +37,19 @@ func (ig *idGenerator) EID() string {
 	return UUID(defaultEntityPrefix)
 }
 
+// NewEIDWith returns an entity id with the prefix prepended, fails if no random available.
+func (ig *idGenerator) NewEIDWith(prefix string) (string, error) {
+	if prefix == "" {
+		return NewUUID(defaultEntityPrefix)
+	}
+
+	uid, err := NewUUID("")
+	if nil != err {
+		return "", err
+	}
+	return prefix + uid, nil
+}
+
 // returns an event id.
 func (ig *idGenerator) EvID() string {
 	return UUID(defaultEventPrefix)
@@ -45,16 +65,27 @@ func (ig *idGenerator) SubID() string {
 	return UUID(defaultSubscriptionPrefix)
 }
 
+// returns a trace id.
+func (ig *idGenerator) TraceID() string {
+	return UUID(defaultTracePrefix)
+}
+
 // generate id with prefix.
 func (ig *idGenerator) With(prefix string) {
 	ig.prefix = prefix
 }
 
-// uuid generate an uuid.
+// uuid generate an uuid, empty if no random available, see NewUUID.
 func UUID(prefix string) string {
+	uid, _ := NewUUID(prefix)
+	return uid
+}
+
+// NewUUID generate an uuid, fails if no random available.
+func NewUUID(prefix string) (string, error) {
 	uuid := make([]byte, 16)
-	if _, err := rand.Read(uuid); err != nil {
-		return ""
+	if _, err := io.ReadFull(randReader, uuid); err != nil {
+		return "", errors.Wrap(err, "generate uuid")
 	}
 	// see section 4.1.1.
 	uuid[8] = uuid[8]&^0xc0 | 0x80
@@ -66,5 +97,5 @@ func UUID(prefix string) string {
 	if prefixLen > 0 && prefixLen < 15 {
 		uid = prefix + uid[prefixLen:]
 	}
-	return uid
+	return uid, nil
 }
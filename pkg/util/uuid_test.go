@@ -0,0 +1,37 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failReader struct{}
+
+func (failReader) Read(p []byte) (int, error) {
+	return 0, errors.New("entropy exhausted")
+}
+
+func TestNewUUID(t *testing.T) {
+	uid, err := NewUUID("en-")
+	assert.Nil(t, err)
+	assert.Len(t, uid, 36)
+	assert.Equal(t, "en-", uid[:3])
+
+	defer func(r io.Reader) { randReader = r }(randReader)
+	randReader = bytes.NewReader(make([]byte, 16))
+	uid, err = IG().NewEIDWith("device-")
+	assert.Nil(t, err)
+	assert.Equal(t, "device-00000000-0000-4000-8000-000000000000", uid)
+
+	// no random available.
+	randReader = failReader{}
+	uid, err = NewUUID("en-")
+	assert.NotNil(t, err)
+	assert.Empty(t, uid)
+	_, err = IG().NewEIDWith("")
+	assert.NotNil(t, err)
+}